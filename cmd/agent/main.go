@@ -2,25 +2,57 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
+	"milvus-coredump-agent/pkg/alertrules"
 	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/api"
+	"milvus-coredump-agent/pkg/chanstats"
+	"milvus-coredump-agent/pkg/chaos"
 	"milvus-coredump-agent/pkg/cleaner"
 	"milvus-coredump-agent/pkg/collector"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/crashlogs"
+	"milvus-coredump-agent/pkg/crashmetrics"
+	"milvus-coredump-agent/pkg/cri"
+	"milvus-coredump-agent/pkg/crstatus"
+	"milvus-coredump-agent/pkg/devmode"
 	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/fanout"
+	"milvus-coredump-agent/pkg/ghissues"
+	"milvus-coredump-agent/pkg/httputil"
+	"milvus-coredump-agent/pkg/kubeevents"
+	"milvus-coredump-agent/pkg/leader"
 	"milvus-coredump-agent/pkg/monitor"
+	"milvus-coredump-agent/pkg/nodecondition"
+	"milvus-coredump-agent/pkg/notes"
+	"milvus-coredump-agent/pkg/preflight"
+	"milvus-coredump-agent/pkg/pressure"
+	"milvus-coredump-agent/pkg/procwatch"
+	"milvus-coredump-agent/pkg/reload"
+	"milvus-coredump-agent/pkg/sandbox"
+	"milvus-coredump-agent/pkg/similarity"
 	"milvus-coredump-agent/pkg/storage"
+	"milvus-coredump-agent/pkg/tracing"
+	"milvus-coredump-agent/pkg/watchlist"
 )
 
 var (
@@ -28,6 +60,11 @@ var (
 	kubeconfig   = flag.String("kubeconfig", "", "Path to kubeconfig file (optional, uses in-cluster config if not provided)")
 	healthAddr   = flag.String("health-addr", ":8081", "Health check server address")
 	metricsAddr  = flag.String("metrics-addr", ":8080", "Metrics server address")
+	apiAddr      = flag.String("api-addr", ":8082", "Query API server address")
+	devMode      = flag.Bool("dev", false, "Run without Kubernetes against synthetic Milvus instances and coredumps")
+	devCrashInterval = flag.Duration("dev-crash-interval", 30*time.Second, "Interval between synthetic crashes in --dev mode")
+	selfTest     = flag.Bool("selftest", false, "Run the pipeline self-test against the agent running on this node and exit")
+	selfTestTimeout = flag.Duration("selftest-timeout", 2*time.Minute, "How long --selftest waits for the pipeline")
 	version      = "dev"
 	buildTime    = "unknown"
 	gitCommit    = "unknown"
@@ -44,21 +81,59 @@ func main() {
 		klog.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	var devCoredumpDir string
+	if *devMode {
+		devCoredumpDir, err = os.MkdirTemp("", "milvus-coredump-agent-dev")
+		if err != nil {
+			klog.Fatalf("Failed to create dev coredump directory: %v", err)
+		}
+		defer os.RemoveAll(devCoredumpDir)
+
+		klog.Warning("Running in dev mode: Kubernetes, gdb, Helm and external APIs are replaced by synthetic data")
+		devmode.ApplyConfig(cfg, devCoredumpDir)
+	}
+
 	if err := cfg.Validate(); err != nil {
 		klog.Fatalf("Invalid configuration: %v", err)
 	}
 
-	kubeClient, err := createKubernetesClient()
-	if err != nil {
-		klog.Fatalf("Failed to create Kubernetes client: %v", err)
+	if *selfTest {
+		if err := runSelfTest(cfg, *selfTestTimeout); err != nil {
+			klog.Errorf("Self-test failed: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var kubeClient kubernetes.Interface
+	var dynamicClient dynamic.Interface
+	if *devMode {
+		kubeClient = devmode.NewClient()
+	} else {
+		kubeClient, dynamicClient, err = createKubernetesClient()
+		if err != nil {
+			klog.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	if *devMode {
+		generator := devmode.NewGenerator(kubeClient, devCoredumpDir, *devCrashInterval)
+		go func() {
+			if err := generator.Start(ctx); err != nil {
+				klog.Errorf("Synthetic crash generator failed: %v", err)
+			}
+		}()
+	}
+
 	agent := &Agent{
-		config:     cfg,
-		kubeClient: kubeClient,
+		config:        cfg,
+		configPath:    *configPath,
+		devCoredumpDir: devCoredumpDir,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
 	}
 
 	if err := agent.Run(ctx); err != nil {
@@ -69,40 +144,303 @@ func main() {
 }
 
 type Agent struct {
-	config     *config.Config
-	kubeClient kubernetes.Interface
+	config        *config.Config
+	configPath    string
+	// Set in dev mode
+	devCoredumpDir string
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
 }
 
 func (a *Agent) Run(ctx context.Context) error {
+	klog.Info("Running preflight checks")
+	preflightReport := preflight.Run(ctx, a.config)
+	if !preflightReport.Ready && preflightReport.Policy == preflight.FailurePolicyFailFast {
+		var failures []string
+		for _, check := range preflightReport.Failed() {
+			failures = append(failures, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+		return fmt.Errorf("preflight checks failed: %s", strings.Join(failures, "; "))
+	}
+
 	klog.Info("Initializing agent components")
 
+	if a.config.Monitor.Tracing.Enabled {
+		tracer, err := tracing.New(ctx, &a.config.Monitor.Tracing, os.Getenv("NODE_NAME"), version)
+		if err != nil {
+			return fmt.Errorf("failed to set up tracing: %w", err)
+		}
+		// After the analyzer drained, so its last spans are exported.
+		defer tracer.Shutdown()
+	}
+
+	pressureTracker := pressure.New(&a.config.Agent.Pressure)
+	
+	watchdog := procwatch.New(&a.config.Analyzer.Watchdog)
+	
+	states := collector.NewStateMachine()
+	
 	discoveryManager := discovery.New(a.kubeClient, &a.config.Discovery)
 	
-	collectorManager := collector.New(&a.config.Collector, discoveryManager)
+	var chaosTracker *chaos.Tracker
+	if a.config.Discovery.Chaos.Enabled && a.dynamicClient != nil {
+		chaosTracker = chaos.New(&a.config.Discovery.Chaos, a.dynamicClient)
+	}
+	
+	var crTracker *crstatus.Tracker
+	if a.config.Discovery.MilvusCR.Enabled && a.dynamicClient != nil {
+		crTracker = crstatus.New(&a.config.Discovery.MilvusCR, a.dynamicClient)
+	}
+	
+	containerResolver, err := cri.New(&a.config.Collector.CRI, a.config.Collector.ProcPath)
+	if err != nil {
+		return fmt.Errorf("failed to set up container resolution: %w", err)
+	}
+	defer containerResolver.Close()
 	
-	analyzerManager := analyzer.New(&a.config.Analyzer)
+	var collectorState, cleanerState, retryState, aiUsageState, notesState, contentState, alertRulesState string
+	if a.config.Agent.StateDir != "" {
+		collectorState = filepath.Join(a.config.Agent.StateDir, "processed-files.json")
+		cleanerState = filepath.Join(a.config.Agent.StateDir, "restart-trackers.json")
+		retryState = filepath.Join(a.config.Agent.StateDir, "analysis-retries.json")
+		aiUsageState = filepath.Join(a.config.Agent.StateDir, "ai-usage.json")
+		notesState = filepath.Join(a.config.Agent.StateDir, "notes.json")
+		contentState = filepath.Join(a.config.Agent.StateDir, "content-refs.json")
+		alertRulesState = filepath.Join(a.config.Agent.StateDir, "alert-rules.json")
+	}
+	
+	collectorManager := collector.New(&a.config.Collector, discoveryManager, pressureTracker, chaosTracker, crTracker, containerResolver, os.Getenv("NODE_NAME"), collectorState)
+	states.OnFinal(collectorManager.Finished)
+	
+	var crashGroups *crashgroup.Registry
+	if a.config.Analyzer.CrashGroups.Enabled {
+		crashGroups = crashgroup.New(a.config.Analyzer.CrashGroups.MaxGroups)
+	}
 	
-	storageManager, err := storage.New(&a.config.Storage, &a.config.Analyzer)
+	watched, err := watchlist.New(a.config.Analyzer.Watchlist)
+	if err != nil {
+		return fmt.Errorf("failed to load watchlist: %w", err)
+	}
+	
+	crashLogs, err := crashlogs.New(&a.config.Analyzer.PreCrashLogs)
+	if err != nil {
+		return fmt.Errorf("failed to create pre-crash log fetcher: %w", err)
+	}
+	
+	crashMetrics, err := crashmetrics.New(&a.config.Analyzer.PreCrashMetrics)
+	if err != nil {
+		return fmt.Errorf("failed to create pre-crash metrics fetcher: %w", err)
+	}
+	
+	issueMatcher, err := ghissues.New(&a.config.Analyzer.GitHubIssues)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub issue matcher: %w", err)
+	}
+	
+	similarCrashes, err := similarity.New(&a.config.Analyzer.Similarity, os.Getenv("NODE_NAME"))
+	if err != nil {
+		return fmt.Errorf("failed to create similarity index: %w", err)
+	}
+	
+	analyzerManager := analyzer.New(&a.config.Analyzer, pressureTracker, watchdog, states, crashGroups, watched, crashLogs, crashMetrics, retryState)
+	analyzerManager.UseSimilarity(similarCrashes)
+	analyzerManager.UseIssueMatcher(issueMatcher)
+	if a.config.Analyzer.Sandbox.Enabled {
+		runner, err := sandbox.New(&a.config.Analyzer.Sandbox, a.kubeClient, os.Getenv("NODE_NAME"), agentNamespace(a.config))
+		if err != nil {
+			return fmt.Errorf("failed to create gdb sandbox: %w", err)
+		}
+		analyzerManager.UseSandbox(runner)
+	}
+	analyzerManager.UseAIUsageState(aiUsageState)
+	
+	storageManager, err := storage.New(&a.config.Storage, &a.config.Analyzer, states, os.Getenv("NODE_NAME"), contentState)
 	if err != nil {
 		return fmt.Errorf("failed to create storage manager: %w", err)
 	}
 	
-	cleanerManager := cleaner.New(&a.config.Cleaner, a.kubeClient, discoveryManager)
+	var elector *leader.Elector
+	if a.config.Agent.LeaderElection.Enabled {
+		elector = leader.New(&a.config.Agent.LeaderElection, a.kubeClient, agentIdentity(), agentNamespace(a.config))
+	}
+	
+	cleanerManager := cleaner.New(&a.config.Cleaner, a.kubeClient, discoveryManager, elector, *kubeconfig, cleanerState)
+	
+	var reloader *reload.Watcher
+	if a.config.Agent.ConfigReload.Enabled {
+		reloader = reload.New(&a.config.Agent.ConfigReload, a.configPath, a.config, a.prepareReload(ctx), func(cfg *config.Config) {
+			collectorManager.UpdateConfig(&cfg.Collector)
+			analyzerManager.UpdateConfig(&cfg.Analyzer)
+			storageManager.UpdateConfig(&cfg.Storage, &cfg.Analyzer)
+			cleanerManager.UpdateConfig(&cfg.Cleaner)
+		})
+	}
+	
+	var alertRules *alertrules.Engine
+	if a.config.Monitor.Alerting.Enabled {
+		alertRules, err = alertrules.New(a.config.Monitor.Alerting.Rules, alertRulesState)
+		if err != nil {
+			return fmt.Errorf("failed to load alert rules: %w", err)
+		}
+	}
 	
 	var monitorManager *monitor.Monitor
 	if a.config.Monitor.PrometheusEnabled {
-		monitorManager = monitor.New(&a.config.Monitor)
+		monitorManager = monitor.New(&a.config.Monitor, a.config.Analyzer.EffectiveThresholds(), pressureTracker, watchdog, states, discoveryManager, analyzerManager, storageManager)
+		monitorManager.UseAlertRules(alertRules)
+	}
+
+	var apiStore *api.Store
+	if a.config.API.Enabled {
+		apiStore = api.NewStore(a.config.API.MaxRecords, a.config.API.StatsCacheTTL)
+	}
+
+	var conditionReporter *nodecondition.Reporter
+	if a.config.Monitor.NodeConditions.Enabled {
+		conditionReporter = nodecondition.New(&a.config.Monitor.NodeConditions, a.kubeClient, os.Getenv("NODE_NAME"))
+	}
+
+	var eventRecorder *kubeevents.Recorder
+	if a.config.Monitor.KubeEvents.Enabled {
+		eventRecorder = kubeevents.New(&a.config.Monitor.KubeEvents, a.kubeClient, discoveryManager, os.Getenv("NODE_NAME"))
+	}
+
+	// Every pipeline stage has a single event channel; give each consumer
+	// its own copy so they don't steal events from one another.
+	consumers := 1
+	if monitorManager != nil {
+		consumers++
+	}
+	if apiStore != nil {
+		consumers++
+	}
+	// The node condition reporter only follows collector events.
+	collectorConsumers := consumers
+	if conditionReporter != nil {
+		collectorConsumers++
+	}
+	// The event recorder takes the last copy of the streams it follows.
+	analyzerConsumers := consumers
+	if eventRecorder != nil {
+		collectorConsumers++
+		analyzerConsumers++
+	}
+	collectorEvents := fanout.Split(ctx, collectorManager.GetEventChannel(), collectorConsumers, 100)
+	analyzerEvents := fanout.Split(ctx, analyzerManager.GetEventChannel(), analyzerConsumers, 100)
+	storageEvents := fanout.Split(ctx, storageManager.GetEventChannel(), consumers, 100)
+	next := 1
+	// Cleaner events have no pipeline stage after them; only the monitor,
+	// the API's event stream and the event recorder follow them.
+	cleanerConsumers := consumers - 1
+	if eventRecorder != nil {
+		cleanerConsumers++
+	}
+	var cleanerEvents []<-chan cleaner.CleanupEvent
+	if cleanerConsumers > 0 {
+		cleanerEvents = fanout.Split(ctx, cleanerManager.GetEventChannel(), cleanerConsumers, 100)
 	}
 
-	klog.Info("Starting health and metrics servers")
-	go a.startHealthServer(ctx)
+	servers, err := httputil.New(&a.config.Server)
+	if err != nil {
+		return fmt.Errorf("failed to set up HTTP servers: %w", err)
+	}
+	health := healthHandler(preflightReport, storageManager, pressureTracker)
+	healthAddr := listenAddr(a.config.Server.HealthAddr, "health-addr")
+	for _, pattern := range []string{"/healthz", "/healthz/", "/readyz", "/version"} {
+		servers.Handle(healthAddr, "health", pattern, health, true)
+	}
 	if monitorManager != nil {
-		go a.startMetricsServer(ctx, monitorManager)
+		servers.Handle(listenAddr(a.config.Server.MetricsAddr, "metrics-addr"), "metrics", "/metrics", monitorManager.GetHandler(), false)
+	}
+	if apiStore != nil {
+		var groupSource api.CrashGroupSource
+		if crashGroups != nil {
+			groupSource = crashGroups
+		}
+		var conditionSource api.ConditionSource
+		if crTracker != nil {
+			conditionSource = crTracker
+		}
+		thresholds := a.config.Analyzer.EffectiveThresholds()
+		apiServer := api.NewServer(apiStore, discoveryManager, storageManager, groupSource, conditionSource, &thresholds)
+		apiServer.HandleScoring(a.config.Analyzer.PanicKeywords)
+		apiServer.HandleLifecycleStats(&a.config.Monitor.LifecycleSLA)
+		apiServer.HandleReanalyze(analyzerManager)
+		apiServer.HandleAIUsage(analyzerManager)
+		apiServer.HandleNotes(notes.New(notesState))
+		if alertRules != nil {
+			apiServer.HandleAlertRules(alertRules)
+		}
+		if similarCrashes != nil {
+			apiServer.HandleSimilarity(similarCrashes)
+		}
+		apiServer.HandleSelfTest(a.config.Collector.CoredumpPath)
+		if a.config.API.Node.Enabled {
+			nodeAPI, err := api.NewNodeAPI(apiStore, &a.config.API.Node, nodeHealth(preflightReport, pressureTracker))
+			if err != nil {
+				return fmt.Errorf("failed to create node API: %w", err)
+			}
+			apiServer.HandleNode(nodeAPI)
+		}
+		if a.config.API.Embed.Enabled {
+			embedAPI, err := api.NewEmbedAPI(apiStore, groupSource, &a.config.API.Embed)
+			if err != nil {
+				return fmt.Errorf("failed to create embed API: %w", err)
+			}
+			apiServer.HandleEmbed(embedAPI)
+		}
+		// The node and embed APIs check their own tokens.
+		apiAddr := listenAddr(a.config.Server.APIAddr, "api-addr")
+		servers.Handle(apiAddr, "api", "/", apiServer.Handler(), false)
+		servers.Handle(apiAddr, "api", "/api/v1/node/", apiServer.Handler(), true)
+		servers.Handle(apiAddr, "api", "/api/v1/embed/", apiServer.Handler(), true)
 	}
 
 	klog.Info("Starting agent components")
 	
-	errChan := make(chan error, 5)
+	errChan := make(chan error, 16)
+
+	go func() {
+		if err := servers.Run(ctx); err != nil {
+			errChan <- fmt.Errorf("HTTP servers failed: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := pressureTracker.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("pressure tracker failed: %w", err)
+		}
+	}()
+	
+	go func() {
+		if err := watchdog.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("subprocess watchdog failed: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := chaosTracker.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("chaos tracker failed: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := crTracker.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("Milvus CR status tracker failed: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := elector.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("leader election failed: %w", err)
+		}
+	}()
+
+	go func() {
+		if err := reloader.Start(ctx); err != nil {
+			errChan <- fmt.Errorf("config reload failed: %w", err)
+		}
+	}()
 
 	go func() {
 		if err := discoveryManager.Start(ctx); err != nil {
@@ -116,49 +454,115 @@ func (a *Agent) Run(ctx context.Context) error {
 		}
 	}()
 
+	// Closed once the analyzer drained its queue after shutdown.
+	analyzerDone := make(chan struct{})
 	go func() {
-		collectorEvents := collectorManager.GetEventChannel()
-		if err := analyzerManager.Start(ctx, collectorEvents); err != nil {
+		defer close(analyzerDone)
+		if err := analyzerManager.Start(ctx, collectorEvents[0]); err != nil {
 			errChan <- fmt.Errorf("analyzer manager failed: %w", err)
 		}
 	}()
 
 	go func() {
-		analyzerEvents := analyzerManager.GetEventChannel()
-		if err := storageManager.Start(ctx, analyzerEvents); err != nil {
+		if err := storageManager.Start(ctx, analyzerEvents[0]); err != nil {
 			errChan <- fmt.Errorf("storage manager failed: %w", err)
 		}
 	}()
 
 	go func() {
-		storageEvents := storageManager.GetEventChannel()
-		if err := cleanerManager.Start(ctx, storageEvents); err != nil {
+		if err := cleanerManager.Start(ctx, storageEvents[0]); err != nil {
 			errChan <- fmt.Errorf("cleaner manager failed: %w", err)
 		}
 	}()
 
 	if monitorManager != nil {
+		channels := &monitor.Channels{
+			CollectorEvents: collectorEvents[next],
+			AnalyzerEvents:  analyzerEvents[next],
+			StorageEvents:   storageEvents[next],
+			CleanerEvents:   cleanerEvents[next-1],
+			StateTransitions: states.GetEventChannel(),
+		}
+		next++
 		go func() {
-			if err := monitorManager.Start(ctx, a.getMonitoringChannels(
-				collectorManager, analyzerManager, storageManager, cleanerManager,
-			)); err != nil {
+			if err := monitorManager.Start(ctx, channels); err != nil {
 				errChan <- fmt.Errorf("monitor manager failed: %w", err)
 			}
 		}()
 	}
 
+	if apiStore != nil {
+		channels := &api.Channels{
+			CollectorEvents: collectorEvents[next],
+			AnalyzerEvents:  analyzerEvents[next],
+			StorageEvents:   storageEvents[next],
+			CleanerEvents:   cleanerEvents[next-1],
+			ConfigEvents:    reloader.GetEventChannel(),
+		}
+		go func() {
+			if err := apiStore.Start(ctx, channels); err != nil {
+				errChan <- fmt.Errorf("API store failed: %w", err)
+			}
+		}()
+	}
+
+	if conditionReporter != nil {
+		events := collectorEvents[consumers]
+		go func() {
+			if err := conditionReporter.Start(ctx, events); err != nil {
+				errChan <- fmt.Errorf("node condition reporter failed: %w", err)
+			}
+		}()
+	}
+
+	if eventRecorder != nil {
+		channels := &kubeevents.Channels{
+			CollectorEvents: collectorEvents[len(collectorEvents)-1],
+			AnalyzerEvents:  analyzerEvents[len(analyzerEvents)-1],
+			CleanerEvents:   cleanerEvents[len(cleanerEvents)-1],
+		}
+		go func() {
+			if err := eventRecorder.Start(ctx, channels); err != nil {
+				errChan <- fmt.Errorf("Kubernetes event recorder failed: %w", err)
+			}
+		}()
+	}
+
 	klog.Info("All components started successfully")
 
 	select {
 	case <-ctx.Done():
 		klog.Info("Shutdown signal received")
+		<-analyzerDone
 		return nil
 	case err := <-errChan:
 		return err
 	}
 }
 
-func (a *Agent) startHealthServer(ctx context.Context) {
+// prepareReload prepares a reloaded configuration the way main and Run
+// prepared the one the agent started with: the dev mode overrides, then
+// the preflight checks, which turn off again what the node can't support.
+func (a *Agent) prepareReload(ctx context.Context) func(*config.Config) error {
+	return func(cfg *config.Config) error {
+		if a.devCoredumpDir != "" {
+			devmode.ApplyConfig(cfg, a.devCoredumpDir)
+		}
+		report := preflight.Run(ctx, cfg)
+		if !report.Ready && report.Policy == preflight.FailurePolicyFailFast {
+			var failures []string
+			for _, check := range report.Failed() {
+				failures = append(failures, check.Name)
+			}
+			return fmt.Errorf("preflight checks failed: %s", strings.Join(failures, ", "))
+		}
+		return nil
+	}
+}
+
+// healthHandler serves the probes and status endpoints, which stay open
+// to the kubelet even when the other endpoints require a token.
+func healthHandler(preflightReport *preflight.Report, storageManager *storage.Storage, pressureTracker *pressure.Tracker) http.Handler {
 	mux := http.NewServeMux()
 	
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -167,8 +571,59 @@ func (a *Agent) startHealthServer(ctx context.Context) {
 	})
 	
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !preflightReport.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(preflightReport)
+	})
+	
+	mux.HandleFunc("/healthz/storage", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		result := storageManager.GetSelfTestResult()
+		if result == nil {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"not_run"}`))
+			return
+		}
+		if !result.Passed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	
+	mux.HandleFunc("/healthz/pressure", func(w http.ResponseWriter, r *http.Request) {
+		// Degraded mode is reported but not treated as unhealthy: the agent
+		// is deliberately doing less work, restarting it would not help.
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Ready"))
+		json.NewEncoder(w).Encode(pressureTracker.Status())
+	})
+	
+	mux.HandleFunc("/healthz/events", func(w http.ResponseWriter, r *http.Request) {
+		// Like pressure, dropped events are reported but do not fail the
+		// probe; dashboards show the warning as a banner.
+		channels := chanstats.Snapshot()
+		since := time.Now().Add(-chanstats.RecentDropWindow)
+		status := struct {
+			Dropping bool              `json:"dropping"`
+			Warning  string            `json:"warning,omitempty"`
+			Channels []chanstats.Stats `json:"channels"`
+		}{Channels: channels}
+		var dropping []string
+		for _, channel := range channels {
+			if channel.DroppingSince(since) {
+				dropping = append(dropping, channel.Name)
+			}
+		}
+		if len(dropping) > 0 {
+			status.Dropping = true
+			status.Warning = fmt.Sprintf("events were dropped in the last %v on %s; some coredumps may be missing from results",
+				chanstats.RecentDropWindow, strings.Join(dropping, ", "))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
 	})
 	
 	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
@@ -178,58 +633,119 @@ func (a *Agent) startHealthServer(ctx context.Context) {
 			version, buildTime, gitCommit)
 	})
 
-	server := &http.Server{
-		Addr:    *healthAddr,
-		Handler: mux,
-	}
+	return mux
+}
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		server.Shutdown(shutdownCtx)
-	}()
+// nodeHealth reports the agent's health to node-local tools through the
+// node API.
+func nodeHealth(preflightReport *preflight.Report, pressureTracker *pressure.Tracker) api.NodeHealthFunc {
+	nodeName := os.Getenv("NODE_NAME")
+	return func() api.NodeHealth {
+		now := time.Now()
+		status := pressureTracker.Status()
+		health := api.NodeHealth{
+			NodeName:       nodeName,
+			Ready:          preflightReport.Ready,
+			Degraded:       status.Degraded,
+			DegradedReason: status.Reason,
+			CheckedAt:      now,
+		}
+		since := now.Add(-chanstats.RecentDropWindow)
+		for _, channel := range chanstats.Snapshot() {
+			if channel.DroppingSince(since) {
+				health.DroppingEvents = true
+			}
+		}
+		return health
+	}
+}
 
-	klog.Infof("Health server listening on %s", *healthAddr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		klog.Errorf("Health server failed: %v", err)
+// listenAddr returns the configured address of a listener, unless its flag
+// was set explicitly.
+func listenAddr(configured, flagName string) string {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == flagName
+	})
+	if configured == "" || explicit {
+		return flag.Lookup(flagName).Value.String()
 	}
+	return configured
 }
 
-func (a *Agent) startMetricsServer(ctx context.Context, monitorManager *monitor.Monitor) {
-	server := &http.Server{
-		Addr:    *metricsAddr,
-		Handler: monitorManager.GetHandler(),
+// runSelfTest asks the agent listening on this host's API address to run
+// the pipeline self-test and prints the result.
+func runSelfTest(cfg *config.Config, timeout time.Duration) error {
+	addr := listenAddr(cfg.Server.APIAddr, "api-addr")
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
 	}
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Server.TLS.CertFile != "" {
+		scheme = "https"
+		// The agent is reached over loopback, not under its certificate's name.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	url := fmt.Sprintf("%s://%s/api/v1/selftest?timeout=%s", scheme, addr, timeout)
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		server.Shutdown(shutdownCtx)
-	}()
+	request, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	// The agent refuses the POST without a token, say why up front.
+	if cfg.Server.TokenFile == "" {
+		return fmt.Errorf("the self-test needs server.tokenFile to be configured")
+	}
+	token, err := os.ReadFile(cfg.Server.TokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read server token: %w", err)
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
 
-	klog.Infof("Metrics server listening on %s", *metricsAddr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		klog.Errorf("Metrics server failed: %v", err)
+	client := &http.Client{Transport: transport, Timeout: timeout + 30*time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to reach the agent at %s: %w", addr, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return fmt.Errorf("agent answered %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result api.SelfTestResult
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid self-test result: %w", err)
+	}
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	if !result.Passed {
+		return fmt.Errorf("%s", result.Error)
 	}
+	return nil
 }
 
-func (a *Agent) getMonitoringChannels(
-	collectorMgr *collector.Collector,
-	analyzerMgr *analyzer.Analyzer,
-	storageMgr *storage.Storage,
-	cleanerMgr *cleaner.Cleaner,
-) *monitor.Channels {
-	return &monitor.Channels{
-		CollectorEvents: collectorMgr.GetEventChannel(),
-		AnalyzerEvents:  analyzerMgr.GetEventChannel(),
-		StorageEvents:   storageMgr.GetEventChannel(),
-		CleanerEvents:   cleanerMgr.GetEventChannel(),
+// agentIdentity names the agent in the leader election: its pod, else
+// its host.
+func agentIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
 	}
+	hostname, _ := os.Hostname()
+	return hostname
 }
 
-func createKubernetesClient() (kubernetes.Interface, error) {
+// agentNamespace is the namespace the agent runs in, as the DaemonSet
+// passes it, else the configured one.
+func agentNamespace(cfg *config.Config) string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return cfg.Agent.Namespace
+}
+
+func createKubernetesClient() (kubernetes.Interface, dynamic.Interface, error) {
 	var kubeConfig *rest.Config
 	var err error
 
@@ -242,7 +758,7 @@ func createKubernetesClient() (kubernetes.Interface, error) {
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create kubeconfig: %w", err)
+		return nil, nil, fmt.Errorf("failed to create kubeconfig: %w", err)
 	}
 
 	kubeConfig.QPS = 50
@@ -250,8 +766,14 @@ func createKubernetesClient() (kubernetes.Interface, error) {
 
 	client, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		return nil, nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	// The dynamic client reads CRs the agent has no typed client for.
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic Kubernetes client: %w", err)
 	}
 
-	return client, nil
+	return client, dynamicClient, nil
 }
\ No newline at end of file
@@ -0,0 +1,200 @@
+// core-handler receives cores from the kernel through core_pattern and
+// streams them to the agent on the same node, so the collector gets every
+// core complete and with the crashed process' metadata, without watching
+// a directory for files that may still be written:
+//
+//	echo '|/usr/local/bin/core-handler %e %p %s %t %u %g %h' > /proc/sys/kernel/core_pattern
+//
+// The kernel starts it as root in the host's namespaces with the core on
+// stdin. When the agent cannot be reached, the core is written to
+// --fallback-dir, as core.<executable>.<pid>.<uid>.<signal>, for the agent
+// to pick up from there once it is back.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/corepipe"
+)
+
+const usage = `Usage: core-handler [flags] %e %p %s %t [%u [%g [%h]]]
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("core-handler", flag.ExitOnError)
+	socket := flags.String("socket", corepipe.DefaultSocket, "Unix socket of the agent")
+	fallbackDir := flags.String("fallback-dir", "", "Directory to write the core to when the agent cannot be reached")
+	timeout := flags.Duration("connect-timeout", 5*time.Second, "How long to try reaching the agent")
+	flags.Usage = func() {
+		os.Stderr.WriteString(usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	header, err := parseArgs(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "core-handler: %v\n", err)
+		flags.Usage()
+		os.Exit(2)
+	}
+	// The kernel keeps the process around until the core is read, so its
+	// cgroup, which names its container, can still be.
+	header.Cgroup = readCgroup(header.PID)
+	if err := handle(header, os.Stdin, *socket, *fallbackDir, *timeout); err != nil {
+		// The kernel discards the output of core_pattern handlers; the
+		// kernel log is where node operators look for lost cores.
+		logKernel(fmt.Sprintf("core-handler: core of %s (pid %d): %v", header.Executable, header.PID, err))
+		os.Exit(1)
+	}
+}
+
+// parseArgs reads the core_pattern specifiers %e %p %s %t, optionally
+// followed by %u, %g and %h.
+func parseArgs(args []string) (corepipe.Header, error) {
+	header := corepipe.Header{UID: -1, GID: -1}
+	if len(args) < 4 || len(args) > 7 {
+		return header, fmt.Errorf("expected 4 to 7 arguments, got %d", len(args))
+	}
+	header.Executable = args[0]
+	numbers := []*int{&header.PID, &header.Signal, nil, &header.UID, &header.GID}
+	for i, arg := range args[1:] {
+		if i == 5 {
+			header.Hostname = arg
+			break
+		}
+		value, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return header, fmt.Errorf("argument %d is not a number: %q", i+2, arg)
+		}
+		if numbers[i] == nil {
+			header.Time = value
+		} else {
+			*numbers[i] = int(value)
+		}
+	}
+	return header, nil
+}
+
+// readCgroup returns /proc/<pid>/cgroup, or nothing when it cannot be read.
+func readCgroup(pid int) string {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	// The header it is sent in is bounded.
+	cgroup, err := io.ReadAll(io.LimitReader(file, 2048))
+	if err != nil {
+		return ""
+	}
+	return string(cgroup)
+}
+
+// handle streams core to the agent, or writes it to fallbackDir when the
+// agent cannot be reached. Once streaming started the core cannot be read
+// again, so a failure after that loses it.
+func handle(header corepipe.Header, core io.Reader, socket, fallbackDir string, timeout time.Duration) error {
+	conn, err := dial(socket, timeout)
+	if err != nil {
+		if fallbackDir == "" {
+			return err
+		}
+		path, writeErr := writeFallback(header, core, fallbackDir)
+		if writeErr != nil {
+			return fmt.Errorf("%v; writing it to %s failed too: %w", err, fallbackDir, writeErr)
+		}
+		logKernel(fmt.Sprintf("core-handler: %v, wrote core of %s (pid %d) to %s", err, header.Executable, header.PID, path))
+		return nil
+	}
+	defer conn.Close()
+
+	writer := bufio.NewWriterSize(conn, 1<<20)
+	if err := corepipe.WriteHeader(writer, header); err != nil {
+		return fmt.Errorf("failed to send header: %w", err)
+	}
+	_, err = io.Copy(writer, core)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		// The agent may have refused the core and said why before closing.
+		if refused := corepipe.ReadReply(conn); refused != nil && !errors.Is(refused, corepipe.ErrNoReply) {
+			return fmt.Errorf("agent refused core: %w", refused)
+		}
+		return fmt.Errorf("failed to send core: %w", err)
+	}
+	if err := conn.CloseWrite(); err != nil {
+		return fmt.Errorf("failed to send core: %w", err)
+	}
+	if err := corepipe.ReadReply(conn); err != nil {
+		return fmt.Errorf("agent refused core: %w", err)
+	}
+	return nil
+}
+
+// dial retries while the agent restarts, up to timeout.
+func dial(socket string, timeout time.Duration) (*net.UnixConn, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socket, Net: "unix"})
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to reach the agent at %s: %w", socket, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func writeFallback(header corepipe.Header, core io.Reader, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// Names the collector parses; the UID is 0 when not passed.
+	executable := strings.ReplaceAll(header.Executable, ".", "_")
+	uid := header.UID
+	if uid < 0 {
+		uid = 0
+	}
+	name := fmt.Sprintf("core.%s.%d.%d.%d", executable, header.PID, uid, header.Signal)
+	path := filepath.Join(dir, name)
+	// Hidden until complete, or the collector would wait on it.
+	partial := filepath.Join(dir, "."+name+".partial")
+	file, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, core)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", err
+	}
+	return path, nil
+}
+
+func logKernel(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	if kmsg, err := os.OpenFile("/dev/kmsg", os.O_WRONLY, 0); err == nil {
+		kmsg.WriteString(message)
+		kmsg.Close()
+	}
+}
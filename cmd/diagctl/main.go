@@ -0,0 +1,231 @@
+// diagctl analyzes coredumps outside the cluster, with the agent's own
+// analyzer: gdb, the optional AI analysis and the value score.
+//
+//	diagctl analyze core.milvus.1234 --executable ./milvus --ai=off
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// defaultPanicKeywords are those of the shipped agent configuration, used
+// without --config.
+var defaultPanicKeywords = []string{"panic", "fatal", "SIGSEGV", "SIGABRT", "SIGFPE", "assertion failed"}
+
+const usage = `Usage: diagctl <command> [flags]
+
+Commands:
+  analyze <core>   Analyze a coredump and print its score, stack trace and AI analysis
+
+Run "diagctl <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "analyze":
+		if err := analyze(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "diagctl: %v\n", err)
+			os.Exit(1)
+		}
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "diagctl: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// report is what analyze prints.
+type report struct {
+	Path           string                      `json:"path"`
+	Executable     string                      `json:"executable,omitempty"`
+	Signal         int                         `json:"signal,omitempty"`
+	ValueScore     float64                     `json:"valueScore"`
+	ScoreBreakdown *collector.ScoreBreakdown   `json:"scoreBreakdown"`
+	CrashReason    string                      `json:"crashReason,omitempty"`
+	CrashAddress   string                      `json:"crashAddress,omitempty"`
+	ThreadCount    int                         `json:"threadCount,omitempty"`
+	StackTrace     string                      `json:"stackTrace,omitempty"`
+	AIAnalysis     *collector.AIAnalysisResult `json:"aiAnalysis,omitempty"`
+}
+
+func analyze(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: diagctl analyze <core> [flags]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "Agent configuration whose analyzer section is used (optional)")
+	executable := flags.String("executable", "", "Binary that dumped the core, for its symbols")
+	ai := flags.String("ai", "on", "AI analysis: on or off")
+	aiProvider := flags.String("ai-provider", "", "AI provider, overriding the configured one")
+	aiModel := flags.String("ai-model", "", "AI model, overriding the configured one")
+	gdb := flags.Bool("gdb", true, "Analyze with gdb; otherwise only the signal and file type are used")
+	gdbTimeout := flags.Duration("gdb-timeout", 5*time.Minute, "How long gdb may run")
+	signal := flags.Int("signal", 0, "Signal that killed the process, when the core's name does not record it")
+	output := flags.String("output", "text", "Output format: text or json")
+	verbose := flags.Bool("verbose", false, "Log what the analyzer does to stderr")
+
+	// Flags may follow the core, as in "analyze core.milvus.1234 --ai=off".
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one coredump, got %d", len(positional))
+	}
+	if *ai != "on" && *ai != "off" {
+		return fmt.Errorf("invalid --ai %q, expected on or off", *ai)
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid --output %q, expected text or json", *output)
+	}
+
+	analyzerConfig := &config.AnalyzerConfig{PanicKeywords: defaultPanicKeywords}
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return err
+		}
+		analyzerConfig = &cfg.Analyzer
+	}
+	analyzerConfig.EnableGdbAnalysis = *gdb
+	analyzerConfig.GdbTimeout = *gdbTimeout
+	analyzerConfig.AIAnalysis.Enabled = *ai == "on"
+	// One core does not need the agent's cost control.
+	analyzerConfig.AIAnalysis.EnableCostControl = false
+	if *aiProvider != "" {
+		analyzerConfig.AIAnalysis.Provider = *aiProvider
+	}
+	if *aiModel != "" {
+		analyzerConfig.AIAnalysis.Model = *aiModel
+	}
+
+	if !*verbose {
+		klog.LogToStderr(false)
+		klog.SetOutput(io.Discard)
+	}
+	// The analyzer carries on without AI when its provider is misconfigured;
+	// asking for it here should fail instead.
+	if analyzerConfig.AIAnalysis.Enabled {
+		if _, err := analyzer.NewAIAnalyzer(&analyzerConfig.AIAnalysis); err != nil {
+			return fmt.Errorf("AI analysis: %w", err)
+		}
+	}
+
+	path := positional[0]
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	coredump := collector.NewCoredumpFile(path, info)
+	if *signal != 0 {
+		coredump.Signal = *signal
+	}
+
+	a := analyzer.New(analyzerConfig, nil, nil, nil, nil, nil, nil, nil, "")
+	if err := a.AnalyzeFile(coredump, *executable); err != nil {
+		return err
+	}
+
+	results := coredump.AnalysisResults
+	r := report{
+		Path:           coredump.Path,
+		Executable:     coredump.Executable,
+		Signal:         coredump.Signal,
+		ValueScore:     coredump.ValueScore,
+		ScoreBreakdown: coredump.ScoreBreakdown,
+		CrashReason:    results.CrashReason,
+		CrashAddress:   results.CrashAddress,
+		ThreadCount:    results.ThreadCount,
+		StackTrace:     results.StackTrace,
+		AIAnalysis:     results.AIAnalysis,
+	}
+	if *output == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	}
+	printReport(out, &r)
+	return nil
+}
+
+func printReport(out io.Writer, r *report) {
+	fmt.Fprintf(out, "Core:         %s\n", r.Path)
+	if r.Executable != "" {
+		fmt.Fprintf(out, "Executable:   %s\n", r.Executable)
+	}
+	if r.Signal != 0 {
+		fmt.Fprintf(out, "Signal:       %d\n", r.Signal)
+	}
+	if r.CrashReason != "" {
+		fmt.Fprintf(out, "Crash reason: %s\n", r.CrashReason)
+	}
+	if r.CrashAddress != "" {
+		fmt.Fprintf(out, "Address:      %s\n", r.CrashAddress)
+	}
+	if r.ThreadCount > 0 {
+		fmt.Fprintf(out, "Threads:      %d\n", r.ThreadCount)
+	}
+
+	breakdown := r.ScoreBreakdown
+	capped := ""
+	if breakdown.Capped {
+		capped = " (capped)"
+	}
+	fmt.Fprintf(out, "\nScore: %.2f%s\n", r.ValueScore, capped)
+	fmt.Fprintf(out, "  %-16s %5.1f\n", "base", breakdown.Base)
+	for _, dimension := range breakdown.Dimensions {
+		fmt.Fprintf(out, "  %-16s %+5.1f  %s\n", dimension.Name, dimension.Points, dimension.Detail)
+	}
+
+	if r.StackTrace != "" {
+		fmt.Fprintf(out, "\nStack trace:\n%s\n", strings.TrimRight(r.StackTrace, "\n"))
+	}
+
+	ai := r.AIAnalysis
+	if ai == nil || !ai.Enabled {
+		return
+	}
+	fmt.Fprintf(out, "\nAI analysis (%s):\n", strings.TrimSpace(ai.Provider+" "+ai.Model))
+	if ai.ErrorMessage != "" {
+		fmt.Fprintf(out, "  %s\n", ai.ErrorMessage)
+		return
+	}
+	fmt.Fprintf(out, "  Summary:    %s\n", ai.Summary)
+	fmt.Fprintf(out, "  Root cause: %s\n", ai.RootCause)
+	if ai.Impact != "" {
+		fmt.Fprintf(out, "  Impact:     %s\n", ai.Impact)
+	}
+	for _, recommendation := range ai.Recommendations {
+		fmt.Fprintf(out, "  - %s\n", recommendation)
+	}
+	fmt.Fprintf(out, "  Confidence: %.0f%%, cost: $%.4f\n", ai.Confidence*100, ai.CostUSD)
+}
@@ -0,0 +1,675 @@
+// Package alertrules decides what becomes of an alert before it is sent.
+// Rules match alerts by kind, namespace, instance, value score and restart
+// count; they drop repeats within a deduplication window and raise the
+// severity of alerts that keep coming. Silences mute the alerts they match
+// for a while, such as during a maintenance. Rules from the configuration
+// are fixed; those added through the API and the silences are kept in a
+// state file.
+package alertrules
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+
+	ReasonSilenced     = "silenced"
+	ReasonDeduplicated = "deduplicated"
+
+	// DefaultWindow is the window escalations are counted in when a rule
+	// sets none.
+	DefaultWindow      = time.Hour
+	MaxSilenceDuration = 30 * 24 * time.Hour
+	MaxRules           = 100
+	MaxSilences        = 1000
+	MaxCommentSize     = 1024
+
+	// expiredSilenceRetention is how long ended silences stay listed.
+	expiredSilenceRetention = 24 * time.Hour
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid")
+	// ErrConflict is returned for a rule name that is taken, or a change
+	// to a rule of the configuration.
+	ErrConflict = errors.New("conflict")
+	ErrTooMany  = errors.New("too many")
+)
+
+var (
+	kinds      = map[string]bool{"crash": true, "cleanup": true, "ai_budget": true}
+	severities = map[string]bool{"info": true, "warning": true, "critical": true}
+	ruleName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+)
+
+// Rule matches the alerts of its kinds, namespaces and instances scoring at
+// least MinScore and following at least MinRestartCount restarts; empty
+// lists match everything. Instances are shell patterns such as "prod-*".
+// Severity replaces that of the alerts it matches until an escalation
+// level is reached. Durations are Go durations such as "30m".
+type Rule struct {
+	Name            string            `json:"name"`
+	Kinds           []string          `json:"kinds,omitempty"`
+	Namespaces      []string          `json:"namespaces,omitempty"`
+	Instances       []string          `json:"instances,omitempty"`
+	MinScore        float64           `json:"minScore,omitempty"`
+	MinRestartCount int               `json:"minRestartCount,omitempty"`
+	Severity        string            `json:"severity,omitempty"`
+	DedupWindow     string            `json:"dedupWindow,omitempty"`
+	Window          string            `json:"window,omitempty"`
+	Escalation      []EscalationLevel `json:"escalation,omitempty"`
+	// Source is config or api; only rules added through the API can be
+	// changed through it.
+	Source string `json:"source"`
+
+	dedupWindow time.Duration
+	window      time.Duration
+}
+
+// EscalationLevel raises the severity once the rule has matched
+// Occurrences alerts of an instance within its window. A crash alert
+// counts as the crashes it groups.
+type EscalationLevel struct {
+	Occurrences int    `json:"occurrences"`
+	Severity    string `json:"severity"`
+}
+
+// Silence mutes the alerts matching all of its set fields between StartsAt
+// and EndsAt. Instance is a shell pattern.
+type Silence struct {
+	ID        int       `json:"id"`
+	Rule      string    `json:"rule,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Author    string    `json:"author"`
+	Comment   string    `json:"comment,omitempty"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+// Active reports whether the silence mutes alerts at now.
+func (s *Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Alert is what rules and silences are matched against. Count is the
+// number of occurrences it stands for, such as the crashes of a group.
+type Alert struct {
+	Kind         string
+	Severity     string
+	Namespace    string
+	Instance     string
+	Score        float64
+	RestartCount int
+	Count        int
+}
+
+// Decision is what to do with an alert. Rule names the rule that matched,
+// Level the escalation level reached, 0 for none. Reason says why an alert
+// is not delivered.
+type Decision struct {
+	Deliver   bool
+	Severity  string
+	Rule      string
+	Level     int
+	Reason    string
+	SilenceID int
+}
+
+// Engine evaluates alerts against the rules and silences. A nil *Engine
+// delivers every alert as it is.
+type Engine struct {
+	statePath   string
+	configRules []*Rule
+
+	mu            sync.Mutex
+	rules         []*Rule
+	silences      []*Silence
+	nextSilenceID int
+	history       map[string]*history
+}
+
+// history is what a rule has seen of an instance.
+type history struct {
+	occurrences []occurrence
+	lastSent    time.Time
+	lastLevel   int
+}
+
+type occurrence struct {
+	at    time.Time
+	count int
+}
+
+type state struct {
+	Rules         []*Rule    `json:"rules"`
+	Silences      []*Silence `json:"silences"`
+	NextSilenceID int        `json:"nextSilenceId"`
+}
+
+// New creates the engine with the rules of the configuration, restoring
+// the rules and silences saved in statePath.
+func New(configRules []config.AlertRuleConfig, statePath string) (*Engine, error) {
+	e := &Engine{statePath: statePath, nextSilenceID: 1, history: make(map[string]*history)}
+	for _, cfg := range configRules {
+		rule := ruleFromConfig(cfg)
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", cfg.Name, err)
+		}
+		if e.findRule(rule.Name) != nil {
+			return nil, fmt.Errorf("%w: alert rule %s is defined twice", ErrConflict, rule.Name)
+		}
+		e.configRules = append(e.configRules, rule)
+	}
+	e.load()
+	return e, nil
+}
+
+func ruleFromConfig(cfg config.AlertRuleConfig) *Rule {
+	rule := &Rule{
+		Name:            cfg.Name,
+		Kinds:           cfg.Kinds,
+		Namespaces:      cfg.Namespaces,
+		Instances:       cfg.Instances,
+		MinScore:        cfg.MinScore,
+		MinRestartCount: cfg.MinRestartCount,
+		Severity:        cfg.Severity,
+		Source:          SourceConfig,
+	}
+	if cfg.DedupWindow > 0 {
+		rule.DedupWindow = cfg.DedupWindow.String()
+	}
+	if cfg.Window > 0 {
+		rule.Window = cfg.Window.String()
+	}
+	for _, level := range cfg.Escalation {
+		rule.Escalation = append(rule.Escalation, EscalationLevel{Occurrences: level.Occurrences, Severity: level.Severity})
+	}
+	return rule
+}
+
+// Evaluate decides whether alert is sent and with which severity. The
+// first rule to match applies, those of the configuration first; alerts
+// no rule matches are sent as they are unless silenced.
+func (e *Engine) Evaluate(alert Alert, now time.Time) Decision {
+	decision := Decision{Deliver: true, Severity: alert.Severity}
+	if e == nil {
+		return decision
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.forget(now)
+
+	var matched *Rule
+	for _, rule := range e.allRules() {
+		if rule.matches(&alert) {
+			matched = rule
+			break
+		}
+	}
+
+	var h *history
+	if matched != nil {
+		decision.Rule = matched.Name
+		if matched.Severity != "" {
+			decision.Severity = matched.Severity
+		}
+
+		key := matched.Name + "/" + alert.Kind + "/" + alert.Namespace + "/" + alert.Instance
+		h = e.history[key]
+		if h == nil {
+			h = &history{}
+			e.history[key] = h
+		}
+		h.occurrences = append(h.occurrences, occurrence{at: now, count: max(alert.Count, 1)})
+		total := 0
+		for _, o := range h.occurrences {
+			total += o.count
+		}
+		for i, level := range matched.Escalation {
+			if total >= level.Occurrences {
+				decision.Level = i + 1
+				decision.Severity = level.Severity
+			}
+		}
+	}
+
+	for _, silence := range e.silences {
+		if silence.Active(now) && silence.matches(&alert, decision.Rule) {
+			decision.Deliver = false
+			decision.Reason = ReasonSilenced
+			decision.SilenceID = silence.ID
+			return decision
+		}
+	}
+
+	if h != nil {
+		// Escalations break through the deduplication window.
+		if matched.dedupWindow > 0 && !h.lastSent.IsZero() && now.Sub(h.lastSent) < matched.dedupWindow && decision.Level <= h.lastLevel {
+			decision.Deliver = false
+			decision.Reason = ReasonDeduplicated
+			return decision
+		}
+		h.lastSent = now
+		h.lastLevel = decision.Level
+	}
+	return decision
+}
+
+// forget drops the occurrences that left their rule's window and the
+// history of instances that have gone quiet. It must be called with e.mu
+// held.
+func (e *Engine) forget(now time.Time) {
+	for key, h := range e.history {
+		rule := e.findRule(strings.SplitN(key, "/", 2)[0])
+		if rule == nil {
+			delete(e.history, key)
+			continue
+		}
+		kept := h.occurrences[:0]
+		for _, o := range h.occurrences {
+			if now.Sub(o.at) < rule.window {
+				kept = append(kept, o)
+			}
+		}
+		h.occurrences = kept
+		if len(kept) == 0 && now.Sub(h.lastSent) >= rule.dedupWindow {
+			delete(e.history, key)
+		}
+	}
+}
+
+func (r *Rule) matches(alert *Alert) bool {
+	if !contains(r.Kinds, alert.Kind) || !contains(r.Namespaces, alert.Namespace) {
+		return false
+	}
+	if len(r.Instances) > 0 && !matchesPattern(r.Instances, alert.Instance) {
+		return false
+	}
+	return alert.Score >= r.MinScore && alert.RestartCount >= r.MinRestartCount
+}
+
+func (s *Silence) matches(alert *Alert, rule string) bool {
+	if s.Rule != "" && s.Rule != rule {
+		return false
+	}
+	if s.Kind != "" && s.Kind != alert.Kind {
+		return false
+	}
+	if s.Namespace != "" && s.Namespace != alert.Namespace {
+		return false
+	}
+	return s.Instance == "" || matchesPattern([]string{s.Instance}, alert.Instance)
+}
+
+func contains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// compile validates the rule and parses its durations.
+func (r *Rule) compile() error {
+	if !ruleName.MatchString(r.Name) {
+		return fmt.Errorf("%w: a rule name is 1 to 100 letters, digits, dots, dashes and underscores", ErrInvalid)
+	}
+	for _, kind := range r.Kinds {
+		if !kinds[kind] {
+			return fmt.Errorf("%w: unsupported kind %q, expected crash, cleanup or ai_budget", ErrInvalid, kind)
+		}
+	}
+	for _, pattern := range r.Instances {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: invalid instance pattern %q", ErrInvalid, pattern)
+		}
+	}
+	if r.MinScore < 0 || r.MinRestartCount < 0 {
+		return fmt.Errorf("%w: minScore and minRestartCount must not be negative", ErrInvalid)
+	}
+	if r.Severity != "" && !severities[r.Severity] {
+		return fmt.Errorf("%w: unsupported severity %q, expected info, warning or critical", ErrInvalid, r.Severity)
+	}
+
+	var err error
+	if r.dedupWindow, err = parseDuration("dedupWindow", r.DedupWindow, 0); err != nil {
+		return err
+	}
+	if r.window, err = parseDuration("window", r.Window, DefaultWindow); err != nil {
+		return err
+	}
+
+	previous := 0
+	for _, level := range r.Escalation {
+		if level.Occurrences <= previous {
+			return fmt.Errorf("%w: escalation levels need increasing, positive occurrences", ErrInvalid)
+		}
+		if !severities[level.Severity] {
+			return fmt.Errorf("%w: unsupported escalation severity %q, expected info, warning or critical", ErrInvalid, level.Severity)
+		}
+		previous = level.Occurrences
+	}
+	return nil
+}
+
+func parseDuration(name, value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative Go duration such as 30m", ErrInvalid, name)
+	}
+	if duration == 0 {
+		return fallback, nil
+	}
+	return duration, nil
+}
+
+// allRules must be called with e.mu held.
+func (e *Engine) allRules() []*Rule {
+	return append(append([]*Rule(nil), e.configRules...), e.rules...)
+}
+
+// findRule must be called with e.mu held, or before the engine is shared.
+func (e *Engine) findRule(name string) *Rule {
+	for _, rule := range e.allRules() {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Rules returns copies of the rules, in the order they are evaluated.
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make([]Rule, 0, len(e.configRules)+len(e.rules))
+	for _, rule := range e.allRules() {
+		rules = append(rules, rule.copy())
+	}
+	return rules
+}
+
+// Rule returns a copy of the named rule.
+func (e *Engine) Rule(name string) (Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if rule := e.findRule(name); rule != nil {
+		return rule.copy(), nil
+	}
+	return Rule{}, fmt.Errorf("%w: alert rule %s", ErrNotFound, name)
+}
+
+// AddRule adds a rule, evaluated after the existing ones.
+func (e *Engine) AddRule(rule Rule) (Rule, error) {
+	rule.Source = SourceAPI
+	if err := rule.compile(); err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.findRule(rule.Name) != nil {
+		return Rule{}, fmt.Errorf("%w: alert rule %s exists", ErrConflict, rule.Name)
+	}
+	if len(e.rules) >= MaxRules {
+		return Rule{}, fmt.Errorf("%w: at most %d alert rules can be added", ErrTooMany, MaxRules)
+	}
+	added := rule.copy()
+	e.rules = append(e.rules, &added)
+	e.save()
+	return added.copy(), nil
+}
+
+// UpdateRule replaces the named rule, keeping its place in the order. The
+// rule's name cannot change.
+func (e *Engine) UpdateRule(name string, rule Rule) (Rule, error) {
+	rule.Name = name
+	rule.Source = SourceAPI
+	if err := rule.compile(); err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, existing := range e.rules {
+		if existing.Name == name {
+			updated := rule.copy()
+			e.rules[i] = &updated
+			e.save()
+			return updated.copy(), nil
+		}
+	}
+	return Rule{}, e.missingRule(name)
+}
+
+// DeleteRule removes a rule added through the API.
+func (e *Engine) DeleteRule(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, existing := range e.rules {
+		if existing.Name == name {
+			e.rules = append(e.rules[:i], e.rules[i+1:]...)
+			e.save()
+			return nil
+		}
+	}
+	return e.missingRule(name)
+}
+
+// missingRule must be called with e.mu held.
+func (e *Engine) missingRule(name string) error {
+	for _, rule := range e.configRules {
+		if rule.Name == name {
+			return fmt.Errorf("%w: alert rule %s is defined in the configuration", ErrConflict, name)
+		}
+	}
+	return fmt.Errorf("%w: alert rule %s", ErrNotFound, name)
+}
+
+func (r *Rule) copy() Rule {
+	c := *r
+	c.Kinds = append([]string(nil), r.Kinds...)
+	c.Namespaces = append([]string(nil), r.Namespaces...)
+	c.Instances = append([]string(nil), r.Instances...)
+	c.Escalation = append([]EscalationLevel(nil), r.Escalation...)
+	return c
+}
+
+// Silences returns the silences that are active, pending or ended within
+// the last day, newest first.
+func (e *Engine) Silences(now time.Time) []Silence {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	silences := make([]Silence, 0, len(e.silences))
+	for i := len(e.silences) - 1; i >= 0; i-- {
+		if now.Sub(e.silences[i].EndsAt) < expiredSilenceRetention {
+			silences = append(silences, *e.silences[i])
+		}
+	}
+	return silences
+}
+
+// Silence returns a copy of a silence.
+func (e *Engine) Silence(id int) (Silence, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, silence := range e.silences {
+		if silence.ID == id {
+			return *silence, nil
+		}
+	}
+	return Silence{}, fmt.Errorf("%w: silence %d", ErrNotFound, id)
+}
+
+// AddSilence adds a silence; its ID is assigned, a zero StartsAt starts it
+// at now.
+func (e *Engine) AddSilence(silence Silence, now time.Time) (Silence, error) {
+	silence.Author = strings.TrimSpace(silence.Author)
+	if silence.Author == "" {
+		return Silence{}, fmt.Errorf("%w: author is required", ErrInvalid)
+	}
+	if len(silence.Comment) > MaxCommentSize {
+		return Silence{}, fmt.Errorf("%w: comment must not exceed %d bytes", ErrInvalid, MaxCommentSize)
+	}
+	if silence.Rule == "" && silence.Kind == "" && silence.Namespace == "" && silence.Instance == "" {
+		return Silence{}, fmt.Errorf("%w: a silence needs a rule, kind, namespace or instance to match", ErrInvalid)
+	}
+	if silence.Kind != "" && !kinds[silence.Kind] {
+		return Silence{}, fmt.Errorf("%w: unsupported kind %q, expected crash, cleanup or ai_budget", ErrInvalid, silence.Kind)
+	}
+	if _, err := path.Match(silence.Instance, ""); err != nil {
+		return Silence{}, fmt.Errorf("%w: invalid instance pattern %q", ErrInvalid, silence.Instance)
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		return Silence{}, fmt.Errorf("%w: a silence must end after it starts and in the future", ErrInvalid)
+	}
+	if silence.EndsAt.Sub(silence.StartsAt) > MaxSilenceDuration {
+		return Silence{}, fmt.Errorf("%w: a silence lasts at most %v", ErrInvalid, MaxSilenceDuration)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pruneSilences(now)
+	if len(e.silences) >= MaxSilences {
+		return Silence{}, fmt.Errorf("%w: at most %d silences can exist", ErrTooMany, MaxSilences)
+	}
+	silence.ID = e.nextSilenceID
+	e.nextSilenceID++
+	added := silence
+	e.silences = append(e.silences, &added)
+	e.save()
+	klog.Infof("Alert silence %d added by %s until %s", silence.ID, silence.Author, silence.EndsAt.Format(time.RFC3339))
+	return silence, nil
+}
+
+// ExpireSilence ends a silence at now. It stays listed for a day.
+func (e *Engine) ExpireSilence(id int, now time.Time) (Silence, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, silence := range e.silences {
+		if silence.ID != id {
+			continue
+		}
+		if silence.EndsAt.After(now) {
+			silence.EndsAt = now
+			if silence.StartsAt.After(now) {
+				silence.StartsAt = now
+			}
+			e.save()
+		}
+		return *silence, nil
+	}
+	return Silence{}, fmt.Errorf("%w: silence %d", ErrNotFound, id)
+}
+
+// pruneSilences drops the silences that ended over a day ago. It must be
+// called with e.mu held.
+func (e *Engine) pruneSilences(now time.Time) {
+	kept := e.silences[:0]
+	for _, silence := range e.silences {
+		if now.Sub(silence.EndsAt) < expiredSilenceRetention {
+			kept = append(kept, silence)
+		}
+	}
+	e.silences = kept
+}
+
+func (e *Engine) load() {
+	if e.statePath == "" {
+		return
+	}
+	var saved state
+	if err := statefile.Load(e.statePath, &saved); err != nil {
+		klog.Warningf("Starting without the alert rules and silences added through the API: %v", err)
+		return
+	}
+	for _, rule := range saved.Rules {
+		if rule == nil {
+			continue
+		}
+		rule.Source = SourceAPI
+		if err := rule.compile(); err != nil {
+			klog.Warningf("Dropping saved alert rule %s: %v", rule.Name, err)
+			continue
+		}
+		if e.findRule(rule.Name) != nil {
+			klog.Warningf("Dropping saved alert rule %s, the configuration defines a rule of that name", rule.Name)
+			continue
+		}
+		e.rules = append(e.rules, rule)
+	}
+	for _, silence := range saved.Silences {
+		if silence == nil {
+			continue
+		}
+		e.silences = append(e.silences, silence)
+		if silence.ID >= e.nextSilenceID {
+			e.nextSilenceID = silence.ID + 1
+		}
+	}
+	sort.Slice(e.silences, func(i, j int) bool { return e.silences[i].ID < e.silences[j].ID })
+	if saved.NextSilenceID > e.nextSilenceID {
+		e.nextSilenceID = saved.NextSilenceID
+	}
+	e.pruneSilences(time.Now())
+	if len(e.rules) > 0 || len(e.silences) > 0 {
+		klog.Infof("Restored %d alert rules and %d silences from %s", len(e.rules), len(e.silences), e.statePath)
+	}
+}
+
+// save must be called with e.mu held.
+func (e *Engine) save() {
+	if e.statePath == "" {
+		return
+	}
+	if err := statefile.Save(e.statePath, state{Rules: e.rules, Silences: e.silences, NextSilenceID: e.nextSilenceID}); err != nil {
+		klog.Warningf("Failed to save alert rules and silences: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package alertrules
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestEngineDeduplicatesAndEscalates(t *testing.T) {
+	engine, err := New([]config.AlertRuleConfig{{
+		Name:        "prod-crashes",
+		Kinds:       []string{"crash"},
+		Instances:   []string{"prod-*"},
+		MinScore:    6,
+		Severity:    "warning",
+		DedupWindow: 30 * time.Minute,
+		Window:      time.Hour,
+		Escalation:  []config.AlertEscalationConfig{{Occurrences: 5, Severity: "critical"}},
+	}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	crash := Alert{Kind: "crash", Severity: "critical", Namespace: "milvus", Instance: "prod-a", Score: 7, Count: 2}
+	if decision := engine.Evaluate(crash, start); !decision.Deliver || decision.Severity != "warning" || decision.Rule != "prod-crashes" {
+		t.Fatalf("expected the first alert as warning, got %+v", decision)
+	}
+	if decision := engine.Evaluate(crash, start.Add(time.Minute)); decision.Deliver || decision.Reason != ReasonDeduplicated {
+		t.Fatalf("expected the repeat to be deduplicated, got %+v", decision)
+	}
+	// The fifth crash within the hour escalates through the dedup window.
+	if decision := engine.Evaluate(Alert{Kind: "crash", Namespace: "milvus", Instance: "prod-a", Score: 7, Count: 1}, start.Add(2*time.Minute)); !decision.Deliver || decision.Severity != "critical" || decision.Level != 1 {
+		t.Fatalf("expected an escalation to critical, got %+v", decision)
+	}
+	if decision := engine.Evaluate(crash, start.Add(3*time.Minute)); decision.Deliver {
+		t.Fatalf("expected the escalated level to be deduplicated, got %+v", decision)
+	}
+	// An hour later the occurrences have left the window.
+	if decision := engine.Evaluate(crash, start.Add(2*time.Hour)); !decision.Deliver || decision.Level != 0 {
+		t.Fatalf("expected the count to start over, got %+v", decision)
+	}
+
+	// Another instance, a low score or no rule at all are not affected.
+	if decision := engine.Evaluate(Alert{Kind: "crash", Severity: "critical", Instance: "prod-b", Score: 7}, start); !decision.Deliver || decision.Rule != "prod-crashes" {
+		t.Errorf("expected another instance to be counted apart, got %+v", decision)
+	}
+	if decision := engine.Evaluate(Alert{Kind: "crash", Severity: "critical", Instance: "prod-a", Score: 3}, start); !decision.Deliver || decision.Rule != "" || decision.Severity != "critical" {
+		t.Errorf("expected an unmatched alert to pass as it is, got %+v", decision)
+	}
+
+	var nilEngine *Engine
+	if decision := nilEngine.Evaluate(crash, start); !decision.Deliver || decision.Severity != "critical" {
+		t.Errorf("expected a nil engine to deliver everything, got %+v", decision)
+	}
+}
+
+func TestEngineSilences(t *testing.T) {
+	engine, err := New(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	if _, err := engine.AddSilence(Silence{Author: "alice"}, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a silence matching everything to be rejected, got %v", err)
+	}
+	silence, err := engine.AddSilence(Silence{Namespace: "milvus", Instance: "prod-*", Author: "alice", Comment: "upgrade", EndsAt: now.Add(time.Hour)}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := Alert{Kind: "cleanup", Severity: "critical", Namespace: "milvus", Instance: "prod-a", RestartCount: 5}
+	if decision := engine.Evaluate(cleanup, now.Add(time.Minute)); decision.Deliver || decision.Reason != ReasonSilenced || decision.SilenceID != silence.ID {
+		t.Errorf("expected the alert to be silenced, got %+v", decision)
+	}
+	if decision := engine.Evaluate(Alert{Kind: "cleanup", Namespace: "other", Instance: "prod-a"}, now); !decision.Deliver {
+		t.Errorf("expected another namespace not to be silenced, got %+v", decision)
+	}
+
+	if _, err := engine.ExpireSilence(silence.ID, now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if decision := engine.Evaluate(cleanup, now.Add(3*time.Minute)); !decision.Deliver {
+		t.Errorf("expected the expired silence to let the alert through, got %+v", decision)
+	}
+	if silences := engine.Silences(now.Add(3 * time.Minute)); len(silences) != 1 || silences[0].Active(now.Add(3*time.Minute)) {
+		t.Errorf("expected the expired silence to stay listed, got %+v", silences)
+	}
+	if silences := engine.Silences(now.Add(25 * time.Hour)); len(silences) != 0 {
+		t.Errorf("expected the silence to be gone after a day, got %+v", silences)
+	}
+}
+
+func TestEngineKeepsAPIRules(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "alert-rules.json")
+	configRules := []config.AlertRuleConfig{{Name: "from-config", Kinds: []string{"cleanup"}}}
+	engine, err := New(configRules, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.AddRule(Rule{Name: "budget", Kinds: []string{"ai_budget"}, Severity: "info"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.AddRule(Rule{Name: "from-config"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a taken name to conflict, got %v", err)
+	}
+	if _, err := engine.AddRule(Rule{Name: "bad", DedupWindow: "soon"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected an invalid duration to be rejected, got %v", err)
+	}
+	if _, err := engine.AddRule(Rule{Name: "bad", Escalation: []EscalationLevel{{Occurrences: 5, Severity: "critical"}, {Occurrences: 3, Severity: "warning"}}}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected decreasing escalation levels to be rejected, got %v", err)
+	}
+	if err := engine.DeleteRule("from-config"); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected rules of the configuration to be fixed, got %v", err)
+	}
+	if _, err := engine.UpdateRule("budget", Rule{Kinds: []string{"ai_budget"}, Severity: "critical"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.AddSilence(Silence{Rule: "budget", Author: "bob", EndsAt: time.Now().Add(time.Hour)}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := New(configRules, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := restored.Rules()
+	if len(rules) != 2 || rules[0].Source != SourceConfig || rules[1].Name != "budget" || rules[1].Severity != "critical" || rules[1].Source != SourceAPI {
+		t.Errorf("expected the configured and the saved rule, got %+v", rules)
+	}
+	if silences := restored.Silences(time.Now()); len(silences) != 1 || silences[0].Rule != "budget" {
+		t.Errorf("expected the saved silence, got %+v", silences)
+	}
+	if silence, err := restored.AddSilence(Silence{Kind: "crash", Author: "bob", EndsAt: time.Now().Add(time.Hour)}, time.Now()); err != nil || silence.ID != 2 {
+		t.Errorf("expected silence IDs to continue, got %+v (%v)", silence, err)
+	}
+
+	if _, err := New([]config.AlertRuleConfig{{Name: "x", Kinds: []string{"restart"}}}, ""); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected an invalid configured rule to be rejected, got %v", err)
+	}
+}
@@ -1,13 +1,11 @@
 package analyzer
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,50 +14,25 @@ import (
 
 	"milvus-coredump-agent/pkg/collector"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/redact"
 )
 
 type AIAnalyzer struct {
 	config        *config.AIAnalysisConfig
-	httpClient    *http.Client
+	provider      AIProvider
+	redactor      *redact.Redactor
 	
 	// Cost control
 	mu            sync.RWMutex
 	monthlyUsage  float64
 	hourlyCount   int
 	lastHourReset time.Time
-}
-
-// GLM API request/response structures
-type GLMChatRequest struct {
-	Model       string       `json:"model"`
-	Messages    []GLMMessage `json:"messages"`
-	Temperature float64      `json:"temperature"`
-	MaxTokens   int          `json:"max_tokens"`
-}
-
-type GLMMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type GLMChatResponse struct {
-	ID      string      `json:"id"`
-	Model   string      `json:"model"`
-	Created int64       `json:"created"`
-	Choices []GLMChoice `json:"choices"`
-	Usage   GLMUsage    `json:"usage"`
-}
-
-type GLMChoice struct {
-	Index        int        `json:"index"`
-	Message      GLMMessage `json:"message"`
-	FinishReason string     `json:"finish_reason"`
-}
-
-type GLMUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	// Spend per quota in quotaMonth, see quota.go
+	quotaMonth    string
+	quotaSpend    map[string]float64
+	// statePath keeps the month's spend across restarts.
+	statePath     string
+	saveMu        sync.Mutex
 }
 
 func NewAIAnalyzer(config *config.AIAnalysisConfig) (*AIAnalyzer, error) {
@@ -67,85 +40,79 @@ func NewAIAnalyzer(config *config.AIAnalysisConfig) (*AIAnalyzer, error) {
 		return &AIAnalyzer{config: config}, nil
 	}
 
-	apiKey := config.APIKey
-	if apiKey == "" {
-		// Try environment variable for GLM
-		apiKey = os.Getenv("GLM_API_KEY")
-	}
-	
-	if apiKey == "" {
-		return nil, fmt.Errorf("GLM API key not provided")
-	}
-
-	// Validate required config
-	if config.BaseURL == "" {
-		return nil, fmt.Errorf("GLM API baseURL not provided")
+	provider, err := newAIProvider(config)
+	if err != nil {
+		return nil, err
 	}
-
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
+	redactor, err := redact.New(&config.Redaction)
+	if err != nil {
+		return nil, err
 	}
 
-	klog.Infof("Using GLM API endpoint: %s", config.BaseURL)
-
 	return &AIAnalyzer{
 		config:        config,
-		httpClient:    httpClient,
+		provider:      provider,
+		redactor:      redactor,
 		lastHourReset: time.Now(),
 	}, nil
 }
 
 func (ai *AIAnalyzer) AnalyzeCoredump(ctx context.Context, coredump *collector.CoredumpFile, gdbResults *collector.AnalysisResults) (*collector.AIAnalysisResult, error) {
-	if !ai.config.Enabled || ai.httpClient == nil {
+	if !ai.config.Enabled || ai.provider == nil {
 		return &collector.AIAnalysisResult{
 			Enabled: false,
 		}, nil
 	}
 
-	// Check cost control
-	if !ai.checkCostLimits() {
-		klog.V(2).Infof("AI analysis skipped due to cost control limits")
+	// Check cost control, which applies to cores on the watchlist too
+	if limit := ai.exceededCostLimit(coredump); limit != "" {
+		klog.V(2).Infof("AI analysis skipped due to cost control limits: %s", limit)
 		return &collector.AIAnalysisResult{
-			Enabled:      true,
-			Provider:     ai.config.Provider,
-			Model:        ai.config.Model,
-			AnalysisTime: time.Now(),
-			ErrorMessage: "Analysis skipped due to cost control limits",
+			Enabled:          true,
+			Provider:         ai.config.Provider,
+			Model:            ai.config.Model,
+			AnalysisTime:     time.Now(),
+			ErrorMessage:     "Analysis skipped due to cost control limits",
+			CostLimitReached: limit,
 		}, nil
 	}
 
 	startTime := time.Now()
 	
-	prompt := ai.buildAnalysisPrompt(coredump, gdbResults)
+	prompt, redaction := ai.redactor.Redact(ai.buildAnalysisPrompt(coredump, gdbResults))
+	if redaction != nil {
+		klog.V(2).Infof("Redacted %d matches from the AI prompt for %s: %v", redaction.Total, coredump.Path, redaction.Counts)
+	}
 	
-	// Call GLM API
-	resp, err := ai.callGLMAPI(ctx, prompt)
+	resp, err := ai.provider.Complete(ctx, ai.getSystemPrompt(), prompt)
 	if err != nil {
-		klog.Errorf("GLM API error: %v", err)
+		klog.Errorf("%s API error: %v", ai.provider.Name(), err)
 		return &collector.AIAnalysisResult{
 			Enabled:      true,
 			Provider:     ai.config.Provider,
 			Model:        ai.config.Model,
 			AnalysisTime: time.Now(),
 			ErrorMessage: fmt.Sprintf("API error: %v", err),
+			Redaction:    redaction,
 		}, nil
 	}
 
-	if len(resp.Choices) == 0 {
+	if resp.Content == "" {
 		return &collector.AIAnalysisResult{
 			Enabled:      true,
 			Provider:     ai.config.Provider,
 			Model:        ai.config.Model,
 			AnalysisTime: time.Now(),
 			ErrorMessage: "No response from AI model",
+			Redaction:    redaction,
 		}, nil
 	}
 
-	analysis, err := ai.parseAIResponse(resp.Choices[0].Message.Content)
+	analysis, err := ai.parseAIResponse(resp.Content)
 	if err != nil {
 		klog.Errorf("Failed to parse AI response: %v", err)
 		analysis = &collector.AIAnalysisResult{
-			Summary: resp.Choices[0].Message.Content, // Fallback to raw response
+			Summary: resp.Content, // Fallback to raw response
 		}
 	}
 
@@ -154,11 +121,12 @@ func (ai *AIAnalyzer) AnalyzeCoredump(ctx context.Context, coredump *collector.C
 	analysis.Provider = ai.config.Provider
 	analysis.Model = ai.config.Model
 	analysis.AnalysisTime = startTime
-	analysis.TokensUsed = resp.Usage.TotalTokens
-	analysis.CostUSD = ai.calculateCost(resp.Usage.TotalTokens)
+	analysis.TokensUsed = resp.TotalTokens
+	analysis.CostUSD = ai.provider.Cost(resp)
+	analysis.Redaction = redaction
 
 	// Update cost tracking
-	ai.updateUsage(analysis.CostUSD)
+	ai.updateUsage(coredump, analysis.CostUSD)
 
 	klog.Infof("AI analysis completed for %s: cost=$%.4f, tokens=%d, duration=%v", 
 		coredump.Path, analysis.CostUSD, analysis.TokensUsed, time.Since(startTime))
@@ -166,74 +134,6 @@ func (ai *AIAnalyzer) AnalyzeCoredump(ctx context.Context, coredump *collector.C
 	return analysis, nil
 }
 
-func (ai *AIAnalyzer) callGLMAPI(ctx context.Context, userPrompt string) (*GLMChatResponse, error) {
-	// Prepare request payload - match exact GLM API format
-	request := GLMChatRequest{
-		Model: ai.config.Model,
-		Messages: []GLMMessage{
-			{
-				Role:    "system",
-				Content: ai.getSystemPrompt(),
-			},
-			{
-				Role:    "user", 
-				Content: userPrompt,
-			},
-		},
-		Temperature: 0.3,     // Fixed value to match successful curl requests
-		MaxTokens:   2000,    // Fixed value to match successful curl requests
-	}
-
-	// Marshal request to JSON
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Debug log the request
-	klog.Infof("GLM API request: %s", string(jsonData))
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", ai.config.BaseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ai.config.APIKey)
-
-	// Make the request
-	resp, err := ai.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Debug log the response
-	klog.Infof("GLM API response status: %d", resp.StatusCode)
-	klog.Infof("GLM API response body: %s", string(body))
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var glmResp GLMChatResponse
-	if err := json.Unmarshal(body, &glmResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &glmResp, nil
-}
-
 func (ai *AIAnalyzer) getSystemPrompt() string {
 	return `You are an expert system debugger specializing in analyzing coredump files and stack traces from C/C++ applications, particularly vector databases like Milvus.
 
@@ -322,6 +222,41 @@ func (ai *AIAnalyzer) buildAnalysisPrompt(coredump *collector.CoredumpFile, gdbR
 			prompt.WriteString("\n")
 		}
 
+		// Memory allocator state, for aborts from allocation failures
+		if stats := gdbResults.Jemalloc; stats != nil {
+			prompt.WriteString("JEMALLOC STATS:\n")
+			prompt.WriteString(fmt.Sprintf("arenas=%d allocated=%d active=%d resident=%d mapped=%d fragmentation=%.2f\n",
+				stats.Arenas, stats.Allocated, stats.Active, stats.Resident, stats.Mapped, stats.Fragmentation))
+			prompt.WriteString("\n")
+		}
+
+		// Resource usage and load leading up to the crash
+		if len(gdbResults.PreCrashMetrics) > 0 {
+			prompt.WriteString("METRICS BEFORE CRASH:\n")
+			for _, series := range gdbResults.PreCrashMetrics {
+				if summary := summarizeSeries(series); summary != "" {
+					prompt.WriteString(summary + "\n")
+				}
+			}
+			prompt.WriteString("\n")
+		}
+
+		// Crashes seen before with similar stacks, and what explained them
+		if len(gdbResults.SimilarCrashes) > 0 {
+			prompt.WriteString("SIMILAR PAST CRASHES:\n")
+			for _, crash := range gdbResults.SimilarCrashes {
+				prompt.WriteString(fmt.Sprintf("- similarity %.2f, %s at %s: %s\n",
+					crash.Score, crash.Instance, crash.Timestamp.Format(time.RFC3339), crash.CrashReason))
+				if crash.RootCause != "" {
+					prompt.WriteString(fmt.Sprintf("  root cause: %s\n", crash.RootCause))
+				}
+				if len(crash.Recommendations) > 0 {
+					prompt.WriteString(fmt.Sprintf("  recommendations: %s\n", strings.Join(crash.Recommendations, "; ")))
+				}
+			}
+			prompt.WriteString("\n")
+		}
+
 		// Shared libraries
 		if len(gdbResults.SharedLibraries) > 0 {
 			prompt.WriteString("LOADED LIBRARIES:\n")
@@ -341,6 +276,35 @@ func (ai *AIAnalyzer) buildAnalysisPrompt(coredump *collector.CoredumpFile, gdbR
 	return prompt.String()
 }
 
+// summarizeSeries condenses a pre-crash series to its first, minimum,
+// maximum and last values, which show a trend in a few tokens.
+func summarizeSeries(series collector.MetricSeries) string {
+	if len(series.Points) == 0 {
+		return ""
+	}
+	first, last := series.Points[0].Value, series.Points[len(series.Points)-1].Value
+	min, max := first, first
+	for _, point := range series.Points {
+		min = math.Min(min, point.Value)
+		max = math.Max(max, point.Value)
+	}
+
+	name := series.Name
+	if len(series.Labels) > 0 {
+		keys := make([]string, 0, len(series.Labels))
+		for key := range series.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		labels := make([]string, len(keys))
+		for i, key := range keys {
+			labels[i] = fmt.Sprintf("%s=%q", key, series.Labels[key])
+		}
+		name += "{" + strings.Join(labels, ", ") + "}"
+	}
+	return fmt.Sprintf("%s: first=%g min=%g max=%g last=%g", name, first, min, max, last)
+}
+
 func (ai *AIAnalyzer) parseAIResponse(response string) (*collector.AIAnalysisResult, error) {
 	// Try to extract JSON from the response
 	response = strings.TrimSpace(response)
@@ -402,17 +366,11 @@ func (ai *AIAnalyzer) getSignalName(signal int) string {
 	return fmt.Sprintf("Signal %d", signal)
 }
 
-func (ai *AIAnalyzer) calculateCost(tokens int) float64 {
-	// OpenAI GPT-4 pricing (as of 2024)
-	// Input: $0.03/1K tokens, Output: $0.06/1K tokens
-	// Simplified calculation assuming 50/50 split
-	costPer1KTokens := 0.045 // Average of input and output costs
-	return float64(tokens) / 1000.0 * costPer1KTokens
-}
-
-func (ai *AIAnalyzer) checkCostLimits() bool {
+// exceededCostLimit describes the cost control limit that was reached, or
+// returns "" when the core may be analyzed.
+func (ai *AIAnalyzer) exceededCostLimit(coredump *collector.CoredumpFile) string {
 	if !ai.config.EnableCostControl {
-		return true
+		return ""
 	}
 
 	ai.mu.Lock()
@@ -426,27 +384,30 @@ func (ai *AIAnalyzer) checkCostLimits() bool {
 
 	// Check hourly limit
 	if ai.hourlyCount >= ai.config.MaxAnalysisPerHour {
-		return false
+		return fmt.Sprintf("hourly limit of %d analyses", ai.config.MaxAnalysisPerHour)
 	}
 
 	// Check monthly cost limit
+	ai.resetQuotas(time.Now())
 	if ai.monthlyUsage >= ai.config.MaxCostPerMonth {
-		return false
+		return fmt.Sprintf("monthly cost limit of $%.2f", ai.config.MaxCostPerMonth)
 	}
 
-	return true
+	return ai.exceededQuota(coredump, time.Now())
 }
 
-func (ai *AIAnalyzer) updateUsage(cost float64) {
+func (ai *AIAnalyzer) updateUsage(coredump *collector.CoredumpFile, cost float64) {
 	if !ai.config.EnableCostControl {
 		return
 	}
 
 	ai.mu.Lock()
-	defer ai.mu.Unlock()
-
+	ai.chargeQuotas(coredump, cost, time.Now())
 	ai.monthlyUsage += cost
 	ai.hourlyCount++
+	ai.mu.Unlock()
+
+	ai.saveUsage()
 }
 
 func (ai *AIAnalyzer) GetUsageStats() (monthlyUsage float64, hourlyCount int) {
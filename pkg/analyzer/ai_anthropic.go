@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	anthropicDefaultBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+)
+
+// anthropicPricing is in USD per million tokens. Unknown models are priced
+// as Opus so cost control errs on the safe side.
+var anthropicPricing = map[string]modelPricing{
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-opus-4":     {Input: 15, Output: 75},
+}
+
+var anthropicFallbackPricing = modelPricing{Input: 15, Output: 75}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float32            `json:"temperature"`
+	MaxTokens   int                `json:"max_tokens"`
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API.
+type anthropicProvider struct {
+	config     *config.AIAnalysisConfig
+	apiKey     string
+	url        string
+	httpClient *http.Client
+}
+
+func newAnthropicProvider(config *config.AIAnalysisConfig) (*anthropicProvider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("Anthropic API key not provided")
+	}
+
+	url := config.BaseURL
+	if url == "" {
+		url = anthropicDefaultBaseURL
+	}
+	if !strings.HasSuffix(url, "/messages") {
+		url = strings.TrimSuffix(url, "/") + "/v1/messages"
+	}
+	klog.Infof("Using Anthropic API endpoint: %s", url)
+
+	httpClient, err := httpclient.New("Anthropic API", config.Proxy, config.TLS, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Anthropic HTTP client: %w", err)
+	}
+
+	return &anthropicProvider{
+		config:     config,
+		apiKey:     apiKey,
+		url:        url,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (p *anthropicProvider) Name() string {
+	return "Anthropic"
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error) {
+	request := anthropicRequest{
+		Model:       p.config.Model,
+		System:      systemPrompt,
+		Messages:    []anthropicMessage{{Role: "user", Content: userPrompt}},
+		Temperature: p.config.Temperature,
+		MaxTokens:   maxTokens(p.config),
+	}
+
+	var response anthropicResponse
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	if err := postJSON(ctx, p.httpClient, p.url, headers, request, &response); err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	return &AICompletion{
+		Content:          content.String(),
+		PromptTokens:     response.Usage.InputTokens,
+		CompletionTokens: response.Usage.OutputTokens,
+		TotalTokens:      response.Usage.InputTokens + response.Usage.OutputTokens,
+	}, nil
+}
+
+func (p *anthropicProvider) Cost(completion *AICompletion) float64 {
+	return pricingFor(p.config, anthropicPricing, anthropicFallbackPricing).cost(completion)
+}
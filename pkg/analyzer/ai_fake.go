@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is an AIProvider that never leaves the process. It is
+// selected with provider "fake" for hermetic tests and local development,
+// and answers every prompt with a well-formed analysis derived from the
+// prompt itself. Tests can override the reply with Response or Err.
+type FakeProvider struct {
+	Response string
+	Err      error
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error) {
+	p.mu.Lock()
+	p.prompts = append(p.prompts, userPrompt)
+	p.mu.Unlock()
+
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	content := p.Response
+	if content == "" {
+		content = fakeAnalysis(userPrompt)
+	}
+
+	return &AICompletion{
+		Content:     content,
+		TotalTokens: (len(systemPrompt) + len(userPrompt) + len(content)) / 4,
+	}, nil
+}
+
+// Cost is zero, the fake provider calls no paid API.
+func (p *FakeProvider) Cost(completion *AICompletion) float64 {
+	return 0
+}
+
+// Prompts returns the user prompts received so far.
+func (p *FakeProvider) Prompts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.prompts...)
+}
+
+func fakeAnalysis(prompt string) string {
+	crashReason := "unknown crash"
+	application := "unknown application"
+	for _, line := range strings.Split(prompt, "\n") {
+		if value, found := strings.CutPrefix(line, "Crash Reason: "); found {
+			crashReason = value
+		}
+		if value, found := strings.CutPrefix(line, "Application: "); found {
+			application = value
+		}
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"summary":         "Fake analysis: " + application + " crashed with " + crashReason,
+		"rootCause":       "Synthetic root cause generated by the fake AI provider",
+		"impact":          "None, this analysis was not produced by a model",
+		"recommendations": []string{"Inspect the stack trace manually"},
+		"confidence":      0.5,
+	})
+	return string(data)
+}
@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const ollamaDefaultBaseURL = "http://localhost:11434"
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature float32 `json:"temperature"`
+	NumPredict  int     `json:"num_predict"`
+}
+
+type ollamaChatResponse struct {
+	Message         openAIMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// ollamaProvider talks to a self-hosted Ollama server through its native
+// chat API, so stack traces never leave the cluster. Local inference is
+// free unless a price is configured.
+type ollamaProvider struct {
+	config     *config.AIAnalysisConfig
+	url        string
+	pricing    modelPricing
+	httpClient *http.Client
+}
+
+func newOllamaProvider(config *config.AIAnalysisConfig) (*ollamaProvider, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("Ollama model not provided")
+	}
+
+	url := config.BaseURL
+	if url == "" {
+		url = ollamaDefaultBaseURL
+	}
+	if !strings.HasSuffix(url, "/api/chat") {
+		url = strings.TrimSuffix(url, "/") + "/api/chat"
+	}
+	klog.Infof("Using Ollama API endpoint: %s", url)
+
+	httpClient, err := httpclient.New("Ollama API", config.Proxy, config.TLS, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama HTTP client: %w", err)
+	}
+
+	return &ollamaProvider{
+		config:     config,
+		url:        url,
+		pricing:    pricingFor(config, nil, modelPricing{}),
+		httpClient: httpClient,
+	}, nil
+}
+
+func (p *ollamaProvider) Name() string {
+	return "Ollama"
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error) {
+	request := ollamaChatRequest{
+		Model: p.config.Model,
+		Messages: []openAIMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		// The analyzer expects a JSON reply; Ollama can enforce that.
+		Format: "json",
+		Options: ollamaOptions{
+			Temperature: p.config.Temperature,
+			NumPredict:  maxTokens(p.config),
+		},
+	}
+
+	var response ollamaChatResponse
+	if err := postJSON(ctx, p.httpClient, p.url, nil, request, &response); err != nil {
+		return nil, err
+	}
+
+	return &AICompletion{
+		Content:          response.Message.Content,
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+		TotalTokens:      response.PromptEvalCount + response.EvalCount,
+	}, nil
+}
+
+func (p *ollamaProvider) Cost(completion *AICompletion) float64 {
+	return p.pricing.cost(completion)
+}
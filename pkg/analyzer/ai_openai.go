@@ -0,0 +1,150 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const openAIDefaultBaseURL = "https://api.openai.com/v1"
+
+// openAIPricing is in USD per million tokens. Unknown models are priced as
+// gpt-4 so cost control errs on the safe side.
+var openAIPricing = map[string]modelPricing{
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4.1":       {Input: 2, Output: 8},
+	"gpt-4.1-mini":  {Input: 0.4, Output: 1.6},
+	"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+}
+
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature float32         `json:"temperature"`
+	MaxTokens   int             `json:"max_tokens"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// openAIProvider talks to the OpenAI chat completions API, or any endpoint
+// compatible with it such as vLLM, LocalAI or a gateway in front of them.
+type openAIProvider struct {
+	name       string
+	config     *config.AIAnalysisConfig
+	apiKey     string
+	url        string
+	pricing    modelPricing
+	httpClient *http.Client
+}
+
+func newOpenAIProvider(config *config.AIAnalysisConfig) (*openAIProvider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not provided")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = openAIDefaultBaseURL
+	}
+	pricing := pricingFor(config, openAIPricing, openAIPricing["gpt-4"])
+	return buildOpenAIProvider("OpenAI", config, apiKey, baseURL, pricing)
+}
+
+// newOpenAICompatibleProvider serves self-hosted models. The API key is
+// optional and the model is free unless a price is configured.
+func newOpenAICompatibleProvider(config *config.AIAnalysisConfig) (*openAIProvider, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("OpenAI-compatible API baseURL not provided")
+	}
+	pricing := pricingFor(config, nil, modelPricing{})
+	return buildOpenAIProvider("OpenAI-compatible", config, config.APIKey, config.BaseURL, pricing)
+}
+
+func buildOpenAIProvider(name string, config *config.AIAnalysisConfig, apiKey, baseURL string, pricing modelPricing) (*openAIProvider, error) {
+	url := baseURL
+	if !strings.HasSuffix(url, "/chat/completions") {
+		url = strings.TrimSuffix(url, "/") + "/chat/completions"
+	}
+	klog.Infof("Using %s API endpoint: %s", name, url)
+
+	httpClient, err := httpclient.New(name+" API", config.Proxy, config.TLS, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s HTTP client: %w", name, err)
+	}
+
+	return &openAIProvider{
+		name:       name,
+		config:     config,
+		apiKey:     apiKey,
+		url:        url,
+		pricing:    pricing,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error) {
+	request := openAIChatRequest{
+		Model: p.config.Model,
+		Messages: []openAIMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		Temperature: p.config.Temperature,
+		MaxTokens:   maxTokens(p.config),
+	}
+
+	var response openAIChatResponse
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	if err := postJSON(ctx, p.httpClient, p.url, headers, request, &response); err != nil {
+		return nil, err
+	}
+
+	completion := &AICompletion{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	}
+	if len(response.Choices) > 0 {
+		completion.Content = response.Choices[0].Message.Content
+	}
+	return completion, nil
+}
+
+func (p *openAIProvider) Cost(completion *AICompletion) float64 {
+	return p.pricing.cost(completion)
+}
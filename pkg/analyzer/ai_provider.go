@@ -0,0 +1,286 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+// AIProvider sends an analysis prompt to a model and returns its raw reply.
+// Parsing the reply into an AIAnalysisResult is left to the AIAnalyzer so
+// every provider is held to the same response format. Cost prices a
+// completion in USD, so cost control works the same for every vendor.
+type AIProvider interface {
+	Name() string
+	Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error)
+	Cost(completion *AICompletion) float64
+}
+
+type AICompletion struct {
+	Content          string
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+func newAIProvider(config *config.AIAnalysisConfig) (AIProvider, error) {
+	switch config.Provider {
+	case "fake":
+		klog.Info("Using fake AI provider, no external API calls will be made")
+		return &FakeProvider{}, nil
+	case "openai":
+		return newOpenAIProvider(config)
+	case "openai-compatible":
+		return newOpenAICompatibleProvider(config)
+	case "ollama":
+		return newOllamaProvider(config)
+	case "anthropic":
+		return newAnthropicProvider(config)
+	case "", "glm":
+		return newGLMProvider(config)
+	default:
+		return nil, fmt.Errorf("unsupported AI provider: %s", config.Provider)
+	}
+}
+
+// modelPricing is a model's price in USD per million tokens.
+type modelPricing struct {
+	Input  float64
+	Output float64
+}
+
+// pricingFor returns the configured price, or else the price of the longest
+// model prefix in table, or else fallback. Prices change more often than
+// this code does; set inputCostPerMillion / outputCostPerMillion to keep
+// cost control accurate.
+func pricingFor(config *config.AIAnalysisConfig, table map[string]modelPricing, fallback modelPricing) modelPricing {
+	if config.InputCostPerMillion > 0 || config.OutputCostPerMillion > 0 {
+		return modelPricing{Input: config.InputCostPerMillion, Output: config.OutputCostPerMillion}
+	}
+	pricing, matched := fallback, ""
+	for prefix, candidate := range table {
+		if strings.HasPrefix(config.Model, prefix) && len(prefix) > len(matched) {
+			pricing, matched = candidate, prefix
+		}
+	}
+	return pricing
+}
+
+func (p modelPricing) cost(completion *AICompletion) float64 {
+	prompt, output := completion.PromptTokens, completion.CompletionTokens
+	if prompt == 0 && output == 0 {
+		// Without a split, price the total at the average rate.
+		return float64(completion.TotalTokens) / 1e6 * (p.Input + p.Output) / 2
+	}
+	return (float64(prompt)*p.Input + float64(output)*p.Output) / 1e6
+}
+
+// maxTokens is the configured completion limit, 2000 by default.
+func maxTokens(config *config.AIAnalysisConfig) int {
+	if config.MaxTokens > 0 {
+		return config.MaxTokens
+	}
+	return 2000
+}
+
+// postJSON sends request as JSON and decodes a 200 response into response.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, request, response interface{}) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// GLM API request/response structures
+type GLMChatRequest struct {
+	Model       string       `json:"model"`
+	Messages    []GLMMessage `json:"messages"`
+	Temperature float64      `json:"temperature"`
+	MaxTokens   int          `json:"max_tokens"`
+}
+
+type GLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type GLMChatResponse struct {
+	ID      string      `json:"id"`
+	Model   string      `json:"model"`
+	Created int64       `json:"created"`
+	Choices []GLMChoice `json:"choices"`
+	Usage   GLMUsage    `json:"usage"`
+}
+
+type GLMChoice struct {
+	Index        int        `json:"index"`
+	Message      GLMMessage `json:"message"`
+	FinishReason string     `json:"finish_reason"`
+}
+
+type GLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// glmProvider talks to the GLM chat completions REST API.
+type glmProvider struct {
+	config     *config.AIAnalysisConfig
+	apiKey     string
+	httpClient *http.Client
+}
+
+func newGLMProvider(config *config.AIAnalysisConfig) (*glmProvider, error) {
+	apiKey := config.APIKey
+	if apiKey == "" {
+		// Try environment variable for GLM
+		apiKey = os.Getenv("GLM_API_KEY")
+	}
+
+	if apiKey == "" {
+		return nil, fmt.Errorf("GLM API key not provided")
+	}
+
+	// Validate required config
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("GLM API baseURL not provided")
+	}
+
+	klog.Infof("Using GLM API endpoint: %s", config.BaseURL)
+
+	httpClient, err := httpclient.New("GLM API", config.Proxy, config.TLS, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GLM HTTP client: %w", err)
+	}
+
+	return &glmProvider{
+		config:     config,
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (p *glmProvider) Name() string {
+	return "GLM"
+}
+
+// glmFallbackPricing is the flat rate cost control has always assumed.
+var glmFallbackPricing = modelPricing{Input: 45, Output: 45}
+
+func (p *glmProvider) Cost(completion *AICompletion) float64 {
+	return pricingFor(p.config, nil, glmFallbackPricing).cost(completion)
+}
+
+func (p *glmProvider) Complete(ctx context.Context, systemPrompt, userPrompt string) (*AICompletion, error) {
+	// Prepare request payload - match exact GLM API format
+	request := GLMChatRequest{
+		Model: p.config.Model,
+		Messages: []GLMMessage{
+			{
+				Role:    "system",
+				Content: systemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: userPrompt,
+			},
+		},
+		Temperature: 0.3,  // Fixed value to match successful curl requests
+		MaxTokens:   2000, // Fixed value to match successful curl requests
+	}
+
+	// Marshal request to JSON
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Debug log the request
+	klog.Infof("GLM API request: %s", string(jsonData))
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", p.config.BaseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	// Make the request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Debug log the response
+	klog.Infof("GLM API response status: %d", resp.StatusCode)
+	klog.Infof("GLM API response body: %s", string(body))
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	// Parse response
+	var glmResp GLMChatResponse
+	if err := json.Unmarshal(body, &glmResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	completion := &AICompletion{
+		PromptTokens:     glmResp.Usage.PromptTokens,
+		CompletionTokens: glmResp.Usage.CompletionTokens,
+		TotalTokens:      glmResp.Usage.TotalTokens,
+	}
+	if len(glmResp.Choices) > 0 {
+		completion.Content = glmResp.Choices[0].Message.Content
+	}
+	return completion, nil
+}
@@ -0,0 +1,170 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestOpenAIProvider(t *testing.T) {
+	var request openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"summary\":\"ok\"}"}}],
+			"usage":{"prompt_tokens":1000,"completion_tokens":500,"total_tokens":1500}}`))
+	}))
+	defer server.Close()
+
+	provider, err := newAIProvider(&config.AIAnalysisConfig{
+		Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-test", BaseURL: server.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completion, err := provider.Complete(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Content != `{"summary":"ok"}` || completion.TotalTokens != 1500 {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" || request.MaxTokens != 2000 {
+		t.Errorf("unexpected request: %+v", request)
+	}
+	// 1000 input tokens at $0.15/M plus 500 output tokens at $0.60/M
+	if cost := provider.Cost(completion); math.Abs(cost-0.00045) > 1e-9 {
+		t.Errorf("expected cost $0.00045, got %v", cost)
+	}
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	var request anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s with headers %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"content":[{"type":"text","text":"{\"summary\":"},{"type":"text","text":"\"ok\"}"}],
+			"usage":{"input_tokens":2000,"output_tokens":1000}}`))
+	}))
+	defer server.Close()
+
+	provider, err := newAIProvider(&config.AIAnalysisConfig{
+		Provider: "anthropic", Model: "claude-sonnet-4-20250514", APIKey: "key", BaseURL: server.URL, MaxTokens: 512,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completion, err := provider.Complete(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Content != `{"summary":"ok"}` || completion.TotalTokens != 3000 {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if request.System != "system" || len(request.Messages) != 1 || request.MaxTokens != 512 {
+		t.Errorf("unexpected request: %+v", request)
+	}
+	// 2000 input tokens at $3/M plus 1000 output tokens at $15/M
+	if cost := provider.Cost(completion); math.Abs(cost-0.021) > 1e-9 {
+		t.Errorf("expected cost $0.021, got %v", cost)
+	}
+}
+
+func TestOpenAICompatibleProviderIsFreeWithoutKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}],"usage":{"prompt_tokens":1000,"completion_tokens":500}}`))
+	}))
+	defer server.Close()
+
+	if _, err := newAIProvider(&config.AIAnalysisConfig{Provider: "openai-compatible"}); err == nil {
+		t.Error("expected an error without baseURL")
+	}
+	provider, err := newAIProvider(&config.AIAnalysisConfig{
+		Provider: "openai-compatible", Model: "Qwen/Qwen2.5-7B-Instruct", BaseURL: server.URL + "/v1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completion, err := provider.Complete(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost := provider.Cost(completion); cost != 0 {
+		t.Errorf("expected a self-hosted model to be free, got %v", cost)
+	}
+}
+
+func TestOllamaProvider(t *testing.T) {
+	var request ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"{\"summary\":\"ok\"}"},"done":true,
+			"prompt_eval_count":800,"eval_count":200}`))
+	}))
+	defer server.Close()
+
+	provider, err := newAIProvider(&config.AIAnalysisConfig{Provider: "ollama", Model: "llama3.1:8b", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completion, err := provider.Complete(context.Background(), "system", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Content != `{"summary":"ok"}` || completion.TotalTokens != 1000 {
+		t.Errorf("unexpected completion: %+v", completion)
+	}
+	if request.Stream || request.Model != "llama3.1:8b" || request.Options.NumPredict != 2000 {
+		t.Errorf("unexpected request: %+v", request)
+	}
+	if cost := provider.Cost(completion); cost != 0 {
+		t.Errorf("expected local inference to be free, got %v", cost)
+	}
+}
+
+func TestProviderErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	provider, err := newAIProvider(&config.AIAnalysisConfig{Provider: "openai", APIKey: "sk-test", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Complete(context.Background(), "system", "user"); err == nil {
+		t.Error("expected an error for status 429")
+	}
+}
+
+func TestConfiguredPricingOverridesTable(t *testing.T) {
+	pricing := pricingFor(&config.AIAnalysisConfig{Model: "gpt-4o", InputCostPerMillion: 1, OutputCostPerMillion: 2}, openAIPricing, modelPricing{})
+	if pricing != (modelPricing{Input: 1, Output: 2}) {
+		t.Errorf("expected configured pricing, got %+v", pricing)
+	}
+	// The longest prefix wins: gpt-4o-mini, not gpt-4o or gpt-4.
+	if pricing := pricingFor(&config.AIAnalysisConfig{Model: "gpt-4o-mini-2024-07-18"}, openAIPricing, modelPricing{}); pricing != openAIPricing["gpt-4o-mini"] {
+		t.Errorf("expected gpt-4o-mini pricing, got %+v", pricing)
+	}
+}
+
+func TestUnknownProvider(t *testing.T) {
+	if _, err := newAIProvider(&config.AIAnalysisConfig{Provider: "mock"}); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
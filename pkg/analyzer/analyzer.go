@@ -2,24 +2,54 @@ package analyzer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/klog/v2"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"milvus-coredump-agent/pkg/chanstats"
 	"milvus-coredump-agent/pkg/collector"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/crashlogs"
+	"milvus-coredump-agent/pkg/crashmetrics"
+	"milvus-coredump-agent/pkg/ghissues"
+	"milvus-coredump-agent/pkg/pressure"
+	"milvus-coredump-agent/pkg/procwatch"
+	"milvus-coredump-agent/pkg/sandbox"
+	"milvus-coredump-agent/pkg/similarity"
+	"milvus-coredump-agent/pkg/tracing"
+	"milvus-coredump-agent/pkg/watchlist"
 )
 
 type Analyzer struct {
-	config     *config.AnalyzerConfig
+	// Swapped by UpdateConfig; see config
+	settings   atomic.Pointer[config.AnalyzerConfig]
 	eventChan  chan AnalysisEvent
 	aiAnalyzer *AIAnalyzer
+	pressure   *pressure.Tracker
+	watchdog   *procwatch.Watchdog
+	states     *collector.StateMachine
+	groups     *crashgroup.Registry
+	watchlist  *watchlist.List
+	crashLogs  *crashlogs.Fetcher
+	metrics    *crashmetrics.Fetcher
+	retries    *retryQueue
+	pool       *pool
+	sandbox    *sandbox.Runner
+	similarity *similarity.Index
+	issues     *ghissues.Matcher
+
+	symbolCacheMu sync.Mutex
 }
 
 type AnalysisEvent struct {
@@ -37,7 +67,10 @@ const (
 	EventTypeAnalysisError    EventType = "analysis_error"
 )
 
-func New(config *config.AnalyzerConfig) *Analyzer {
+// New creates the analyzer. groups, watchlist, crashLogs and metrics may be
+// nil. When retryState is set, failed analyses waiting for a retry are kept
+// there.
+func New(config *config.AnalyzerConfig, pressure *pressure.Tracker, watchdog *procwatch.Watchdog, states *collector.StateMachine, groups *crashgroup.Registry, watchlist *watchlist.List, crashLogs *crashlogs.Fetcher, metrics *crashmetrics.Fetcher, retryState string) *Analyzer {
 	aiAnalyzer, err := NewAIAnalyzer(&config.AIAnalysis)
 	if err != nil {
 		klog.Errorf("Failed to initialize AI analyzer: %v", err)
@@ -45,19 +78,54 @@ func New(config *config.AnalyzerConfig) *Analyzer {
 		aiAnalyzer = nil
 	}
 
-	return &Analyzer{
-		config:     config,
+	analyzer := &Analyzer{
 		eventChan:  make(chan AnalysisEvent, 100),
 		aiAnalyzer: aiAnalyzer,
-	}
+		pressure:   pressure,
+		watchdog:   watchdog,
+		states:     states,
+		groups:     groups,
+		watchlist:  watchlist,
+		crashLogs:  crashLogs,
+		metrics:    metrics,
+		retries:    newRetryQueue(&config.Retry, retryState),
+		pool:       newPool(config.MaxConcurrentAnalyses, config.AnalysisQueueSize),
+	}
+	analyzer.settings.Store(config)
+	chanstats.Register("analyzer_events", analyzer.eventChan)
+	return analyzer
+}
+
+// config returns the analyzer's current configuration, which UpdateConfig
+// may swap at any time.
+func (a *Analyzer) config() *config.AnalyzerConfig {
+	return a.settings.Load()
+}
+
+// UpdateConfig swaps in a reloaded configuration. What New built from the
+// old one, such as the AI provider, the retry queue and the worker pool,
+// is kept.
+func (a *Analyzer) UpdateConfig(config *config.AnalyzerConfig) {
+	a.settings.Store(config)
+}
+
+// UseSandbox has gdb's analysis run in pods of runner instead of the
+// agent's container. Call it before Start.
+func (a *Analyzer) UseSandbox(runner *sandbox.Runner) {
+	a.sandbox = runner
 }
 
+// Start analyzes the discovered cores until ctx is done, then returns once
+// the analyses already running or queued finished or the drain timed out.
 func (a *Analyzer) Start(ctx context.Context, collectorChan <-chan collector.CollectionEvent) error {
-	klog.Info("Starting coredump analyzer")
+	klog.Infof("Starting coredump analyzer with %d workers", a.pool.workers)
 
 	go a.processCollectionEvents(ctx, collectorChan)
+	if a.retries != nil {
+		go a.processRetries(ctx)
+	}
 
-	<-ctx.Done()
+	a.pool.run(ctx, a.config().DrainTimeout)
 	return nil
 }
 
@@ -72,7 +140,22 @@ func (a *Analyzer) processCollectionEvents(ctx context.Context, collectorChan <-
 			return
 		case event := <-collectorChan:
 			if event.Type == collector.EventTypeFileDiscovered && event.CoredumpFile != nil {
-				go a.analyzeCoredumpFile(event.CoredumpFile)
+				// Waiting for room in the queue holds back the collector
+				// rather than piling up gdb processes. Cores the watchlist
+				// may be hunting for skip ahead of the others.
+				coredump := event.CoredumpFile
+				_, queued := tracing.Start(ctx, coredump.TraceParent, tracing.SpanAnalysisQueue)
+				submit := a.pool.submit
+				if a.watchlist.Candidate(coredump) {
+					submit = a.pool.submitFirst
+				}
+				if !submit(ctx, func() {
+					queued.End()
+					a.analyzeCoredumpFile(coredump)
+				}) {
+					queued.End()
+					return
+				}
 			}
 		}
 	}
@@ -82,8 +165,9 @@ func (a *Analyzer) analyzeCoredumpFile(coredump *collector.CoredumpFile) {
 	klog.Infof("Analyzing coredump file: %s", coredump.Path)
 
 	if a.shouldSkipAnalysis(coredump) {
-		coredump.Status = collector.StatusSkipped
-		coredump.UpdatedAt = metav1.Now()
+		if err := a.states.Transition(coredump, collector.StatusDiscovered, collector.StatusSkipped, "filtered before analysis"); err != nil {
+			return
+		}
 		
 		event := AnalysisEvent{
 			Type:         EventTypeAnalysisSkipped,
@@ -95,23 +179,64 @@ func (a *Analyzer) analyzeCoredumpFile(coredump *collector.CoredumpFile) {
 		return
 	}
 
-	coredump.Status = collector.StatusProcessing
-	coredump.UpdatedAt = metav1.Now()
+	if err := a.states.Transition(coredump, collector.StatusDiscovered, collector.StatusProcessing, ""); err != nil {
+		return
+	}
+	a.analyze(coredump)
+}
+
+// analyze analyzes a core in processing.
+func (a *Analyzer) analyze(coredump *collector.CoredumpFile) {
+	coredump.AnalysisAttempts++
 
 	var analysisResults *collector.AnalysisResults
 	var err error
 
-	if a.config.EnableGdbAnalysis {
-		analysisResults, err = a.analyzeWithGdb(coredump)
+	ctx, span := tracing.Start(context.Background(), coredump.TraceParent, tracing.SpanAnalysis,
+		trace.WithAttributes(attribute.Int("coredump.analysis.attempt", coredump.AnalysisAttempts)))
+	// err is reused below for steps that only log their failures.
+	var spanErr error
+	defer func() { tracing.End(span, spanErr) }()
+
+	// Self-test cores are not real cores; they only check the pipeline.
+	if a.config().EnableGdbAnalysis && !coredump.SelfTest {
+		// gdb on a large core is the agent's most memory-hungry step, so
+		// hold it back while the agent is under pressure, unless the core
+		// may be one the watchlist is hunting for.
+		if a.watchlist.Candidate(coredump) || a.pressure.WaitForCapacity(context.Background()) {
+			_, gdbSpan := tracing.Start(ctx, "", tracing.SpanGdb)
+			analysisResults, err = a.analyzeWithGdb(coredump, "")
+			tracing.End(gdbSpan, err)
+			if err == nil && a.config().Jemalloc.Enabled {
+				a.addJemallocStats(coredump, analysisResults)
+			}
+			if err == nil && a.config().Delve.Enabled {
+				a.addGoAnalysis(coredump, analysisResults)
+			}
+		} else {
+			klog.Warningf("Still under resource pressure, using basic analysis for %s", coredump.Path)
+			analysisResults, err = a.basicAnalysis(coredump)
+		}
 	} else {
 		analysisResults, err = a.basicAnalysis(coredump)
 	}
 
 	if err != nil {
 		klog.Errorf("Failed to analyze coredump %s: %v", coredump.Path, err)
-		coredump.Status = collector.StatusError
-		coredump.ErrorMessage = err.Error()
-		coredump.UpdatedAt = metav1.Now()
+		spanErr = err
+		retryAt, retry := a.retries.next(coredump, time.Now())
+		if retry {
+			coredump.NextRetryAt = &retryAt
+		}
+		if err := a.states.Transition(coredump, collector.StatusProcessing, collector.StatusError, err.Error()); err != nil {
+			return
+		}
+		span.SetAttributes(attribute.Bool("coredump.analysis.retry", retry))
+		if retry {
+			klog.Infof("Retrying analysis of %s at %s (attempt %d failed)",
+				coredump.Path, retryAt.Format(time.RFC3339), coredump.AnalysisAttempts)
+			a.retries.add(coredump)
+		}
 		
 		event := AnalysisEvent{
 			Type:         EventTypeAnalysisError,
@@ -124,41 +249,51 @@ func (a *Analyzer) analyzeCoredumpFile(coredump *collector.CoredumpFile) {
 		return
 	}
 
-	// Perform AI analysis if available and enabled
-	if a.aiAnalyzer != nil {
-		klog.V(2).Infof("Starting AI analysis for %s", coredump.Path)
-		
-		aiCtx, aiCancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer aiCancel()
-		
-		aiResult, aiErr := a.aiAnalyzer.AnalyzeCoredump(aiCtx, coredump, analysisResults)
-		if aiErr != nil {
-			klog.Errorf("AI analysis failed for %s: %v", coredump.Path, aiErr)
-			// Don't fail the entire analysis, just log the error
-			if analysisResults != nil {
-				analysisResults.AIAnalysis = &collector.AIAnalysisResult{
-					Enabled:      true,
-					Provider:     a.config.AIAnalysis.Provider,
-					Model:        a.config.AIAnalysis.Model,
-					AnalysisTime: time.Now(),
-					ErrorMessage: fmt.Sprintf("AI analysis failed: %v", aiErr),
-				}
-			}
-		} else if aiResult != nil {
-			if analysisResults != nil {
-				analysisResults.AIAnalysis = aiResult
-			}
-			klog.Infof("AI analysis completed for %s: confidence=%.2f, cost=$%.4f", 
-				coredump.Path, aiResult.Confidence, aiResult.CostUSD)
-		}
-	}
-
 	coredump.AnalysisResults = analysisResults
-	coredump.ValueScore = a.calculateValueScore(coredump, analysisResults)
+	var stack string
+	if analysisResults != nil {
+		stack = analysisResults.StackTrace
+	}
+	coredump.Watchlist = a.watchlist.Match(coredump, stack)
+	if len(coredump.Watchlist) > 0 {
+		klog.Infof("Coredump %s matches watchlist %s", coredump.Path, strings.Join(coredump.Watchlist, ", "))
+	}
+	if a.config().Environment.Enabled {
+		analysisResults.Environment = a.captureEnvironment(coredump)
+	}
+	if logs, err := a.crashLogs.Fetch(context.Background(), coredump); err != nil {
+		klog.Warningf("Failed to fetch pre-crash logs of %s: %v", coredump.Path, err)
+	} else {
+		analysisResults.PreCrashLogs = logs
+	}
+	series, err := a.metrics.Fetch(context.Background(), coredump)
+	if err != nil {
+		klog.Warningf("Failed to fetch pre-crash metrics of %s: %v", coredump.Path, err)
+	}
+	analysisResults.PreCrashMetrics = series
+	duplicate := a.groupCrash(coredump, analysisResults)
+	vector := a.findSimilarCrashes(ctx, coredump, analysisResults)
+
+	// Perform AI analysis if available and enabled; later cores of a crash
+	// group may reuse the analysis of its first one instead
+	if reused := a.reusedAIAnalysis(coredump, duplicate); reused != nil {
+		analysisResults.AIAnalysis = reused
+		klog.Infof("Reusing AI analysis of %s for %s (fingerprint %s)",
+			reused.ReusedFrom, coredump.Path, coredump.Fingerprint[:12])
+	} else if a.aiAnalyzer != nil && !coredump.SelfTest {
+		a.analyzeWithAI(ctx, coredump, analysisResults)
+	}
+	a.matchIssues(ctx, coredump, analysisResults)
+	a.indexCrash(ctx, coredump, vector)
+
+	coredump.ScoreBreakdown = a.calculateValueScore(coredump, analysisResults)
+	coredump.ValueScore = coredump.ScoreBreakdown.Total
 	coredump.IsAnalyzed = true
 	coredump.AnalysisTime = time.Now()
-	coredump.Status = collector.StatusAnalyzed
-	coredump.UpdatedAt = metav1.Now()
+	span.SetAttributes(attribute.Float64("coredump.value_score", coredump.ValueScore))
+	if err := a.states.Transition(coredump, collector.StatusProcessing, collector.StatusAnalyzed, ""); err != nil {
+		return
+	}
 
 	klog.Infof("Analysis complete for %s, value score: %.2f", coredump.Path, coredump.ValueScore)
 
@@ -171,9 +306,92 @@ func (a *Analyzer) analyzeCoredumpFile(coredump *collector.CoredumpFile) {
 	a.sendEvent(event)
 }
 
+// groupCrash fingerprints the core from its stack trace and counts it in its
+// crash group. It reports whether an earlier core had the same fingerprint.
+func (a *Analyzer) groupCrash(coredump *collector.CoredumpFile, results *collector.AnalysisResults) bool {
+	if a.groups == nil || results == nil {
+		return false
+	}
+
+	fingerprint, frames := crashgroup.Fingerprint(coredump.Executable, coredump.Signal, results.StackTrace, a.config().CrashGroups.Frames)
+	if fingerprint == "" {
+		return false
+	}
+	coredump.Fingerprint = fingerprint
+
+	group, duplicate := a.groups.Observe(coredump, frames, time.Now())
+	coredump.Occurrences = group.Occurrences
+	if duplicate {
+		klog.Infof("Coredump %s is occurrence %d of crash group %s", coredump.Path, group.Occurrences, fingerprint[:12])
+	}
+	return duplicate
+}
+
+// reusedAIAnalysis returns a copy of the AI analysis made for an earlier
+// core of the same crash group, so a crash loop costs one provider call.
+func (a *Analyzer) reusedAIAnalysis(coredump *collector.CoredumpFile, duplicate bool) *collector.AIAnalysisResult {
+	// Watched cores get an analysis of their own.
+	if !duplicate || !a.config().CrashGroups.ReuseAIAnalysis || len(coredump.Watchlist) > 0 {
+		return nil
+	}
+	analysis, analyzedBy, exists := a.groups.AIAnalysis(coredump.Fingerprint)
+	if !exists {
+		return nil
+	}
+	analysis.ReusedFrom = analyzedBy
+	analysis.TokensUsed = 0
+	analysis.CostUSD = 0
+	return analysis
+}
+
+// analyzeWithAI adds the AI analysis of the core to results. A failed
+// analysis is recorded in results rather than failing the core's.
+func (a *Analyzer) analyzeWithAI(ctx context.Context, coredump *collector.CoredumpFile, analysisResults *collector.AnalysisResults) {
+	klog.V(2).Infof("Starting AI analysis for %s", coredump.Path)
+	
+	aiCtx, aiCancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer aiCancel()
+	
+	aiCtx, span := tracing.Start(aiCtx, "", tracing.SpanAI, trace.WithAttributes(
+		attribute.String("ai.provider", a.config().AIAnalysis.Provider),
+		attribute.String("ai.model", a.config().AIAnalysis.Model)))
+	aiResult, aiErr := a.aiAnalyzer.AnalyzeCoredump(aiCtx, coredump, analysisResults)
+	spanErr := aiErr
+	if aiResult != nil {
+		span.SetAttributes(attribute.Int("ai.tokens", aiResult.TokensUsed), attribute.Float64("ai.cost_usd", aiResult.CostUSD))
+		// Skipping for the budget is not a failure of the call.
+		if aiResult.CostLimitReached != "" {
+			span.SetAttributes(attribute.String("ai.cost_limit_reached", aiResult.CostLimitReached))
+		} else if aiResult.ErrorMessage != "" && spanErr == nil {
+			spanErr = errors.New(aiResult.ErrorMessage)
+		}
+	}
+	tracing.End(span, spanErr)
+	if aiErr != nil {
+		klog.Errorf("AI analysis failed for %s: %v", coredump.Path, aiErr)
+		// Don't fail the entire analysis, just log the error
+		if analysisResults != nil {
+			analysisResults.AIAnalysis = &collector.AIAnalysisResult{
+				Enabled:      true,
+				Provider:     a.config().AIAnalysis.Provider,
+				Model:        a.config().AIAnalysis.Model,
+				AnalysisTime: time.Now(),
+				ErrorMessage: fmt.Sprintf("AI analysis failed: %v", aiErr),
+			}
+		}
+	} else if aiResult != nil {
+		if analysisResults != nil {
+			analysisResults.AIAnalysis = aiResult
+		}
+		a.groups.RecordAIAnalysis(coredump.Fingerprint, coredump.ID, aiResult)
+		klog.Infof("AI analysis completed for %s: confidence=%.2f, cost=$%.4f", 
+			coredump.Path, aiResult.Confidence, aiResult.CostUSD)
+	}
+}
+
 func (a *Analyzer) shouldSkipAnalysis(coredump *collector.CoredumpFile) bool {
 	if coredump.ContainerName != "" {
-		for _, pattern := range a.config.IgnorePatterns {
+		for _, pattern := range a.config().IgnorePatterns {
 			if strings.Contains(coredump.ContainerName, pattern) {
 				klog.V(2).Infof("Skipping analysis for %s due to ignore pattern: %s", 
 					coredump.Path, pattern)
@@ -197,16 +415,38 @@ func (a *Analyzer) shouldSkipAnalysis(coredump *collector.CoredumpFile) bool {
 	return false
 }
 
-func (a *Analyzer) analyzeWithGdb(coredump *collector.CoredumpFile) (*collector.AnalysisResults, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), a.config.GdbTimeout)
-	defer cancel()
-
+// analyzeWithGdb runs gdb on the core. executable, when set, is the binary
+// that dumped it; otherwise gdb finds it through the core.
+func (a *Analyzer) analyzeWithGdb(coredump *collector.CoredumpFile, executable string) (*collector.AnalysisResults, error) {
 	gdbScript := a.generateGdbScript()
+	env, args := a.gdbSymbolOptions()
+	if a.sandbox != nil {
+		// The pod gets no stdin, so the script goes on the command line.
+		args = append(args, "-batch")
+		for _, command := range strings.Split(gdbScript, "\n") {
+			if command = strings.TrimSpace(command); command != "" {
+				args = append(args, "-ex", command)
+			}
+		}
+	} else {
+		args = append(args, "-batch", "-x", "-")
+	}
+	files := []string{coredump.Path}
+	if executable != "" {
+		args = append(args, executable)
+		files = append(files, executable)
+	}
+	args = append(args, coredump.Path)
 	
-	cmd := exec.CommandContext(ctx, "gdb", "-batch", "-x", "-", coredump.Path)
-	cmd.Stdin = strings.NewReader(gdbScript)
-	
-	output, err := cmd.Output()
+	var output []byte
+	var err error
+	if a.sandbox != nil {
+		output, err = a.sandbox.Run(context.Background(), a.config().GdbTimeout, env, args, files...)
+	} else {
+		output, err = a.watchdog.OutputEnv(context.Background(), "gdb", a.config().GdbTimeout,
+			env, strings.NewReader(gdbScript), "gdb", args...)
+		a.pruneSymbolCache()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("gdb analysis failed: %w", err)
 	}
@@ -291,88 +531,35 @@ func (a *Analyzer) basicAnalysis(coredump *collector.CoredumpFile) (*collector.A
 	return results, nil
 }
 
-func (a *Analyzer) calculateValueScore(coredump *collector.CoredumpFile, results *collector.AnalysisResults) float64 {
-	score := 4.0 // base score (updated from 5.0 to align with documentation)
-	scoreBreakdown := []string{fmt.Sprintf("基础分: %.1f", score)}
-
-	// Rule-based scoring dimensions (AI analysis does NOT affect scoring)
-	
-	// 1. Crash reason clarity (+2.0)
-	if results.CrashReason != "" {
-		score += 2.0
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("崩溃原因明确: +2.0 (%s)", results.CrashReason))
-		
-		// Panic keywords bonus (+1.0)
-		for _, keyword := range a.config.PanicKeywords {
-			if strings.Contains(strings.ToLower(results.CrashReason), strings.ToLower(keyword)) {
-				score += 1.0
-				scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("包含关键词 '%s': +1.0", keyword))
-				break
-			}
-		}
-	} else {
-		scoreBreakdown = append(scoreBreakdown, "崩溃原因不明确: +0.0")
-	}
-
-	// 2. Stack trace quality (+1.5)
-	if results.StackTrace != "" && len(results.StackTrace) > 100 {
-		score += 1.5
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("堆栈跟踪质量高: +1.5 (%d字符)", len(results.StackTrace)))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("堆栈跟踪质量低: +0.0 (%d字符)", len(results.StackTrace)))
-	}
-
-	// 3. Multi-thread complexity (+0.5)
-	if results.ThreadCount > 1 {
-		score += 0.5
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("多线程复杂性: +0.5 (%d线程)", results.ThreadCount))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("单线程: +0.0 (%d线程)", results.ThreadCount))
-	}
-
-	// 4. Pod association (+1.0)
-	if coredump.PodName != "" && coredump.InstanceName != "" {
-		score += 1.0
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("Pod关联: +1.0 (%s/%s)", coredump.PodName, coredump.InstanceName))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, "无Pod关联: +0.0")
-	}
-
-	// 5. Signal severity (+1.0)
-	if coredump.Signal == 11 || coredump.Signal == 6 || coredump.Signal == 8 {
-		score += 1.0
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("严重信号: +1.0 (信号%d)", coredump.Signal))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("普通信号: +0.0 (信号%d)", coredump.Signal))
-	}
-
-	// 6. File size (+0.5) - larger files contain more information
-	if coredump.Size > 100*1024*1024 {
-		score += 0.5
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("大文件: +0.5 (%.1fMB)", float64(coredump.Size)/1024/1024))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("小文件: +0.0 (%.1fMB)", float64(coredump.Size)/1024/1024))
-	}
-
-	// 7. Freshness (+0.5) - recent crashes are more valuable
-	if time.Since(coredump.ModTime) < time.Hour {
-		score += 0.5
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("新鲜度高: +0.5 (%s前)", time.Since(coredump.ModTime).Round(time.Minute)))
-	} else {
-		scoreBreakdown = append(scoreBreakdown, fmt.Sprintf("文件较旧: +0.0 (%s前)", time.Since(coredump.ModTime).Round(time.Minute)))
-	}
-
-	// Cap the score at 10.0
-	if score > 10.0 {
-		score = 10.0
-		scoreBreakdown = append(scoreBreakdown, "分数上限: 10.0")
+// calculateValueScore scores the core and records what each dimension
+// contributed; the breakdown is kept with the core, so what the API shows
+// is what the analyzer decided.
+func (a *Analyzer) calculateValueScore(coredump *collector.CoredumpFile, results *collector.AnalysisResults) *collector.ScoreBreakdown {
+	breakdown := Score(ScoreInput{
+		CrashReason:      results.CrashReason,
+		StackTraceLength: len(results.StackTrace),
+		ThreadCount:      results.ThreadCount,
+		PodName:          coredump.PodName,
+		InstanceName:     coredump.InstanceName,
+		Component:        coredump.Component,
+		Signal:           coredump.Signal,
+		Size:             coredump.Size,
+		Age:              time.Since(coredump.ModTime),
+	}, a.config().PanicKeywords, DefaultScoreWeights)
+
+	details := []string{fmt.Sprintf("基础分: %.1f", breakdown.Base)}
+	for _, dimension := range breakdown.Dimensions {
+		details = append(details, dimension.Detail)
+	}
+	if breakdown.Capped {
+		details = append(details, fmt.Sprintf("分数上限: %.1f", maxScore))
 	}
 
 	// Log detailed scoring breakdown
 	klog.Infof("分数计算详情 [%s]: %s -> 总分: %.2f", 
-		coredump.Path, strings.Join(scoreBreakdown, ", "), score)
+		coredump.Path, strings.Join(details, ", "), breakdown.Total)
 
-	return score
+	return breakdown
 }
 
 func (a *Analyzer) splitGdbOutput(output string) map[string]string {
@@ -538,9 +725,7 @@ func (a *Analyzer) extractSizeFromLine(line string) int64 {
 }
 
 func (a *Analyzer) sendEvent(event AnalysisEvent) {
-	select {
-	case a.eventChan <- event:
-	default:
+	if !chanstats.TrySend("analyzer_events", a.eventChan, event) {
 		klog.Warning("Analysis event channel is full, dropping event")
 	}
 }
\ No newline at end of file
@@ -10,13 +10,20 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// newTestAnalyzer returns an analyzer with only its configuration set.
+func newTestAnalyzer(config *config.AnalyzerConfig) *Analyzer {
+	analyzer := &Analyzer{}
+	analyzer.settings.Store(config)
+	return analyzer
+}
+
 func TestBasicValueScoring(t *testing.T) {
 	config := &config.AnalyzerConfig{
 		ValueThreshold: 4.0,
 		PanicKeywords:  []string{"panic", "fatal", "sigsegv", "sigabrt", "assert"},
 	}
 	
-	analyzer := &Analyzer{config: config}
+	analyzer := newTestAnalyzer(config)
 	
 	// Create test coredump file
 	coredump := &collector.CoredumpFile{
@@ -27,6 +34,7 @@ func TestBasicValueScoring(t *testing.T) {
 		PodName:       "test-pod",
 		ContainerName: "milvus",
 		InstanceName:  "test-instance",
+		Component:     "rootcoord",
 		CreatedAt:     metav1.Now(),
 		UpdatedAt:     metav1.Now(),
 	}
@@ -46,7 +54,8 @@ func TestBasicValueScoring(t *testing.T) {
 		},
 	}
 	
-	score := analyzer.calculateValueScore(coredump, results)
+	breakdown := analyzer.calculateValueScore(coredump, results)
+	score := breakdown.Total
 	
 	// Should get high score due to:
 	// - Base score: 4.0
@@ -58,11 +67,25 @@ func TestBasicValueScoring(t *testing.T) {
 	// - Severe signal: +1.0
 	// - Large file: +0.5
 	// - Fresh file: +0.5
-	// Total expected: ~11.0, capped at 10.0
+	// - Coordinator component: +0.5
+	// Total expected: 12.5, capped at 10.0
 	
 	if score < 9.0 || score > 10.0 {
 		t.Errorf("Expected high value score (9.0-10.0), got %.2f", score)
 	}
+	if !breakdown.Capped || len(breakdown.Dimensions) != 9 {
+		t.Errorf("Expected a capped breakdown of 9 dimensions, got %+v", breakdown)
+	}
+	sum := breakdown.Base
+	for _, dimension := range breakdown.Dimensions {
+		if dimension.Points != dimension.Max {
+			t.Errorf("Expected full points for %s, got %.1f of %.1f", dimension.Name, dimension.Points, dimension.Max)
+		}
+		sum += dimension.Points
+	}
+	if sum != 12.5 {
+		t.Errorf("Expected the dimensions to add up to 12.5 before the cap, got %.1f", sum)
+	}
 }
 
 func TestBasicCrashReasonExtraction(t *testing.T) {
@@ -123,7 +146,7 @@ func TestSkipAnalysisLogic(t *testing.T) {
 	config := &config.AnalyzerConfig{
 		IgnorePatterns: []string{"test", "debug"},
 	}
-	analyzer := &Analyzer{config: config}
+	analyzer := newTestAnalyzer(config)
 	
 	tests := []struct {
 		name        string
@@ -171,4 +194,21 @@ func TestSkipAnalysisLogic(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestPromptSummarizesPreCrashMetrics(t *testing.T) {
+	ai := &AIAnalyzer{config: &config.AIAnalysisConfig{}}
+	prompt := ai.buildAnalysisPrompt(&collector.CoredumpFile{Executable: "milvus", Signal: 6}, &collector.AnalysisResults{
+		PreCrashMetrics: []collector.MetricSeries{
+			{Name: "memory", Labels: map[string]string{"pod": "prod-querynode-0", "container": "querynode"}, Points: []collector.MetricPoint{
+				{Value: 2e9}, {Value: 1e9}, {Value: 7.9e9},
+			}},
+			{Name: "qps"},
+		},
+	})
+
+	want := `memory{container="querynode", pod="prod-querynode-0"}: first=2e+09 min=1e+09 max=7.9e+09 last=7.9e+09`
+	if !strings.Contains(prompt, "METRICS BEFORE CRASH:\n"+want+"\n\n") {
+		t.Errorf("expected the memory trend and no line for the empty series, got:\n%s", prompt)
+	}
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+func FuzzParseGdbOutput(f *testing.F) {
+	for _, fixture := range []string{
+		"sigsegv_backtrace.txt",
+		"milvus_assert_sigabrt.txt",
+		"deadlock_multi_thread.txt",
+		"truncated_output.txt",
+	} {
+		f.Add(testutil.LoadTestGDBOutput(f, fixture))
+	}
+	f.Add("=====BACKTRACE=====")
+	f.Add("==========\n=====\n=")
+	f.Add("=====REGISTERS=====\n=\n==\nrip = \n")
+
+	analyzer := &Analyzer{}
+
+	f.Fuzz(func(t *testing.T, output string) {
+		results, err := analyzer.parseGdbOutput(output)
+		if err != nil {
+			return
+		}
+		if results == nil {
+			t.Fatal("parseGdbOutput returned nil results without an error")
+		}
+		if results.ThreadCount < 0 {
+			t.Errorf("negative thread count %d", results.ThreadCount)
+		}
+	})
+}
+
+func FuzzParseAIResponse(f *testing.F) {
+	f.Add(`{"summary":"null deref","rootCause":"x","confidence":0.8}`)
+	f.Add("Here is the analysis:\n```json\n{\"summary\": \"s\", \"codeSuggestions\": [{\"lineNumber\": 12}]}\n```")
+	f.Add("}{")
+	f.Add(`{"confidence": "high"}`)
+	f.Add("")
+
+	ai := &AIAnalyzer{config: &config.AIAnalysisConfig{}}
+
+	f.Fuzz(func(t *testing.T, response string) {
+		result, err := ai.parseAIResponse(response)
+		if err == nil && result == nil {
+			t.Fatal("parseAIResponse returned nil result without an error")
+		}
+	})
+}
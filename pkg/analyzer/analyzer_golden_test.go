@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/gdb_outputs/golden")
+
+func TestParseGdbOutputGolden(t *testing.T) {
+	tests := []struct {
+		fixture           string
+		expectCrashReason string
+		expectThreads     int
+		expectStackTrace  bool
+	}{
+		{"sigsegv_backtrace.txt", "Segmentation fault (SIGSEGV)", 1, true},
+		{"sigabrt_backtrace.txt", "Assertion failure", 1, true},
+		{"multi_thread_backtrace.txt", "Segmentation fault (SIGSEGV)", 4, true},
+		{"milvus_segcore_sigsegv.txt", "Segmentation fault (SIGSEGV)", 4, true},
+		{"milvus_assert_sigabrt.txt", "Assertion failure", 2, true},
+		{"deadlock_multi_thread.txt", "Unknown crash reason", 6, true},
+		{"truncated_output.txt", "Segmentation fault (SIGSEGV)", 0, true},
+	}
+
+	analyzer := &Analyzer{}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output := testutil.LoadTestGDBOutput(t, tt.fixture)
+
+			results, err := analyzer.parseGdbOutput(output)
+			if err != nil {
+				t.Fatalf("parseGdbOutput failed: %v", err)
+			}
+
+			if results.CrashReason != tt.expectCrashReason {
+				t.Errorf("expected crash reason %q, got %q", tt.expectCrashReason, results.CrashReason)
+			}
+			if results.ThreadCount != tt.expectThreads {
+				t.Errorf("expected %d threads, got %d", tt.expectThreads, results.ThreadCount)
+			}
+			if tt.expectStackTrace && len(results.StackTrace) <= 100 {
+				t.Errorf("expected a stack trace longer than 100 characters, got %d", len(results.StackTrace))
+			}
+
+			goldenPath := filepath.Join("../../testdata/gdb_outputs/golden",
+				strings.TrimSuffix(tt.fixture, ".txt")+".json")
+
+			if *updateGolden {
+				data, err := json.MarshalIndent(results, "", "  ")
+				if err != nil {
+					t.Fatalf("Failed to marshal results: %v", err)
+				}
+				if err := os.WriteFile(goldenPath, append(data, '\n'), 0644); err != nil {
+					t.Fatalf("Failed to write golden file: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+
+			var expected collector.AnalysisResults
+			if err := json.Unmarshal(data, &expected); err != nil {
+				t.Fatalf("Failed to parse golden file: %v", err)
+			}
+
+			if !reflect.DeepEqual(normalizeResults(&expected), normalizeResults(results)) {
+				actual, _ := json.MarshalIndent(results, "", "  ")
+				t.Errorf("parsed results differ from %s:\n%s", goldenPath, actual)
+			}
+		})
+	}
+}
+
+// normalizeResults maps nil and empty collections to the same value so
+// that JSON round-trips compare equal.
+func normalizeResults(results *collector.AnalysisResults) *collector.AnalysisResults {
+	normalized := *results
+	if len(normalized.LibraryVersions) == 0 {
+		normalized.LibraryVersions = nil
+	}
+	if len(normalized.RegisterInfo) == 0 {
+		normalized.RegisterInfo = nil
+	}
+	if len(normalized.SharedLibraries) == 0 {
+		normalized.SharedLibraries = nil
+	}
+	return &normalized
+}
@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+func TestDuplicatesReuseAIAnalysis(t *testing.T) {
+	analyzer := newTestAnalyzer(&config.AnalyzerConfig{CrashGroups: config.CrashGroupConfig{Enabled: true, ReuseAIAnalysis: true}})
+	analyzer.groups = crashgroup.New(0)
+	results := &collector.AnalysisResults{StackTrace: testutil.LoadTestGDBOutput(t, "milvus_segcore_sigsegv.txt")}
+
+	first := &collector.CoredumpFile{ID: "core-1", Executable: "milvus", Signal: 11}
+	if analyzer.groupCrash(first, results) {
+		t.Fatal("first core must not be a duplicate")
+	}
+	if first.Fingerprint == "" || first.Occurrences != 1 {
+		t.Fatalf("expected a fingerprinted first occurrence, got %q (%d)", first.Fingerprint, first.Occurrences)
+	}
+	if analyzer.reusedAIAnalysis(first, false) != nil {
+		t.Fatal("first core must be analyzed itself")
+	}
+	analyzer.groups.RecordAIAnalysis(first.Fingerprint, first.ID, &collector.AIAnalysisResult{Summary: "null segment", CostUSD: 0.02, TokensUsed: 900})
+
+	second := &collector.CoredumpFile{ID: "core-2", Executable: "milvus", Signal: 11}
+	duplicate := analyzer.groupCrash(second, results)
+	if !duplicate || second.Fingerprint != first.Fingerprint || second.Occurrences != 2 {
+		t.Fatalf("expected the second core in the same group, got %q (%d)", second.Fingerprint, second.Occurrences)
+	}
+	reused := analyzer.reusedAIAnalysis(second, duplicate)
+	if reused == nil || reused.Summary != "null segment" || reused.ReusedFrom != "core-1" {
+		t.Fatalf("expected the first core's analysis, got %+v", reused)
+	}
+	if reused.CostUSD != 0 || reused.TokensUsed != 0 {
+		t.Errorf("reused analysis must not count the provider cost again: %+v", reused)
+	}
+
+	analyzer.config().CrashGroups.ReuseAIAnalysis = false
+	if analyzer.reusedAIAnalysis(second, duplicate) != nil {
+		t.Error("expected no reuse when disabled")
+	}
+}
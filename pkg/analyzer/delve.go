@@ -0,0 +1,267 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	// ntFile is the core note listing the files mapped by the process; its
+	// first entry is the executable.
+	ntFile = 0x46494c45
+
+	// maxGoroutineDump bounds the goroutine dump kept in the results; busy
+	// Milvus components run tens of thousands of goroutines.
+	maxGoroutineDump = 256 * 1024
+)
+
+// delveScript prints the crashing goroutine's stack with arguments and
+// locals, then every goroutine with its top frames.
+const delveScript = `stack 50 -full
+goroutines -t 10
+exit
+`
+
+var (
+	goroutineCountPattern = regexp.MustCompile(`\[(\d+) goroutines\]`)
+	currentGoroutine      = regexp.MustCompile(`(?m)^\*\s*Goroutine (\d+) `)
+	delveFramePattern     = regexp.MustCompile(`^\d+\s+0x[0-9a-fA-F]+\s+in\s+(\S+)$`)
+	delveVarPattern       = regexp.MustCompile(`^(\w+) = (.*)$`)
+)
+
+// analyzeWithDelve runs dlv on the core of a Go executable. Go cores are
+// recognized by the executable's build info, so the executable the core
+// names must be reachable; see findExecutable.
+func (a *Analyzer) analyzeWithDelve(coredump *collector.CoredumpFile) (*collector.GoAnalysis, error) {
+	name, err := coreExecutable(coredump.Path)
+	if err != nil {
+		return nil, err
+	}
+	executable := a.findExecutable(name)
+	if executable == "" {
+		return nil, fmt.Errorf("executable %s not found", name)
+	}
+	info, err := buildinfo.ReadFile(executable)
+	if err != nil {
+		// Not a Go executable; gdb's analysis is all there is.
+		return nil, nil
+	}
+
+	dlv := a.config().Delve.Path
+	if dlv == "" {
+		dlv = "dlv"
+	}
+	timeout := a.config().Delve.Timeout
+	if timeout <= 0 {
+		timeout = a.config().GdbTimeout
+	}
+
+	klog.V(2).Infof("Analyzing Go core %s with delve (executable %s, %s)", coredump.Path, executable, info.GoVersion)
+	output, err := a.watchdog.Output(context.Background(), "dlv", timeout,
+		strings.NewReader(delveScript), dlv, "core", executable, coredump.Path, "--allow-non-terminal-interactive=true")
+	if err != nil {
+		return nil, fmt.Errorf("delve analysis failed: %w", err)
+	}
+
+	analysis := parseDelveOutput(string(output))
+	analysis.GoVersion = info.GoVersion
+	return analysis, nil
+}
+
+// addGoAnalysis runs delve on Go cores; a failure leaves gdb's results as
+// they are.
+func (a *Analyzer) addGoAnalysis(coredump *collector.CoredumpFile, results *collector.AnalysisResults) {
+	analysis, err := a.analyzeWithDelve(coredump)
+	if err != nil {
+		klog.Warningf("Skipping Go analysis of %s: %v", coredump.Path, err)
+		return
+	}
+	if analysis != nil {
+		applyGoAnalysis(results, analysis)
+	}
+}
+
+// findExecutable resolves the executable path recorded in the core. It is
+// tried as is, then below each of Delve.ExecutablePaths, then by base name
+// in each of them.
+func (a *Analyzer) findExecutable(name string) string {
+	candidates := []string{name}
+	for _, dir := range a.config().Delve.ExecutablePaths {
+		candidates = append(candidates, filepath.Join(dir, name), filepath.Join(dir, filepath.Base(name)))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// coreExecutable returns the executable path from the NT_FILE note of an
+// ELF core.
+func coreExecutable(path string) (string, error) {
+	files, err := coreMappedFiles(path)
+	if err != nil {
+		return "", err
+	}
+	return files[0], nil
+}
+
+// coreMappedFiles returns the files mapped by the process from the NT_FILE
+// note of an ELF core, in mapping order, so the executable comes first. A
+// file mapped more than once is listed at its first mapping.
+func coreMappedFiles(path string) ([]string, error) {
+	core, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read core: %w", err)
+	}
+	defer core.Close()
+
+	if core.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("%s is not an ELF core", path)
+	}
+	for _, prog := range core.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return nil, fmt.Errorf("failed to read core notes: %w", err)
+		}
+		if files := mappedFiles(data, core.ByteOrder, core.Class); len(files) > 0 {
+			return files, nil
+		}
+	}
+	return nil, fmt.Errorf("core has no file mappings")
+}
+
+// mappedFiles walks the notes of a PT_NOTE segment for NT_FILE. Its
+// descriptor is a count and page size, count (start, end, offset) triples,
+// then count NUL-terminated file names.
+func mappedFiles(notes []byte, order binary.ByteOrder, class elf.Class) []string {
+	word := 8
+	if class == elf.ELFCLASS32 {
+		word = 4
+	}
+	readWord := func(b []byte) uint64 {
+		if word == 4 {
+			return uint64(order.Uint32(b))
+		}
+		return order.Uint64(b)
+	}
+	align := func(n uint32) int {
+		return int((n + 3) &^ 3)
+	}
+
+	for len(notes) >= 12 {
+		nameSize := order.Uint32(notes[0:4])
+		descSize := order.Uint32(notes[4:8])
+		noteType := order.Uint32(notes[8:12])
+		descStart := 12 + align(nameSize)
+		descEnd := descStart + int(descSize)
+		if descEnd > len(notes) {
+			return nil
+		}
+		desc := notes[descStart:descEnd]
+		notes = notes[12+align(nameSize)+align(descSize):]
+
+		if noteType != ntFile || len(desc) < 2*word {
+			continue
+		}
+		count := readWord(desc)
+		names := 2*word + int(count)*3*word
+		if count == 0 || names > len(desc) {
+			continue
+		}
+		var files []string
+		seen := make(map[string]bool)
+		for _, name := range bytes.Split(desc[names:], []byte{0}) {
+			if len(name) == 0 || seen[string(name)] {
+				continue
+			}
+			seen[string(name)] = true
+			files = append(files, string(name))
+			if len(files) == int(count) {
+				break
+			}
+		}
+		return files
+	}
+	return nil
+}
+
+// parseDelveOutput splits the output at the dlv prompts into the crashing
+// goroutine's stack and the goroutine dump, and picks the panic value or
+// fatal error from the runtime frames of the stack.
+func parseDelveOutput(output string) *collector.GoAnalysis {
+	analysis := &collector.GoAnalysis{}
+
+	sections := strings.Split(output, "(dlv)")
+	if len(sections) > 1 {
+		analysis.StackTrace = strings.Trim(sections[1], " \n")
+	}
+	if len(sections) > 2 {
+		dump := strings.Trim(sections[2], " \n")
+		if matches := goroutineCountPattern.FindStringSubmatch(dump); len(matches) == 2 {
+			analysis.GoroutineCount, _ = strconv.Atoi(matches[1])
+		}
+		if matches := currentGoroutine.FindStringSubmatch(dump); len(matches) == 2 {
+			analysis.CrashingGoroutine, _ = strconv.Atoi(matches[1])
+		}
+		if len(dump) > maxGoroutineDump {
+			dump = dump[:maxGoroutineDump] + "\n... (truncated)"
+		}
+		analysis.Goroutines = dump
+	}
+
+	function := ""
+	for _, line := range strings.Split(analysis.StackTrace, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := delveFramePattern.FindStringSubmatch(line); len(matches) == 2 {
+			function = matches[1]
+			continue
+		}
+		matches := delveVarPattern.FindStringSubmatch(line)
+		if len(matches) != 3 {
+			continue
+		}
+		switch {
+		case function == "runtime.gopanic" && matches[1] == "e" && analysis.PanicValue == "":
+			analysis.PanicValue = matches[2]
+		case (function == "runtime.throw" || function == "runtime.fatal") && matches[1] == "s" && analysis.FatalError == "":
+			analysis.FatalError, _ = strconv.Unquote(matches[2])
+		}
+	}
+	return analysis
+}
+
+// applyGoAnalysis attaches delve's findings. When the Go runtime itself
+// brought the process down, the Go stack replaces gdb's, which shows little
+// more than runtime.raise for Go code.
+func applyGoAnalysis(results *collector.AnalysisResults, analysis *collector.GoAnalysis) {
+	results.GoAnalysis = analysis
+	switch {
+	case analysis.PanicValue != "":
+		results.CrashReason = "Go panic: " + analysis.PanicValue
+	case analysis.FatalError != "":
+		results.CrashReason = "Go fatal error: " + analysis.FatalError
+	default:
+		return
+	}
+	if analysis.StackTrace != "" {
+		results.StackTrace = analysis.StackTrace
+	}
+}
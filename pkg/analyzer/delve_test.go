@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+// writeTestCore writes an ELF core whose only content is an NT_FILE note
+// mapping executable and then libraries.
+func writeTestCore(t *testing.T, executable string, libraries ...string) string {
+	t.Helper()
+
+	files := append([]string{executable}, libraries...)
+	var desc bytes.Buffer
+	binary.Write(&desc, binary.LittleEndian, []uint64{uint64(len(files)), 4096})
+	for i := range files {
+		start := uint64(0x400000 + i*0x1000)
+		binary.Write(&desc, binary.LittleEndian, []uint64{start, start + 0x1000, 0})
+	}
+	for _, file := range files {
+		desc.WriteString(file + "\x00")
+	}
+	for desc.Len()%4 != 0 {
+		desc.WriteByte(0)
+	}
+
+	var note bytes.Buffer
+	binary.Write(&note, binary.LittleEndian, []uint32{5, uint32(desc.Len()), ntFile})
+	note.WriteString("CORE\x00\x00\x00\x00")
+	note.Write(desc.Bytes())
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_CORE),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	prog := elf.Prog64{
+		Type:   uint32(elf.PT_NOTE),
+		Off:    64 + 56,
+		Filesz: uint64(note.Len()),
+		Memsz:  uint64(note.Len()),
+		Align:  4,
+	}
+
+	var core bytes.Buffer
+	binary.Write(&core, binary.LittleEndian, header)
+	binary.Write(&core, binary.LittleEndian, prog)
+	core.Write(note.Bytes())
+
+	path := filepath.Join(t.TempDir(), "core.milvus.1000.1234567890.6")
+	if err := os.WriteFile(path, core.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCoreExecutable(t *testing.T) {
+	executable, err := coreExecutable(writeTestCore(t, "/milvus/bin/milvus"))
+	if err != nil {
+		t.Fatalf("Failed to read core: %v", err)
+	}
+	if executable != "/milvus/bin/milvus" {
+		t.Errorf("expected /milvus/bin/milvus, got %q", executable)
+	}
+
+	notCore := filepath.Join(t.TempDir(), "core.milvus.1.0.6")
+	os.WriteFile(notCore, []byte("not an ELF file"), 0644)
+	if _, err := coreExecutable(notCore); err == nil {
+		t.Error("expected an error for a file that is not an ELF core")
+	}
+}
+
+func TestParseDelveOutput(t *testing.T) {
+	panicked := parseDelveOutput(testutil.LoadTestDelveOutput(t, "go_panic.txt"))
+	if !strings.HasPrefix(panicked.PanicValue, "interface {}(runtime.boundsError)") {
+		t.Errorf("unexpected panic value: %q", panicked.PanicValue)
+	}
+	if panicked.FatalError != "" {
+		t.Errorf("expected no fatal error, got %q", panicked.FatalError)
+	}
+	if panicked.GoroutineCount != 3 || panicked.CrashingGoroutine != 1187 {
+		t.Errorf("expected goroutine 1187 of 3, got %d of %d", panicked.CrashingGoroutine, panicked.GoroutineCount)
+	}
+	if !strings.Contains(panicked.StackTrace, "(*queryTask).PostExecute") || strings.Contains(panicked.StackTrace, "Goroutine 1 ") {
+		t.Errorf("expected only the crashing goroutine's stack, got:\n%s", panicked.StackTrace)
+	}
+
+	fatal := parseDelveOutput(testutil.LoadTestDelveOutput(t, "go_fatal_error.txt"))
+	if fatal.FatalError != "concurrent map writes" || fatal.PanicValue != "" {
+		t.Errorf("expected the fatal error, got %+v", fatal)
+	}
+
+	results := &collector.AnalysisResults{StackTrace: "#0 runtime.raise ()", CrashReason: "SIGABRT"}
+	applyGoAnalysis(results, fatal)
+	if results.CrashReason != "Go fatal error: concurrent map writes" || results.StackTrace != fatal.StackTrace {
+		t.Errorf("expected the Go stack and error to replace gdb's, got %q", results.CrashReason)
+	}
+}
+
+func TestAnalyzeWithDelve(t *testing.T) {
+	goExecutable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := filepath.Abs("../../testdata/dlv_outputs/go_panic.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dlv := filepath.Join(t.TempDir(), "dlv")
+	if err := os.WriteFile(dlv, []byte("#!/bin/sh\ncat "+fixture+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The core names the executable by its path in the crashed container.
+	analyzer := newTestAnalyzer(&config.AnalyzerConfig{
+		GdbTimeout: 10 * time.Second,
+		Delve: config.DelveConfig{
+			Enabled:         true,
+			Path:            dlv,
+			ExecutablePaths: []string{filepath.Dir(goExecutable)},
+		},
+	})
+	core := &collector.CoredumpFile{Path: writeTestCore(t, "/milvus/bin/"+filepath.Base(goExecutable))}
+	analysis, err := analyzer.analyzeWithDelve(core)
+	if err != nil {
+		t.Fatalf("delve analysis failed: %v", err)
+	}
+	if analysis == nil || analysis.GoVersion == "" || analysis.CrashingGoroutine != 1187 {
+		t.Fatalf("unexpected analysis: %+v", analysis)
+	}
+
+	notGo := filepath.Join(t.TempDir(), "milvus")
+	os.WriteFile(notGo, []byte("#!/bin/sh\n"), 0755)
+	core.Path = writeTestCore(t, notGo)
+	if analysis, err := analyzer.analyzeWithDelve(core); analysis != nil || err != nil {
+		t.Errorf("expected no Go analysis for a non-Go executable, got %+v (%v)", analysis, err)
+	}
+
+	core.Path = writeTestCore(t, "/milvus/bin/missing")
+	if _, err := analyzer.analyzeWithDelve(core); err == nil {
+		t.Error("expected an error when the executable is not found")
+	}
+}
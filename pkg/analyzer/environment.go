@@ -0,0 +1,286 @@
+package analyzer
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	packageManagerDpkg = "dpkg"
+	packageManagerApk  = "apk"
+)
+
+// captureEnvironment builds the environment manifest of a core. The
+// libraries come from the core itself; the OS, packages, build IDs and
+// library owners need the container's root filesystem, which is only
+// reachable while a process of the container runs. Restarted containers
+// run the same image, so their replacement serves as well.
+func (a *Analyzer) captureEnvironment(coredump *collector.CoredumpFile) *collector.EnvironmentManifest {
+	files, err := coreMappedFiles(coredump.Path)
+	if err != nil {
+		klog.Warningf("Skipping environment manifest of %s: %v", coredump.Path, err)
+		return nil
+	}
+
+	manifest := &collector.EnvironmentManifest{Libraries: []collector.SharedLibrary{}}
+	for _, file := range files {
+		if isSharedLibrary(file) {
+			manifest.Libraries = append(manifest.Libraries, collector.SharedLibrary{Path: file})
+		}
+	}
+
+	root := containerRoot(a.config().Environment.ProcPath, coredump.ContainerID)
+	if root == "" {
+		klog.V(2).Infof("No running process of container %q, environment manifest of %s lists libraries only", coredump.ContainerID, coredump.Path)
+		return manifest
+	}
+
+	manifest.OS = osRelease(root)
+	var owners map[string]string
+	switch {
+	case fileExists(filepath.Join(root, "var/lib/dpkg/status")):
+		manifest.PackageManager = packageManagerDpkg
+		manifest.Packages = dpkgPackages(root)
+		owners = dpkgOwners(root, manifest.Libraries)
+	case fileExists(filepath.Join(root, "lib/apk/db/installed")):
+		manifest.PackageManager = packageManagerApk
+		manifest.Packages, owners = apkPackages(root)
+	}
+
+	versions := make(map[string]string, len(manifest.Packages))
+	for _, pkg := range manifest.Packages {
+		versions[pkg.Name] = pkg.Version
+	}
+	for i := range manifest.Libraries {
+		library := &manifest.Libraries[i]
+		library.BuildID = buildID(filepath.Join(root, library.Path))
+		for _, path := range ownedPaths(library.Path) {
+			if owner, found := owners[path]; found {
+				library.Package = owner
+				library.Version = versions[owner]
+				break
+			}
+		}
+	}
+	return manifest
+}
+
+// isSharedLibrary matches libfoo.so and versioned names like libfoo.so.1.2.
+func isSharedLibrary(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")
+}
+
+// ownedPaths returns the paths a package database may list a mapped
+// library under. Libraries are often mapped through a symlink such as
+// /lib/x86_64-linux-gnu -> /usr/lib/x86_64-linux-gnu, so the path with the
+// /usr prefix toggled is tried as well.
+func ownedPaths(path string) []string {
+	if rest, found := strings.CutPrefix(path, "/usr/"); found {
+		return []string{path, "/" + rest}
+	}
+	return []string{path, "/usr" + path}
+}
+
+// containerRoot returns the root filesystem of a running process of the
+// container, found by the container ID in the processes' cgroups.
+func containerRoot(procPath, containerID string) string {
+	if containerID == "" {
+		return ""
+	}
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	procs, err := os.ReadDir(procPath)
+	if err != nil {
+		return ""
+	}
+	for _, proc := range procs {
+		if strings.Trim(proc.Name(), "0123456789") != "" {
+			continue
+		}
+		cgroup, err := os.ReadFile(filepath.Join(procPath, proc.Name(), "cgroup"))
+		if err != nil || !strings.Contains(string(cgroup), containerID) {
+			continue
+		}
+		root := filepath.Join(procPath, proc.Name(), "root")
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			return root
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// osRelease returns PRETTY_NAME from the root's os-release.
+func osRelease(root string) string {
+	for _, path := range []string{"etc/os-release", "usr/lib/os-release"} {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, found := strings.CutPrefix(line, "PRETTY_NAME="); found {
+				return strings.Trim(value, `"'`)
+			}
+		}
+	}
+	return ""
+}
+
+// dpkgPackages reads the installed packages from dpkg's status database.
+func dpkgPackages(root string) []collector.Package {
+	file, err := os.Open(filepath.Join(root, "var/lib/dpkg/status"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var packages []collector.Package
+	var current collector.Package
+	installed := false
+	flush := func() {
+		if installed && current.Name != "" {
+			packages = append(packages, current)
+		}
+		current, installed = collector.Package{}, false
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "Package: "):
+			current.Name = strings.TrimPrefix(line, "Package: ")
+		case strings.HasPrefix(line, "Version: "):
+			current.Version = strings.TrimPrefix(line, "Version: ")
+		case strings.HasPrefix(line, "Status: "):
+			installed = strings.HasSuffix(line, " installed")
+		}
+	}
+	flush()
+	sortPackages(packages)
+	return packages
+}
+
+// dpkgOwners maps the paths the libraries may be listed under to the
+// packages listing them in /var/lib/dpkg/info/<package>.list.
+func dpkgOwners(root string, libraries []collector.SharedLibrary) map[string]string {
+	wanted := make(map[string]bool)
+	for _, library := range libraries {
+		for _, path := range ownedPaths(library.Path) {
+			wanted[path] = true
+		}
+	}
+
+	lists, _ := filepath.Glob(filepath.Join(root, "var/lib/dpkg/info/*.list"))
+	owners := make(map[string]string)
+	for _, list := range lists {
+		data, err := os.ReadFile(list)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(list), ".list")
+		name, _, _ = strings.Cut(name, ":")
+		for _, line := range strings.Split(string(data), "\n") {
+			if wanted[line] {
+				owners[line] = name
+			}
+		}
+	}
+	return owners
+}
+
+// apkPackages reads apk's installed database, which lists each package's
+// files as F: (directory) and R: (file) lines.
+func apkPackages(root string) ([]collector.Package, map[string]string) {
+	file, err := os.Open(filepath.Join(root, "lib/apk/db/installed"))
+	if err != nil {
+		return nil, nil
+	}
+	defer file.Close()
+
+	var packages []collector.Package
+	owners := make(map[string]string)
+	var current collector.Package
+	directory := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if current.Name != "" {
+				packages = append(packages, current)
+			}
+			current, directory = collector.Package{}, ""
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		switch key {
+		case "P":
+			current.Name = value
+		case "V":
+			current.Version = value
+		case "F":
+			directory = value
+		case "R":
+			if isSharedLibrary(value) {
+				owners["/"+filepath.Join(directory, value)] = current.Name
+			}
+		}
+	}
+	if current.Name != "" {
+		packages = append(packages, current)
+	}
+	sortPackages(packages)
+	return packages, owners
+}
+
+func sortPackages(packages []collector.Package) {
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+}
+
+// buildID reads the GNU build ID note of an ELF file, the key debuginfod
+// and debug file directories look debug info up by.
+func buildID(path string) string {
+	file, err := elf.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := file.Section(".note.gnu.build-id")
+	if section == nil {
+		return ""
+	}
+	data, err := section.Data()
+	if err != nil || len(data) < 16 {
+		return ""
+	}
+	nameSize := file.ByteOrder.Uint32(data[0:4])
+	descSize := file.ByteOrder.Uint32(data[4:8])
+	descStart := 12 + int((nameSize+3)&^3)
+	if descStart+int(descSize) > len(data) {
+		return ""
+	}
+	return hex.EncodeToString(data[descStart : descStart+int(descSize)])
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// writeFiles creates files below root from a map of relative paths to
+// contents.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCaptureEnvironmentDpkg(t *testing.T) {
+	core := writeTestCore(t, "/milvus/bin/milvus",
+		"/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"/milvus/lib/libjemalloc.so",
+		"/usr/share/locale/locale-archive",
+		"/usr/lib/x86_64-linux-gnu/libc.so.6")
+	proc := t.TempDir()
+	writeFiles(t, proc, map[string]string{
+		"1/cgroup":               "0::/kubepods/besteffort/pod1/cri-containerd-other.scope\n",
+		"42/cgroup":              "0::/kubepods/burstable/pod2/cri-containerd-abc123.scope\n",
+		"42/root/etc/os-release": "NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 22.04.4 LTS\"\n",
+		"42/root/var/lib/dpkg/status": "Package: libc6\nStatus: install ok installed\nArchitecture: amd64\nVersion: 2.35-0ubuntu3.8\n\n" +
+			"Package: removed\nStatus: deinstall ok config-files\nVersion: 1.0\n\n" +
+			"Package: bash\nStatus: install ok installed\nVersion: 5.1-6ubuntu1\n",
+		"42/root/var/lib/dpkg/info/libc6:amd64.list": "/.\n/lib/x86_64-linux-gnu\n/lib/x86_64-linux-gnu/libc.so.6\n",
+	})
+	analyzer := newTestAnalyzer(&config.AnalyzerConfig{Environment: config.EnvironmentConfig{Enabled: true, ProcPath: proc}})
+
+	manifest := analyzer.captureEnvironment(&collector.CoredumpFile{Path: core, ContainerID: "abc123"})
+	if manifest == nil {
+		t.Fatal("expected a manifest")
+	}
+	if manifest.OS != "Ubuntu 22.04.4 LTS" || manifest.PackageManager != "dpkg" {
+		t.Errorf("unexpected OS %q or package manager %q", manifest.OS, manifest.PackageManager)
+	}
+	expectedPackages := []collector.Package{{Name: "bash", Version: "5.1-6ubuntu1"}, {Name: "libc6", Version: "2.35-0ubuntu3.8"}}
+	if !reflect.DeepEqual(manifest.Packages, expectedPackages) {
+		t.Errorf("expected installed packages %+v, got %+v", expectedPackages, manifest.Packages)
+	}
+	expectedLibraries := []collector.SharedLibrary{
+		{Path: "/usr/lib/x86_64-linux-gnu/libc.so.6", Package: "libc6", Version: "2.35-0ubuntu3.8"},
+		{Path: "/milvus/lib/libjemalloc.so"},
+	}
+	if !reflect.DeepEqual(manifest.Libraries, expectedLibraries) {
+		t.Errorf("expected libraries %+v, got %+v", expectedLibraries, manifest.Libraries)
+	}
+
+	// Without a running process of the container only the libraries are known.
+	manifest = analyzer.captureEnvironment(&collector.CoredumpFile{Path: core, ContainerID: "gone"})
+	if manifest == nil || len(manifest.Libraries) != 2 || manifest.Packages != nil || manifest.OS != "" {
+		t.Errorf("expected a libraries-only manifest, got %+v", manifest)
+	}
+}
+
+func TestCaptureEnvironmentApk(t *testing.T) {
+	core := writeTestCore(t, "/usr/bin/etcd", "/lib/ld-musl-x86_64.so.1")
+	proc := t.TempDir()
+	writeFiles(t, proc, map[string]string{
+		"7/cgroup":              "0::/kubepods/pod3/def456\n",
+		"7/root/etc/os-release": "PRETTY_NAME='Alpine Linux v3.19'\n",
+		"7/root/lib/apk/db/installed": "C:Q1abc=\nP:musl\nV:1.2.4_git20230717-r4\nF:lib\nR:ld-musl-x86_64.so.1\nR:libc.musl-x86_64.so.1\n\n" +
+			"P:busybox\nV:1.36.1-r15\nF:bin\nR:busybox\n",
+	})
+	analyzer := newTestAnalyzer(&config.AnalyzerConfig{Environment: config.EnvironmentConfig{Enabled: true, ProcPath: proc}})
+
+	manifest := analyzer.captureEnvironment(&collector.CoredumpFile{Path: core, ContainerID: "def456"})
+	if manifest == nil || manifest.OS != "Alpine Linux v3.19" || manifest.PackageManager != "apk" || len(manifest.Packages) != 2 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	library := manifest.Libraries[0]
+	if library.Package != "musl" || library.Version != "1.2.4_git20230717-r4" {
+		t.Errorf("expected the loader to belong to musl, got %+v", library)
+	}
+}
@@ -0,0 +1,31 @@
+package analyzer
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/ghissues"
+)
+
+// UseIssueMatcher has AI analyses list the GitHub issues matcher finds for
+// the core as their related issues. Call it before Start.
+func (a *Analyzer) UseIssueMatcher(matcher *ghissues.Matcher) {
+	a.issues = matcher
+}
+
+// matchIssues replaces the related issues the model named, which it often
+// makes up, with the GitHub issues that mention the core's crash site.
+func (a *Analyzer) matchIssues(ctx context.Context, coredump *collector.CoredumpFile, results *collector.AnalysisResults) {
+	if a.issues == nil || results == nil || results.AIAnalysis == nil {
+		return
+	}
+	issues, err := a.issues.Match(ctx, coredump, results)
+	if err != nil {
+		klog.Warningf("Failed to search GitHub issues for %s: %v", coredump.Path, err)
+	} else if len(issues) > 0 {
+		klog.Infof("Coredump %s matches %d GitHub issues, first %s", coredump.Path, len(issues), issues[0])
+	}
+	results.AIAnalysis.RelatedIssues = issues
+}
@@ -0,0 +1,218 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+// maxJemallocBins bounds the size classes kept in the results.
+const maxJemallocBins = 10
+
+// jemallocScript walks jemalloc's arenas and bins in the core and prints
+// their counters as JSON. The layout of arena_t changed across jemalloc 5.x,
+// so every field is looked up along the paths of 5.1 to 5.3, and atomics are
+// unwrapped from their repr field. Counters the core's jemalloc lacks are
+// left out.
+const jemallocScript = `
+set pagination off
+python
+import json
+
+def lookup(names):
+    for name in names:
+        try:
+            return gdb.parse_and_eval(name)
+        except gdb.error:
+            pass
+    return None
+
+def number(value):
+    try:
+        return int(value['repr'])
+    except gdb.error:
+        return int(value)
+
+def field(value, *paths):
+    for path in paths:
+        try:
+            current = value
+            for name in path.split('.'):
+                current = current[name]
+            return number(current)
+        except gdb.error:
+            continue
+    return None
+
+def length(array):
+    low, high = array.type.range()
+    return high - low + 1
+
+def shards(arena, infos, index):
+    try:
+        bins = arena['bins'][index]
+    except gdb.error:
+        # 5.3: the bins follow the arena at per size class offsets.
+        offsets = lookup(['je_arena_bin_offsets', 'arena_bin_offsets'])
+        if offsets is None:
+            raise gdb.error('unknown jemalloc bin layout')
+        base = arena.address.cast(gdb.lookup_type('char').pointer()) + int(offsets[index])
+        first = base.cast(gdb.lookup_type('bin_t').pointer())
+        return [first[i] for i in range(int(infos[index]['n_shards']))]
+    try:
+        # 5.2: bins are sharded.
+        return [bins['bin_shards'][i] for i in range(int(infos[index]['n_shards']))]
+    except gdb.error:
+        return [bins]
+
+def collect():
+    arenas = lookup(['je_arenas', 'arenas'])
+    infos = lookup(['je_bin_infos', 'bin_infos'])
+    if arenas is None or infos is None:
+        return {'error': 'jemalloc symbols not found'}
+    arena_type = gdb.lookup_type('arena_t').pointer()
+
+    bins = [{'size': int(infos[i]['reg_size']), 'slabRegions': int(infos[i]['nregs']), 'regions': 0, 'slabs': 0}
+            for i in range(length(infos))]
+    result = {'arenas': 0, 'activePages': 0, 'bins': bins}
+    for index in range(length(arenas)):
+        pointer = arenas[index]
+        try:
+            pointer = pointer['repr']
+        except gdb.error:
+            pass
+        if int(pointer) == 0:
+            continue
+        arena = pointer.cast(arena_type).dereference()
+        result['arenas'] += 1
+        result['activePages'] += field(arena, 'pa_shard.nactive', 'nactive') or 0
+        for key, paths in (
+                ('resident', ('stats.resident',)),
+                ('mapped', ('stats.mapped', 'stats.pa_shard_stats.mapped')),
+                ('retained', ('stats.retained', 'stats.pa_shard_stats.pac_stats.retained')),
+                ('allocatedLarge', ('stats.allocated_large',))):
+            value = field(arena, *paths)
+            if value is not None:
+                result[key] = result.get(key, 0) + value
+        for i, entry in enumerate(bins):
+            for shard in shards(arena, infos, i):
+                entry['regions'] += field(shard, 'stats.curregs') or 0
+                entry['slabs'] += field(shard, 'stats.curslabs') or 0
+    return result
+
+try:
+    result = collect()
+except Exception as e:
+    result = {'error': str(e)}
+print('=====JEMALLOC=====')
+print(json.dumps(result))
+end
+quit
+`
+
+type jemallocOutput struct {
+	Error          string  `json:"error"`
+	Arenas         int     `json:"arenas"`
+	ActivePages    uint64  `json:"activePages"`
+	Resident       uint64  `json:"resident"`
+	Mapped         uint64  `json:"mapped"`
+	Retained       uint64  `json:"retained"`
+	AllocatedLarge *uint64 `json:"allocatedLarge"`
+	Bins           []struct {
+		Size        uint64 `json:"size"`
+		SlabRegions uint64 `json:"slabRegions"`
+		Regions     uint64 `json:"regions"`
+		Slabs       uint64 `json:"slabs"`
+	} `json:"bins"`
+}
+
+// addJemallocStats runs the jemalloc script on the core; a failure, such
+// as a process that doesn't use jemalloc, leaves the results as they are.
+func (a *Analyzer) addJemallocStats(coredump *collector.CoredumpFile, results *collector.AnalysisResults) {
+	timeout := a.config().Jemalloc.Timeout
+	if timeout <= 0 {
+		timeout = a.config().GdbTimeout
+	}
+	env, args := a.gdbSymbolOptions()
+	args = append(args, "-batch", "-x", "-", coredump.Path)
+
+	output, err := a.watchdog.OutputEnv(context.Background(), "gdb", timeout,
+		env, strings.NewReader(jemallocScript), "gdb", args...)
+	if err != nil {
+		klog.Warningf("Skipping jemalloc stats of %s: gdb failed: %v", coredump.Path, err)
+		return
+	}
+	stats, err := parseJemallocOutput(string(output), uint64(os.Getpagesize()))
+	if err != nil {
+		klog.V(2).Infof("Skipping jemalloc stats of %s: %v", coredump.Path, err)
+		return
+	}
+	results.Jemalloc = stats
+}
+
+// parseJemallocOutput turns the script's counters into byte counts. Active
+// pages are converted with the page size of this node, where the core was
+// written.
+func parseJemallocOutput(output string, pageSize uint64) (*collector.JemallocStats, error) {
+	_, data, found := strings.Cut(output, "=====JEMALLOC=====\n")
+	if !found {
+		return nil, fmt.Errorf("no jemalloc output")
+	}
+	data, _, _ = strings.Cut(data, "\n")
+
+	var parsed jemallocOutput
+	if err := json.Unmarshal([]byte(data), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid jemalloc output: %w", err)
+	}
+	if parsed.Error != "" {
+		return nil, fmt.Errorf("%s", parsed.Error)
+	}
+	if parsed.Arenas == 0 {
+		return nil, fmt.Errorf("no initialized jemalloc arenas")
+	}
+
+	stats := &collector.JemallocStats{
+		Arenas:   parsed.Arenas,
+		Active:   parsed.ActivePages * pageSize,
+		Resident: parsed.Resident,
+		Mapped:   parsed.Mapped,
+		Retained: parsed.Retained,
+	}
+	var small uint64
+	unused := make(map[uint64]uint64)
+	for _, bin := range parsed.Bins {
+		small += bin.Regions * bin.Size
+		capacity := bin.Slabs * bin.SlabRegions
+		if capacity == 0 || bin.Regions > capacity {
+			continue
+		}
+		unused[bin.Size] = (capacity - bin.Regions) * bin.Size
+		stats.Bins = append(stats.Bins, collector.JemallocBin{
+			Size:        bin.Size,
+			Regions:     bin.Regions,
+			Slabs:       bin.Slabs,
+			Utilization: float64(bin.Regions) / float64(capacity),
+		})
+	}
+	if parsed.AllocatedLarge != nil {
+		stats.Allocated = small + *parsed.AllocatedLarge
+		if stats.Active > 0 && stats.Allocated <= stats.Active {
+			stats.Fragmentation = 1 - float64(stats.Allocated)/float64(stats.Active)
+		}
+	}
+
+	sort.SliceStable(stats.Bins, func(i, j int) bool {
+		return unused[stats.Bins[i].Size] > unused[stats.Bins[j].Size]
+	})
+	if len(stats.Bins) > maxJemallocBins {
+		stats.Bins = stats.Bins[:maxJemallocBins]
+	}
+	return stats, nil
+}
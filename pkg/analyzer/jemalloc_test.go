@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestParseJemallocOutput(t *testing.T) {
+	output, err := os.ReadFile("../../testdata/gdb_outputs/jemalloc_stats.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := parseJemallocOutput(string(output), 4096)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if stats.Arenas != 4 || stats.Active != 2<<30 || stats.Resident != 2415919104 || stats.Retained != 1<<30 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if stats.Allocated != 1<<30+8*1024+4096*100 {
+		t.Errorf("expected small and large allocations to add up, got %d", stats.Allocated)
+	}
+	if math.Abs(stats.Fragmentation-0.4998) > 0.0001 {
+		t.Errorf("expected about half of the active pages unused, got %.4f", stats.Fragmentation)
+	}
+	if len(stats.Bins) != 2 || stats.Bins[0].Size != 4096 || stats.Bins[0].Utilization != 0.125 {
+		t.Errorf("expected the 4KiB bin to waste the most, empty bins left out, got %+v", stats.Bins)
+	}
+
+	for _, output := range []string{
+		"no marker",
+		"=====JEMALLOC=====\n{\"error\": \"jemalloc symbols not found\"}\n",
+		"=====JEMALLOC=====\n{\"arenas\": 0}\n",
+		"=====JEMALLOC=====\nnot json\n",
+	} {
+		if _, err := parseJemallocOutput(output, 4096); err == nil {
+			t.Errorf("expected an error for %q", output)
+		}
+	}
+
+	// Without the large allocations there is no fragmentation to report.
+	stats, err = parseJemallocOutput("=====JEMALLOC=====\n{\"arenas\": 1, \"activePages\": 10}\n", 4096)
+	if err != nil || stats.Allocated != 0 || stats.Fragmentation != 0 {
+		t.Errorf("expected no allocated bytes or fragmentation, got %+v (%v)", stats, err)
+	}
+}
+
+func TestAddJemallocStats(t *testing.T) {
+	fixture, err := filepath.Abs("../../testdata/gdb_outputs/jemalloc_stats.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "gdb"), []byte("#!/bin/sh\ncat "+fixture+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	analyzer := newTestAnalyzer(&config.AnalyzerConfig{
+		GdbTimeout: 10 * time.Second,
+		Jemalloc:   config.JemallocConfig{Enabled: true},
+	})
+	results := &collector.AnalysisResults{}
+	analyzer.addJemallocStats(&collector.CoredumpFile{Path: "core"}, results)
+	if results.Jemalloc == nil || results.Jemalloc.Arenas != 4 {
+		t.Errorf("expected jemalloc stats, got %+v", results.Jemalloc)
+	}
+}
@@ -0,0 +1,38 @@
+package analyzer
+
+import (
+	"context"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+// AnalyzeFile analyzes a single core outside the agent's pipeline, as
+// diagctl does on a developer's machine. The core gets the gdb analysis, or
+// the basic one when gdb is disabled, the AI analysis when enabled, and its
+// score; there are no state transitions, retries, crash groups or pre-crash
+// logs and metrics. executable, when set, is passed to gdb along with the
+// core, for cores whose binary is not where they recorded it.
+func (a *Analyzer) AnalyzeFile(coredump *collector.CoredumpFile, executable string) error {
+	var results *collector.AnalysisResults
+	var err error
+	if a.config().EnableGdbAnalysis {
+		results, err = a.analyzeWithGdb(coredump, executable)
+	} else {
+		results, err = a.basicAnalysis(coredump)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.aiAnalyzer != nil && a.config().AIAnalysis.Enabled {
+		a.analyzeWithAI(context.Background(), coredump, results)
+	}
+
+	coredump.AnalysisResults = results
+	coredump.ScoreBreakdown = a.calculateValueScore(coredump, results)
+	coredump.ValueScore = coredump.ScoreBreakdown.Total
+	coredump.IsAnalyzed = true
+	coredump.AnalysisTime = time.Now()
+	return nil
+}
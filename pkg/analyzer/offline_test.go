@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestAnalyzeFile(t *testing.T) {
+	fixture, err := filepath.Abs("../../testdata/gdb_outputs/milvus_segcore_sigsegv.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	argsFile := filepath.Join(bin, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat " + fixture + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "gdb"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	analyzer := New(&config.AnalyzerConfig{
+		EnableGdbAnalysis: true,
+		GdbTimeout:        10 * time.Second,
+		PanicKeywords:     []string{"SIGSEGV"},
+		AIAnalysis:        config.AIAnalysisConfig{Enabled: true, Provider: "fake", Model: "fake"},
+	}, nil, nil, nil, nil, nil, nil, nil, "")
+
+	coredump := &collector.CoredumpFile{Path: "core.milvus.1234", Executable: "milvus", Signal: 11, ModTime: time.Now()}
+	if err := analyzer.AnalyzeFile(coredump, "./milvus"); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(args)), "./milvus core.milvus.1234") {
+		t.Errorf("expected gdb to get the executable before the core, got %q", args)
+	}
+	results := coredump.AnalysisResults
+	if results == nil || results.StackTrace == "" {
+		t.Fatalf("expected the gdb stack trace, got %+v", results)
+	}
+	if results.AIAnalysis == nil || results.AIAnalysis.Summary == "" {
+		t.Errorf("expected an AI summary from the fake provider, got %+v", results.AIAnalysis)
+	}
+	if !coredump.IsAnalyzed || coredump.ScoreBreakdown == nil || coredump.ValueScore != coredump.ScoreBreakdown.Total {
+		t.Errorf("expected the core to be scored, got %+v", coredump.ScoreBreakdown)
+	}
+}
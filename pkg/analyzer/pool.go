@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/chanstats"
+)
+
+const (
+	// Each analysis may run gdb on a multi-gigabyte core, so only a few run
+	// at once.
+	defaultMaxConcurrentAnalyses = 2
+	defaultAnalysisQueueSize     = 100
+	defaultDrainTimeout          = 30 * time.Second
+
+	queueChannel         = "analyzer_queue"
+	priorityQueueChannel = "analyzer_priority_queue"
+)
+
+// errQueueFull is returned by Requeue when no more analyses can be queued.
+var errQueueFull = errors.New("the analysis queue is full")
+
+// PoolStats describes the analysis worker pool. Queued includes the
+// Prioritized analyses; Capacity is that of the regular queue.
+type PoolStats struct {
+	Workers     int
+	Busy        int
+	Queued      int
+	Prioritized int
+	Capacity    int
+}
+
+// pool runs analyses on a fixed number of workers, so a burst of cores
+// doesn't start a gdb process for each of them at once. Analyses queued
+// with submitFirst, such as of cores the watchlist hunts for, run before
+// the others.
+type pool struct {
+	workers  int
+	queue    chan func()
+	priority chan func()
+	busy     atomic.Int32
+	stopping atomic.Bool
+}
+
+func newPool(workers, queueSize int) *pool {
+	if workers <= 0 {
+		workers = defaultMaxConcurrentAnalyses
+	}
+	if queueSize <= 0 {
+		queueSize = defaultAnalysisQueueSize
+	}
+	p := &pool{
+		workers:  workers,
+		queue:    make(chan func(), queueSize),
+		priority: make(chan func(), queueSize),
+	}
+	chanstats.Register(queueChannel, p.queue)
+	chanstats.Register(priorityQueueChannel, p.priority)
+	return p
+}
+
+// submit queues an analysis, waiting while the queue is full. It fails
+// once ctx is done.
+func (p *pool) submit(ctx context.Context, job func()) bool {
+	return send(ctx, p.queue, job)
+}
+
+// submitFirst queues an analysis ahead of those queued by submit.
+func (p *pool) submitFirst(ctx context.Context, job func()) bool {
+	return send(ctx, p.priority, job)
+}
+
+func send(ctx context.Context, queue chan func(), job func()) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case queue <- job:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// trySubmit queues an analysis unless the queue is full.
+func (p *pool) trySubmit(job func()) bool {
+	return chanstats.TrySend(queueChannel, p.queue, job)
+}
+
+// run starts the workers and blocks until ctx is done and the running and
+// queued analyses finished, or drainTimeout passed.
+func (p *pool) run(ctx context.Context, drainTimeout time.Duration) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	<-ctx.Done()
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		klog.Info("Analysis queue drained")
+	case <-time.After(drainTimeout):
+		p.stopping.Store(true)
+		stats := p.stats()
+		klog.Warningf("Stopped draining the analysis queue after %s with %d analyses running and %d queued", drainTimeout, stats.Busy, stats.Queued)
+	}
+}
+
+// work runs queued analyses, prioritized ones first. Once ctx is done it
+// empties the queues and returns, unless the drain timed out first.
+func (p *pool) work(ctx context.Context) {
+	for {
+		// select picks at random among the queues with analyses waiting.
+		if job, ok := p.next(); ok {
+			p.do(job)
+			continue
+		}
+		select {
+		case job := <-p.priority:
+			p.do(job)
+		case job := <-p.queue:
+			p.do(job)
+		case <-ctx.Done():
+			for !p.stopping.Load() {
+				job, ok := p.next()
+				if !ok {
+					return
+				}
+				p.do(job)
+			}
+			return
+		}
+	}
+}
+
+// next takes the next queued analysis without waiting.
+func (p *pool) next() (func(), bool) {
+	select {
+	case job := <-p.priority:
+		return job, true
+	default:
+	}
+	select {
+	case job := <-p.queue:
+		return job, true
+	default:
+		return nil, false
+	}
+}
+
+func (p *pool) do(job func()) {
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+	job()
+}
+
+func (p *pool) stats() PoolStats {
+	return PoolStats{
+		Workers:     p.workers,
+		Busy:        int(p.busy.Load()),
+		Queued:      len(p.queue) + len(p.priority),
+		Prioritized: len(p.priority),
+		Capacity:    cap(p.queue),
+	}
+}
+
+// PoolStats returns the state of the analysis worker pool.
+func (a *Analyzer) PoolStats() PoolStats {
+	if a == nil {
+		return PoolStats{}
+	}
+	return a.pool.stats()
+}
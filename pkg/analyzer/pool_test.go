@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	p := newPool(2, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		p.run(ctx, time.Second)
+		close(stopped)
+	}()
+
+	var running, finished atomic.Int32
+	var exceeded atomic.Bool
+	release := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		if !p.submit(ctx, func() {
+			if running.Add(1) > 2 {
+				exceeded.Store(true)
+			}
+			<-release
+			running.Add(-1)
+			finished.Add(1)
+		}) {
+			t.Fatal("expected the job to be queued")
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.stats().Busy < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := p.stats(); stats.Busy != 2 || stats.Queued != 4 || stats.Workers != 2 || stats.Capacity != 10 {
+		t.Fatalf("expected 2 busy workers and 4 queued jobs, got %+v", stats)
+	}
+
+	// Shutting down still runs the queued jobs.
+	cancel()
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the pool to drain")
+	}
+	if finished.Load() != 6 || exceeded.Load() {
+		t.Errorf("expected all 6 jobs to run at most 2 at a time, %d ran (more at once: %v)", finished.Load(), exceeded.Load())
+	}
+	if p.submit(ctx, func() {}) {
+		t.Error("expected no jobs to be queued after shutdown")
+	}
+}
+
+func TestPoolDrainTimeout(t *testing.T) {
+	p := newPool(1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	var started atomic.Int32
+	for i := 0; i < 3; i++ {
+		p.submit(ctx, func() {
+			started.Add(1)
+			<-release
+		})
+	}
+	cancel()
+
+	begun := time.Now()
+	p.run(ctx, 50*time.Millisecond)
+	if took := time.Since(begun); took > time.Second {
+		t.Errorf("expected the drain to give up after its timeout, took %s", took)
+	}
+	if !p.trySubmit(func() {}) || p.stats().Queued == 0 {
+		t.Error("expected the jobs left over to stay queued")
+	}
+	if started.Load() > 1 {
+		t.Errorf("expected no more jobs to start after the timeout, %d did", started.Load())
+	}
+}
+
+func TestPoolRunsPrioritizedFirst(t *testing.T) {
+	p := newPool(1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx, time.Second)
+
+	// Keep the only worker busy while the queues fill.
+	started, release := make(chan struct{}), make(chan struct{})
+	p.submit(ctx, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	order := make(chan string, 3)
+	p.submit(ctx, func() { order <- "first regular" })
+	p.submit(ctx, func() { order <- "second regular" })
+	p.submitFirst(ctx, func() { order <- "watchlist" })
+	if stats := p.stats(); stats.Queued != 3 || stats.Prioritized != 1 {
+		t.Fatalf("expected 3 queued analyses, 1 prioritized, got %+v", stats)
+	}
+	close(release)
+
+	for _, expected := range []string{"watchlist", "first regular", "second regular"} {
+		select {
+		case got := <-order:
+			if got != expected {
+				t.Fatalf("expected the %s analysis next, got the %s one", expected, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the queued analyses to run")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+// AIUsage is what the agent spent on AI analyses against its cost limits.
+type AIUsage struct {
+	CostControl        bool    `json:"costControl"`
+	MonthlyCostUSD     float64 `json:"monthlyCostUSD"`
+	MaxCostPerMonth    float64 `json:"maxCostPerMonth,omitempty"`
+	AnalysesThisHour   int     `json:"analysesThisHour"`
+	MaxAnalysisPerHour int     `json:"maxAnalysisPerHour,omitempty"`
+	// Month the quotas' spend is counted in, such as "2024-05"
+	QuotaMonth string       `json:"quotaMonth,omitempty"`
+	Quotas     []QuotaUsage `json:"quotas,omitempty"`
+}
+
+// QuotaUsage is the spend of an AI quota in the current month.
+type QuotaUsage struct {
+	Name            string            `json:"name"`
+	Namespaces      []string          `json:"namespaces,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	MaxCostPerMonth float64           `json:"maxCostPerMonth"`
+	SpentUSD        float64           `json:"spentUSD"`
+	RemainingUSD    float64           `json:"remainingUSD"`
+}
+
+// AIUsage returns the AI spend of the agent, or nil when AI analysis is
+// disabled.
+func (a *Analyzer) AIUsage() *AIUsage {
+	if a.aiAnalyzer == nil || !a.aiAnalyzer.config.Enabled {
+		return nil
+	}
+	usage := a.aiAnalyzer.Usage(time.Now())
+	return &usage
+}
+
+// Usage returns the spend against the cost limits at now.
+func (ai *AIAnalyzer) Usage(now time.Time) AIUsage {
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	ai.resetQuotas(now)
+	usage := AIUsage{
+		CostControl:        ai.config.EnableCostControl,
+		MonthlyCostUSD:     ai.monthlyUsage,
+		MaxCostPerMonth:    ai.config.MaxCostPerMonth,
+		AnalysesThisHour:   ai.hourlyCount,
+		MaxAnalysisPerHour: ai.config.MaxAnalysisPerHour,
+	}
+	if len(ai.config.Quotas) == 0 {
+		return usage
+	}
+	usage.QuotaMonth = ai.quotaMonth
+	for _, quota := range ai.config.Quotas {
+		spent := ai.quotaSpend[quota.Name]
+		remaining := quota.MaxCostPerMonth - spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.Quotas = append(usage.Quotas, QuotaUsage{
+			Name:            quota.Name,
+			Namespaces:      quota.Namespaces,
+			Labels:          quota.Labels,
+			MaxCostPerMonth: quota.MaxCostPerMonth,
+			SpentUSD:        spent,
+			RemainingUSD:    remaining,
+		})
+	}
+	return usage
+}
+
+// exceededQuota describes the first quota selecting the core that is used
+// up, or returns "". ai.mu must be held.
+func (ai *AIAnalyzer) exceededQuota(coredump *collector.CoredumpFile, now time.Time) string {
+	ai.resetQuotas(now)
+	for i := range ai.config.Quotas {
+		quota := &ai.config.Quotas[i]
+		if quotaSelects(quota, coredump) && ai.quotaSpend[quota.Name] >= quota.MaxCostPerMonth {
+			return fmt.Sprintf("monthly cost quota %s of $%.2f", quota.Name, quota.MaxCostPerMonth)
+		}
+	}
+	return ""
+}
+
+// chargeQuotas adds cost to every quota selecting the core. ai.mu must be
+// held.
+func (ai *AIAnalyzer) chargeQuotas(coredump *collector.CoredumpFile, cost float64, now time.Time) {
+	ai.resetQuotas(now)
+	for i := range ai.config.Quotas {
+		if quota := &ai.config.Quotas[i]; quotaSelects(quota, coredump) {
+			ai.quotaSpend[quota.Name] += cost
+		}
+	}
+}
+
+// resetQuotas starts counting the monthly spend and the quotas' spend
+// afresh in a new calendar month (UTC).
+func (ai *AIAnalyzer) resetQuotas(now time.Time) {
+	month := now.UTC().Format("2006-01")
+	if ai.quotaMonth != month || ai.quotaSpend == nil {
+		ai.quotaMonth = month
+		ai.monthlyUsage = 0
+		ai.quotaSpend = make(map[string]float64)
+	}
+}
+
+// aiUsageState is the month's spend as kept in the state file.
+type aiUsageState struct {
+	Month          string             `json:"month"`
+	MonthlyCostUSD float64            `json:"monthlyCostUSD"`
+	Quotas         map[string]float64 `json:"quotas,omitempty"`
+}
+
+// UseAIUsageState keeps the month's AI spend in statePath, so a restart
+// doesn't reset the monthly limit and the quotas. Call it before Start.
+func (a *Analyzer) UseAIUsageState(statePath string) {
+	if a.aiAnalyzer != nil {
+		a.aiAnalyzer.loadUsage(statePath, time.Now())
+	}
+}
+
+// loadUsage restores the spend saved in statePath, unless it was saved in
+// an earlier month.
+func (ai *AIAnalyzer) loadUsage(statePath string, now time.Time) {
+	ai.statePath = statePath
+	if statePath == "" {
+		return
+	}
+
+	var state aiUsageState
+	if err := statefile.Load(statePath, &state); err != nil {
+		klog.Warningf("Starting without AI usage state: %v", err)
+		return
+	}
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	ai.resetQuotas(now)
+	if state.Month != ai.quotaMonth {
+		return
+	}
+	ai.monthlyUsage = state.MonthlyCostUSD
+	for name, spent := range state.Quotas {
+		ai.quotaSpend[name] = spent
+	}
+	klog.Infof("Restored AI spend of $%.2f for %s from %s", ai.monthlyUsage, state.Month, statePath)
+}
+
+// saveUsage writes the month's spend to the state file. ai.mu is only
+// held while it is copied.
+func (ai *AIAnalyzer) saveUsage() {
+	if ai.statePath == "" {
+		return
+	}
+	ai.saveMu.Lock()
+	defer ai.saveMu.Unlock()
+
+	ai.mu.Lock()
+	state := aiUsageState{
+		Month:          ai.quotaMonth,
+		MonthlyCostUSD: ai.monthlyUsage,
+		Quotas:         make(map[string]float64, len(ai.quotaSpend)),
+	}
+	for name, spent := range ai.quotaSpend {
+		state.Quotas[name] = spent
+	}
+	ai.mu.Unlock()
+
+	if err := statefile.Save(ai.statePath, state); err != nil {
+		klog.Warningf("Failed to save AI usage state: %v", err)
+	}
+}
+
+func quotaSelects(quota *config.AIQuota, coredump *collector.CoredumpFile) bool {
+	if len(quota.Namespaces) > 0 {
+		found := false
+		for _, namespace := range quota.Namespaces {
+			found = found || namespace == coredump.PodNamespace
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, value := range quota.Labels {
+		if actual, found := coredump.InstanceLabels[key]; !found || actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// pricedProvider charges a fixed cost for every analysis.
+type pricedProvider struct {
+	FakeProvider
+	cost float64
+}
+
+func (p *pricedProvider) Cost(completion *AICompletion) float64 {
+	return p.cost
+}
+
+func TestQuotasLimitTheirTenantsOnly(t *testing.T) {
+	ai := &AIAnalyzer{
+		config: &config.AIAnalysisConfig{
+			Enabled:            true,
+			EnableCostControl:  true,
+			MaxCostPerMonth:    10,
+			MaxAnalysisPerHour: 100,
+			Quotas: []config.AIQuota{
+				{Name: "search-team", Namespaces: []string{"search", "search-dev"}, MaxCostPerMonth: 0.5},
+				{Name: "ingest-team", Labels: map[string]string{"team": "ingest"}, MaxCostPerMonth: 5},
+			},
+		},
+		provider:      &pricedProvider{cost: 0.3},
+		lastHourReset: time.Now(),
+	}
+
+	search := &collector.CoredumpFile{Path: "/cores/core.1", PodNamespace: "search-dev"}
+	ingest := &collector.CoredumpFile{Path: "/cores/core.2", PodNamespace: "ingest", InstanceLabels: map[string]string{"team": "ingest"}}
+	for i, expected := range []string{"", "", "monthly cost quota search-team of $0.50"} {
+		result, err := ai.AnalyzeCoredump(context.Background(), search, &collector.AnalysisResults{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.CostLimitReached != expected {
+			t.Errorf("analysis %d: expected limit %q, got %q", i, expected, result.CostLimitReached)
+		}
+	}
+	if result, _ := ai.AnalyzeCoredump(context.Background(), ingest, &collector.AnalysisResults{}); result.CostLimitReached != "" {
+		t.Errorf("expected other tenants to be analyzed, got %q", result.CostLimitReached)
+	}
+
+	usage := ai.Usage(time.Now())
+	if usage.MonthlyCostUSD < 0.89 || len(usage.Quotas) != 2 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	if quota := usage.Quotas[0]; quota.SpentUSD < 0.59 || quota.RemainingUSD != 0 {
+		t.Errorf("expected the search quota to be used up, got %+v", quota)
+	}
+	if quota := usage.Quotas[1]; quota.SpentUSD < 0.29 || quota.RemainingUSD < 4.69 {
+		t.Errorf("expected one analysis on the ingest quota, got %+v", quota)
+	}
+
+	// Quotas start afresh every month.
+	if usage := ai.Usage(time.Now().AddDate(0, 1, 0)); usage.Quotas[0].SpentUSD != 0 {
+		t.Errorf("expected the quotas to reset in a new month, got %+v", usage.Quotas[0])
+	}
+}
+
+func TestAIUsageSurvivesRestart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "ai-usage.json")
+	newAI := func() *AIAnalyzer {
+		ai := &AIAnalyzer{
+			config: &config.AIAnalysisConfig{
+				Enabled:            true,
+				EnableCostControl:  true,
+				MaxCostPerMonth:    10,
+				MaxAnalysisPerHour: 100,
+				Quotas:             []config.AIQuota{{Name: "search-team", Namespaces: []string{"search"}, MaxCostPerMonth: 0.5}},
+			},
+			provider:      &pricedProvider{cost: 0.3},
+			lastHourReset: time.Now(),
+		}
+		ai.loadUsage(statePath, time.Now())
+		return ai
+	}
+
+	search := &collector.CoredumpFile{Path: "/cores/core.1", PodNamespace: "search"}
+	ai := newAI()
+	for i := 0; i < 2; i++ {
+		if _, err := ai.AnalyzeCoredump(context.Background(), search, &collector.AnalysisResults{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restarted := newAI()
+	usage := restarted.Usage(time.Now())
+	if usage.MonthlyCostUSD < 0.59 || usage.Quotas[0].SpentUSD < 0.59 {
+		t.Fatalf("expected the month's spend to be restored, got %+v", usage)
+	}
+	result, _ := restarted.AnalyzeCoredump(context.Background(), search, &collector.AnalysisResults{})
+	if result.CostLimitReached != "monthly cost quota search-team of $0.50" {
+		t.Errorf("expected the restored quota to be used up, got %q", result.CostLimitReached)
+	}
+
+	// Spend saved in an earlier month is not restored.
+	stale := &AIAnalyzer{config: restarted.config}
+	stale.loadUsage(statePath, time.Now().AddDate(0, 1, 0))
+	if usage := stale.Usage(time.Now().AddDate(0, 1, 0)); usage.MonthlyCostUSD != 0 || usage.Quotas[0].SpentUSD != 0 {
+		t.Errorf("expected last month's spend to be dropped, got %+v", usage)
+	}
+}
+
+func TestCostLimitsApplyToWatchlistCores(t *testing.T) {
+	ai := &AIAnalyzer{
+		config: &config.AIAnalysisConfig{
+			Enabled:            true,
+			EnableCostControl:  true,
+			MaxCostPerMonth:    0.5,
+			MaxAnalysisPerHour: 100,
+		},
+		provider:      &pricedProvider{cost: 0.3},
+		lastHourReset: time.Now(),
+	}
+
+	hunted := &collector.CoredumpFile{Path: "/cores/core.1", Watchlist: []string{"hnsw-null-segment"}}
+	for i, expected := range []string{"", "", "monthly cost limit of $0.50"} {
+		result, err := ai.AnalyzeCoredump(context.Background(), hunted, &collector.AnalysisResults{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.CostLimitReached != expected {
+			t.Errorf("analysis %d: expected limit %q, got %q", i, expected, result.CostLimitReached)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package analyzer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+const (
+	defaultRetryAttempts       = 3
+	defaultRetryInitialBackoff = time.Minute
+	defaultRetryMaxBackoff     = 30 * time.Minute
+
+	retryPollInterval = 10 * time.Second
+)
+
+// retryQueue holds the cores whose analysis failed until they are due to be
+// analyzed again. The queue is kept in statePath, when set, so pending
+// retries survive agent restarts. A nil retryQueue retries nothing.
+type retryQueue struct {
+	config    *config.RetryConfig
+	statePath string
+
+	mu      sync.Mutex
+	pending map[string]*collector.CoredumpFile
+}
+
+func newRetryQueue(cfg *config.RetryConfig, statePath string) *retryQueue {
+	if !cfg.Enabled {
+		return nil
+	}
+	q := &retryQueue{
+		config:    cfg,
+		statePath: statePath,
+		pending:   make(map[string]*collector.CoredumpFile),
+	}
+	q.load()
+	return q
+}
+
+// next returns when the core, whose analysis just failed, is to be
+// retried, or false when it has no attempts left.
+func (q *retryQueue) next(coredump *collector.CoredumpFile, now time.Time) (time.Time, bool) {
+	if q == nil {
+		return time.Time{}, false
+	}
+	maxAttempts := q.config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryAttempts
+	}
+	if coredump.AnalysisAttempts >= maxAttempts {
+		return time.Time{}, false
+	}
+
+	backoff := q.config.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryInitialBackoff
+	}
+	maxBackoff := q.config.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	for i := 1; i < coredump.AnalysisAttempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return now.Add(backoff), true
+}
+
+func (q *retryQueue) add(coredump *collector.CoredumpFile) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[coredump.ID] = coredump
+	q.save()
+}
+
+// remove drops the core from the queue, reporting whether it was queued.
+func (q *retryQueue) remove(id string) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, exists := q.pending[id]; !exists {
+		return false
+	}
+	delete(q.pending, id)
+	q.save()
+	return true
+}
+
+// due takes the cores due for a retry at now off the queue, earliest first.
+func (q *retryQueue) due(now time.Time) []*collector.CoredumpFile {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []*collector.CoredumpFile
+	for id, coredump := range q.pending {
+		if coredump.NextRetryAt == nil || !coredump.NextRetryAt.After(now) {
+			due = append(due, coredump)
+			delete(q.pending, id)
+		}
+	}
+	if len(due) > 0 {
+		q.save()
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(&due[j].CreatedAt)
+	})
+	return due
+}
+
+func (q *retryQueue) load() {
+	if q.statePath == "" {
+		return
+	}
+	var pending []*collector.CoredumpFile
+	if err := statefile.Load(q.statePath, &pending); err != nil {
+		klog.Warningf("Starting without pending analysis retries: %v", err)
+		return
+	}
+	for _, coredump := range pending {
+		if coredump == nil || coredump.ID == "" {
+			continue
+		}
+		// The collector empties the staging directory when it starts, so
+		// the core is read again from where it was found.
+		if coredump.OriginalPath != "" {
+			coredump.Path = coredump.OriginalPath
+			coredump.OriginalPath = ""
+		}
+		q.pending[coredump.ID] = coredump
+	}
+	klog.Infof("Restored %d pending analysis retries from %s", len(q.pending), q.statePath)
+}
+
+// save must be called with q.mu held.
+func (q *retryQueue) save() {
+	if q.statePath == "" {
+		return
+	}
+	pending := make([]*collector.CoredumpFile, 0, len(q.pending))
+	for _, coredump := range q.pending {
+		pending = append(pending, coredump)
+	}
+	if err := statefile.Save(q.statePath, pending); err != nil {
+		klog.Warningf("Failed to save pending analysis retries: %v", err)
+	}
+}
+
+// processRetries analyzes the queued cores again as they fall due.
+func (a *Analyzer) processRetries(ctx context.Context) {
+	ticker := time.NewTicker(retryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, coredump := range a.retries.due(now) {
+				coredump := coredump
+				if !a.pool.submit(ctx, func() { a.reanalyze(coredump, "retrying analysis") }) {
+					// Shutting down; keep it for the next start.
+					a.retries.add(coredump)
+				}
+			}
+		}
+	}
+}
+
+// Requeue queues a core whose analysis failed for another analysis ahead
+// of its retries, whether or not it has any left. The analysis works on a
+// copy, leaving coredump as it was when requeued. It fails with
+// collector.ErrStateConflict when the core's analysis hasn't failed, and
+// without changing the core when the analysis queue is full.
+func (a *Analyzer) Requeue(coredump *collector.CoredumpFile) error {
+	if stats := a.pool.stats(); stats.Queued-stats.Prioritized == stats.Capacity {
+		return errQueueFull
+	}
+	errorMessage, nextRetryAt := coredump.ErrorMessage, coredump.NextRetryAt
+	if err := a.states.Transition(coredump, collector.StatusError, collector.StatusProcessing, "requeued"); err != nil {
+		return err
+	}
+	coredump.NextRetryAt = nil
+
+	requeued := *coredump
+	if !a.pool.trySubmit(func() { a.analyze(&requeued) }) {
+		// Filled up in the meantime.
+		a.states.Transition(coredump, collector.StatusProcessing, collector.StatusError, errorMessage)
+		coredump.NextRetryAt = nextRetryAt
+		return errQueueFull
+	}
+	if a.retries.remove(coredump.ID) {
+		klog.Infof("Coredump %s requeued ahead of its scheduled retry", coredump.ID)
+	}
+	return nil
+}
+
+func (a *Analyzer) reanalyze(coredump *collector.CoredumpFile, reason string) {
+	if err := a.states.Transition(coredump, collector.StatusError, collector.StatusProcessing, reason); err != nil {
+		return
+	}
+	coredump.NextRetryAt = nil
+	a.analyze(coredump)
+}
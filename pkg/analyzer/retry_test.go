@@ -0,0 +1,145 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	q := newRetryQueue(&config.RetryConfig{
+		Enabled:        true,
+		MaxAttempts:    5,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     5 * time.Minute,
+	}, "")
+	now := time.Now()
+
+	for attempts, expected := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 5 * time.Minute,
+	} {
+		retryAt, ok := q.next(&collector.CoredumpFile{AnalysisAttempts: attempts}, now)
+		if !ok || retryAt.Sub(now) != expected {
+			t.Errorf("after %d attempts: expected a retry in %s, got %s (%v)", attempts, expected, retryAt.Sub(now), ok)
+		}
+	}
+	if _, ok := q.next(&collector.CoredumpFile{AnalysisAttempts: 5}, now); ok {
+		t.Error("expected no retry after the last attempt")
+	}
+
+	var disabled *retryQueue
+	if _, ok := disabled.next(&collector.CoredumpFile{AnalysisAttempts: 1}, now); ok {
+		t.Error("expected no retries when disabled")
+	}
+}
+
+func TestRetryQueuePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis-retries.json")
+	cfg := &config.RetryConfig{Enabled: true}
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	q := newRetryQueue(cfg, path)
+	q.add(&collector.CoredumpFile{ID: "late", Status: collector.StatusError, NextRetryAt: &later})
+	for i, id := range []string{"second", "first"} {
+		q.add(&collector.CoredumpFile{ID: id, Status: collector.StatusError, NextRetryAt: &now,
+			CreatedAt: metav1.NewTime(now.Add(-time.Duration(i) * time.Minute))})
+	}
+
+	// A restarted agent picks up where the last one left off.
+	restored := newRetryQueue(cfg, path)
+	due := restored.due(now)
+	if len(due) != 2 || due[0].ID != "first" || due[1].ID != "second" {
+		t.Fatalf("expected the two due cores oldest first, got %+v", due)
+	}
+	if due[0].Status != collector.StatusError || due[0].NextRetryAt == nil {
+		t.Errorf("expected the core's state to be restored, got %+v", due[0])
+	}
+
+	if !newRetryQueue(cfg, path).remove("late") {
+		t.Error("expected the core not yet due to stay queued")
+	}
+	if due := newRetryQueue(cfg, path).due(later); len(due) != 0 {
+		t.Errorf("expected the queue to be empty, got %+v", due)
+	}
+}
+
+func TestRetryAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "analysis-retries.json")
+	stagingPath := filepath.Join(dir, "staging")
+	original := filepath.Join(dir, "core.milvus.4242.1000.11")
+	staged := filepath.Join(stagingPath, "abc-core.milvus.4242.1000.11")
+	os.MkdirAll(stagingPath, 0755)
+	if err := os.WriteFile(original, []byte("ELF core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(original, staged); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	cfg := &config.RetryConfig{Enabled: true}
+	newRetryQueue(cfg, statePath).add(&collector.CoredumpFile{ID: "abc", Path: staged, OriginalPath: original,
+		Status: collector.StatusError, AnalysisAttempts: 1, NextRetryAt: &now})
+
+	// The restarted agent's collector empties the staging directory.
+	restored := newRetryQueue(cfg, statePath)
+	if err := os.RemoveAll(stagingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	due := restored.due(now)
+	if len(due) != 1 || due[0].Path != original {
+		t.Fatalf("expected the retry to read the core where it was found, got %+v", due)
+	}
+	if data, err := os.ReadFile(due[0].Path); err != nil || string(data) != "ELF core" {
+		t.Fatalf("expected the core to be readable, got %q, %v", data, err)
+	}
+
+	a := New(&config.AnalyzerConfig{}, nil, nil, nil, nil, nil, nil, nil, "")
+	a.reanalyze(due[0], "retrying analysis")
+	if due[0].Status != collector.StatusAnalyzed || due[0].AnalysisAttempts != 2 {
+		t.Errorf("expected the retry to be analyzed, got %s after %d attempts", due[0].Status, due[0].AnalysisAttempts)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	a := New(&config.AnalyzerConfig{}, nil, nil, nil, nil, nil, nil, nil, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Start(ctx, make(chan collector.CollectionEvent))
+
+	if err := a.Requeue(&collector.CoredumpFile{ID: "abc", Status: collector.StatusStored}); err == nil {
+		t.Error("expected a stored core not to be requeued")
+	}
+
+	retryAt := time.Now().Add(time.Hour)
+	coredump := &collector.CoredumpFile{ID: "abc", Status: collector.StatusError, ErrorMessage: "gdb analysis failed",
+		AnalysisAttempts: 3, NextRetryAt: &retryAt}
+	if err := a.Requeue(coredump); err != nil {
+		t.Fatal(err)
+	}
+	if coredump.Status != collector.StatusProcessing || coredump.ErrorMessage != "" || coredump.NextRetryAt != nil {
+		t.Errorf("expected the core to be processing again, got %+v", coredump)
+	}
+
+	select {
+	case event := <-a.GetEventChannel():
+		if event.Type != EventTypeAnalysisComplete || event.CoredumpFile.AnalysisAttempts != 4 {
+			t.Errorf("expected the fourth attempt to complete, got %s after %d attempts", event.Type, event.CoredumpFile.AnalysisAttempts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the requeued core to be analyzed")
+	}
+}
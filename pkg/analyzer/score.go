@@ -0,0 +1,172 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+// Rule-based scoring dimensions, as named in the score breakdown.
+const (
+	ScoreCrashReason    = "crashReason"
+	ScorePanicKeyword   = "panicKeyword"
+	ScoreStackTrace     = "stackTrace"
+	ScoreThreads        = "threads"
+	ScorePodAssociation = "podAssociation"
+	ScoreSignal         = "signal"
+	ScoreFileSize       = "fileSize"
+	ScoreFreshness      = "freshness"
+	ScoreComponent      = "component"
+
+	maxScore = 10.0
+)
+
+// coordinatorComponents are the Milvus components an instance can't serve
+// without.
+var coordinatorComponents = map[string]bool{
+	"mixcoord":   true,
+	"rootcoord":  true,
+	"datacoord":  true,
+	"querycoord": true,
+	"indexcoord": true,
+	"standalone": true,
+}
+
+// ScoreInput is what the value score of a core is computed from.
+type ScoreInput struct {
+	CrashReason      string
+	StackTraceLength int
+	ThreadCount      int
+	PodName          string
+	InstanceName     string
+	Component        string
+	Signal           int
+	Size             int64
+	// Time since the core was written
+	Age time.Duration
+}
+
+// ScoreWeights are the base score and the points each dimension adds when
+// its rule holds.
+type ScoreWeights struct {
+	Base           float64 `json:"base"`
+	CrashReason    float64 `json:"crashReason"`
+	PanicKeyword   float64 `json:"panicKeyword"`
+	StackTrace     float64 `json:"stackTrace"`
+	Threads        float64 `json:"threads"`
+	PodAssociation float64 `json:"podAssociation"`
+	Signal         float64 `json:"signal"`
+	FileSize       float64 `json:"fileSize"`
+	Freshness      float64 `json:"freshness"`
+	Component      float64 `json:"component"`
+}
+
+// DefaultScoreWeights are the weights the analyzer scores cores with.
+var DefaultScoreWeights = ScoreWeights{
+	Base:           4.0, // updated from 5.0 to align with documentation
+	CrashReason:    2.0,
+	PanicKeyword:   1.0,
+	StackTrace:     1.5,
+	Threads:        0.5,
+	PodAssociation: 1.0,
+	Signal:         1.0,
+	FileSize:       0.5,
+	Freshness:      0.5,
+	Component:      0.5,
+}
+
+// Score computes the value score of a core, capped at 10, and what each
+// dimension contributed. AI analysis does not affect the score.
+func Score(input ScoreInput, panicKeywords []string, weights ScoreWeights) *collector.ScoreBreakdown {
+	breakdown := &collector.ScoreBreakdown{Base: weights.Base}
+	add := func(name string, holds bool, max float64, detail string, args ...interface{}) {
+		points := 0.0
+		if holds {
+			points = max
+		}
+		breakdown.Dimensions = append(breakdown.Dimensions, collector.ScoreDimension{
+			Name:   name,
+			Points: points,
+			Max:    max,
+			Detail: fmt.Sprintf(detail, append([]interface{}{points}, args...)...),
+		})
+	}
+
+	// 1. Crash reason clarity, with a bonus for panic keywords
+	if input.CrashReason != "" {
+		add(ScoreCrashReason, true, weights.CrashReason, "崩溃原因明确: +%.1f (%s)", input.CrashReason)
+		for _, keyword := range panicKeywords {
+			if strings.Contains(strings.ToLower(input.CrashReason), strings.ToLower(keyword)) {
+				add(ScorePanicKeyword, true, weights.PanicKeyword, "包含关键词 '%[2]s': +%.1[1]f", keyword)
+				break
+			}
+		}
+	} else {
+		add(ScoreCrashReason, false, weights.CrashReason, "崩溃原因不明确: +%.1f")
+	}
+
+	// 2. Stack trace quality
+	if input.StackTraceLength > 100 {
+		add(ScoreStackTrace, true, weights.StackTrace, "堆栈跟踪质量高: +%.1f (%d字符)", input.StackTraceLength)
+	} else {
+		add(ScoreStackTrace, false, weights.StackTrace, "堆栈跟踪质量低: +%.1f (%d字符)", input.StackTraceLength)
+	}
+
+	// 3. Multi-thread complexity
+	if input.ThreadCount > 1 {
+		add(ScoreThreads, true, weights.Threads, "多线程复杂性: +%.1f (%d线程)", input.ThreadCount)
+	} else {
+		add(ScoreThreads, false, weights.Threads, "单线程: +%.1f (%d线程)", input.ThreadCount)
+	}
+
+	// 4. Pod association
+	if input.PodName != "" && input.InstanceName != "" {
+		add(ScorePodAssociation, true, weights.PodAssociation, "Pod关联: +%.1f (%s/%s)", input.PodName, input.InstanceName)
+	} else {
+		add(ScorePodAssociation, false, weights.PodAssociation, "无Pod关联: +%.1f")
+	}
+
+	// 5. Signal severity: SIGSEGV, SIGABRT and SIGFPE
+	if input.Signal == 11 || input.Signal == 6 || input.Signal == 8 {
+		add(ScoreSignal, true, weights.Signal, "严重信号: +%.1f (信号%d)", input.Signal)
+	} else {
+		add(ScoreSignal, false, weights.Signal, "普通信号: +%.1f (信号%d)", input.Signal)
+	}
+
+	// 6. File size - larger files contain more information
+	sizeMB := float64(input.Size) / 1024 / 1024
+	if input.Size > 100*1024*1024 {
+		add(ScoreFileSize, true, weights.FileSize, "大文件: +%.1f (%.1fMB)", sizeMB)
+	} else {
+		add(ScoreFileSize, false, weights.FileSize, "小文件: +%.1f (%.1fMB)", sizeMB)
+	}
+
+	// 7. Freshness - recent crashes are more valuable
+	if input.Age < time.Hour {
+		add(ScoreFreshness, true, weights.Freshness, "新鲜度高: +%.1f (%s前)", input.Age.Round(time.Minute))
+	} else {
+		add(ScoreFreshness, false, weights.Freshness, "文件较旧: +%.1f (%s前)", input.Age.Round(time.Minute))
+	}
+
+	// 8. Component - a coordinator or standalone crash stalls the whole
+	// instance, a worker or proxy crash only its share of it
+	if coordinatorComponents[input.Component] {
+		add(ScoreComponent, true, weights.Component, "核心组件: +%.1f (%s)", input.Component)
+	} else if input.Component != "" {
+		add(ScoreComponent, false, weights.Component, "普通组件: +%.1f (%s)", input.Component)
+	} else {
+		add(ScoreComponent, false, weights.Component, "组件未知: +%.1f")
+	}
+
+	breakdown.Total = breakdown.Base
+	for _, dimension := range breakdown.Dimensions {
+		breakdown.Total += dimension.Points
+	}
+	if breakdown.Total > maxScore {
+		breakdown.Total = maxScore
+		breakdown.Capped = true
+	}
+	return breakdown
+}
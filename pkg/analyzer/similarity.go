@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/similarity"
+)
+
+// UseSimilarity has analyses look up the past crashes most similar to each
+// core in index, and add the core to it once analyzed. Call it before
+// Start.
+func (a *Analyzer) UseSimilarity(index *similarity.Index) {
+	a.similarity = index
+}
+
+// findSimilarCrashes adds the past crashes most like the core to results,
+// for the AI analysis to build on, and returns the core's embedding.
+// Failures only cost the core its similar crashes.
+func (a *Analyzer) findSimilarCrashes(ctx context.Context, coredump *collector.CoredumpFile, results *collector.AnalysisResults) []float32 {
+	if a.similarity == nil || coredump.SelfTest {
+		return nil
+	}
+	vector, err := a.similarity.Embed(ctx, coredump)
+	if err != nil {
+		klog.Warningf("Failed to embed the stack of %s: %v", coredump.Path, err)
+		return nil
+	}
+	similar, err := a.similarity.Search(ctx, vector, coredump.ID, 0)
+	if err != nil {
+		klog.Warningf("Failed to find crashes similar to %s: %v", coredump.Path, err)
+		return vector
+	}
+	if len(similar) > 0 {
+		klog.Infof("Coredump %s resembles %d past crashes, closest %s (%.2f)",
+			coredump.Path, len(similar), similar[0].CoredumpID, similar[0].Score)
+	}
+	results.SimilarCrashes = similar
+	return vector
+}
+
+// indexCrash adds the analyzed core, with what its AI analysis found, to
+// the crashes later ones are compared with.
+func (a *Analyzer) indexCrash(ctx context.Context, coredump *collector.CoredumpFile, vector []float32) {
+	if err := a.similarity.Add(ctx, coredump, vector); err != nil {
+		klog.Warningf("Failed to add %s to the similarity index: %v", coredump.Path, err)
+	}
+}
@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const defaultMaxSymbolCacheSize = 5 * 1024 * 1024 * 1024
+
+// gdbSymbolOptions returns the environment and leading gdb arguments that
+// point gdb at the configured symbol sources. The -iex commands run before
+// the script, so a gdb built without debuginfod only warns about them.
+func (a *Analyzer) gdbSymbolOptions() ([]string, []string) {
+	symbols := a.config().Symbols
+	if !symbols.Enabled {
+		return nil, nil
+	}
+
+	var env, args []string
+	if len(symbols.DebuginfodURLs) > 0 {
+		env = append(env, "DEBUGINFOD_URLS="+strings.Join(symbols.DebuginfodURLs, " "))
+		// Batch mode would otherwise leave debuginfod at "ask", which
+		// gdb treats as off.
+		args = append(args, "-iex", "set debuginfod enabled on")
+	}
+	if symbols.CacheDir != "" {
+		env = append(env, "DEBUGINFOD_CACHE_PATH="+symbols.CacheDir)
+	}
+	if len(symbols.DebugFileDirectories) > 0 {
+		dirs := append(append([]string{}, symbols.DebugFileDirectories...), "/usr/lib/debug")
+		args = append(args, "-iex", "set debug-file-directory "+strings.Join(dirs, ":"))
+	}
+	return env, args
+}
+
+// pruneSymbolCache keeps the debuginfod cache below Symbols.MaxCacheSize.
+// Concurrent analyses share the cache, so only one of them prunes at a time.
+func (a *Analyzer) pruneSymbolCache() {
+	symbols := a.config().Symbols
+	if !symbols.Enabled || symbols.CacheDir == "" {
+		return
+	}
+	if !a.symbolCacheMu.TryLock() {
+		return
+	}
+	defer a.symbolCacheMu.Unlock()
+
+	maxSize := config.ParseSize(symbols.MaxCacheSize, defaultMaxSymbolCacheSize)
+	removed, err := pruneCache(symbols.CacheDir, maxSize)
+	if err != nil {
+		klog.Warningf("Failed to prune symbol cache %s: %v", symbols.CacheDir, err)
+		return
+	}
+	if removed > 0 {
+		klog.Infof("Removed %d files from symbol cache %s", removed, symbols.CacheDir)
+	}
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pruneCache removes the least recently written files below root until the
+// files total at most maxSize. Files directly in root are debuginfod's own
+// bookkeeping and are kept.
+func pruneCache(root string, maxSize int64) (int, error) {
+	var files []cachedFile
+	var total int64
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() || filepath.Dir(path) == root {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || total <= maxSize {
+		return 0, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	removed := 0
+	for _, file := range files {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			klog.Warningf("Failed to remove cached symbol file %s: %v", file.path, err)
+			continue
+		}
+		total -= file.size
+		removed++
+		// debuginfod keeps one directory per build ID; drop it once empty.
+		os.Remove(filepath.Dir(file.path))
+	}
+	return removed, nil
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestGdbSymbolOptions(t *testing.T) {
+	a := newTestAnalyzer(&config.AnalyzerConfig{Symbols: config.SymbolsConfig{
+		Enabled:              true,
+		DebuginfodURLs:       []string{"https://debuginfod.internal", "https://debuginfod.ubuntu.com"},
+		DebugFileDirectories: []string{"/symbols"},
+		CacheDir:             "/var/cache/debuginfod",
+	}})
+
+	env, args := a.gdbSymbolOptions()
+	expectedEnv := []string{
+		"DEBUGINFOD_URLS=https://debuginfod.internal https://debuginfod.ubuntu.com",
+		"DEBUGINFOD_CACHE_PATH=/var/cache/debuginfod",
+	}
+	if !reflect.DeepEqual(env, expectedEnv) {
+		t.Errorf("unexpected env %q", env)
+	}
+	expectedArgs := []string{"-iex", "set debuginfod enabled on", "-iex", "set debug-file-directory /symbols:/usr/lib/debug"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("unexpected args %q", args)
+	}
+
+	a.config().Symbols.Enabled = false
+	if env, args := a.gdbSymbolOptions(); env != nil || args != nil {
+		t.Errorf("expected no options when disabled, got %q %q", env, args)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldest := write("aaaa/debuginfo", 400, 3*time.Hour)
+	older := write("bbbb/debuginfo", 400, 2*time.Hour)
+	newest := write("cccc/debuginfo", 400, time.Hour)
+	bookkeeping := write("cache_clean_interval_s", 10, 4*time.Hour)
+
+	removed, err := pruneCache(root, 900)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 file removed, got %d", removed)
+	}
+	for path, kept := range map[string]bool{oldest: false, older: true, newest: true, bookkeeping: true} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Errorf("%s: expected kept=%v, stat error %v", path, kept, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(oldest)); !os.IsNotExist(err) {
+		t.Errorf("expected the emptied build ID directory to be removed")
+	}
+
+	if removed, err := pruneCache(filepath.Join(root, "missing"), 0); err != nil || removed != 0 {
+		t.Errorf("expected a missing cache to be a no-op, got %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"milvus-coredump-agent/pkg/analyzer"
+)
+
+// AIUsageSource provides the AI spend against the cost limits and quotas.
+type AIUsageSource interface {
+	// AIUsage returns nil when AI analysis is disabled.
+	AIUsage() *analyzer.AIUsage
+}
+
+// HandleAIUsage enables the AI spend statistics of source.
+func (s *Server) HandleAIUsage(source AIUsageSource) {
+	s.aiUsage = source
+}
+
+// GET /api/v1/stats/ai
+//
+// Reports what this agent spent on AI analyses this month and hour against
+// its limits, and the spend and remaining budget of each quota.
+func (s *Server) handleAIUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	var usage *analyzer.AIUsage
+	if s.aiUsage != nil {
+		usage = s.aiUsage.AIUsage()
+	}
+	if usage == nil {
+		writeProblem(w, r, CodeUnavailable, "AI analysis is not enabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"milvus-coredump-agent/pkg/analyzer"
+)
+
+type staticAIUsage struct {
+	usage *analyzer.AIUsage
+}
+
+func (s staticAIUsage) AIUsage() *analyzer.AIUsage {
+	return s.usage
+}
+
+func TestAIUsage(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+	server.HandleAIUsage(staticAIUsage{&analyzer.AIUsage{
+		CostControl:    true,
+		MonthlyCostUSD: 1.5,
+		Quotas:         []analyzer.QuotaUsage{{Name: "search-team", MaxCostPerMonth: 2, SpentUSD: 1.5, RemainingUSD: 0.5}},
+	}})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/ai", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var usage analyzer.AIUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if usage.MonthlyCostUSD != 1.5 || len(usage.Quotas) != 1 || usage.Quotas[0].RemainingUSD != 0.5 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	server.HandleAIUsage(staticAIUsage{})
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/ai", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without AI analysis, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/alertrules"
+)
+
+// maxAlertRequestSize bounds the body of a rule or silence.
+const maxAlertRequestSize = 64 << 10
+
+// AlertSilenceRequest is the body of adding a silence. It starts at
+// startsAt, or now, and lasts for duration, a Go duration up to 30 days.
+type AlertSilenceRequest struct {
+	Rule      string     `json:"rule,omitempty"`
+	Kind      string     `json:"kind,omitempty"`
+	Namespace string     `json:"namespace,omitempty"`
+	Instance  string     `json:"instance,omitempty"`
+	Author    string     `json:"author"`
+	Comment   string     `json:"comment,omitempty"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	Duration  string     `json:"duration"`
+}
+
+// HandleAlertRules enables the management of the alert rules and silences
+// of engine.
+func (s *Server) HandleAlertRules(engine *alertrules.Engine) {
+	s.alertRules = engine
+}
+
+// GET|POST /api/v1/alert-rules
+// GET|PUT|DELETE /api/v1/alert-rules/<name>
+//
+// Lists, adds, replaces and removes the alert rules, in the order they are
+// evaluated. Rules of the configuration are listed with source "config"
+// and can only be changed there.
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	if s.alertRules == nil {
+		writeProblem(w, r, CodeUnavailable, "alert rules are not enabled")
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/alert-rules"), "/")
+	if name == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"items": s.alertRules.Rules()})
+		case http.MethodPost:
+			var rule alertrules.Rule
+			if !readAlertRequest(w, r, &rule) {
+				return
+			}
+			added, err := s.alertRules.AddRule(rule)
+			if err != nil {
+				writeAlertRuleError(w, r, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, added)
+		default:
+			writeMethodNotAllowed(w, r, "GET, POST")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := s.alertRules.Rule(name)
+		if err != nil {
+			writeAlertRuleError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	case http.MethodPut:
+		var rule alertrules.Rule
+		if !readAlertRequest(w, r, &rule) {
+			return
+		}
+		if rule.Name != "" && rule.Name != name {
+			writeProblem(w, r, CodeInvalidBody, "a rule cannot be renamed")
+			return
+		}
+		updated, err := s.alertRules.UpdateRule(name, rule)
+		if err != nil {
+			writeAlertRuleError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		rule, err := s.alertRules.Rule(name)
+		if err == nil {
+			err = s.alertRules.DeleteRule(name)
+		}
+		if err != nil {
+			writeAlertRuleError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	default:
+		writeMethodNotAllowed(w, r, "GET, PUT, DELETE")
+	}
+}
+
+// GET|POST /api/v1/alert-silences
+// GET|DELETE /api/v1/alert-silences/<id>
+//
+// Lists, adds and expires the silences that mute the alerts they match.
+// Expired silences stay listed for a day; deleting a silence expires it.
+func (s *Server) handleAlertSilences(w http.ResponseWriter, r *http.Request) {
+	if s.alertRules == nil {
+		writeProblem(w, r, CodeUnavailable, "alert rules are not enabled")
+		return
+	}
+
+	idText := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/alert-silences"), "/")
+	if idText == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"items": s.alertRules.Silences(time.Now())})
+		case http.MethodPost:
+			var request AlertSilenceRequest
+			if !readAlertRequest(w, r, &request) {
+				return
+			}
+			duration, err := time.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				writeProblem(w, r, CodeInvalidBody, "duration must be a positive Go duration such as 2h")
+				return
+			}
+			now := time.Now()
+			startsAt := now
+			if request.StartsAt != nil {
+				startsAt = *request.StartsAt
+			}
+			silence, err := s.alertRules.AddSilence(alertrules.Silence{
+				Rule:      request.Rule,
+				Kind:      request.Kind,
+				Namespace: request.Namespace,
+				Instance:  request.Instance,
+				Author:    request.Author,
+				Comment:   request.Comment,
+				StartsAt:  startsAt,
+				EndsAt:    startsAt.Add(duration),
+			}, now)
+			if err != nil {
+				writeAlertRuleError(w, r, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, silence)
+		default:
+			writeMethodNotAllowed(w, r, "GET, POST")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		writeProblem(w, r, CodeNotFound, "")
+		return
+	}
+	var silence alertrules.Silence
+	switch r.Method {
+	case http.MethodGet:
+		silence, err = s.alertRules.Silence(id)
+	case http.MethodDelete:
+		silence, err = s.alertRules.ExpireSilence(id, time.Now())
+	default:
+		writeMethodNotAllowed(w, r, "GET, DELETE")
+		return
+	}
+	if err != nil {
+		writeAlertRuleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, silence)
+}
+
+func readAlertRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAlertRequestSize+1))
+	if err != nil {
+		writeProblem(w, r, CodeInvalidBody, "failed to read the request body")
+		return false
+	}
+	if len(body) > maxAlertRequestSize {
+		writeProblem(w, r, CodeInvalidBody, fmt.Sprintf("the request body must not exceed %d bytes", maxAlertRequestSize))
+		return false
+	}
+	if err := decodeStrict(body, v); err != nil {
+		writeProblem(w, r, CodeInvalidBody, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeAlertRuleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, alertrules.ErrInvalid):
+		writeProblem(w, r, CodeInvalidBody, err.Error())
+	case errors.Is(err, alertrules.ErrConflict), errors.Is(err, alertrules.ErrTooMany):
+		writeProblem(w, r, CodeConflict, err.Error())
+	case errors.Is(err, alertrules.ErrNotFound):
+		writeProblem(w, r, CodeNotFound, err.Error())
+	default:
+		writeProblem(w, r, CodeUnavailable, err.Error())
+	}
+}
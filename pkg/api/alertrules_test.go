@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/alertrules"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestAlertRulesAndSilences(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := send(http.MethodGet, "/api/v1/alert-rules", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without alert rules, got %d", rec.Code)
+	}
+	engine, err := alertrules.New([]config.AlertRuleConfig{{Name: "cleanups", Kinds: []string{"cleanup"}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HandleAlertRules(engine)
+
+	rule := `{"name": "prod", "kinds": ["crash"], "instances": ["prod-*"], "dedupWindow": "30m",
+		"escalation": [{"occurrences": 5, "severity": "critical"}]}`
+	if rec := send(http.MethodPost, "/api/v1/alert-rules", rule); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPost, "/api/v1/alert-rules", rule); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken name, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/api/v1/alert-rules", `{"name": "bad", "severity": "page"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown severity, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, "/api/v1/alert-rules/prod", `{"kinds": ["crash"], "severity": "info"}`); rec.Code != http.StatusOK {
+		t.Errorf("expected the rule to be replaced, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodDelete, "/api/v1/alert-rules/cleanups", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a rule of the configuration, got %d", rec.Code)
+	}
+
+	var rules struct {
+		Items []alertrules.Rule `json:"items"`
+	}
+	rec := send(http.MethodGet, "/api/v1/alert-rules", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &rules); err != nil || len(rules.Items) != 2 || rules.Items[1].Severity != "info" || rules.Items[1].DedupWindow != "" {
+		t.Errorf("expected the configured rule and the replaced one, got %s", rec.Body.String())
+	}
+
+	if rec := send(http.MethodPost, "/api/v1/alert-silences", `{"instance": "prod-*", "author": "alice", "duration": "forever"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid duration, got %d", rec.Code)
+	}
+	rec = send(http.MethodPost, "/api/v1/alert-silences", `{"rule": "prod", "author": "alice", "comment": "upgrade", "duration": "2h"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var silence alertrules.Silence
+	if err := json.Unmarshal(rec.Body.Bytes(), &silence); err != nil || silence.EndsAt.Sub(silence.StartsAt).Hours() != 2 {
+		t.Fatalf("expected a two hour silence, got %s", rec.Body.String())
+	}
+	path := "/api/v1/alert-silences/" + strconv.Itoa(silence.ID)
+	if rec := send(http.MethodDelete, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the silence to expire, got %d", rec.Code)
+	}
+	rec = send(http.MethodGet, path, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &silence); err != nil || silence.EndsAt.Sub(silence.StartsAt).Hours() >= 2 {
+		t.Errorf("expected the silence to have ended, got %s", rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/api/v1/alert-silences/99", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown silence, got %d", rec.Code)
+	}
+
+	if rec := send(http.MethodDelete, "/api/v1/alert-rules/prod", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the rule to be removed, got %d", rec.Code)
+	}
+	if rec := send(http.MethodGet, "/api/v1/alert-rules/prod", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the removed rule, got %d", rec.Code)
+	}
+}
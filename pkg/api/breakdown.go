@@ -0,0 +1,170 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+const (
+	defaultBreakdownWindow = 24 * time.Hour
+	maxBreakdownWindow     = 90 * 24 * time.Hour
+	unknownBucket          = "unknown"
+)
+
+var signalNames = map[int]string{
+	4:  "SIGILL",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	15: "SIGTERM",
+}
+
+type BucketCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type Breakdown struct {
+	Window          string        `json:"window"`
+	Since           time.Time     `json:"since"`
+	Until           time.Time     `json:"until"`
+	Total           int           `json:"total"`
+	BySignal        []BucketCount `json:"bySignal"`
+	ByExecutable    []BucketCount `json:"byExecutable"`
+	ByComponent     []BucketCount `json:"byComponent"`
+	ByNamespace     []BucketCount `json:"byNamespace"`
+	ByMilvusVersion []BucketCount `json:"byMilvusVersion"`
+	ByContainerType []BucketCount `json:"byContainerType"`
+}
+
+// GET /api/v1/stats/breakdown?window=24h
+func (s *Server) handleBreakdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	window := defaultBreakdownWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "window", err.Error())
+			return
+		}
+		window = parsed
+	}
+
+	breakdown := statscache.Get(s.store.stats, "breakdown/"+window.String(), func() *Breakdown {
+		until := time.Now()
+		return computeBreakdown(s.store.Records(), until.Add(-window), until, window)
+	})
+	writeJSON(w, http.StatusOK, breakdown)
+}
+
+func computeBreakdown(records []*collector.CoredumpFile, since, until time.Time, window time.Duration) *Breakdown {
+	bySignal := map[string]int{}
+	byExecutable := map[string]int{}
+	byComponent := map[string]int{}
+	byNamespace := map[string]int{}
+	byVersion := map[string]int{}
+	byContainerType := map[string]int{}
+	total := 0
+
+	for _, record := range records {
+		if record.Timestamp.Before(since) || record.Timestamp.After(until) {
+			continue
+		}
+		total++
+		bySignal[signalName(record.Signal)]++
+		byExecutable[bucketKey(record.Executable)]++
+		byComponent[bucketKey(record.Component)]++
+		byNamespace[bucketKey(record.PodNamespace)]++
+		byVersion[bucketKey(record.MilvusVersion)]++
+		byContainerType[bucketKey(record.ContainerType)]++
+	}
+
+	return &Breakdown{
+		Window:          formatWindow(window),
+		Since:           since,
+		Until:           until,
+		Total:           total,
+		BySignal:        sortedBuckets(bySignal),
+		ByExecutable:    sortedBuckets(byExecutable),
+		ByComponent:     sortedBuckets(byComponent),
+		ByNamespace:     sortedBuckets(byNamespace),
+		ByMilvusVersion: sortedBuckets(byVersion),
+		ByContainerType: sortedBuckets(byContainerType),
+	}
+}
+
+// parseWindow accepts Go durations plus a "d" suffix for whole days.
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		window = parsed
+	}
+
+	if window <= 0 || window > maxBreakdownWindow {
+		return 0, fmt.Errorf("window must be between 0 and %s", formatWindow(maxBreakdownWindow))
+	}
+	return window, nil
+}
+
+func formatWindow(window time.Duration) string {
+	if window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+	return window.String()
+}
+
+func signalName(signal int) string {
+	if name, exists := signalNames[signal]; exists {
+		return name
+	}
+	if signal == 0 {
+		return unknownBucket
+	}
+	return fmt.Sprintf("signal %d", signal)
+}
+
+func bucketKey(value string) string {
+	if value == "" {
+		return unknownBucket
+	}
+	return value
+}
+
+// sortedBuckets orders buckets by count, largest first, breaking ties by key
+// so responses are stable.
+func sortedBuckets(counts map[string]int) []BucketCount {
+	buckets := make([]BucketCount, 0, len(counts))
+	for key, count := range counts {
+		buckets = append(buckets, BucketCount{Key: key, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	return buckets
+}
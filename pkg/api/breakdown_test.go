@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func TestBreakdown(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+
+	store.upsert(&collector.CoredumpFile{ID: "a", Signal: 11, Executable: "milvus", Component: "querynode",
+		PodNamespace: "default", MilvusVersion: "v2.4.5", Timestamp: now.Add(-time.Hour)})
+	store.upsert(&collector.CoredumpFile{ID: "b", Signal: 11, Executable: "milvus", Component: "proxy",
+		PodNamespace: "default", MilvusVersion: "v2.4.5", ContainerType: "init", Timestamp: now.Add(-2 * time.Hour)})
+	store.upsert(&collector.CoredumpFile{ID: "c", Signal: 6, Executable: "milvus", Component: "querynode",
+		PodNamespace: "milvus-system", Timestamp: now.Add(-3 * time.Hour)})
+	// Outside the default 24h window
+	store.upsert(&collector.CoredumpFile{ID: "d", Signal: 8, Executable: "milvus",
+		Timestamp: now.Add(-48 * time.Hour)})
+
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/breakdown", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var breakdown Breakdown
+	if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if breakdown.Window != "1d" || breakdown.Total != 3 {
+		t.Fatalf("expected 3 cores in 1d window, got %d in %s", breakdown.Total, breakdown.Window)
+	}
+	expectBuckets(t, "bySignal", breakdown.BySignal, []BucketCount{{"SIGSEGV", 2}, {"SIGABRT", 1}})
+	expectBuckets(t, "byComponent", breakdown.ByComponent, []BucketCount{{"querynode", 2}, {"proxy", 1}})
+	expectBuckets(t, "byNamespace", breakdown.ByNamespace, []BucketCount{{"default", 2}, {"milvus-system", 1}})
+	expectBuckets(t, "byMilvusVersion", breakdown.ByMilvusVersion, []BucketCount{{"v2.4.5", 2}, {"unknown", 1}})
+	expectBuckets(t, "byContainerType", breakdown.ByContainerType, []BucketCount{{"unknown", 2}, {"init", 1}})
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/breakdown?window=7d", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if breakdown.Total != 4 {
+		t.Errorf("expected 4 cores in 7d window, got %d", breakdown.Total)
+	}
+}
+
+func TestBreakdownCacheInvalidatedOnWrite(t *testing.T) {
+	store := NewStore(0, time.Hour)
+	server := NewServer(store, nil, nil, nil, nil, nil)
+	total := func() int {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/breakdown", nil))
+		var breakdown Breakdown
+		if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return breakdown.Total
+	}
+
+	if total() != 0 {
+		t.Fatal("expected an empty breakdown")
+	}
+	store.upsert(&collector.CoredumpFile{ID: "a", Signal: 11, Timestamp: time.Now()})
+	if got := total(); got != 1 {
+		t.Errorf("expected the new core despite the hour-long TTL, got %d", got)
+	}
+}
+
+func TestBreakdownInvalidWindow(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+
+	for _, window := range []string{"abc", "-1h", "365d"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/breakdown?window="+window, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("window %q: expected 400, got %d", window, rec.Code)
+		}
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	store := NewStore(2, 0)
+	for _, id := range []string{"a", "b", "c"} {
+		store.upsert(&collector.CoredumpFile{ID: id})
+	}
+
+	if _, exists := store.Get("a"); exists {
+		t.Error("expected oldest record to be evicted")
+	}
+	if records := store.Records(); len(records) != 2 || records[0].ID != "b" {
+		t.Errorf("unexpected records after eviction: %v", records)
+	}
+}
+
+func expectBuckets(t *testing.T, name string, got, expected []BucketCount) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s: expected %v, got %v", name, expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("%s[%d]: expected %v, got %v", name, i, expected[i], got[i])
+		}
+	}
+}
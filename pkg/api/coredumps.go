@@ -0,0 +1,330 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/notes"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+type CoredumpList struct {
+	Items      []*CoredumpView `json:"items"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+	// Offset and Total are only set in offset mode.
+	Offset *int `json:"offset,omitempty"`
+	Total  *int `json:"total,omitempty"`
+}
+
+// pageCursor is the position of the last item of a page in the list
+// ordering (createdAt descending, then ID descending). Unlike an offset it
+// stays valid when new coredumps arrive between requests.
+type pageCursor struct {
+	createdAt time.Time
+	id        string
+}
+
+func (c pageCursor) encode() string {
+	raw := fmt.Sprintf("%d:%s", c.createdAt.UnixNano(), c.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found || id == "" {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return pageCursor{createdAt: time.Unix(0, n), id: id}, nil
+}
+
+// after reports whether record sorts after the cursor position.
+func (c pageCursor) after(record *collector.CoredumpFile) bool {
+	createdAt := record.CreatedAt.Time
+	if !createdAt.Equal(c.createdAt) {
+		return createdAt.Before(c.createdAt)
+	}
+	return record.ID < c.id
+}
+
+// GET /api/v1/coredumps?limit=50&cursor=<nextCursor>
+// GET /api/v1/coredumps?limit=50&offset=100
+// GET /api/v1/coredumps?underChaos=false&containerType=init
+// GET /api/v1/coredumps?package=libc6@2.35-0ubuntu3.8
+// GET /api/v1/coredumps?namespace=milvus&instance=prod&minScore=7
+// GET /api/v1/coredumps?since=2024-05-01T00:00:00Z&until=2024-05-02T00:00:00Z
+// GET /api/v1/coredumps?full=true
+func (s *Server) handleListCoredumps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	full, ok := fullRecord(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultPageLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			writeInvalidParameter(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+			return
+		}
+		limit = n
+	}
+
+	if query.Get("cursor") != "" && query.Get("offset") != "" {
+		writeInvalidParameter(w, r, "offset", "cursor and offset cannot be combined")
+		return
+	}
+
+	filter, ok := parseCoredumpFilter(w, r)
+	if !ok {
+		return
+	}
+	records := sortedForList(s.store.Select(filter.matches))
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			writeInvalidParameter(w, r, "offset", "offset must be a non-negative integer")
+			return
+		}
+		writeJSON(w, http.StatusOK, offsetPage(records, offset, limit, full))
+		return
+	}
+
+	var cursor *pageCursor
+	if value := query.Get("cursor"); value != "" {
+		decoded, err := decodeCursor(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "cursor", err.Error())
+			return
+		}
+		cursor = &decoded
+	}
+	writeJSON(w, http.StatusOK, cursorPage(records, cursor, limit, full))
+}
+
+// coredumpFilter selects the coredumps of a listing; unset fields match
+// every coredump. since and until bound the crash time.
+type coredumpFilter struct {
+	underChaos     *bool
+	containerType  string
+	packageName    string
+	packageVersion string
+	namespace      string
+	instance       string
+	minScore       *float64
+	maxScore       *float64
+	since          time.Time
+	until          time.Time
+}
+
+func parseCoredumpFilter(w http.ResponseWriter, r *http.Request) (*coredumpFilter, bool) {
+	query := r.URL.Query()
+	filter := &coredumpFilter{
+		containerType: query.Get("containerType"),
+		namespace:     query.Get("namespace"),
+		instance:      query.Get("instance"),
+	}
+
+	if value := query.Get("underChaos"); value != "" {
+		underChaos, err := strconv.ParseBool(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "underChaos", "underChaos must be true or false")
+			return nil, false
+		}
+		filter.underChaos = &underChaos
+	}
+	switch filter.containerType {
+	case "", discovery.ContainerTypeMain, discovery.ContainerTypeInit, discovery.ContainerTypeEphemeral:
+	default:
+		writeInvalidParameter(w, r, "containerType", "containerType must be main, init or ephemeral")
+		return nil, false
+	}
+	if value := query.Get("package"); value != "" {
+		filter.packageName, filter.packageVersion, _ = strings.Cut(value, "@")
+	}
+
+	for _, bound := range []struct {
+		name  string
+		score **float64
+	}{{"minScore", &filter.minScore}, {"maxScore", &filter.maxScore}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 10 {
+			writeInvalidParameter(w, r, bound.name, bound.name+" must be a score between 0 and 10")
+			return nil, false
+		}
+		*bound.score = &score
+	}
+	if filter.minScore != nil && filter.maxScore != nil && *filter.minScore > *filter.maxScore {
+		writeInvalidParameter(w, r, "maxScore", "maxScore must not be below minScore")
+		return nil, false
+	}
+
+	for _, bound := range []struct {
+		name string
+		at   *time.Time
+	}{{"since", &filter.since}, {"until", &filter.until}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeInvalidParameter(w, r, bound.name, bound.name+" must be an RFC 3339 time such as 2024-05-01T00:00:00Z")
+			return nil, false
+		}
+		*bound.at = at
+	}
+	if !filter.since.IsZero() && !filter.until.IsZero() && filter.until.Before(filter.since) {
+		writeInvalidParameter(w, r, "until", "until must not be before since")
+		return nil, false
+	}
+	return filter, true
+}
+
+func (f *coredumpFilter) matches(record *collector.CoredumpFile) bool {
+	switch {
+	case f.underChaos != nil && record.UnderChaos != *f.underChaos,
+		f.containerType != "" && record.ContainerType != f.containerType,
+		f.namespace != "" && record.PodNamespace != f.namespace,
+		f.instance != "" && record.InstanceName != f.instance,
+		f.minScore != nil && record.ValueScore < *f.minScore,
+		f.maxScore != nil && record.ValueScore > *f.maxScore,
+		!f.since.IsZero() && record.Timestamp.Before(f.since),
+		!f.until.IsZero() && record.Timestamp.After(f.until):
+		return false
+	}
+	if f.packageName == "" {
+		return true
+	}
+	manifest := environmentOf(record)
+	if manifest == nil {
+		return false
+	}
+	installed, found := packageVersion(manifest, f.packageName)
+	return found && (f.packageVersion == "" || installed == f.packageVersion)
+}
+
+// GET /api/v1/coredumps/<id>
+// GET /api/v1/coredumps/<id>?full=true
+func (s *Server) handleGetCoredump(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/coredumps/")
+	if waitFor, found := strings.CutSuffix(id, "/wait"); found && waitFor != "" && !strings.Contains(waitFor, "/") {
+		s.handleWaitCoredump(w, r, waitFor)
+		return
+	}
+	if requeue, found := strings.CutSuffix(id, "/reanalyze"); found && requeue != "" && !strings.Contains(requeue, "/") {
+		s.handleReanalyze(w, r, requeue)
+		return
+	}
+	if coredumpID, found := strings.CutSuffix(id, "/stacktrace"); found && coredumpID != "" && !strings.Contains(coredumpID, "/") {
+		s.handleStackTrace(w, r, coredumpID)
+		return
+	}
+	if coredumpID, found := strings.CutSuffix(id, "/goroutines"); found && coredumpID != "" && !strings.Contains(coredumpID, "/") {
+		s.handleGoroutines(w, r, coredumpID)
+		return
+	}
+	if coredumpID, found := strings.CutSuffix(id, "/similar"); found && coredumpID != "" && !strings.Contains(coredumpID, "/") {
+		s.handleSimilar(w, r, coredumpID)
+		return
+	}
+	if coredumpID, noteID, found := notesPath(id); found {
+		exists := func() bool {
+			_, exists := s.store.Get(coredumpID)
+			return exists
+		}
+		s.handleNotes(w, r, notes.Subject{Kind: notes.KindCoredump, ID: coredumpID}, exists, noteID)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		writeProblem(w, r, CodeNotFound, "")
+		return
+	}
+
+	full, ok := fullRecord(w, r)
+	if !ok {
+		return
+	}
+	record, exists := s.store.Get(id)
+	if !exists {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, newCoredumpView(record, full))
+}
+
+func sortedForList(records []*collector.CoredumpFile) []*collector.CoredumpFile {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i].CreatedAt.Time, records[j].CreatedAt.Time
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return records[i].ID > records[j].ID
+	})
+	return records
+}
+
+func cursorPage(records []*collector.CoredumpFile, cursor *pageCursor, limit int, full bool) *CoredumpList {
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(records), func(i int) bool {
+			return cursor.after(records[i])
+		})
+	}
+	return page(records, start, limit, full)
+}
+
+func offsetPage(records []*collector.CoredumpFile, offset, limit int, full bool) *CoredumpList {
+	total := len(records)
+	list := page(records, min(offset, total), limit, full)
+	list.Offset = &offset
+	list.Total = &total
+	return list
+}
+
+func page(records []*collector.CoredumpFile, start, limit int, full bool) *CoredumpList {
+	end := min(start+limit, len(records))
+	list := &CoredumpList{Items: make([]*CoredumpView, 0, end-start)}
+	for _, record := range records[start:end] {
+		list.Items = append(list.Items, newCoredumpView(record, full))
+	}
+	if end < len(records) {
+		last := records[end-1]
+		list.NextCursor = pageCursor{createdAt: last.CreatedAt.Time, id: last.ID}.encode()
+	}
+	return list
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func listCoredumps(t *testing.T, server *Server, query string) *CoredumpList {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+	}
+	var list CoredumpList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return &list
+}
+
+func TestListCoredumpsCursor(t *testing.T) {
+	store := NewStore(0, 0)
+	base := time.Now()
+	// Two records share a timestamp so the ID tie-breaker is exercised.
+	for i, offset := range []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		store.upsert(&collector.CoredumpFile{
+			ID:        fmt.Sprintf("core-%d", i),
+			CreatedAt: metav1.NewTime(base.Add(offset)),
+		})
+	}
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	first := listCoredumps(t, server, "limit=2")
+	if len(first.Items) != 2 || first.NextCursor == "" {
+		t.Fatalf("unexpected first page: %d items, cursor %q", len(first.Items), first.NextCursor)
+	}
+	if first.Items[0].ID != "core-4" || first.Items[1].ID != "core-3" {
+		t.Errorf("expected newest first, got %s, %s", first.Items[0].ID, first.Items[1].ID)
+	}
+
+	// A core arriving between requests must not shift later pages.
+	store.upsert(&collector.CoredumpFile{ID: "core-new", CreatedAt: metav1.NewTime(base.Add(time.Hour))})
+
+	seen := []string{first.Items[0].ID, first.Items[1].ID}
+	cursor := first.NextCursor
+	for cursor != "" {
+		page := listCoredumps(t, server, "limit=2&cursor="+cursor)
+		for _, item := range page.Items {
+			seen = append(seen, item.ID)
+		}
+		cursor = page.NextCursor
+	}
+
+	expected := []string{"core-4", "core-3", "core-2", "core-1", "core-0"}
+	if fmt.Sprint(seen) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, seen)
+	}
+}
+
+func TestListCoredumpsOffset(t *testing.T) {
+	store := NewStore(0, 0)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		store.upsert(&collector.CoredumpFile{
+			ID:        fmt.Sprintf("core-%d", i),
+			CreatedAt: metav1.NewTime(base.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	list := listCoredumps(t, server, "limit=2&offset=3")
+	if list.Total == nil || *list.Total != 5 {
+		t.Fatalf("expected total 5, got %v", list.Total)
+	}
+	if len(list.Items) != 2 || list.Items[0].ID != "core-1" || list.Items[1].ID != "core-0" {
+		t.Errorf("unexpected offset page: %v", list.Items)
+	}
+	if list.NextCursor != "" {
+		t.Errorf("expected no next cursor on the last page, got %q", list.NextCursor)
+	}
+
+	if list := listCoredumps(t, server, "offset=10"); len(list.Items) != 0 {
+		t.Errorf("expected empty page past the end, got %d items", len(list.Items))
+	}
+}
+
+func TestListCoredumpsInvalidParams(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+
+	for _, query := range []string{"limit=0", "limit=1000", "offset=-1", "cursor=!!!", "cursor=abc&offset=1", "containerType=sidecar",
+		"minScore=11", "minScore=8&maxScore=5", "since=yesterday", "since=2024-05-02T00:00:00Z&until=2024-05-01T00:00:00Z"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListCoredumpsByContainerType(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "a", ContainerType: "main"})
+	store.upsert(&collector.CoredumpFile{ID: "b", ContainerType: "init"})
+	store.upsert(&collector.CoredumpFile{ID: "c"})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	list := listCoredumps(t, server, "containerType=init")
+	if len(list.Items) != 1 || list.Items[0].ID != "b" {
+		t.Errorf("expected only the init container core, got %+v", list.Items)
+	}
+}
+
+func TestListCoredumpsFiltered(t *testing.T) {
+	store := NewStore(0, 0)
+	crashedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, record := range []collector.CoredumpFile{
+		{PodNamespace: "milvus", InstanceName: "prod", ValueScore: 8},
+		{PodNamespace: "milvus", InstanceName: "prod", ValueScore: 4},
+		{PodNamespace: "milvus", InstanceName: "staging", ValueScore: 9},
+		{PodNamespace: "other", InstanceName: "prod", ValueScore: 9},
+	} {
+		record.ID = fmt.Sprintf("core-%d", i)
+		record.Timestamp = crashedAt.Add(time.Duration(i) * time.Hour)
+		record.CreatedAt = metav1.NewTime(record.Timestamp)
+		store.upsert(&record)
+	}
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	for query, expected := range map[string][]string{
+		"namespace=milvus&instance=prod":                        {"core-1", "core-0"},
+		"namespace=milvus&minScore=7":                           {"core-2", "core-0"},
+		"maxScore=4.5":                                          {"core-1"},
+		"since=2024-05-01T13:00:00Z":                            {"core-3", "core-2", "core-1"},
+		"since=2024-05-01T13:00:00Z&until=2024-05-01T14:00:00Z": {"core-2", "core-1"},
+		"instance=prod&minScore=5&offset=0":                     {"core-3", "core-0"},
+	} {
+		list := listCoredumps(t, server, query)
+		var ids []string
+		for _, item := range list.Items {
+			ids = append(ids, item.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, ids)
+		}
+	}
+	if list := listCoredumps(t, server, "instance=prod&limit=1&offset=0"); *list.Total != 3 {
+		t.Errorf("expected the total to count the filtered coredumps, got %d", *list.Total)
+	}
+}
+
+func TestGetCoredump(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "abc123", Executable: "milvus"})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps/abc123", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var record collector.CoredumpFile
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil || record.Executable != "milvus" {
+		t.Errorf("unexpected record %+v (err %v)", record, err)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
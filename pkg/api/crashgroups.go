@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/notes"
+)
+
+// CrashGroupSource provides the crash groups found by the analyzer.
+type CrashGroupSource interface {
+	Groups() []crashgroup.Group
+	Get(fingerprint string) (crashgroup.Group, bool)
+}
+
+// GET /api/v1/crash-groups
+func (s *Server) handleListCrashGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.groups == nil {
+		writeProblem(w, r, CodeUnavailable, "crash grouping is not enabled")
+		return
+	}
+
+	items := s.groups.Groups()
+	if items == nil {
+		items = []crashgroup.Group{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// GET /api/v1/crash-groups/<fingerprint>
+func (s *Server) handleGetCrashGroup(w http.ResponseWriter, r *http.Request) {
+	fingerprint := strings.TrimPrefix(r.URL.Path, "/api/v1/crash-groups/")
+	if groupID, noteID, found := notesPath(fingerprint); found {
+		exists := func() bool {
+			if s.groups == nil {
+				return false
+			}
+			_, exists := s.groups.Get(groupID)
+			return exists
+		}
+		s.handleNotes(w, r, notes.Subject{Kind: notes.KindCrashGroup, ID: groupID}, exists, noteID)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.groups == nil {
+		writeProblem(w, r, CodeUnavailable, "crash grouping is not enabled")
+		return
+	}
+
+	group, exists := s.groups.Get(fingerprint)
+	if !exists {
+		writeProblem(w, r, CodeNotFound, "crash group "+fingerprint+" not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, group)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/crashgroup"
+)
+
+func TestCrashGroups(t *testing.T) {
+	registry := crashgroup.New(0)
+	for i, id := range []string{"core-1", "core-2", "core-3"} {
+		fingerprint := "loop"
+		if id == "core-3" {
+			fingerprint = "once"
+		}
+		core := &collector.CoredumpFile{ID: id, Executable: "milvus", Signal: 11, Fingerprint: fingerprint}
+		registry.Observe(core, []string{"bulk_subscript"}, time.Now().Add(time.Duration(i)*time.Minute))
+	}
+	server := NewServer(NewStore(0, 0), nil, nil, registry, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/crash-groups", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var list struct {
+		Items []crashgroup.Group `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].Fingerprint != "loop" || list.Items[0].Occurrences != 2 {
+		t.Errorf("expected the crash loop first with 2 occurrences, got %+v", list.Items)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/crash-groups/once", nil))
+	var group crashgroup.Group
+	if err := json.Unmarshal(rec.Body.Bytes(), &group); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || group.FirstCoredumpID != "core-3" {
+		t.Errorf("unexpected group (%d): %+v", rec.Code, group)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/crash-groups/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown group, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(NewStore(0, 0), nil, nil, nil, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/crash-groups", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without crash grouping, got %d", rec.Code)
+	}
+}
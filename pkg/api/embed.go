@@ -0,0 +1,378 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+const (
+	WidgetSummary     = "summary"
+	WidgetTrend       = "trend"
+	WidgetCrashGroups = "crash-groups"
+	WidgetRestarts    = "restarts"
+
+	defaultWidgetWindow = 7 * 24 * time.Hour
+	defaultWidgetGroups = 5
+	maxWidgetGroups     = 20
+)
+
+var widgets = []string{WidgetSummary, WidgetTrend, WidgetCrashGroups, WidgetRestarts}
+
+// SummaryWidget counts the crashes of the window and of the window before.
+type SummaryWidget struct {
+	Window          string    `json:"window"`
+	Since           time.Time `json:"since"`
+	Until           time.Time `json:"until"`
+	Crashes         int       `json:"crashes"`
+	PreviousCrashes int       `json:"previousCrashes"`
+	Instances       int       `json:"instances"`
+}
+
+// TrendWidget counts crashes per UTC day, oldest first.
+type TrendWidget struct {
+	Window string        `json:"window"`
+	Days   []BucketCount `json:"days"`
+}
+
+// EmbedAPI serves /api/v1/embed/: small widgets that other teams embed in
+// their portals, as JSON or, with ?format=html, as a self-contained HTML
+// page for an iframe. Each token may be limited to some of the widgets;
+// since iframes cannot send headers, the token may also be passed as
+// ?token=. Cross-origin requests are allowed from AllowedOrigins.
+type EmbedAPI struct {
+	store   *Store
+	groups  CrashGroupSource
+	tokens  map[string]map[string]bool
+	origins []string
+}
+
+// NewEmbedAPI reads the tokens from config.TokensFile, one per line followed
+// by the widgets it may read, comma separated, or "*" for all of them.
+func NewEmbedAPI(store *Store, groups CrashGroupSource, config *config.EmbedAPIConfig) (*EmbedAPI, error) {
+	file, err := os.Open(config.TokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embed API tokens: %w", err)
+	}
+	defer file.Close()
+
+	tokens := make(map[string]map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("embed API tokens line %d: expected a token and its widgets", line)
+		}
+		scope := make(map[string]bool)
+		for _, widget := range strings.Split(fields[1], ",") {
+			if widget == "*" {
+				for _, name := range widgets {
+					scope[name] = true
+				}
+				continue
+			}
+			if !isWidget(widget) {
+				return nil, fmt.Errorf("embed API tokens line %d: unknown widget %q", line, widget)
+			}
+			scope[widget] = true
+		}
+		tokens[fields[0]] = scope
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read embed API tokens: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("embed API tokens file %s has no tokens", config.TokensFile)
+	}
+
+	return &EmbedAPI{
+		store:   store,
+		groups:  groups,
+		tokens:  tokens,
+		origins: config.AllowedOrigins,
+	}, nil
+}
+
+// HandleEmbed mounts the embed API on the server.
+func (s *Server) HandleEmbed(embed *EmbedAPI) {
+	s.mux.Handle("/api/v1/embed/", embed)
+}
+
+func (e *EmbedAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.allowOrigin(w, r)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	widget := strings.TrimPrefix(r.URL.Path, "/api/v1/embed/")
+	if !isWidget(widget) {
+		writeProblem(w, r, CodeNotFound, "")
+		return
+	}
+	scope, ok := e.scope(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="milvus-coredump-agent"`)
+		writeProblem(w, r, CodeUnauthorized, "")
+		return
+	}
+	if !scope[widget] {
+		writeProblem(w, r, CodeForbidden, fmt.Sprintf("token may not read the %s widget", widget))
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "html" {
+		writeInvalidParameter(w, r, "format", "format must be json or html")
+		return
+	}
+	window := defaultWidgetWindow
+	if value := query.Get("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "window", err.Error())
+			return
+		}
+		window = parsed
+	}
+
+	var data interface{}
+	switch widget {
+	case WidgetSummary:
+		data = statscache.Get(e.store.stats, "embed/summary/"+window.String(), func() *SummaryWidget {
+			return computeSummary(e.store.Records(), time.Now(), window)
+		})
+	case WidgetTrend:
+		data = statscache.Get(e.store.stats, "embed/trend/"+window.String(), func() *TrendWidget {
+			return computeTrend(e.store.Records(), time.Now(), window)
+		})
+	case WidgetCrashGroups:
+		if e.groups == nil {
+			writeProblem(w, r, CodeUnavailable, "crash grouping is not enabled")
+			return
+		}
+		limit := defaultWidgetGroups
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > maxWidgetGroups {
+				writeInvalidParameter(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxWidgetGroups))
+				return
+			}
+			limit = n
+		}
+		data = topCrashGroups(e.groups.Groups(), time.Now().Add(-window), limit)
+	case WidgetRestarts:
+		data = statscache.Get(e.store.stats, "embed/restarts/"+window.String(), func() *RestartList {
+			until := time.Now()
+			return computeRestarts(e.store.Restarts(), restartFilter{}, RestartsByHour, until.Add(-window), until, window)
+		})
+	}
+
+	if format == "html" {
+		e.writeWidgetHTML(w, widget, data)
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// scope returns the widgets the request's token may read.
+func (e *EmbedAPI) scope(r *http.Request) (map[string]bool, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return nil, false
+	}
+	for candidate, scope := range e.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			return scope, true
+		}
+	}
+	return nil, false
+}
+
+// allowOrigin sets the CORS headers for allowed origins; "*" allows all.
+func (e *EmbedAPI) allowOrigin(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	allowed := false
+	for _, candidate := range e.origins {
+		allowed = allowed || candidate == origin || candidate == "*"
+	}
+	if origin == "" || !allowed {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.Header().Add("Vary", "Origin")
+}
+
+func (e *EmbedAPI) writeWidgetHTML(w http.ResponseWriter, widget string, data interface{}) {
+	var body bytes.Buffer
+	if err := widgetTemplates.ExecuteTemplate(&body, widget, data); err != nil {
+		klog.Errorf("Failed to render %s widget: %v", widget, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Only the allowed origins may frame the widget.
+	ancestors := "'none'"
+	if len(e.origins) > 0 {
+		ancestors = strings.Join(e.origins, " ")
+	}
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors "+ancestors)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+func isWidget(name string) bool {
+	for _, widget := range widgets {
+		if widget == name {
+			return true
+		}
+	}
+	return false
+}
+
+func computeSummary(records []*collector.CoredumpFile, until time.Time, window time.Duration) *SummaryWidget {
+	since := until.Add(-window)
+	summary := &SummaryWidget{Window: formatWindow(window), Since: since, Until: until}
+	instances := make(map[string]bool)
+	for _, record := range records {
+		switch {
+		case record.Timestamp.After(until):
+		case !record.Timestamp.Before(since):
+			summary.Crashes++
+			if record.InstanceName != "" {
+				instances[record.PodNamespace+"/"+record.InstanceName] = true
+			}
+		case !record.Timestamp.Before(since.Add(-window)):
+			summary.PreviousCrashes++
+		}
+	}
+	summary.Instances = len(instances)
+	return summary
+}
+
+func computeTrend(records []*collector.CoredumpFile, until time.Time, window time.Duration) *TrendWidget {
+	days := int((window + 24*time.Hour - 1) / (24 * time.Hour))
+	last := until.UTC().Truncate(24 * time.Hour)
+	first := last.AddDate(0, 0, -(days - 1))
+
+	trend := &TrendWidget{Window: formatWindow(window), Days: make([]BucketCount, days)}
+	for i := range trend.Days {
+		trend.Days[i].Key = first.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	for _, record := range records {
+		day := record.Timestamp.UTC().Truncate(24 * time.Hour)
+		if day.Before(first) || day.After(last) {
+			continue
+		}
+		trend.Days[int(day.Sub(first)/(24*time.Hour))].Count++
+	}
+	return trend
+}
+
+// topCrashGroups returns the most frequent groups seen since the given time.
+func topCrashGroups(groups []crashgroup.Group, since time.Time, limit int) map[string]interface{} {
+	items := []crashgroup.Group{}
+	for _, group := range groups {
+		if group.LastSeen.Before(since) {
+			continue
+		}
+		items = append(items, group)
+		if len(items) == limit {
+			break
+		}
+	}
+	return map[string]interface{}{"items": items}
+}
+
+const widgetStyle = `<style>
+body{margin:0;font:14px/1.4 -apple-system,"Segoe UI",Helvetica,Arial,sans-serif;color:#1f2328;background:transparent}
+.tile{padding:12px 16px}
+.label{color:#656d76;font-size:12px;text-transform:uppercase}
+.value{font-size:32px;font-weight:600}
+table{border-collapse:collapse;width:100%}
+td{padding:4px 8px;border-bottom:1px solid #d0d7de;vertical-align:top}
+td.count{text-align:right;font-weight:600}
+</style>`
+
+var widgetTemplates = template.Must(template.New("widgets").Funcs(template.FuncMap{
+	"sparkline": sparkline,
+	"topFrame":  topFrame,
+}).Parse(`
+{{define "summary"}}<!DOCTYPE html><html><head><meta charset="utf-8">` + widgetStyle + `</head><body><div class="tile">
+<div class="label">Crashes, last {{.Window}}</div>
+<div class="value">{{.Crashes}}</div>
+<div>{{.PreviousCrashes}} in the {{.Window}} before, {{.Instances}} instances affected</div>
+</div></body></html>{{end}}
+{{define "trend"}}<!DOCTYPE html><html><head><meta charset="utf-8">` + widgetStyle + `</head><body><div class="tile">
+<div class="label">Crashes per day, last {{.Window}}</div>
+{{sparkline .Days}}
+</div></body></html>{{end}}
+{{define "crash-groups"}}<!DOCTYPE html><html><head><meta charset="utf-8">` + widgetStyle + `</head><body><div class="tile">
+<div class="label">Top crash sites</div>
+<table>{{range .items}}<tr><td>{{.Executable}} {{topFrame .Frames}}<br><small>{{.CrashReason}}</small></td><td class="count">{{.Occurrences}}</td></tr>{{else}}<tr><td>No crashes</td></tr>{{end}}</table>
+</div></body></html>{{end}}
+{{define "restarts"}}<!DOCTYPE html><html><head><meta charset="utf-8">` + widgetStyle + `</head><body><div class="tile">
+<div class="label">Restarts per hour, last {{.Window}}</div>
+<div class="value">{{.Total}}</div>
+{{sparkline .Groups}}
+</div></body></html>{{end}}
+`))
+
+// sparkline draws daily or hourly counts as an inline SVG polyline.
+func sparkline(days []BucketCount) template.HTML {
+	const width, height = 200, 40
+	max := 1
+	for _, day := range days {
+		if day.Count > max {
+			max = day.Count
+		}
+	}
+	var points []string
+	for i, day := range days {
+		x := 0.0
+		if len(days) > 1 {
+			x = float64(i) * width / float64(len(days)-1)
+		}
+		y := height - float64(day.Count)*(height-2)/float64(max) - 1
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return template.HTML(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d"><polyline fill="none" stroke="#cf222e" stroke-width="2" points="%s"/></svg>`,
+		width, height, width, height, strings.Join(points, " ")))
+}
+
+func topFrame(frames []string) string {
+	if len(frames) == 0 {
+		return ""
+	}
+	return frames[0]
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+)
+
+func newTestEmbedAPI(t *testing.T, store *Store, groups CrashGroupSource) *Server {
+	t.Helper()
+	cfg := config.EmbedAPIConfig{
+		TokensFile:     filepath.Join(t.TempDir(), "tokens"),
+		AllowedOrigins: []string{"https://portal.internal"},
+	}
+	tokens := "# team portal\nportal summary,trend\nadmin *\n"
+	if err := os.WriteFile(cfg.TokensFile, []byte(tokens), 0600); err != nil {
+		t.Fatal(err)
+	}
+	embed, err := NewEmbedAPI(store, groups, &cfg)
+	if err != nil {
+		t.Fatalf("NewEmbedAPI failed: %v", err)
+	}
+	server := NewServer(store, nil, nil, groups, nil, nil)
+	server.HandleEmbed(embed)
+	return server
+}
+
+func embedRequest(server *Server, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEmbedTokenScopes(t *testing.T) {
+	server := newTestEmbedAPI(t, NewStore(0, 0), crashgroup.New(0))
+
+	for path, expected := range map[string]int{
+		"/api/v1/embed/summary":                    http.StatusUnauthorized,
+		"/api/v1/embed/summary?token=wrong":        http.StatusUnauthorized,
+		"/api/v1/embed/summary?token=portal":       http.StatusOK,
+		"/api/v1/embed/crash-groups?token=portal":  http.StatusForbidden,
+		"/api/v1/embed/crash-groups?token=admin":   http.StatusOK,
+		"/api/v1/embed/unknown?token=admin":        http.StatusNotFound,
+		"/api/v1/embed/trend?token=admin&format=x": http.StatusBadRequest,
+	} {
+		if rec := embedRequest(server, http.MethodGet, path, nil); rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, rec.Code)
+		}
+	}
+	header := http.Header{"Authorization": {"Bearer portal"}}
+	if rec := embedRequest(server, http.MethodGet, "/api/v1/embed/trend", header); rec.Code != http.StatusOK {
+		t.Errorf("expected a bearer token to be accepted, got %d", rec.Code)
+	}
+}
+
+func TestEmbedCORS(t *testing.T) {
+	server := newTestEmbedAPI(t, NewStore(0, 0), nil)
+
+	rec := embedRequest(server, http.MethodOptions, "/api/v1/embed/summary", http.Header{"Origin": {"https://portal.internal"}})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://portal.internal" {
+		t.Errorf("expected an allowed preflight, got %d %v", rec.Code, rec.Header())
+	}
+	rec = embedRequest(server, http.MethodGet, "/api/v1/embed/summary?token=portal", http.Header{"Origin": {"https://elsewhere.example"}})
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for another origin, got %v", rec.Header())
+	}
+}
+
+func TestEmbedWidgets(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+	store.upsert(&collector.CoredumpFile{ID: "a", PodNamespace: "milvus", InstanceName: "prod", Timestamp: now.Add(-time.Hour)})
+	store.upsert(&collector.CoredumpFile{ID: "b", PodNamespace: "milvus", InstanceName: "prod", Timestamp: now.Add(-2 * 24 * time.Hour)})
+	store.upsert(&collector.CoredumpFile{ID: "c", Timestamp: now.Add(-10 * 24 * time.Hour)})
+	groups := crashgroup.New(0)
+	groups.Observe(&collector.CoredumpFile{ID: "a", Executable: "milvus", Fingerprint: "fp"}, []string{"bulk_subscript"}, now)
+	server := newTestEmbedAPI(t, store, groups)
+
+	var summary SummaryWidget
+	rec := embedRequest(server, http.MethodGet, "/api/v1/embed/summary?token=admin", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("failed to decode summary: %v", err)
+	}
+	if summary.Crashes != 2 || summary.PreviousCrashes != 1 || summary.Instances != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	var trend TrendWidget
+	rec = embedRequest(server, http.MethodGet, "/api/v1/embed/trend?token=admin&window=3d", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &trend); err != nil {
+		t.Fatalf("failed to decode trend: %v", err)
+	}
+	total := 0
+	for _, day := range trend.Days {
+		total += day.Count
+	}
+	if len(trend.Days) != 3 || total != 2 {
+		t.Errorf("expected 2 crashes over 3 days, got %+v", trend.Days)
+	}
+
+	rec = embedRequest(server, http.MethodGet, "/api/v1/embed/crash-groups?token=admin&format=html", http.Header{"Origin": {"https://portal.internal"}})
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML widget, got %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), "bulk_subscript") {
+		t.Errorf("expected the crash site in the widget:\n%s", rec.Body.String())
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors https://portal.internal") {
+		t.Errorf("expected framing limited to the allowed origins, got %q", csp)
+	}
+
+	rec = embedRequest(server, http.MethodGet, "/api/v1/embed/trend?token=admin&format=html", nil)
+	if !strings.Contains(rec.Body.String(), "<svg") {
+		t.Errorf("expected an SVG sparkline:\n%s", rec.Body.String())
+	}
+
+	store.recordRestart(restartAt("milvus", "prod", now.Add(-time.Hour), false))
+	rec = embedRequest(server, http.MethodGet, "/api/v1/embed/restarts?token=admin&format=html&window=1d", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<svg") {
+		t.Errorf("expected an hourly restarts sparkline, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
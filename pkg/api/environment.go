@@ -0,0 +1,128 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+// VersionBreakdown counts the crashes of a window by the version of one
+// package or shared library in their environment manifests, to tell whether
+// a crash follows e.g. a glibc or jemalloc version.
+type VersionBreakdown struct {
+	Package string    `json:"package,omitempty"`
+	Library string    `json:"library,omitempty"`
+	Window  string    `json:"window"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	// Total counts the crashes with an environment manifest; Without those
+	// whose manifest lacks the package or library.
+	Total    int           `json:"total"`
+	Without  int           `json:"without"`
+	Versions []BucketCount `json:"versions"`
+}
+
+// GET /api/v1/stats/versions?package=libc6&window=7d
+// GET /api/v1/stats/versions?library=libjemalloc.so.2
+func (s *Server) handleVersionBreakdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	pkg, library := query.Get("package"), query.Get("library")
+	if (pkg == "") == (library == "") {
+		writeInvalidParameter(w, r, "package", "exactly one of package and library is required")
+		return
+	}
+	window := defaultBreakdownWindow
+	if value := query.Get("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "window", err.Error())
+			return
+		}
+		window = parsed
+	}
+
+	key := "versions/" + pkg + "/" + library + "/" + window.String()
+	breakdown := statscache.Get(s.store.stats, key, func() *VersionBreakdown {
+		until := time.Now()
+		return computeVersionBreakdown(s.store.Records(), pkg, library, until.Add(-window), until, window)
+	})
+	writeJSON(w, http.StatusOK, breakdown)
+}
+
+func computeVersionBreakdown(records []*collector.CoredumpFile, pkg, library string, since, until time.Time, window time.Duration) *VersionBreakdown {
+	breakdown := &VersionBreakdown{
+		Package: pkg,
+		Library: library,
+		Window:  formatWindow(window),
+		Since:   since,
+		Until:   until,
+	}
+	versions := map[string]int{}
+	for _, record := range records {
+		if record.Timestamp.Before(since) || record.Timestamp.After(until) {
+			continue
+		}
+		manifest := environmentOf(record)
+		if manifest == nil {
+			continue
+		}
+		breakdown.Total++
+		var version string
+		var found bool
+		if pkg != "" {
+			version, found = packageVersion(manifest, pkg)
+		} else {
+			version, found = libraryVersion(manifest, library)
+		}
+		if !found {
+			breakdown.Without++
+			continue
+		}
+		versions[bucketKey(version)]++
+	}
+	breakdown.Versions = sortedBuckets(versions)
+	return breakdown
+}
+
+func environmentOf(record *collector.CoredumpFile) *collector.EnvironmentManifest {
+	if record.AnalysisResults == nil {
+		return nil
+	}
+	return record.AnalysisResults.Environment
+}
+
+func packageVersion(manifest *collector.EnvironmentManifest, name string) (string, bool) {
+	for _, pkg := range manifest.Packages {
+		if pkg.Name == name {
+			return pkg.Version, true
+		}
+	}
+	return "", false
+}
+
+// libraryVersion matches the library by file name. Its version is that of
+// its package; libraries shipped outside a package, like Milvus' bundled
+// jemalloc, are told apart by build ID.
+func libraryVersion(manifest *collector.EnvironmentManifest, name string) (string, bool) {
+	for _, library := range manifest.Libraries {
+		if filepath.Base(library.Path) != name {
+			continue
+		}
+		if library.Version != "" {
+			return library.Version, true
+		}
+		if library.BuildID != "" {
+			return "build-id:" + library.BuildID, true
+		}
+		return "", true
+	}
+	return "", false
+}
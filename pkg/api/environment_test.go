@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func withEnvironment(id string, timestamp time.Time, glibc string, jemallocBuildID string) *collector.CoredumpFile {
+	manifest := &collector.EnvironmentManifest{
+		Libraries: []collector.SharedLibrary{{Path: "/milvus/lib/libjemalloc.so.2", BuildID: jemallocBuildID}},
+	}
+	if glibc != "" {
+		manifest.Packages = []collector.Package{{Name: "libc6", Version: glibc}}
+		manifest.Libraries = append(manifest.Libraries, collector.SharedLibrary{Path: "/lib/x86_64-linux-gnu/libc.so.6", Package: "libc6", Version: glibc})
+	}
+	return &collector.CoredumpFile{ID: id, Timestamp: timestamp, AnalysisResults: &collector.AnalysisResults{Environment: manifest}}
+}
+
+func TestVersionBreakdown(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+	store.upsert(withEnvironment("a", now.Add(-time.Hour), "2.35-0ubuntu3.8", "aa"))
+	store.upsert(withEnvironment("b", now.Add(-2*time.Hour), "2.35-0ubuntu3.8", "aa"))
+	store.upsert(withEnvironment("c", now.Add(-3*time.Hour), "2.31-0ubuntu9.16", "bb"))
+	store.upsert(withEnvironment("d", now.Add(-4*time.Hour), "", "bb"))
+	// No manifest
+	store.upsert(&collector.CoredumpFile{ID: "e", Timestamp: now.Add(-time.Hour)})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	var breakdown VersionBreakdown
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/versions?package=libc6", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if breakdown.Total != 4 || breakdown.Without != 1 {
+		t.Errorf("expected 4 cores with a manifest, 1 without libc6, got %+v", breakdown)
+	}
+	expectBuckets(t, "versions", breakdown.Versions, []BucketCount{{"2.35-0ubuntu3.8", 2}, {"2.31-0ubuntu9.16", 1}})
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/versions?library=libjemalloc.so.2", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &breakdown); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expectBuckets(t, "versions", breakdown.Versions, []BucketCount{{"build-id:aa", 2}, {"build-id:bb", 2}})
+
+	for _, query := range []string{"", "?package=libc6&library=libc.so.6", "?package=libc6&window=x"} {
+		rec = httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/versions"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListCoredumpsByPackage(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+	store.upsert(withEnvironment("a", now, "2.35-0ubuntu3.8", ""))
+	store.upsert(withEnvironment("b", now, "2.31-0ubuntu9.16", ""))
+	store.upsert(withEnvironment("c", now, "", ""))
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	for query, expected := range map[string]int{
+		"package=libc6":                  2,
+		"package=libc6@2.31-0ubuntu9.16": 1,
+		"package=libc6@2.27":             0,
+		"package=musl":                   0,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps?"+query, nil))
+		var list CoredumpList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(list.Items) != expected {
+			t.Errorf("%s: expected %d coredumps, got %d", query, expected, len(list.Items))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// ErrorCode identifies the kind of error in a problem response. Codes are
+// stable; clients should branch on them rather than on the detail text.
+type ErrorCode string
+
+const (
+	CodeNotFound         ErrorCode = "not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeInvalidParameter ErrorCode = "invalid_parameter"
+	CodeInvalidBody      ErrorCode = "invalid_body"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeConflict         ErrorCode = "conflict"
+	CodeRateLimited      ErrorCode = "rate_limited"
+	CodeUnavailable      ErrorCode = "unavailable"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:milvus-coredump-agent:problem:"
+)
+
+var errorCodes = map[ErrorCode]struct {
+	status int
+	title  string
+}{
+	CodeNotFound:         {http.StatusNotFound, "Resource not found"},
+	CodeMethodNotAllowed: {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeInvalidParameter: {http.StatusBadRequest, "Invalid query parameter"},
+	CodeInvalidBody:      {http.StatusBadRequest, "Invalid request body"},
+	CodeUnauthorized:     {http.StatusUnauthorized, "Missing or invalid token"},
+	CodeForbidden:        {http.StatusForbidden, "Token not allowed for this resource"},
+	CodeConflict:         {http.StatusConflict, "Resource state conflict"},
+	CodeRateLimited:      {http.StatusTooManyRequests, "Rate limit exceeded"},
+	CodeUnavailable:      {http.StatusServiceUnavailable, "Data source unavailable"},
+}
+
+// Problem is an RFC 7807 error response. Type is derived from Code, which
+// is repeated as an extension member so clients need not parse the URI.
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+	// Parameter names the offending query parameter of an
+	// invalid_parameter error.
+	Parameter string `json:"parameter,omitempty"`
+}
+
+func newProblem(r *http.Request, code ErrorCode, detail string) *Problem {
+	info := errorCodes[code]
+	return &Problem{
+		Type:     problemTypePrefix + string(code),
+		Title:    info.title,
+		Status:   info.status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, code ErrorCode, detail string) {
+	sendProblem(w, newProblem(r, code, detail))
+}
+
+func writeInvalidParameter(w http.ResponseWriter, r *http.Request, parameter, detail string) {
+	problem := newProblem(r, CodeInvalidParameter, detail)
+	problem.Parameter = parameter
+	sendProblem(w, problem)
+}
+
+// writeMethodNotAllowed answers a request to one of the few endpoints that
+// take another method than GET.
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeProblem(w, r, CodeMethodNotAllowed, "")
+}
+
+func sendProblem(w http.ResponseWriter, problem *Problem) {
+	if problem.Code == CodeMethodNotAllowed && w.Header().Get("Allow") == "" {
+		// Endpoints are read-only unless they say otherwise.
+		w.Header().Set("Allow", http.MethodGet)
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		klog.Errorf("Failed to encode API error: %v", err)
+	}
+}
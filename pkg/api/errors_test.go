@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		method    string
+		target    string
+		status    int
+		code      ErrorCode
+		parameter string
+	}{
+		{http.MethodGet, "/api/v1/coredumps?limit=0", http.StatusBadRequest, CodeInvalidParameter, "limit"},
+		{http.MethodGet, "/api/v1/stats/breakdown?window=abc", http.StatusBadRequest, CodeInvalidParameter, "window"},
+		{http.MethodGet, "/api/v1/coredumps/missing", http.StatusNotFound, CodeNotFound, ""},
+		{http.MethodGet, "/api/v2/unknown", http.StatusNotFound, CodeNotFound, ""},
+		{http.MethodPost, "/api/v1/coredumps", http.StatusMethodNotAllowed, CodeMethodNotAllowed, ""},
+		{http.MethodGet, "/api/v1/instances", http.StatusServiceUnavailable, CodeUnavailable, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(test.method, test.target, nil))
+
+		if rec.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.target, test.status, rec.Code)
+			continue
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != problemContentType {
+			t.Errorf("%s: expected %s, got %s", test.target, problemContentType, contentType)
+		}
+		var problem Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s: failed to decode problem: %v", test.target, err)
+		}
+		if problem.Code != test.code || problem.Status != test.status || problem.Parameter != test.parameter {
+			t.Errorf("%s: unexpected problem %+v", test.target, problem)
+		}
+		if problem.Type != problemTypePrefix+string(test.code) || problem.Title == "" {
+			t.Errorf("%s: expected type and title for %s, got %+v", test.target, test.code, problem)
+		}
+	}
+}
+
+func TestMethodNotAllowedListsGet(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer(NewStore(0, 0), nil, nil, nil, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/stats/storage", nil))
+	if rec.Header().Get("Allow") != http.MethodGet {
+		t.Errorf("expected Allow: GET, got %q", rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/reload"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+const (
+	EventSourceCollector = "collector"
+	EventSourceAnalyzer  = "analyzer"
+	EventSourceStorage   = "storage"
+	EventSourceCleaner   = "cleaner"
+	EventSourceConfig    = "config"
+
+	// maxEventSubscribers bounds the concurrent /api/v1/events streams.
+	maxEventSubscribers = 64
+	// eventBuffer is the number of events a subscriber may fall behind by
+	// before it misses events.
+	eventBuffer = 64
+	// eventKeepalive is how often an idle stream sends a comment, so
+	// proxies and load balancers don't close it.
+	eventKeepalive = 15 * time.Second
+)
+
+// Event is a pipeline event as streamed to /api/v1/events. It carries
+// enough to update a dashboard; the full record is at
+// /api/v1/coredumps/<coredumpId>.
+type Event struct {
+	Source     string    `json:"source"`
+	Type       string    `json:"type"`
+	CoredumpID string    `json:"coredumpId,omitempty"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Instance   string    `json:"instance,omitempty"`
+	Pod        string    `json:"pod,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	ValueScore float64   `json:"valueScore,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+func coredumpEvent(source, eventType string, file *collector.CoredumpFile, errorMessage string, timestamp time.Time) Event {
+	event := Event{Source: source, Type: eventType, Error: errorMessage, Timestamp: timestamp}
+	if file != nil {
+		event.CoredumpID = file.ID
+		event.Namespace = file.PodNamespace
+		event.Instance = file.InstanceName
+		event.Pod = file.PodName
+		event.Status = string(file.Status)
+		event.ValueScore = file.ValueScore
+	}
+	return event
+}
+
+func collectionEvent(event collector.CollectionEvent) Event {
+	result := coredumpEvent(EventSourceCollector, string(event.Type), event.CoredumpFile, event.Error, event.Timestamp)
+	if restart := event.RestartEvent; restart != nil {
+		result.Namespace = restart.PodNamespace
+		result.Instance = restart.InstanceName
+		result.Pod = restart.PodName
+		result.Reason = restart.Reason
+	}
+	return result
+}
+
+func analysisEvent(event analyzer.AnalysisEvent) Event {
+	return coredumpEvent(EventSourceAnalyzer, string(event.Type), event.CoredumpFile, event.Error, event.Timestamp)
+}
+
+func storageEvent(event storage.StorageEvent) Event {
+	return coredumpEvent(EventSourceStorage, string(event.Type), event.CoredumpFile, event.Error, event.Timestamp)
+}
+
+func cleanupEvent(event cleaner.CleanupEvent) Event {
+	return Event{
+		Source:    EventSourceCleaner,
+		Type:      string(event.Type),
+		Namespace: event.Namespace,
+		Instance:  event.InstanceName,
+		Reason:    event.Reason,
+		Error:     event.Error,
+		Timestamp: event.Timestamp,
+	}
+}
+
+// configEvent reports a configuration reload, with the sections whose
+// settings were applied as its reason.
+func configEvent(event reload.Event) Event {
+	return Event{
+		Source:    EventSourceConfig,
+		Type:      string(event.Type),
+		Reason:    strings.Join(event.Changed, ", "),
+		Error:     event.Error,
+		Timestamp: event.Timestamp,
+	}
+}
+
+// eventHub broadcasts events to the /api/v1/events subscribers. Publishing
+// never blocks: a subscriber whose buffer is full misses the event and is
+// told so with a "dropped" count, rather than stalling the store and with it
+// every other consumer of the pipeline.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	closed      bool
+}
+
+type eventSubscriber struct {
+	events  chan Event
+	dropped int
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[*eventSubscriber]struct{})}
+}
+
+// subscribe registers a subscriber. It returns nil once the hub is closed
+// or when there are too many subscribers.
+func (h *eventHub) subscribe() *eventSubscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || len(h.subscribers) >= maxEventSubscribers {
+		return nil
+	}
+	subscriber := &eventSubscriber{events: make(chan Event, eventBuffer)}
+	h.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (h *eventHub) unsubscribe(subscriber *eventSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.subscribers[subscriber]; exists {
+		delete(h.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+func (h *eventHub) publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber.events <- event:
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+// takeDropped returns the events the subscriber missed since the last call.
+func (h *eventHub) takeDropped(subscriber *eventSubscriber) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dropped := subscriber.dropped
+	subscriber.dropped = 0
+	return dropped
+}
+
+// close ends all streams; the API server's shutdown does not cancel them.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for subscriber := range h.subscribers {
+		delete(h.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// GET /api/v1/events?source=collector,storage
+//
+// Streams pipeline events as server-sent events, named after their source.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	sources := make(map[string]bool)
+	if value := r.URL.Query().Get("source"); value != "" {
+		for _, source := range strings.Split(value, ",") {
+			switch source = strings.TrimSpace(source); source {
+			case EventSourceCollector, EventSourceAnalyzer, EventSourceStorage, EventSourceCleaner, EventSourceConfig:
+				sources[source] = true
+			default:
+				writeInvalidParameter(w, r, "source", fmt.Sprintf("unknown event source %q", source))
+				return
+			}
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, r, CodeUnavailable, "streaming is not supported")
+		return
+	}
+	subscriber := s.store.events.subscribe()
+	if subscriber == nil {
+		writeProblem(w, r, CodeUnavailable, "too many event streams")
+		return
+	}
+	defer s.store.events.unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep nginx ingresses from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event, ok := <-subscriber.events:
+			if !ok {
+				return
+			}
+			if dropped := s.store.events.takeDropped(subscriber); dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", dropped)
+			}
+			if len(sources) > 0 && !sources[event.Source] {
+				break
+			}
+			if data, err := json.Marshal(event); err == nil {
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Source, data)
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/reload"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+// readEvent returns the name and data of the next event on the stream,
+// skipping comments.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	store := NewStore(0, 0)
+	collectorEvents := make(chan collector.CollectionEvent)
+	analyzerEvents := make(chan analyzer.AnalysisEvent)
+	storageEvents := make(chan storage.StorageEvent)
+	cleanerEvents := make(chan cleaner.CleanupEvent)
+	configEvents := make(chan reload.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Start(ctx, &Channels{
+		CollectorEvents: collectorEvents,
+		AnalyzerEvents:  analyzerEvents,
+		StorageEvents:   storageEvents,
+		CleanerEvents:   cleanerEvents,
+		ConfigEvents:    configEvents,
+	})
+
+	server := httptest.NewServer(NewServer(store, nil, nil, nil, nil, nil).Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/api/v1/events?source=storage,cleaner,config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %v", resp.StatusCode, resp.Header)
+	}
+	reader := bufio.NewReader(resp.Body)
+	// The connected comment is sent once the stream is subscribed.
+	if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, ": connected") {
+		t.Fatalf("unexpected first line %q", line)
+	}
+
+	file := &collector.CoredumpFile{ID: "a", PodNamespace: "milvus", InstanceName: "prod", Status: collector.StatusStored, ValueScore: 7.5}
+	analyzerEvents <- analyzer.AnalysisEvent{Type: analyzer.EventTypeAnalysisComplete, CoredumpFile: file}
+	storageEvents <- storage.StorageEvent{Type: storage.EventTypeFileStored, CoredumpFile: file}
+	cleanerEvents <- cleaner.CleanupEvent{Type: cleaner.EventTypeInstanceUninstalled, Namespace: "milvus", InstanceName: "prod", Reason: "crash loop"}
+	configEvents <- reload.Event{Type: reload.EventTypeConfigReloaded, Changed: []string{"analyzer", "cleaner"}}
+
+	name, data := readEvent(t, reader)
+	var event Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("failed to decode %q: %v", data, err)
+	}
+	if name != EventSourceStorage || event.Type != "file_stored" || event.CoredumpID != "a" || event.Instance != "prod" || event.ValueScore != 7.5 {
+		t.Errorf("expected the storage event first, got %s %+v", name, event)
+	}
+	name, data = readEvent(t, reader)
+	if name != EventSourceCleaner || !strings.Contains(data, `"reason":"crash loop"`) {
+		t.Errorf("expected the cleaner event, got %s %s", name, data)
+	}
+	name, data = readEvent(t, reader)
+	if name != EventSourceConfig || !strings.Contains(data, `"type":"config_reloaded","reason":"analyzer, cleaner"`) {
+		t.Errorf("expected the config event, got %s %s", name, data)
+	}
+	if _, exists := store.Get("a"); !exists {
+		t.Errorf("expected streamed events to still update the store")
+	}
+
+	// Stopping the store ends the stream.
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		reader.ReadString(0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end with the store")
+	}
+}
+
+func TestEventHubDropsForSlowSubscribers(t *testing.T) {
+	hub := newEventHub()
+	subscriber := hub.subscribe()
+	for i := 0; i < eventBuffer+3; i++ {
+		hub.publish(Event{Source: EventSourceCollector})
+	}
+	if dropped := hub.takeDropped(subscriber); dropped != 3 {
+		t.Errorf("expected 3 dropped events, got %d", dropped)
+	}
+	if dropped := hub.takeDropped(subscriber); dropped != 0 {
+		t.Errorf("expected the dropped count to reset, got %d", dropped)
+	}
+
+	for i := 1; i < maxEventSubscribers; i++ {
+		hub.subscribe()
+	}
+	if hub.subscribe() != nil {
+		t.Errorf("expected subscribers beyond the limit to be refused")
+	}
+	hub.close()
+	if _, ok := <-subscriber.events; !ok {
+		t.Errorf("expected buffered events to remain readable after close")
+	}
+	if hub.subscribe() != nil {
+		t.Errorf("expected a closed hub to refuse subscribers")
+	}
+}
+
+func TestEventStreamRejectsUnknownSource(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events?source=gdb", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
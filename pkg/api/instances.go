@@ -0,0 +1,398 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/crstatus"
+	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+// InstanceSource provides the Milvus instances known to discovery.
+type InstanceSource interface {
+	GetInstances() map[string]*discovery.MilvusInstance
+}
+
+const (
+	HealthHealthy  = "healthy"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+
+	LayerAccess       = "access"
+	LayerCoordination = "coordination"
+	LayerWorker       = "worker"
+	LayerStandalone   = "standalone"
+	LayerDependency   = "dependency"
+	LayerOther        = "other"
+)
+
+// componentLayers places each Milvus component in the layer it is drawn in.
+var componentLayers = map[string]string{
+	"proxy":         LayerAccess,
+	"mixcoord":      LayerCoordination,
+	"rootcoord":     LayerCoordination,
+	"datacoord":     LayerCoordination,
+	"querycoord":    LayerCoordination,
+	"indexcoord":    LayerCoordination,
+	"querynode":     LayerWorker,
+	"datanode":      LayerWorker,
+	"indexnode":     LayerWorker,
+	"streamingnode": LayerWorker,
+	"standalone":    LayerStandalone,
+	"etcd":          LayerDependency,
+	"minio":         LayerDependency,
+	"pulsar":        LayerDependency,
+	"kafka":         LayerDependency,
+}
+
+// layerEdges are the request paths between layers: clients reach the
+// proxies, proxies talk to the coordinators, coordinators drive the workers.
+var layerEdges = [][2]string{
+	{LayerAccess, LayerCoordination},
+	{LayerAccess, LayerWorker},
+	{LayerCoordination, LayerWorker},
+}
+
+type InstanceSummary struct {
+	Name         string                   `json:"name"`
+	Namespace    string                   `json:"namespace"`
+	Type         discovery.DeploymentType `json:"type"`
+	MatchedRule  string                   `json:"matchedRule,omitempty"`
+	Status       discovery.InstanceStatus `json:"status"`
+	Health       string                   `json:"health"`
+	Pods         int                      `json:"pods"`
+	RestartCount int32                    `json:"restartCount"`
+	CrashCount   int                      `json:"crashCount"`
+	TerminatedAt *time.Time               `json:"terminatedAt,omitempty"`
+	Cleanup      *discovery.Cleanup       `json:"cleanup,omitempty"`
+}
+
+// InstanceDetail is an instance with its component topology, the data behind
+// the instance detail view.
+type InstanceDetail struct {
+	InstanceSummary
+	MilvusVersion string          `json:"milvusVersion,omitempty"`
+	Components    []ComponentNode `json:"components"`
+	Edges         []TopologyEdge  `json:"edges"`
+	// The Milvus CR of operator deployments, when CRs are tracked
+	MilvusCR *MilvusCRState `json:"milvusCR,omitempty"`
+}
+
+// ResourceSource provides the state of Milvus CRs. A ConditionSource that
+// implements it adds the CR to the instance detail.
+type ResourceSource interface {
+	Resource(namespace, name string) *crstatus.Resource
+}
+
+// MilvusCRState sets what an instance's Milvus CR asks for against the pods
+// discovery found.
+type MilvusCRState struct {
+	*crstatus.Resource
+	Components []ComponentReplicas `json:"components"`
+	// The operator reconciled the latest spec, rolled out its image, and
+	// every component runs the desired number of ready pods
+	InSync bool `json:"inSync"`
+}
+
+type ComponentReplicas struct {
+	Component string `json:"component"`
+	Desired   int64  `json:"desired"`
+	Pods      int    `json:"pods"`
+	Ready     int    `json:"ready"`
+}
+
+type ComponentNode struct {
+	Component    string    `json:"component"`
+	Layer        string    `json:"layer"`
+	Health       string    `json:"health"`
+	RestartCount int32     `json:"restartCount"`
+	CrashCount   int       `json:"crashCount"`
+	Pods         []PodNode `json:"pods"`
+}
+
+type PodNode struct {
+	Name         string        `json:"name"`
+	Status       string        `json:"status"`
+	Ready        bool          `json:"ready"`
+	Health       string        `json:"health"`
+	RestartCount int32         `json:"restartCount"`
+	LastRestart  *time.Time    `json:"lastRestart,omitempty"`
+	Crashes      []CrashMarker `json:"crashes"`
+}
+
+// CrashMarker points at a coredump of the pod, newest first.
+type CrashMarker struct {
+	CoredumpID string    `json:"coredumpId"`
+	Signal     int       `json:"signal"`
+	Status     string    `json:"status"`
+	ValueScore float64   `json:"valueScore"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type TopologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GET /api/v1/instances
+// GET /api/v1/instances?includeTerminated=true
+//
+// Instances whose pods are all gone are left out unless includeTerminated
+// is set; discovery keeps them for an hour after termination.
+func (s *Server) handleListInstances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.instances == nil {
+		writeProblem(w, r, CodeUnavailable, "instance discovery is not available")
+		return
+	}
+	includeTerminated := false
+	if value := r.URL.Query().Get("includeTerminated"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "includeTerminated", "includeTerminated must be true or false")
+			return
+		}
+		includeTerminated = include
+	}
+
+	crashes := s.cachedCrashes()
+
+	items := []InstanceSummary{}
+	for _, instance := range s.instances.GetInstances() {
+		if instance.Status == discovery.InstanceStatusTerminated && !includeTerminated {
+			continue
+		}
+		items = append(items, buildInstanceDetail(instance, crashes).InstanceSummary)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// GET /api/v1/instances/<namespace>/<name>
+// GET /api/v1/instances/<namespace>/<name>/timeline
+func (s *Server) handleGetInstance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/api/v1/instances/")
+	if instance, found := strings.CutSuffix(key, "/timeline"); found {
+		namespace, name, found := strings.Cut(instance, "/")
+		if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+			writeProblem(w, r, CodeNotFound, "")
+			return
+		}
+		s.handleInstanceTimeline(w, r, namespace, name)
+		return
+	}
+
+	if s.instances == nil {
+		writeProblem(w, r, CodeUnavailable, "instance discovery is not available")
+		return
+	}
+	namespace, name, found := strings.Cut(key, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		writeProblem(w, r, CodeNotFound, "")
+		return
+	}
+
+	instance, exists := s.instances.GetInstances()[key]
+	if !exists {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("instance %s/%s not found", namespace, name))
+		return
+	}
+
+	detail := buildInstanceDetail(instance, s.cachedCrashes())
+	if resources, ok := s.conditions.(ResourceSource); ok {
+		if resource := resources.Resource(namespace, name); resource != nil {
+			detail.MilvusCR = compareMilvusCR(resource, detail.Components)
+		}
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func compareMilvusCR(resource *crstatus.Resource, components []ComponentNode) *MilvusCRState {
+	state := &MilvusCRState{Resource: resource, Components: []ComponentReplicas{}}
+	state.InSync = resource.ObservedGeneration >= resource.Generation &&
+		(resource.CurrentImage == "" || resource.CurrentImage == resource.Image)
+
+	found := make(map[string]ComponentNode, len(components))
+	for _, component := range components {
+		found[component.Component] = component
+	}
+	for name, desired := range resource.Replicas {
+		replicas := ComponentReplicas{Component: name, Desired: desired}
+		for _, pod := range found[name].Pods {
+			replicas.Pods++
+			if pod.Ready {
+				replicas.Ready++
+			}
+		}
+		if int64(replicas.Pods) != desired || int64(replicas.Ready) != desired {
+			state.InSync = false
+		}
+		state.Components = append(state.Components, replicas)
+	}
+	sort.Slice(state.Components, func(i, j int) bool {
+		return state.Components[i].Component < state.Components[j].Component
+	})
+	return state
+}
+
+func buildInstanceDetail(instance *discovery.MilvusInstance, crashes map[string][]CrashMarker) *InstanceDetail {
+	detail := &InstanceDetail{
+		InstanceSummary: InstanceSummary{
+			Name:        instance.Name,
+			Namespace:   instance.Namespace,
+			Type:        instance.Type,
+			MatchedRule: instance.MatchedRule,
+			Status:      instance.Status,
+			Health:      HealthHealthy,
+			Pods:        len(instance.Pods),
+			Cleanup:     instance.Cleanup,
+		},
+		Components: []ComponentNode{},
+		Edges:      []TopologyEdge{},
+	}
+	if instance.TerminatedAt != nil {
+		terminatedAt := instance.TerminatedAt.Time
+		detail.TerminatedAt = &terminatedAt
+	}
+
+	components := make(map[string]*ComponentNode)
+	for _, pod := range instance.Pods {
+		if detail.MilvusVersion == "" {
+			detail.MilvusVersion = pod.MilvusVersion
+		}
+
+		node := buildPodNode(pod, crashes[pod.Namespace+"/"+pod.Name])
+
+		name := pod.Component
+		if name == "" {
+			name = "unknown"
+		}
+		component, exists := components[name]
+		if !exists {
+			layer, known := componentLayers[name]
+			if !known {
+				layer = LayerOther
+			}
+			component = &ComponentNode{Component: name, Layer: layer, Health: HealthHealthy}
+			components[name] = component
+		}
+		component.Pods = append(component.Pods, node)
+		component.RestartCount += node.RestartCount
+		component.CrashCount += len(node.Crashes)
+		component.Health = worseHealth(component.Health, node.Health)
+	}
+
+	layers := make(map[string][]string)
+	for _, component := range components {
+		sort.Slice(component.Pods, func(i, j int) bool { return component.Pods[i].Name < component.Pods[j].Name })
+		detail.Components = append(detail.Components, *component)
+		detail.RestartCount += component.RestartCount
+		detail.CrashCount += component.CrashCount
+		detail.Health = worseHealth(detail.Health, component.Health)
+		layers[component.Layer] = append(layers[component.Layer], component.Component)
+	}
+	sort.Slice(detail.Components, func(i, j int) bool {
+		return detail.Components[i].Component < detail.Components[j].Component
+	})
+
+	for _, edge := range layerEdges {
+		from, to := layers[edge[0]], layers[edge[1]]
+		sort.Strings(from)
+		sort.Strings(to)
+		for _, f := range from {
+			for _, t := range to {
+				detail.Edges = append(detail.Edges, TopologyEdge{From: f, To: t})
+			}
+		}
+	}
+
+	return detail
+}
+
+func buildPodNode(pod discovery.PodInfo, crashes []CrashMarker) PodNode {
+	ready := len(pod.ContainerStatuses) > 0
+	for _, container := range pod.ContainerStatuses {
+		ready = ready && container.Ready
+	}
+
+	node := PodNode{
+		Name:         pod.Name,
+		Status:       pod.Status,
+		Ready:        ready,
+		RestartCount: pod.RestartCount,
+		Crashes:      crashes,
+	}
+	if node.Crashes == nil {
+		node.Crashes = []CrashMarker{}
+	}
+	if !pod.LastRestart.IsZero() {
+		lastRestart := pod.LastRestart.Time
+		node.LastRestart = &lastRestart
+	}
+
+	switch {
+	case pod.Status != "Running" || !ready:
+		node.Health = HealthCritical
+	case pod.RestartCount > 0 || len(crashes) > 0:
+		node.Health = HealthWarning
+	default:
+		node.Health = HealthHealthy
+	}
+	return node
+}
+
+// cachedCrashes is crashesByPod over all records, cached until the next write.
+func (s *Server) cachedCrashes() map[string][]CrashMarker {
+	return statscache.Get(s.store.stats, "crashesByPod", func() map[string][]CrashMarker {
+		return crashesByPod(s.store.Records())
+	})
+}
+
+func crashesByPod(records []*collector.CoredumpFile) map[string][]CrashMarker {
+	crashes := make(map[string][]CrashMarker)
+	for _, record := range records {
+		if record.PodName == "" {
+			continue
+		}
+		key := record.PodNamespace + "/" + record.PodName
+		crashes[key] = append(crashes[key], CrashMarker{
+			CoredumpID: record.ID,
+			Signal:     record.Signal,
+			Status:     string(record.Status),
+			ValueScore: record.ValueScore,
+			CreatedAt:  record.CreatedAt.Time,
+		})
+	}
+	for _, markers := range crashes {
+		sort.Slice(markers, func(i, j int) bool { return markers[i].CreatedAt.After(markers[j].CreatedAt) })
+	}
+	return crashes
+}
+
+func worseHealth(a, b string) string {
+	rank := map[string]int{HealthHealthy: 0, HealthWarning: 1, HealthCritical: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/crstatus"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+type staticInstances map[string]*discovery.MilvusInstance
+
+func (s staticInstances) GetInstances() map[string]*discovery.MilvusInstance {
+	return s
+}
+
+func testPod(name, component string, ready bool, restarts int32) discovery.PodInfo {
+	return discovery.PodInfo{
+		Name:              name,
+		Namespace:         "milvus",
+		Status:            "Running",
+		RestartCount:      restarts,
+		Component:         component,
+		MilvusVersion:     "v2.4.1",
+		ContainerStatuses: []discovery.ContainerStatusInfo{{Name: "milvus", Ready: ready}},
+	}
+}
+
+func TestGetInstanceTopology(t *testing.T) {
+	instances := staticInstances{
+		"milvus/prod": {
+			Name:      "prod",
+			Namespace: "milvus",
+			Type:      discovery.DeploymentTypeHelm,
+			Status:    discovery.InstanceStatusRunning,
+			Pods: []discovery.PodInfo{
+				testPod("prod-proxy-0", "proxy", true, 0),
+				testPod("prod-mixcoord-0", "mixcoord", true, 0),
+				testPod("prod-querynode-1", "querynode", true, 3),
+				testPod("prod-querynode-0", "querynode", false, 5),
+				testPod("prod-datanode-0", "datanode", true, 0),
+			},
+		},
+	}
+
+	store := NewStore(0, 0)
+	base := time.Now()
+	for i, id := range []string{"core-old", "core-new"} {
+		store.upsert(&collector.CoredumpFile{
+			ID:           id,
+			PodNamespace: "milvus",
+			PodName:      "prod-querynode-1",
+			Signal:       11,
+			CreatedAt:    metav1.NewTime(base.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+
+	server := NewServer(store, instances, nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/instances/milvus/prod", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var detail InstanceDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if detail.Health != HealthCritical || detail.RestartCount != 8 || detail.CrashCount != 2 {
+		t.Errorf("unexpected instance summary: health %s, restarts %d, crashes %d",
+			detail.Health, detail.RestartCount, detail.CrashCount)
+	}
+	if detail.MilvusVersion != "v2.4.1" {
+		t.Errorf("expected milvus version v2.4.1, got %s", detail.MilvusVersion)
+	}
+
+	components := make(map[string]ComponentNode)
+	for _, component := range detail.Components {
+		components[component.Component] = component
+	}
+	if got := components["proxy"]; got.Layer != LayerAccess || got.Health != HealthHealthy {
+		t.Errorf("unexpected proxy node: %+v", got)
+	}
+	querynode := components["querynode"]
+	if querynode.Layer != LayerWorker || querynode.Health != HealthCritical || len(querynode.Pods) != 2 {
+		t.Fatalf("unexpected querynode node: %+v", querynode)
+	}
+	crashed := querynode.Pods[1]
+	if crashed.Name != "prod-querynode-1" || crashed.Health != HealthWarning {
+		t.Errorf("unexpected crashed pod: %+v", crashed)
+	}
+	if len(crashed.Crashes) != 2 || crashed.Crashes[0].CoredumpID != "core-new" {
+		t.Errorf("expected crash markers newest first, got %+v", crashed.Crashes)
+	}
+
+	edges := make(map[TopologyEdge]bool)
+	for _, edge := range detail.Edges {
+		edges[edge] = true
+	}
+	for _, want := range []TopologyEdge{
+		{From: "proxy", To: "mixcoord"},
+		{From: "proxy", To: "querynode"},
+		{From: "mixcoord", To: "datanode"},
+	} {
+		if !edges[want] {
+			t.Errorf("missing edge %s -> %s", want.From, want.To)
+		}
+	}
+}
+
+func TestGetInstanceNotFound(t *testing.T) {
+	server := NewServer(NewStore(0, 0), staticInstances{}, nil, nil, nil, nil)
+
+	for _, path := range []string{"/api/v1/instances/milvus/missing", "/api/v1/instances/milvus"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestListInstancesHidesTerminated(t *testing.T) {
+	terminatedAt := metav1.Now()
+	instances := staticInstances{
+		"milvus/prod": {Name: "prod", Namespace: "milvus", Status: discovery.InstanceStatusRunning},
+		"milvus/old": {
+			Name:         "old",
+			Namespace:    "milvus",
+			Status:       discovery.InstanceStatusTerminated,
+			TerminatedAt: &terminatedAt,
+			Cleanup:      &discovery.Cleanup{Reason: "crash loop", At: terminatedAt},
+		},
+	}
+	server := NewServer(NewStore(0, 0), instances, nil, nil, nil, nil)
+
+	list := func(query string) []InstanceSummary {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/instances"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", query, rec.Code)
+		}
+		var body struct {
+			Items []InstanceSummary `json:"items"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body.Items
+	}
+
+	if items := list(""); len(items) != 1 || items[0].Name != "prod" {
+		t.Errorf("expected only the running instance, got %+v", items)
+	}
+	items := list("?includeTerminated=true")
+	if len(items) != 2 || items[0].Name != "old" {
+		t.Fatalf("expected both instances, got %+v", items)
+	}
+	if items[0].TerminatedAt == nil || items[0].Cleanup == nil || items[0].Cleanup.Reason != "crash loop" {
+		t.Errorf("expected the termination linked to its cleanup, got %+v", items[0])
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/instances?includeTerminated=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid includeTerminated, got %d", rec.Code)
+	}
+}
+
+type staticConditions []crstatus.Transition
+
+func (s staticConditions) Transitions(namespace, instance string) []crstatus.Transition {
+	return s
+}
+
+type staticResources map[string]*crstatus.Resource
+
+func (s staticResources) Transitions(namespace, instance string) []crstatus.Transition {
+	return nil
+}
+
+func (s staticResources) Resource(namespace, name string) *crstatus.Resource {
+	return s[namespace+"/"+name]
+}
+
+func TestGetInstanceComparesMilvusCR(t *testing.T) {
+	instances := staticInstances{
+		"milvus/prod": {
+			Name:      "prod",
+			Namespace: "milvus",
+			Type:      discovery.DeploymentTypeOperator,
+			Status:    discovery.InstanceStatusRunning,
+			Pods: []discovery.PodInfo{
+				testPod("prod-milvus-proxy-0", "proxy", true, 0),
+				testPod("prod-milvus-querynode-0", "querynode", true, 0),
+				testPod("prod-milvus-querynode-1", "querynode", false, 2),
+			},
+		},
+		"milvus/staging": {Name: "staging", Namespace: "milvus", Pods: []discovery.PodInfo{}},
+	}
+	resources := staticResources{
+		"milvus/prod": {
+			Namespace: "milvus", Name: "prod", Status: "Unhealthy", Image: "milvusdb/milvus:v2.4.5", Version: "v2.4.5",
+			Replicas: map[string]int64{"proxy": 1, "querynode": 3}, Generation: 2, ObservedGeneration: 2,
+		},
+	}
+	server := NewServer(NewStore(0, 0), instances, nil, nil, resources, nil)
+
+	get := func(path string) *InstanceDetail {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var detail InstanceDetail
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &detail) != nil {
+			t.Fatalf("expected an instance detail, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return &detail
+	}
+
+	detail := get("/api/v1/instances/milvus/prod")
+	cr := detail.MilvusCR
+	if cr == nil || cr.Version != "v2.4.5" || cr.InSync || len(cr.Components) != 2 {
+		t.Fatalf("expected an out-of-sync CR with 2 components, got %+v", cr)
+	}
+	if proxy := cr.Components[0]; proxy.Component != "proxy" || proxy.Desired != 1 || proxy.Ready != 1 {
+		t.Errorf("expected the proxy at its desired replicas, got %+v", proxy)
+	}
+	if queryNode := cr.Components[1]; queryNode.Desired != 3 || queryNode.Pods != 2 || queryNode.Ready != 1 {
+		t.Errorf("expected 1 of 3 query nodes ready, got %+v", queryNode)
+	}
+
+	if detail := get("/api/v1/instances/milvus/staging"); detail.MilvusCR != nil {
+		t.Errorf("expected no CR for an instance without one, got %+v", detail.MilvusCR)
+	}
+}
+
+func TestInstanceTimeline(t *testing.T) {
+	base := time.Now().Truncate(time.Second)
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "core-1", PodNamespace: "milvus", PodName: "prod-querynode-0",
+		InstanceName: "prod", Signal: 11, Timestamp: base.Add(2 * time.Minute)})
+	store.upsert(&collector.CoredumpFile{ID: "core-other", PodNamespace: "milvus", InstanceName: "staging",
+		Timestamp: base.Add(time.Minute)})
+	conditions := staticConditions{
+		{Namespace: "milvus", Instance: "prod", Condition: "MilvusUpdated", Status: "False", Reason: "UpdateFailed", Time: base},
+	}
+
+	rec := httptest.NewRecorder()
+	NewServer(store, nil, nil, nil, conditions, nil).Handler().ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/api/v1/instances/milvus/prod/timeline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Items []TimelineEntry `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Items) != 2 {
+		t.Fatalf("expected 2 timeline entries, got %+v", response.Items)
+	}
+	if first := response.Items[0]; first.Kind != TimelineCondition || first.Condition.Reason != "UpdateFailed" {
+		t.Errorf("expected the condition transition first, got %+v", first)
+	}
+	if second := response.Items[1]; second.Kind != TimelineCrash || second.Crash.CoredumpID != "core-1" || second.Pod != "prod-querynode-0" {
+		t.Errorf("expected the crash second, got %+v", second)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(store, nil, nil, nil, nil, nil).Handler().ServeHTTP(rec,
+		httptest.NewRequest(http.MethodGet, "/api/v1/instances/milvus/timeline", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a timeline without instance, got %d", rec.Code)
+	}
+}
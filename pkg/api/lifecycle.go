@@ -0,0 +1,121 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+// LifecycleStats is how long the coredumps of a window took through each
+// stage of the pipeline.
+type LifecycleStats struct {
+	Window string       `json:"window"`
+	Since  time.Time    `json:"since"`
+	Until  time.Time    `json:"until"`
+	Stages []StageStats `json:"stages"`
+}
+
+// StageStats summarizes the durations of one stage, in seconds. Breaches
+// counts the cores that took longer than the target.
+type StageStats struct {
+	Stage         collector.LifecycleStage `json:"stage"`
+	Count         int                      `json:"count"`
+	P50Seconds    float64                  `json:"p50Seconds"`
+	P90Seconds    float64                  `json:"p90Seconds"`
+	P99Seconds    float64                  `json:"p99Seconds"`
+	MaxSeconds    float64                  `json:"maxSeconds"`
+	TargetSeconds float64                  `json:"targetSeconds,omitempty"`
+	Breaches      int                      `json:"breaches"`
+}
+
+// HandleLifecycleStats mounts the pipeline latency statistics, judged
+// against the SLA targets of sla.
+func (s *Server) HandleLifecycleStats(sla *config.LifecycleSLAConfig) {
+	s.mux.HandleFunc("/api/v1/stats/lifecycle", func(w http.ResponseWriter, r *http.Request) {
+		s.handleLifecycleStats(w, r, sla)
+	})
+}
+
+// GET /api/v1/stats/lifecycle?window=24h
+func (s *Server) handleLifecycleStats(w http.ResponseWriter, r *http.Request, sla *config.LifecycleSLAConfig) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	window := defaultBreakdownWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "window", err.Error())
+			return
+		}
+		window = parsed
+	}
+
+	stats := statscache.Get(s.store.stats, "lifecycle/"+window.String(), func() *LifecycleStats {
+		until := time.Now()
+		return computeLifecycleStats(s.store.Records(), until.Add(-window), until, window, sla)
+	})
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func computeLifecycleStats(records []*collector.CoredumpFile, since, until time.Time, window time.Duration, sla *config.LifecycleSLAConfig) *LifecycleStats {
+	durations := map[collector.LifecycleStage][]time.Duration{}
+	for _, record := range records {
+		if record.Timestamp.Before(since) || record.Timestamp.After(until) {
+			continue
+		}
+		for _, stage := range collector.LifecycleStages {
+			if duration, ok := record.StageDuration(stage); ok {
+				durations[stage] = append(durations[stage], duration)
+			}
+		}
+	}
+
+	stats := &LifecycleStats{
+		Window: formatWindow(window),
+		Since:  since,
+		Until:  until,
+		Stages: make([]StageStats, 0, len(collector.LifecycleStages)),
+	}
+	for _, stage := range collector.LifecycleStages {
+		sorted := durations[stage]
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		target := collector.SLATarget(sla, stage)
+
+		stageStats := StageStats{
+			Stage:         stage,
+			Count:         len(sorted),
+			P50Seconds:    percentile(sorted, 50),
+			P90Seconds:    percentile(sorted, 90),
+			P99Seconds:    percentile(sorted, 99),
+			MaxSeconds:    percentile(sorted, 100),
+			TargetSeconds: target.Seconds(),
+		}
+		if target > 0 {
+			// The durations are sorted, so the breaches are the tail.
+			stageStats.Breaches = len(sorted) - sort.Search(len(sorted), func(i int) bool { return sorted[i] > target })
+		}
+		stats.Stages = append(stats.Stages, stageStats)
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted durations in
+// seconds, or 0 when there are none.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Seconds()
+}
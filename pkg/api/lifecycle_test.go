@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestLifecycleStats(t *testing.T) {
+	store := NewStore(0, 0)
+	crashed := time.Now().Add(-time.Hour)
+
+	for i, analysis := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 20 * time.Minute} {
+		record := &collector.CoredumpFile{
+			ID:           string(rune('a' + i)),
+			Timestamp:    crashed,
+			CreatedAt:    metav1.NewTime(crashed.Add(10 * time.Second)),
+			AnalysisTime: crashed.Add(10*time.Second + analysis),
+			Status:       collector.StatusAnalyzed,
+		}
+		if i == 0 {
+			record.Status = collector.StatusStored
+			record.UpdatedAt = metav1.NewTime(record.AnalysisTime.Add(5 * time.Second))
+		}
+		store.upsert(record)
+	}
+	// Still being analyzed
+	store.upsert(&collector.CoredumpFile{ID: "e", Timestamp: crashed, CreatedAt: metav1.NewTime(crashed.Add(time.Minute))})
+	// Outside the window
+	store.upsert(&collector.CoredumpFile{ID: "f", Timestamp: crashed.Add(-48 * time.Hour),
+		CreatedAt: metav1.NewTime(crashed.Add(-47 * time.Hour))})
+
+	server := NewServer(store, nil, nil, nil, nil, nil)
+	server.HandleLifecycleStats(&config.LifecycleSLAConfig{DiscoveredToAnalyzed: 5 * time.Minute})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/lifecycle", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var stats LifecycleStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(stats.Stages) != 3 {
+		t.Fatalf("expected all three stages, got %+v", stats.Stages)
+	}
+
+	discovery, analysis, storage := stats.Stages[0], stats.Stages[1], stats.Stages[2]
+	if discovery.Stage != collector.StageCrashToDiscovered || discovery.Count != 5 || discovery.P50Seconds != 10 || discovery.MaxSeconds != 60 {
+		t.Errorf("unexpected discovery stats %+v", discovery)
+	}
+	if analysis.Count != 4 || analysis.P50Seconds != 120 || analysis.P90Seconds != 1200 || analysis.MaxSeconds != 1200 {
+		t.Errorf("unexpected analysis stats %+v", analysis)
+	}
+	if analysis.TargetSeconds != 300 || analysis.Breaches != 1 {
+		t.Errorf("expected one core over the 5m target, got %+v", analysis)
+	}
+	if storage.Count != 1 || storage.P99Seconds != 5 || storage.TargetSeconds != 0 || storage.Breaches != 0 {
+		t.Errorf("unexpected storage stats %+v", storage)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/lifecycle?window=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid window, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+const (
+	defaultNodeRateLimit = 5
+	defaultNodeBurst     = 10
+)
+
+// NodeCoredump is the subset of a coredump record exposed to node-local
+// tools. Paths, stack traces and AI output stay on the full API.
+type NodeCoredump struct {
+	ID           string               `json:"id"`
+	FileName     string               `json:"fileName"`
+	Status       collector.FileStatus `json:"status"`
+	Signal       int                  `json:"signal"`
+	PodName      string               `json:"podName,omitempty"`
+	PodNamespace string               `json:"podNamespace,omitempty"`
+	InstanceName string               `json:"instanceName,omitempty"`
+	Component    string               `json:"component,omitempty"`
+	ValueScore   float64              `json:"valueScore"`
+	CrashReason  string               `json:"crashReason,omitempty"`
+	CreatedAt    time.Time            `json:"createdAt"`
+	UpdatedAt    time.Time            `json:"updatedAt"`
+}
+
+// NodeHealth is the agent's view of its own health on this node.
+type NodeHealth struct {
+	NodeName       string                       `json:"nodeName"`
+	Ready          bool                         `json:"ready"`
+	Degraded       bool                         `json:"degraded"`
+	DegradedReason string                       `json:"degradedReason,omitempty"`
+	DroppingEvents bool                         `json:"droppingEvents"`
+	Coredumps      map[collector.FileStatus]int `json:"coredumps"`
+	CheckedAt      time.Time                    `json:"checkedAt"`
+}
+
+// NodeHealthFunc reports the agent's health; the API fills in the coredump
+// counts.
+type NodeHealthFunc func() NodeHealth
+
+// NodeAPI serves /api/v1/node/: a minimal, read-only view of the cores on
+// this node for other node-local tooling such as a node-problem-detector
+// plugin. Unlike the rest of the API it requires a bearer token and is rate
+// limited, since it is meant to be polled by processes outside the cluster's
+// network policies.
+type NodeAPI struct {
+	store   *Store
+	token   []byte
+	health  NodeHealthFunc
+	limiter *rateLimiter
+}
+
+// NewNodeAPI reads the access token from config.TokenFile.
+func NewNodeAPI(store *Store, config *config.NodeAPIConfig, health NodeHealthFunc) (*NodeAPI, error) {
+	data, err := os.ReadFile(config.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node API token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("node API token file %s is empty", config.TokenFile)
+	}
+
+	rate := config.RateLimit
+	if rate <= 0 {
+		rate = defaultNodeRateLimit
+	}
+	burst := config.Burst
+	if burst <= 0 {
+		burst = defaultNodeBurst
+	}
+
+	return &NodeAPI{
+		store:   store,
+		token:   []byte(token),
+		health:  health,
+		limiter: newRateLimiter(rate, burst),
+	}, nil
+}
+
+// HandleNode mounts the node API on the server.
+func (s *Server) HandleNode(node *NodeAPI) {
+	s.mux.Handle("/api/v1/node/", node)
+}
+
+func (n *NodeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wait, ok := n.limiter.allow(time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeProblem(w, r, CodeRateLimited, "")
+		return
+	}
+	if !n.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="milvus-coredump-agent"`)
+		writeProblem(w, r, CodeUnauthorized, "")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v1/node/coredumps":
+		n.handleCoredumps(w, r)
+	case "/api/v1/node/health":
+		n.handleHealth(w, r)
+	default:
+		writeProblem(w, r, CodeNotFound, "")
+	}
+}
+
+func (n *NodeAPI) authorized(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), n.token) == 1
+}
+
+// handleCoredumps lists the cores on this node, newest first. ?status=
+// restricts the list to one status.
+func (n *NodeAPI) handleCoredumps(w http.ResponseWriter, r *http.Request) {
+	status := collector.FileStatus(r.URL.Query().Get("status"))
+
+	records := n.store.Records()
+	items := make([]NodeCoredump, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		current := nodeStatus(record)
+		if status != "" && current != status {
+			continue
+		}
+		item := NodeCoredump{
+			ID:           record.ID,
+			FileName:     record.FileName,
+			Status:       current,
+			Signal:       record.Signal,
+			PodName:      record.PodName,
+			PodNamespace: record.PodNamespace,
+			InstanceName: record.InstanceName,
+			Component:    record.Component,
+			ValueScore:   record.ValueScore,
+			CreatedAt:    record.CreatedAt.Time,
+			UpdatedAt:    record.UpdatedAt.Time,
+		}
+		if record.AnalysisResults != nil {
+			item.CrashReason = record.AnalysisResults.CrashReason
+		}
+		items = append(items, item)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (n *NodeAPI) handleHealth(w http.ResponseWriter, r *http.Request) {
+	var health NodeHealth
+	if n.health != nil {
+		health = n.health()
+	}
+	health.Coredumps = statscache.Get(n.store.stats, "statusCounts", func() map[collector.FileStatus]int {
+		counts := make(map[collector.FileStatus]int)
+		for _, record := range n.store.Records() {
+			counts[nodeStatus(record)]++
+		}
+		return counts
+	})
+	if health.CheckedAt.IsZero() {
+		health.CheckedAt = time.Now()
+	}
+
+	writeJSON(w, http.StatusOK, health)
+}
+
+func nodeStatus(record *collector.CoredumpFile) collector.FileStatus {
+	if record.Status == "" {
+		return collector.StatusDiscovered
+	}
+	return record.Status
+}
+
+// rateLimiter is a token bucket shared by all callers of the node API.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token if one is available, or returns how long until one is.
+func (l *rateLimiter) allow(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+	}
+	l.tokens--
+	return 0, true
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func newTestNodeAPI(t *testing.T, store *Store, cfg config.NodeAPIConfig) *Server {
+	t.Helper()
+	cfg.TokenFile = filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(cfg.TokenFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	node, err := NewNodeAPI(store, &cfg, func() NodeHealth {
+		return NodeHealth{NodeName: "node-1", Ready: true}
+	})
+	if err != nil {
+		t.Fatalf("NewNodeAPI failed: %v", err)
+	}
+	server := NewServer(store, nil, nil, nil, nil, nil)
+	server.HandleNode(node)
+	return server
+}
+
+func nodeRequest(server *Server, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNodeAPIRequiresToken(t *testing.T) {
+	server := newTestNodeAPI(t, NewStore(0, 0), config.NodeAPIConfig{})
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := nodeRequest(server, "/api/v1/node/health", token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+	if rec := nodeRequest(server, "/api/v1/node/health", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with valid token, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNodeAPICoredumps(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "core-1", Path: "/var/lib/core-1", Status: collector.StatusStored,
+		AnalysisResults: &collector.AnalysisResults{CrashReason: "SIGSEGV", StackTrace: "#0 main"}})
+	store.upsert(&collector.CoredumpFile{ID: "core-2"})
+	server := newTestNodeAPI(t, store, config.NodeAPIConfig{})
+
+	rec := nodeRequest(server, "/api/v1/node/coredumps", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0]["id"] != "core-2" || list.Items[0]["status"] != "discovered" {
+		t.Fatalf("unexpected items: %v", list.Items)
+	}
+	if _, leaked := list.Items[1]["path"]; leaked {
+		t.Error("node API must not expose core paths")
+	}
+	if list.Items[1]["crashReason"] != "SIGSEGV" {
+		t.Errorf("expected crash reason, got %v", list.Items[1]["crashReason"])
+	}
+
+	rec = nodeRequest(server, "/api/v1/node/coredumps?status=stored", "secret")
+	list.Items = nil
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Items) != 1 || list.Items[0]["id"] != "core-1" {
+		t.Errorf("expected only core-1 for status=stored, got %v", list.Items)
+	}
+
+	rec = nodeRequest(server, "/api/v1/node/health", "secret")
+	var health NodeHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("failed to decode health: %v", err)
+	}
+	if health.NodeName != "node-1" || !health.Ready {
+		t.Errorf("unexpected health: %+v", health)
+	}
+	if health.Coredumps[collector.StatusStored] != 1 || health.Coredumps[collector.StatusDiscovered] != 1 {
+		t.Errorf("unexpected coredump counts: %v", health.Coredumps)
+	}
+}
+
+func TestNodeAPIRateLimit(t *testing.T) {
+	server := newTestNodeAPI(t, NewStore(0, 0), config.NodeAPIConfig{RateLimit: 0.001, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if rec := nodeRequest(server, "/api/v1/node/health", "secret"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := nodeRequest(server, "/api/v1/node/health", "secret")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := newRateLimiter(2, 1)
+	now := time.Now()
+
+	if _, ok := limiter.allow(now); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	wait, ok := limiter.allow(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got %v (allowed %v)", wait, ok)
+	}
+	if _, ok := limiter.allow(now.Add(500 * time.Millisecond)); !ok {
+		t.Error("expected a token after 500ms")
+	}
+}
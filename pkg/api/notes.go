@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/notes"
+)
+
+// NoteRequest creates a note, or edits one when Version is the version of
+// the note the edit was made from.
+type NoteRequest struct {
+	Author  string `json:"author"`
+	Body    string `json:"body"`
+	Version int    `json:"version"`
+}
+
+// HandleNotes enables notes on coredumps and crash groups, kept in book.
+func (s *Server) HandleNotes(book *notes.Book) {
+	s.notes = book
+}
+
+// notesPath splits "<id>/notes" and "<id>/notes/<note>" into the id and
+// what follows "notes".
+func notesPath(path string) (id, rest string, ok bool) {
+	id, rest, found := strings.Cut(path, "/")
+	if !found || id == "" {
+		return "", "", false
+	}
+	rest, found = strings.CutPrefix(rest, "notes")
+	if !found || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", "", false
+	}
+	return id, strings.TrimPrefix(rest, "/"), true
+}
+
+// GET|POST /api/v1/coredumps/<id>/notes
+// GET|PUT /api/v1/coredumps/<id>/notes/<note>
+// and the same under /api/v1/crash-groups/<fingerprint>
+//
+// Lists, adds and edits the markdown notes engineers keep on a coredump or
+// crash group. Notes can only be added while the coredump or group is known,
+// but stay listed after it's gone. Edits keep the earlier text in the
+// note's history and must name the version they were made from.
+func (s *Server) handleNotes(w http.ResponseWriter, r *http.Request, subject notes.Subject, exists func() bool, noteID string) {
+	if s.notes == nil {
+		writeProblem(w, r, CodeUnavailable, "notes are not enabled")
+		return
+	}
+
+	if noteID == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"items": s.notes.List(subject)})
+		case http.MethodPost:
+			request, ok := readNoteRequest(w, r)
+			if !ok {
+				return
+			}
+			if !exists() {
+				writeProblem(w, r, CodeNotFound, fmt.Sprintf("%s %s not found", subject.Kind, subject.ID))
+				return
+			}
+			note, err := s.notes.Add(subject, request.Author, request.Body, time.Now())
+			if err != nil {
+				writeNoteError(w, r, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, note)
+		default:
+			writeMethodNotAllowed(w, r, "GET, POST")
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(noteID)
+	if err != nil {
+		writeProblem(w, r, CodeNotFound, "")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		for _, note := range s.notes.List(subject) {
+			if note.ID == id {
+				writeJSON(w, http.StatusOK, note)
+				return
+			}
+		}
+		writeNoteError(w, r, notes.ErrNotFound)
+	case http.MethodPut:
+		request, ok := readNoteRequest(w, r)
+		if !ok {
+			return
+		}
+		if request.Version <= 0 {
+			writeProblem(w, r, CodeInvalidBody, "version must name the version of the note being edited")
+			return
+		}
+		note, err := s.notes.Edit(subject, id, request.Version, request.Author, request.Body, time.Now())
+		if err != nil {
+			writeNoteError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, note)
+	default:
+		writeMethodNotAllowed(w, r, "GET, PUT")
+	}
+}
+
+func readNoteRequest(w http.ResponseWriter, r *http.Request) (*NoteRequest, bool) {
+	// Leave room for the JSON escaping of a body at the size limit.
+	limit := int64(2*notes.MaxBodySize + notes.MaxAuthorSize + 1024)
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		writeProblem(w, r, CodeInvalidBody, "failed to read the request body")
+		return nil, false
+	}
+	if int64(len(body)) > limit {
+		writeProblem(w, r, CodeInvalidBody, fmt.Sprintf("the request body must not exceed %d bytes", limit))
+		return nil, false
+	}
+	var request NoteRequest
+	if err := decodeStrict(body, &request); err != nil {
+		writeProblem(w, r, CodeInvalidBody, err.Error())
+		return nil, false
+	}
+	return &request, true
+}
+
+func writeNoteError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, notes.ErrInvalid):
+		writeProblem(w, r, CodeInvalidBody, err.Error())
+	case errors.Is(err, notes.ErrVersionConflict), errors.Is(err, notes.ErrTooMany):
+		writeProblem(w, r, CodeConflict, err.Error())
+	case errors.Is(err, notes.ErrNotFound):
+		writeProblem(w, r, CodeNotFound, err.Error())
+	default:
+		writeProblem(w, r, CodeUnavailable, err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/notes"
+)
+
+func TestNotes(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "abc123"})
+	registry := crashgroup.New(0)
+	registry.Observe(&collector.CoredumpFile{ID: "abc123", Fingerprint: "loop"}, []string{"bulk_subscript"}, time.Now())
+	server := NewServer(store, nil, nil, registry, nil, nil)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := send(http.MethodGet, "/api/v1/coredumps/abc123/notes", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without notes, got %d", rec.Code)
+	}
+	server.HandleNotes(notes.New(""))
+
+	rec := send(http.MethodPost, "/api/v1/coredumps/abc123/notes", `{"author": "alice", "body": "Reproduced with script X"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var note notes.Note
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	path := "/api/v1/coredumps/abc123/notes/" + strconv.Itoa(note.ID)
+	if rec := send(http.MethodPut, path, `{"author": "bob", "body": "Fixed in PR 1234", "version": 1}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected the edit to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPut, path, `{"author": "carol", "body": "Not fixed", "version": 1}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an edit of an old version, got %d", rec.Code)
+	}
+	rec = send(http.MethodGet, path, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil || note.Version != 2 || note.Body != "Fixed in PR 1234" || len(note.History) != 1 {
+		t.Errorf("expected the second version with its history, got %+v (%v)", note, err)
+	}
+
+	if rec := send(http.MethodPost, "/api/v1/crash-groups/loop/notes", `{"author": "alice", "body": "Known issue"}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected a note on the crash group, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = send(http.MethodGet, "/api/v1/crash-groups/loop/notes", "")
+	var list struct {
+		Items []notes.Note `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Items) != 1 || list.Items[0].Body != "Known issue" {
+		t.Errorf("expected the crash group's note only, got %+v (%v)", list.Items, err)
+	}
+
+	for _, c := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodPost, "/api/v1/coredumps/unknown/notes", `{"author": "alice", "body": "note"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/crash-groups/unknown/notes", `{"author": "alice", "body": "note"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/coredumps/abc123/notes", `{"body": "note"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/coredumps/abc123/notes", `{"author": "alice", "text": "note"}`, http.StatusBadRequest},
+		{http.MethodPut, path, `{"author": "alice", "body": "note"}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/coredumps/abc123/notes/99", `{"author": "alice", "body": "note", "version": 1}`, http.StatusNotFound},
+		{http.MethodDelete, path, "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/coredumps/abc123/notesx", "", http.StatusNotFound},
+	} {
+		if rec := send(c.method, c.path, c.body); rec.Code != c.code {
+			t.Errorf("%s %s: expected %d, got %d: %s", c.method, c.path, c.code, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/alertrules"
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/notes"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+// route documents an endpoint in the OpenAPI document. Request and
+// response are values of the Go types the handler decodes and encodes, from
+// which the schemas are generated.
+type route struct {
+	method   string
+	path     string
+	summary  string
+	params   []param
+	request  interface{}
+	response interface{}
+	// status is the success status, 200 when unset.
+	status int
+	// items wraps the response in an {"items": [...]} list.
+	items bool
+	// contentType of the response, JSON when unset.
+	contentType string
+	// security names the token an endpoint outside the API token requires.
+	security string
+}
+
+type param struct {
+	name        string
+	in          string
+	description string
+	kind        string
+}
+
+func queryParam(name, kind, description string) param {
+	return param{name: name, in: "query", kind: kind, description: description}
+}
+
+func pathParam(name, description string) param {
+	return param{name: name, in: "path", kind: "string", description: description}
+}
+
+var (
+	windowParam      = queryParam("window", "string", "Time window, a Go duration or whole days such as 7d")
+	coredumpIDParam  = pathParam("id", "Coredump ID")
+	fingerprintParam = pathParam("fingerprint", "Crash group fingerprint")
+	noteIDParam      = pathParam("noteId", "Note ID")
+	alertRuleParam   = pathParam("name", "Alert rule name")
+	silenceIDParam   = pathParam("id", "Silence ID")
+	fullParam        = queryParam("full", "boolean", "Include the stack trace and goroutine dump")
+)
+
+// routes lists the endpoints of the API. Keep it next to the handlers'
+// doc comments when adding or changing one.
+var routes = []route{
+	{method: http.MethodGet, path: "/api/v1/coredumps", summary: "List coredumps, newest first", params: []param{
+		queryParam("limit", "integer", "Page size, 1 to 500"),
+		queryParam("cursor", "string", "nextCursor of the previous page"),
+		queryParam("offset", "integer", "Offset of the page; not combinable with cursor"),
+		queryParam("underChaos", "boolean", "Only cores written during, or outside, chaos experiments"),
+		queryParam("containerType", "string", "main, init or ephemeral"),
+		queryParam("package", "string", "Package, or package@version, in the crash environment"),
+		queryParam("namespace", "string", "Only coredumps of pods in this namespace"),
+		queryParam("instance", "string", "Only coredumps of this Milvus instance"),
+		queryParam("minScore", "number", "Lowest value score"),
+		queryParam("maxScore", "number", "Highest value score"),
+		queryParam("since", "string", "Crashed at or after this RFC 3339 time"),
+		queryParam("until", "string", "Crashed at or before this RFC 3339 time"),
+		fullParam,
+	}, response: CoredumpList{}},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}", summary: "Get a coredump", params: []param{coredumpIDParam, fullParam},
+		response: CoredumpView{}},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}/stacktrace", summary: "Stream the stack trace of a coredump", params: []param{
+		coredumpIDParam,
+		queryParam("limit", "integer", "Return a JSON page (FramePage) of this many frames instead"),
+		queryParam("offset", "integer", "First frame of the page"),
+	}, response: "", contentType: "text/plain"},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}/goroutines", summary: "Stream the goroutine dump of a Go coredump", params: []param{coredumpIDParam},
+		response: "", contentType: "text/plain"},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}/similar", summary: "Search the past crashes most similar to a coredump", params: []param{
+		coredumpIDParam, queryParam("limit", "integer", "Crashes returned, 1-50; the configured topK when unset"),
+	}, response: SimilarCrashView{}, items: true},
+	{method: http.MethodPost, path: "/api/v1/coredumps/{id}/wait", summary: "Wait for the analysis of a coredump", params: []param{
+		coredumpIDParam, queryParam("timeout", "string", "How long to wait, up to 10m"),
+	}, response: WaitResult{}},
+	{method: http.MethodPost, path: "/api/v1/coredumps/{id}/reanalyze", summary: "Queue a failed analysis again", params: []param{coredumpIDParam},
+		response: WaitResult{}, status: http.StatusAccepted},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}/notes", summary: "List the notes on a coredump", params: []param{coredumpIDParam},
+		response: notes.Note{}, items: true},
+	{method: http.MethodPost, path: "/api/v1/coredumps/{id}/notes", summary: "Add a note to a coredump", params: []param{coredumpIDParam},
+		request: NoteRequest{}, response: notes.Note{}, status: http.StatusCreated},
+	{method: http.MethodGet, path: "/api/v1/coredumps/{id}/notes/{noteId}", summary: "Get a note on a coredump", params: []param{coredumpIDParam, noteIDParam},
+		response: notes.Note{}},
+	{method: http.MethodPut, path: "/api/v1/coredumps/{id}/notes/{noteId}", summary: "Edit a note on a coredump", params: []param{coredumpIDParam, noteIDParam},
+		request: NoteRequest{}, response: notes.Note{}},
+	{method: http.MethodGet, path: "/api/v1/search", summary: "Search stack traces, crash reasons and AI summaries", params: []param{
+		queryParam("q", "string", "Space separated terms, all of which must match"),
+		queryParam("limit", "integer", "Maximum number of hits"),
+	}, response: SearchResults{}},
+	{method: http.MethodGet, path: "/api/v1/stats/breakdown", summary: "Crash counts by signal, executable, component and more", params: []param{windowParam},
+		response: Breakdown{}},
+	{method: http.MethodGet, path: "/api/v1/stats/versions", summary: "Crash counts by version of a package or library", params: []param{
+		queryParam("package", "string", "Package to break down by"),
+		queryParam("library", "string", "Shared library file name to break down by"),
+		windowParam,
+	}, response: VersionBreakdown{}},
+	{method: http.MethodGet, path: "/api/v1/stats/storage", summary: "Usage of the primary storage backend", response: storage.EfficiencyStats{}},
+	{method: http.MethodGet, path: "/api/v1/stats/ai", summary: "AI spend against the cost limits and quotas", response: analyzer.AIUsage{}},
+	{method: http.MethodGet, path: "/api/v1/stats/lifecycle", summary: "Time coredumps took through each pipeline stage", params: []param{windowParam},
+		response: LifecycleStats{}},
+	{method: http.MethodGet, path: "/api/v1/crash-groups", summary: "List crash groups, most occurrences first", response: crashgroup.Group{}, items: true},
+	{method: http.MethodGet, path: "/api/v1/crash-groups/{fingerprint}", summary: "Get a crash group", params: []param{fingerprintParam},
+		response: crashgroup.Group{}},
+	{method: http.MethodGet, path: "/api/v1/crash-groups/{fingerprint}/notes", summary: "List the notes on a crash group", params: []param{fingerprintParam},
+		response: notes.Note{}, items: true},
+	{method: http.MethodPost, path: "/api/v1/crash-groups/{fingerprint}/notes", summary: "Add a note to a crash group", params: []param{fingerprintParam},
+		request: NoteRequest{}, response: notes.Note{}, status: http.StatusCreated},
+	{method: http.MethodGet, path: "/api/v1/crash-groups/{fingerprint}/notes/{noteId}", summary: "Get a note on a crash group", params: []param{fingerprintParam, noteIDParam},
+		response: notes.Note{}},
+	{method: http.MethodPut, path: "/api/v1/crash-groups/{fingerprint}/notes/{noteId}", summary: "Edit a note on a crash group", params: []param{fingerprintParam, noteIDParam},
+		request: NoteRequest{}, response: notes.Note{}},
+	{method: http.MethodGet, path: "/api/v1/thresholds", summary: "Effective value score thresholds", response: config.ScoreThresholds{}},
+	{method: http.MethodPost, path: "/api/v1/scoring/simulate", summary: "Score a hypothetical crash", request: SimulationRequest{},
+		response: SimulationResult{}},
+	{method: http.MethodPost, path: "/api/v1/selftest", summary: "Run a synthetic core through the pipeline", params: []param{
+		queryParam("timeout", "string", "How long to wait for the pipeline, up to 10m"),
+	}, response: SelfTestResult{}},
+	{method: http.MethodGet, path: "/api/v1/instances", summary: "List the discovered Milvus instances", params: []param{
+		queryParam("includeTerminated", "boolean", "Include instances terminated within the last hour"),
+	}, response: InstanceSummary{}, items: true},
+	{method: http.MethodGet, path: "/api/v1/instances/{namespace}/{name}", summary: "Get an instance and its component topology", params: []param{
+		pathParam("namespace", "Namespace"), pathParam("name", "Instance name"),
+	}, response: InstanceDetail{}},
+	{method: http.MethodGet, path: "/api/v1/instances/{namespace}/{name}/timeline", summary: "Crashes and CR condition changes of an instance", params: []param{
+		pathParam("namespace", "Namespace"), pathParam("name", "Instance name"),
+	}, response: TimelineEntry{}, items: true},
+	{method: http.MethodGet, path: "/api/v1/restarts", summary: "Container restarts", params: []param{
+		queryParam("namespace", "string", "Namespace"),
+		queryParam("instance", "string", "Instance name"),
+		queryParam("panic", "boolean", "Only restarts caused by a panic"),
+		queryParam("groupBy", "string", "hour or instance"),
+		windowParam,
+	}, response: RestartList{}},
+	{method: http.MethodGet, path: "/api/v1/events", summary: "Stream pipeline events as server-sent events", params: []param{
+		queryParam("source", "string", "Comma separated sources: collector, analyzer, storage, cleaner, config"),
+	}, response: Event{}, contentType: "text/event-stream"},
+	{method: http.MethodGet, path: "/api/v1/alert-rules", summary: "List the alert rules in evaluation order", response: alertrules.Rule{}, items: true},
+	{method: http.MethodPost, path: "/api/v1/alert-rules", summary: "Add an alert rule", request: alertrules.Rule{},
+		response: alertrules.Rule{}, status: http.StatusCreated},
+	{method: http.MethodGet, path: "/api/v1/alert-rules/{name}", summary: "Get an alert rule", params: []param{alertRuleParam},
+		response: alertrules.Rule{}},
+	{method: http.MethodPut, path: "/api/v1/alert-rules/{name}", summary: "Replace an alert rule added through the API", params: []param{alertRuleParam},
+		request: alertrules.Rule{}, response: alertrules.Rule{}},
+	{method: http.MethodDelete, path: "/api/v1/alert-rules/{name}", summary: "Remove an alert rule added through the API", params: []param{alertRuleParam},
+		response: alertrules.Rule{}},
+	{method: http.MethodGet, path: "/api/v1/alert-silences", summary: "List the alert silences, newest first", response: alertrules.Silence{}, items: true},
+	{method: http.MethodPost, path: "/api/v1/alert-silences", summary: "Silence the matching alerts for a while", request: AlertSilenceRequest{},
+		response: alertrules.Silence{}, status: http.StatusCreated},
+	{method: http.MethodGet, path: "/api/v1/alert-silences/{id}", summary: "Get an alert silence", params: []param{silenceIDParam},
+		response: alertrules.Silence{}},
+	{method: http.MethodDelete, path: "/api/v1/alert-silences/{id}", summary: "Expire an alert silence", params: []param{silenceIDParam},
+		response: alertrules.Silence{}},
+	{method: http.MethodGet, path: "/api/v1/node/coredumps", summary: "Coredumps on this node", params: []param{
+		queryParam("status", "string", "Only coredumps in this status"),
+	}, response: NodeCoredump{}, items: true, security: "nodeToken"},
+	{method: http.MethodGet, path: "/api/v1/node/health", summary: "Health of the agent on this node", response: NodeHealth{}, security: "nodeToken"},
+	{method: http.MethodGet, path: "/api/v1/embed/summary", summary: "Crash summary widget", params: embedParams, response: SummaryWidget{}, security: "embedToken"},
+	{method: http.MethodGet, path: "/api/v1/embed/trend", summary: "Crash trend widget", params: embedParams, response: TrendWidget{}, security: "embedToken"},
+	{method: http.MethodGet, path: "/api/v1/embed/crash-groups", summary: "Top crash groups widget", params: append([]param{
+		queryParam("limit", "integer", "Number of groups"),
+	}, embedParams...), response: crashgroup.Group{}, items: true, security: "embedToken"},
+	{method: http.MethodGet, path: "/api/v1/embed/restarts", summary: "Restarts by hour widget", params: embedParams, response: RestartList{}, security: "embedToken"},
+}
+
+var embedParams = []param{
+	windowParam,
+	queryParam("format", "string", "json or html"),
+	queryParam("token", "string", "Embed token, for iframes that can't set a header"),
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// GET /api/v1/openapi.json
+//
+// Serves the OpenAPI 3 document of the API, for client generators and the
+// Swagger UI at /api/v1/docs.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	openAPIOnce.Do(func() {
+		var err error
+		openAPIDocument, err = json.Marshal(buildOpenAPI(routes))
+		if err != nil {
+			panic("api: failed to encode the OpenAPI document: " + err.Error())
+		}
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// GET /api/v1/docs
+//
+// Serves Swagger UI over the OpenAPI document. The page loads Swagger UI
+// from a CDN, so it needs a browser with internet access.
+func (s *Server) handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Milvus Coredump Agent API</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+func buildOpenAPI(routes []route) map[string]interface{} {
+	schemas := newSchemaSet()
+	paths := make(map[string]map[string]interface{})
+
+	for _, rt := range routes {
+		operation := map[string]interface{}{
+			"summary":     rt.summary,
+			"operationId": operationID(rt),
+		}
+		if len(rt.params) > 0 {
+			var params []interface{}
+			for _, p := range rt.params {
+				params = append(params, map[string]interface{}{
+					"name":        p.name,
+					"in":          p.in,
+					"description": p.description,
+					"required":    p.in == "path",
+					"schema":      map[string]interface{}{"type": p.kind},
+				})
+			}
+			operation["parameters"] = params
+		}
+		if rt.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(rt.request))},
+				},
+			}
+		}
+
+		schema := schemas.of(reflect.TypeOf(rt.response))
+		if rt.items {
+			schema = map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"items": map[string]interface{}{"type": "array", "items": schema}},
+			}
+		}
+		contentType := rt.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(status): map[string]interface{}{
+				"description": http.StatusText(status),
+				"content":     map[string]interface{}{contentType: map[string]interface{}{"schema": schema}},
+			},
+			"default": map[string]interface{}{
+				"description": "RFC 7807 problem",
+				"content": map[string]interface{}{
+					problemContentType: map[string]interface{}{"schema": schemas.of(reflect.TypeOf(Problem{}))},
+				},
+			},
+		}
+		if rt.security != "" {
+			operation["security"] = []interface{}{map[string]interface{}{rt.security: []string{}}}
+		}
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]interface{})
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = operation
+	}
+
+	bearer := map[string]interface{}{"type": "http", "scheme": "bearer"}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Milvus Coredump Agent API",
+			"version":     "v1",
+			"description": "Coredumps collected by the agent, their analysis and the Milvus instances they came from.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.defined,
+			"securitySchemes": map[string]interface{}{
+				"apiToken":   bearer,
+				"nodeToken":  bearer,
+				"embedToken": bearer,
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"apiToken": []string{}}},
+	}
+}
+
+// operationID derives an identifier such as getCoredumpsIdNotes from the
+// method and path.
+func operationID(rt route) string {
+	id := strings.ToLower(rt.method)
+	for _, segment := range strings.Split(strings.TrimPrefix(rt.path, "/api/v1/"), "/") {
+		segment = strings.Trim(segment, "{}")
+		for _, word := range strings.Split(segment, "-") {
+			if word != "" {
+				id += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return id
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	metaTimeType   = reflect.TypeOf(metav1.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaSet generates JSON schemas from Go types the way encoding/json
+// encodes them. Named structs become components referenced by name.
+type schemaSet struct {
+	defined map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{defined: make(map[string]interface{}), names: make(map[reflect.Type]string)}
+}
+
+func (s *schemaSet) of(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType, metaTimeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.of(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+			// Encoded by its own rules.
+			return map[string]interface{}{}
+		}
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.ref(t)
+	}
+	// Interfaces can hold anything.
+	return map[string]interface{}{}
+}
+
+func (s *schemaSet) ref(t reflect.Type) map[string]interface{} {
+	name, exists := s.names[t]
+	if !exists {
+		name = t.Name()
+		if _, taken := s.defined[name]; taken {
+			name = strings.ToUpper(path.Base(t.PkgPath())[:1]) + path.Base(t.PkgPath())[1:] + name
+		}
+		s.names[t] = name
+		// Reserve the name before generating, for recursive types.
+		s.defined[name] = nil
+		s.defined[name] = s.structSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+func (s *schemaSet) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	s.addFields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (s *schemaSet) addFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+	server.HandleScoring(nil)
+	server.HandleLifecycleStats(&config.LifecycleSLAConfig{})
+	server.HandleSelfTest(t.TempDir())
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var document struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+		t.Fatalf("failed to decode the document: %v", err)
+	}
+	if document.OpenAPI != "3.0.3" || len(document.Paths) == 0 {
+		t.Fatalf("unexpected document %s", rec.Body.String())
+	}
+	if _, exists := document.Paths["/api/v1/coredumps/{id}/notes"]["post"]["requestBody"]; !exists {
+		t.Error("expected the request body of adding a note to be documented")
+	}
+	if record := document.Components.Schemas["CoredumpView"]; record.Properties["analysisResults"] == nil || record.Properties["nodeName"] == nil {
+		t.Errorf("expected the coredump schema to follow its JSON tags, got %v", record.Properties)
+	}
+
+	// Every reference resolves.
+	for _, ref := range strings.Split(rec.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if _, exists := document.Components.Schemas[name]; !exists {
+			t.Errorf("unresolved reference to %s", name)
+		}
+	}
+
+	// Every documented endpoint of the server is routed to its handler
+	// rather than the catch-all.
+	for _, rt := range routes {
+		if rt.security != "" || rt.contentType == "text/event-stream" {
+			continue
+		}
+		path := strings.NewReplacer("{id}", "abc123", "{fingerprint}", "loop", "{noteId}", "1",
+			"{namespace}", "milvus", "{name}", "prod").Replace(rt.path)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(rt.method, path+"?timeout=1ms", strings.NewReader("{}")))
+		if rec.Code == http.StatusNotFound && !strings.Contains(rec.Body.String(), `"detail"`) {
+			t.Errorf("%s %s is documented but not served", rt.method, rt.path)
+		}
+		if rec.Code == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s is documented but the method is not allowed", rt.method, rt.path)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "openapi.json") {
+		t.Errorf("expected the Swagger UI page, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+// Requeuer analyzes coredumps whose analysis failed again.
+type Requeuer interface {
+	Requeue(coredump *collector.CoredumpFile) error
+}
+
+// HandleReanalyze enables requeueing failed analyses through requeuer.
+func (s *Server) HandleReanalyze(requeuer Requeuer) {
+	s.requeuer = requeuer
+}
+
+// POST /api/v1/coredumps/<id>/reanalyze
+//
+// Queues a coredump whose analysis failed for another analysis, whether or
+// not it has automatic retries left. Answers 202; the outcome can be awaited
+// with /wait.
+func (s *Server) handleReanalyze(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	if s.requeuer == nil {
+		writeProblem(w, r, CodeUnavailable, "reanalysis is not available")
+		return
+	}
+	record, exists := s.store.Get(id)
+	if !exists {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s not found", id))
+		return
+	}
+
+	// Records are shared with readers, so the requeue changes a copy.
+	requeued := *record
+	if err := s.requeuer.Requeue(&requeued); err != nil {
+		if errors.Is(err, collector.ErrStateConflict) {
+			writeProblem(w, r, CodeConflict, fmt.Sprintf("coredump %s is %s, only failed analyses can be requeued", id, record.Status))
+			return
+		}
+		writeProblem(w, r, CodeUnavailable, err.Error())
+		return
+	}
+	s.store.upsert(&requeued)
+	writeJSON(w, http.StatusAccepted, waitResult(&requeued))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+type fakeRequeuer struct {
+	requeued []string
+}
+
+func (f *fakeRequeuer) Requeue(coredump *collector.CoredumpFile) error {
+	if err := collector.NewStateMachine().Transition(coredump, collector.StatusError, collector.StatusProcessing, "requeued"); err != nil {
+		return err
+	}
+	f.requeued = append(f.requeued, coredump.ID)
+	return nil
+}
+
+func TestReanalyze(t *testing.T) {
+	store := NewStore(0, 0)
+	retryAt := time.Now().Add(time.Minute)
+	store.upsert(&collector.CoredumpFile{ID: "failed", Status: collector.StatusError, ErrorMessage: "gdb analysis failed"})
+	store.upsert(&collector.CoredumpFile{ID: "retrying", Status: collector.StatusError, NextRetryAt: &retryAt})
+	store.upsert(&collector.CoredumpFile{ID: "stored", Status: collector.StatusStored})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	post := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec
+	}
+
+	if rec := post("/api/v1/coredumps/failed/reanalyze"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a requeuer, got %d", rec.Code)
+	}
+
+	requeuer := &fakeRequeuer{}
+	server.HandleReanalyze(requeuer)
+
+	rec := post("/api/v1/coredumps/failed/reanalyze")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result WaitResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Status != string(collector.StatusProcessing) || result.Completed || result.ErrorMessage != "" {
+		t.Errorf("expected the core to be processing again, got %+v", result)
+	}
+	if record, _ := store.Get("failed"); record.Status != collector.StatusProcessing {
+		t.Errorf("expected waiters to see the requeue, got %s", record.Status)
+	}
+
+	if rec := post("/api/v1/coredumps/stored/reanalyze"); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a stored core, got %d", rec.Code)
+	}
+	if rec := post("/api/v1/coredumps/missing/reanalyze"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown core, got %d", rec.Code)
+	}
+	if len(requeuer.requeued) != 1 {
+		t.Errorf("expected one requeue, got %v", requeuer.requeued)
+	}
+
+	// A failed analysis with a retry pending hasn't completed yet.
+	if record, _ := store.Get("retrying"); waitResult(record).Completed {
+		t.Error("expected a core awaiting a retry not to be completed")
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+const (
+	RestartsByHour     = "hour"
+	RestartsByInstance = "instance"
+)
+
+// RestartList holds the container restarts of a window, newest first, or
+// with groupBy their counts per UTC hour, oldest first, or per instance.
+// Restarts matter without a core too: an OOM kill or a failing liveness
+// probe leaves none.
+type RestartList struct {
+	Window  string                   `json:"window"`
+	Since   time.Time                `json:"since"`
+	Until   time.Time                `json:"until"`
+	Total   int                      `json:"total"`
+	GroupBy string                   `json:"groupBy,omitempty"`
+	Items   []discovery.RestartEvent `json:"items,omitempty"`
+	Groups  []BucketCount            `json:"groups,omitempty"`
+}
+
+// restartFilter selects restarts by instance, namespace and whether the
+// container panicked; empty fields match all.
+type restartFilter struct {
+	instance  string
+	namespace string
+	panicOnly bool
+}
+
+func (f restartFilter) matches(event discovery.RestartEvent) bool {
+	return (f.instance == "" || event.InstanceName == f.instance) &&
+		(f.namespace == "" || event.PodNamespace == f.namespace) &&
+		(!f.panicOnly || event.IsPanic)
+}
+
+// GET /api/v1/restarts?namespace=milvus&instance=prod&window=24h
+// GET /api/v1/restarts?panic=true&groupBy=hour
+// GET /api/v1/restarts?window=7d&groupBy=instance
+func (s *Server) handleListRestarts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := restartFilter{instance: query.Get("instance"), namespace: query.Get("namespace")}
+	if value := query.Get("panic"); value != "" {
+		panicOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "panic", "panic must be true or false")
+			return
+		}
+		filter.panicOnly = panicOnly
+	}
+	groupBy := query.Get("groupBy")
+	if groupBy != "" && groupBy != RestartsByHour && groupBy != RestartsByInstance {
+		writeInvalidParameter(w, r, "groupBy", "groupBy must be hour or instance")
+		return
+	}
+	window := defaultBreakdownWindow
+	if value := query.Get("window"); value != "" {
+		parsed, err := parseWindow(value)
+		if err != nil {
+			writeInvalidParameter(w, r, "window", err.Error())
+			return
+		}
+		window = parsed
+	}
+
+	key := "restarts/" + filter.namespace + "/" + filter.instance + "/" + strconv.FormatBool(filter.panicOnly) + "/" + groupBy + "/" + window.String()
+	list := statscache.Get(s.store.stats, key, func() *RestartList {
+		until := time.Now()
+		return computeRestarts(s.store.Restarts(), filter, groupBy, until.Add(-window), until, window)
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func computeRestarts(events []discovery.RestartEvent, filter restartFilter, groupBy string, since, until time.Time, window time.Duration) *RestartList {
+	list := &RestartList{Window: formatWindow(window), Since: since, Until: until, GroupBy: groupBy}
+
+	var matched []discovery.RestartEvent
+	for _, event := range events {
+		at := event.RestartTime.Time
+		if at.Before(since) || at.After(until) || !filter.matches(event) {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	list.Total = len(matched)
+
+	switch groupBy {
+	case RestartsByHour:
+		first := since.UTC().Truncate(time.Hour)
+		hours := int(until.UTC().Truncate(time.Hour).Sub(first)/time.Hour) + 1
+		list.Groups = make([]BucketCount, hours)
+		for i := range list.Groups {
+			list.Groups[i].Key = first.Add(time.Duration(i) * time.Hour).Format(time.RFC3339)
+		}
+		for _, event := range matched {
+			list.Groups[int(event.RestartTime.UTC().Truncate(time.Hour).Sub(first)/time.Hour)].Count++
+		}
+	case RestartsByInstance:
+		counts := map[string]int{}
+		for _, event := range matched {
+			counts[event.PodNamespace+"/"+bucketKey(event.InstanceName)]++
+		}
+		list.Groups = sortedBuckets(counts)
+	default:
+		sort.SliceStable(matched, func(i, j int) bool {
+			return matched[i].RestartTime.After(matched[j].RestartTime.Time)
+		})
+		list.Items = matched
+	}
+	return list
+}
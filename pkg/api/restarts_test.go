@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+func restartAt(namespace, instance string, at time.Time, panicked bool) discovery.RestartEvent {
+	return discovery.RestartEvent{
+		PodName:      instance + "-querynode-0",
+		PodNamespace: namespace,
+		InstanceName: instance,
+		RestartTime:  metav1.NewTime(at),
+		IsPanic:      panicked,
+	}
+}
+
+func TestListRestarts(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+	store.recordRestart(restartAt("milvus", "prod", now.Add(-3*time.Hour), true))
+	store.recordRestart(restartAt("milvus", "prod", now.Add(-time.Hour), false))
+	store.recordRestart(restartAt("milvus", "staging", now.Add(-time.Hour), true))
+	store.recordRestart(restartAt("test", "prod", now.Add(-2*time.Hour), true))
+	store.recordRestart(restartAt("milvus", "prod", now.Add(-48*time.Hour), true))
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	get := func(query string) *RestartList {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/restarts"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var list RestartList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return &list
+	}
+
+	list := get("")
+	if list.Total != 4 || len(list.Items) != 4 || !list.Items[0].RestartTime.After(list.Items[3].RestartTime.Time) {
+		t.Errorf("expected the 4 restarts of the last day, newest first, got %+v", list.Items)
+	}
+	if list := get("?namespace=milvus&instance=prod"); list.Total != 2 {
+		t.Errorf("expected 2 restarts of milvus/prod, got %d", list.Total)
+	}
+	if list := get("?panic=true&window=7d"); list.Total != 4 {
+		t.Errorf("expected 4 panics within a week, got %d", list.Total)
+	}
+
+	byInstance := get("?groupBy=instance")
+	expectBuckets(t, "instances", byInstance.Groups, []BucketCount{{"milvus/prod", 2}, {"milvus/staging", 1}, {"test/prod", 1}})
+
+	byHour := get("?groupBy=hour&window=6h")
+	total := 0
+	for _, hour := range byHour.Groups {
+		total += hour.Count
+	}
+	if len(byHour.Groups) != 7 || total != 4 || len(byHour.Items) != 0 {
+		t.Errorf("expected 4 restarts over 7 hourly buckets, got %+v", byHour.Groups)
+	}
+
+	for _, query := range []string{"?panic=maybe", "?groupBy=day", "?window=x"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/restarts"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestRecordRestartIsBounded(t *testing.T) {
+	store := NewStore(2, 0)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		store.recordRestart(restartAt("milvus", "prod", now.Add(time.Duration(i)*time.Minute), false))
+	}
+	restarts := store.Restarts()
+	if len(restarts) != 2 || !restarts[0].RestartTime.Equal(&metav1.Time{Time: now.Add(time.Minute)}) {
+		t.Errorf("expected the 2 most recent restarts, got %+v", restarts)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const maxSimulationBody = 64 << 10
+
+// SimulationRequest is a hypothetical analysis to score. Weights left out
+// keep the analyzer's, as do the panic keywords when none are given.
+type SimulationRequest struct {
+	CrashReason   string   `json:"crashReason"`
+	StackLength   int      `json:"stackLength"`
+	ThreadCount   int      `json:"threadCount"`
+	PodName       string   `json:"podName"`
+	InstanceName  string   `json:"instanceName"`
+	Component     string   `json:"component"`
+	Signal        int      `json:"signal"`
+	Size          int64    `json:"size"`
+	Age           string   `json:"age"`
+	PanicKeywords []string `json:"panicKeywords"`
+	// Partial ScoreWeights overriding the analyzer's
+	Weights json.RawMessage `json:"weights"`
+}
+
+// SimulationResult is the score the analyzer would give, with the weights
+// it was computed with and, when the thresholds are known, whether such a
+// core would be stored and alert as critical.
+type SimulationResult struct {
+	Score     float64                   `json:"score"`
+	Breakdown *collector.ScoreBreakdown `json:"breakdown"`
+	Weights   analyzer.ScoreWeights     `json:"weights"`
+	Stored    *bool                     `json:"stored,omitempty"`
+	Critical  *bool                     `json:"critical,omitempty"`
+}
+
+// HandleScoring mounts the scoring playground, scoring like an analyzer
+// configured with panicKeywords.
+func (s *Server) HandleScoring(panicKeywords []string) {
+	s.mux.HandleFunc("/api/v1/scoring/simulate", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSimulateScore(w, r, panicKeywords)
+	})
+}
+
+// POST /api/v1/scoring/simulate
+//
+// Scores a hypothetical analysis with the analyzer's rules, optionally
+// under other weights, so scoring changes can be tried before rolling them
+// out. Nothing is stored.
+func (s *Server) handleSimulateScore(w http.ResponseWriter, r *http.Request, panicKeywords []string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSimulationBody+1))
+	if err != nil {
+		writeProblem(w, r, CodeInvalidBody, "failed to read the request body")
+		return
+	}
+	if len(body) > maxSimulationBody {
+		writeProblem(w, r, CodeInvalidBody, fmt.Sprintf("the request body must not exceed %d bytes", maxSimulationBody))
+		return
+	}
+
+	var request SimulationRequest
+	if err := decodeStrict(body, &request); err != nil {
+		writeProblem(w, r, CodeInvalidBody, err.Error())
+		return
+	}
+	weights := analyzer.DefaultScoreWeights
+	if len(request.Weights) > 0 {
+		if err := decodeStrict(request.Weights, &weights); err != nil {
+			writeProblem(w, r, CodeInvalidBody, "weights: "+err.Error())
+			return
+		}
+	}
+	for name, weight := range map[string]float64{
+		"base": weights.Base, "crashReason": weights.CrashReason, "panicKeyword": weights.PanicKeyword,
+		"stackTrace": weights.StackTrace, "threads": weights.Threads, "podAssociation": weights.PodAssociation,
+		"signal": weights.Signal, "fileSize": weights.FileSize, "freshness": weights.Freshness,
+		"component": weights.Component,
+	} {
+		if weight < 0 || weight > 10 {
+			writeProblem(w, r, CodeInvalidBody, fmt.Sprintf("weight %s must be between 0 and 10", name))
+			return
+		}
+	}
+	var age time.Duration
+	if request.Age != "" {
+		age, err = time.ParseDuration(request.Age)
+		if err != nil || age < 0 {
+			writeProblem(w, r, CodeInvalidBody, "age must be a non-negative duration such as 30m")
+			return
+		}
+	}
+	if request.PanicKeywords != nil {
+		panicKeywords = request.PanicKeywords
+	}
+
+	breakdown := analyzer.Score(analyzer.ScoreInput{
+		CrashReason:      request.CrashReason,
+		StackTraceLength: request.StackLength,
+		ThreadCount:      request.ThreadCount,
+		PodName:          request.PodName,
+		InstanceName:     request.InstanceName,
+		Component:        request.Component,
+		Signal:           request.Signal,
+		Size:             request.Size,
+		Age:              age,
+	}, panicKeywords, weights)
+
+	result := &SimulationResult{Score: breakdown.Total, Breakdown: breakdown, Weights: weights}
+	if s.thresholds != nil {
+		stored := breakdown.Total >= s.thresholds.Store
+		critical := breakdown.Total >= s.thresholds.Critical
+		result.Stored, result.Critical = &stored, &critical
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// decodeStrict decodes JSON, rejecting unknown fields so that a misspelled
+// weight isn't silently ignored.
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
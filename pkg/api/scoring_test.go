@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func simulate(t *testing.T, server *Server, body string) (*httptest.ResponseRecorder, *SimulationResult) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/scoring/simulate", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		return rec, nil
+	}
+	var result SimulationResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return rec, &result
+}
+
+func TestSimulateScore(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, &config.ScoreThresholds{Store: 4, Critical: 8})
+	server.HandleScoring([]string{"sigsegv"})
+
+	crash := `"crashReason": "Segmentation fault (SIGSEGV)", "stackLength": 2000, "threadCount": 40,
+		"podName": "prod-querynode-0", "instanceName": "prod", "signal": 11, "size": 4294967296, "age": "10m"`
+
+	_, result := simulate(t, server, `{`+crash+`}`)
+	if result == nil || result.Score != 10 || !result.Breakdown.Capped || !*result.Critical {
+		t.Fatalf("expected a capped critical score with the analyzer's weights, got %+v", result)
+	}
+	if result.Weights != analyzer.DefaultScoreWeights {
+		t.Errorf("expected the default weights, got %+v", result.Weights)
+	}
+
+	// Lowered weights, and keywords that no longer match.
+	_, result = simulate(t, server, `{`+crash+`, "panicKeywords": ["panic"], "weights": {"base": 1, "fileSize": 0}}`)
+	if result == nil || result.Score != 7.5 || result.Breakdown.Capped || *result.Critical || !*result.Stored {
+		t.Fatalf("expected a stored, non-critical 7.5, got %+v", result)
+	}
+	for _, dimension := range result.Breakdown.Dimensions {
+		if dimension.Name == analyzer.ScorePanicKeyword {
+			t.Errorf("expected no panic keyword bonus, got %+v", dimension)
+		}
+	}
+
+	_, result = simulate(t, server, `{"signal": 15, "age": "48h"}`)
+	if result == nil || result.Score != 4 || !*result.Stored {
+		t.Errorf("expected the base score for an empty analysis, got %+v", result)
+	}
+
+	_, result = simulate(t, server, `{"component": "rootcoord", "signal": 15, "age": "48h"}`)
+	if result == nil || result.Score != 4.5 {
+		t.Errorf("expected a coordinator crash to score 4.5, got %+v", result)
+	}
+	_, result = simulate(t, server, `{"component": "querynode", "signal": 15, "age": "48h"}`)
+	if result == nil || result.Score != 4 {
+		t.Errorf("expected a worker crash to score the base score, got %+v", result)
+	}
+}
+
+func TestSimulateScoreRejectsInvalidRequests(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, nil)
+	server.HandleScoring(nil)
+
+	for _, body := range []string{
+		`not json`,
+		`{"crashReasons": "typo"}`,
+		`{"weights": {"crashreasons": 3}}`,
+		`{"weights": {"base": -1}}`,
+		`{"age": "yesterday"}`,
+	} {
+		rec, _ := simulate(t, server, body)
+		var problem Problem
+		if rec.Code != http.StatusBadRequest || json.Unmarshal(rec.Body.Bytes(), &problem) != nil || problem.Code != CodeInvalidBody {
+			t.Errorf("%s: expected invalid_body, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/scoring/simulate", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("expected GET to be rejected with Allow: POST, got %d (%q)", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	// Without thresholds the outcome is left out.
+	_, result := simulate(t, server, `{}`)
+	if result == nil || result.Stored != nil || result.Critical != nil {
+		t.Errorf("expected no store or critical verdict without thresholds, got %+v", result)
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+const (
+	SearchFieldStackTrace  = "stackTrace"
+	SearchFieldCrashReason = "crashReason"
+	SearchFieldAISummary   = "aiSummary"
+
+	// snippetContext is how many bytes around the first match a snippet
+	// shows on each side.
+	snippetContext = 60
+)
+
+// SearchHit is a coredump whose stack trace, crash reason or AI summary
+// contains every term of the query, with a snippet around the first term.
+type SearchHit struct {
+	ID           string    `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Namespace    string    `json:"namespace,omitempty"`
+	InstanceName string    `json:"instanceName,omitempty"`
+	Executable   string    `json:"executable,omitempty"`
+	ValueScore   float64   `json:"valueScore"`
+	Fields       []string  `json:"fields"`
+	Snippet      string    `json:"snippet"`
+}
+
+type SearchResults struct {
+	Query string      `json:"query"`
+	Total int         `json:"total"`
+	Items []SearchHit `json:"items"`
+}
+
+// GET /api/v1/search?q=knowhere::IndexHNSW&limit=50
+//
+// Terms are separated by spaces and matched case-insensitively as
+// substrings, so "IndexHNSW" also finds "knowhere::IndexHNSW::Search". A
+// coredump matches when each term appears in one of the searched fields.
+// Hits are newest first; Total counts all of them.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	terms := strings.Fields(strings.ToLower(query.Get("q")))
+	if len(terms) == 0 {
+		writeInvalidParameter(w, r, "q", "q is required")
+		return
+	}
+	limit := defaultPageLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			writeInvalidParameter(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+			return
+		}
+		limit = n
+	}
+
+	key := "search/" + strconv.Itoa(limit) + "/" + strings.Join(terms, " ")
+	results := statscache.Get(s.store.stats, key, func() *SearchResults {
+		return search(s.store.Records(), terms, limit)
+	})
+	writeJSON(w, http.StatusOK, results)
+}
+
+func search(records []*collector.CoredumpFile, terms []string, limit int) *SearchResults {
+	results := &SearchResults{Query: strings.Join(terms, " "), Items: []SearchHit{}}
+	for _, record := range sortedForList(records) {
+		hit, ok := matchRecord(record, terms)
+		if !ok {
+			continue
+		}
+		results.Total++
+		if len(results.Items) < limit {
+			results.Items = append(results.Items, *hit)
+		}
+	}
+	return results
+}
+
+func matchRecord(record *collector.CoredumpFile, terms []string) (*SearchHit, bool) {
+	results := record.AnalysisResults
+	if results == nil {
+		return nil, false
+	}
+	fields := map[string]string{
+		SearchFieldStackTrace:  results.StackTrace,
+		SearchFieldCrashReason: results.CrashReason,
+	}
+	if results.AIAnalysis != nil {
+		fields[SearchFieldAISummary] = results.AIAnalysis.Summary
+	}
+
+	hit := &SearchHit{
+		ID:           record.ID,
+		Timestamp:    record.Timestamp,
+		Namespace:    record.PodNamespace,
+		InstanceName: record.InstanceName,
+		Executable:   record.Executable,
+		ValueScore:   record.ValueScore,
+	}
+	lowered := make(map[string]string, len(fields))
+	for name, text := range fields {
+		lowered[name] = strings.ToLower(text)
+	}
+	for _, term := range terms {
+		found := false
+		for _, text := range lowered {
+			if strings.Contains(text, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	// Fields in a fixed order, the snippet from the first one holding the
+	// first term.
+	for _, name := range []string{SearchFieldStackTrace, SearchFieldCrashReason, SearchFieldAISummary} {
+		text, exists := lowered[name]
+		if !exists {
+			continue
+		}
+		for _, term := range terms {
+			if index := strings.Index(text, term); index >= 0 {
+				hit.Fields = append(hit.Fields, name)
+				if hit.Snippet == "" && term == terms[0] {
+					original := fields[name]
+					if len(original) != len(text) {
+						// Lowering changed the byte offsets.
+						original = text
+					}
+					hit.Snippet = snippet(original, index, len(term))
+				}
+				break
+			}
+		}
+	}
+	return hit, true
+}
+
+// snippet cuts the text around a match to a single line of context.
+func snippet(text string, index, length int) string {
+	start := index - snippetContext
+	if start < 0 {
+		start = 0
+	}
+	end := index + length + snippetContext
+	if end > len(text) {
+		end = len(text)
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	cut := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(text) {
+		cut += "…"
+	}
+	return cut
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func withStack(id string, created time.Time, stack, reason, summary string) *collector.CoredumpFile {
+	results := &collector.AnalysisResults{StackTrace: stack, CrashReason: reason}
+	if summary != "" {
+		results.AIAnalysis = &collector.AIAnalysisResult{Summary: summary}
+	}
+	return &collector.CoredumpFile{ID: id, CreatedAt: metav1.NewTime(created), AnalysisResults: results}
+}
+
+func TestSearch(t *testing.T) {
+	store := NewStore(0, 0)
+	now := time.Now()
+	hnsw := "#0 0x7f3a in knowhere::IndexHNSW::Search (this=0x0) at index_hnsw.cc:212\n#1 0x7f3b in milvus::segcore::SearchOnSealed"
+	store.upsert(withStack("a", now.Add(-2*time.Hour), hnsw, "SIGSEGV: null pointer", ""))
+	store.upsert(withStack("b", now.Add(-time.Hour), "#0 raise\n#1 abort", "SIGABRT", "Assertion in knowhere::IndexHNSW while loading the index"))
+	store.upsert(withStack("c", now, "#0 runtime.sigpanic", "SIGSEGV", ""))
+	store.upsert(&collector.CoredumpFile{ID: "d", CreatedAt: metav1.NewTime(now)})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	get := func(query string) *SearchResults {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var results SearchResults
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return &results
+	}
+
+	results := get("q=knowhere::IndexHNSW")
+	if results.Total != 2 || results.Items[0].ID != "b" || results.Items[1].ID != "a" {
+		t.Fatalf("expected both HNSW crashes, newest first, got %+v", results.Items)
+	}
+	if fields := results.Items[0].Fields; len(fields) != 1 || fields[0] != SearchFieldAISummary {
+		t.Errorf("expected the AI summary to match, got %v", fields)
+	}
+	if snippet := results.Items[1].Snippet; !strings.Contains(snippet, "knowhere::IndexHNSW::Search") || strings.Contains(snippet, "\n") {
+		t.Errorf("expected a one-line snippet around the match, got %q", snippet)
+	}
+
+	if results := get("q=indexhnsw+sigsegv"); results.Total != 1 || results.Items[0].ID != "a" {
+		t.Errorf("expected all terms to be required, got %+v", results.Items)
+	}
+	if results := get("q=SIGSEGV&limit=1"); results.Total != 2 || len(results.Items) != 1 {
+		t.Errorf("expected 2 hits with 1 returned, got %d and %d", results.Total, len(results.Items))
+	}
+
+	for _, query := range []string{"", "q=+", "q=x&limit=0"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
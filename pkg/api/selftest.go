@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	defaultSelfTestTimeout = 2 * time.Minute
+	selfTestCoreSize       = 64 << 10
+)
+
+// SelfTestResult is the outcome of a pipeline self-test. It passed when the
+// synthetic core was discovered and its analysis completed without error
+// before the timeout.
+type SelfTestResult struct {
+	Passed    bool          `json:"passed"`
+	ID        string        `json:"id"`
+	Path      string        `json:"path"`
+	Status    string        `json:"status,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type selfTest struct {
+	coredumpDir string
+	running     atomic.Bool
+}
+
+// HandleSelfTest enables the pipeline self-test, which writes its synthetic
+// cores into coredumpDir, the directory the collector watches.
+func (s *Server) HandleSelfTest(coredumpDir string) {
+	test := &selfTest{coredumpDir: coredumpDir}
+	s.mux.HandleFunc("/api/v1/selftest", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSelfTest(w, r, test)
+	})
+}
+
+// POST /api/v1/selftest?timeout=2m
+//
+// Drops a small synthetic core into the watched directory and waits until
+// the pipeline processed it, as an end-to-end check after installs and
+// upgrades. The core is removed again afterwards. Answers 200 with the
+// result whether or not the test passed.
+func (s *Server) handleSelfTest(w http.ResponseWriter, r *http.Request, test *selfTest) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	timeout := defaultSelfTestTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxWaitTimeout {
+			writeInvalidParameter(w, r, "timeout", fmt.Sprintf("timeout must be a duration between 0 and %s", maxWaitTimeout))
+			return
+		}
+		timeout = parsed
+	}
+
+	if !test.running.CompareAndSwap(false, true) {
+		writeProblem(w, r, CodeConflict, "a self-test is already running")
+		return
+	}
+	defer test.running.Store(false)
+
+	result := &SelfTestResult{StartedAt: time.Now()}
+	path, id, err := test.writeCore(result.StartedAt)
+	if err != nil {
+		result.Error = err.Error()
+		result.Duration = time.Since(result.StartedAt)
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	defer os.Remove(path)
+	result.ID = id
+	result.Path = path
+
+	if !s.awaitSelfTest(r.Context(), result, timeout) {
+		return
+	}
+	result.Duration = time.Since(result.StartedAt)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// awaitSelfTest follows the synthetic core until its analysis completed or
+// the timeout passed. It returns false when ctx is done first.
+func (s *Server) awaitSelfTest(ctx context.Context, result *SelfTestResult, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		record, exists, changed := s.store.Watch(result.ID)
+		if exists {
+			wait := waitResult(record)
+			result.Status = wait.Status
+			if wait.Completed {
+				result.Passed = record.Status != collector.StatusError
+				result.Error = record.ErrorMessage
+				return true
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			if exists {
+				result.Error = fmt.Sprintf("the synthetic core was still %s after %s", result.Status, timeout)
+			} else {
+				result.Error = fmt.Sprintf("the synthetic core was not discovered within %s", timeout)
+			}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// writeCore writes a synthetic core and returns its path and the ID the
+// collector will give it. It is written under a name the collector ignores
+// and then renamed, so it is never seen incomplete.
+func (t *selfTest) writeCore(now time.Time) (string, string, error) {
+	data := make([]byte, selfTestCoreSize)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", fmt.Errorf("failed to generate the synthetic core: %w", err)
+	}
+
+	name := fmt.Sprintf("core.%s.%d.0.6", collector.SelfTestExecutable, now.UnixNano())
+	path := filepath.Join(t.coredumpDir, name)
+	tmp := filepath.Join(t.coredumpDir, "."+name)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write the synthetic core: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", "", fmt.Errorf("failed to write the synthetic core: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		os.Remove(path)
+		return "", "", fmt.Errorf("failed to write the synthetic core: %w", err)
+	}
+	return path, collector.CoredumpID(path, info.ModTime()), nil
+}
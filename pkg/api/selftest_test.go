@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func runSelfTest(t *testing.T, server *Server, timeout string) *SelfTestResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/selftest?timeout="+timeout, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result SelfTestResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return &result
+}
+
+func TestSelfTest(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(0, 0)
+	server := NewServer(store, nil, nil, nil, nil, nil)
+	server.HandleSelfTest(dir)
+
+	// Stands in for the pipeline: picks up the core and reports it analyzed.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			matches, _ := filepath.Glob(filepath.Join(dir, "core."+collector.SelfTestExecutable+".*"))
+			if len(matches) == 0 {
+				continue
+			}
+			info, err := os.Stat(matches[0])
+			if err != nil {
+				continue
+			}
+			id := collector.CoredumpID(matches[0], info.ModTime())
+			store.upsert(&collector.CoredumpFile{ID: id, Path: matches[0], SelfTest: true, Status: collector.StatusProcessing})
+			store.upsert(&collector.CoredumpFile{ID: id, Path: matches[0], SelfTest: true, Status: collector.StatusAnalyzed})
+			return
+		}
+	}()
+
+	result := runSelfTest(t, server, "5s")
+	<-done
+	if !result.Passed || result.Status != string(collector.StatusAnalyzed) || result.Error != "" {
+		t.Errorf("expected the self-test to pass, got %+v", result)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the synthetic core to be removed, found %d files", len(entries))
+	}
+
+	result = runSelfTest(t, server, "20ms")
+	if result.Passed || !strings.Contains(result.Error, "not discovered") {
+		t.Errorf("expected the self-test to fail without a pipeline, got %+v", result)
+	}
+
+	// Only one self-test at a time holds an analysis worker.
+	running := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/selftest?timeout=300ms", nil))
+		running <- rec.Code
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first self-test to write its core")
+		}
+	}
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/selftest?timeout=20ms", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected a concurrent self-test to be refused, got %d", rec.Code)
+	}
+	if code := <-running; code != http.StatusOK {
+		t.Errorf("expected the first self-test to finish, got %d", code)
+	}
+
+	server = NewServer(store, nil, nil, nil, nil, nil)
+	server.HandleSelfTest(filepath.Join(dir, "missing"))
+	if result := runSelfTest(t, server, "20ms"); result.Passed || !strings.Contains(result.Error, "failed to write") {
+		t.Errorf("expected the self-test to fail on an unwritable directory, got %+v", result)
+	}
+}
@@ -0,0 +1,86 @@
+// Package api serves a JSON API over the coredumps this agent has seen, for
+// dashboards and other tooling. Most endpoints are reads; the few that
+// change state (alert rules and silences, notes, reanalysis, self-tests)
+// are refused by pkg/httputil unless server.tokenFile is set.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/alertrules"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/notes"
+	"milvus-coredump-agent/pkg/similarity"
+)
+
+type Server struct {
+	store      *Store
+	instances  InstanceSource
+	storage    StorageStatsSource
+	groups     CrashGroupSource
+	conditions ConditionSource
+	// thresholds are the effective value score thresholds.
+	thresholds *config.ScoreThresholds
+	requeuer   Requeuer
+	notes      *notes.Book
+	alertRules *alertrules.Engine
+	similarity *similarity.Index
+	aiUsage    AIUsageSource
+	mux        *http.ServeMux
+}
+
+// NewServer creates the API server. instances, storage, groups and
+// thresholds may be nil, in which case their endpoints report that they are
+// unavailable; without conditions the instance timelines show crashes only.
+func NewServer(store *Store, instances InstanceSource, storage StorageStatsSource, groups CrashGroupSource, conditions ConditionSource, thresholds *config.ScoreThresholds) *Server {
+	s := &Server{
+		store:      store,
+		instances:  instances,
+		storage:    storage,
+		groups:     groups,
+		conditions: conditions,
+		thresholds: thresholds,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/api/v1/coredumps", s.handleListCoredumps)
+	s.mux.HandleFunc("/api/v1/coredumps/", s.handleGetCoredump)
+	s.mux.HandleFunc("/api/v1/stats/breakdown", s.handleBreakdown)
+	s.mux.HandleFunc("/api/v1/stats/storage", s.handleStorageStats)
+	s.mux.HandleFunc("/api/v1/stats/versions", s.handleVersionBreakdown)
+	s.mux.HandleFunc("/api/v1/stats/ai", s.handleAIUsage)
+	s.mux.HandleFunc("/api/v1/instances", s.handleListInstances)
+	s.mux.HandleFunc("/api/v1/instances/", s.handleGetInstance)
+	s.mux.HandleFunc("/api/v1/crash-groups", s.handleListCrashGroups)
+	s.mux.HandleFunc("/api/v1/crash-groups/", s.handleGetCrashGroup)
+	s.mux.HandleFunc("/api/v1/thresholds", s.handleThresholds)
+	s.mux.HandleFunc("/api/v1/restarts", s.handleListRestarts)
+	s.mux.HandleFunc("/api/v1/search", s.handleSearch)
+	s.mux.HandleFunc("/api/v1/events", s.handleEvents)
+	s.mux.HandleFunc("/api/v1/alert-rules", s.handleAlertRules)
+	s.mux.HandleFunc("/api/v1/alert-rules/", s.handleAlertRules)
+	s.mux.HandleFunc("/api/v1/alert-silences", s.handleAlertSilences)
+	s.mux.HandleFunc("/api/v1/alert-silences/", s.handleAlertSilences)
+	s.mux.HandleFunc("/api/v1/openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("/api/v1/docs", s.handleSwaggerUI)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, CodeNotFound, "")
+	})
+
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed to encode API response: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/notes"
+	"milvus-coredump-agent/pkg/similarity"
+)
+
+const maxSimilarLimit = 50
+
+// SimilarCrashView is a similar past crash with the notes this agent holds
+// on it and its crash group, such as which change fixed it.
+type SimilarCrashView struct {
+	collector.SimilarCrash
+	Notes []notes.Note `json:"notes,omitempty"`
+}
+
+// HandleSimilarity enables the search for crashes similar to a coredump in
+// index.
+func (s *Server) HandleSimilarity(index *similarity.Index) {
+	s.similarity = index
+}
+
+// GET /api/v1/coredumps/<id>/similar
+// GET /api/v1/coredumps/<id>/similar?limit=10
+//
+// Searches the past crashes most similar to the coredump, most similar
+// first, with what their AI analyses found. Unlike the similarCrashes of
+// the analysis, it includes crashes indexed since.
+func (s *Server) handleSimilar(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.similarity == nil {
+		writeProblem(w, r, CodeUnavailable, "similarity search is not enabled")
+		return
+	}
+	record, exists := s.store.Get(id)
+	if !exists {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s not found", id))
+		return
+	}
+	if record.AnalysisResults == nil {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s has not been analyzed", id))
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxSimilarLimit {
+			writeInvalidParameter(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxSimilarLimit))
+			return
+		}
+		limit = n
+	}
+
+	similar, err := s.similarity.Similar(r.Context(), record, limit)
+	if err != nil {
+		writeProblem(w, r, CodeUnavailable, err.Error())
+		return
+	}
+	items := make([]SimilarCrashView, 0, len(similar))
+	for _, crash := range similar {
+		items = append(items, SimilarCrashView{SimilarCrash: crash, Notes: s.resolutionNotes(crash)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) resolutionNotes(crash collector.SimilarCrash) []notes.Note {
+	if s.notes == nil {
+		return nil
+	}
+	found := s.notes.List(notes.Subject{Kind: notes.KindCoredump, ID: crash.CoredumpID})
+	if crash.Fingerprint != "" {
+		found = append(found, s.notes.List(notes.Subject{Kind: notes.KindCrashGroup, ID: crash.Fingerprint})...)
+	}
+	return found
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/notes"
+	"milvus-coredump-agent/pkg/similarity"
+)
+
+func TestSimilarCrashes(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "new", Executable: "milvus", Signal: 11, AnalysisResults: &collector.AnalysisResults{
+		StackTrace: "#0  0x7f3a in segcore::Search (seg=0x0) at search.cpp:42\n",
+	}})
+	store.upsert(&collector.CoredumpFile{ID: "pending"})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/api/v1/coredumps/new/similar"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without similarity search, got %d", rec.Code)
+	}
+
+	embeddings := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"embedding": [0.6, 0.8]}]}`))
+	}))
+	defer embeddings.Close()
+	milvus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/vectordb/collections/has":
+			w.Write([]byte(`{"code": 0, "data": {"has": true}}`))
+		case "/v2/vectordb/entities/search":
+			w.Write([]byte(`{"code": 0, "data": [{"id": "old", "distance": 0.95, "fingerprint": "f00d", "rootCause": "null segment"}]}`))
+		}
+	}))
+	defer milvus.Close()
+	index, err := similarity.New(&config.SimilarityConfig{
+		Enabled:   true,
+		Embedding: config.EmbeddingConfig{URL: embeddings.URL},
+		Milvus:    config.MilvusCollectionConfig{Address: milvus.URL},
+	}, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HandleSimilarity(index)
+	book := notes.New("")
+	book.Add(notes.Subject{Kind: notes.KindCrashGroup, ID: "f00d"}, "alice", "Fixed by #1234", time.Now())
+	server.HandleNotes(book)
+
+	rec := get("/api/v1/coredumps/new/similar?limit=3")
+	var list struct {
+		Items []SimilarCrashView `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(list.Items) != 1 || list.Items[0].CoredumpID != "old" || list.Items[0].RootCause != "null segment" ||
+		len(list.Items[0].Notes) != 1 || list.Items[0].Notes[0].Body != "Fixed by #1234" {
+		t.Errorf("expected the similar crash with its group's note, got %s", rec.Body.String())
+	}
+
+	if rec := get("/api/v1/coredumps/new/similar?limit=100"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a limit above 50, got %d", rec.Code)
+	}
+	if rec := get("/api/v1/coredumps/pending/similar"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a coredump not analyzed yet, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const maxFramePage = 500
+
+// frameStart matches the first line of a gdb frame ("#3  0x... in f ()")
+// or a delve one ("3  0x... in main.f").
+var frameStart = regexp.MustCompile(`^\s*#?\d+\s+0x[0-9a-fA-F]+\s+in\s|^#\d+\s`)
+
+// CoredumpView is a coredump record as the list and detail endpoints show
+// it. The stack trace and the goroutine dump, which can run to megabytes,
+// are left out unless the record is asked for in full; their sizes say
+// whether fetching them from /stacktrace and /goroutines is worth it.
+type CoredumpView struct {
+	*collector.CoredumpFile
+	AnalysisResults *AnalysisResultsView `json:"analysisResults,omitempty"`
+}
+
+type AnalysisResultsView struct {
+	*collector.AnalysisResults
+	StackTrace       string          `json:"stackTrace,omitempty"`
+	StackTraceBytes  int             `json:"stackTraceBytes"`
+	StackTraceFrames int             `json:"stackTraceFrames"`
+	GoAnalysis       *GoAnalysisView `json:"goAnalysis,omitempty"`
+}
+
+type GoAnalysisView struct {
+	*collector.GoAnalysis
+	Goroutines      string `json:"goroutines,omitempty"`
+	GoroutinesBytes int    `json:"goroutinesBytes,omitempty"`
+}
+
+func newCoredumpView(record *collector.CoredumpFile, full bool) *CoredumpView {
+	view := &CoredumpView{CoredumpFile: record}
+	results := record.AnalysisResults
+	if results == nil {
+		return view
+	}
+	view.AnalysisResults = &AnalysisResultsView{
+		AnalysisResults:  results,
+		StackTraceBytes:  len(results.StackTrace),
+		StackTraceFrames: len(splitFrames(results.StackTrace)),
+	}
+	if full {
+		view.AnalysisResults.StackTrace = results.StackTrace
+	}
+	if results.GoAnalysis != nil {
+		view.AnalysisResults.GoAnalysis = &GoAnalysisView{
+			GoAnalysis:      results.GoAnalysis,
+			GoroutinesBytes: len(results.GoAnalysis.Goroutines),
+		}
+		if full {
+			view.AnalysisResults.GoAnalysis.Goroutines = results.GoAnalysis.Goroutines
+		}
+	}
+	return view
+}
+
+// fullRecord reports whether the request asks for records in full.
+func fullRecord(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("full")
+	if value == "" {
+		return false, true
+	}
+	full, err := strconv.ParseBool(value)
+	if err != nil {
+		writeInvalidParameter(w, r, "full", "full must be true or false")
+		return false, false
+	}
+	return full, true
+}
+
+// splitFrames splits a stack trace into its frames, each with the lines
+// that follow it, such as the locals of bt full. Lines before the first
+// frame, such as a thread header, go with it.
+func splitFrames(stackTrace string) []string {
+	if strings.TrimSpace(stackTrace) == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(stackTrace, "\n")
+	var frames []string
+	var current strings.Builder
+	seen := false
+	for _, line := range lines {
+		if frameStart.MatchString(line) {
+			if seen {
+				frames = append(frames, current.String())
+				current.Reset()
+			}
+			seen = true
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		frames = append(frames, current.String())
+	}
+	return frames
+}
+
+// FramePage is a page of the frames of a stack trace.
+type FramePage struct {
+	Frames     []string `json:"frames"`
+	Offset     int      `json:"offset"`
+	Total      int      `json:"total"`
+	NextOffset *int     `json:"nextOffset,omitempty"`
+}
+
+// GET /api/v1/coredumps/<id>/stacktrace
+// GET /api/v1/coredumps/<id>/stacktrace?offset=0&limit=20
+//
+// Streams the coredump's stack trace as text, or returns a page of its
+// frames as JSON when limit is given.
+func (s *Server) handleStackTrace(w http.ResponseWriter, r *http.Request, id string) {
+	results, ok := s.analysisResults(w, r, id)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("limit") == "" && query.Get("offset") == "" {
+		writeText(w, results.StackTrace)
+		return
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > maxFramePage {
+		writeInvalidParameter(w, r, "limit", fmt.Sprintf("limit must be between 1 and %d", maxFramePage))
+		return
+	}
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			writeInvalidParameter(w, r, "offset", "offset must be a non-negative integer")
+			return
+		}
+	}
+
+	frames := splitFrames(results.StackTrace)
+	page := &FramePage{Frames: []string{}, Offset: offset, Total: len(frames)}
+	if offset < len(frames) {
+		end := offset + limit
+		if end > len(frames) {
+			end = len(frames)
+		}
+		page.Frames = append(page.Frames, frames[offset:end]...)
+		if end < len(frames) {
+			page.NextOffset = &end
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /api/v1/coredumps/<id>/goroutines
+//
+// Streams the summary of every goroutine delve found in a Go coredump.
+func (s *Server) handleGoroutines(w http.ResponseWriter, r *http.Request, id string) {
+	results, ok := s.analysisResults(w, r, id)
+	if !ok {
+		return
+	}
+	if results.GoAnalysis == nil {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s has no Go analysis", id))
+		return
+	}
+	writeText(w, results.GoAnalysis.Goroutines)
+}
+
+func (s *Server) analysisResults(w http.ResponseWriter, r *http.Request, id string) (*collector.AnalysisResults, bool) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return nil, false
+	}
+	record, exists := s.store.Get(id)
+	if !exists {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s not found", id))
+		return nil, false
+	}
+	if record.AnalysisResults == nil {
+		writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s has not been analyzed", id))
+		return nil, false
+	}
+	return record.AnalysisResults, true
+}
+
+// writeText streams text without building a JSON copy of it.
+func writeText(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(text)))
+	io.Copy(w, strings.NewReader(text))
+}
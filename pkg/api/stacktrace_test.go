@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const fullBacktrace = `#0  0x00007f3c1a2b in raise () from /lib/x86_64-linux-gnu/libc.so.6
+No symbol table info available.
+#1  0x00007f3c1a2c in abort () from /lib/x86_64-linux-gnu/libc.so.6
+#2  0x000055d1b3e4 in milvus::segcore::SegmentSealedImpl::bulk_subscript (this=0x1) at segment.cpp:120
+        offset = 42
+        field = <optimized out>
+#3  main () at main.cpp:10
+`
+
+func TestStackTrace(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "abc123", AnalysisResults: &collector.AnalysisResults{
+		StackTrace:  fullBacktrace,
+		CrashReason: "SIGABRT",
+		GoAnalysis:  &collector.GoAnalysis{GoroutineCount: 2, Goroutines: "goroutine 1 [running]\ngoroutine 2 [select]\n"},
+	}})
+	store.upsert(&collector.CoredumpFile{ID: "pending"})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/coredumps/abc123")
+	if strings.Contains(rec.Body.String(), "bulk_subscript") || strings.Contains(rec.Body.String(), "goroutine 1") {
+		t.Errorf("expected the detail to leave out the stack trace and goroutines, got %s", rec.Body.String())
+	}
+	var view CoredumpView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if results := view.AnalysisResults; results.CrashReason != "SIGABRT" || results.StackTraceBytes != len(fullBacktrace) ||
+		results.StackTraceFrames != 4 || results.GoAnalysis.GoroutineCount != 2 || results.GoAnalysis.GoroutinesBytes == 0 {
+		t.Errorf("expected the summary fields and sizes, got %+v", results)
+	}
+	if rec := get("/api/v1/coredumps/abc123?full=true"); !strings.Contains(rec.Body.String(), "bulk_subscript") || !strings.Contains(rec.Body.String(), "goroutine 1") {
+		t.Errorf("expected the full record to include the stack trace, got %s", rec.Body.String())
+	}
+	if rec := get("/api/v1/coredumps?limit=10"); strings.Contains(rec.Body.String(), "bulk_subscript") {
+		t.Errorf("expected the list to leave out stack traces, got %s", rec.Body.String())
+	}
+
+	rec = get("/api/v1/coredumps/abc123/stacktrace")
+	if rec.Body.String() != fullBacktrace || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("expected the stack trace as text, got %q", rec.Body.String())
+	}
+
+	rec = get("/api/v1/coredumps/abc123/stacktrace?offset=1&limit=2")
+	var page FramePage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if page.Total != 4 || len(page.Frames) != 2 || page.NextOffset == nil || *page.NextOffset != 3 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	if !strings.HasPrefix(page.Frames[0], "#1 ") || !strings.Contains(page.Frames[1], "offset = 42") {
+		t.Errorf("expected frames with their locals, got %q", page.Frames)
+	}
+	if rec := get("/api/v1/coredumps/abc123/stacktrace?offset=3&limit=2"); strings.Contains(rec.Body.String(), "nextOffset") {
+		t.Errorf("expected no next page after the last frame, got %s", rec.Body.String())
+	}
+
+	if rec := get("/api/v1/coredumps/abc123/goroutines"); rec.Body.String() != "goroutine 1 [running]\ngoroutine 2 [select]\n" {
+		t.Errorf("expected the goroutine dump, got %q", rec.Body.String())
+	}
+
+	for path, code := range map[string]int{
+		"/api/v1/coredumps/pending/stacktrace":          http.StatusNotFound,
+		"/api/v1/coredumps/unknown/stacktrace":          http.StatusNotFound,
+		"/api/v1/coredumps/abc123/stacktrace?limit=0":   http.StatusBadRequest,
+		"/api/v1/coredumps/abc123/stacktrace?offset=-1": http.StatusBadRequest,
+		"/api/v1/coredumps/abc123?full=maybe":           http.StatusBadRequest,
+	} {
+		if rec := get(path); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"milvus-coredump-agent/pkg/storage"
+)
+
+// StorageStatsSource provides the storage efficiency stats.
+type StorageStatsSource interface {
+	Efficiency() storage.EfficiencyStats
+}
+
+// GET /api/v1/stats/storage
+func (s *Server) handleStorageStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.storage == nil {
+		writeProblem(w, r, CodeUnavailable, "storage stats are not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.storage.Efficiency())
+}
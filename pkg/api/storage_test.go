@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+type staticStorageStats storage.EfficiencyStats
+
+func (s staticStorageStats) Efficiency() storage.EfficiencyStats {
+	return storage.EfficiencyStats(s)
+}
+
+func TestStorageStats(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, staticStorageStats{UsedBytes: 2048, CompressionRatio: 0.3}, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/storage", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var stats storage.EfficiencyStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.UsedBytes != 2048 || stats.CompressionRatio != 0.3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(NewStore(0, 0), nil, nil, nil, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/storage", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without storage, got %d", rec.Code)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	server := NewServer(NewStore(0, 0), nil, nil, nil, nil, &config.ScoreThresholds{Store: 6, Critical: 8})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/thresholds", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var thresholds config.ScoreThresholds
+	if err := json.Unmarshal(rec.Body.Bytes(), &thresholds); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if thresholds.Store != 6 || thresholds.Critical != 8 {
+		t.Errorf("unexpected thresholds: %+v", thresholds)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(NewStore(0, 0), nil, nil, nil, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/thresholds", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without thresholds, got %d", rec.Code)
+	}
+}
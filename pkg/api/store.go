@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/reload"
+	"milvus-coredump-agent/pkg/statscache"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+const defaultMaxRecords = 10000
+
+// Channels are the pipeline event streams the store is built from.
+type Channels struct {
+	CollectorEvents <-chan collector.CollectionEvent
+	AnalyzerEvents  <-chan analyzer.AnalysisEvent
+	StorageEvents   <-chan storage.StorageEvent
+	// CleanerEvents are only streamed to /api/v1/events; nil when not
+	// followed.
+	CleanerEvents <-chan cleaner.CleanupEvent
+	// ConfigEvents report configuration reloads; nil without reloading.
+	ConfigEvents <-chan reload.Event
+}
+
+// Store keeps a bounded, in-memory view of the coredumps and container
+// restarts seen by this agent.
+// Records are copies taken when an event arrives, so readers never share a
+// CoredumpFile with the pipeline goroutines that keep mutating it.
+//
+// Stats computed over all records are cached in stats, which every write
+// invalidates. Every event received is also broadcast to the
+// /api/v1/events streams.
+type Store struct {
+	mu         sync.RWMutex
+	records    map[string]*collector.CoredumpFile
+	order      []string
+	maxRecords int
+	restarts   []discovery.RestartEvent
+	stats      *statscache.Cache
+	events     *eventHub
+	// changed is closed and replaced on every record write, waking up
+	// the requests waiting for a record to change.
+	changed chan struct{}
+}
+
+func NewStore(maxRecords int, statsTTL time.Duration) *Store {
+	if maxRecords <= 0 {
+		maxRecords = defaultMaxRecords
+	}
+	return &Store{
+		records:    make(map[string]*collector.CoredumpFile),
+		maxRecords: maxRecords,
+		changed:    make(chan struct{}),
+		stats:      statscache.New("api", statsTTL),
+		events:     newEventHub(),
+	}
+}
+
+func (s *Store) Start(ctx context.Context, channels *Channels) error {
+	klog.Info("Starting API record store")
+	defer s.events.close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-channels.CollectorEvents:
+			if event.Type == collector.EventTypeFileDiscovered && event.CoredumpFile != nil {
+				s.upsert(event.CoredumpFile)
+			}
+			if event.Type == collector.EventTypeRestartDetected && event.RestartEvent != nil {
+				s.recordRestart(*event.RestartEvent)
+			}
+			s.events.publish(collectionEvent(event))
+		case event := <-channels.AnalyzerEvents:
+			if event.CoredumpFile != nil {
+				s.upsert(event.CoredumpFile)
+			}
+			s.events.publish(analysisEvent(event))
+		case event := <-channels.StorageEvents:
+			if event.CoredumpFile != nil {
+				s.upsert(event.CoredumpFile)
+			}
+			s.events.publish(storageEvent(event))
+		case event := <-channels.CleanerEvents:
+			s.events.publish(cleanupEvent(event))
+		case event := <-channels.ConfigEvents:
+			s.events.publish(configEvent(event))
+		}
+	}
+}
+
+func (s *Store) upsert(file *collector.CoredumpFile) {
+	if file.ID == "" {
+		return
+	}
+
+	record := *file
+
+	s.mu.Lock()
+	defer s.stats.Invalidate()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[record.ID]; !exists {
+		s.order = append(s.order, record.ID)
+		for len(s.order) > s.maxRecords {
+			delete(s.records, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	s.records[record.ID] = &record
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Get returns the record with the given ID.
+func (s *Store) Get(id string) (*collector.CoredumpFile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.records[id]
+	return record, exists
+}
+
+// Watch returns the record with the given ID and a channel that is closed
+// at the next write of any record.
+func (s *Store) Watch(id string) (*collector.CoredumpFile, bool, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, exists := s.records[id]
+	return record, exists, s.changed
+}
+
+// Records returns all records, oldest first.
+func (s *Store) Records() []*collector.CoredumpFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]*collector.CoredumpFile, 0, len(s.order))
+	for _, id := range s.order {
+		records = append(records, s.records[id])
+	}
+	return records
+}
+
+// Select returns the records match accepts, oldest first. Matching under
+// the lock spares copying every record for a selective listing.
+func (s *Store) Select(match func(*collector.CoredumpFile) bool) []*collector.CoredumpFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*collector.CoredumpFile
+	for _, id := range s.order {
+		if record := s.records[id]; match(record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// recordRestart keeps the last maxRecords restarts, in arrival order.
+func (s *Store) recordRestart(event discovery.RestartEvent) {
+	s.mu.Lock()
+	defer s.stats.Invalidate()
+	defer s.mu.Unlock()
+
+	s.restarts = append(s.restarts, event)
+	if excess := len(s.restarts) - s.maxRecords; excess > 0 {
+		s.restarts = append(s.restarts[:0:0], s.restarts[excess:]...)
+	}
+}
+
+// Restarts returns the recorded restarts, oldest first.
+func (s *Store) Restarts() []discovery.RestartEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]discovery.RestartEvent(nil), s.restarts...)
+}
@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+// GET /api/v1/thresholds
+func (s *Server) handleThresholds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, CodeMethodNotAllowed, "")
+		return
+	}
+	if s.thresholds == nil {
+		writeProblem(w, r, CodeUnavailable, "thresholds are not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.thresholds)
+}
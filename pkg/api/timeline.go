@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"milvus-coredump-agent/pkg/crstatus"
+)
+
+// ConditionSource provides the Milvus CR condition transitions of an
+// instance.
+type ConditionSource interface {
+	Transitions(namespace, instance string) []crstatus.Transition
+}
+
+const (
+	TimelineCrash     = "crash"
+	TimelineCondition = "condition"
+)
+
+// TimelineEntry is a crash or a Milvus CR condition transition of an
+// instance.
+type TimelineEntry struct {
+	Time      time.Time            `json:"time"`
+	Kind      string               `json:"kind"`
+	Pod       string               `json:"pod,omitempty"`
+	Crash     *CrashMarker         `json:"crash,omitempty"`
+	Condition *crstatus.Transition `json:"condition,omitempty"`
+}
+
+// GET /api/v1/instances/<namespace>/<name>/timeline
+//
+// Crashes are placed at the time of the crash. CR conditions are only
+// available for operator deployments with milvusCR tracking enabled.
+func (s *Server) handleInstanceTimeline(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	entries := []TimelineEntry{}
+	for _, record := range s.store.Records() {
+		if record.PodNamespace != namespace || record.InstanceName != name {
+			continue
+		}
+		at := record.Timestamp
+		if at.IsZero() {
+			at = record.CreatedAt.Time
+		}
+		entries = append(entries, TimelineEntry{
+			Time: at,
+			Kind: TimelineCrash,
+			Pod:  record.PodName,
+			Crash: &CrashMarker{
+				CoredumpID: record.ID,
+				Signal:     record.Signal,
+				Status:     string(record.Status),
+				ValueScore: record.ValueScore,
+				CreatedAt:  record.CreatedAt.Time,
+			},
+		})
+	}
+	if s.conditions != nil {
+		for _, transition := range s.conditions.Transitions(namespace, name) {
+			transition := transition
+			entries = append(entries, TimelineEntry{Time: transition.Time, Kind: TimelineCondition, Condition: &transition})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": entries})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	defaultWaitTimeout = 60 * time.Second
+	maxWaitTimeout     = 10 * time.Minute
+)
+
+// WaitResult is the state of a coredump when its analysis completed or the
+// wait timed out. AI analysis runs as part of the analysis, so its summary
+// is final once Completed is set.
+type WaitResult struct {
+	ID           string  `json:"id"`
+	Status       string  `json:"status"`
+	Completed    bool    `json:"completed"`
+	ValueScore   float64 `json:"valueScore"`
+	CrashReason  string  `json:"crashReason,omitempty"`
+	Summary      string  `json:"summary,omitempty"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+	// When a failed analysis is retried; it isn't completed until then
+	NextRetryAt *time.Time `json:"nextRetryAt,omitempty"`
+}
+
+// POST /api/v1/coredumps/<id>/wait?timeout=120s
+//
+// Blocks until the coredump is analyzed, stored, skipped or failed with no
+// retry pending, for CI pipelines that gate on the triage of a crash. Answers 200 once analysis
+// completed and 202 with the current state when the timeout elapsed first.
+func (s *Server) handleWaitCoredump(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	timeout := defaultWaitTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxWaitTimeout {
+			writeInvalidParameter(w, r, "timeout", fmt.Sprintf("timeout must be a duration between 0 and %s", maxWaitTimeout))
+			return
+		}
+		timeout = parsed
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		record, exists, changed := s.store.Watch(id)
+		if !exists {
+			writeProblem(w, r, CodeNotFound, fmt.Sprintf("coredump %s not found", id))
+			return
+		}
+		result := waitResult(record)
+		if result.Completed {
+			writeJSON(w, http.StatusOK, result)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			writeJSON(w, http.StatusAccepted, result)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func waitResult(record *collector.CoredumpFile) *WaitResult {
+	result := &WaitResult{
+		ID:           record.ID,
+		Status:       string(record.Status),
+		ValueScore:   record.ValueScore,
+		ErrorMessage: record.ErrorMessage,
+		NextRetryAt:  record.NextRetryAt,
+	}
+	switch record.Status {
+	case collector.StatusAnalyzed, collector.StatusStored, collector.StatusSkipped:
+		result.Completed = true
+	case collector.StatusError:
+		result.Completed = record.NextRetryAt == nil
+	}
+	if results := record.AnalysisResults; results != nil {
+		result.CrashReason = results.CrashReason
+		if results.AIAnalysis != nil {
+			result.Summary = results.AIAnalysis.Summary
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+func TestWaitForAnalysis(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "a", Status: collector.StatusProcessing})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.upsert(&collector.CoredumpFile{ID: "b", Status: collector.StatusDiscovered})
+		time.Sleep(50 * time.Millisecond)
+		store.upsert(&collector.CoredumpFile{
+			ID:         "a",
+			Status:     collector.StatusAnalyzed,
+			ValueScore: 7.5,
+			AnalysisResults: &collector.AnalysisResults{
+				CrashReason: "SIGSEGV",
+				AIAnalysis:  &collector.AIAnalysisResult{Summary: "null segment in the HNSW search"},
+			},
+		})
+	}()
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/coredumps/a/wait?timeout=10s", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result WaitResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.Completed || result.ValueScore != 7.5 || result.CrashReason != "SIGSEGV" || result.Summary == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	store := NewStore(0, 0)
+	store.upsert(&collector.CoredumpFile{ID: "a", Status: collector.StatusProcessing})
+	server := NewServer(store, nil, nil, nil, nil, nil)
+
+	for target, expected := range map[string]int{
+		"/api/v1/coredumps/a/wait?timeout=50ms": http.StatusAccepted,
+		"/api/v1/coredumps/x/wait":              http.StatusNotFound,
+		"/api/v1/coredumps/a/wait?timeout=1h":   http.StatusBadRequest,
+		"/api/v1/coredumps/a/wait?timeout=soon": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != expected {
+			t.Errorf("%s: expected %d, got %d", target, expected, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coredumps/a/wait", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("expected GET to be rejected with Allow: POST, got %d (%q)", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,115 @@
+// Package chanstats counts events dropped on the agent's internal event
+// channels and reports how full those channels are. Components send through
+// TrySend instead of a bare select/default, so data loss shows up in metrics
+// and on /healthz/events rather than only in the logs.
+package chanstats
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RecentDropWindow is how long a channel counts as dropping after its last
+// dropped event.
+const RecentDropWindow = 5 * time.Minute
+
+type Stats struct {
+	Name       string    `json:"name"`
+	Length     int       `json:"length"`
+	Capacity   int       `json:"capacity"`
+	Dropped    int64     `json:"dropped"`
+	LastDropAt time.Time `json:"lastDropAt,omitempty"`
+}
+
+// Utilization is the fraction of the channel's buffer in use.
+func (s Stats) Utilization() float64 {
+	if s.Capacity == 0 {
+		return 0
+	}
+	return float64(s.Length) / float64(s.Capacity)
+}
+
+// DroppingSince reports whether an event was dropped after since.
+func (s Stats) DroppingSince(since time.Time) bool {
+	return !s.LastDropAt.IsZero() && s.LastDropAt.After(since)
+}
+
+type channel struct {
+	value    reflect.Value
+	dropped  atomic.Int64
+	lastDrop atomic.Int64
+}
+
+var (
+	mu       sync.RWMutex
+	channels = make(map[string]*channel)
+)
+
+// Register makes a channel's length and capacity visible under name. ch must
+// be a channel; registering a name again replaces the channel but keeps its
+// drop count.
+func Register(name string, ch interface{}) {
+	value := reflect.ValueOf(ch)
+	if value.Kind() != reflect.Chan {
+		panic("chanstats: Register called with non-channel " + value.Kind().String())
+	}
+	c := entry(name)
+	mu.Lock()
+	c.value = value
+	mu.Unlock()
+}
+
+// TrySend sends v on ch without blocking. When the channel is full the event
+// is dropped and counted against name.
+func TrySend[T any](name string, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+		c := entry(name)
+		c.dropped.Add(1)
+		c.lastDrop.Store(time.Now().UnixNano())
+		return false
+	}
+}
+
+// Snapshot returns the current state of every known channel, sorted by name.
+func Snapshot() []Stats {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stats := make([]Stats, 0, len(channels))
+	for name, c := range channels {
+		s := Stats{Name: name, Dropped: c.dropped.Load()}
+		if c.value.IsValid() {
+			s.Length = c.value.Len()
+			s.Capacity = c.value.Cap()
+		}
+		if last := c.lastDrop.Load(); last > 0 {
+			s.LastDropAt = time.Unix(0, last)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+func entry(name string) *channel {
+	mu.RLock()
+	c, exists := channels[name]
+	mu.RUnlock()
+	if exists {
+		return c
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if c, exists = channels[name]; !exists {
+		c = &channel{}
+		channels[name] = c
+	}
+	return c
+}
@@ -0,0 +1,69 @@
+package chanstats
+
+import (
+	"testing"
+	"time"
+)
+
+func find(t *testing.T, name string) Stats {
+	t.Helper()
+	for _, stats := range Snapshot() {
+		if stats.Name == name {
+			return stats
+		}
+	}
+	t.Fatalf("channel %s not in snapshot", name)
+	return Stats{}
+}
+
+func TestTrySendCountsDrops(t *testing.T) {
+	ch := make(chan int, 2)
+	Register("test_drops", ch)
+
+	for i := 0; i < 5; i++ {
+		TrySend("test_drops", ch, i)
+	}
+
+	stats := find(t, "test_drops")
+	if stats.Dropped != 3 {
+		t.Errorf("expected 3 dropped events, got %d", stats.Dropped)
+	}
+	if stats.Length != 2 || stats.Capacity != 2 {
+		t.Errorf("expected length 2 of capacity 2, got %d of %d", stats.Length, stats.Capacity)
+	}
+	if stats.Utilization() != 1 {
+		t.Errorf("expected utilization 1, got %v", stats.Utilization())
+	}
+	if !stats.DroppingSince(time.Now().Add(-RecentDropWindow)) {
+		t.Error("expected channel to be dropping")
+	}
+}
+
+func TestSnapshotWithoutDrops(t *testing.T) {
+	ch := make(chan string, 4)
+	Register("test_quiet", ch)
+
+	if !TrySend("test_quiet", ch, "event") {
+		t.Fatal("expected send to succeed")
+	}
+
+	stats := find(t, "test_quiet")
+	if stats.Dropped != 0 || !stats.LastDropAt.IsZero() {
+		t.Errorf("expected no drops, got %d at %v", stats.Dropped, stats.LastDropAt)
+	}
+	if stats.Utilization() != 0.25 {
+		t.Errorf("expected utilization 0.25, got %v", stats.Utilization())
+	}
+	if stats.DroppingSince(time.Now().Add(-RecentDropWindow)) {
+		t.Error("expected channel not to be dropping")
+	}
+}
+
+func TestRegisterRejectsNonChannel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Register to panic on a non-channel")
+		}
+	}()
+	Register("test_invalid", 42)
+}
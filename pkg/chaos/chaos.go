@@ -0,0 +1,313 @@
+// Package chaos tracks Chaos Mesh and LitmusChaos experiments so crashes
+// caused by deliberate fault injection can be told apart from real ones.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	SourceChaosMesh = "chaos-mesh"
+	SourceLitmus    = "litmus"
+
+	defaultPollInterval = 30 * time.Second
+	defaultMargin       = 2 * time.Minute
+	defaultRetention    = 24 * time.Hour
+)
+
+// chaosMeshResources are the Chaos Mesh fault kinds. Workflows and schedules
+// are left out; they create these objects for each run.
+var chaosMeshResources = []string{
+	"podchaos", "networkchaos", "iochaos", "stresschaos", "timechaos",
+	"kernelchaos", "dnschaos", "httpchaos", "jvmchaos",
+}
+
+var litmusEngines = schema.GroupVersionResource{Group: "litmuschaos.io", Version: "v1alpha1", Resource: "chaosengines"}
+
+// Experiment is a fault injection run, as recorded on the crashes it
+// overlaps.
+type Experiment struct {
+	Source           string    `json:"source"`
+	Kind             string    `json:"kind"`
+	Namespace        string    `json:"namespace"`
+	Name             string    `json:"name"`
+	Fault            string    `json:"fault,omitempty"`
+	TargetNamespaces []string  `json:"targetNamespaces,omitempty"`
+	Start            time.Time `json:"start"`
+	// End is zero while the experiment is running.
+	End time.Time `json:"end,omitempty"`
+}
+
+func (e *Experiment) key() string {
+	return e.Source + "/" + e.Kind + "/" + e.Namespace + "/" + e.Name
+}
+
+// overlaps reports whether the experiment was running within margin of at
+// and targeted namespace. An unknown namespace matches every experiment.
+func (e *Experiment) overlaps(namespace string, at time.Time, margin time.Duration) bool {
+	if at.Before(e.Start.Add(-margin)) {
+		return false
+	}
+	if !e.End.IsZero() && at.After(e.End.Add(margin)) {
+		return false
+	}
+	if namespace == "" {
+		return true
+	}
+	for _, target := range e.TargetNamespaces {
+		if target == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracker polls the chaos CRs. Ended experiments are kept for the retention
+// period so cores analyzed late are still matched. A nil *Tracker knows of
+// no experiments.
+type Tracker struct {
+	config *config.ChaosConfig
+	client dynamic.Interface
+
+	mu          sync.RWMutex
+	experiments map[string]*Experiment
+	missing     map[string]bool
+}
+
+func New(config *config.ChaosConfig, client dynamic.Interface) *Tracker {
+	return &Tracker{
+		config:      config,
+		client:      client,
+		experiments: make(map[string]*Experiment),
+		missing:     make(map[string]bool),
+	}
+}
+
+func (t *Tracker) Start(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	klog.Info("Starting chaos experiment tracker")
+
+	interval := t.config.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.poll(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.poll(ctx, time.Now())
+		}
+	}
+}
+
+// Overlapping returns the experiments that were running in namespace around
+// the given time, oldest first.
+func (t *Tracker) Overlapping(namespace string, at time.Time) []Experiment {
+	if t == nil {
+		return nil
+	}
+	margin := t.config.Margin
+	if margin <= 0 {
+		margin = defaultMargin
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var overlapping []Experiment
+	for _, experiment := range t.experiments {
+		if experiment.overlaps(namespace, at, margin) {
+			overlapping = append(overlapping, *experiment)
+		}
+	}
+	sort.Slice(overlapping, func(i, j int) bool {
+		return overlapping[i].Start.Before(overlapping[j].Start)
+	})
+	return overlapping
+}
+
+func (t *Tracker) poll(ctx context.Context, now time.Time) {
+	var seen []*Experiment
+	if t.config.ChaosMesh {
+		for _, resource := range chaosMeshResources {
+			gvr := schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: resource}
+			for _, item := range t.list(ctx, gvr) {
+				seen = append(seen, parseChaosMesh(&item, now))
+			}
+		}
+	}
+	if t.config.Litmus {
+		for _, item := range t.list(ctx, litmusEngines) {
+			seen = append(seen, parseLitmus(&item, now))
+		}
+	}
+	t.update(seen, now)
+}
+
+func (t *Tracker) list(ctx context.Context, gvr schema.GroupVersionResource) []unstructured.Unstructured {
+	list, err := t.client.Resource(gvr).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// Clusters usually have only some of the fault kinds installed.
+		if apierrors.IsNotFound(err) {
+			if !t.missing[gvr.Resource] {
+				t.missing[gvr.Resource] = true
+				klog.V(2).Infof("Chaos resource %s is not installed", gvr.GroupResource())
+			}
+		} else {
+			klog.Warningf("Failed to list %s: %v", gvr.GroupResource(), err)
+		}
+		return nil
+	}
+	delete(t.missing, gvr.Resource)
+	return list.Items
+}
+
+// update replaces the running experiments with the ones just seen. A running
+// experiment whose CR disappeared ended at the latest now.
+func (t *Tracker) update(seen []*Experiment, now time.Time) {
+	retention := t.config.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]bool, len(seen))
+	for _, experiment := range seen {
+		key := experiment.key()
+		current[key] = true
+		previous, exists := t.experiments[key]
+		if exists && previous.Start.Equal(experiment.Start) {
+			// A stopped experiment's CR may linger; keep the first end
+			// time seen rather than moving it forward on every poll.
+			if !previous.End.IsZero() && (experiment.End.IsZero() || previous.End.Before(experiment.End)) {
+				experiment.End = previous.End
+			}
+		} else {
+			klog.Infof("Tracking %s experiment %s/%s (%s)", experiment.Source, experiment.Namespace, experiment.Name, experiment.Fault)
+		}
+		t.experiments[key] = experiment
+	}
+
+	for key, experiment := range t.experiments {
+		if !current[key] && experiment.End.IsZero() {
+			experiment.End = now
+		}
+		if !experiment.End.IsZero() && now.Sub(experiment.End) > retention {
+			delete(t.experiments, key)
+		}
+	}
+}
+
+// parseChaosMesh reads a Chaos Mesh fault object. Its selector defaults to
+// the object's own namespace; it ends after spec.duration, or when recovered
+// or paused.
+func parseChaosMesh(item *unstructured.Unstructured, now time.Time) *Experiment {
+	experiment := &Experiment{
+		Source:    SourceChaosMesh,
+		Kind:      item.GetKind(),
+		Namespace: item.GetNamespace(),
+		Name:      item.GetName(),
+		Start:     item.GetCreationTimestamp().Time,
+	}
+
+	action, _, _ := unstructured.NestedString(item.Object, "spec", "action")
+	mode, _, _ := unstructured.NestedString(item.Object, "spec", "mode")
+	experiment.Fault = strings.TrimSuffix(fmt.Sprintf("%s %s", strings.ToLower(experiment.Kind), action), " ")
+	if mode != "" {
+		experiment.Fault += " (" + mode + ")"
+	}
+
+	namespaces, _, _ := unstructured.NestedStringSlice(item.Object, "spec", "selector", "namespaces")
+	if len(namespaces) == 0 {
+		namespaces = []string{item.GetNamespace()}
+	}
+	experiment.TargetNamespaces = namespaces
+
+	if value, _, _ := unstructured.NestedString(item.Object, "spec", "duration"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			if end := experiment.Start.Add(duration); end.Before(now) {
+				experiment.End = end
+			}
+		}
+	}
+	if experiment.End.IsZero() && (conditionTrue(item, "AllRecovered") || conditionTrue(item, "Paused")) {
+		experiment.End = now
+	}
+	if deleted := item.GetDeletionTimestamp(); deleted != nil && experiment.End.IsZero() {
+		experiment.End = deleted.Time
+	}
+	return experiment
+}
+
+// parseLitmus reads a LitmusChaos engine, which targets spec.appinfo.appns and
+// runs until its engine status is completed or stopped.
+func parseLitmus(item *unstructured.Unstructured, now time.Time) *Experiment {
+	experiment := &Experiment{
+		Source:    SourceLitmus,
+		Kind:      item.GetKind(),
+		Namespace: item.GetNamespace(),
+		Name:      item.GetName(),
+		Start:     item.GetCreationTimestamp().Time,
+	}
+
+	var names []string
+	experiments, _, _ := unstructured.NestedSlice(item.Object, "spec", "experiments")
+	for _, entry := range experiments {
+		if fields, ok := entry.(map[string]interface{}); ok {
+			if name, ok := fields["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	experiment.Fault = strings.Join(names, ", ")
+
+	namespace, _, _ := unstructured.NestedString(item.Object, "spec", "appinfo", "appns")
+	if namespace == "" {
+		namespace = item.GetNamespace()
+	}
+	experiment.TargetNamespaces = []string{namespace}
+
+	engineStatus, _, _ := unstructured.NestedString(item.Object, "status", "engineStatus")
+	engineState, _, _ := unstructured.NestedString(item.Object, "spec", "engineState")
+	if engineStatus == "completed" || engineStatus == "stopped" || engineState == "stop" {
+		experiment.End = now
+	}
+	return experiment
+}
+
+func conditionTrue(item *unstructured.Unstructured, conditionType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+	for _, entry := range conditions {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if fields["type"] == conditionType && fields["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
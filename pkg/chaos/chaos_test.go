@@ -0,0 +1,126 @@
+package chaos
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func chaosObject(apiVersion, kind, namespace, name string, created time.Time, spec, status map[string]interface{}) *unstructured.Unstructured {
+	item := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"spec":       spec,
+	}}
+	if status != nil {
+		item.Object["status"] = status
+	}
+	item.SetNamespace(namespace)
+	item.SetName(name)
+	item.SetCreationTimestamp(metav1.NewTime(created))
+	return item
+}
+
+// newFakeClient creates the objects through their resource; the fake client
+// would otherwise guess "podchaoses" from the kind.
+func newFakeClient(t *testing.T, objects ...*unstructured.Unstructured) *dynamicfake.FakeDynamicClient {
+	t.Helper()
+	listKinds := map[schema.GroupVersionResource]string{litmusEngines: "ChaosEngineList"}
+	for _, resource := range chaosMeshResources {
+		listKinds[schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: resource}] = resource + "List"
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	for _, object := range objects {
+		gvr := litmusEngines
+		if object.GroupVersionKind().Group == "chaos-mesh.org" {
+			gvr = schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: strings.ToLower(object.GetKind())}
+		}
+		if _, err := client.Resource(gvr).Namespace(object.GetNamespace()).Create(context.Background(), object, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func TestTrackerMatchesRunningExperiments(t *testing.T) {
+	start := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	podChaos := chaosObject("chaos-mesh.org/v1alpha1", "PodChaos", "chaos-testing", "kill-querynode", start,
+		map[string]interface{}{
+			"action":   "pod-kill",
+			"mode":     "one",
+			"selector": map[string]interface{}{"namespaces": []interface{}{"milvus"}},
+		}, nil)
+	engine := chaosObject("litmuschaos.io/v1alpha1", "ChaosEngine", "litmus", "network-loss", start,
+		map[string]interface{}{
+			"appinfo":     map[string]interface{}{"appns": "other"},
+			"experiments": []interface{}{map[string]interface{}{"name": "pod-network-loss"}},
+		}, map[string]interface{}{"engineStatus": "initialized"})
+
+	tracker := New(&config.ChaosConfig{ChaosMesh: true, Litmus: true}, newFakeClient(t, podChaos, engine))
+	tracker.poll(context.Background(), time.Now())
+
+	overlapping := tracker.Overlapping("milvus", time.Now())
+	if len(overlapping) != 1 {
+		t.Fatalf("expected 1 experiment for namespace milvus, got %v", overlapping)
+	}
+	if overlapping[0].Name != "kill-querynode" || overlapping[0].Fault != "podchaos pod-kill (one)" {
+		t.Errorf("unexpected experiment: %+v", overlapping[0])
+	}
+	if len(tracker.Overlapping("other", time.Now())) != 1 {
+		t.Error("expected the litmus engine to target namespace other")
+	}
+	if len(tracker.Overlapping("milvus", start.Add(-time.Hour))) != 0 {
+		t.Error("crash before the experiment started must not match")
+	}
+}
+
+func TestTrackerEndsExperiments(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-time.Hour)
+	// The five minute duration ended long ago.
+	expired := chaosObject("chaos-mesh.org/v1alpha1", "NetworkChaos", "milvus", "delay", start,
+		map[string]interface{}{"action": "delay", "duration": "5m"}, nil)
+	running := chaosObject("chaos-mesh.org/v1alpha1", "StressChaos", "milvus", "cpu", start,
+		map[string]interface{}{}, nil)
+
+	client := newFakeClient(t, expired, running)
+	tracker := New(&config.ChaosConfig{ChaosMesh: true, Margin: time.Minute}, client)
+	tracker.poll(context.Background(), now)
+
+	overlapping := tracker.Overlapping("milvus", now)
+	if len(overlapping) != 1 || overlapping[0].Name != "cpu" {
+		t.Fatalf("expected only the running experiment, got %v", overlapping)
+	}
+	if len(tracker.Overlapping("milvus", start.Add(3*time.Minute))) != 2 {
+		t.Error("expected both experiments during the delay's duration")
+	}
+
+	// A deleted CR ends the experiment but it is still matched afterwards.
+	gvr := schema.GroupVersionResource{Group: "chaos-mesh.org", Version: "v1alpha1", Resource: "stresschaos"}
+	if err := client.Resource(gvr).Namespace("milvus").Delete(context.Background(), "cpu", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tracker.poll(context.Background(), now.Add(time.Minute))
+	if len(tracker.Overlapping("milvus", now.Add(time.Minute))) != 1 {
+		t.Error("expected the deleted experiment to match within the margin")
+	}
+	if len(tracker.Overlapping("milvus", now.Add(10*time.Minute))) != 0 {
+		t.Error("expected no experiment long after the deletion")
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	if tracker.Overlapping("milvus", time.Now()) != nil {
+		t.Error("nil tracker must know of no experiments")
+	}
+}
@@ -3,36 +3,43 @@ package cleaner
 import (
 	"context"
 	"fmt"
-	"os/exec"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"milvus-coredump-agent/pkg/chanstats"
 	"milvus-coredump-agent/pkg/config"
 	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/leader"
 	"milvus-coredump-agent/pkg/storage"
+	"milvus-coredump-agent/pkg/tracing"
 )
 
 type Cleaner struct {
-	config        *config.CleanerConfig
+	// Swapped by UpdateConfig; see config
+	settings      atomic.Pointer[config.CleanerConfig]
 	kubeClient    kubernetes.Interface
 	discovery     *discovery.Discovery
+	leader        *leader.Elector
 	restartCounts map[string]*RestartTracker
 	mu            sync.RWMutex
 	eventChan     chan CleanupEvent
+	helmConfig    helmConfigFunc
+	statePath     string
 }
 
 type RestartTracker struct {
-	Count       int
-	FirstRestart time.Time
-	LastRestart  time.Time
-	InstanceName string
-	Namespace    string
-	Cleaned      bool
+	Count        int       `json:"count"`
+	FirstRestart time.Time `json:"firstRestart"`
+	LastRestart  time.Time `json:"lastRestart"`
+	InstanceName string    `json:"instanceName"`
+	Namespace    string    `json:"namespace"`
+	Cleaned      bool      `json:"cleaned"`
 }
 
 type CleanupEvent struct {
@@ -41,6 +48,9 @@ type CleanupEvent struct {
 	Namespace    string    `json:"namespace"`
 	Reason       string    `json:"reason"`
 	Error        string    `json:"error,omitempty"`
+	// RestartCount is the number of restarts within the window that led
+	// to the event.
+	RestartCount int       `json:"restartCount,omitempty"`
 	Timestamp    time.Time `json:"timestamp"`
 }
 
@@ -53,18 +63,41 @@ const (
 	EventTypeRestartThreshold    EventType = "restart_threshold_exceeded"
 )
 
-func New(config *config.CleanerConfig, kubeClient kubernetes.Interface, discovery *discovery.Discovery) *Cleaner {
-	return &Cleaner{
-		config:        config,
+// New creates the cleaner. Helm releases are uninstalled with the Helm SDK,
+// using kubeconfig when set and the in-cluster credentials otherwise. When
+// statePath is set, restart trackers are kept there across agent restarts.
+// Every agent tracks restarts, but with an elector only the leader
+// uninstalls instances.
+func New(config *config.CleanerConfig, kubeClient kubernetes.Interface, discovery *discovery.Discovery, leader *leader.Elector, kubeconfig, statePath string) *Cleaner {
+	cleaner := &Cleaner{
 		kubeClient:    kubeClient,
 		discovery:     discovery,
+		leader:        leader,
 		restartCounts: make(map[string]*RestartTracker),
 		eventChan:     make(chan CleanupEvent, 100),
+		helmConfig:    newHelmConfigFunc(kubeconfig, config.UninstallTimeout),
+		statePath:     statePath,
 	}
+	cleaner.settings.Store(config)
+	cleaner.loadRestartCounts()
+	chanstats.Register("cleaner_events", cleaner.eventChan)
+	return cleaner
+}
+
+// config returns the cleaner's current configuration, which UpdateConfig
+// may swap at any time.
+func (c *Cleaner) config() *config.CleanerConfig {
+	return c.settings.Load()
+}
+
+// UpdateConfig swaps in a reloaded configuration. Helm uninstalls keep the
+// timeout the cleaner was created with.
+func (c *Cleaner) UpdateConfig(config *config.CleanerConfig) {
+	c.settings.Store(config)
 }
 
 func (c *Cleaner) Start(ctx context.Context, storageEvents <-chan storage.StorageEvent) error {
-	if !c.config.Enabled {
+	if !c.config().Enabled {
 		klog.Info("Auto cleanup is disabled")
 		return nil
 	}
@@ -105,7 +138,10 @@ func (c *Cleaner) monitorStorageEvents(ctx context.Context, storageEvents <-chan
 			return
 		case event := <-storageEvents:
 			if event.Type == storage.EventTypeFileStored && event.CoredumpFile != nil {
-				c.evaluateForCleanup(event.CoredumpFile.InstanceName, event.CoredumpFile.PodNamespace)
+				_, span := tracing.Start(ctx, event.CoredumpFile.TraceParent, tracing.SpanCleanup)
+				scheduled := c.evaluateForCleanup(event.CoredumpFile.InstanceName, event.CoredumpFile.PodNamespace)
+				span.SetAttributes(attribute.Bool("cleanup.scheduled", scheduled))
+				span.End()
 			}
 		}
 	}
@@ -129,7 +165,7 @@ func (c *Cleaner) handleRestartEvent(event discovery.RestartEvent) {
 		}
 		c.restartCounts[key] = tracker
 	} else {
-		if time.Since(tracker.FirstRestart) > c.config.RestartTimeWindow {
+		if time.Since(tracker.FirstRestart) > c.config().RestartTimeWindow {
 			tracker.Count = 1
 			tracker.FirstRestart = event.RestartTime.Time
 		} else {
@@ -137,19 +173,21 @@ func (c *Cleaner) handleRestartEvent(event discovery.RestartEvent) {
 		}
 		tracker.LastRestart = event.RestartTime.Time
 	}
+	c.saveRestartCounts()
 
 	klog.V(2).Infof("Restart count for %s: %d (within %v window)", 
-		key, tracker.Count, c.config.RestartTimeWindow)
+		key, tracker.Count, c.config().RestartTimeWindow)
 
-	if tracker.Count >= c.config.MaxRestartCount && !tracker.Cleaned {
+	if tracker.Count >= c.config().MaxRestartCount && !tracker.Cleaned {
 		klog.Warningf("Instance %s has exceeded restart threshold (%d), scheduling for cleanup", 
-			key, c.config.MaxRestartCount)
+			key, c.config().MaxRestartCount)
 
 		cleanupEvent := CleanupEvent{
 			Type:         EventTypeRestartThreshold,
 			InstanceName: event.InstanceName,
 			Namespace:    event.PodNamespace,
-			Reason:       fmt.Sprintf("Exceeded restart threshold: %d restarts in %v", tracker.Count, c.config.RestartTimeWindow),
+			Reason:       fmt.Sprintf("Exceeded restart threshold: %d restarts in %v", tracker.Count, c.config().RestartTimeWindow),
+			RestartCount: tracker.Count,
 			Timestamp:    time.Now(),
 		}
 		c.sendEvent(cleanupEvent)
@@ -159,9 +197,13 @@ func (c *Cleaner) handleRestartEvent(event discovery.RestartEvent) {
 }
 
 func (c *Cleaner) scheduleCleanup(instanceName, namespace string, tracker *RestartTracker) {
-	time.Sleep(c.config.CleanupDelay)
+	time.Sleep(c.config().CleanupDelay)
 
 	key := fmt.Sprintf("%s/%s", namespace, instanceName)
+	if !c.leader.IsLeader() {
+		klog.Infof("Not the leader, leaving the cleanup of instance %s to it", key)
+		return
+	}
 	
 	c.mu.Lock()
 	if tracker.Cleaned {
@@ -170,6 +212,8 @@ func (c *Cleaner) scheduleCleanup(instanceName, namespace string, tracker *Resta
 		return
 	}
 	tracker.Cleaned = true
+	restarts := tracker.Count
+	c.saveRestartCounts()
 	c.mu.Unlock()
 
 	if err := c.cleanupInstance(instanceName, namespace); err != nil {
@@ -180,12 +224,14 @@ func (c *Cleaner) scheduleCleanup(instanceName, namespace string, tracker *Resta
 			InstanceName: instanceName,
 			Namespace:    namespace,
 			Error:        err.Error(),
+			RestartCount: restarts,
 			Timestamp:    time.Now(),
 		}
 		c.sendEvent(event)
 		
 		c.mu.Lock()
 		tracker.Cleaned = false
+		c.saveRestartCounts()
 		c.mu.Unlock()
 	} else {
 		klog.Infof("Successfully cleaned up instance: %s", key)
@@ -195,15 +241,19 @@ func (c *Cleaner) scheduleCleanup(instanceName, namespace string, tracker *Resta
 			InstanceName: instanceName,
 			Namespace:    namespace,
 			Reason:       "Automatic cleanup due to repeated crashes",
+			RestartCount: restarts,
 			Timestamp:    time.Now(),
 		}
+		c.discovery.RecordCleanup(namespace, instanceName, event.Reason)
 		c.sendEvent(event)
 	}
 }
 
-func (c *Cleaner) evaluateForCleanup(instanceName, namespace string) {
+// evaluateForCleanup schedules the cleanup of an instance that exceeded
+// the restart threshold, and reports whether it did.
+func (c *Cleaner) evaluateForCleanup(instanceName, namespace string) bool {
 	if instanceName == "" || namespace == "" {
-		return
+		return false
 	}
 
 	key := fmt.Sprintf("%s/%s", namespace, instanceName)
@@ -212,10 +262,12 @@ func (c *Cleaner) evaluateForCleanup(instanceName, namespace string) {
 	tracker, exists := c.restartCounts[key]
 	c.mu.RUnlock()
 
-	if exists && tracker.Count >= c.config.MaxRestartCount && !tracker.Cleaned {
+	if exists && tracker.Count >= c.config().MaxRestartCount && !tracker.Cleaned {
 		klog.Infof("Evaluating instance %s for immediate cleanup due to stored coredump", key)
 		go c.scheduleCleanup(instanceName, namespace, tracker)
+		return true
 	}
+	return false
 }
 
 func (c *Cleaner) cleanupInstance(instanceName, namespace string) error {
@@ -226,42 +278,26 @@ func (c *Cleaner) cleanupInstance(instanceName, namespace string) error {
 	if !exists {
 		return fmt.Errorf("instance not found: %s", instanceKey)
 	}
+	if instance.Status == discovery.InstanceStatusTerminated {
+		return fmt.Errorf("instance already terminated: %s", instanceKey)
+	}
 
 	switch instance.Type {
 	case discovery.DeploymentTypeHelm:
 		return c.uninstallHelmRelease(instanceName, namespace)
 	case discovery.DeploymentTypeOperator:
 		return c.deleteOperatorInstance(instanceName, namespace)
+	case discovery.DeploymentTypeRegistered, discovery.DeploymentTypeCustom:
+		return fmt.Errorf("instance %s was not deployed by helm or the operator and is not uninstalled automatically", instanceKey)
 	default:
 		return fmt.Errorf("unsupported deployment type: %s", instance.Type)
 	}
 }
 
-func (c *Cleaner) uninstallHelmRelease(releaseName, namespace string) error {
-	klog.Infof("Uninstalling Helm release: %s in namespace %s", releaseName, namespace)
-
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.UninstallTimeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "helm", "uninstall", releaseName, "-n", namespace)
-	output, err := cmd.CombinedOutput()
-	
-	if err != nil {
-		if strings.Contains(string(output), "not found") {
-			klog.Infof("Helm release %s not found, may already be uninstalled", releaseName)
-			return nil
-		}
-		return fmt.Errorf("helm uninstall failed: %v, output: %s", err, string(output))
-	}
-
-	klog.Infof("Helm release %s uninstalled successfully", releaseName)
-	return nil
-}
-
 func (c *Cleaner) deleteOperatorInstance(instanceName, namespace string) error {
 	klog.Infof("Deleting Milvus operator instance: %s in namespace %s", instanceName, namespace)
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.UninstallTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), c.config().UninstallTimeout)
 	defer cancel()
 
 	deleteOptions := metav1.DeleteOptions{}
@@ -307,14 +343,19 @@ func (c *Cleaner) cleanupOldTrackers() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	cutoff := time.Now().Add(-24 * time.Hour)
+	cutoff := time.Now().Add(-trackerRetention)
 	
+	removed := false
 	for key, tracker := range c.restartCounts {
 		if tracker.LastRestart.Before(cutoff) {
 			delete(c.restartCounts, key)
+			removed = true
 			klog.V(2).Infof("Removed old restart tracker for %s", key)
 		}
 	}
+	if removed {
+		c.saveRestartCounts()
+	}
 }
 
 func (c *Cleaner) GetRestartCounts() map[string]*RestartTracker {
@@ -337,9 +378,7 @@ func (c *Cleaner) GetRestartCounts() map[string]*RestartTracker {
 }
 
 func (c *Cleaner) sendEvent(event CleanupEvent) {
-	select {
-	case c.eventChan <- event:
-	default:
+	if !chanstats.TrySend("cleaner_events", c.eventChan, event) {
 		klog.Warning("Cleanup event channel is full, dropping event")
 	}
 }
\ No newline at end of file
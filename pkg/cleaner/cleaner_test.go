@@ -0,0 +1,27 @@
+package cleaner
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/leader"
+)
+
+func TestFollowerLeavesCleanupToLeader(t *testing.T) {
+	follower := leader.New(&config.LeaderElectionConfig{}, fake.NewSimpleClientset(), "node-b", "milvus-system")
+	cleaner := New(&config.CleanerConfig{MaxRestartCount: 1}, nil, nil, follower, "", "")
+
+	tracker := &RestartTracker{Count: 3, InstanceName: "prod", Namespace: "milvus"}
+	cleaner.scheduleCleanup("prod", "milvus", tracker)
+
+	if tracker.Cleaned {
+		t.Error("expected a follower not to clean up the instance")
+	}
+	select {
+	case event := <-cleaner.GetEventChannel():
+		t.Errorf("expected no cleanup event from a follower, got %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,67 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog/v2"
+)
+
+// helmConfigFunc sets up the Helm SDK for the releases of a namespace.
+type helmConfigFunc func(namespace string) (*action.Configuration, error)
+
+// newHelmConfigFunc uses the agent's in-cluster credentials, or kubeconfig
+// when set. Release records are read with Helm's default storage driver,
+// Secrets, unless HELM_DRIVER selects another as for the helm CLI. Every
+// API request is bounded by timeout.
+func newHelmConfigFunc(kubeconfig string, timeout time.Duration) helmConfigFunc {
+	return func(namespace string) (*action.Configuration, error) {
+		flags := genericclioptions.NewConfigFlags(false)
+		flags.Namespace = &namespace
+		if kubeconfig != "" {
+			flags.KubeConfig = &kubeconfig
+		}
+		if timeout > 0 {
+			requestTimeout := timeout.String()
+			flags.Timeout = &requestTimeout
+		}
+
+		configuration := new(action.Configuration)
+		logf := func(format string, v ...interface{}) {
+			klog.V(2).Infof("helm: "+format, v...)
+		}
+		if err := configuration.Init(flags, namespace, os.Getenv("HELM_DRIVER"), logf); err != nil {
+			return nil, err
+		}
+		return configuration, nil
+	}
+}
+
+func (c *Cleaner) uninstallHelmRelease(releaseName, namespace string) error {
+	klog.Infof("Uninstalling Helm release: %s in namespace %s", releaseName, namespace)
+
+	configuration, err := c.helmConfig(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to set up Helm for namespace %s: %w", namespace, err)
+	}
+
+	uninstall := action.NewUninstall(configuration)
+	// Bounds the pre- and post-delete hooks; the release's resources are
+	// deleted without waiting for them to go away.
+	uninstall.Timeout = c.config().UninstallTimeout
+	if _, err := uninstall.Run(releaseName); err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			klog.Infof("Helm release %s not found, may already be uninstalled", releaseName)
+			return nil
+		}
+		return fmt.Errorf("helm uninstall failed: %w", err)
+	}
+
+	klog.Infof("Helm release %s uninstalled successfully", releaseName)
+	return nil
+}
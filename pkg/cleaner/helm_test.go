@@ -0,0 +1,60 @@
+package cleaner
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func newTestHelmCleaner(t *testing.T, releases ...*release.Release) (*Cleaner, *storage.Storage) {
+	t.Helper()
+	store := storage.Init(driver.NewMemory())
+	for _, rel := range releases {
+		if err := store.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cleaner := New(&config.CleanerConfig{UninstallTimeout: time.Minute}, nil, nil, nil, "", "")
+	cleaner.helmConfig = func(namespace string) (*action.Configuration, error) {
+		return &action.Configuration{
+			Releases:     store,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          t.Logf,
+		}, nil
+	}
+	return cleaner, store
+}
+
+func TestUninstallHelmRelease(t *testing.T) {
+	deployed := &release.Release{
+		Name:      "milvus-prod",
+		Namespace: "milvus",
+		Version:   1,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "milvus", Version: "4.1.0"}},
+		Info:      &release.Info{Status: release.StatusDeployed},
+	}
+	cleaner, store := newTestHelmCleaner(t, deployed)
+
+	if err := cleaner.uninstallHelmRelease("milvus-prod", "milvus"); err != nil {
+		t.Fatalf("uninstall failed: %v", err)
+	}
+	if _, err := store.History("milvus-prod"); err != driver.ErrReleaseNotFound {
+		t.Errorf("expected the release history to be purged, got %v", err)
+	}
+
+	// A release that is already gone counts as uninstalled.
+	if err := cleaner.uninstallHelmRelease("milvus-prod", "milvus"); err != nil {
+		t.Errorf("expected a missing release to be ignored, got %v", err)
+	}
+}
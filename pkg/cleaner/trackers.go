@@ -0,0 +1,45 @@
+package cleaner
+
+import (
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+// trackerRetention is how long a restart tracker is kept after the last
+// restart it counted.
+const trackerRetention = 24 * time.Hour
+
+// loadRestartCounts restores the trackers saved before the agent restarted,
+// leaving out those past retention. An instance over the threshold whose
+// cleanup was still pending is cleaned up on its next restart or stored core.
+func (c *Cleaner) loadRestartCounts() {
+	if c.statePath == "" {
+		return
+	}
+
+	var trackers map[string]*RestartTracker
+	if err := statefile.Load(c.statePath, &trackers); err != nil {
+		klog.Warningf("Starting without restart trackers: %v", err)
+		return
+	}
+	cutoff := time.Now().Add(-trackerRetention)
+	for key, tracker := range trackers {
+		if tracker != nil && !tracker.LastRestart.Before(cutoff) {
+			c.restartCounts[key] = tracker
+		}
+	}
+	klog.Infof("Restored %d restart trackers from %s", len(c.restartCounts), c.statePath)
+}
+
+// saveRestartCounts must be called with c.mu held.
+func (c *Cleaner) saveRestartCounts() {
+	if c.statePath == "" {
+		return
+	}
+	if err := statefile.Save(c.statePath, c.restartCounts); err != nil {
+		klog.Warningf("Failed to save restart trackers: %v", err)
+	}
+}
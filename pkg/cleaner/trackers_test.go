@@ -0,0 +1,40 @@
+package cleaner
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+func TestRestartTrackersSurviveRestart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "restart-trackers.json")
+	cfg := &config.CleanerConfig{MaxRestartCount: 5, RestartTimeWindow: time.Hour}
+
+	cleaner := New(cfg, nil, nil, nil, "", statePath)
+	for i := 0; i < 3; i++ {
+		cleaner.handleRestartEvent(discovery.RestartEvent{
+			InstanceName: "milvus-prod",
+			PodNamespace: "milvus",
+			RestartTime:  metav1.NewTime(time.Now()),
+			IsPanic:      true,
+		})
+	}
+	cleaner.mu.Lock()
+	cleaner.restartCounts["milvus/stale"] = &RestartTracker{Count: 1, LastRestart: time.Now().Add(-48 * time.Hour)}
+	cleaner.saveRestartCounts()
+	cleaner.mu.Unlock()
+
+	restarted := New(cfg, nil, nil, nil, "", statePath)
+	counts := restarted.GetRestartCounts()
+	if tracker := counts["milvus/milvus-prod"]; tracker == nil || tracker.Count != 3 || tracker.InstanceName != "milvus-prod" {
+		t.Errorf("expected the restart count to carry over, got %+v", tracker)
+	}
+	if _, exists := counts["milvus/stale"]; exists {
+		t.Error("expected a tracker past retention to be dropped")
+	}
+}
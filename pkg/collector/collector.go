@@ -2,26 +2,59 @@ package collector
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/klog/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"milvus-coredump-agent/pkg/chanstats"
+	"milvus-coredump-agent/pkg/chaos"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/cri"
+	"milvus-coredump-agent/pkg/crstatus"
 	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/pressure"
+	"milvus-coredump-agent/pkg/tracing"
 )
 
 type Collector struct {
-	config         *config.CollectorConfig
+	// Swapped by UpdateConfig; see config
+	settings       atomic.Pointer[config.CollectorConfig]
 	discovery      *discovery.Discovery
+	pressure       *pressure.Tracker
+	chaos          *chaos.Tracker
+	crs            *crstatus.Tracker
+	containers     *cri.Resolver
+	nodeName       string
 	eventChan      chan CollectionEvent
 	stopChan       chan struct{}
-	processedFiles map[string]bool
+	statePath      string
+
+	mu             sync.Mutex
+	// When each core, by path or coredumpctl key, was processed
+	processedFiles map[string]time.Time
+	// The processed cores that reached a final status; only these are
+	// saved to statePath
+	finished map[string]bool
+	// The keys of the cores in the pipeline, by coredump ID
+	inFlight     map[string][]string
+	observations map[string]*observation
+	staged       map[string]time.Time
+	// Serializes saves to statePath
+	saveMu sync.Mutex
 }
 
 var (
@@ -29,21 +62,64 @@ var (
 	systemdPattern  = regexp.MustCompile(`^core\.([^.]+)\.(\d+)\.([0-9a-f]+)\.(\d+)\.(\d+)$`)
 )
 
-func New(config *config.CollectorConfig, discovery *discovery.Discovery) *Collector {
-	return &Collector{
-		config:         config,
+// SelfTestExecutable is the executable named by the synthetic cores of the
+// pipeline self-test, such as core.coredump-selftest.1234.0.6. They are not
+// associated with a pod, analyzed with gdb or AI, or alerted on.
+const SelfTestExecutable = "coredump-selftest"
+
+// New creates the collector. Cores found are recorded as on nodeName. When
+// statePath is set, the cores already processed are kept there and not
+// picked up again after an agent restart. crs, when set, links cores of
+// operator deployments to their Milvus CR. containers, when set, finds the
+// container each core came from through the container runtime.
+func New(config *config.CollectorConfig, discovery *discovery.Discovery, pressure *pressure.Tracker, chaos *chaos.Tracker, crs *crstatus.Tracker, containers *cri.Resolver, nodeName, statePath string) *Collector {
+	collector := &Collector{
 		discovery:      discovery,
+		pressure:       pressure,
+		chaos:          chaos,
+		crs:            crs,
+		containers:     containers,
+		nodeName:       nodeName,
 		eventChan:      make(chan CollectionEvent, 100),
 		stopChan:       make(chan struct{}),
-		processedFiles: make(map[string]bool),
+		statePath:      statePath,
+		processedFiles: make(map[string]time.Time),
+		finished:       make(map[string]bool),
+		inFlight:       make(map[string][]string),
+		observations:   make(map[string]*observation),
+		staged:         make(map[string]time.Time),
 	}
+	collector.settings.Store(config)
+	collector.loadProcessedFiles()
+	chanstats.Register("collector_events", collector.eventChan)
+	return collector
+}
+
+// config returns the collector's current configuration, which
+// UpdateConfig may swap at any time.
+func (c *Collector) config() *config.CollectorConfig {
+	return c.settings.Load()
+}
+
+// UpdateConfig swaps in a reloaded configuration. The coredump path and
+// watch mode are those the collector started with until it is restarted.
+func (c *Collector) UpdateConfig(config *config.CollectorConfig) {
+	c.settings.Store(config)
 }
 
 func (c *Collector) Start(ctx context.Context) error {
 	klog.Info("Starting coredump collector")
 
+	c.resetStaging()
+
 	go c.watchRestartEvents(ctx)
-	go c.scanCoredumpFiles(ctx)
+	go c.watchCoredumpFiles(ctx)
+	if c.config().Coredumpctl.Enabled {
+		go c.watchJournal(ctx)
+	}
+	if c.config().PipeHandler.Enabled {
+		go c.listenPipe(ctx)
+	}
 
 	<-ctx.Done()
 	close(c.stopChan)
@@ -62,12 +138,12 @@ func (c *Collector) watchRestartEvents(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case event := <-restartChan:
-			c.handleRestartEvent(event)
+			c.handleRestartEvent(ctx, event)
 		}
 	}
 }
 
-func (c *Collector) handleRestartEvent(event discovery.RestartEvent) {
+func (c *Collector) handleRestartEvent(ctx context.Context, event discovery.RestartEvent) {
 	klog.Infof("Handling restart event for pod %s/%s", event.PodNamespace, event.PodName)
 	
 	collectionEvent := CollectionEvent{
@@ -76,18 +152,16 @@ func (c *Collector) handleRestartEvent(event discovery.RestartEvent) {
 		Timestamp:    time.Now(),
 	}
 	
-	select {
-	case c.eventChan <- collectionEvent:
-	default:
+	if !chanstats.TrySend("collector_events", c.eventChan, collectionEvent) {
 		klog.Warning("Event channel is full, dropping restart event")
 	}
 
 	if event.IsPanic {
-		go c.collectCoredumpForRestart(event)
+		go c.collectCoredumpForRestart(ctx, event)
 	}
 }
 
-func (c *Collector) collectCoredumpForRestart(event discovery.RestartEvent) {
+func (c *Collector) collectCoredumpForRestart(ctx context.Context, event discovery.RestartEvent) {
 	maxWait := 30 * time.Second
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
@@ -96,6 +170,8 @@ func (c *Collector) collectCoredumpForRestart(event discovery.RestartEvent) {
 	
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-timeout:
 			klog.Warningf("Timeout waiting for coredump file for restart event %s/%s", 
 				event.PodNamespace, event.PodName)
@@ -103,7 +179,7 @@ func (c *Collector) collectCoredumpForRestart(event discovery.RestartEvent) {
 		case <-ticker.C:
 			if files := c.findCoredumpForRestart(event); len(files) > 0 {
 				for _, file := range files {
-					c.processCoredumpFile(file)
+					c.processCoredumpFile(ctx, file)
 				}
 				return
 			}
@@ -114,7 +190,7 @@ func (c *Collector) collectCoredumpForRestart(event discovery.RestartEvent) {
 func (c *Collector) findCoredumpForRestart(event discovery.RestartEvent) []*CoredumpFile {
 	var files []*CoredumpFile
 	
-	err := filepath.Walk(c.config.CoredumpPath, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(c.config().CoredumpPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
@@ -131,7 +207,7 @@ func (c *Collector) findCoredumpForRestart(event discovery.RestartEvent) []*Core
 			return nil
 		}
 		
-		if c.processedFiles[path] {
+		if c.isProcessed(path) || !c.isComplete(path, info, time.Now()) {
 			return nil
 		}
 		
@@ -151,21 +227,32 @@ func (c *Collector) findCoredumpForRestart(event discovery.RestartEvent) []*Core
 }
 
 func (c *Collector) scanCoredumpFiles(ctx context.Context) {
-	ticker := time.NewTicker(c.config.WatchInterval)
+	ticker := time.NewTicker(c.config().WatchInterval)
 	defer ticker.Stop()
 
+	skipped := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			c.scanDirectory()
+			if c.pressure.Degraded() {
+				skipped++
+				if skipped < c.pressure.DegradedScanFactor() {
+					continue
+				}
+			}
+			skipped = 0
+			c.scanDirectory(ctx)
 		}
 	}
 }
 
-func (c *Collector) scanDirectory() {
-	err := filepath.Walk(c.config.CoredumpPath, func(path string, info os.FileInfo, err error) error {
+func (c *Collector) scanDirectory(ctx context.Context) {
+	now := time.Now()
+	defer c.cleanStaging(now)
+
+	err := filepath.Walk(c.config().CoredumpPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
 		}
@@ -178,17 +265,17 @@ func (c *Collector) scanDirectory() {
 			return nil
 		}
 		
-		if time.Since(info.ModTime()) > c.config.MaxFileAge {
+		if time.Since(info.ModTime()) > c.config().MaxFileAge {
 			return nil
 		}
 		
-		if c.processedFiles[path] {
+		if c.isProcessed(path) || !c.isComplete(path, info, now) {
 			return nil
 		}
 		
 		coredumpFile := c.parseCoredumpFile(path, info)
 		if coredumpFile != nil {
-			c.processCoredumpFile(coredumpFile)
+			c.processCoredumpFile(ctx, coredumpFile)
 		}
 		
 		return nil
@@ -200,15 +287,45 @@ func (c *Collector) scanDirectory() {
 }
 
 func (c *Collector) isCoredumpFile(filename string) bool {
+	// systemd-coredump's cores are extracted through coredumpctl instead.
+	if c.config().Coredumpctl.Enabled && systemdCoredumpPattern.MatchString(filename) {
+		return false
+	}
 	return coredumpPattern.MatchString(filename) || 
 		   systemdPattern.MatchString(filename) ||
 		   strings.HasPrefix(filename, "core.")
 }
 
 func (c *Collector) parseCoredumpFile(path string, info os.FileInfo) *CoredumpFile {
+	return c.annotate(NewCoredumpFile(path, info))
+}
+
+// annotate associates the core with its node, pod and Milvus instance, once
+// what the core's source knows about the crashed process is set.
+func (c *Collector) annotate(coredump *CoredumpFile) *CoredumpFile {
+	coredump.NodeName = c.nodeName
+
+	if coredump.Executable == SelfTestExecutable {
+		coredump.SelfTest = true
+		return coredump
+	}
+
+	c.enrichWithPodInfo(coredump)
+	c.annotateChaos(coredump)
+	c.annotateMilvusCR(coredump)
+	
+	return coredump
+}
+
+// NewCoredumpFile describes the core at path from its file information, and
+// the executable, PID, UID and signal its name records, as in
+// core.<executable>.<pid>.<uid>.<signal> or the name systemd-coredump gives
+// it, which has no signal. It is not associated with a pod.
+func NewCoredumpFile(path string, info os.FileInfo) *CoredumpFile {
 	filename := info.Name()
 	
 	coredump := &CoredumpFile{
+		ID:        CoredumpID(path, info.ModTime()),
 		Path:      path,
 		FileName:  filename,
 		Size:      info.Size(),
@@ -230,6 +347,17 @@ func (c *Collector) parseCoredumpFile(path string, info os.FileInfo) *CoredumpFi
 		if signal, err := strconv.Atoi(matches[4]); err == nil {
 			coredump.Signal = signal
 		}
+	} else if matches := systemdCoredumpPattern.FindStringSubmatch(filename); len(matches) >= 6 {
+		coredump.Executable = matches[1]
+		if uid, err := strconv.Atoi(matches[2]); err == nil {
+			coredump.UID = uid
+		}
+		if pid, err := strconv.Atoi(matches[4]); err == nil {
+			coredump.PID = pid
+		}
+		if micros, err := strconv.ParseInt(matches[5], 10, 64); err == nil {
+			coredump.Timestamp = time.UnixMicro(micros)
+		}
 	} else if matches := systemdPattern.FindStringSubmatch(filename); len(matches) >= 6 {
 		coredump.Executable = matches[1]
 		if pid, err := strconv.Atoi(matches[2]); err == nil {
@@ -243,12 +371,20 @@ func (c *Collector) parseCoredumpFile(path string, info os.FileInfo) *CoredumpFi
 		}
 	}
 
-	c.enrichWithPodInfo(coredump)
-	
 	return coredump
 }
 
+// CoredumpID derives a stable identifier from the file path and modification
+// time, so a core rewritten under the same name gets a new ID.
+func CoredumpID(path string, modTime time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", path, modTime.UnixNano())))
+	return hex.EncodeToString(sum[:8])
+}
+
 func (c *Collector) enrichWithPodInfo(coredump *CoredumpFile) {
+	if c.enrichFromRuntime(coredump) {
+		return
+	}
 	instances := c.discovery.GetInstances()
 	
 	for _, instance := range instances {
@@ -257,12 +393,16 @@ func (c *Collector) enrichWithPodInfo(coredump *CoredumpFile) {
 				coredump.PodName = pod.Name
 				coredump.PodNamespace = pod.Namespace
 				coredump.InstanceName = instance.Name
+				coredump.InstanceLabels = instance.Labels
+				coredump.Component = pod.Component
+				coredump.MilvusVersion = pod.MilvusVersion
 				
-				for _, containerStatus := range pod.ContainerStatuses {
-					if strings.Contains(coredump.Executable, containerStatus.Name) {
-						coredump.ContainerName = containerStatus.Name
-						break
-					}
+				if container := crashedContainer(pod, coredump); container != nil {
+					coredump.ContainerName = container.Name
+					coredump.ContainerType = container.Type
+					coredump.ContainerID = container.ContainerID
+					coredump.Image = container.Image
+					coredump.ImageID = container.ImageID
 				}
 				return
 			}
@@ -270,6 +410,151 @@ func (c *Collector) enrichWithPodInfo(coredump *CoredumpFile) {
 	}
 }
 
+// enrichFromRuntime attributes the core to the container the runtime ran
+// the crashed process in. It returns false when the runtime cannot tell,
+// leaving it to be guessed from the executable and restart times.
+func (c *Collector) enrichFromRuntime(coredump *CoredumpFile) bool {
+	if c.containers == nil {
+		return false
+	}
+	container, err := c.containers.Resolve(context.Background(), cri.Process{
+		PID:        coredump.PID,
+		Executable: coredump.Executable,
+		Signal:     coredump.Signal,
+		Time:       coredump.ModTime,
+		Cgroup:     coredump.Cgroup,
+	})
+	if errors.Is(err, cri.ErrNoContainer) {
+		klog.V(2).Infof("Coredump %s is of a process outside of containers", coredump.FileName)
+		return true
+	}
+	if err != nil {
+		klog.Warningf("Failed to find the container of coredump %s: %v", coredump.FileName, err)
+		return false
+	}
+	if container == nil {
+		return false
+	}
+
+	for _, instance := range c.discovery.GetInstances() {
+		for _, pod := range instance.Pods {
+			status, found := podContainer(pod, container)
+			if !found {
+				continue
+			}
+			coredump.InstanceName = instance.Name
+			coredump.InstanceLabels = instance.Labels
+			coredump.Component = pod.Component
+			coredump.MilvusVersion = pod.MilvusVersion
+			coredump.PodName = pod.Name
+			coredump.PodNamespace = pod.Namespace
+			if status != nil {
+				coredump.ContainerName = status.Name
+				coredump.ContainerType = status.Type
+				coredump.Image = status.Image
+				coredump.ImageID = status.ImageID
+			}
+			setRuntimeContainer(coredump, container)
+			return true
+		}
+	}
+	// Not of a Milvus pod; without its pod's name the core is left to the
+	// guess.
+	if container.PodName == "" {
+		return false
+	}
+	coredump.PodName = container.PodName
+	coredump.PodNamespace = container.PodNamespace
+	setRuntimeContainer(coredump, container)
+	return true
+}
+
+func setRuntimeContainer(coredump *CoredumpFile, container *cri.Container) {
+	coredump.ContainerID = container.ID
+	coredump.PodUID = container.PodUID
+	if container.Name != "" {
+		coredump.ContainerName = container.Name
+	}
+	if container.Image != "" {
+		coredump.Image = container.Image
+	}
+}
+
+// podContainer reports whether container belongs to pod, and returns its
+// status in the pod when it has one. Without the pod's name, as when only
+// the cgroup is known, the container is found by its ID, which the pod
+// reports until the container is replaced.
+func podContainer(pod discovery.PodInfo, container *cri.Container) (*discovery.ContainerStatusInfo, bool) {
+	if container.PodName != "" {
+		if pod.Name != container.PodName || pod.Namespace != container.PodNamespace {
+			return nil, false
+		}
+		for i := range pod.ContainerStatuses {
+			if pod.ContainerStatuses[i].Name == container.Name {
+				return &pod.ContainerStatuses[i], true
+			}
+		}
+		return nil, true
+	}
+	for i := range pod.ContainerStatuses {
+		if pod.ContainerStatuses[i].ContainerID == container.ID {
+			return &pod.ContainerStatuses[i], true
+		}
+	}
+	return nil, false
+}
+
+// crashedContainer picks the container the core came from: the one named
+// after the executable, else the one that terminated closest to when the
+// core was written. The latter attributes cores of init and ephemeral
+// containers, whose names rarely match the executable.
+func crashedContainer(pod discovery.PodInfo, coredump *CoredumpFile) *discovery.ContainerStatusInfo {
+	for i := range pod.ContainerStatuses {
+		if strings.Contains(coredump.Executable, pod.ContainerStatuses[i].Name) {
+			return &pod.ContainerStatuses[i]
+		}
+	}
+
+	var closest *discovery.ContainerStatusInfo
+	closestDiff := 5 * time.Minute
+	for i := range pod.ContainerStatuses {
+		container := &pod.ContainerStatuses[i]
+		if container.LastTerminatedAt.IsZero() {
+			continue
+		}
+		if diff := coredump.ModTime.Sub(container.LastTerminatedAt.Time).Abs(); diff < closestDiff {
+			closest = container
+			closestDiff = diff
+		}
+	}
+	return closest
+}
+
+// annotateChaos records the chaos experiments that were running against the
+// crashed pod's namespace when the core was written, so injected failures
+// can be triaged apart from real ones.
+func (c *Collector) annotateChaos(coredump *CoredumpFile) {
+	experiments := c.chaos.Overlapping(coredump.PodNamespace, coredump.ModTime)
+	if len(experiments) == 0 {
+		return
+	}
+	coredump.UnderChaos = true
+	coredump.ChaosExperiments = experiments
+	klog.Infof("Coredump %s happened under chaos: %s/%s (%s)",
+		coredump.FileName, experiments[0].Namespace, experiments[0].Name, experiments[0].Fault)
+}
+
+// annotateMilvusCR links the core to the Milvus CR its instance was
+// deployed from. The operator names the instance's pods after the CR.
+func (c *Collector) annotateMilvusCR(coredump *CoredumpFile) {
+	if coredump.InstanceName == "" {
+		return
+	}
+	if resource := c.crs.Resource(coredump.PodNamespace, coredump.InstanceName); resource != nil {
+		coredump.MilvusCR = resource.Name
+	}
+}
+
 func (c *Collector) isPodRelatedToCoredump(pod discovery.PodInfo, coredump *CoredumpFile) bool {
 	if strings.Contains(coredump.Executable, "milvus") {
 		return true
@@ -296,13 +581,44 @@ func (c *Collector) isRelatedToRestart(coredump *CoredumpFile, event discovery.R
 	return false
 }
 
-func (c *Collector) processCoredumpFile(coredump *CoredumpFile) {
-	c.processedFiles[coredump.Path] = true
+func (c *Collector) isProcessed(path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, processed := c.processedFiles[path]
+	return processed
+}
+
+// processCoredumpFile hands the core to the pipeline. The core, and keys
+// its source tracks it by besides its path, are processed from now on, but
+// only saved as such once the core is Finished: if the agent restarts
+// before, it is picked up again. It waits for room in the pipeline, so a
+// backlog holds back the collector rather than losing cores.
+func (c *Collector) processCoredumpFile(ctx context.Context, coredump *CoredumpFile, keys ...string) {
+	keys = append(keys, coredump.Path)
+	now := time.Now()
+	c.mu.Lock()
+	for _, key := range keys {
+		c.processedFiles[key] = now
+	}
+	c.inFlight[coredump.ID] = keys
+	delete(c.observations, coredump.Path)
+	c.mu.Unlock()
 	
 	klog.Infof("Processing coredump file: %s", coredump.Path)
 	
-	coredump.Status = StatusProcessing
-	coredump.UpdatedAt = metav1.Now()
+	// The trace starts with the crash, so its first span shows how long
+	// the core took to be written and picked up.
+	traceCtx, discovered := tracing.Start(context.Background(), "", tracing.SpanDiscovery,
+		trace.WithTimestamp(coredump.Timestamp), trace.WithAttributes(coredumpAttributes(coredump)...))
+	discovered.End()
+	coredump.TraceParent = tracing.TraceParent(traceCtx)
+	
+	_, collected := tracing.Start(traceCtx, "", tracing.SpanCollection)
+	err := c.stage(coredump)
+	if err != nil {
+		klog.Warningf("Failed to stage %s, analyzing in place: %v", coredump.Path, err)
+	}
+	tracing.End(collected, err)
 	
 	event := CollectionEvent{
 		Type:         EventTypeFileDiscovered,
@@ -312,11 +628,43 @@ func (c *Collector) processCoredumpFile(coredump *CoredumpFile) {
 	
 	select {
 	case c.eventChan <- event:
-	default:
-		klog.Warning("Event channel is full, dropping file event")
+	case <-ctx.Done():
+		// Stopping, the core is collected again after the restart.
+		c.mu.Lock()
+		for _, key := range keys {
+			delete(c.processedFiles, key)
+		}
+		delete(c.inFlight, coredump.ID)
+		c.mu.Unlock()
 	}
 }
 
+// coredumpAttributes describe the core on the first span of its trace.
+func coredumpAttributes(coredump *CoredumpFile) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("coredump.id", coredump.ID),
+		attribute.String("coredump.path", coredump.Path),
+		attribute.Int64("coredump.size", coredump.Size),
+		attribute.String("coredump.executable", coredump.Executable),
+		attribute.Int("coredump.signal", coredump.Signal),
+	}
+	if coredump.PodName != "" {
+		attributes = append(attributes,
+			attribute.String("k8s.namespace.name", coredump.PodNamespace),
+			attribute.String("k8s.pod.name", coredump.PodName),
+			attribute.String("milvus.instance", coredump.InstanceName),
+			attribute.String("milvus.component", coredump.Component))
+	}
+	return attributes
+}
+
 func (c *Collector) GetProcessedFiles() map[string]bool {
-	return c.processedFiles
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	processed := make(map[string]bool, len(c.processedFiles))
+	for path := range c.processedFiles {
+		processed[path] = true
+	}
+	return processed
 }
\ No newline at end of file
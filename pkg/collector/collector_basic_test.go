@@ -3,6 +3,12 @@ package collector
 import (
 	"errors"
 	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/cri"
+	"milvus-coredump-agent/pkg/discovery"
 )
 
 func TestBasicCoredumpPatternMatching(t *testing.T) {
@@ -128,4 +134,51 @@ func extractBasicCoredumpInfo(filename string) (process, pid string, err error)
 	}
 	
 	return "", "", errors.New("no match")
-}
\ No newline at end of file
+}
+
+func TestCrashedContainer(t *testing.T) {
+	crashed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pod := discovery.PodInfo{
+		ContainerStatuses: []discovery.ContainerStatusInfo{
+			{Name: "querynode", Type: discovery.ContainerTypeMain},
+			{Name: "config", Type: discovery.ContainerTypeInit, LastTerminatedAt: metav1.NewTime(crashed.Add(-time.Hour))},
+			{Name: "log-shipper", Type: discovery.ContainerTypeInit, LastTerminatedAt: metav1.NewTime(crashed.Add(-10 * time.Second))},
+		},
+	}
+
+	if container := crashedContainer(pod, &CoredumpFile{Executable: "querynode", ModTime: crashed}); container == nil || container.Name != "querynode" {
+		t.Errorf("expected the container named after the executable, got %+v", container)
+	}
+	container := crashedContainer(pod, &CoredumpFile{Executable: "fluent-bit", ModTime: crashed})
+	if container == nil || container.Name != "log-shipper" || container.Type != discovery.ContainerTypeInit {
+		t.Errorf("expected the sidecar that just terminated, got %+v", container)
+	}
+	if container := crashedContainer(pod, &CoredumpFile{Executable: "fluent-bit", ModTime: crashed.Add(time.Hour)}); container != nil {
+		t.Errorf("expected no container without a nearby termination, got %+v", container)
+	}
+}
+
+func TestPodContainer(t *testing.T) {
+	pod := discovery.PodInfo{
+		Name:      "milvus-querynode-0",
+		Namespace: "milvus",
+		ContainerStatuses: []discovery.ContainerStatusInfo{
+			{Name: "querynode", Type: discovery.ContainerTypeMain, ContainerID: "replacement"},
+		},
+	}
+
+	status, found := podContainer(pod, &cri.Container{ID: "crashed", Name: "querynode", PodName: "milvus-querynode-0", PodNamespace: "milvus"})
+	if !found || status == nil || status.Name != "querynode" {
+		t.Errorf("expected the pod's container of the same name, got %+v, %v", status, found)
+	}
+	if _, found := podContainer(pod, &cri.Container{ID: "crashed", Name: "querynode", PodName: "milvus-querynode-0", PodNamespace: "other"}); found {
+		t.Error("expected the pod of another namespace not to match")
+	}
+	// Only the cgroup is known: the container is matched by its ID.
+	if status, found := podContainer(pod, &cri.Container{ID: "replacement"}); !found || status == nil {
+		t.Errorf("expected the container with the same ID, got %+v, %v", status, found)
+	}
+	if _, found := podContainer(pod, &cri.Container{ID: "crashed"}); found {
+		t.Error("expected a container the pod does not report not to match")
+	}
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+// fuzzFileInfo is a minimal os.FileInfo for feeding arbitrary file names
+// into parseCoredumpFile without touching the filesystem.
+type fuzzFileInfo struct {
+	name string
+	size int64
+}
+
+func (f fuzzFileInfo) Name() string       { return f.name }
+func (f fuzzFileInfo) Size() int64        { return f.size }
+func (f fuzzFileInfo) Mode() os.FileMode  { return 0644 }
+func (f fuzzFileInfo) ModTime() time.Time { return time.Unix(1700000000, 0) }
+func (f fuzzFileInfo) IsDir() bool        { return false }
+func (f fuzzFileInfo) Sys() interface{}   { return nil }
+
+func FuzzParseCoredumpFile(f *testing.F) {
+	seeds := []string{
+		"core.milvus.1000.1234567890.15678",
+		"core.milvus_crasher.1001.1634567890.15679",
+		"core.milvus.1000.a1b2c3d4.1234567890.15678",
+		"core.milvus.99999999999999999999.1.2",
+		"core.milvus.1000.ffffffffffffffffff.1.11",
+		"core.",
+		"core..1.2.3",
+		"milvus.log",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	c := New(&config.CollectorConfig{}, discovery.New(nil, &config.DiscoveryConfig{}), nil, nil, nil, nil, "", "")
+
+	f.Fuzz(func(t *testing.T, filename string) {
+		matched := c.isCoredumpFile(filename)
+
+		coredump := c.parseCoredumpFile("/fuzz/"+filename, fuzzFileInfo{name: filename, size: 1024})
+		if coredump == nil {
+			t.Fatalf("parseCoredumpFile returned nil for %q", filename)
+		}
+		if coredump.FileName != filename {
+			t.Errorf("expected file name %q, got %q", filename, coredump.FileName)
+		}
+		if !matched && coredump.Executable != "" {
+			t.Errorf("non-coredump name %q produced executable %q", filename, coredump.Executable)
+		}
+	})
+}
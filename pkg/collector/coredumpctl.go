@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultCoredumpctlTimeout = 5 * time.Minute
+	// Prefix of the processed keys of cores read through coredumpctl,
+	// followed by the crash time in microseconds and the PID.
+	journalKeyPrefix = "coredumpctl:"
+)
+
+// systemdCoredumpPattern matches the names systemd-coredump gives the cores
+// it stores as files: core.<comm>.<uid>.<boot ID>.<pid>.<time in µs>,
+// compressed by default.
+var systemdCoredumpPattern = regexp.MustCompile(`^core\.(.+)\.(\d+)\.([0-9a-f]{32})\.(\d+)\.(\d+)(\.(zst|xz|lz4))?$`)
+
+// journalCore is an entry of `coredumpctl --json=short list`.
+type journalCore struct {
+	// Crash time in microseconds
+	Time   int64 `json:"time"`
+	PID    int   `json:"pid"`
+	UID    int   `json:"uid"`
+	GID    int   `json:"gid"`
+	Signal int   `json:"sig"`
+	// present, journal, missing, truncated, ...
+	Corefile string `json:"corefile"`
+	Exe      string `json:"exe"`
+	Size     int64  `json:"size"`
+}
+
+func (core journalCore) crashTime() time.Time {
+	return time.UnixMicro(core.Time)
+}
+
+func (core journalCore) key() string {
+	return fmt.Sprintf("%s%d/%d", journalKeyPrefix, core.Time, core.PID)
+}
+
+// journalKeyTime returns the crash time recorded in a processed key of
+// coredumpctl, or false for the paths of core files.
+func journalKeyTime(key string) (time.Time, bool) {
+	rest, found := strings.CutPrefix(key, journalKeyPrefix)
+	if !found {
+		return time.Time{}, false
+	}
+	micros, _, _ := strings.Cut(rest, "/")
+	value, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMicro(value), true
+}
+
+// watchJournal picks up the cores coredumpctl lists every WatchInterval.
+func (c *Collector) watchJournal(ctx context.Context) {
+	c.adoptExtracted()
+	c.scanJournal(ctx)
+
+	ticker := time.NewTicker(c.config().WatchInterval)
+	defer ticker.Stop()
+
+	skipped := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.pressure.Degraded() {
+				skipped++
+				if skipped < c.pressure.DegradedScanFactor() {
+					continue
+				}
+			}
+			skipped = 0
+			c.scanJournal(ctx)
+		}
+	}
+}
+
+// scanJournal extracts and processes the cores coredumpctl recorded within
+// MaxFileAge that have not been processed yet.
+func (c *Collector) scanJournal(ctx context.Context) {
+	now := time.Now()
+	cores, err := c.listJournalCores(ctx, now.Add(-c.config().MaxFileAge))
+	if err != nil {
+		klog.Warningf("Failed to list coredumps with coredumpctl: %v", err)
+		return
+	}
+	for _, core := range cores {
+		if core.Corefile != "present" && core.Corefile != "journal" {
+			klog.V(2).Infof("Skipping core of pid %d from coredumpctl: %s", core.PID, core.Corefile)
+			continue
+		}
+		if c.isProcessed(core.key()) {
+			continue
+		}
+		coredump, err := c.extractJournalCore(ctx, core)
+		if err != nil {
+			klog.Warningf("Failed to extract core of pid %d (%s) with coredumpctl: %v", core.PID, core.Exe, err)
+			continue
+		}
+		c.processCoredumpFile(ctx, coredump, core.key())
+	}
+	c.cleanStaging(now)
+}
+
+func (c *Collector) listJournalCores(ctx context.Context, since time.Time) ([]journalCore, error) {
+	output, err := c.coredumpctl(ctx, "--json=short", fmt.Sprintf("--since=@%d", since.Unix()), "list")
+	if err != nil {
+		// coredumpctl fails when nothing matches.
+		if strings.Contains(err.Error(), "No coredumps found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var cores []journalCore
+	if err := json.Unmarshal(output, &cores); err != nil {
+		return nil, fmt.Errorf("failed to decode coredumpctl list: %w", err)
+	}
+	return cores, nil
+}
+
+// extractJournalCore writes the core to ExtractDir, decompressed, under the
+// name core.<executable>.<pid>.<uid>.<signal> with the crash time as its
+// mtime, and describes it.
+func (c *Collector) extractJournalCore(ctx context.Context, core journalCore) (*CoredumpFile, error) {
+	dir := c.extractDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create extract directory: %w", err)
+	}
+	executable := strings.ReplaceAll(filepath.Base(core.Exe), ".", "_")
+	path := filepath.Join(dir, fmt.Sprintf("core.%s.%d.%d.%d", executable, core.PID, core.UID, core.Signal))
+
+	// PIDs are reused, so the core is also matched by its crash time.
+	_, err := c.coredumpctl(ctx, "--output="+path, "dump",
+		fmt.Sprintf("COREDUMP_PID=%d", core.PID), fmt.Sprintf("COREDUMP_TIMESTAMP=%d", core.Time))
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	crashed := core.crashTime()
+	if err := os.Chtimes(path, crashed, crashed); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.staged[path] = time.Now()
+	c.mu.Unlock()
+
+	coredump := NewCoredumpFile(path, info)
+	coredump.GID = core.GID
+	if core.Exe != "" {
+		coredump.Executable = filepath.Base(core.Exe)
+	}
+	c.annotate(coredump)
+	klog.Infof("Extracted core of pid %d (%s, signal %d) from coredumpctl to %s", core.PID, core.Exe, core.Signal, path)
+	return coredump, nil
+}
+
+func (c *Collector) coredumpctl(ctx context.Context, args ...string) ([]byte, error) {
+	cfg := c.config().Coredumpctl
+	command := cfg.Command
+	if command == "" {
+		command = "coredumpctl"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultCoredumpctlTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args = append([]string{"--no-pager"}, args...)
+	if cfg.Directory != "" {
+		args = append([]string{"--directory=" + cfg.Directory}, args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("coredumpctl failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func (c *Collector) extractDir() string {
+	if dir := c.config().Coredumpctl.ExtractDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "coredumpctl")
+}
+
+// adoptExtracted removes the cores extracted by a previous run after the
+// staging retention, like those extracted by this one; until then pending
+// analysis retries still read them. Cores that did not finish the pipeline
+// are extracted again.
+func (c *Collector) adoptExtracted() {
+	entries, err := os.ReadDir(c.extractDir())
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range entries {
+		c.staged[filepath.Join(c.extractDir(), entry.Name())] = now
+	}
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// fakeCoredumpctl lists one core kept in the journal and one lost, and
+// dumps the core to --output, logging its arguments to args.
+const fakeCoredumpctl = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/args"
+for arg in "$@"; do
+	case "$arg" in
+	list)
+		echo '[{"time":CRASHED,"pid":4242,"uid":1000,"gid":1000,"sig":11,"corefile":"journal","exe":"/milvus/bin/milvus","size":4096},
+			{"time":CRASHED,"pid":4343,"uid":1000,"gid":1000,"sig":6,"corefile":"missing","exe":"/milvus/bin/milvus","size":4096}]'
+		exit 0;;
+	--output=*)
+		printf 'ELF core' > "${arg#--output=}";;
+	esac
+done
+`
+
+func TestScanJournalExtractsCores(t *testing.T) {
+	dir := t.TempDir()
+	crashed := time.Now().Add(-time.Minute).Truncate(time.Microsecond)
+	command := filepath.Join(dir, "coredumpctl")
+	micros := strconv.FormatInt(crashed.UnixMicro(), 10)
+	if err := os.WriteFile(command, []byte(strings.ReplaceAll(fakeCoredumpctl, "CRASHED", micros)), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	statePath := filepath.Join(dir, "processed.json")
+	cfg := &config.CollectorConfig{
+		MaxFileAge: time.Hour,
+		Coredumpctl: config.CoredumpctlConfig{
+			Enabled:    true,
+			Command:    command,
+			Directory:  "/host/var/log/journal",
+			ExtractDir: filepath.Join(dir, "extracted"),
+		},
+	}
+	c := newStagingTestCollector(cfg)
+	c.statePath = statePath
+	c.scanJournal(context.Background())
+
+	select {
+	case event := <-c.GetEventChannel():
+		coredump := event.CoredumpFile
+		if coredump.Executable != "milvus" || coredump.PID != 4242 || coredump.UID != 1000 || coredump.Signal != 11 ||
+			!coredump.Timestamp.Equal(crashed) {
+			t.Errorf("expected the journal's metadata, got %+v", coredump)
+		}
+		if data, err := os.ReadFile(coredump.Path); err != nil || string(data) != "ELF core" {
+			t.Errorf("expected the core to be extracted to %s, got %q, %v", coredump.Path, data, err)
+		}
+		c.Finished(coredump)
+	default:
+		t.Fatal("expected the core kept in the journal to be processed")
+	}
+	select {
+	case event := <-c.GetEventChannel():
+		t.Errorf("expected the missing core to be skipped, got %+v", event.CoredumpFile)
+	default:
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.Contains(string(args), "--directory=/host/var/log/journal --no-pager --json=short") ||
+		!strings.Contains(string(args), "dump COREDUMP_PID=4242 COREDUMP_TIMESTAMP="+micros) {
+		t.Errorf("unexpected coredumpctl calls:\n%s", args)
+	}
+
+	// Finished cores are not extracted again, even after a restart.
+	restarted := newStagingTestCollector(cfg)
+	restarted.statePath = statePath
+	restarted.loadProcessedFiles()
+	restarted.scanJournal(context.Background())
+	args, _ = os.ReadFile(filepath.Join(dir, "args"))
+	if calls := strings.Count(string(args), " dump "); calls != 1 {
+		t.Errorf("expected one extraction, got %d", calls)
+	}
+}
+
+func TestSystemdCoredumpNames(t *testing.T) {
+	info := fuzzFileInfo{name: "core.milvus.1000.8d3f6e2a9b1c4d5e8f7a6b5c4d3e2f1a.4242.1714521600123456.zst"}
+	coredump := NewCoredumpFile("/var/lib/systemd/coredump/"+info.name, info)
+	if coredump.Executable != "milvus" || coredump.UID != 1000 || coredump.PID != 4242 ||
+		!coredump.Timestamp.Equal(time.UnixMicro(1714521600123456)) {
+		t.Errorf("unexpected systemd-coredump metadata %+v", coredump)
+	}
+
+	c := newStagingTestCollector(&config.CollectorConfig{Coredumpctl: config.CoredumpctlConfig{Enabled: true}})
+	if c.isCoredumpFile(info.name) || !c.isCoredumpFile("core.milvus.4242.1000.11") {
+		t.Error("expected only systemd-coredump's cores to be left to coredumpctl")
+	}
+}
+
+func TestAdoptExtractedKeepsCoresForRetries(t *testing.T) {
+	dir := t.TempDir()
+	extracted := filepath.Join(dir, "core.milvus.4242.1000.11")
+	if err := os.WriteFile(extracted, []byte("ELF core"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := newStagingTestCollector(&config.CollectorConfig{
+		Coredumpctl: config.CoredumpctlConfig{Enabled: true, ExtractDir: dir},
+		Staging:     config.StagingConfig{Retention: time.Minute},
+	})
+
+	c.adoptExtracted()
+	c.cleanStaging(time.Now())
+	if _, err := os.Stat(extracted); err != nil {
+		t.Fatalf("expected the core extracted by the previous run to be kept, got %v", err)
+	}
+	c.cleanStaging(time.Now().Add(2 * time.Minute))
+	if _, err := os.Stat(extracted); !os.IsNotExist(err) {
+		t.Errorf("expected the core to be removed after the staging retention, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// LifecycleStage is a leg of a coredump's way through the pipeline.
+type LifecycleStage string
+
+const (
+	// From the core being written to the collector finding it
+	StageCrashToDiscovered LifecycleStage = "crash_to_discovered"
+	// From the collector finding the core to the analysis finishing
+	StageDiscoveredToAnalyzed LifecycleStage = "discovered_to_analyzed"
+	// From the analysis finishing to the core being stored
+	StageAnalyzedToStored LifecycleStage = "analyzed_to_stored"
+)
+
+// LifecycleStages lists the stages in pipeline order.
+var LifecycleStages = []LifecycleStage{
+	StageCrashToDiscovered,
+	StageDiscoveredToAnalyzed,
+	StageAnalyzedToStored,
+}
+
+// SLATarget returns the configured target for stage, zero when it has none.
+func SLATarget(cfg *config.LifecycleSLAConfig, stage LifecycleStage) time.Duration {
+	switch stage {
+	case StageCrashToDiscovered:
+		return cfg.CrashToDiscovered
+	case StageDiscoveredToAnalyzed:
+		return cfg.DiscoveredToAnalyzed
+	case StageAnalyzedToStored:
+		return cfg.AnalyzedToStored
+	}
+	return 0
+}
+
+// StageDuration returns how long the coredump took through stage, and false
+// when it hasn't got through it. Cores kept as duplicates count as stored.
+func (c *CoredumpFile) StageDuration(stage LifecycleStage) (time.Duration, bool) {
+	var from, to time.Time
+	switch stage {
+	case StageCrashToDiscovered:
+		from, to = c.Timestamp, c.CreatedAt.Time
+	case StageDiscoveredToAnalyzed:
+		from, to = c.CreatedAt.Time, c.AnalysisTime
+	case StageAnalyzedToStored:
+		stateMu.Lock()
+		if c.Status == StatusStored {
+			to = c.UpdatedAt.Time
+		}
+		stateMu.Unlock()
+		from = c.AnalysisTime
+	}
+	if from.IsZero() || to.IsZero() {
+		return 0, false
+	}
+	if to.Before(from) {
+		// The clock was stepped back in between.
+		return 0, true
+	}
+	return to.Sub(from), true
+}
@@ -0,0 +1,204 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/corepipe"
+)
+
+// listenPipe receives the cores core-handler streams on the unix socket
+// until ctx is done. Cores arrive complete with the crashed process'
+// metadata, so they skip the completeness checks of the directory scan.
+func (c *Collector) listenPipe(ctx context.Context) {
+	socket := c.config().PipeHandler.SocketPath
+	if socket == "" {
+		socket = corepipe.DefaultSocket
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		klog.Errorf("Failed to create the directory of the core-handler socket: %v", err)
+		return
+	}
+	// A socket left behind by the previous run refuses the bind.
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		klog.Errorf("Failed to listen for core-handler on %s: %v", socket, err)
+		return
+	}
+	// core-handler runs as root, nothing else may hand us cores.
+	if err := os.Chmod(socket, 0600); err != nil {
+		klog.Warningf("Failed to restrict the core-handler socket: %v", err)
+	}
+	klog.Infof("Receiving cores from core-handler on %s", socket)
+	c.recoverReceived(ctx)
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				klog.Errorf("Stopped receiving cores from core-handler: %v", err)
+			}
+			return
+		}
+		go c.receiveCore(ctx, conn)
+	}
+}
+
+// receiveCore writes the core sent on conn to ReceiveDir, replies to
+// core-handler and processes the core.
+func (c *Collector) receiveCore(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	coredump, err := c.readCore(reader)
+	if err != nil {
+		// core-handler only reads the reply once it sent the whole core;
+		// closing on it would fail its write without the reason.
+		io.Copy(io.Discard, reader)
+	}
+	if err := corepipe.WriteReply(conn, err); err != nil {
+		klog.V(2).Infof("Failed to reply to core-handler: %v", err)
+	}
+	if err != nil {
+		klog.Errorf("Failed to receive core from core-handler: %v", err)
+		return
+	}
+	c.processCoredumpFile(ctx, coredump)
+}
+
+func (c *Collector) readCore(reader *bufio.Reader) (*CoredumpFile, error) {
+	header, err := corepipe.ReadHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	dir := c.receiveDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create receive directory: %w", err)
+	}
+
+	executable := strings.ReplaceAll(header.Executable, ".", "_")
+	name := fmt.Sprintf("core.%s.%d.%d.%d", executable, header.PID, max(header.UID, 0), header.Signal)
+	path := filepath.Join(dir, name)
+	partial := filepath.Join(dir, "."+name+".partial")
+	if err := c.writeReceived(reader, partial); err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	crashed := time.Unix(header.Time, 0)
+	if header.Time <= 0 {
+		crashed = time.Now()
+	}
+	if err := os.Chtimes(partial, crashed, crashed); err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.staged[path] = time.Now()
+	c.mu.Unlock()
+
+	coredump := NewCoredumpFile(path, info)
+	coredump.Executable = header.Executable
+	if header.UID >= 0 {
+		coredump.UID = header.UID
+	}
+	if header.GID >= 0 {
+		coredump.GID = header.GID
+	}
+	if header.Hostname != "" {
+		coredump.Hostname = header.Hostname
+	}
+	coredump.Cgroup = header.Cgroup
+	c.annotate(coredump)
+	klog.Infof("Received core of pid %d (%s, signal %d, %d bytes) from core-handler", header.PID, header.Executable, header.Signal, info.Size())
+	return coredump, nil
+}
+
+// writeReceived copies the core to path, up to the collector's
+// maxFileSize.
+func (c *Collector) writeReceived(reader io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create core: %w", err)
+	}
+	limit := config.ParseSize(c.config().MaxFileSize, 0)
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit+1)
+	}
+	written, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write core: %w", err)
+	}
+	if limit > 0 && written > limit {
+		return errors.New("core exceeds maxFileSize")
+	}
+	return nil
+}
+
+// recoverReceived picks up the cores received before the agent restarted
+// that did not finish the pipeline, with only the metadata their names
+// record. Like the cores received since, they are removed after the
+// staging retention.
+func (c *Collector) recoverReceived(ctx context.Context) {
+	dir := c.receiveDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		// Cut short by the restart.
+		if strings.HasPrefix(entry.Name(), ".") {
+			os.Remove(path)
+			continue
+		}
+
+		c.mu.Lock()
+		c.staged[path] = now
+		c.mu.Unlock()
+		if c.isProcessed(path) || now.Sub(info.ModTime()) > c.config().MaxFileAge {
+			continue
+		}
+		klog.Infof("Recovered core %s received before the agent restarted", path)
+		c.processCoredumpFile(ctx, c.parseCoredumpFile(path, info))
+	}
+}
+
+func (c *Collector) receiveDir() string {
+	if dir := c.config().PipeHandler.ReceiveDir; dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "core-handler")
+}
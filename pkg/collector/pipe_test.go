@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/corepipe"
+)
+
+// sendCore does what core-handler does.
+func sendCore(t *testing.T, socket string, header corepipe.Header, core string) error {
+	t.Helper()
+	var conn *net.UnixConn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: socket, Net: "unix"}); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	corepipe.WriteHeader(conn, header)
+	conn.Write([]byte(core))
+	conn.CloseWrite()
+	return corepipe.ReadReply(conn)
+}
+
+func TestPipeReceivesCores(t *testing.T) {
+	// Unix socket paths are limited to 108 bytes.
+	dir, err := os.MkdirTemp("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "core.sock")
+
+	c := newStagingTestCollector(&config.CollectorConfig{
+		MaxFileSize: "1KB",
+		PipeHandler: config.PipeHandlerConfig{Enabled: true, SocketPath: socket, ReceiveDir: filepath.Join(dir, "received")},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.listenPipe(ctx)
+
+	header := corepipe.Header{Executable: "milvus.bin", PID: 4242, UID: 1000, GID: -1, Signal: 6, Time: 1714521600, Hostname: "node-1"}
+	if err := sendCore(t, socket, header, "ELF core"); err != nil {
+		t.Fatalf("expected the core to be taken, got %v", err)
+	}
+	select {
+	case event := <-c.GetEventChannel():
+		coredump := event.CoredumpFile
+		if coredump.Executable != "milvus.bin" || coredump.PID != 4242 || coredump.UID != 1000 || coredump.Signal != 6 ||
+			coredump.Hostname != "node-1" || !coredump.Timestamp.Equal(time.Unix(1714521600, 0)) {
+			t.Errorf("expected the handler's metadata, got %+v", coredump)
+		}
+		if data, err := os.ReadFile(coredump.Path); err != nil || string(data) != "ELF core" {
+			t.Errorf("expected the core at %s, got %q, %v", coredump.Path, data, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the received core to be processed")
+	}
+
+	if err := sendCore(t, socket, header, strings.Repeat("x", 2048)); err == nil || !strings.Contains(err.Error(), "maxFileSize") {
+		t.Errorf("expected cores over maxFileSize to be refused, got %v", err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket to be private, got %v, %v", info, err)
+	}
+}
+
+func TestRecoverReceivedCores(t *testing.T) {
+	dir := t.TempDir()
+	c := newStagingTestCollector(&config.CollectorConfig{
+		MaxFileAge:  time.Hour,
+		PipeHandler: config.PipeHandlerConfig{Enabled: true, ReceiveDir: dir},
+	})
+	received := filepath.Join(dir, "core.milvus.4242.1000.11")
+	partial := filepath.Join(dir, ".core.milvus.4343.1000.11.partial")
+	for _, path := range []string{received, partial} {
+		if err := os.WriteFile(path, []byte("ELF core"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.recoverReceived(context.Background())
+	select {
+	case event := <-c.GetEventChannel():
+		if event.CoredumpFile.Path != received || event.CoredumpFile.PID != 4242 {
+			t.Errorf("expected the received core to be processed, got %+v", event.CoredumpFile)
+		}
+	default:
+		t.Fatal("expected the core received before the restart to be processed")
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("expected the partial core to be removed, got %v", err)
+	}
+	if _, staged := c.staged[received]; !staged {
+		t.Error("expected the recovered core to be removed after the staging retention")
+	}
+}
+
+func TestPipeRefusesOversizedCoreWithReason(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "core.sock")
+
+	c := newStagingTestCollector(&config.CollectorConfig{
+		MaxFileSize: "1KB",
+		PipeHandler: config.PipeHandlerConfig{Enabled: true, SocketPath: socket, ReceiveDir: filepath.Join(dir, "received")},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.listenPipe(ctx)
+
+	var conn *net.UnixConn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.DialUnix("unix", nil, &net.UnixAddr{Name: socket, Net: "unix"}); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Far more than the socket buffers: the agent must keep reading past
+	// the limit for the whole core to be sent.
+	header := corepipe.Header{Executable: "milvus", PID: 4242, UID: 1000, GID: 1000, Signal: 11, Time: 1714521600}
+	if err := corepipe.WriteHeader(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(make([]byte, 16<<20)); err != nil {
+		t.Fatalf("expected the oversized core to be read to its end, got %v", err)
+	}
+	conn.CloseWrite()
+	if err := corepipe.ReadReply(conn); err == nil || !strings.Contains(err.Error(), "maxFileSize") {
+		t.Errorf("expected the core to be refused for its size, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "received")); len(entries) != 0 {
+		t.Errorf("expected nothing to be kept of the refused core, got %d files", len(entries))
+	}
+}
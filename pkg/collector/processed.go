@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+// loadProcessedFiles restores the cores finished before the agent
+// restarted. Cores deleted since then are forgotten, which also keeps the
+// state file from growing.
+func (c *Collector) loadProcessedFiles() {
+	if c.statePath == "" {
+		return
+	}
+
+	var paths []string
+	if err := statefile.Load(c.statePath, &paths); err != nil {
+		klog.Warningf("Starting without processed coredump state: %v", err)
+		return
+	}
+	for _, path := range paths {
+		// coredumpctl lists cores for MaxFileAge.
+		if crashed, found := journalKeyTime(path); found {
+			if time.Since(crashed) <= c.config().MaxFileAge {
+				c.processedFiles[path] = crashed
+				c.finished[path] = true
+			}
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			c.processedFiles[path] = info.ModTime()
+			c.finished[path] = true
+		}
+	}
+	klog.Infof("Restored %d processed coredump files from %s", len(c.processedFiles), c.statePath)
+}
+
+// Finished records that the core reached a final status, stored, skipped
+// or failed, so it is not collected again after the agent restarts.
+func (c *Collector) Finished(coredump *CoredumpFile) {
+	c.mu.Lock()
+	keys, found := c.inFlight[coredump.ID]
+	delete(c.inFlight, coredump.ID)
+	for _, key := range keys {
+		if _, processed := c.processedFiles[key]; processed {
+			c.finished[key] = true
+		}
+	}
+	c.mu.Unlock()
+
+	if found {
+		c.saveProcessedFiles()
+	}
+}
+
+// pruneProcessed forgets the cores processed longer than MaxFileAge ago,
+// which are too old to be collected again anyway.
+func (c *Collector) pruneProcessed(now time.Time) {
+	maxAge := c.config().MaxFileAge
+	if maxAge <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	pruned := false
+	for key, processedAt := range c.processedFiles {
+		if now.Sub(processedAt) > maxAge {
+			delete(c.processedFiles, key)
+			if c.finished[key] {
+				delete(c.finished, key)
+				pruned = true
+			}
+		}
+	}
+	// Cores the pipeline dropped never finish.
+	for id, keys := range c.inFlight {
+		if _, processed := c.processedFiles[keys[0]]; !processed {
+			delete(c.inFlight, id)
+		}
+	}
+	c.mu.Unlock()
+
+	if pruned {
+		c.saveProcessedFiles()
+	}
+}
+
+// saveProcessedFiles writes the finished cores to statePath. c.mu is only
+// held while they are copied.
+func (c *Collector) saveProcessedFiles() {
+	if c.statePath == "" {
+		return
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	paths := make([]string, 0, len(c.finished))
+	for path := range c.finished {
+		paths = append(paths, path)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(paths)
+	if err := statefile.Save(c.statePath, paths); err != nil {
+		klog.Warningf("Failed to save processed coredump state: %v", err)
+	}
+}
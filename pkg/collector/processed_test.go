@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+func TestProcessedFilesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state", "processed-files.json")
+	kept := filepath.Join(dir, "core.milvus.1000.1.11")
+	deleted := filepath.Join(dir, "core.milvus.1000.2.11")
+	unfinished := filepath.Join(dir, "core.milvus.1000.3.11")
+	for _, path := range []string{kept, deleted, unfinished} {
+		if err := os.WriteFile(path, []byte("core"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newCollector := func() *Collector {
+		return New(&config.CollectorConfig{}, discovery.New(nil, &config.DiscoveryConfig{}), nil, nil, nil, nil, "", statePath)
+	}
+	c := newCollector()
+	for i, path := range []string{kept, deleted, unfinished} {
+		coredump := &CoredumpFile{ID: string(rune('a' + i)), Path: path}
+		c.processCoredumpFile(context.Background(), coredump)
+		if path != unfinished {
+			c.Finished(coredump)
+		}
+	}
+	if !c.isProcessed(unfinished) {
+		t.Error("expected the core in the pipeline not to be collected twice")
+	}
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newCollector()
+	if !restarted.isProcessed(kept) {
+		t.Error("expected the finished core to be remembered after a restart")
+	}
+	if restarted.isProcessed(deleted) {
+		t.Error("expected a deleted core to be forgotten")
+	}
+	if restarted.isProcessed(unfinished) {
+		t.Error("expected the core that did not finish the pipeline to be collected again")
+	}
+}
+
+func TestFinishedThroughStateMachine(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "processed-files.json")
+	c := New(&config.CollectorConfig{}, discovery.New(nil, &config.DiscoveryConfig{}), nil, nil, nil, nil, "", statePath)
+	m := NewStateMachine()
+	m.OnFinal(c.Finished)
+
+	coredump := &CoredumpFile{ID: "abc", Path: "coredumpctl:1/2"}
+	c.processCoredumpFile(context.Background(), coredump, "coredumpctl:1/2")
+	m.Transition(coredump, StatusDiscovered, StatusProcessing, "")
+	if c.finished[coredump.Path] {
+		t.Error("expected the core to finish only at a final status")
+	}
+	m.Transition(coredump, StatusProcessing, StatusError, "gdb failed")
+	if !c.finished[coredump.Path] {
+		t.Error("expected the failed core to be finished")
+	}
+}
+
+func TestPruneProcessed(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "processed-files.json")
+	c := New(&config.CollectorConfig{MaxFileAge: time.Hour}, discovery.New(nil, &config.DiscoveryConfig{}), nil, nil, nil, nil, "", statePath)
+
+	old := &CoredumpFile{ID: "old", Path: "/cores/core.milvus.1.0.11"}
+	recent := &CoredumpFile{ID: "recent", Path: "/cores/core.milvus.2.0.11"}
+	dropped := &CoredumpFile{ID: "dropped", Path: "/cores/core.milvus.3.0.11"}
+	for _, coredump := range []*CoredumpFile{old, recent, dropped} {
+		c.processCoredumpFile(context.Background(), coredump)
+	}
+	c.Finished(old)
+	c.Finished(recent)
+	c.processedFiles[old.Path] = time.Now().Add(-2 * time.Hour)
+	c.processedFiles[dropped.Path] = time.Now().Add(-2 * time.Hour)
+
+	c.pruneProcessed(time.Now())
+	if c.isProcessed(old.Path) || c.finished[old.Path] || !c.isProcessed(recent.Path) {
+		t.Errorf("expected only the core processed past maxFileAge to be forgotten, got %v", c.processedFiles)
+	}
+	if _, found := c.inFlight[dropped.ID]; found {
+		t.Error("expected the core that never finished to be forgotten")
+	}
+}
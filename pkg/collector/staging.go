@@ -0,0 +1,236 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const defaultStagingRetention = time.Hour
+
+// observation is the last size and mtime seen for a core that is not yet
+// known to be complete.
+type observation struct {
+	size        int64
+	modTime     time.Time
+	stableSince time.Time
+}
+
+// isComplete reports whether the kernel (or systemd-coredump) has finished
+// writing a core: its size and mtime must be unchanged since an earlier scan,
+// for at least StableFor, and no process may have it open for writing.
+func (c *Collector) isComplete(path string, info os.FileInfo, now time.Time) bool {
+	c.mu.Lock()
+	obs, seen := c.observations[path]
+	if !seen || obs.size != info.Size() || !obs.modTime.Equal(info.ModTime()) {
+		c.observations[path] = &observation{
+			size:        info.Size(),
+			modTime:     info.ModTime(),
+			stableSince: now,
+		}
+		c.mu.Unlock()
+		klog.V(2).Infof("Waiting for %s to stop changing (size %d)", path, info.Size())
+		return false
+	}
+	stable := now.Sub(obs.stableSince)
+	c.mu.Unlock()
+
+	if stable < c.config().StableFor {
+		return false
+	}
+	if pid := c.findWriter(info); pid > 0 {
+		klog.V(2).Infof("Waiting for pid %d to close %s", pid, path)
+		return false
+	}
+	return true
+}
+
+// findWriter returns a process that has the file open for writing, or 0.
+// Files are matched by device and inode so the check works regardless of how
+// the writer's mount namespace names the path. It only sees processes in the
+// PID namespace of ProcPath.
+func (c *Collector) findWriter(info os.FileInfo) int {
+	target, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+
+	procPath := c.config().ProcPath
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	procs, err := os.ReadDir(procPath)
+	if err != nil {
+		return 0
+	}
+
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procPath, proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			fdInfo, err := os.Stat(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			stat, ok := fdInfo.Sys().(*syscall.Stat_t)
+			if !ok || stat.Dev != target.Dev || stat.Ino != target.Ino {
+				continue
+			}
+			if openedForWriting(filepath.Join(procPath, proc.Name(), "fdinfo", fd.Name())) {
+				return pid
+			}
+		}
+	}
+	return 0
+}
+
+// openedForWriting reads the open flags from /proc/<pid>/fdinfo/<fd>.
+func openedForWriting(fdInfoPath string) bool {
+	data, err := os.ReadFile(fdInfoPath)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(line, "flags:"); found {
+			flags, err := strconv.ParseInt(strings.TrimSpace(value), 8, 64)
+			if err != nil {
+				return false
+			}
+			return flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0
+		}
+	}
+	return false
+}
+
+// stage moves a complete core into the staging directory so that analysis and
+// upload read a file nobody else writes to or rotates away. A hard link is
+// used when possible; across filesystems the core is copied.
+func (c *Collector) stage(coredump *CoredumpFile) error {
+	stagingPath := c.config().Staging.Path
+	if stagingPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	target := filepath.Join(stagingPath, coredump.ID+"-"+coredump.FileName)
+	if err := os.Link(coredump.Path, target); err != nil && !os.IsExist(err) {
+		klog.V(2).Infof("Cannot hard-link %s into staging (%v), copying", coredump.Path, err)
+		if err := copyToStaging(coredump.Path, target, coredump.Size); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.staged[target] = time.Now()
+	c.mu.Unlock()
+
+	coredump.OriginalPath = coredump.Path
+	coredump.Path = target
+	return nil
+}
+
+// copyToStaging copies through a temporary name so the target only ever
+// appears complete, and verifies the copy has the size the core was seen at.
+func copyToStaging(source, target string, size int64) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open core for staging: %w", err)
+	}
+	defer in.Close()
+
+	partial := target + ".partial"
+	out, err := os.Create(partial)
+	if err != nil {
+		return fmt.Errorf("failed to create staged core: %w", err)
+	}
+
+	written, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = fmt.Errorf("core changed while staging: copied %d bytes, expected %d", written, size)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to stage core: %w", err)
+	}
+
+	if err := os.Rename(partial, target); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to stage core: %w", err)
+	}
+	return nil
+}
+
+// cleanStaging removes staged cores past their retention and forgets
+// observations of cores that disappeared before they became complete, and
+// processed cores too old to be collected again.
+func (c *Collector) cleanStaging(now time.Time) {
+	retention := c.config().Staging.Retention
+	if retention <= 0 {
+		retention = defaultStagingRetention
+	}
+
+	c.mu.Lock()
+	var expired []string
+	for path, stagedAt := range c.staged {
+		if now.Sub(stagedAt) > retention {
+			expired = append(expired, path)
+			delete(c.staged, path)
+		}
+	}
+	for path := range c.observations {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(c.observations, path)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, path := range expired {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			klog.Warningf("Failed to remove staged core %s: %v", path, err)
+			continue
+		}
+		klog.V(2).Infof("Removed staged core %s", path)
+	}
+	c.pruneProcessed(now)
+}
+
+// resetStaging empties the staging directory on startup. Cores staged by a
+// previous run that did not finish the pipeline were not saved as
+// processed, so they are picked up again from where they were found.
+func (c *Collector) resetStaging() {
+	stagingPath := c.config().Staging.Path
+	if stagingPath == "" {
+		return
+	}
+	entries, err := os.ReadDir(stagingPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(stagingPath, entry.Name())); err != nil {
+			klog.Warningf("Failed to remove stale staged core %s: %v", entry.Name(), err)
+		}
+	}
+	if len(entries) > 0 {
+		klog.Infof("Removed %d stale entries from staging directory %s", len(entries), stagingPath)
+	}
+}
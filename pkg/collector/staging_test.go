@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+func newStagingTestCollector(cfg *config.CollectorConfig) *Collector {
+	return New(cfg, discovery.New(nil, &config.DiscoveryConfig{}), nil, nil, nil, nil, "", "")
+}
+
+func TestIsCompleteWaitsForStableFile(t *testing.T) {
+	c := newStagingTestCollector(&config.CollectorConfig{})
+	path := filepath.Join(t.TempDir(), "core.milvus.1.0.11")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("partial")
+
+	now := time.Now()
+	stat := func() os.FileInfo {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	if c.isComplete(path, stat(), now) {
+		t.Fatal("a core seen for the first time must not be complete")
+	}
+	if c.isComplete(path, stat(), now.Add(time.Second)) {
+		t.Fatal("a core still open for writing must not be complete")
+	}
+
+	file.WriteString(" and more")
+	file.Close()
+	if c.isComplete(path, stat(), now.Add(2*time.Second)) {
+		t.Fatal("a core that grew since the last scan must not be complete")
+	}
+	if !c.isComplete(path, stat(), now.Add(3*time.Second)) {
+		t.Fatal("expected a stable, closed core to be complete")
+	}
+}
+
+func TestIsCompleteHonoursStableFor(t *testing.T) {
+	c := newStagingTestCollector(&config.CollectorConfig{StableFor: time.Minute})
+	path := filepath.Join(t.TempDir(), "core.milvus.1.0.11")
+	if err := os.WriteFile(path, []byte("core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	now := time.Now()
+	c.isComplete(path, info, now)
+	if c.isComplete(path, info, now.Add(30*time.Second)) {
+		t.Error("expected core to wait for stableFor")
+	}
+	if !c.isComplete(path, info, now.Add(time.Minute)) {
+		t.Error("expected core to be complete after stableFor")
+	}
+}
+
+func TestStage(t *testing.T) {
+	stagingPath := filepath.Join(t.TempDir(), "staging")
+	c := newStagingTestCollector(&config.CollectorConfig{
+		Staging: config.StagingConfig{Path: stagingPath, Retention: time.Minute},
+	})
+
+	original := filepath.Join(t.TempDir(), "core.milvus.1.0.11")
+	if err := os.WriteFile(original, []byte("core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	coredump := &CoredumpFile{ID: "abc", Path: original, FileName: "core.milvus.1.0.11", Size: 4}
+
+	if err := c.stage(coredump); err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	if coredump.OriginalPath != original || filepath.Dir(coredump.Path) != stagingPath {
+		t.Fatalf("unexpected paths after staging: %s (original %s)", coredump.Path, coredump.OriginalPath)
+	}
+
+	// The staged core must survive the original being rotated away.
+	os.Remove(original)
+	if data, err := os.ReadFile(coredump.Path); err != nil || string(data) != "core" {
+		t.Fatalf("unexpected staged content %q: %v", data, err)
+	}
+
+	c.cleanStaging(time.Now())
+	if _, err := os.Stat(coredump.Path); err != nil {
+		t.Fatalf("staged core removed before retention: %v", err)
+	}
+	c.cleanStaging(time.Now().Add(2 * time.Minute))
+	if _, err := os.Stat(coredump.Path); !os.IsNotExist(err) {
+		t.Errorf("expected staged core to be removed after retention, got %v", err)
+	}
+}
+
+func TestCopyToStagingDetectsSizeChange(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "core")
+	if err := os.WriteFile(source, []byte("longer than expected"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "staged")
+
+	if err := copyToStaging(source, target, 4); err == nil {
+		t.Fatal("expected size mismatch to fail staging")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("a failed copy must not leave a staged core behind")
+	}
+	if _, err := os.Stat(target + ".partial"); !os.IsNotExist(err) {
+		t.Error("a failed copy must not leave a partial file behind")
+	}
+}
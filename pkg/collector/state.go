@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/chanstats"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid state transition")
+	ErrStateConflict     = errors.New("state changed concurrently")
+)
+
+// allowedTransitions is the coredump lifecycle. The collector creates cores
+// as discovered, the analyzer moves them through processing, and storage
+// decides what happens to analyzed cores. Stored and skipped are final;
+// error is final unless the analyzer retries the core.
+var allowedTransitions = map[FileStatus][]FileStatus{
+	StatusDiscovered: {StatusProcessing, StatusSkipped},
+	StatusProcessing: {StatusAnalyzed, StatusSkipped, StatusError},
+	StatusAnalyzed:   {StatusStored, StatusSkipped, StatusError},
+	StatusError:      {StatusProcessing},
+}
+
+// StateTransition is emitted for every status change.
+type StateTransition struct {
+	CoredumpID string     `json:"coredumpId"`
+	From       FileStatus `json:"from"`
+	To         FileStatus `json:"to"`
+	Version    int        `json:"version"`
+	Reason     string     `json:"reason,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
+}
+
+// stateMu serializes status changes of all coredumps, so a transition's
+// check of the current state and its update happen atomically.
+var stateMu sync.Mutex
+
+// StateMachine is the single owner of CoredumpFile.Status: components change
+// a core's status only through Transition. A nil *StateMachine still
+// validates and applies transitions but emits no events.
+type StateMachine struct {
+	eventChan chan StateTransition
+	rejected  atomic.Int64
+	finalized []func(*CoredumpFile)
+}
+
+func NewStateMachine() *StateMachine {
+	m := &StateMachine{
+		eventChan: make(chan StateTransition, 100),
+	}
+	chanstats.Register("state_transitions", m.eventChan)
+	return m
+}
+
+func (m *StateMachine) GetEventChannel() <-chan StateTransition {
+	return m.eventChan
+}
+
+// OnFinal calls finalized with every core that reaches a final status:
+// stored, skipped or error. It must be called before cores are processed.
+func (m *StateMachine) OnFinal(finalized func(*CoredumpFile)) {
+	m.finalized = append(m.finalized, finalized)
+}
+
+// Rejected returns the number of transitions refused so far.
+func (m *StateMachine) Rejected() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.rejected.Load()
+}
+
+// Transition moves a coredump from one status to another. The caller names
+// the status it expects the core to be in; if another component has moved it
+// in the meantime the transition fails with ErrStateConflict instead of
+// overwriting that change. The lifecycle has no cycles, so the expected
+// status pins the version the caller saw. A reason given for a transition to
+// StatusError becomes the core's error message.
+func (m *StateMachine) Transition(coredump *CoredumpFile, from, to FileStatus, reason string) error {
+	stateMu.Lock()
+
+	current := coredump.Status
+	if current == "" {
+		current = StatusDiscovered
+	}
+	if current != from {
+		stateMu.Unlock()
+		return m.reject(coredump, fmt.Errorf("%w: %s is %s, expected %s", ErrStateConflict, coredump.ID, current, from))
+	}
+	if !transitionAllowed(from, to) {
+		stateMu.Unlock()
+		return m.reject(coredump, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to))
+	}
+
+	coredump.Status = to
+	coredump.StateVersion++
+	coredump.UpdatedAt = metav1.Now()
+	if to == StatusError && reason != "" {
+		coredump.ErrorMessage = reason
+	}
+	if from == StatusError {
+		coredump.ErrorMessage = ""
+	}
+
+	transition := StateTransition{
+		CoredumpID: coredump.ID,
+		From:       from,
+		To:         to,
+		Version:    coredump.StateVersion,
+		Reason:     reason,
+		Timestamp:  time.Now(),
+	}
+	stateMu.Unlock()
+
+	klog.V(3).Infof("Coredump %s: %s -> %s (version %d)", coredump.ID, from, to, transition.Version)
+	m.emit(transition)
+	if m != nil && (to == StatusStored || to == StatusSkipped || to == StatusError) {
+		for _, finalized := range m.finalized {
+			finalized(coredump)
+		}
+	}
+	return nil
+}
+
+// State returns a coredump's status and version.
+func (c *CoredumpFile) State() (FileStatus, int) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return c.Status, c.StateVersion
+}
+
+func (m *StateMachine) reject(coredump *CoredumpFile, err error) error {
+	if m != nil {
+		m.rejected.Add(1)
+	}
+	klog.Errorf("Rejected status change of %s: %v", coredump.Path, err)
+	return err
+}
+
+func (m *StateMachine) emit(transition StateTransition) {
+	if m == nil {
+		return
+	}
+	if !chanstats.TrySend("state_transitions", m.eventChan, transition) {
+		klog.V(4).Infof("State transition channel is full, dropping transition of %s", transition.CoredumpID)
+	}
+}
+
+func transitionAllowed(from, to FileStatus) bool {
+	for _, allowed := range allowedTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
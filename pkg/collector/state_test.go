@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateMachineLifecycle(t *testing.T) {
+	m := NewStateMachine()
+	coredump := &CoredumpFile{ID: "abc", Status: StatusDiscovered}
+
+	steps := []struct{ from, to FileStatus }{
+		{StatusDiscovered, StatusProcessing},
+		{StatusProcessing, StatusAnalyzed},
+		{StatusAnalyzed, StatusStored},
+	}
+	for i, step := range steps {
+		if err := m.Transition(coredump, step.from, step.to, ""); err != nil {
+			t.Fatalf("%s -> %s: %v", step.from, step.to, err)
+		}
+
+		transition := <-m.GetEventChannel()
+		if transition.From != step.from || transition.To != step.to || transition.Version != i+1 {
+			t.Errorf("unexpected transition event %+v", transition)
+		}
+	}
+
+	if status, version := coredump.State(); status != StatusStored || version != 3 {
+		t.Errorf("expected stored at version 3, got %s at version %d", status, version)
+	}
+}
+
+func TestStateMachineRejects(t *testing.T) {
+	m := NewStateMachine()
+
+	t.Run("invalid", func(t *testing.T) {
+		coredump := &CoredumpFile{ID: "abc", Status: StatusStored}
+		err := m.Transition(coredump, StatusStored, StatusProcessing, "")
+		if !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("expected ErrInvalidTransition, got %v", err)
+		}
+		if coredump.Status != StatusStored || coredump.StateVersion != 0 {
+			t.Error("a rejected transition must not change the coredump")
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		coredump := &CoredumpFile{ID: "abc", Status: StatusError}
+		err := m.Transition(coredump, StatusAnalyzed, StatusStored, "")
+		if !errors.Is(err, ErrStateConflict) {
+			t.Fatalf("expected ErrStateConflict, got %v", err)
+		}
+		if coredump.Status != StatusError {
+			t.Error("a conflicting transition must not overwrite the current status")
+		}
+	})
+
+	if got := m.Rejected(); got != 2 {
+		t.Errorf("expected 2 rejected transitions, got %d", got)
+	}
+}
+
+func TestStateMachineNil(t *testing.T) {
+	var m *StateMachine
+	// A core created without an explicit status starts as discovered.
+	coredump := &CoredumpFile{ID: "abc"}
+
+	if err := m.Transition(coredump, StatusDiscovered, StatusProcessing, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Transition(coredump, StatusProcessing, StatusError, "gdb failed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if coredump.ErrorMessage != "gdb failed" {
+		t.Errorf("expected error message from reason, got %q", coredump.ErrorMessage)
+	}
+	if err := m.Transition(coredump, StatusError, StatusStored, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition, got %v", err)
+	}
+}
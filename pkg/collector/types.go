@@ -4,11 +4,16 @@ import (
 	"time"
 	
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"milvus-coredump-agent/pkg/chaos"
 	"milvus-coredump-agent/pkg/discovery"
 )
 
 type CoredumpFile struct {
+	ID          string                `json:"id"`
 	Path        string                `json:"path"`
+	// OriginalPath is where the core was found when Path points into the
+	// staging directory.
+	OriginalPath string               `json:"originalPath,omitempty"`
 	FileName    string                `json:"fileName"`
 	Size        int64                 `json:"size"`
 	ModTime     time.Time            `json:"modTime"`
@@ -20,21 +25,72 @@ type CoredumpFile struct {
 	Executable  string               `json:"executable"`
 	Arguments   []string             `json:"arguments"`
 	Hostname    string               `json:"hostname"`
+	// Node whose filesystem holds the core
+	NodeName    string               `json:"nodeName,omitempty"`
+	// Synthetic core written by the pipeline self-test
+	SelfTest    bool                 `json:"selfTest,omitempty"`
 	
 	// Associated pod information
 	PodName      string              `json:"podName,omitempty"`
 	PodNamespace string              `json:"podNamespace,omitempty"`
 	ContainerName string             `json:"containerName,omitempty"`
+	// main, init or ephemeral, see discovery.ContainerTypeMain
+	ContainerType string             `json:"containerType,omitempty"`
+	// Current container and image; after a restart the container ID is
+	// that of the replacement, which runs the same image, unless the
+	// container was found through the runtime, see cri
+	ContainerID  string              `json:"containerId,omitempty"`
+	// Set when the container was found through the runtime
+	PodUID       string              `json:"podUid,omitempty"`
+	// cgroup file of the crashed process, when core-handler sent it
+	Cgroup       string              `json:"cgroup,omitempty"`
+	Image        string              `json:"image,omitempty"`
+	ImageID      string              `json:"imageId,omitempty"`
 	InstanceName string              `json:"instanceName,omitempty"`
+	Component    string              `json:"component,omitempty"`
+	MilvusVersion string             `json:"milvusVersion,omitempty"`
+	// Name of the Milvus CR the instance was deployed from, in PodNamespace
+	MilvusCR     string              `json:"milvusCR,omitempty"`
+	// Labels of the Milvus instance, which AI quotas select on
+	InstanceLabels map[string]string `json:"instanceLabels,omitempty"`
 	
 	// Analysis results
 	IsAnalyzed   bool                `json:"isAnalyzed"`
 	ValueScore   float64             `json:"valueScore"`
+	// How the analyzer arrived at ValueScore
+	ScoreBreakdown *ScoreBreakdown   `json:"scoreBreakdown,omitempty"`
 	AnalysisTime time.Time           `json:"analysisTime,omitempty"`
+	// Analyses started, counting retries
+	AnalysisAttempts int             `json:"analysisAttempts,omitempty"`
+	// When a failed analysis is retried next
+	NextRetryAt  *time.Time          `json:"nextRetryAt,omitempty"`
 	AnalysisResults *AnalysisResults `json:"analysisResults,omitempty"`
+	// Crash site fingerprint and how often it was seen, counting this core
+	Fingerprint  string              `json:"fingerprint,omitempty"`
+	Occurrences  int                 `json:"occurrences,omitempty"`
+	// Names of the watchlist entries the core matched
+	Watchlist    []string            `json:"watchlist,omitempty"`
 	
-	// Processing status
+	// Storage results
+	StoragePath  string              `json:"storagePath,omitempty"`
+	// Roles of the storage backends holding the core, "primary" and/or
+	// "secondary", primary first
+	StorageBackends []string         `json:"storageBackends,omitempty"`
+	// Size on the backend and how it was compressed; Size is the original
+	StoredSize   int64               `json:"storedSize,omitempty"`
+	Compression  string              `json:"compression,omitempty"`
+	DuplicateOf  string              `json:"duplicateOf,omitempty"`
+	
+	// Chaos experiments running in the pod's namespace around the crash
+	UnderChaos       bool               `json:"underChaos"`
+	ChaosExperiments []chaos.Experiment `json:"chaosExperiments,omitempty"`
+	
+	// W3C traceparent of the coredump's trace, set when tracing is enabled
+	TraceParent  string              `json:"traceParent,omitempty"`
+	
+	// Processing status, changed only through StateMachine.Transition
 	Status       FileStatus          `json:"status"`
+	StateVersion int                 `json:"stateVersion"`
 	ErrorMessage string              `json:"errorMessage,omitempty"`
 	CreatedAt    metav1.Time         `json:"createdAt"`
 	UpdatedAt    metav1.Time         `json:"updatedAt"`
@@ -50,10 +106,82 @@ type AnalysisResults struct {
 	RegisterInfo    map[string]string `json:"registerInfo"`
 	SharedLibraries []string          `json:"sharedLibraries"`
 	
+	// Delve's view of the core when the process was a Go program
+	GoAnalysis      *GoAnalysis       `json:"goAnalysis,omitempty"`
+	
+	// What the pod logged in the window before the crash, oldest first
+	PreCrashLogs    []LogEntry        `json:"preCrashLogs,omitempty"`
+	// Prometheus series over the window before the crash
+	PreCrashMetrics []MetricSeries    `json:"preCrashMetrics,omitempty"`
+	
+	// Libraries and packages the process ran with
+	Environment     *EnvironmentManifest `json:"environment,omitempty"`
+	// jemalloc's allocation statistics at the time of the crash
+	Jemalloc        *JemallocStats    `json:"jemalloc,omitempty"`
+	
+	// Past crashes with the most similar stacks, most similar first
+	SimilarCrashes  []SimilarCrash    `json:"similarCrashes,omitempty"`
+	
 	// AI Analysis Results
 	AIAnalysis      *AIAnalysisResult `json:"aiAnalysis,omitempty"`
 }
 
+// SimilarCrash is a past crash whose stack is close to a core's, with what
+// its AI analysis found.
+type SimilarCrash struct {
+	CoredumpID  string    `json:"coredumpId"`
+	// Cosine similarity of the embedded stacks; 1 is the same stack.
+	Score       float64   `json:"score"`
+	Node        string    `json:"node,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Instance    string    `json:"instance,omitempty"`
+	Executable  string    `json:"executable"`
+	Timestamp   time.Time `json:"timestamp"`
+	CrashReason string    `json:"crashReason,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	
+	Summary         string   `json:"summary,omitempty"`
+	RootCause       string   `json:"rootCause,omitempty"`
+	Recommendations []string `json:"recommendations,omitempty"`
+}
+
+// ScoreBreakdown is the base value score and what each rule-based
+// dimension added to it.
+type ScoreBreakdown struct {
+	Base       float64          `json:"base"`
+	Dimensions []ScoreDimension `json:"dimensions"`
+	// Set when the dimensions added up to more than the maximum score
+	Capped bool    `json:"capped,omitempty"`
+	Total  float64 `json:"total"`
+}
+
+type ScoreDimension struct {
+	Name   string  `json:"name"`
+	Points float64 `json:"points"`
+	Max    float64 `json:"max"`
+	Detail string  `json:"detail"`
+}
+
+// LogEntry is a log line of the crashed pod.
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Container string    `json:"container,omitempty"`
+	Line      string    `json:"line"`
+}
+
+// MetricSeries is a series of a pre-crash metrics query, named after the
+// query; a query may return several series, told apart by their labels.
+type MetricSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Points []MetricPoint     `json:"points"`
+}
+
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
 type AIAnalysisResult struct {
 	Enabled          bool              `json:"enabled"`
 	Provider         string            `json:"provider"`
@@ -69,6 +197,80 @@ type AIAnalysisResult struct {
 	ErrorMessage     string            `json:"errorMessage,omitempty"`
 	RelatedIssues    []string          `json:"relatedIssues,omitempty"`    // Known similar issues
 	CodeSuggestions  []CodeSuggestion  `json:"codeSuggestions,omitempty"`  // Specific code fixes
+	ReusedFrom       string            `json:"reusedFrom,omitempty"`       // Core of the same crash group this analysis was made for
+	CostLimitReached string            `json:"costLimitReached,omitempty"` // Cost control limit that skipped the analysis
+	Redaction        *RedactionReport  `json:"redaction,omitempty"`        // What was redacted from the prompt
+}
+
+// RedactionReport counts what was redacted from the prompt of an AI
+// analysis, by detector or pattern name. The redacted text itself is not
+// kept.
+type RedactionReport struct {
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// GoAnalysis is what delve found in the core of a Go process.
+type GoAnalysis struct {
+	GoVersion         string `json:"goVersion,omitempty"`
+	PanicValue        string `json:"panicValue,omitempty"`
+	FatalError        string `json:"fatalError,omitempty"`
+	CrashingGoroutine int    `json:"crashingGoroutine,omitempty"`
+	GoroutineCount    int    `json:"goroutineCount"`
+	// Stack of the crashing goroutine with arguments and locals
+	StackTrace        string `json:"stackTrace"`
+	// Every goroutine with its top frames, truncated for large dumps
+	Goroutines        string `json:"goroutines,omitempty"`
+}
+
+// JemallocStats are jemalloc's allocation statistics read from a core,
+// summed over all arenas. Byte counts mean what jemalloc's stats.*
+// mallctls do.
+type JemallocStats struct {
+	Arenas        int     `json:"arenas"`
+	// Bytes in live allocations; zero when large allocations couldn't be read
+	Allocated     uint64  `json:"allocated,omitempty"`
+	Active        uint64  `json:"active"`
+	Resident      uint64  `json:"resident,omitempty"`
+	Mapped        uint64  `json:"mapped,omitempty"`
+	Retained      uint64  `json:"retained,omitempty"`
+	// Share of active pages not holding live allocations, 1 - allocated/active
+	Fragmentation float64 `json:"fragmentation,omitempty"`
+	// Small size classes leaving the most slab space unused, worst first
+	Bins          []JemallocBin `json:"bins,omitempty"`
+}
+
+type JemallocBin struct {
+	Size        uint64  `json:"size"`
+	// Live allocations and the slabs holding them
+	Regions     uint64  `json:"regions"`
+	Slabs       uint64  `json:"slabs"`
+	// Regions over the slabs' capacity
+	Utilization float64 `json:"utilization"`
+}
+
+// EnvironmentManifest inventories what a crashed process ran with: the
+// shared libraries mapped in its core and, when the container's root
+// filesystem could be reached, its OS and installed packages.
+type EnvironmentManifest struct {
+	OS              string            `json:"os,omitempty"`
+	Libraries       []SharedLibrary   `json:"libraries"`
+	// dpkg or apk; empty when no package database was found
+	PackageManager  string            `json:"packageManager,omitempty"`
+	Packages        []Package         `json:"packages,omitempty"`
+}
+
+type SharedLibrary struct {
+	Path     string `json:"path"`
+	BuildID  string `json:"buildId,omitempty"`
+	// The package owning the file, from the package database
+	Package  string `json:"package,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 type CodeSuggestion struct {
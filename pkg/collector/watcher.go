@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+const (
+	WatchModeInotify = "inotify"
+	WatchModePoll    = "poll"
+
+	defaultResyncInterval = 5 * time.Minute
+	// settleInterval is how often cores that changed are checked for
+	// completeness while being watched.
+	settleInterval = time.Second
+)
+
+// watchCoredumpFiles follows CoredumpPath and its subdirectories with
+// inotify, so a core is picked up as soon as it is complete instead of on
+// the next scan. It falls back to polling when configured to, or when
+// inotify is unavailable, e.g. because the node ran out of watches. The
+// directory is still rescanned every ResyncInterval to catch what inotify
+// dropped.
+func (c *Collector) watchCoredumpFiles(ctx context.Context) {
+	if c.config().WatchMode == WatchModePoll {
+		c.scanCoredumpFiles(ctx)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Warningf("Failed to start inotify watcher, falling back to polling: %v", err)
+		c.scanCoredumpFiles(ctx)
+		return
+	}
+
+	pending := make(map[string]bool)
+	if err := c.watchTree(watcher, c.config().CoredumpPath, pending); err != nil {
+		klog.Warningf("Failed to watch %s, falling back to polling: %v", c.config().CoredumpPath, err)
+		watcher.Close()
+		c.scanCoredumpFiles(ctx)
+		return
+	}
+	defer watcher.Close()
+	klog.Infof("Watching %s for coredumps", c.config().CoredumpPath)
+
+	resyncInterval := c.config().ResyncInterval
+	if resyncInterval <= 0 {
+		resyncInterval = defaultResyncInterval
+	}
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+	settle := time.NewTicker(settleInterval)
+	defer settle.Stop()
+
+	skipped := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			c.handleWatchEvent(watcher, event, pending)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				klog.Warningf("Inotify queue overflowed, rescanning %s", c.config().CoredumpPath)
+				c.watchTree(watcher, c.config().CoredumpPath, pending)
+				continue
+			}
+			klog.Warningf("Inotify watcher error: %v", err)
+		case <-settle.C:
+			if len(pending) == 0 {
+				continue
+			}
+			if c.pressure.Degraded() {
+				skipped++
+				if skipped < c.pressure.DegradedScanFactor() {
+					continue
+				}
+			}
+			skipped = 0
+			c.checkPending(ctx, pending, time.Now())
+		case <-resync.C:
+			c.watchTree(watcher, c.config().CoredumpPath, pending)
+			c.cleanStaging(time.Now())
+		}
+	}
+}
+
+// watchTree adds a watch on every directory under root and marks the cores
+// found as pending. Adding an existing watch is a no-op.
+func (c *Collector) watchTree(watcher *fsnotify.Watcher, root string, pending map[string]bool) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				if path == root {
+					return err
+				}
+				klog.Warningf("Failed to watch %s: %v", path, err)
+			}
+			return nil
+		}
+		if c.isCoredumpFile(info.Name()) && !c.isProcessed(path) {
+			pending[path] = true
+		}
+		return nil
+	})
+}
+
+func (c *Collector) handleWatchEvent(watcher *fsnotify.Watcher, event fsnotify.Event, pending map[string]bool) {
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		delete(pending, event.Name)
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			// Cores may be written before the watch on a new directory is
+			// in place, so the directory is walked as well.
+			c.watchTree(watcher, event.Name, pending)
+			return
+		}
+		if c.isCoredumpFile(info.Name()) && !c.isProcessed(event.Name) {
+			pending[event.Name] = true
+		}
+	}
+}
+
+// checkPending collects the pending cores that are now complete. Cores that
+// disappeared or are too old are dropped; the others wait for the next check.
+func (c *Collector) checkPending(ctx context.Context, pending map[string]bool, now time.Time) {
+	for path := range pending {
+		info, err := os.Stat(path)
+		if err != nil || c.isProcessed(path) || now.Sub(info.ModTime()) > c.config().MaxFileAge {
+			delete(pending, path)
+			continue
+		}
+		if !c.isComplete(path, info, now) {
+			continue
+		}
+		delete(pending, path)
+		if coredumpFile := c.parseCoredumpFile(path, info); coredumpFile != nil {
+			c.processCoredumpFile(ctx, coredumpFile)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+func TestWatcherPicksUpNewCores(t *testing.T) {
+	dir := t.TempDir()
+	c := newStagingTestCollector(&config.CollectorConfig{
+		CoredumpPath: dir,
+		// Long enough that only inotify can find the cores in time.
+		WatchInterval:  time.Hour,
+		ResyncInterval: time.Hour,
+		MaxFileAge:     time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.watchCoredumpFiles(ctx)
+	// Give the watcher time to add its watches.
+	time.Sleep(100 * time.Millisecond)
+
+	path := filepath.Join(dir, "core.milvus.1000.1234567890.11")
+	if err := os.WriteFile(path, []byte("synthetic core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	event := testutil.AssertEventReceived(t, c.GetEventChannel(), 5*time.Second, "core in the watched directory")
+	if event.Type != EventTypeFileDiscovered || event.CoredumpFile.Path != path {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	// Directories created after the watch started are followed too.
+	subdir := filepath.Join(dir, "milvus")
+	if err := os.Mkdir(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(subdir, "core.milvus.1001.1234567890.6")
+	if err := os.WriteFile(nested, []byte("synthetic core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	event = testutil.AssertEventReceived(t, c.GetEventChannel(), 5*time.Second, "core in a new subdirectory")
+	if event.CoredumpFile.Path != nested {
+		t.Fatalf("expected %s, got %s", nested, event.CoredumpFile.Path)
+	}
+}
+
+func TestScanWaitsForRoomInsteadOfDroppingCores(t *testing.T) {
+	dir := t.TempDir()
+	c := newStagingTestCollector(&config.CollectorConfig{CoredumpPath: dir, MaxFileAge: time.Hour})
+	// More cores than the event channel holds, with nobody reading it.
+	cores := cap(c.eventChan) + 50
+	for i := 0; i < cores; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("core.milvus.%d.1234567890.11", 1000+i))
+		if err := os.WriteFile(path, []byte("synthetic core"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The first scan only observes the files.
+	c.scanDirectory(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		c.scanDirectory(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.eventChan) < cap(c.eventChan) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the event channel to fill up, holds %d events", len(c.eventChan))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the scan to wait for room in the event channel")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < cores {
+		event := testutil.AssertEventReceived(t, c.GetEventChannel(), 5*time.Second, "queued core")
+		seen[event.CoredumpFile.Path] = true
+	}
+	<-done
+}
+
+func TestCancelledSendForgetsCore(t *testing.T) {
+	c := newStagingTestCollector(&config.CollectorConfig{})
+	for i := 0; i < cap(c.eventChan); i++ {
+		c.eventChan <- CollectionEvent{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	coredump := &CoredumpFile{ID: "abc", Path: "/cores/core.milvus.1.0.11"}
+	c.processCoredumpFile(ctx, coredump, "coredumpctl:1/2")
+	if c.isProcessed(coredump.Path) || c.isProcessed("coredumpctl:1/2") {
+		t.Error("expected a core that never entered the pipeline to be collected again")
+	}
+	if _, found := c.inFlight[coredump.ID]; found {
+		t.Error("expected the core not to wait for the pipeline to finish it")
+	}
+}
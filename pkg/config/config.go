@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type Config struct {
@@ -15,6 +18,35 @@ type Config struct {
 	Storage   StorageConfig   `mapstructure:"storage"`
 	Cleaner   CleanerConfig   `mapstructure:"cleaner"`
 	Monitor   MonitorConfig   `mapstructure:"monitor"`
+	API       APIConfig       `mapstructure:"api"`
+	Server    ServerConfig    `mapstructure:"server"`
+	// Proxy is the default for every outbound integration that doesn't set
+	// its own.
+	Proxy     ProxyConfig     `mapstructure:"proxy"`
+}
+
+type ProxyConfig struct {
+	URL             string   `mapstructure:"url"`
+	Username        string   `mapstructure:"username"`
+	Password        string   `mapstructure:"password"`
+	NoProxy         []string `mapstructure:"noProxy"`
+	CABundle        string   `mapstructure:"caBundle"`
+	FromEnvironment bool     `mapstructure:"fromEnvironment"`
+	// Direct opts an integration out of the global proxy.
+	Direct          bool     `mapstructure:"direct"`
+}
+
+type TLSConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile             string `mapstructure:"caFile"`
+	CertFile           string `mapstructure:"certFile"`
+	KeyFile            string `mapstructure:"keyFile"`
+	ServerName         string `mapstructure:"serverName"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"`
+}
+
+func (p ProxyConfig) isSet() bool {
+	return p.URL != "" || p.FromEnvironment || p.Direct
 }
 
 type AgentConfig struct {
@@ -23,30 +55,446 @@ type AgentConfig struct {
 	LogLevel    string `mapstructure:"logLevel"`
 	MetricsPort int    `mapstructure:"metricsPort"`
 	HealthPort  int    `mapstructure:"healthPort"`
+	Pressure    PressureConfig `mapstructure:"pressure"`
+	Preflight   PreflightConfig `mapstructure:"preflight"`
+	// StateDir keeps the cores already processed and the restart trackers
+	// across agent restarts. Empty keeps them in memory only.
+	StateDir    string `mapstructure:"stateDir"`
+	LeaderElection LeaderElectionConfig `mapstructure:"leaderElection"`
+	ConfigReload   ConfigReloadConfig   `mapstructure:"configReload"`
+}
+
+// ConfigReloadConfig reloads the configuration file when it changes, once
+// it has been left alone for Debounce.
+type ConfigReloadConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+// LeaderElectionConfig makes the agents elect the one that takes
+// cluster-scoped actions, such as the cleaner's uninstalls. Without it
+// every agent takes them. The Lease is kept in Namespace, by default the
+// agent's own.
+type LeaderElectionConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	LeaseName     string        `mapstructure:"leaseName"`
+	Namespace     string        `mapstructure:"namespace"`
+	LeaseDuration time.Duration `mapstructure:"leaseDuration"`
+	RenewDeadline time.Duration `mapstructure:"renewDeadline"`
+	RetryPeriod   time.Duration `mapstructure:"retryPeriod"`
+}
+
+type PreflightConfig struct {
+	// FailurePolicy is "degrade" (turn off features whose dependency is
+	// missing and keep running) or "failFast" (exit on any failed check).
+	FailurePolicy string `mapstructure:"failurePolicy"`
+}
+
+type PressureConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	CheckInterval   time.Duration `mapstructure:"checkInterval"`
+	// Fractions of the cgroup memory limit and CPU quota at which the agent
+	// enters degraded mode.
+	MemoryThreshold float64       `mapstructure:"memoryThreshold"`
+	CPUThreshold    float64       `mapstructure:"cpuThreshold"`
+	// How long gdb analysis waits for pressure to clear before falling back
+	// to basic analysis.
+	MaxAnalysisDeferral time.Duration `mapstructure:"maxAnalysisDeferral"`
+	// Scans run only every Nth tick while degraded.
+	DegradedScanFactor  int           `mapstructure:"degradedScanFactor"`
 }
 
 type DiscoveryConfig struct {
 	ScanInterval       time.Duration `mapstructure:"scanInterval"`
 	Namespaces         []string      `mapstructure:"namespaces"`
+	// Sent with the pod list and watch requests; pods outside it are never
+	// seen, so it must cover the labels, instances and rules below
+	LabelSelector      string        `mapstructure:"labelSelector"`
+	// How often the pod caches replay every pod
+	ResyncInterval     time.Duration `mapstructure:"resyncInterval"`
 	HelmReleaseLabels  []string      `mapstructure:"helmReleaseLabels"`
 	OperatorLabels     []string      `mapstructure:"operatorLabels"`
+	Chaos              ChaosConfig   `mapstructure:"chaos"`
+	MilvusCR           MilvusCRConfig `mapstructure:"milvusCR"`
+	// Instances registered by hand, for those the labels above miss
+	Instances          []RegisteredInstance `mapstructure:"instances"`
+	// Rules identifying deployments without the helm or operator labels,
+	// tried in order after Instances
+	Rules              []InstanceRule `mapstructure:"rules"`
+}
+
+// InstanceRule identifies the Milvus pods of nonstandard deployments, such
+// as kustomize or raw manifests. A pod matches when it matches Selector, its
+// name matches NamePattern and its controller's kind is one of OwnerKinds;
+// criteria left empty match any pod, but a rule needs a selector or a name
+// pattern. The instance is named by the pod's InstanceLabel, else by the
+// pattern's "instance" group, else after the pod.
+type InstanceRule struct {
+	Name          string   `mapstructure:"name"`
+	Selector      string   `mapstructure:"selector"`
+	NamePattern   string   `mapstructure:"namePattern"`
+	OwnerKinds    []string `mapstructure:"ownerKinds"`
+	InstanceLabel string   `mapstructure:"instanceLabel"`
+}
+
+// RegisteredInstance declares the pods in Namespace matching Selector, a
+// Kubernetes label selector such as "app=vectordb,tier in (query,data)", to
+// be the Milvus instance Name. Its namespace is watched even when it isn't
+// in Namespaces.
+type RegisteredInstance struct {
+	Name      string `mapstructure:"name"`
+	Namespace string `mapstructure:"namespace"`
+	Selector  string `mapstructure:"selector"`
+}
+
+// MilvusCRConfig enables watching the status of Milvus operator CRs.
+// Condition transitions are kept for Retention.
+type MilvusCRConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	ResyncInterval time.Duration `mapstructure:"resyncInterval"`
+	Retention      time.Duration `mapstructure:"retention"`
+}
+
+// ChaosConfig enables tracking of chaos experiments. Crashes within Margin of
+// a running experiment that targets their namespace are marked as under
+// chaos; ended experiments are kept for Retention.
+type ChaosConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	ChaosMesh    bool          `mapstructure:"chaosMesh"`
+	Litmus       bool          `mapstructure:"litmus"`
+	PollInterval time.Duration `mapstructure:"pollInterval"`
+	Margin       time.Duration `mapstructure:"margin"`
+	Retention    time.Duration `mapstructure:"retention"`
 }
 
 type CollectorConfig struct {
 	CoredumpPath     string        `mapstructure:"coredumpPath"`
 	HostCoredumpPath string        `mapstructure:"hostCoredumpPath"`
+	// WatchMode is inotify (default), falling back to scanning every
+	// WatchInterval when inotify is unavailable, or poll.
+	WatchMode        string        `mapstructure:"watchMode"`
 	WatchInterval    time.Duration `mapstructure:"watchInterval"`
+	// Full rescan while watching with inotify, to catch dropped events.
+	ResyncInterval   time.Duration `mapstructure:"resyncInterval"`
 	MaxFileAge       time.Duration `mapstructure:"maxFileAge"`
 	MaxFileSize      string        `mapstructure:"maxFileSize"`
+	// A core is only picked up once its size and mtime have held for
+	// StableFor across scans and no process has it open for writing.
+	StableFor        time.Duration `mapstructure:"stableFor"`
+	// /proc of the host PID namespace, used to find writers of a core.
+	ProcPath         string        `mapstructure:"procPath"`
+	Staging          StagingConfig `mapstructure:"staging"`
+	Coredumpctl      CoredumpctlConfig `mapstructure:"coredumpctl"`
+	PipeHandler      PipeHandlerConfig `mapstructure:"pipeHandler"`
+	CRI              CRIConfig         `mapstructure:"cri"`
+}
+
+// CRIConfig attributes cores to the exact container and pod they came
+// from, found through the crashed process' cgroup or the container
+// runtime's record of its exit, rather than from the executable's name
+// and the timing of pod restarts.
+type CRIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CRI socket of containerd or CRI-O as the agent sees it, such as
+	// unix:///host/run/containerd/containerd.sock.
+	Endpoint string        `mapstructure:"endpoint"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// How far from the crash a container may have exited to be the one
+	// that crashed, 30s when unset.
+	MatchWindow time.Duration `mapstructure:"matchWindow"`
+}
+
+// PipeHandlerConfig receives the cores core-handler streams from the
+// kernel's core_pattern pipe on a unix socket. Received cores are written
+// to ReceiveDir and removed after Staging.Retention.
+type PipeHandlerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// The socket core-handler connects to, see corepipe.DefaultSocket.
+	SocketPath string `mapstructure:"socketPath"`
+	// Under the temporary directory when empty.
+	ReceiveDir string `mapstructure:"receiveDir"`
+}
+
+// CoredumpctlConfig picks up the cores systemd-coredump records, whether
+// kept in the journal or compressed under /var/lib/systemd/coredump, by
+// listing them with coredumpctl and extracting each to ExtractDir.
+type CoredumpctlConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// coredumpctl binary, looked up in PATH when empty.
+	Command string `mapstructure:"command"`
+	// Journal directory of the host, the local journal when empty.
+	Directory string `mapstructure:"directory"`
+	// Where cores are extracted to, under the temporary directory when
+	// empty. Extracted cores are removed after Staging.Retention.
+	ExtractDir string        `mapstructure:"extractDir"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+type StagingConfig struct {
+	// Work directory cores are hard-linked into (copied when it is on
+	// another filesystem) before analysis. Empty analyzes cores in place.
+	Path      string        `mapstructure:"path"`
+	// How long a staged core is kept for analysis and upload.
+	Retention time.Duration `mapstructure:"retention"`
 }
 
 type AnalyzerConfig struct {
 	EnableGdbAnalysis bool          `mapstructure:"enableGdbAnalysis"`
 	GdbTimeout        time.Duration `mapstructure:"gdbTimeout"`
+	// Deprecated: use Thresholds.Store, which falls back to it.
 	ValueThreshold    float64       `mapstructure:"valueThreshold"`
+	Thresholds        ScoreThresholds `mapstructure:"thresholds"`
 	IgnorePatterns    []string      `mapstructure:"ignorePatterns"`
 	PanicKeywords     []string      `mapstructure:"panicKeywords"`
 	AIAnalysis        AIAnalysisConfig `mapstructure:"aiAnalysis"`
+	Watchdog          WatchdogConfig   `mapstructure:"watchdog"`
+	CrashGroups       CrashGroupConfig `mapstructure:"crashGroups"`
+	Delve             DelveConfig      `mapstructure:"delve"`
+	Sandbox           GdbSandboxConfig `mapstructure:"sandbox"`
+	Similarity        SimilarityConfig `mapstructure:"similarity"`
+	GitHubIssues      GitHubIssuesConfig `mapstructure:"githubIssues"`
+	Symbols           SymbolsConfig    `mapstructure:"symbols"`
+	Environment       EnvironmentConfig `mapstructure:"environment"`
+	Jemalloc          JemallocConfig   `mapstructure:"jemalloc"`
+	Watchlist         []WatchlistEntry `mapstructure:"watchlist"`
+	PreCrashLogs      PreCrashLogsConfig `mapstructure:"preCrashLogs"`
+	PreCrashMetrics   PreCrashMetricsConfig `mapstructure:"preCrashMetrics"`
+	Retry             RetryConfig      `mapstructure:"retry"`
+	// Analyses run by at most MaxConcurrentAnalyses workers; further cores
+	// wait in a queue of AnalysisQueueSize. On shutdown the workers get
+	// DrainTimeout to finish the running and queued analyses.
+	MaxConcurrentAnalyses int           `mapstructure:"maxConcurrentAnalyses"`
+	AnalysisQueueSize     int           `mapstructure:"analysisQueueSize"`
+	DrainTimeout          time.Duration `mapstructure:"drainTimeout"`
+}
+
+// RetryConfig retries failed analyses, such as gdb timeouts, up to
+// MaxAttempts analyses in all. The first retry waits InitialBackoff, and
+// each one after that twice as long, up to MaxBackoff.
+type RetryConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	MaxAttempts    int           `mapstructure:"maxAttempts"`
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"`
+	MaxBackoff     time.Duration `mapstructure:"maxBackoff"`
+}
+
+// PreCrashMetricsConfig attaches the series of a set of Prometheus queries
+// over the window before a crash to the analysis of its cores.
+type PreCrashMetricsConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	PrometheusURL string        `mapstructure:"prometheusUrl"`
+	Window        time.Duration `mapstructure:"window"`
+	Step          time.Duration `mapstructure:"step"`
+	// {namespace}, {pod} and {container} in the queries are replaced with
+	// the crashed container's.
+	Queries []MetricQuery `mapstructure:"queries"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Proxy   ProxyConfig   `mapstructure:"proxy"`
+	TLS     TLSConfig     `mapstructure:"tls"`
+}
+
+type MetricQuery struct {
+	Name  string `mapstructure:"name"`
+	Query string `mapstructure:"query"`
+}
+
+// PreCrashLogsConfig attaches the log lines a pod wrote in the window
+// before its crash, queried from Loki, to the analysis of its cores.
+type PreCrashLogsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	LokiURL string `mapstructure:"lokiUrl"`
+	// LogQL stream selector; {namespace}, {pod} and {container} are
+	// replaced with the crashed container's.
+	Selector string        `mapstructure:"selector"`
+	Window   time.Duration `mapstructure:"window"`
+	// Newest lines kept when the window holds more.
+	Limit   int           `mapstructure:"limit"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Sent as X-Scope-OrgID to multi-tenant Loki.
+	TenantID string      `mapstructure:"tenantId"`
+	Proxy    ProxyConfig `mapstructure:"proxy"`
+	TLS      TLSConfig   `mapstructure:"tls"`
+}
+
+// WatchlistEntry singles out the cores of a bug being hunted. Its patterns
+// are regular expressions; a core matches when all patterns that are set
+// match its executable, instance and stack trace.
+type WatchlistEntry struct {
+	Name       string `mapstructure:"name"`
+	Executable string `mapstructure:"executable"`
+	Instance   string `mapstructure:"instance"`
+	Stack      string `mapstructure:"stack"`
+}
+
+// JemallocConfig enables reading jemalloc's allocation statistics from
+// cores with a gdb Python script after gdb's analysis. The script needs
+// jemalloc's debug info, see SymbolsConfig.
+type JemallocConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Defaults to the gdb timeout.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// EnvironmentConfig enables the environment manifest of each core: the
+// shared libraries it mapped and the packages installed in its container.
+// The container's files are reached through a process of the container,
+// found in ProcPath, which must be /proc of the host PID namespace.
+type EnvironmentConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	ProcPath string `mapstructure:"procPath"`
+}
+
+// SymbolsConfig lets gdb fetch the debug info that stripped release images
+// lack, from debuginfod servers and from local directories of .debug files.
+type SymbolsConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	DebuginfodURLs []string `mapstructure:"debuginfodUrls"`
+	// Searched before the debuginfod servers, laid out like /usr/lib/debug.
+	DebugFileDirectories []string `mapstructure:"debugFileDirectories"`
+	// Where downloaded debug info is kept; the least recently downloaded
+	// files are removed once it grows past MaxCacheSize.
+	CacheDir     string `mapstructure:"cacheDir"`
+	MaxCacheSize string `mapstructure:"maxCacheSize"`
+}
+
+// DelveConfig enables a second, delve-based analysis of cores from Go
+// executables after gdb's. Delve needs the executable, which is looked up
+// at the path recorded in the core and then in ExecutablePaths.
+type DelveConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	// Defaults to the gdb timeout.
+	Timeout         time.Duration `mapstructure:"timeout"`
+	ExecutablePaths []string      `mapstructure:"executablePaths"`
+}
+
+// GitHubIssuesConfig searches the issues of GitHub repositories for the
+// top stack frames of each core and lists the matches as the related
+// issues of its AI analysis, in place of the ones the model names.
+type GitHubIssuesConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Repos   []string `mapstructure:"repos"`
+	// Falls back to GITHUB_TOKEN. Without a token GitHub allows 10
+	// searches a minute.
+	Token string `mapstructure:"token"`
+	// For GitHub Enterprise, such as https://github.example.com/api/v3.
+	APIURL string `mapstructure:"apiUrl"`
+	// Frames searched for, 3 when unset; frames of libc, the C++ and Go
+	// runtimes and signal handling are skipped.
+	Frames     int `mapstructure:"frames"`
+	MaxResults int `mapstructure:"maxResults"`
+	// How long the issues found for a crash fingerprint are reused.
+	CacheTTL time.Duration `mapstructure:"cacheTTL"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Proxy    ProxyConfig   `mapstructure:"proxy"`
+	TLS      TLSConfig     `mapstructure:"tls"`
+}
+
+// SimilarityConfig finds the past crashes most like a new one: the top
+// frames of each analyzed core are embedded through an OpenAI-compatible
+// embeddings endpoint and kept, with what the AI analysis found, in a Milvus
+// collection, created on first use.
+type SimilarityConfig struct {
+	Enabled   bool                   `mapstructure:"enabled"`
+	Embedding EmbeddingConfig        `mapstructure:"embedding"`
+	Milvus    MilvusCollectionConfig `mapstructure:"milvus"`
+	// Top stack frames embedded, 20 when unset.
+	Frames int `mapstructure:"frames"`
+	// Similar crashes attached to an analysis, 5 when unset.
+	TopK int `mapstructure:"topK"`
+	// Cosine similarity below which a crash is not counted as similar.
+	MinScore float64 `mapstructure:"minScore"`
+}
+
+type EmbeddingConfig struct {
+	// Full URL of the endpoint, such as https://api.openai.com/v1/embeddings.
+	URL     string        `mapstructure:"url"`
+	Model   string        `mapstructure:"model"`
+	APIKey  string        `mapstructure:"apiKey"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Proxy   ProxyConfig   `mapstructure:"proxy"`
+	TLS     TLSConfig     `mapstructure:"tls"`
+}
+
+// MilvusCollectionConfig reaches a collection through Milvus' RESTful API.
+type MilvusCollectionConfig struct {
+	// Such as http://milvus.milvus.svc:19530.
+	Address string `mapstructure:"address"`
+	// "user:password", or an API key.
+	Token      string        `mapstructure:"token"`
+	Database   string        `mapstructure:"database"`
+	Collection string        `mapstructure:"collection"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	Proxy      ProxyConfig   `mapstructure:"proxy"`
+	TLS        TLSConfig     `mapstructure:"tls"`
+}
+
+// GdbSandboxConfig runs gdb in a short-lived pod on the agent's node
+// instead of the agent's container, so the agent image needs no gdb and a
+// malicious core only reaches a locked-down pod. The pod sees a core at the
+// path the agent does through Mounts, which map agent paths to the host
+// directories behind them.
+type GdbSandboxConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Image   string `mapstructure:"image"`
+	// Defaults to the agent's namespace.
+	Namespace string `mapstructure:"namespace"`
+	// Limits of the pod, as Kubernetes quantities.
+	CPU    string `mapstructure:"cpu"`
+	Memory string `mapstructure:"memory"`
+	// How long the pod may take to be scheduled and pull its image, on top
+	// of the gdb timeout.
+	StartTimeout time.Duration  `mapstructure:"startTimeout"`
+	Mounts       []SandboxMount `mapstructure:"mounts"`
+}
+
+// SandboxMount gives the gdb sandbox HostPath, read-only, at Path.
+type SandboxMount struct {
+	Path     string `mapstructure:"path"`
+	HostPath string `mapstructure:"hostPath"`
+}
+
+// DefaultCriticalThreshold is the value score from which a crash is critical.
+const DefaultCriticalThreshold = 8.0
+
+// ScoreThresholds are the value score cut-offs, on the analyzer's 0-10
+// scale, that storage, alerting and the API share.
+type ScoreThresholds struct {
+	// Cores scoring below Store are not stored.
+	Store float64 `mapstructure:"store" json:"store"`
+	// Crashes scoring at least Critical alert as critical.
+	Critical float64 `mapstructure:"critical" json:"critical"`
+}
+
+// EffectiveThresholds fills in what Thresholds leaves unset: Store from
+// ValueThreshold, Critical from DefaultCriticalThreshold.
+func (c *AnalyzerConfig) EffectiveThresholds() ScoreThresholds {
+	thresholds := c.Thresholds
+	if thresholds.Store == 0 {
+		thresholds.Store = c.ValueThreshold
+	}
+	if thresholds.Critical == 0 {
+		thresholds.Critical = DefaultCriticalThreshold
+	}
+	return thresholds
+}
+
+type CrashGroupConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Top stack frames hashed into the fingerprint, 5 when unset.
+	Frames    int `mapstructure:"frames"`
+	MaxGroups int `mapstructure:"maxGroups"`
+	// Give duplicates the AI analysis of the group's first core instead of
+	// asking the provider again.
+	ReuseAIAnalysis bool `mapstructure:"reuseAIAnalysis"`
+}
+
+type WatchdogConfig struct {
+	// Time between SIGTERM and SIGKILL of a timed-out subprocess group.
+	KillGracePeriod time.Duration `mapstructure:"killGracePeriod"`
+	CheckInterval   time.Duration `mapstructure:"checkInterval"`
+	// Parent of the per-analysis work directories, removed after each run.
+	WorkDir         string        `mapstructure:"workDir"`
 }
 
 type AIAnalysisConfig struct {
@@ -61,6 +509,42 @@ type AIAnalysisConfig struct {
 	EnableCostControl bool          `mapstructure:"enableCostControl"`
 	MaxCostPerMonth   float64       `mapstructure:"maxCostPerMonth"`
 	MaxAnalysisPerHour int          `mapstructure:"maxAnalysisPerHour"`
+	// Override the provider's built-in price, in USD per million tokens.
+	InputCostPerMillion  float64    `mapstructure:"inputCostPerMillion"`
+	OutputCostPerMillion float64    `mapstructure:"outputCostPerMillion"`
+	Proxy             ProxyConfig   `mapstructure:"proxy"`
+	TLS               TLSConfig     `mapstructure:"tls"`
+	Redaction         RedactionConfig `mapstructure:"redaction"`
+	// Monthly cost limits for the cores of some tenants, enforced with
+	// the global ones when cost control is enabled.
+	Quotas            []AIQuota     `mapstructure:"quotas"`
+}
+
+// AIQuota caps the monthly AI spend on the cores of pods in one of
+// Namespaces whose Milvus instance carries all of Labels, either of which
+// may be left empty. A core selected by several quotas counts against
+// each of them.
+type AIQuota struct {
+	Name            string            `mapstructure:"name"`
+	Namespaces      []string          `mapstructure:"namespaces"`
+	Labels          map[string]string `mapstructure:"labels"`
+	MaxCostPerMonth float64           `mapstructure:"maxCostPerMonth"`
+}
+
+// RedactionConfig removes secrets and identifying details from the prompt
+// before it is sent to the AI provider. Detectors are built in: "ip",
+// "token" and "s3-key", all of them when none are named. Patterns are
+// regular expressions; one with a group redacts what the first group
+// matched, otherwise the whole match.
+type RedactionConfig struct {
+	Enabled   bool               `mapstructure:"enabled"`
+	Detectors []string           `mapstructure:"detectors"`
+	Patterns  []RedactionPattern `mapstructure:"patterns"`
+}
+
+type RedactionPattern struct {
+	Name    string `mapstructure:"name"`
+	Pattern string `mapstructure:"pattern"`
 }
 
 type StorageConfig struct {
@@ -69,7 +553,37 @@ type StorageConfig struct {
 	MaxStorageSize    string        `mapstructure:"maxStorageSize"`
 	RetentionDays     int           `mapstructure:"retentionDays"`
 	CompressionEnabled bool         `mapstructure:"compressionEnabled"`
+	// Compression is the codec of stored cores: gzip, zstd, lz4 or none.
+	// When empty, CompressionEnabled picks gzip or none.
+	Compression       string        `mapstructure:"compression"`
+	// CompressionLevel tunes the codec, 0 uses its default level.
+	CompressionLevel  int           `mapstructure:"compressionLevel"`
+	SelfTestOnStartup bool          `mapstructure:"selfTestOnStartup"`
+	DedupMode         string        `mapstructure:"dedupMode"`
+	DedupWindow       time.Duration `mapstructure:"dedupWindow"`
 	S3                S3Config      `mapstructure:"s3"`
+	Secondary         SecondaryStorageConfig `mapstructure:"secondary"`
+}
+
+// EffectiveCompression is the codec stored cores are compressed with.
+func (c *StorageConfig) EffectiveCompression() string {
+	if c.Compression != "" {
+		return c.Compression
+	}
+	if c.CompressionEnabled {
+		return "gzip"
+	}
+	return "none"
+}
+
+// SecondaryStorageConfig adds a second backend, enabled by setting Backend.
+// Mode "replicate" writes every core to both backends; "failover" writes to
+// the secondary only when the primary fails.
+type SecondaryStorageConfig struct {
+	Backend   string   `mapstructure:"backend"`
+	Mode      string   `mapstructure:"mode"`
+	LocalPath string   `mapstructure:"localPath"`
+	S3        S3Config `mapstructure:"s3"`
 }
 
 type S3Config struct {
@@ -78,6 +592,38 @@ type S3Config struct {
 	Endpoint  string `mapstructure:"endpoint"`
 	AccessKey string `mapstructure:"accessKey"`
 	SecretKey string `mapstructure:"secretKey"`
+	// Prefix is prepended to every object key.
+	Prefix    string `mapstructure:"prefix"`
+	// ForcePathStyle addresses the bucket in the path, as MinIO needs.
+	ForcePathStyle bool `mapstructure:"forcePathStyle"`
+	// ServerSideEncryption is "AES256", "aws:kms" or empty for the
+	// bucket default. KMSKeyID selects the key for "aws:kms".
+	ServerSideEncryption string `mapstructure:"serverSideEncryption"`
+	KMSKeyID             string `mapstructure:"kmsKeyId"`
+	// Multipart upload part size in MiB and parts uploaded in parallel.
+	PartSizeMB  int `mapstructure:"partSizeMB"`
+	Concurrency int `mapstructure:"concurrency"`
+	// Provision lets the storage self-test create a missing bucket and
+	// expire stored cores after RetentionDays with a lifecycle rule.
+	Provision bool `mapstructure:"provision"`
+	Proxy     ProxyConfig `mapstructure:"proxy"`
+	TLS       TLSConfig   `mapstructure:"tls"`
+}
+
+func (c *S3Config) validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket cannot be empty")
+	}
+	if c.ServerSideEncryption != "" && c.ServerSideEncryption != "AES256" && c.ServerSideEncryption != "aws:kms" {
+		return fmt.Errorf("unsupported server-side encryption: %s", c.ServerSideEncryption)
+	}
+	if c.KMSKeyID != "" && c.ServerSideEncryption != "aws:kms" {
+		return fmt.Errorf("kmsKeyId requires serverSideEncryption aws:kms")
+	}
+	if c.PartSizeMB != 0 && c.PartSizeMB < 5 {
+		return fmt.Errorf("partSizeMB must be at least 5, got %d", c.PartSizeMB)
+	}
+	return nil
 }
 
 type CleanerConfig struct {
@@ -91,29 +637,205 @@ type CleanerConfig struct {
 type MonitorConfig struct {
 	PrometheusEnabled bool          `mapstructure:"prometheusEnabled"`
 	Alerting          AlertingConfig `mapstructure:"alerting"`
+	NodeConditions    NodeConditionConfig `mapstructure:"nodeConditions"`
+	LifecycleSLA      LifecycleSLAConfig  `mapstructure:"lifecycleSLA"`
+	KubeEvents        KubeEventsConfig    `mapstructure:"kubeEvents"`
+	Tracing           TracingConfig       `mapstructure:"tracing"`
+}
+
+// TracingConfig exports OpenTelemetry spans of each coredump's way through
+// the pipeline over OTLP. Protocol is grpc or http; an empty endpoint
+// falls back to the OTEL_EXPORTER_OTLP_* environment variables, else
+// localhost. SampleRatio is the share of coredumps traced, 0 meaning all.
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Protocol    string  `mapstructure:"protocol"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+}
+
+// KubeEventsConfig posts Kubernetes Events on the pods and namespaces the
+// agent acted on. Each object gets at most Burst events at once, refilled
+// at QPS events per second.
+type KubeEventsConfig struct {
+	Enabled bool    `mapstructure:"enabled"`
+	QPS     float32 `mapstructure:"qps"`
+	Burst   int     `mapstructure:"burst"`
+}
+
+// LifecycleSLAConfig sets how long a coredump may take through each stage
+// of the pipeline before it counts as an SLA breach. Zero leaves a stage
+// without a target.
+type LifecycleSLAConfig struct {
+	CrashToDiscovered    time.Duration `mapstructure:"crashToDiscovered"`
+	DiscoveredToAnalyzed time.Duration `mapstructure:"discoveredToAnalyzed"`
+	AnalyzedToStored     time.Duration `mapstructure:"analyzedToStored"`
+}
+
+// NodeConditionConfig sets a node condition when at least Threshold cores
+// were found on the node within Window.
+type NodeConditionConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	ConditionType string        `mapstructure:"conditionType"`
+	Window        time.Duration `mapstructure:"window"`
+	Threshold     int           `mapstructure:"threshold"`
+	ResyncPeriod  time.Duration `mapstructure:"resyncPeriod"`
+}
+
+// ServerConfig sets up the agent's HTTP listeners. Empty addresses fall
+// back to the --health-addr, --metrics-addr and --api-addr flags; components
+// given the same address are served on one port.
+type ServerConfig struct {
+	HealthAddr  string          `mapstructure:"healthAddr"`
+	MetricsAddr string          `mapstructure:"metricsAddr"`
+	APIAddr     string          `mapstructure:"apiAddr"`
+	TLS         ServerTLSConfig `mapstructure:"tls"`
+	// TokenFile holds a bearer token required by the metrics and query API
+	// endpoints. Health endpoints stay open for the kubelet's probes, and
+	// the node and embed APIs check their own tokens.
+	TokenFile string `mapstructure:"tokenFile"`
+}
+
+// ServerTLSConfig serves every listener over TLS when both files are set.
+type ServerTLSConfig struct {
+	CertFile string `mapstructure:"certFile"`
+	KeyFile  string `mapstructure:"keyFile"`
+}
+
+type APIConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	MaxRecords int           `mapstructure:"maxRecords"`
+	// StatsCacheTTL bounds how long aggregate stats are served from cache
+	// when no new record arrives.
+	StatsCacheTTL time.Duration `mapstructure:"statsCacheTTL"`
+	Node          NodeAPIConfig `mapstructure:"node"`
+	Embed         EmbedAPIConfig `mapstructure:"embed"`
+}
+
+// EmbedAPIConfig configures the widgets other portals embed. TokensFile
+// lists one token per line with the widgets it may read.
+type EmbedAPIConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	TokensFile     string   `mapstructure:"tokensFile"`
+	AllowedOrigins []string `mapstructure:"allowedOrigins"`
+}
+
+// NodeAPIConfig configures the token-protected metadata API for node-local
+// tools. RateLimit is in requests per second across all callers.
+type NodeAPIConfig struct {
+	Enabled   bool    `mapstructure:"enabled"`
+	TokenFile string  `mapstructure:"tokenFile"`
+	RateLimit float64 `mapstructure:"rateLimit"`
+	Burst     int     `mapstructure:"burst"`
 }
 
 type AlertingConfig struct {
-	Enabled    bool   `mapstructure:"enabled"`
+	Enabled bool `mapstructure:"enabled"`
+	// WebhookURL receives every alert as JSON, as a channel of type
+	// webhook without a route would.
 	WebhookURL string `mapstructure:"webhookUrl"`
+	// Channels deliver the alerts their route matches to Slack, Feishu,
+	// PagerDuty or a webhook.
+	Channels []AlertChannelConfig `mapstructure:"channels"`
+	// Rules deduplicate, escalate and override the severity of the alerts
+	// they match; more can be added through the API.
+	Rules []AlertRuleConfig `mapstructure:"rules"`
+	// Crashes of the same instance and crash site within the window are
+	// sent as one notification. GroupWindows overrides it per severity.
+	GroupWindow  time.Duration            `mapstructure:"groupWindow"`
+	GroupWindows map[string]time.Duration `mapstructure:"groupWindows"`
+	Proxy        ProxyConfig              `mapstructure:"proxy"`
+	TLS          TLSConfig                `mapstructure:"tls"`
+}
+
+// AlertRuleConfig matches alerts by kind, namespace, instance pattern,
+// value score and restart count. Alerts of a rule and instance within
+// DedupWindow of the last one sent are dropped unless they escalate; the
+// escalation levels count the alerts within Window.
+type AlertRuleConfig struct {
+	Name            string                  `mapstructure:"name"`
+	Kinds           []string                `mapstructure:"kinds"`
+	Namespaces      []string                `mapstructure:"namespaces"`
+	Instances       []string                `mapstructure:"instances"`
+	MinScore        float64                 `mapstructure:"minScore"`
+	MinRestartCount int                     `mapstructure:"minRestartCount"`
+	Severity        string                  `mapstructure:"severity"`
+	DedupWindow     time.Duration           `mapstructure:"dedupWindow"`
+	Window          time.Duration           `mapstructure:"window"`
+	Escalation      []AlertEscalationConfig `mapstructure:"escalation"`
+}
+
+// AlertEscalationConfig raises the severity once a rule has matched
+// Occurrences alerts within its window.
+type AlertEscalationConfig struct {
+	Occurrences int    `mapstructure:"occurrences"`
+	Severity    string `mapstructure:"severity"`
+}
+
+// AlertChannelConfig is one destination of alerts. URL is the webhook,
+// the Slack incoming webhook or the Feishu bot webhook; for PagerDuty it
+// overrides the Events API v2 endpoint, such as for the EU region.
+type AlertChannelConfig struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	URL  string `mapstructure:"url"`
+	// Secret signs Feishu bot requests when the bot checks signatures.
+	Secret string `mapstructure:"secret"`
+	// RoutingKey is the integration key of a PagerDuty service.
+	RoutingKey string           `mapstructure:"routingKey"`
+	Route      AlertRouteConfig `mapstructure:"route"`
+}
+
+// AlertRouteConfig selects the alerts a channel receives; an empty list
+// matches everything. Kinds are crash, cleanup and ai_budget. MinScore
+// only applies to crashes.
+type AlertRouteConfig struct {
+	Kinds      []string `mapstructure:"kinds"`
+	Severities []string `mapstructure:"severities"`
+	Namespaces []string `mapstructure:"namespaces"`
+	MinScore   float64  `mapstructure:"minScore"`
 }
 
 func Load(configPath string) (*Config, error) {
-	viper.SetConfigFile(configPath)
-	viper.SetConfigType("yaml")
+	// A viper of its own, as the file may be reloaded while the agent runs.
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	config.applyProxyDefaults()
+
 	return &config, nil
 }
 
+// applyProxyDefaults copies the global proxy into every integration that
+// doesn't configure its own.
+func (c *Config) applyProxyDefaults() {
+	for _, proxy := range []*ProxyConfig{
+		&c.Analyzer.AIAnalysis.Proxy,
+		&c.Analyzer.PreCrashLogs.Proxy,
+		&c.Analyzer.PreCrashMetrics.Proxy,
+		&c.Analyzer.Similarity.Embedding.Proxy,
+		&c.Analyzer.Similarity.Milvus.Proxy,
+		&c.Analyzer.GitHubIssues.Proxy,
+		&c.Storage.S3.Proxy,
+		&c.Monitor.Alerting.Proxy,
+	} {
+		if !proxy.isSet() {
+			*proxy = c.Proxy
+		}
+	}
+}
+
 func (c *Config) Validate() error {
 	if c.Agent.Name == "" {
 		return fmt.Errorf("agent name cannot be empty")
@@ -127,9 +849,246 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("coredump path cannot be empty")
 	}
 	
-	if c.Storage.Backend != "local" && c.Storage.Backend != "s3" && c.Storage.Backend != "nfs" {
+	if c.Collector.WatchMode != "" && c.Collector.WatchMode != "inotify" && c.Collector.WatchMode != "poll" {
+		return fmt.Errorf("unsupported collector watch mode: %s", c.Collector.WatchMode)
+	}
+	
+	if c.Collector.CRI.Enabled && c.Collector.CRI.Endpoint == "" {
+		return fmt.Errorf("collector cri endpoint is required when enabled")
+	}
+	
+	if c.Storage.Backend != "local" && c.Storage.Backend != "s3" && c.Storage.Backend != "nfs" && c.Storage.Backend != "memory" {
 		return fmt.Errorf("unsupported storage backend: %s", c.Storage.Backend)
 	}
 	
+	maxLevel := map[string]int{"gzip": 9, "zstd": 22, "lz4": 9, "none": 0}
+	if max, supported := maxLevel[c.Storage.EffectiveCompression()]; !supported {
+		return fmt.Errorf("unsupported storage compression: %s", c.Storage.Compression)
+	} else if c.Storage.CompressionLevel < 0 || c.Storage.CompressionLevel > max {
+		return fmt.Errorf("storage compression level for %s must be between 0 and %d", c.Storage.EffectiveCompression(), max)
+	}
+	
+	if c.Storage.Backend == "s3" {
+		if err := c.Storage.S3.validate(); err != nil {
+			return fmt.Errorf("invalid s3 storage config: %w", err)
+		}
+	}
+	
+	if c.Agent.Preflight.FailurePolicy != "" && c.Agent.Preflight.FailurePolicy != "degrade" && c.Agent.Preflight.FailurePolicy != "failFast" {
+		return fmt.Errorf("unsupported preflight failure policy: %s", c.Agent.Preflight.FailurePolicy)
+	}
+	
+	if secondary := c.Storage.Secondary; secondary.Backend != "" {
+		if secondary.Backend != "local" && secondary.Backend != "s3" && secondary.Backend != "nfs" && secondary.Backend != "memory" {
+			return fmt.Errorf("unsupported secondary storage backend: %s", secondary.Backend)
+		}
+		if secondary.Mode != "replicate" && secondary.Mode != "failover" {
+			return fmt.Errorf("unsupported secondary storage mode: %s", secondary.Mode)
+		}
+		if secondary.Backend == "s3" {
+			if err := secondary.S3.validate(); err != nil {
+				return fmt.Errorf("invalid secondary s3 storage config: %w", err)
+			}
+		}
+	}
+	
+	if _, err := labels.Parse(c.Discovery.LabelSelector); err != nil {
+		return fmt.Errorf("invalid discovery label selector: %w", err)
+	}
+	
+	for _, instance := range c.Discovery.Instances {
+		if instance.Name == "" || instance.Namespace == "" || instance.Selector == "" {
+			return fmt.Errorf("registered instances need a name, namespace and selector")
+		}
+		if _, err := labels.Parse(instance.Selector); err != nil {
+			return fmt.Errorf("invalid selector for registered instance %s/%s: %w", instance.Namespace, instance.Name, err)
+		}
+	}
+	
+	for _, rule := range c.Discovery.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("discovery rules need a name")
+		}
+		if rule.Selector == "" && rule.NamePattern == "" {
+			return fmt.Errorf("discovery rule %s needs a selector or a name pattern", rule.Name)
+		}
+		if _, err := labels.Parse(rule.Selector); err != nil {
+			return fmt.Errorf("invalid selector for discovery rule %s: %w", rule.Name, err)
+		}
+		if _, err := regexp.Compile(rule.NamePattern); err != nil {
+			return fmt.Errorf("invalid name pattern for discovery rule %s: %w", rule.Name, err)
+		}
+	}
+	
+	thresholds := c.Analyzer.EffectiveThresholds()
+	if thresholds.Store < 0 || thresholds.Store > 10 {
+		return fmt.Errorf("store threshold must be between 0 and 10: %v", thresholds.Store)
+	}
+	if thresholds.Critical < 0 || thresholds.Critical > 10 {
+		return fmt.Errorf("critical threshold must be between 0 and 10: %v", thresholds.Critical)
+	}
+	
+	if ai := c.Analyzer.AIAnalysis; ai.Enabled {
+		switch ai.Provider {
+		case "", "glm", "openai", "openai-compatible", "ollama", "anthropic", "fake":
+		default:
+			return fmt.Errorf("unsupported AI provider: %s", ai.Provider)
+		}
+		for _, pattern := range ai.Redaction.Patterns {
+			if _, err := regexp.Compile(pattern.Pattern); err != nil {
+				return fmt.Errorf("invalid redaction pattern %s: %w", pattern.Name, err)
+			}
+		}
+		quotas := make(map[string]bool)
+		for _, quota := range ai.Quotas {
+			if quota.Name == "" || quotas[quota.Name] {
+				return fmt.Errorf("AI quotas need unique names: %q", quota.Name)
+			}
+			quotas[quota.Name] = true
+			if len(quota.Namespaces) == 0 && len(quota.Labels) == 0 {
+				return fmt.Errorf("AI quota %s needs namespaces or labels", quota.Name)
+			}
+			if quota.MaxCostPerMonth <= 0 {
+				return fmt.Errorf("AI quota %s needs a positive maxCostPerMonth", quota.Name)
+			}
+		}
+	}
+	
+	if retry := c.Analyzer.Retry; retry.MaxAttempts < 0 || retry.InitialBackoff < 0 || retry.MaxBackoff < 0 {
+		return fmt.Errorf("analysis retry settings must not be negative")
+	}
+	
+	if c.Analyzer.MaxConcurrentAnalyses < 0 || c.Analyzer.AnalysisQueueSize < 0 || c.Analyzer.DrainTimeout < 0 {
+		return fmt.Errorf("analysis worker pool settings must not be negative")
+	}
+	
+	if sandbox := c.Analyzer.Sandbox; sandbox.Enabled {
+		if sandbox.Image == "" {
+			return fmt.Errorf("the gdb sandbox requires an image")
+		}
+		if len(sandbox.Mounts) == 0 {
+			return fmt.Errorf("the gdb sandbox requires at least one mount")
+		}
+		for _, mount := range sandbox.Mounts {
+			if !strings.HasPrefix(mount.Path, "/") || !strings.HasPrefix(mount.HostPath, "/") {
+				return fmt.Errorf("gdb sandbox mounts need absolute paths: %q, %q", mount.Path, mount.HostPath)
+			}
+		}
+	}
+	
+	if similarity := c.Analyzer.Similarity; similarity.Enabled {
+		if similarity.Embedding.URL == "" || similarity.Milvus.Address == "" {
+			return fmt.Errorf("similarity search requires an embedding URL and a Milvus address")
+		}
+		if similarity.Frames < 0 || similarity.TopK < 0 || similarity.MinScore < 0 || similarity.MinScore > 1 {
+			return fmt.Errorf("similarity search needs non-negative frames and topK and a minScore between 0 and 1")
+		}
+	}
+	
+	if issues := c.Analyzer.GitHubIssues; issues.Enabled {
+		if len(issues.Repos) == 0 {
+			return fmt.Errorf("the GitHub issue matcher requires at least one repository")
+		}
+		for _, repo := range issues.Repos {
+			if owner, name, found := strings.Cut(repo, "/"); !found || owner == "" || name == "" || strings.Contains(name, "/") {
+				return fmt.Errorf("GitHub repositories must be given as owner/name: %q", repo)
+			}
+		}
+	}
+	
+	if c.Analyzer.PreCrashLogs.Enabled && c.Analyzer.PreCrashLogs.LokiURL == "" {
+		return fmt.Errorf("pre-crash logs require a Loki URL")
+	}
+	
+	if metrics := c.Analyzer.PreCrashMetrics; metrics.Enabled {
+		if metrics.PrometheusURL == "" {
+			return fmt.Errorf("pre-crash metrics require a Prometheus URL")
+		}
+		if len(metrics.Queries) == 0 {
+			return fmt.Errorf("pre-crash metrics require at least one query")
+		}
+		for _, query := range metrics.Queries {
+			if query.Name == "" || query.Query == "" {
+				return fmt.Errorf("pre-crash metric queries need a name and a query")
+			}
+		}
+	}
+	
+	if c.Storage.DedupMode != "" && c.Storage.DedupMode != "off" && c.Storage.DedupMode != "metadata" && c.Storage.DedupMode != "content" {
+		return fmt.Errorf("unsupported storage dedup mode: %s", c.Storage.DedupMode)
+	}
+	
+	for _, channel := range c.Monitor.Alerting.Channels {
+		if channel.Name == "" {
+			return fmt.Errorf("alert channels need a name")
+		}
+		switch channel.Type {
+		case "webhook", "slack", "feishu":
+			if channel.URL == "" {
+				return fmt.Errorf("%s alert channel %s requires a url", channel.Type, channel.Name)
+			}
+		case "pagerduty":
+			if channel.RoutingKey == "" {
+				return fmt.Errorf("pagerduty alert channel %s requires a routing key", channel.Name)
+			}
+		default:
+			return fmt.Errorf("unsupported type for alert channel %s: %s", channel.Name, channel.Type)
+		}
+		for _, kind := range channel.Route.Kinds {
+			if kind != "crash" && kind != "cleanup" && kind != "ai_budget" {
+				return fmt.Errorf("unsupported alert kind in the route of channel %s: %s", channel.Name, kind)
+			}
+		}
+	}
+	
+	if sla := c.Monitor.LifecycleSLA; sla.CrashToDiscovered < 0 || sla.DiscoveredToAnalyzed < 0 || sla.AnalyzedToStored < 0 {
+		return fmt.Errorf("lifecycle SLA targets must not be negative")
+	}
+	
+	if tracing := c.Monitor.Tracing; tracing.Enabled {
+		if tracing.Protocol != "" && tracing.Protocol != "grpc" && tracing.Protocol != "http" {
+			return fmt.Errorf("unsupported tracing protocol: %s", tracing.Protocol)
+		}
+		if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+			return fmt.Errorf("tracing sample ratio must be between 0 and 1")
+		}
+	}
+	
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server TLS requires both a certificate and a key file")
+	}
+	
+	if c.API.Node.Enabled && c.API.Node.TokenFile == "" {
+		return fmt.Errorf("node API requires a token file")
+	}
+	
+	if c.API.Embed.Enabled && c.API.Embed.TokensFile == "" {
+		return fmt.Errorf("embed API requires a tokens file")
+	}
+	
 	return nil
-}
\ No newline at end of file
+}
+
+// ParseSize parses sizes such as "50GB", "512MB" or "64KB". Sizes that
+// don't parse are returned as fallback.
+func ParseSize(sizeStr string, fallback int64) int64 {
+	sizeStr = strings.ToUpper(strings.TrimSpace(sizeStr))
+	
+	var multiplier int64 = 1
+	if strings.HasSuffix(sizeStr, "GB") {
+		multiplier = 1024 * 1024 * 1024
+		sizeStr = strings.TrimSuffix(sizeStr, "GB")
+	} else if strings.HasSuffix(sizeStr, "MB") {
+		multiplier = 1024 * 1024
+		sizeStr = strings.TrimSuffix(sizeStr, "MB")
+	} else if strings.HasSuffix(sizeStr, "KB") {
+		multiplier = 1024
+		sizeStr = strings.TrimSuffix(sizeStr, "KB")
+	}
+
+	var size int64
+	if _, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil {
+		return fallback
+	}
+	return size * multiplier
+}
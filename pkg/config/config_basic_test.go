@@ -41,6 +41,13 @@ storage:
   backend: "local"
   localPath: "/tmp/coredumps"
   retentionDays: 7
+
+monitor:
+  alerting:
+    enabled: true
+    groupWindow: "5m"
+    groupWindows:
+      critical: "1m"
 `
 
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -103,6 +110,15 @@ storage:
 	if config.Storage.RetentionDays != 7 {
 		t.Errorf("Expected retention days 7, got %d", config.Storage.RetentionDays)
 	}
+
+	// Alerting validation
+	if config.Monitor.Alerting.GroupWindow != 5*time.Minute {
+		t.Errorf("Expected alert group window 5m, got %v", config.Monitor.Alerting.GroupWindow)
+	}
+
+	if config.Monitor.Alerting.GroupWindows["critical"] != time.Minute {
+		t.Errorf("Expected critical alert group window 1m, got %v", config.Monitor.Alerting.GroupWindows["critical"])
+	}
 }
 
 func TestBasicConfigValidation(t *testing.T) {
@@ -235,4 +251,52 @@ func TestBasicDurationParsing(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestProxyDefaults(t *testing.T) {
+	config := &Config{
+		Proxy: ProxyConfig{URL: "http://proxy.internal:3128"},
+	}
+	config.Analyzer.AIAnalysis.Proxy = ProxyConfig{URL: "http://ai-egress.internal:3128"}
+	config.Storage.S3.Proxy = ProxyConfig{Direct: true}
+
+	config.applyProxyDefaults()
+
+	if config.Analyzer.AIAnalysis.Proxy.URL != "http://ai-egress.internal:3128" {
+		t.Errorf("Expected AI proxy override to be kept, got %q", config.Analyzer.AIAnalysis.Proxy.URL)
+	}
+	if config.Storage.S3.Proxy.URL != "" {
+		t.Errorf("Expected S3 to bypass the global proxy, got %q", config.Storage.S3.Proxy.URL)
+	}
+	if config.Monitor.Alerting.Proxy.URL != "http://proxy.internal:3128" {
+		t.Errorf("Expected webhook to inherit the global proxy, got %q", config.Monitor.Alerting.Proxy.URL)
+	}
+}
+
+func TestEffectiveThresholds(t *testing.T) {
+	legacy := &AnalyzerConfig{ValueThreshold: 6.5}
+	if got := legacy.EffectiveThresholds(); got != (ScoreThresholds{Store: 6.5, Critical: DefaultCriticalThreshold}) {
+		t.Errorf("Expected the store threshold from valueThreshold, got %+v", got)
+	}
+
+	explicit := &AnalyzerConfig{ValueThreshold: 6.5, Thresholds: ScoreThresholds{Store: 5, Critical: 9}}
+	if got := explicit.EffectiveThresholds(); got != (ScoreThresholds{Store: 5, Critical: 9}) {
+		t.Errorf("Expected the configured thresholds, got %+v", got)
+	}
+}
+
+func TestEffectiveCompression(t *testing.T) {
+	for _, tc := range []struct {
+		config StorageConfig
+		want   string
+	}{
+		{StorageConfig{CompressionEnabled: true}, "gzip"},
+		{StorageConfig{}, "none"},
+		{StorageConfig{CompressionEnabled: true, Compression: "zstd"}, "zstd"},
+		{StorageConfig{Compression: "lz4"}, "lz4"},
+	} {
+		if got := tc.config.EffectiveCompression(); got != tc.want {
+			t.Errorf("Expected %s for %+v, got %s", tc.want, tc.config, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package corepipe is the protocol between core-handler, which the kernel
+// pipes cores to through core_pattern, and the agent's collector. The
+// handler connects to the agent's unix socket, sends a Header as a line of
+// JSON followed by the core, closes its side for writing and reads the
+// agent's reply line: "ok", or "error: " and the reason.
+//
+// The package depends on the standard library only, so the handler the
+// kernel starts for every crash stays small.
+package corepipe
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultSocket is where the agent listens unless configured otherwise.
+const DefaultSocket = "/run/milvus-coredump-agent/core.sock"
+
+// ErrNoReply is returned by ReadReply when the agent closed the connection
+// without replying.
+var ErrNoReply = errors.New("no reply from the agent")
+
+// maxHeaderSize bounds the header line the agent reads.
+const maxHeaderSize = 4096
+
+// Header describes the crashed process, from the core_pattern specifiers.
+type Header struct {
+	// %e, the executable's name
+	Executable string `json:"executable"`
+	// %p, in the initial PID namespace
+	PID int `json:"pid"`
+	// %u and %g, -1 when not passed
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+	// %s
+	Signal int `json:"signal"`
+	// %t, seconds since the epoch
+	Time int64 `json:"time"`
+	// %h
+	Hostname string `json:"hostname,omitempty"`
+	// /proc/<pid>/cgroup, read while the process is still there
+	Cgroup string `json:"cgroup,omitempty"`
+}
+
+// WriteHeader sends the header line.
+func WriteHeader(w io.Writer, header Header) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ReadHeader reads the header line; the core follows in r.
+func ReadHeader(r *bufio.Reader) (Header, error) {
+	var header Header
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxHeaderSize {
+		return header, fmt.Errorf("header longer than %d bytes", maxHeaderSize)
+	}
+	if err != nil {
+		return header, fmt.Errorf("failed to read header: %w", err)
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, fmt.Errorf("failed to decode header: %w", err)
+	}
+	if header.Executable == "" || strings.ContainsAny(header.Executable, "/\x00") || header.PID <= 0 {
+		return header, fmt.Errorf("invalid header: executable %q, pid %d", header.Executable, header.PID)
+	}
+	return header, nil
+}
+
+// WriteReply answers the handler: "ok" when err is nil.
+func WriteReply(w io.Writer, err error) error {
+	reply := "ok\n"
+	if err != nil {
+		reply = "error: " + strings.ReplaceAll(err.Error(), "\n", " ") + "\n"
+	}
+	_, writeErr := io.WriteString(w, reply)
+	return writeErr
+}
+
+// ReadReply returns the agent's error, or nil when it took the core.
+func ReadReply(r io.Reader) error {
+	line, err := bufio.NewReader(io.LimitReader(r, maxHeaderSize)).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("%w: %w", ErrNoReply, err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "ok" {
+		return nil
+	}
+	return errors.New(strings.TrimPrefix(line, "error: "))
+}
@@ -0,0 +1,45 @@
+package corepipe
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	sent := Header{Executable: "milvus", PID: 4242, UID: 1000, GID: 1000, Signal: 11, Time: 1714521600, Hostname: "node-1"}
+	if err := WriteHeader(&buf, sent); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("ELF core")
+
+	reader := bufio.NewReader(&buf)
+	received, err := ReadHeader(reader)
+	if err != nil || received != sent {
+		t.Fatalf("expected %+v, got %+v, %v", sent, received, err)
+	}
+	if rest, _ := reader.ReadString(0); rest != "ELF core" {
+		t.Errorf("expected the core to follow the header, got %q", rest)
+	}
+
+	for _, header := range []string{`{"executable":"../milvus","pid":1}`, `{"executable":"milvus"}`, strings.Repeat("x", 5000)} {
+		if _, err := ReadHeader(bufio.NewReader(strings.NewReader(header + "\n"))); err == nil {
+			t.Errorf("expected header %.40q to be refused", header)
+		}
+	}
+}
+
+func TestReply(t *testing.T) {
+	var buf bytes.Buffer
+	WriteReply(&buf, nil)
+	if err := ReadReply(&buf); err != nil {
+		t.Errorf("expected ok, got %v", err)
+	}
+	WriteReply(&buf, errors.New("core exceeds maxFileSize"))
+	if err := ReadReply(&buf); err == nil || err.Error() != "core exceeds maxFileSize" {
+		t.Errorf("expected the agent's error, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package crashgroup fingerprints crash sites and groups the cores that
+// share one, so repeated crashes of a crash loop are analyzed once and shown
+// as a single group with an occurrence count.
+package crashgroup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	// DefaultFrames is the number of top stack frames that identify a crash site.
+	DefaultFrames = 5
+
+	defaultMaxGroups = 1000
+)
+
+var (
+	framePattern = regexp.MustCompile(`^#\d+\s+(?:0x[0-9a-fA-F]+\s+in\s+)?(.+?)\s*\(`)
+	// delveFramePattern matches the Go stacks delve prints for Go cores.
+	delveFramePattern = regexp.MustCompile(`^\d+\s+0x[0-9a-fA-F]+\s+in\s+(\S+)$`)
+)
+
+// Fingerprint derives a stable identifier for a crash site from the
+// executable, signal and the function names of the top stack frames, and
+// returns it with those frames. Addresses and arguments are ignored so that
+// cores from the same crash loop hash to the same value. A stack trace
+// without parsable frames has no fingerprint.
+func Fingerprint(executable string, signal int, stackTrace string, frames int) (string, []string) {
+	if frames <= 0 {
+		frames = DefaultFrames
+	}
+	top := topFrames(stackTrace, frames)
+	if len(top) == 0 {
+		return "", nil
+	}
+
+	key := fmt.Sprintf("%s|%d|%s", executable, signal, strings.Join(top, "|"))
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]), top
+}
+
+func topFrames(stackTrace string, limit int) []string {
+	var frames []string
+	for _, line := range strings.Split(stackTrace, "\n") {
+		line = strings.TrimSpace(line)
+		matches := framePattern.FindStringSubmatch(line)
+		if len(matches) < 2 {
+			// The Go runtime's panic and signal frames top every Go
+			// stack; the crash site is the first frame below them.
+			matches = delveFramePattern.FindStringSubmatch(line)
+			if len(matches) < 2 || strings.HasPrefix(matches[1], "runtime.") {
+				continue
+			}
+		}
+		frames = append(frames, matches[1])
+		if len(frames) >= limit {
+			break
+		}
+	}
+	return frames
+}
+
+// Group is the set of cores sharing a fingerprint.
+type Group struct {
+	Fingerprint     string    `json:"fingerprint"`
+	Executable      string    `json:"executable"`
+	Signal          int       `json:"signal"`
+	CrashReason     string    `json:"crashReason,omitempty"`
+	Frames          []string  `json:"frames"`
+	Occurrences     int       `json:"occurrences"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+	FirstCoredumpID string    `json:"firstCoredumpId"`
+	LastCoredumpID  string    `json:"lastCoredumpId"`
+	// Instances are the namespace/instance keys the crash was seen in.
+	Instances []string `json:"instances"`
+	// AnalyzedBy is the core whose AI analysis the group's later cores
+	// reference; empty until one succeeded.
+	AnalyzedBy string `json:"analyzedBy,omitempty"`
+
+	analysis *collector.AIAnalysisResult
+}
+
+// Registry holds the crash groups. When full, the group seen least recently
+// is dropped. A nil *Registry groups nothing.
+type Registry struct {
+	maxGroups int
+
+	mu     sync.RWMutex
+	groups map[string]*Group
+}
+
+func New(maxGroups int) *Registry {
+	if maxGroups <= 0 {
+		maxGroups = defaultMaxGroups
+	}
+	return &Registry{
+		maxGroups: maxGroups,
+		groups:    make(map[string]*Group),
+	}
+}
+
+// Observe counts the core in the group of its fingerprint, creating the
+// group for a new crash site. It returns a copy of the group and whether the
+// core is a duplicate of an earlier one.
+func (r *Registry) Observe(coredump *collector.CoredumpFile, frames []string, now time.Time) (Group, bool) {
+	if r == nil || coredump.Fingerprint == "" {
+		return Group{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	group, duplicate := r.groups[coredump.Fingerprint]
+	if !duplicate {
+		if len(r.groups) >= r.maxGroups {
+			r.evictOldest()
+		}
+		group = &Group{
+			Fingerprint:     coredump.Fingerprint,
+			Executable:      coredump.Executable,
+			Signal:          coredump.Signal,
+			Frames:          frames,
+			FirstSeen:       now,
+			FirstCoredumpID: coredump.ID,
+		}
+		if coredump.AnalysisResults != nil {
+			group.CrashReason = coredump.AnalysisResults.CrashReason
+		}
+		r.groups[coredump.Fingerprint] = group
+	}
+
+	group.Occurrences++
+	group.LastSeen = now
+	group.LastCoredumpID = coredump.ID
+	if coredump.InstanceName != "" {
+		key := coredump.PodNamespace + "/" + coredump.InstanceName
+		index := sort.SearchStrings(group.Instances, key)
+		if index == len(group.Instances) || group.Instances[index] != key {
+			group.Instances = append(group.Instances, "")
+			copy(group.Instances[index+1:], group.Instances[index:])
+			group.Instances[index] = key
+		}
+	}
+	return group.copy(), duplicate
+}
+
+// AIAnalysis returns the AI analysis recorded for the fingerprint and the
+// core it was made for.
+func (r *Registry) AIAnalysis(fingerprint string) (*collector.AIAnalysisResult, string, bool) {
+	if r == nil {
+		return nil, "", false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	group, exists := r.groups[fingerprint]
+	if !exists || group.analysis == nil {
+		return nil, "", false
+	}
+	analysis := *group.analysis
+	return &analysis, group.AnalyzedBy, true
+}
+
+// RecordAIAnalysis keeps the first successful AI analysis of a group for
+// its later cores.
+func (r *Registry) RecordAIAnalysis(fingerprint, coredumpID string, analysis *collector.AIAnalysisResult) {
+	if r == nil || analysis == nil || analysis.ErrorMessage != "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	group, exists := r.groups[fingerprint]
+	if !exists || group.analysis != nil {
+		return
+	}
+	recorded := *analysis
+	group.analysis = &recorded
+	group.AnalyzedBy = coredumpID
+}
+
+// Get returns a copy of the group with the fingerprint.
+func (r *Registry) Get(fingerprint string) (Group, bool) {
+	if r == nil {
+		return Group{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	group, exists := r.groups[fingerprint]
+	if !exists {
+		return Group{}, false
+	}
+	return group.copy(), true
+}
+
+// Groups returns copies of all groups, most occurrences first.
+func (r *Registry) Groups() []Group {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	groups := make([]Group, 0, len(r.groups))
+	for _, group := range r.groups {
+		groups = append(groups, group.copy())
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Occurrences != groups[j].Occurrences {
+			return groups[i].Occurrences > groups[j].Occurrences
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+	return groups
+}
+
+func (r *Registry) evictOldest() {
+	var oldest *Group
+	for _, group := range r.groups {
+		if oldest == nil || group.LastSeen.Before(oldest.LastSeen) {
+			oldest = group
+		}
+	}
+	if oldest != nil {
+		delete(r.groups, oldest.Fingerprint)
+	}
+}
+
+func (g *Group) copy() Group {
+	result := *g
+	result.Frames = append([]string(nil), g.Frames...)
+	result.Instances = append([]string{}, g.Instances...)
+	result.analysis = nil
+	return result
+}
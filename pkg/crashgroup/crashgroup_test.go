@@ -0,0 +1,180 @@
+package crashgroup
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+func TestTopFrames(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		expected []string
+	}{
+		{
+			fixture:  "sigsegv_backtrace.txt",
+			expected: []string{"crash_function", "main"},
+		},
+		{
+			fixture: "milvus_segcore_sigsegv.txt",
+			expected: []string{
+				"milvus::segcore::SegmentSealedImpl::bulk_subscript",
+				"milvus::segcore::SegmentInternalInterface::FillTargetEntry",
+				"FillTargetEntry",
+				"_cgo_5b9a5b3cc6c3_Cfunc_FillTargetEntry",
+				"runtime.asmcgocall",
+			},
+		},
+		{
+			fixture: "milvus_assert_sigabrt.txt",
+			expected: []string{
+				"__GI_raise",
+				"__GI_abort",
+				"__assert_fail_base",
+				"__GI___assert_fail",
+				"milvus::segcore::ConcurrentVectorImpl<float, false>::get_element",
+			},
+		},
+		{
+			fixture: "truncated_output.txt",
+			expected: []string{
+				"milvus::storage::ChunkCache::Read",
+				"milvus::segcore::SegmentSealedImpl::LoadFieldData",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			frames := topFrames(testutil.LoadTestGDBOutput(t, tt.fixture), DefaultFrames)
+			if len(frames) != len(tt.expected) {
+				t.Fatalf("expected %d frames, got %d: %v", len(tt.expected), len(frames), frames)
+			}
+			for i := range frames {
+				if frames[i] != tt.expected[i] {
+					t.Errorf("frame %d: expected %q, got %q", i, tt.expected[i], frames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	segv := testutil.LoadTestGDBOutput(t, "milvus_segcore_sigsegv.txt")
+	abrt := testutil.LoadTestGDBOutput(t, "milvus_assert_sigabrt.txt")
+
+	// The same crash site in another process has different addresses.
+	relocated := regexp.MustCompile(`0x[0-9a-f]+`).ReplaceAllString(segv, "0xdeadbeef")
+
+	base, frames := Fingerprint("milvus", 11, segv, 0)
+	if base == "" || len(frames) != DefaultFrames {
+		t.Fatalf("expected a fingerprint over %d frames, got %q %v", DefaultFrames, base, frames)
+	}
+
+	if fp, _ := Fingerprint("milvus", 11, relocated, 0); fp != base {
+		t.Errorf("expected addresses to be ignored, got %s vs %s", fp, base)
+	}
+	if fp, _ := Fingerprint("milvus", 6, segv, 0); fp == base {
+		t.Error("expected a different signal to change the fingerprint")
+	}
+	if fp, _ := Fingerprint("milvus", 11, abrt, 0); fp == base {
+		t.Error("expected a different crash site to change the fingerprint")
+	}
+	if fp, _ := Fingerprint("milvus", 11, segv, 2); fp == base {
+		t.Error("expected fewer frames to change the fingerprint")
+	}
+	if fp, _ := Fingerprint("milvus", 11, "no frames here", 0); fp != "" {
+		t.Errorf("expected empty fingerprint without frames, got %s", fp)
+	}
+}
+
+func TestFingerprintSkipsGoRuntimeFrames(t *testing.T) {
+	_, frames := Fingerprint("milvus", 6, testutil.LoadTestDelveOutput(t, "go_panic.txt"), 2)
+	expected := []string{
+		"github.com/milvus-io/milvus/internal/proxy.(*queryTask).PostExecute",
+		"github.com/milvus-io/milvus/internal/proxy.(*taskScheduler).processTask",
+	}
+	if len(frames) != 2 || frames[0] != expected[0] || frames[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, frames)
+	}
+}
+
+func TestRegistryGroupsDuplicates(t *testing.T) {
+	registry := New(10)
+	start := time.Now()
+
+	newCore := func(id, instance string) *collector.CoredumpFile {
+		return &collector.CoredumpFile{
+			ID:              id,
+			Executable:      "milvus",
+			Signal:          11,
+			PodNamespace:    "milvus",
+			InstanceName:    instance,
+			Fingerprint:     "abc",
+			AnalysisResults: &collector.AnalysisResults{CrashReason: "Segmentation fault"},
+		}
+	}
+
+	if _, duplicate := registry.Observe(newCore("core-1", "b"), []string{"f"}, start); duplicate {
+		t.Fatal("first core of a crash site must not be a duplicate")
+	}
+	if _, _, exists := registry.AIAnalysis("abc"); exists {
+		t.Fatal("expected no AI analysis before one is recorded")
+	}
+	registry.RecordAIAnalysis("abc", "core-1", &collector.AIAnalysisResult{Summary: "null pointer"})
+
+	group, duplicate := registry.Observe(newCore("core-2", "a"), []string{"f"}, start.Add(time.Minute))
+	if !duplicate {
+		t.Fatal("expected the second core to be a duplicate")
+	}
+	if group.Occurrences != 2 || group.FirstCoredumpID != "core-1" || group.LastCoredumpID != "core-2" {
+		t.Errorf("unexpected group: %+v", group)
+	}
+	if len(group.Instances) != 2 || group.Instances[0] != "milvus/a" {
+		t.Errorf("expected sorted instances, got %v", group.Instances)
+	}
+	if group.CrashReason != "Segmentation fault" || group.AnalyzedBy != "core-1" {
+		t.Errorf("unexpected group: %+v", group)
+	}
+
+	analysis, analyzedBy, exists := registry.AIAnalysis("abc")
+	if !exists || analysis.Summary != "null pointer" || analyzedBy != "core-1" {
+		t.Errorf("expected the first analysis to be kept, got %+v from %s", analysis, analyzedBy)
+	}
+	registry.RecordAIAnalysis("abc", "core-2", &collector.AIAnalysisResult{Summary: "other"})
+	if analysis, _, _ := registry.AIAnalysis("abc"); analysis.Summary != "null pointer" {
+		t.Errorf("expected the first analysis to be kept, got %q", analysis.Summary)
+	}
+}
+
+func TestRegistryEvictsLeastRecentlySeen(t *testing.T) {
+	registry := New(2)
+	start := time.Now()
+	for i, fingerprint := range []string{"a", "b", "a", "c"} {
+		core := &collector.CoredumpFile{ID: fingerprint, Fingerprint: fingerprint}
+		registry.Observe(core, nil, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	groups := registry.Groups()
+	if len(groups) != 2 || groups[0].Fingerprint != "a" || groups[1].Fingerprint != "c" {
+		t.Errorf("expected groups a and c, got %+v", groups)
+	}
+	if _, exists := registry.Get("b"); exists {
+		t.Error("expected the least recently seen group to be evicted")
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var registry *Registry
+	core := &collector.CoredumpFile{Fingerprint: "abc"}
+	if _, duplicate := registry.Observe(core, nil, time.Now()); duplicate {
+		t.Error("nil registry must not report duplicates")
+	}
+	registry.RecordAIAnalysis("abc", "core-1", &collector.AIAnalysisResult{})
+	if registry.Groups() != nil {
+		t.Error("nil registry must have no groups")
+	}
+}
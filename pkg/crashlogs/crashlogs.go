@@ -0,0 +1,170 @@
+// Package crashlogs fetches what a pod logged shortly before it crashed
+// from Loki, so the analysis of a core shows the errors leading up to it.
+package crashlogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultSelector = `{namespace="{namespace}", pod="{pod}"}`
+	defaultWindow   = 5 * time.Minute
+	defaultLimit    = 200
+	defaultTimeout  = 10 * time.Second
+
+	// Log shippers timestamp lines when they read them, which can be a few
+	// seconds after the crash wrote them.
+	shipperDelay = 10 * time.Second
+
+	maxResponseSize = 8 << 20
+)
+
+// Fetcher queries Loki for the logs of crashed pods. A nil Fetcher fetches
+// nothing.
+type Fetcher struct {
+	config   *config.PreCrashLogsConfig
+	client   *http.Client
+	endpoint string
+}
+
+// New creates the fetcher, or returns nil when pre-crash logs are disabled.
+func New(cfg *config.PreCrashLogsConfig) (*Fetcher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client, err := httpclient.New("Loki", cfg.Proxy, cfg.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Fetcher{
+		config:   cfg,
+		client:   client,
+		endpoint: strings.TrimSuffix(cfg.LokiURL, "/") + "/loki/api/v1/query_range",
+	}, nil
+}
+
+// Fetch returns the newest lines the coredump's pod logged in the window
+// before the core was written, oldest first. Cores not matched to a pod
+// have no logs.
+func (f *Fetcher) Fetch(ctx context.Context, coredump *collector.CoredumpFile) ([]collector.LogEntry, error) {
+	if f == nil || coredump.PodName == "" {
+		return nil, nil
+	}
+
+	window := f.config.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	limit := f.config.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	end := coredump.Timestamp.Add(shipperDelay)
+	start := coredump.Timestamp.Add(-window)
+
+	query := url.Values{}
+	query.Set("query", f.selector(coredump))
+	query.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	query.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("direction", "backward")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Loki request: %w", err)
+	}
+	if f.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", f.config.TenantID)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Loki response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Loki returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return parseQueryRange(body, limit)
+}
+
+// selector fills the coredump's namespace, pod and container into the
+// configured stream selector.
+func (f *Fetcher) selector(coredump *collector.CoredumpFile) string {
+	selector := f.config.Selector
+	if selector == "" {
+		selector = defaultSelector
+	}
+	return strings.NewReplacer(
+		"{namespace}", coredump.PodNamespace,
+		"{pod}", coredump.PodName,
+		"{container}", coredump.ContainerName,
+	).Replace(selector)
+}
+
+type queryRangeResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// parseQueryRange merges the streams of a query_range response into the
+// newest limit lines, oldest first.
+func parseQueryRange(body []byte, limit int) ([]collector.LogEntry, error) {
+	var response queryRangeResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode Loki response: %w", err)
+	}
+	if response.Status != "success" || response.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("unexpected Loki response: status %q, result type %q", response.Status, response.Data.ResultType)
+	}
+
+	entries := []collector.LogEntry{}
+	for _, stream := range response.Data.Result {
+		for _, value := range stream.Values {
+			nanos, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Loki timestamp %q", value[0])
+			}
+			entries = append(entries, collector.LogEntry{
+				Timestamp: time.Unix(0, nanos).UTC(),
+				Container: stream.Stream["container"],
+				Line:      value[1],
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
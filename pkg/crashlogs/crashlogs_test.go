@@ -0,0 +1,125 @@
+package crashlogs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestFetch(t *testing.T) {
+	crashed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	nanos := func(offset time.Duration) string {
+		return strconv.FormatInt(crashed.Add(offset).UnixNano(), 10)
+	}
+
+	var query map[string][]string
+	var tenant string
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		tenant = r.Header.Get("X-Scope-OrgID")
+		// Backward queries return each stream newest first.
+		w.Write([]byte(`{"status": "success", "data": {"resultType": "streams", "result": [
+			{"stream": {"container": "querynode"}, "values": [
+				["` + nanos(-time.Second) + `", "panic: runtime error"],
+				["` + nanos(-time.Minute) + `", "segment load started"]
+			]},
+			{"stream": {"container": "sidecar"}, "values": [
+				["` + nanos(-30*time.Second) + `", "flush"],
+				["` + nanos(-2*time.Minute) + `", "too old to keep"]
+			]}
+		]}}`))
+	}))
+	defer loki.Close()
+
+	fetcher, err := New(&config.PreCrashLogsConfig{
+		Enabled:  true,
+		LokiURL:  loki.URL + "/",
+		Selector: `{namespace="{namespace}", pod="{pod}", container="{container}"}`,
+		Window:   time.Minute,
+		Limit:    3,
+		TenantID: "milvus",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{
+		PodNamespace:  "milvus",
+		PodName:       "prod-querynode-0",
+		ContainerName: "querynode",
+		Timestamp:     crashed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := query["query"][0]; got != `{namespace="milvus", pod="prod-querynode-0", container="querynode"}` {
+		t.Errorf("unexpected selector %s", got)
+	}
+	if query["start"][0] != nanos(-time.Minute) || query["end"][0] != nanos(shipperDelay) {
+		t.Errorf("expected the window before the crash, got %s to %s", query["start"][0], query["end"][0])
+	}
+	if query["limit"][0] != "3" || query["direction"][0] != "backward" || tenant != "milvus" {
+		t.Errorf("unexpected limit %s, direction %s or tenant %q", query["limit"][0], query["direction"][0], tenant)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected the newest 3 lines, got %+v", entries)
+	}
+	if entries[0].Line != "segment load started" || entries[1].Container != "sidecar" || entries[2].Line != "panic: runtime error" {
+		t.Errorf("expected the streams merged oldest first, got %+v", entries)
+	}
+	if !entries[2].Timestamp.Equal(crashed.Add(-time.Second)) {
+		t.Errorf("unexpected timestamp %v", entries[2].Timestamp)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	}))
+	defer loki.Close()
+
+	fetcher, err := New(&config.PreCrashLogsConfig{Enabled: true, LokiURL: loki.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{PodName: "prod-querynode-0"}); err == nil {
+		t.Error("expected an error when Loki rejects the query")
+	}
+
+	// Cores not matched to a pod are not queried.
+	if entries, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{}); err != nil || entries != nil {
+		t.Errorf("expected no logs without a pod, got %v (%v)", entries, err)
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{"status": "error"}`,
+		`{"status": "success", "data": {"resultType": "matrix"}}`,
+		`{"status": "success", "data": {"resultType": "streams", "result": [{"values": [["soon", "line"]]}]}}`,
+	} {
+		if _, err := parseQueryRange([]byte(body), 10); err == nil {
+			t.Errorf("expected an error for %s", body)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	fetcher, err := New(&config.PreCrashLogsConfig{})
+	if err != nil || fetcher != nil {
+		t.Fatalf("expected no fetcher when disabled, got %v (%v)", fetcher, err)
+	}
+	if entries, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{PodName: "prod-querynode-0"}); err != nil || entries != nil {
+		t.Errorf("expected a nil fetcher to fetch nothing, got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package crashmetrics snapshots Prometheus series over the minutes before
+// a crash, so the analysis of a core shows whether memory, CPU or load
+// were climbing when the process died.
+package crashmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultWindow  = 15 * time.Minute
+	defaultStep    = 30 * time.Second
+	defaultTimeout = 10 * time.Second
+
+	// maxSeries bounds the series kept per query, so a query without a pod
+	// matcher doesn't bloat the record.
+	maxSeries = 10
+
+	maxResponseSize = 8 << 20
+)
+
+// Fetcher runs the configured queries against Prometheus. A nil Fetcher
+// fetches nothing.
+type Fetcher struct {
+	config   *config.PreCrashMetricsConfig
+	client   *http.Client
+	endpoint string
+}
+
+// New creates the fetcher, or returns nil when pre-crash metrics are
+// disabled.
+func New(cfg *config.PreCrashMetricsConfig) (*Fetcher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client, err := httpclient.New("Prometheus", cfg.Proxy, cfg.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Fetcher{
+		config:   cfg,
+		client:   client,
+		endpoint: strings.TrimSuffix(cfg.PrometheusURL, "/") + "/api/v1/query_range",
+	}, nil
+}
+
+// Fetch returns the series of every query over the window before the core
+// was written. Queries that fail are left out and reported in the error,
+// next to the series of the others. Cores not matched to a pod have no
+// metrics.
+func (f *Fetcher) Fetch(ctx context.Context, coredump *collector.CoredumpFile) ([]collector.MetricSeries, error) {
+	if f == nil || coredump.PodName == "" {
+		return nil, nil
+	}
+
+	window := f.config.Window
+	if window <= 0 {
+		window = defaultWindow
+	}
+	step := f.config.Step
+	if step <= 0 {
+		step = defaultStep
+	}
+	end := coredump.Timestamp
+	start := end.Add(-window)
+	replacer := strings.NewReplacer(
+		"{namespace}", coredump.PodNamespace,
+		"{pod}", coredump.PodName,
+		"{container}", coredump.ContainerName,
+	)
+
+	var series []collector.MetricSeries
+	var errs []error
+	for _, query := range f.config.Queries {
+		result, err := f.queryRange(ctx, replacer.Replace(query.Query), start, end, step)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", query.Name, err))
+			continue
+		}
+		for _, s := range result {
+			s.Name = query.Name
+			series = append(series, s)
+		}
+	}
+	return series, errors.Join(errs...)
+}
+
+func (f *Fetcher) queryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]collector.MetricSeries, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Prometheus response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Prometheus returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return parseMatrix(body)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', 3, 64)
+}
+
+type queryRangeResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]interface{}  `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// parseMatrix converts the matrix of a query_range response, keeping the
+// first maxSeries series.
+func parseMatrix(body []byte) ([]collector.MetricSeries, error) {
+	var response queryRangeResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode Prometheus response: %w", err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed: %s", response.Error)
+	}
+	if response.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected Prometheus result type %q", response.Data.ResultType)
+	}
+
+	series := []collector.MetricSeries{}
+	for _, result := range response.Data.Result {
+		if len(series) == maxSeries {
+			break
+		}
+		s := collector.MetricSeries{Labels: result.Metric, Points: make([]collector.MetricPoint, 0, len(result.Values))}
+		delete(s.Labels, "__name__")
+		for _, value := range result.Values {
+			seconds, ok := value[0].(float64)
+			text, isString := value[1].(string)
+			if !ok || !isString {
+				return nil, fmt.Errorf("invalid Prometheus sample %v", value)
+			}
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Prometheus sample value %q", text)
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				// JSON can't carry them; the gap shows in the graph.
+				continue
+			}
+			s.Points = append(s.Points, collector.MetricPoint{
+				Timestamp: time.Unix(0, int64(seconds*float64(time.Second))).UTC(),
+				Value:     v,
+			})
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
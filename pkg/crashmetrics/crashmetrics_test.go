@@ -0,0 +1,122 @@
+package crashmetrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestFetch(t *testing.T) {
+	crashed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var queries []string
+	var start, end, step string
+	prometheus := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" || r.ParseForm() != nil {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.PostForm.Get("query")
+		queries = append(queries, query)
+		start, end, step = r.PostForm.Get("start"), r.PostForm.Get("end"), r.PostForm.Get("step")
+		if strings.HasPrefix(query, "broken") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "parse error"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [
+			{"metric": {"__name__": "container_memory_working_set_bytes", "container": "querynode"},
+			 "values": [[1714564740, "1000"], [1714564770, "NaN"], [1714564800, "4000"]]}
+		]}}`))
+	}))
+	defer prometheus.Close()
+
+	fetcher, err := New(&config.PreCrashMetricsConfig{
+		Enabled:       true,
+		PrometheusURL: prometheus.URL,
+		Window:        time.Minute,
+		Step:          30 * time.Second,
+		Queries: []config.MetricQuery{
+			{Name: "memory", Query: `container_memory_working_set_bytes{namespace="{namespace}", pod="{pod}"}`},
+			{Name: "broken", Query: "broken("},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{
+		PodNamespace: "milvus",
+		PodName:      "prod-querynode-0",
+		Timestamp:    crashed,
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the failed query to be reported, got %v", err)
+	}
+
+	if queries[0] != `container_memory_working_set_bytes{namespace="milvus", pod="prod-querynode-0"}` {
+		t.Errorf("unexpected query %s", queries[0])
+	}
+	if start != "1714564740.000" || end != "1714564800.000" || step != "30" {
+		t.Errorf("expected the minute before the crash in 30s steps, got %s to %s step %s", start, end, step)
+	}
+
+	if len(series) != 1 {
+		t.Fatalf("expected the series of the working query, got %+v", series)
+	}
+	memory := series[0]
+	if memory.Name != "memory" || memory.Labels["container"] != "querynode" || memory.Labels["__name__"] != "" {
+		t.Errorf("unexpected series %+v", memory)
+	}
+	if len(memory.Points) != 2 || memory.Points[1].Value != 4000 || !memory.Points[1].Timestamp.Equal(crashed) {
+		t.Errorf("expected NaN samples dropped, got %+v", memory.Points)
+	}
+}
+
+func TestFetchSkipsCoresWithoutPod(t *testing.T) {
+	var nilFetcher *Fetcher
+	if series, err := nilFetcher.Fetch(context.Background(), &collector.CoredumpFile{PodName: "prod-querynode-0"}); series != nil || err != nil {
+		t.Errorf("expected a nil fetcher to fetch nothing, got %v (%v)", series, err)
+	}
+
+	fetcher, err := New(&config.PreCrashMetricsConfig{Enabled: true, PrometheusURL: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series, err := fetcher.Fetch(context.Background(), &collector.CoredumpFile{}); series != nil || err != nil {
+		t.Errorf("expected no metrics without a pod, got %v (%v)", series, err)
+	}
+}
+
+func TestParseMatrix(t *testing.T) {
+	for _, body := range []string{
+		`not json`,
+		`{"status": "error", "error": "timeout"}`,
+		`{"status": "success", "data": {"resultType": "vector"}}`,
+		`{"status": "success", "data": {"resultType": "matrix", "result": [{"values": [[1714564800, 1]]}]}}`,
+		`{"status": "success", "data": {"resultType": "matrix", "result": [{"values": [[1714564800, "high"]]}]}}`,
+	} {
+		if _, err := parseMatrix([]byte(body)); err == nil {
+			t.Errorf("expected an error for %s", body)
+		}
+	}
+
+	var result strings.Builder
+	result.WriteString(`{"status": "success", "data": {"resultType": "matrix", "result": [`)
+	for i := 0; i < maxSeries+5; i++ {
+		if i > 0 {
+			result.WriteString(",")
+		}
+		result.WriteString(`{"metric": {}, "values": []}`)
+	}
+	result.WriteString(`]}}`)
+	series, err := parseMatrix([]byte(result.String()))
+	if err != nil || len(series) != maxSeries {
+		t.Errorf("expected %d series kept, got %d (%v)", maxSeries, len(series), err)
+	}
+}
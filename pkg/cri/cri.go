@@ -0,0 +1,272 @@
+// Package cri finds the container a crashed process ran in, so its core is
+// attributed to the exact pod and container instead of guessed from the
+// executable's name and restart times. The crashed process' cgroup names
+// its container; when the process is gone before its cgroup could be read,
+// the container runtime (containerd, CRI-O) is asked over the CRI for the
+// container that exited on the crash's signal around the time of the crash.
+package cri
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	defaultTimeout     = 5 * time.Second
+	defaultMatchWindow = 30 * time.Second
+
+	// Labels the kubelet sets on the containers it creates.
+	labelPodName       = "io.kubernetes.pod.name"
+	labelPodNamespace  = "io.kubernetes.pod.namespace"
+	labelPodUID        = "io.kubernetes.pod.uid"
+	labelContainerName = "io.kubernetes.container.name"
+)
+
+// ErrNoContainer means the crashed process' cgroup shows it ran outside of
+// any container, on the host.
+var ErrNoContainer = errors.New("process did not run in a container")
+
+var (
+	// The last element of a container's cgroup, for cgroupfs and systemd
+	// drivers: <id>, cri-containerd-<id>.scope, crio-<id>.scope, ...
+	containerIDPattern = regexp.MustCompile(`(?:^|[-:])([0-9a-f]{64})(?:\.scope)?$`)
+	// kubepods-burstable-pod<uid>.slice, or pod<uid> with cgroupfs
+	podUIDPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// Process describes a crashed process, as known from its core.
+type Process struct {
+	PID        int
+	Executable string
+	Signal     int
+	Time       time.Time
+	// Its /proc/<pid>/cgroup, when read before it exited
+	Cgroup string
+}
+
+// Container is the container a process crashed in. Only ID and PodUID are
+// set when the runtime could not be asked about it.
+type Container struct {
+	ID           string
+	Name         string
+	Image        string
+	PodName      string
+	PodNamespace string
+	PodUID       string
+}
+
+// Resolver maps crashed processes to their containers. A nil *Resolver
+// resolves nothing.
+type Resolver struct {
+	config   *config.CRIConfig
+	procPath string
+	conn     *grpc.ClientConn
+	client   runtimeapi.RuntimeServiceClient
+}
+
+// New connects to the runtime's CRI socket, or returns nil when resolution
+// is disabled. procPath is /proc of the host PID namespace, where the
+// cgroups of crashed processes still exiting are read.
+func New(cfg *config.CRIConfig, procPath string) (*Resolver, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		return nil, errors.New("cri endpoint is required")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "unix://" + endpoint
+	}
+	if procPath == "" {
+		procPath = "/proc"
+	}
+	// Connects lazily, the runtime may come up after the agent.
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the CRI at %s: %w", endpoint, err)
+	}
+	return &Resolver{
+		config:   cfg,
+		procPath: procPath,
+		conn:     conn,
+		client:   runtimeapi.NewRuntimeServiceClient(conn),
+	}, nil
+}
+
+// Close disconnects from the runtime.
+func (r *Resolver) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
+
+// Resolve returns the container process crashed in, nil when it cannot be
+// told, or ErrNoContainer when the process ran on the host.
+func (r *Resolver) Resolve(ctx context.Context, process Process) (*Container, error) {
+	if r == nil {
+		return nil, nil
+	}
+	timeout := r.config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cgroup := process.Cgroup
+	if cgroup == "" {
+		cgroup = r.readCgroup(process)
+	}
+	if cgroup == "" {
+		return r.byExit(ctx, process)
+	}
+	id, podUID := ParseCgroup(cgroup)
+	if id == "" {
+		return nil, ErrNoContainer
+	}
+	status, err := r.client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: id})
+	if err != nil {
+		// The cgroup alone still names the container and its pod.
+		klog.Warningf("Failed to get status of container %s from the CRI: %v", id, err)
+		return &Container{ID: id, PodUID: podUID}, nil
+	}
+	return newContainer(status.GetStatus()), nil
+}
+
+// readCgroup reads the cgroup of process if it has not exited yet. The PID
+// may have been reused already, so the process must still run the
+// executable.
+func (r *Resolver) readCgroup(process Process) string {
+	if process.PID <= 0 || process.Executable == "" {
+		return ""
+	}
+	dir := filepath.Join(r.procPath, strconv.Itoa(process.PID))
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil || !sameExecutable(strings.TrimSpace(string(comm)), process.Executable) {
+		return ""
+	}
+	cgroup, err := os.ReadFile(filepath.Join(dir, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	return string(cgroup)
+}
+
+// sameExecutable compares a process' comm, truncated to 15 bytes by the
+// kernel, with the executable named by its core, whose dots the collector
+// may have replaced.
+func sameExecutable(comm, executable string) bool {
+	if comm == "" {
+		return false
+	}
+	executable = strings.ReplaceAll(executable, "_", ".")
+	comm = strings.ReplaceAll(comm, "_", ".")
+	if len(executable) > len(comm) && len(comm) == 15 {
+		executable = executable[:15]
+	}
+	return comm == executable
+}
+
+// byExit asks the runtime for the container that exited on the crash's
+// signal closest to the crash, within MatchWindow. This only finds crashes
+// of a container's main process, which take the container down with them.
+func (r *Resolver) byExit(ctx context.Context, process Process) (*Container, error) {
+	if process.Time.IsZero() {
+		return nil, nil
+	}
+	window := r.config.MatchWindow
+	if window <= 0 {
+		window = defaultMatchWindow
+	}
+	list, err := r.client.ListContainers(ctx, &runtimeapi.ListContainersRequest{
+		Filter: &runtimeapi.ContainerFilter{
+			State: &runtimeapi.ContainerStateValue{State: runtimeapi.ContainerState_CONTAINER_EXITED},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers from the CRI: %w", err)
+	}
+
+	var closest *runtimeapi.ContainerStatus
+	closestDiff := window
+	for _, container := range list.GetContainers() {
+		if container.GetCreatedAt() > process.Time.UnixNano() {
+			continue
+		}
+		response, err := r.client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: container.GetId()})
+		if err != nil {
+			klog.V(2).Infof("Failed to get status of container %s from the CRI: %v", container.GetId(), err)
+			continue
+		}
+		status := response.GetStatus()
+		if process.Signal > 0 && status.GetExitCode() != int32(128+process.Signal) {
+			continue
+		}
+		finished := time.Unix(0, status.GetFinishedAt())
+		if diff := finished.Sub(process.Time).Abs(); diff <= closestDiff {
+			closest = status
+			closestDiff = diff
+		}
+	}
+	if closest == nil {
+		return nil, nil
+	}
+	return newContainer(closest), nil
+}
+
+func newContainer(status *runtimeapi.ContainerStatus) *Container {
+	labels := status.GetLabels()
+	container := &Container{
+		ID:           status.GetId(),
+		Name:         labels[labelContainerName],
+		Image:        status.GetImage().GetImage(),
+		PodName:      labels[labelPodName],
+		PodNamespace: labels[labelPodNamespace],
+		PodUID:       labels[labelPodUID],
+	}
+	if container.Name == "" {
+		container.Name = status.GetMetadata().GetName()
+	}
+	return container
+}
+
+// ParseCgroup returns the container ID and pod UID in a /proc/<pid>/cgroup
+// file, empty when the process did not run in a container or pod.
+func ParseCgroup(cgroup string) (containerID, podUID string) {
+	scanner := bufio.NewScanner(strings.NewReader(cgroup))
+	for scanner.Scan() {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := strings.TrimSuffix(parts[2], "/")
+		match := containerIDPattern.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			continue
+		}
+		containerID = match[1]
+		if match := podUIDPattern.FindStringSubmatch(path); match != nil {
+			podUID = strings.ReplaceAll(match[1], "_", "-")
+		}
+		return containerID, podUID
+	}
+	return "", ""
+}
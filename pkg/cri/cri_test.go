@@ -0,0 +1,190 @@
+package cri
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	crashedID = "4f2b6c1d9e8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c"
+	otherID   = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	podUID    = "0b9f3c4e-2d1a-4e5f-8a7b-6c5d4e3f2a1b"
+)
+
+// fakeRuntime serves the containers of a CRI runtime.
+type fakeRuntime struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	statuses []*runtimeapi.ContainerStatus
+}
+
+func (f *fakeRuntime) ListContainers(ctx context.Context, request *runtimeapi.ListContainersRequest) (*runtimeapi.ListContainersResponse, error) {
+	var containers []*runtimeapi.Container
+	for _, status := range f.statuses {
+		if request.GetFilter().GetState() != nil && status.State != request.GetFilter().GetState().GetState() {
+			continue
+		}
+		containers = append(containers, &runtimeapi.Container{Id: status.Id, State: status.State, CreatedAt: status.CreatedAt, Labels: status.Labels})
+	}
+	return &runtimeapi.ListContainersResponse{Containers: containers}, nil
+}
+
+func (f *fakeRuntime) ContainerStatus(ctx context.Context, request *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+	for _, status := range f.statuses {
+		if status.Id == request.ContainerId {
+			return &runtimeapi.ContainerStatusResponse{Status: status}, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func startFakeRuntime(t *testing.T, statuses ...*runtimeapi.ContainerStatus) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, &fakeRuntime{statuses: statuses})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return "unix://" + socket
+}
+
+func exited(id, container string, exitCode int32, created, finished time.Time) *runtimeapi.ContainerStatus {
+	return &runtimeapi.ContainerStatus{
+		Id:         id,
+		Metadata:   &runtimeapi.ContainerMetadata{Name: container},
+		State:      runtimeapi.ContainerState_CONTAINER_EXITED,
+		CreatedAt:  created.UnixNano(),
+		FinishedAt: finished.UnixNano(),
+		ExitCode:   exitCode,
+		Image:      &runtimeapi.ImageSpec{Image: "milvusdb/milvus:v2.4.0"},
+		Labels: map[string]string{
+			labelPodName:       "milvus-querynode-0",
+			labelPodNamespace:  "milvus",
+			labelPodUID:        podUID,
+			labelContainerName: container,
+		},
+	}
+}
+
+func newTestResolver(t *testing.T, endpoint, procPath string) *Resolver {
+	t.Helper()
+	resolver, err := New(&config.CRIConfig{Enabled: true, Endpoint: endpoint}, procPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resolver.Close() })
+	return resolver
+}
+
+func TestParseCgroup(t *testing.T) {
+	cases := []struct {
+		name, cgroup, id, podUID string
+	}{
+		{
+			name:   "containerd with the systemd driver",
+			cgroup: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0b9f3c4e_2d1a_4e5f_8a7b_6c5d4e3f2a1b.slice/cri-containerd-" + crashedID + ".scope\n",
+			id:     crashedID,
+			podUID: podUID,
+		},
+		{
+			name:   "CRI-O",
+			cgroup: "0::/kubepods.slice/kubepods-pod0b9f3c4e_2d1a_4e5f_8a7b_6c5d4e3f2a1b.slice/crio-" + crashedID + ".scope\n",
+			id:     crashedID,
+			podUID: podUID,
+		},
+		{
+			name:   "cgroup v1 with the cgroupfs driver",
+			cgroup: "12:pids:/kubepods/besteffort/pod" + podUID + "/" + crashedID + "\n11:memory:/kubepods/besteffort/pod" + podUID + "/" + crashedID + "\n",
+			id:     crashedID,
+			podUID: podUID,
+		},
+		{
+			name:   "a host process",
+			cgroup: "0::/system.slice/sshd.service\n",
+		},
+	}
+	for _, tc := range cases {
+		id, uid := ParseCgroup(tc.cgroup)
+		if id != tc.id || uid != tc.podUID {
+			t.Errorf("%s: expected container %q of pod %q, got %q of %q", tc.name, tc.id, tc.podUID, id, uid)
+		}
+	}
+}
+
+func TestResolveByCgroup(t *testing.T) {
+	crashed := time.Now()
+	endpoint := startFakeRuntime(t, exited(crashedID, "querynode", 139, crashed.Add(-time.Hour), crashed))
+	resolver := newTestResolver(t, endpoint, t.TempDir())
+
+	cgroup := "0::/kubepods.slice/kubepods-pod0b9f3c4e_2d1a_4e5f_8a7b_6c5d4e3f2a1b.slice/cri-containerd-" + crashedID + ".scope\n"
+	container, err := resolver.Resolve(context.Background(), Process{PID: 4242, Executable: "milvus", Signal: 11, Time: crashed, Cgroup: cgroup})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container == nil || container.ID != crashedID || container.Name != "querynode" || container.PodName != "milvus-querynode-0" ||
+		container.PodNamespace != "milvus" || container.PodUID != podUID || container.Image != "milvusdb/milvus:v2.4.0" {
+		t.Errorf("expected the container named by the cgroup, got %+v", container)
+	}
+
+	if _, err := resolver.Resolve(context.Background(), Process{PID: 4242, Executable: "sshd", Cgroup: "0::/system.slice/sshd.service\n"}); !errors.Is(err, ErrNoContainer) {
+		t.Errorf("expected a host process to be outside of containers, got %v", err)
+	}
+}
+
+func TestResolveReadsCgroupOfExitingProcess(t *testing.T) {
+	procPath := t.TempDir()
+	dir := filepath.Join(procPath, "4242")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "comm"), []byte("milvus\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "cgroup"), []byte("0::/kubepods/pod"+podUID+"/"+crashedID+"\n"), 0644)
+
+	// The runtime is unreachable; the cgroup alone names the container.
+	resolver := newTestResolver(t, "unix://"+filepath.Join(t.TempDir(), "missing.sock"), procPath)
+	container, err := resolver.Resolve(context.Background(), Process{PID: 4242, Executable: "milvus", Signal: 11, Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container == nil || container.ID != crashedID || container.PodUID != podUID {
+		t.Errorf("expected the container of the process' cgroup, got %+v", container)
+	}
+
+	// The PID was reused by another executable.
+	os.WriteFile(filepath.Join(dir, "comm"), []byte("bash\n"), 0644)
+	if got := resolver.readCgroup(Process{PID: 4242, Executable: "milvus"}); got != "" {
+		t.Errorf("expected the cgroup of another executable to be ignored, got %q", got)
+	}
+}
+
+func TestResolveByExit(t *testing.T) {
+	crashed := time.Now().Truncate(time.Second)
+	endpoint := startFakeRuntime(t,
+		exited(otherID, "init-config", 0, crashed.Add(-time.Hour), crashed.Add(time.Second)),
+		exited(crashedID, "querynode", 139, crashed.Add(-time.Hour), crashed.Add(2*time.Second)),
+	)
+	resolver := newTestResolver(t, endpoint, t.TempDir())
+
+	container, err := resolver.Resolve(context.Background(), Process{PID: 4242, Executable: "milvus", Signal: 11, Time: crashed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container == nil || container.ID != crashedID {
+		t.Errorf("expected the container that exited on SIGSEGV, got %+v", container)
+	}
+
+	if container, err := resolver.Resolve(context.Background(), Process{PID: 4242, Executable: "milvus", Signal: 11, Time: crashed.Add(time.Hour)}); err != nil || container != nil {
+		t.Errorf("expected no container to have exited near the crash, got %+v, %v", container, err)
+	}
+}
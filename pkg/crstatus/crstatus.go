@@ -0,0 +1,328 @@
+// Package crstatus follows the status of Milvus custom resources managed by
+// the Milvus operator and keeps their condition transitions, which often
+// explain why an instance's pods started crashing: an upgrade that failed,
+// or a dependency such as etcd or storage becoming unavailable. It also
+// keeps what each CR asks for, so that it can be compared with the pods
+// discovery finds.
+package crstatus
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	// ConditionStatus is the condition name under which changes of the
+	// overall status.status (Healthy, Unhealthy, ...) are recorded.
+	ConditionStatus = "Status"
+
+	defaultResyncInterval = 10 * time.Minute
+	defaultRetention      = 7 * 24 * time.Hour
+	// maxTransitions bounds the transitions kept per instance.
+	maxTransitions = 500
+)
+
+var milvusResource = schema.GroupVersionResource{Group: "milvus.io", Version: "v1beta1", Resource: "milvuses"}
+
+// Transition is a change of one status condition of a Milvus CR.
+type Transition struct {
+	Namespace string `json:"namespace"`
+	Instance  string `json:"instance"`
+	Condition string `json:"condition"`
+	Status    string `json:"status"`
+	// Previous is empty for the first status seen.
+	Previous string    `json:"previous,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Resource is what a Milvus CR asks for and what the operator reports
+// having rolled out.
+type Resource struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// standalone or cluster
+	Mode   string `json:"mode,omitempty"`
+	Status string `json:"status,omitempty"`
+	// Image and Milvus version of the spec
+	Image   string `json:"image,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Image the operator last rolled out, when it reports it
+	CurrentImage string `json:"currentImage,omitempty"`
+	// Desired replicas by component, named as discovery names them
+	Replicas map[string]int64 `json:"replicas,omitempty"`
+	// The spec generation, and the one the operator last reconciled
+	Generation         int64     `json:"generation"`
+	ObservedGeneration int64     `json:"observedGeneration"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+type conditionState struct {
+	status string
+	reason string
+}
+
+// Tracker watches the Milvus CRs. A nil *Tracker records nothing.
+type Tracker struct {
+	config *config.MilvusCRConfig
+	client dynamic.Interface
+
+	mu          sync.RWMutex
+	states      map[string]map[string]conditionState
+	transitions map[string][]Transition
+	resources   map[string]*Resource
+}
+
+func New(config *config.MilvusCRConfig, client dynamic.Interface) *Tracker {
+	return &Tracker{
+		config:      config,
+		client:      client,
+		states:      make(map[string]map[string]conditionState),
+		transitions: make(map[string][]Transition),
+		resources:   make(map[string]*Resource),
+	}
+}
+
+// Start watches the Milvus CRs until ctx is done. Without the Milvus CRD,
+// i.e. in clusters that only run Helm deployments, or without permission to
+// list the CRs, it returns right away; crashes are tracked all the same.
+func (t *Tracker) Start(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	_, err := t.client.Resource(milvusResource).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{Limit: 1})
+	if apierrors.IsNotFound(err) {
+		klog.Info("Milvus CRD is not installed, not watching Milvus CR status")
+		return nil
+	}
+	if err != nil {
+		klog.Warningf("Not watching Milvus CR status: failed to list Milvus CRs: %v", err)
+		return nil
+	}
+
+	klog.Info("Watching Milvus CR status")
+	resync := t.config.ResyncInterval
+	if resync <= 0 {
+		resync = defaultResyncInterval
+	}
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(t.client, resync)
+	informer := factory.ForResource(milvusResource).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if item, ok := obj.(*unstructured.Unstructured); ok {
+				t.observe(item, time.Now())
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if item, ok := newObj.(*unstructured.Unstructured); ok {
+				t.observe(item, time.Now())
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if item, ok := obj.(*unstructured.Unstructured); ok {
+				t.forget(item)
+			}
+		},
+	})
+
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+	return nil
+}
+
+// Transitions returns the recorded transitions of an instance, oldest first.
+func (t *Tracker) Transitions(namespace, instance string) []Transition {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]Transition(nil), t.transitions[namespace+"/"+instance]...)
+}
+
+// Resource returns the state of the Milvus CR namespace/name, or nil when
+// there is no such CR.
+func (t *Tracker) Resource(namespace, name string) *Resource {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	resource, exists := t.resources[namespace+"/"+name]
+	if !exists {
+		return nil
+	}
+	copied := *resource
+	return &copied
+}
+
+// observe records the conditions of the CR that changed since it was last
+// seen. A condition whose status changed is dated by its
+// lastTransitionTime; a changed reason alone is dated now.
+func (t *Tracker) observe(item *unstructured.Unstructured, now time.Time) {
+	key := item.GetNamespace() + "/" + item.GetName()
+
+	var observed []Transition
+	if phase, _, _ := unstructured.NestedString(item.Object, "status", "status"); phase != "" {
+		observed = append(observed, Transition{Condition: ConditionStatus, Status: phase, Time: now})
+	}
+	conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+	for _, entry := range conditions {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		transition := Transition{Time: now}
+		transition.Condition, _ = fields["type"].(string)
+		transition.Status, _ = fields["status"].(string)
+		transition.Reason, _ = fields["reason"].(string)
+		transition.Message, _ = fields["message"].(string)
+		if transition.Condition == "" {
+			continue
+		}
+		if value, ok := fields["lastTransitionTime"].(string); ok {
+			if at, err := time.Parse(time.RFC3339, value); err == nil {
+				transition.Time = at
+			}
+		}
+		observed = append(observed, transition)
+	}
+
+	resource := parseResource(item, now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.resources[key] = resource
+	states, exists := t.states[key]
+	if !exists {
+		states = make(map[string]conditionState)
+		t.states[key] = states
+	}
+	for _, transition := range observed {
+		previous, seen := states[transition.Condition]
+		if seen && previous.status == transition.Status && previous.reason == transition.Reason {
+			continue
+		}
+		if seen && previous.status == transition.Status {
+			transition.Time = now
+		}
+		states[transition.Condition] = conditionState{status: transition.Status, reason: transition.Reason}
+
+		transition.Namespace = item.GetNamespace()
+		transition.Instance = item.GetName()
+		transition.Previous = previous.status
+		if seen {
+			klog.Infof("Milvus %s: %s changed from %s to %s (%s)", key, transition.Condition, previous.status, transition.Status, transition.Reason)
+		}
+		t.transitions[key] = append(t.transitions[key], transition)
+	}
+	t.prune(now)
+}
+
+// prune keeps each instance's transitions within the retention period and
+// maxTransitions, sorted by time. Instances whose CR was deleted are
+// dropped once their last transition aged out.
+func (t *Tracker) prune(now time.Time) {
+	retention := t.config.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	cutoff := now.Add(-retention)
+
+	for key, transitions := range t.transitions {
+		sort.SliceStable(transitions, func(i, j int) bool {
+			return transitions[i].Time.Before(transitions[j].Time)
+		})
+		start := sort.Search(len(transitions), func(i int) bool {
+			return !transitions[i].Time.Before(cutoff)
+		})
+		if len(transitions)-start > maxTransitions {
+			start = len(transitions) - maxTransitions
+		}
+		if _, exists := t.states[key]; !exists && start == len(transitions) {
+			delete(t.transitions, key)
+			continue
+		}
+		t.transitions[key] = append([]Transition(nil), transitions[start:]...)
+	}
+}
+
+// forget drops the state of a deleted CR so a CR recreated under the same
+// name starts fresh. Its transitions stay until they age out.
+func (t *Tracker) forget(item *unstructured.Unstructured) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.states, item.GetNamespace()+"/"+item.GetName())
+	delete(t.resources, item.GetNamespace()+"/"+item.GetName())
+}
+
+// parseResource reads the spec and status of a Milvus CR. Components are
+// keyed by their spec field in lower case, e.g. queryNode as querynode.
+func parseResource(item *unstructured.Unstructured, now time.Time) *Resource {
+	resource := &Resource{
+		Namespace:  item.GetNamespace(),
+		Name:       item.GetName(),
+		Generation: item.GetGeneration(),
+		UpdatedAt:  now,
+	}
+	resource.Mode, _, _ = unstructured.NestedString(item.Object, "spec", "mode")
+	resource.Status, _, _ = unstructured.NestedString(item.Object, "status", "status")
+	resource.Image, _, _ = unstructured.NestedString(item.Object, "spec", "components", "image")
+	resource.CurrentImage, _, _ = unstructured.NestedString(item.Object, "status", "currentImage")
+	resource.ObservedGeneration, _, _ = unstructured.NestedInt64(item.Object, "status", "observedGeneration")
+	resource.Version, _, _ = unstructured.NestedString(item.Object, "spec", "components", "version")
+	if resource.Version == "" {
+		resource.Version = imageTag(resource.Image)
+	}
+
+	components, _, _ := unstructured.NestedMap(item.Object, "spec", "components")
+	for name, value := range components {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		replicas, found, err := unstructured.NestedInt64(fields, "replicas")
+		if !found || err != nil {
+			continue
+		}
+		if resource.Replicas == nil {
+			resource.Replicas = make(map[string]int64)
+		}
+		resource.Replicas[strings.ToLower(name)] = replicas
+	}
+	return resource
+}
+
+// imageTag returns the tag of an image reference, without a digest.
+func imageTag(image string) string {
+	if at := strings.Index(image, "@"); at != -1 {
+		image = image[:at]
+	}
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		return image[colon+1:]
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package crstatus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func milvusObject(phase string, conditions ...map[string]interface{}) *unstructured.Unstructured {
+	entries := make([]interface{}, 0, len(conditions))
+	for _, condition := range conditions {
+		entries = append(entries, condition)
+	}
+	item := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "milvus.io/v1beta1",
+		"kind":       "Milvus",
+		"status":     map[string]interface{}{"status": phase, "conditions": entries},
+	}}
+	item.SetNamespace("milvus")
+	item.SetName("prod")
+	return item
+}
+
+func condition(conditionType, status, reason string, at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"type":               conditionType,
+		"status":             status,
+		"reason":             reason,
+		"lastTransitionTime": at.Format(time.RFC3339),
+	}
+}
+
+func TestObserveRecordsTransitions(t *testing.T) {
+	tracker := New(&config.MilvusCRConfig{}, nil)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tracker.observe(milvusObject("Healthy",
+		condition("MilvusReady", "True", "AllReady", start),
+		condition("MilvusUpdated", "True", "Updated", start)), start)
+	// A resync without changes records nothing.
+	tracker.observe(milvusObject("Healthy",
+		condition("MilvusReady", "True", "AllReady", start),
+		condition("MilvusUpdated", "True", "Updated", start)), start.Add(time.Minute))
+
+	failed := start.Add(10 * time.Minute)
+	tracker.observe(milvusObject("Unhealthy",
+		condition("MilvusReady", "False", "DependencyNotReady", failed),
+		condition("MilvusUpdated", "False", "UpdateFailed", failed)), failed.Add(5*time.Second))
+
+	transitions := tracker.Transitions("milvus", "prod")
+	if len(transitions) != 6 {
+		t.Fatalf("expected 6 transitions, got %+v", transitions)
+	}
+	last := transitions[len(transitions)-1]
+	if last.Condition != ConditionStatus || last.Status != "Unhealthy" || last.Previous != "Healthy" {
+		t.Errorf("expected the status change last, got %+v", last)
+	}
+	var updateFailed *Transition
+	for i := range transitions {
+		if transitions[i].Reason == "UpdateFailed" {
+			updateFailed = &transitions[i]
+		}
+	}
+	if updateFailed == nil || !updateFailed.Time.Equal(failed) || updateFailed.Previous != "True" {
+		t.Errorf("expected the UpdateFailed transition dated by lastTransitionTime, got %+v", updateFailed)
+	}
+	if got := tracker.Transitions("milvus", "other"); len(got) != 0 {
+		t.Errorf("expected no transitions for another instance, got %+v", got)
+	}
+}
+
+func TestObserveRecordsResource(t *testing.T) {
+	tracker := New(&config.MilvusCRConfig{}, nil)
+	item := milvusObject("Healthy")
+	item.SetGeneration(3)
+	item.Object["spec"] = map[string]interface{}{
+		"mode": "cluster",
+		"components": map[string]interface{}{
+			"image":     "milvusdb/milvus:v2.4.5",
+			"proxy":     map[string]interface{}{"replicas": int64(2)},
+			"queryNode": map[string]interface{}{"replicas": int64(3)},
+			"dataNode":  map[string]interface{}{},
+		},
+	}
+	item.Object["status"].(map[string]interface{})["observedGeneration"] = int64(2)
+	item.Object["status"].(map[string]interface{})["currentImage"] = "milvusdb/milvus:v2.4.4"
+	now := time.Now()
+	tracker.observe(item, now)
+
+	resource := tracker.Resource("milvus", "prod")
+	if resource == nil {
+		t.Fatal("expected the CR to be recorded")
+	}
+	if resource.Mode != "cluster" || resource.Status != "Healthy" || resource.Version != "v2.4.5" ||
+		resource.CurrentImage != "milvusdb/milvus:v2.4.4" || resource.Generation != 3 || resource.ObservedGeneration != 2 {
+		t.Errorf("unexpected resource %+v", resource)
+	}
+	if len(resource.Replicas) != 2 || resource.Replicas["proxy"] != 2 || resource.Replicas["querynode"] != 3 {
+		t.Errorf("expected the replicas of proxy and querynode, got %v", resource.Replicas)
+	}
+
+	tracker.forget(item)
+	if tracker.Resource("milvus", "prod") != nil {
+		t.Error("expected a deleted CR to be dropped")
+	}
+	var nilTracker *Tracker
+	if nilTracker.Resource("milvus", "prod") != nil {
+		t.Error("expected a nil tracker to know no CRs")
+	}
+}
+
+func TestPruneDropsOldTransitions(t *testing.T) {
+	tracker := New(&config.MilvusCRConfig{Retention: time.Hour}, nil)
+	now := time.Now()
+
+	tracker.observe(milvusObject("Healthy", condition("MilvusReady", "True", "AllReady", now.Add(-2*time.Hour))), now)
+	transitions := tracker.Transitions("milvus", "prod")
+	if len(transitions) != 1 || transitions[0].Condition != ConditionStatus {
+		t.Errorf("expected only the recent status transition, got %+v", transitions)
+	}
+
+	tracker.forget(milvusObject(""))
+	tracker.observe(milvusObject("Healthy"), now.Add(2*time.Hour))
+	if got := tracker.Transitions("milvus", "prod"); len(got) != 1 {
+		t.Errorf("expected a recreated CR to start fresh, got %+v", got)
+	}
+}
+
+func TestStartWatchesCRs(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{milvusResource: "MilvusList"})
+	tracker := New(&config.MilvusCRConfig{}, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- tracker.Start(ctx) }()
+
+	observed := milvusObject("Unhealthy")
+	if _, err := client.Resource(milvusResource).Namespace("milvus").Create(ctx, observed, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for len(tracker.Transitions("milvus", "prod")) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("expected the informer to observe the CR")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var nilTracker *Tracker
+	if err := nilTracker.Start(context.Background()); err != nil || nilTracker.Transitions("milvus", "prod") != nil {
+		t.Errorf("expected a nil tracker to do nothing")
+	}
+}
@@ -0,0 +1,238 @@
+// Package devmode runs the agent without a Kubernetes cluster. It provides
+// a fake clientset seeded with synthetic Milvus instances and a generator
+// that periodically "crashes" their pods, writing synthetic coredump files
+// and bumping restart counts so the whole pipeline has data to work on.
+package devmode
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+type syntheticInstance struct {
+	name       string
+	namespace  string
+	operator   bool
+	components []string
+}
+
+var syntheticInstances = []syntheticInstance{
+	{name: "milvus-dev", namespace: "default", components: []string{"standalone"}},
+	{name: "milvus-prod", namespace: "default", components: []string{"proxy", "querynode", "querynode", "datanode", "indexnode"}},
+	{name: "milvus-operated", namespace: "milvus-system", operator: true, components: []string{"proxy", "querynode", "datanode"}},
+}
+
+var syntheticSignals = []struct {
+	signal int32
+	reason string
+}{
+	{11, "Error"},
+	{6, "Error"},
+	{8, "Error"},
+}
+
+// NewClient returns a fake clientset populated with the synthetic instances.
+func NewClient() kubernetes.Interface {
+	client := fake.NewSimpleClientset()
+	seeded := 0
+
+	for _, instance := range syntheticInstances {
+		for i, component := range instance.components {
+			pod := newPod(instance, component, i)
+			if _, err := client.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+				klog.Errorf("Failed to seed synthetic pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				continue
+			}
+			seeded++
+		}
+	}
+
+	klog.Infof("Seeded fake Kubernetes client with %d synthetic Milvus pods", seeded)
+	return client
+}
+
+// ApplyConfig points the agent at local, hermetic dependencies: a scratch
+// coredump directory, the memory storage backend, the fake AI provider and
+// no gdb, Helm or container runtime.
+func ApplyConfig(cfg *config.Config, coredumpDir string) {
+	cfg.Collector.CoredumpPath = coredumpDir
+	cfg.Collector.HostCoredumpPath = coredumpDir
+	cfg.Collector.CRI.Enabled = false
+
+	namespaces := map[string]bool{}
+	for _, namespace := range cfg.Discovery.Namespaces {
+		namespaces[namespace] = true
+	}
+	for _, instance := range syntheticInstances {
+		if !namespaces[instance.namespace] {
+			cfg.Discovery.Namespaces = append(cfg.Discovery.Namespaces, instance.namespace)
+			namespaces[instance.namespace] = true
+		}
+	}
+
+	cfg.Analyzer.EnableGdbAnalysis = false
+	cfg.Analyzer.AIAnalysis.Provider = "fake"
+	cfg.Analyzer.AIAnalysis.Model = "fake"
+	cfg.Storage.Backend = "memory"
+	cfg.Cleaner.Enabled = false
+	cfg.API.Enabled = true
+}
+
+// Generator simulates crashes of the synthetic pods.
+type Generator struct {
+	client      kubernetes.Interface
+	coredumpDir string
+	interval    time.Duration
+	rand        *mathrand.Rand
+}
+
+func NewGenerator(client kubernetes.Interface, coredumpDir string, interval time.Duration) *Generator {
+	return &Generator{
+		client:      client,
+		coredumpDir: coredumpDir,
+		interval:    interval,
+		rand:        mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (g *Generator) Start(ctx context.Context) error {
+	klog.Infof("Starting synthetic crash generator (every %v, writing to %s)", g.interval, g.coredumpDir)
+
+	if err := os.MkdirAll(g.coredumpDir, 0755); err != nil {
+		return fmt.Errorf("failed to create coredump directory: %w", err)
+	}
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := g.crashRandomPod(ctx); err != nil {
+				klog.Errorf("Failed to simulate crash: %v", err)
+			}
+		}
+	}
+}
+
+func (g *Generator) crashRandomPod(ctx context.Context) error {
+	instance := syntheticInstances[g.rand.Intn(len(syntheticInstances))]
+
+	pods, err := g.client.CoreV1().Pods(instance.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var candidates []corev1.Pod
+	for _, pod := range pods.Items {
+		if podInstance(&pod) == instance.name {
+			candidates = append(candidates, pod)
+		}
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no pods for instance %s/%s", instance.namespace, instance.name)
+	}
+
+	pod := candidates[g.rand.Intn(len(candidates))]
+	crash := syntheticSignals[g.rand.Intn(len(syntheticSignals))]
+	now := metav1.Now()
+
+	pid := 1000 + g.rand.Intn(60000)
+	filename := fmt.Sprintf("core.milvus.%d.%d.%d", pid, 1000, crash.signal)
+	if err := g.writeCoredump(filepath.Join(g.coredumpDir, filename)); err != nil {
+		return err
+	}
+
+	status := &pod.Status.ContainerStatuses[0]
+	status.RestartCount++
+	status.LastTerminationState = corev1.ContainerState{
+		Terminated: &corev1.ContainerStateTerminated{
+			ExitCode:   128 + crash.signal,
+			Signal:     crash.signal,
+			Reason:     crash.reason,
+			FinishedAt: now,
+		},
+	}
+
+	if _, err := g.client.CoreV1().Pods(pod.Namespace).UpdateStatus(ctx, &pod, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update pod status: %w", err)
+	}
+
+	klog.Infof("Simulated crash of %s/%s with signal %d, wrote %s",
+		pod.Namespace, pod.Name, crash.signal, filename)
+	return nil
+}
+
+func (g *Generator) writeCoredump(path string) error {
+	// Size varies so that storage and scoring see different inputs.
+	data := make([]byte, 64*1024+g.rand.Intn(1024*1024))
+	if _, err := rand.Read(data); err != nil {
+		return fmt.Errorf("failed to generate coredump content: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write synthetic coredump: %w", err)
+	}
+	return nil
+}
+
+func newPod(instance syntheticInstance, component string, index int) *corev1.Pod {
+	labels := map[string]string{
+		"app.kubernetes.io/component": component,
+	}
+	if instance.operator {
+		labels["app.kubernetes.io/managed-by"] = "milvus-operator"
+		labels["milvus.io/instance"] = instance.name
+	} else {
+		labels["app.kubernetes.io/name"] = "milvus"
+		labels["helm.sh/chart"] = "milvus"
+		labels["app.kubernetes.io/instance"] = instance.name
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              fmt.Sprintf("%s-milvus-%s-%d", instance.name, component, index),
+			Namespace:         instance.namespace,
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "milvus",
+					Image: "milvusdb/milvus:v2.4.5",
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "milvus",
+					Ready: true,
+				},
+			},
+		},
+	}
+}
+
+func podInstance(pod *corev1.Pod) string {
+	if name, exists := pod.Labels["app.kubernetes.io/instance"]; exists {
+		return name
+	}
+	return pod.Labels["milvus.io/instance"]
+}
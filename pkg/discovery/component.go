@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// milvusComponents are the Milvus roles a pod can run.
+var milvusComponents = map[string]bool{
+	"proxy":         true,
+	"mixcoord":      true,
+	"rootcoord":     true,
+	"datacoord":     true,
+	"querycoord":    true,
+	"indexcoord":    true,
+	"querynode":     true,
+	"datanode":      true,
+	"indexnode":     true,
+	"streamingnode": true,
+	"standalone":    true,
+}
+
+// componentLabels name a pod's role: the operator sets the first, the Helm
+// chart the second.
+var componentLabels = []string{"app.kubernetes.io/component", "component"}
+
+// podComponent returns the Milvus role a pod runs. The component labels are
+// trusted first, then the container names and the role passed to
+// "milvus run", then the pod name, as in my-release-milvus-querynode-5c9d.
+// A label naming something other than a Milvus role, such as etcd, is
+// returned as is when nothing else matched.
+func podComponent(pod *corev1.Pod) string {
+	var labeled string
+	for _, label := range componentLabels {
+		value := pod.Labels[label]
+		if component := normalizeComponent(value); milvusComponents[component] {
+			return component
+		}
+		if labeled == "" {
+			labeled = value
+		}
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if component := normalizeComponent(container.Name); milvusComponents[component] {
+			return component
+		}
+		if component := runComponent(append(container.Command, container.Args...)); component != "" {
+			return component
+		}
+	}
+
+	if component := nameComponent(pod.Name); component != "" {
+		return component
+	}
+	return labeled
+}
+
+// runComponent returns the role in a "milvus run <role>" command line.
+func runComponent(args []string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "run" {
+			if component := normalizeComponent(args[i+1]); milvusComponents[component] {
+				return component
+			}
+		}
+	}
+	return ""
+}
+
+// nameComponent finds a role among the dash-separated parts of a pod name,
+// also across two parts for names such as query-node. Parts are matched
+// from the right, past the release name a role could also appear in.
+func nameComponent(name string) string {
+	parts := strings.Split(strings.ToLower(name), "-")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if milvusComponents[parts[i]] {
+			return parts[i]
+		}
+		if i > 0 && milvusComponents[parts[i-1]+parts[i]] {
+			return parts[i-1] + parts[i]
+		}
+	}
+	return ""
+}
+
+// normalizeComponent lower-cases a role and drops separators, so that
+// QueryNode, query-node and query_node are all querynode.
+func normalizeComponent(value string) string {
+	value = strings.ToLower(value)
+	return strings.NewReplacer("-", "", "_", "").Replace(value)
+}
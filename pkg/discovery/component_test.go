@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodComponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected string
+	}{
+		{
+			name: "operator label",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0",
+				Labels: map[string]string{"app.kubernetes.io/component": "querynode"}}},
+			expected: "querynode",
+		},
+		{
+			name: "helm chart label",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0",
+				Labels: map[string]string{"component": "RootCoord"}}},
+			expected: "rootcoord",
+		},
+		{
+			name: "container name",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "data-node"}}}},
+			expected: "datanode",
+		},
+		{
+			name: "milvus run argument",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a-0"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "milvus",
+					Command: []string{"/milvus/bin/milvus"}, Args: []string{"run", "indexnode"}}}}},
+			expected: "indexnode",
+		},
+		{
+			name:     "pod name",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "proxy-test-milvus-query-node-5c9d-x2"}},
+			expected: "querynode",
+		},
+		{
+			name: "non-milvus label",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "my-release-etcd-0",
+				Labels: map[string]string{"app.kubernetes.io/component": "etcd"}}},
+			expected: "etcd",
+		},
+		{
+			name:     "unknown",
+			pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "my-release-milvus-0"}},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if component := podComponent(tt.pod); component != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, component)
+			}
+		})
+	}
+}
@@ -4,44 +4,61 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
+	"milvus-coredump-agent/pkg/chanstats"
 	"milvus-coredump-agent/pkg/config"
 )
 
 type Discovery struct {
 	client      kubernetes.Interface
 	config      *config.DiscoveryConfig
+	registered  []registeredInstance
+	rules       []instanceRule
+	mu          sync.RWMutex
 	instances   map[string]*MilvusInstance
+	// Cleanups the cleaner reported, until the instance terminates
+	cleanups    map[string]Cleanup
 	restartChan chan RestartEvent
 	stopChan    chan struct{}
+	informers   []*podInformer
 }
 
 func New(client kubernetes.Interface, config *config.DiscoveryConfig) *Discovery {
-	return &Discovery{
+	d := &Discovery{
 		client:      client,
 		config:      config,
+		registered:  parseRegisteredInstances(config.Instances),
+		rules:       compileRules(config.Rules),
 		instances:   make(map[string]*MilvusInstance),
+		cleanups:    make(map[string]Cleanup),
 		restartChan: make(chan RestartEvent, 100),
 		stopChan:    make(chan struct{}),
 	}
+	d.informers = d.newPodInformers()
+	chanstats.Register("discovery_restarts", d.restartChan)
+	return d
 }
 
 func (d *Discovery) Start(ctx context.Context) error {
 	klog.Info("Starting Milvus instance discovery")
 
+	d.startInformers(d.stopChan)
 	go d.scanInstances(ctx)
-	go d.watchPodEvents(ctx)
 
 	<-ctx.Done()
 	close(d.stopChan)
+	for _, informer := range d.informers {
+		informer.factory.Shutdown()
+	}
 	return nil
 }
 
@@ -49,16 +66,29 @@ func (d *Discovery) GetRestartChannel() <-chan RestartEvent {
 	return d.restartChan
 }
 
+// GetInstances returns a snapshot of the known instances keyed by
+// namespace/name. Instances are replaced, never modified, on rescan.
 func (d *Discovery) GetInstances() map[string]*MilvusInstance {
-	return d.instances
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	instances := make(map[string]*MilvusInstance, len(d.instances))
+	for key, instance := range d.instances {
+		instances[key] = instance
+	}
+	return instances
 }
 
 func (d *Discovery) scanInstances(ctx context.Context) {
 	ticker := time.NewTicker(d.config.ScanInterval)
 	defer ticker.Stop()
 
-	// Scan immediately on startup
+	// Scan once the caches are filled, or after a scan interval at the
+	// latest; namespaces still syncing are picked up by the next scan.
 	klog.Info("Starting initial Milvus instance scan...")
+	syncCtx, cancel := context.WithTimeout(ctx, d.config.ScanInterval)
+	d.waitForCacheSync(syncCtx)
+	cancel()
 	d.discoverInstances(ctx)
 
 	for {
@@ -71,61 +101,69 @@ func (d *Discovery) scanInstances(ctx context.Context) {
 	}
 }
 
-func (d *Discovery) discoverInstances(ctx context.Context) {
-	klog.Infof("Scanning for Milvus instances in namespaces: %v", d.config.Namespaces)
-	for _, namespace := range d.config.Namespaces {
-		if err := d.discoverInNamespace(ctx, namespace); err != nil {
-			klog.Errorf("Failed to discover instances in namespace %s: %v", namespace, err)
-		}
+func (d *Discovery) waitForCacheSync(ctx context.Context) {
+	synced := make([]cache.InformerSynced, 0, len(d.informers))
+	for _, informer := range d.informers {
+		synced = append(synced, informer.informer.HasSynced)
 	}
+	cache.WaitForCacheSync(ctx.Done(), synced...)
 }
 
-func (d *Discovery) discoverInNamespace(ctx context.Context, namespace string) error {
-	pods, err := d.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list pods: %w", err)
+// discoverInstances rebuilds the instances from the pod caches. It does not
+// call the API server.
+func (d *Discovery) discoverInstances(ctx context.Context) {
+	for _, informer := range d.informers {
+		if !informer.informer.HasSynced() {
+			klog.Warningf("Not scanning namespace %q: its pod cache has not synced", informer.namespace)
+			continue
+		}
+		pods, err := informer.lister.List(labels.Everything())
+		if err != nil {
+			klog.Errorf("Failed to discover instances in namespace %s: %v", informer.namespace, err)
+			continue
+		}
+		d.discoverInNamespace(informer.namespace, pods)
 	}
+}
 
-	klog.Infof("Found %d pods in namespace %s", len(pods.Items), namespace)
+func (d *Discovery) discoverInNamespace(namespace string, pods []*corev1.Pod) {
+	klog.V(2).Infof("Found %d pods in namespace %s", len(pods), namespace)
 	instanceMap := make(map[string]*MilvusInstance)
 
-	for _, pod := range pods.Items {
-		if instance := d.identifyMilvusInstance(&pod); instance != nil {
+	for _, pod := range pods {
+		if instance := d.identifyMilvusInstance(pod); instance != nil {
 			key := fmt.Sprintf("%s/%s", instance.Namespace, instance.Name)
 			if existing, exists := instanceMap[key]; exists {
-				existing.Pods = append(existing.Pods, d.createPodInfo(&pod))
+				existing.Pods = append(existing.Pods, d.createPodInfo(pod))
 			} else {
-				instance.Pods = append(instance.Pods, d.createPodInfo(&pod))
+				instance.Pods = append(instance.Pods, d.createPodInfo(pod))
 				instanceMap[key] = instance
 			}
 		}
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	for key, instance := range instanceMap {
 		d.instances[key] = instance
 		klog.V(2).Infof("Discovered Milvus instance: %s", key)
 	}
-
-	return nil
+	d.terminateMissing(namespace, instanceMap, time.Now())
 }
 
 func (d *Discovery) identifyMilvusInstance(pod *corev1.Pod) *MilvusInstance {
-	deploymentType := d.getDeploymentType(pod)
-	if deploymentType == "" {
+	match := d.matchPod(pod)
+	if match == nil {
 		klog.V(4).Infof("Pod %s/%s is not a Milvus instance", pod.Namespace, pod.Name)
 		return nil
 	}
-	klog.Infof("Identified Milvus pod %s/%s as %s deployment", pod.Namespace, pod.Name, deploymentType)
-
-	instanceName := d.extractInstanceName(pod, deploymentType)
-	if instanceName == "" {
-		return nil
-	}
+	klog.Infof("Identified Milvus pod %s/%s as %s deployment (%s)", pod.Namespace, pod.Name, match.deploymentType, match.rule)
 
 	return &MilvusInstance{
-		Name:        instanceName,
+		Name:        match.instance,
 		Namespace:   pod.Namespace,
-		Type:        DeploymentType(deploymentType),
+		Type:        match.deploymentType,
+		MatchedRule: match.rule,
 		Labels:      pod.Labels,
 		Annotations: pod.Annotations,
 		Status:      d.getInstanceStatus(pod),
@@ -134,7 +172,9 @@ func (d *Discovery) identifyMilvusInstance(pod *corev1.Pod) *MilvusInstance {
 	}
 }
 
-func (d *Discovery) getDeploymentType(pod *corev1.Pod) string {
+// getDeploymentType returns the deployment type the pod's labels identify
+// and the configured label that did.
+func (d *Discovery) getDeploymentType(pod *corev1.Pod) (string, string) {
 	labels := pod.Labels
 	
 	for _, helmLabel := range d.config.HelmReleaseLabels {
@@ -142,11 +182,11 @@ func (d *Discovery) getDeploymentType(pod *corev1.Pod) string {
 		if len(parts) == 2 {
 			key, value := parts[0], parts[1]
 			if labels[key] == value {
-				return "helm"
+				return "helm", helmLabel
 			}
 		} else {
 			if _, exists := labels[helmLabel]; exists {
-				return "helm"
+				return "helm", helmLabel
 			}
 		}
 	}
@@ -156,16 +196,16 @@ func (d *Discovery) getDeploymentType(pod *corev1.Pod) string {
 		if len(parts) == 2 {
 			key, value := parts[0], parts[1]
 			if labels[key] == value {
-				return "operator"
+				return "operator", operatorLabel
 			}
 		} else {
 			if _, exists := labels[operatorLabel]; exists {
-				return "operator"
+				return "operator", operatorLabel
 			}
 		}
 	}
 
-	return ""
+	return "", ""
 }
 
 func (d *Discovery) extractInstanceName(pod *corev1.Pod, deploymentType string) string {
@@ -210,7 +250,8 @@ func (d *Discovery) createPodInfo(pod *corev1.Pod) PodInfo {
 	var lastRestart metav1.Time
 	var containerStatuses []ContainerStatusInfo
 
-	for _, containerStatus := range pod.Status.ContainerStatuses {
+	for _, status := range podContainerStatuses(pod) {
+		containerStatus := status.ContainerStatus
 		restartCount += containerStatus.RestartCount
 		
 		if containerStatus.LastTerminationState.Terminated != nil {
@@ -219,23 +260,29 @@ func (d *Discovery) createPodInfo(pod *corev1.Pod) PodInfo {
 			}
 		}
 
-		containerStatuses = append(containerStatuses, ContainerStatusInfo{
+		info := ContainerStatusInfo{
 			Name:         containerStatus.Name,
+			Type:         status.containerType,
 			RestartCount: containerStatus.RestartCount,
 			Ready:        containerStatus.Ready,
-			LastTerminationReason: func() string {
-				if containerStatus.LastTerminationState.Terminated != nil {
-					return containerStatus.LastTerminationState.Terminated.Reason
-				}
-				return ""
-			}(),
-			LastTerminationMessage: func() string {
-				if containerStatus.LastTerminationState.Terminated != nil {
-					return containerStatus.LastTerminationState.Terminated.Message
-				}
-				return ""
-			}(),
-		})
+			Image:        containerStatus.Image,
+			ImageID:      containerStatus.ImageID,
+		}
+		// IDs look like containerd://<id>.
+		if _, id, found := strings.Cut(containerStatus.ContainerID, "://"); found {
+			info.ContainerID = id
+		}
+		if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil {
+			info.LastTerminationReason = terminated.Reason
+			info.LastTerminationMessage = terminated.Message
+			info.LastTerminatedAt = terminated.FinishedAt
+		}
+		// Init and ephemeral containers that crashed without being
+		// restarted only show it in their current state.
+		if terminated := containerStatus.State.Terminated; terminated != nil && terminated.FinishedAt.After(info.LastTerminatedAt.Time) {
+			info.LastTerminatedAt = terminated.FinishedAt
+		}
+		containerStatuses = append(containerStatuses, info)
 	}
 
 	return PodInfo{
@@ -244,38 +291,52 @@ func (d *Discovery) createPodInfo(pod *corev1.Pod) PodInfo {
 		Status:            string(pod.Status.Phase),
 		RestartCount:      restartCount,
 		LastRestart:       lastRestart,
+		Component:         podComponent(pod),
+		MilvusVersion:     milvusVersion(pod),
 		ContainerStatuses: containerStatuses,
 	}
 }
 
-func (d *Discovery) watchPodEvents(ctx context.Context) {
-	for _, namespace := range d.config.Namespaces {
-		go d.watchPodsInNamespace(ctx, namespace)
+type typedContainerStatus struct {
+	corev1.ContainerStatus
+	containerType string
+}
+
+// podContainerStatuses returns the statuses of the pod's main, init and
+// ephemeral containers, in that order.
+func podContainerStatuses(pod *corev1.Pod) []typedContainerStatus {
+	var statuses []typedContainerStatus
+	for _, group := range []struct {
+		containerType string
+		statuses      []corev1.ContainerStatus
+	}{
+		{ContainerTypeMain, pod.Status.ContainerStatuses},
+		{ContainerTypeInit, pod.Status.InitContainerStatuses},
+		{ContainerTypeEphemeral, pod.Status.EphemeralContainerStatuses},
+	} {
+		for _, status := range group.statuses {
+			statuses = append(statuses, typedContainerStatus{ContainerStatus: status, containerType: group.containerType})
+		}
 	}
+	return statuses
 }
 
-func (d *Discovery) watchPodsInNamespace(ctx context.Context, namespace string) {
-	watchlist := cache.NewListWatchFromClient(
-		d.client.CoreV1().RESTClient(),
-		"pods",
-		namespace,
-		fields.Everything(),
-	)
-
-	_, controller := cache.NewInformer(
-		watchlist,
-		&corev1.Pod{},
-		time.Second*10,
-		cache.ResourceEventHandlerFuncs{
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				oldPod := oldObj.(*corev1.Pod)
-				newPod := newObj.(*corev1.Pod)
-				d.checkForRestarts(oldPod, newPod)
-			},
-		},
-	)
-
-	go controller.Run(d.stopChan)
+// milvusVersion returns the image tag of the pod's Milvus container, which is
+// how both the Helm chart and the operator pin the Milvus release.
+func milvusVersion(pod *corev1.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		if !strings.Contains(container.Image, "milvus") {
+			continue
+		}
+		image := container.Image
+		if at := strings.Index(image, "@"); at != -1 {
+			image = image[:at]
+		}
+		if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+			return image[colon+1:]
+		}
+	}
+	return ""
 }
 
 func (d *Discovery) checkForRestarts(oldPod, newPod *corev1.Pod) {
@@ -284,21 +345,26 @@ func (d *Discovery) checkForRestarts(oldPod, newPod *corev1.Pod) {
 	}
 	klog.V(3).Infof("Checking for restarts: pod %s/%s", newPod.Namespace, newPod.Name)
 
-	for i, newStatus := range newPod.Status.ContainerStatuses {
-		if i >= len(oldPod.Status.ContainerStatuses) {
+	oldRestarts := make(map[string]int32)
+	for _, oldStatus := range podContainerStatuses(oldPod) {
+		oldRestarts[oldStatus.containerType+"/"+oldStatus.Name] = oldStatus.RestartCount
+	}
+
+	for _, status := range podContainerStatuses(newPod) {
+		newStatus := status.ContainerStatus
+		oldCount, exists := oldRestarts[status.containerType+"/"+newStatus.Name]
+		if !exists {
 			continue
 		}
 		
-		oldStatus := oldPod.Status.ContainerStatuses[i]
-		if newStatus.RestartCount > oldStatus.RestartCount {
+		if newStatus.RestartCount > oldCount {
 			klog.Infof("Detected restart for pod %s/%s: %s (old: %d, new: %d)", 
 				newPod.Namespace, newPod.Name, newStatus.Name, 
-				oldStatus.RestartCount, newStatus.RestartCount)
+				oldCount, newStatus.RestartCount)
 			event := d.createRestartEvent(newPod, newStatus)
-			select {
-			case d.restartChan <- event:
+			if chanstats.TrySend("discovery_restarts", d.restartChan, event) {
 				klog.Infof("Sent restart event for pod %s/%s", newPod.Namespace, newPod.Name)
-			default:
+			} else {
 				klog.Warning("Restart event channel is full, dropping event")
 			}
 		}
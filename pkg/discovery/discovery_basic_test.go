@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -230,4 +231,71 @@ func TestBasicPodRestartDetection(t *testing.T) {
 // Helper function for basic restart detection
 func detectBasicPodRestart(oldRestartCount, newRestartCount int32) bool {
 	return newRestartCount > oldRestartCount
-}
\ No newline at end of file
+}
+
+func TestMilvusVersion(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"milvusdb/milvus:v2.4.5", "v2.4.5"},
+		{"registry.local:5000/milvusdb/milvus:v2.3.0-gpu", "v2.3.0-gpu"},
+		{"milvusdb/milvus:v2.4.5@sha256:abcdef", "v2.4.5"},
+		{"registry.local:5000/milvusdb/milvus", ""},
+		{"busybox:1.36", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "milvus", Image: tt.image}},
+				},
+			}
+			if version := milvusVersion(pod); version != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, version)
+			}
+		})
+	}
+}
+
+func TestCreatePodInfoIncludesInitAndEphemeralContainers(t *testing.T) {
+	crashed := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "milvus-querynode-0", Namespace: "milvus"},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "querynode", Ready: true,
+				ContainerID: "containerd://abc123", Image: "milvusdb/milvus:v2.4.5"}},
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "config",
+				RestartCount: 1,
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "Error", FinishedAt: crashed},
+				},
+			}},
+			EphemeralContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "debugger",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: crashed}},
+			}},
+		},
+	}
+
+	info := (&Discovery{}).createPodInfo(pod)
+	if len(info.ContainerStatuses) != 3 {
+		t.Fatalf("expected 3 container statuses, got %+v", info.ContainerStatuses)
+	}
+	for i, expected := range []string{ContainerTypeMain, ContainerTypeInit, ContainerTypeEphemeral} {
+		if info.ContainerStatuses[i].Type != expected {
+			t.Errorf("expected %s to be a %s container, got %q", info.ContainerStatuses[i].Name, expected, info.ContainerStatuses[i].Type)
+		}
+	}
+	if info.RestartCount != 1 || !info.LastRestart.Equal(&crashed) {
+		t.Errorf("expected the init container restart to count, got %d at %v", info.RestartCount, info.LastRestart)
+	}
+	if !info.ContainerStatuses[2].LastTerminatedAt.Equal(&crashed) {
+		t.Errorf("expected the ephemeral container's termination time, got %v", info.ContainerStatuses[2].LastTerminatedAt)
+	}
+	if main := info.ContainerStatuses[0]; main.ContainerID != "abc123" || main.Image != "milvusdb/milvus:v2.4.5" {
+		t.Errorf("expected the container ID without the runtime prefix and the image, got %+v", main)
+	}
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultResyncInterval = 10 * time.Minute
+
+// podInformer caches the pods of a namespace, or of all namespaces when
+// namespace is empty. Discovery scans the cache instead of listing pods
+// from the API server, which it only watches.
+type podInformer struct {
+	namespace string
+	factory   informers.SharedInformerFactory
+	informer  cache.SharedIndexInformer
+	lister    corelisters.PodLister
+
+	adds    atomic.Int64
+	updates atomic.Int64
+	deletes atomic.Int64
+}
+
+// InformerStats describes the pod cache of a namespace.
+type InformerStats struct {
+	Namespace string `json:"namespace"`
+	Pods      int    `json:"pods"`
+	Synced    bool   `json:"synced"`
+	// Events received, updates counting resyncs
+	Adds    int64 `json:"adds"`
+	Updates int64 `json:"updates"`
+	Deletes int64 `json:"deletes"`
+}
+
+// newPodInformers creates a shared informer factory per watched namespace.
+// The configured label selector is sent with the list and watch requests,
+// so pods outside it never reach the agent.
+func (d *Discovery) newPodInformers() []*podInformer {
+	resync := d.config.ResyncInterval
+	if resync <= 0 {
+		resync = defaultResyncInterval
+	}
+
+	var podInformers []*podInformer
+	for _, namespace := range d.namespaces() {
+		factory := informers.NewSharedInformerFactoryWithOptions(d.client, resync,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = d.config.LabelSelector
+			}))
+		pods := factory.Core().V1().Pods()
+		p := &podInformer{
+			namespace: namespace,
+			factory:   factory,
+			informer:  pods.Informer(),
+			lister:    pods.Lister(),
+		}
+		p.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(interface{}) {
+				p.adds.Add(1)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				p.updates.Add(1)
+				oldPod, oldOK := oldObj.(*corev1.Pod)
+				newPod, newOK := newObj.(*corev1.Pod)
+				if oldOK && newOK {
+					d.checkForRestarts(oldPod, newPod)
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				p.deletes.Add(1)
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if pod, ok := obj.(*corev1.Pod); ok {
+					d.handlePodDeleted(pod, p.informer.GetStore().List(), time.Now())
+				}
+			},
+		})
+		podInformers = append(podInformers, p)
+	}
+	return podInformers
+}
+
+func (d *Discovery) startInformers(stop <-chan struct{}) {
+	for _, informer := range d.informers {
+		informer.factory.Start(stop)
+	}
+}
+
+// InformerStats returns the state of the pod caches, one per watched
+// namespace. A nil *Discovery has none.
+func (d *Discovery) InformerStats() []InformerStats {
+	if d == nil {
+		return nil
+	}
+
+	stats := make([]InformerStats, 0, len(d.informers))
+	for _, p := range d.informers {
+		stats = append(stats, InformerStats{
+			Namespace: p.namespace,
+			Pods:      len(p.informer.GetStore().ListKeys()),
+			Synced:    p.informer.HasSynced(),
+			Adds:      p.adds.Load(),
+			Updates:   p.updates.Load(),
+			Deletes:   p.deletes.Load(),
+		})
+	}
+	return stats
+}
+
+// Pod returns the cached pod, or nil when it is not cached or d is nil.
+func (d *Discovery) Pod(namespace, name string) *corev1.Pod {
+	if d == nil {
+		return nil
+	}
+
+	for _, p := range d.informers {
+		if p.namespace != "" && p.namespace != namespace {
+			continue
+		}
+		if pod, err := p.lister.Pods(namespace).Get(name); err == nil {
+			return pod
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestPodInformersFilterAndFollowPods(t *testing.T) {
+	pod := milvusPod("prod-querynode-0", "prod")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "querynode"}}
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "milvus", Labels: map[string]string{"app": "web"}}}
+	client := fake.NewSimpleClientset(pod, other)
+	d := New(client, &config.DiscoveryConfig{
+		Namespaces:        []string{"milvus"},
+		LabelSelector:     "app.kubernetes.io/name=milvus",
+		HelmReleaseLabels: []string{"app.kubernetes.io/name=milvus"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.startInformers(ctx.Done())
+	d.waitForCacheSync(ctx)
+
+	stats := d.InformerStats()
+	if len(stats) != 1 || !stats[0].Synced || stats[0].Pods != 1 || stats[0].Adds != 1 {
+		t.Fatalf("expected a synced cache of the Milvus pod only, got %+v", stats)
+	}
+	if cached := d.Pod("milvus", pod.Name); cached == nil || cached.UID != pod.UID {
+		t.Errorf("expected the pod from the cache, got %v", cached)
+	}
+	if d.Pod("milvus", other.Name) != nil {
+		t.Error("expected pods outside the selector not to be cached")
+	}
+
+	d.discoverInstances(ctx)
+	if instance, exists := d.GetInstances()["milvus/prod"]; !exists || len(instance.Pods) != 1 {
+		t.Fatalf("expected the instance to be discovered from the cache, got %v", d.GetInstances())
+	}
+
+	restarted := pod.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	if _, err := client.CoreV1().Pods("milvus").UpdateStatus(ctx, restarted, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-d.GetRestartChannel():
+		if event.PodName != pod.Name || event.InstanceName != "prod" {
+			t.Errorf("unexpected restart event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a restart event from the watch")
+	}
+
+	if err := client.CoreV1().Pods("milvus").Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for d.GetInstances()["milvus/prod"].Status != InstanceStatusTerminated {
+		select {
+		case <-deadline:
+			t.Fatal("expected the deletion to terminate the instance")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if stats := d.InformerStats(); stats[0].Updates != 1 || stats[0].Deletes != 1 {
+		t.Errorf("expected an update and a delete to be counted, got %+v", stats)
+	}
+
+	var nilDiscovery *Discovery
+	if nilDiscovery.InformerStats() != nil || nilDiscovery.Pod("milvus", pod.Name) != nil {
+		t.Error("expected no stats without discovery")
+	}
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// registeredInstance is a config.RegisteredInstance with its selector
+// parsed.
+type registeredInstance struct {
+	name      string
+	namespace string
+	selector  labels.Selector
+}
+
+func parseRegisteredInstances(instances []config.RegisteredInstance) []registeredInstance {
+	var registered []registeredInstance
+	for _, instance := range instances {
+		selector, err := labels.Parse(instance.Selector)
+		if err != nil {
+			klog.Errorf("Ignoring registered instance %s/%s: invalid selector: %v", instance.Namespace, instance.Name, err)
+			continue
+		}
+		registered = append(registered, registeredInstance{
+			name:      instance.Name,
+			namespace: instance.Namespace,
+			selector:  selector,
+		})
+	}
+	return registered
+}
+
+// registeredInstanceOf returns the name of the registered instance the pod
+// belongs to, or "" when it belongs to none.
+func (d *Discovery) registeredInstanceOf(pod *corev1.Pod) string {
+	for _, instance := range d.registered {
+		if instance.namespace == pod.Namespace && instance.selector.Matches(labels.Set(pod.Labels)) {
+			return instance.name
+		}
+	}
+	return ""
+}
+
+// namespaces returns the configured namespaces followed by those only
+// registered instances live in.
+func (d *Discovery) namespaces() []string {
+	namespaces := append([]string{}, d.config.Namespaces...)
+	seen := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		seen[namespace] = true
+	}
+	for _, instance := range d.registered {
+		if !seen[instance.namespace] {
+			seen[instance.namespace] = true
+			namespaces = append(namespaces, instance.namespace)
+		}
+	}
+	return namespaces
+}
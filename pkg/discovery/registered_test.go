@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestRegisteredInstances(t *testing.T) {
+	pod := func(namespace, name string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	}
+	client := fake.NewSimpleClientset(
+		pod("vector", "search-query-0", map[string]string{"app": "vectordb", "tier": "query"}),
+		pod("vector", "search-data-0", map[string]string{"app": "vectordb", "tier": "data"}),
+		pod("vector", "search-etcd-0", map[string]string{"app": "vectordb", "tier": "etcd"}),
+		pod("default", "other-0", map[string]string{"app": "vectordb", "tier": "query"}),
+	)
+	d := New(client, &config.DiscoveryConfig{
+		Namespaces: []string{"default"},
+		Instances: []config.RegisteredInstance{
+			{Name: "search", Namespace: "vector", Selector: "app=vectordb,tier in (query,data)"},
+			{Name: "broken", Namespace: "vector", Selector: "tier in ("},
+		},
+	})
+
+	if namespaces := d.namespaces(); len(namespaces) != 2 || namespaces[1] != "vector" {
+		t.Errorf("expected the registered instance's namespace to be watched, got %v", namespaces)
+	}
+	if len(d.registered) != 1 {
+		t.Errorf("expected the invalid selector to be ignored, got %d registered instances", len(d.registered))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.startInformers(ctx.Done())
+	d.waitForCacheSync(ctx)
+	d.discoverInstances(ctx)
+	instances := d.GetInstances()
+	instance, exists := instances["vector/search"]
+	if !exists {
+		t.Fatalf("expected the registered instance to be discovered, got %v", instances)
+	}
+	if instance.Type != DeploymentTypeRegistered || len(instance.Pods) != 2 {
+		t.Errorf("expected a registered instance with the query and data pods, got %s with %+v", instance.Type, instance.Pods)
+	}
+	if _, exists := instances["default/other-0"]; exists {
+		t.Error("expected the selector to apply to the registered namespace only")
+	}
+}
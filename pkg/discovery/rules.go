@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// podMatch is the instance a pod was identified as part of, and how.
+type podMatch struct {
+	deploymentType DeploymentType
+	instance       string
+	rule           string
+}
+
+// instanceRule is a config.InstanceRule compiled.
+type instanceRule struct {
+	name          string
+	selector      labels.Selector
+	namePattern   *regexp.Regexp
+	ownerKinds    map[string]bool
+	instanceLabel string
+}
+
+func compileRules(rules []config.InstanceRule) []instanceRule {
+	var compiled []instanceRule
+	for _, rule := range rules {
+		selector, err := labels.Parse(rule.Selector)
+		if err != nil {
+			klog.Errorf("Ignoring discovery rule %s: invalid selector: %v", rule.Name, err)
+			continue
+		}
+		var namePattern *regexp.Regexp
+		if rule.NamePattern != "" {
+			if namePattern, err = regexp.Compile(rule.NamePattern); err != nil {
+				klog.Errorf("Ignoring discovery rule %s: invalid name pattern: %v", rule.Name, err)
+				continue
+			}
+		}
+		ownerKinds := make(map[string]bool, len(rule.OwnerKinds))
+		for _, kind := range rule.OwnerKinds {
+			ownerKinds[kind] = true
+		}
+		compiled = append(compiled, instanceRule{
+			name:          rule.Name,
+			selector:      selector,
+			namePattern:   namePattern,
+			ownerKinds:    ownerKinds,
+			instanceLabel: rule.InstanceLabel,
+		})
+	}
+	return compiled
+}
+
+// matchPod identifies the instance of a pod by, in order, the registered
+// instances, the custom rules and the helm and operator labels. It returns
+// nil for pods that aren't Milvus.
+func (d *Discovery) matchPod(pod *corev1.Pod) *podMatch {
+	if name := d.registeredInstanceOf(pod); name != "" {
+		return &podMatch{deploymentType: DeploymentTypeRegistered, instance: name, rule: "instances: " + name}
+	}
+	for _, rule := range d.rules {
+		if name, ok := rule.match(pod); ok {
+			return &podMatch{deploymentType: DeploymentTypeCustom, instance: name, rule: "rules: " + rule.name}
+		}
+	}
+
+	deploymentType, label := d.getDeploymentType(pod)
+	if deploymentType == "" {
+		return nil
+	}
+	instance := d.extractInstanceName(pod, deploymentType)
+	if instance == "" {
+		return nil
+	}
+	key := "helmReleaseLabels: "
+	if deploymentType == string(DeploymentTypeOperator) {
+		key = "operatorLabels: "
+	}
+	return &podMatch{deploymentType: DeploymentType(deploymentType), instance: instance, rule: key + label}
+}
+
+// match reports whether the pod matches the rule, and the name of its
+// instance.
+func (r *instanceRule) match(pod *corev1.Pod) (string, bool) {
+	if !r.selector.Matches(labels.Set(pod.Labels)) {
+		return "", false
+	}
+	var groups []string
+	if r.namePattern != nil {
+		if groups = r.namePattern.FindStringSubmatch(pod.Name); groups == nil {
+			return "", false
+		}
+	}
+	if len(r.ownerKinds) > 0 && !r.ownerKinds[controllerKind(pod)] {
+		return "", false
+	}
+
+	if name := pod.Labels[r.instanceLabel]; r.instanceLabel != "" && name != "" {
+		return name, true
+	}
+	if r.namePattern != nil {
+		if index := r.namePattern.SubexpIndex("instance"); index > 0 && groups[index] != "" {
+			return groups[index], true
+		}
+	}
+	return pod.Name, true
+}
+
+// controllerKind returns the kind of the pod's controller. Pods of a
+// Deployment are owned by its ReplicaSets, which are told apart from bare
+// ones by the pod-template-hash label.
+func controllerKind(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" && pod.Labels["pod-template-hash"] != "" {
+			return "Deployment"
+		}
+		return owner.Kind
+	}
+	return ""
+}
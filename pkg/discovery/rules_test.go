@@ -0,0 +1,89 @@
+package discovery
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestMatchPodRules(t *testing.T) {
+	controller := true
+	owned := func(kind string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: "owner", Controller: &controller}}
+	}
+	d := New(nil, &config.DiscoveryConfig{
+		HelmReleaseLabels: []string{"app.kubernetes.io/name=milvus"},
+		Instances:         []config.RegisteredInstance{{Name: "search", Namespace: "vector", Selector: "app=vectordb"}},
+		Rules: []config.InstanceRule{
+			{Name: "kustomize", Selector: "app=vectordb", InstanceLabel: "vectordb/instance"},
+			{Name: "manifests", NamePattern: `^(?P<instance>[a-z]+)-milvus-`, OwnerKinds: []string{"StatefulSet", "Deployment"}},
+			{Name: "broken", NamePattern: `(`},
+		},
+	})
+	if len(d.rules) != 2 {
+		t.Fatalf("expected the invalid rule to be ignored, got %d rules", len(d.rules))
+	}
+
+	for _, tc := range []struct {
+		name     string
+		pod      corev1.Pod
+		instance string
+		rule     string
+	}{
+		{
+			name: "registered instances come first",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "vector", Name: "search-0",
+				Labels: map[string]string{"app": "vectordb", "vectordb/instance": "other"}}},
+			instance: "search", rule: "instances: search",
+		},
+		{
+			name: "instance label",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vectordb-0",
+				Labels: map[string]string{"app": "vectordb", "vectordb/instance": "prod"}}},
+			instance: "prod", rule: "rules: kustomize",
+		},
+		{
+			name: "pod name without instance label",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vectordb-0",
+				Labels: map[string]string{"app": "vectordb"}}},
+			instance: "vectordb-0", rule: "rules: kustomize",
+		},
+		{
+			name: "name pattern group and statefulset owner",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "staging-milvus-querynode-0",
+				OwnerReferences: owned("StatefulSet")}},
+			instance: "staging", rule: "rules: manifests",
+		},
+		{
+			name: "deployment owner",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "staging-milvus-proxy-5d9f8-x2x7q",
+				Labels: map[string]string{"pod-template-hash": "5d9f8"}, OwnerReferences: owned("ReplicaSet")}},
+			instance: "staging", rule: "rules: manifests",
+		},
+		{
+			name: "owner kind filtered out",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "staging-milvus-migrate-abcde",
+				OwnerReferences: owned("Job")}},
+		},
+		{
+			name: "helm labels after the rules",
+			pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "prod-milvus-proxy-0",
+				Labels: map[string]string{"app.kubernetes.io/name": "milvus", "app.kubernetes.io/instance": "prod"}}},
+			instance: "prod", rule: "helmReleaseLabels: app.kubernetes.io/name=milvus",
+		},
+	} {
+		match := d.matchPod(&tc.pod)
+		if tc.instance == "" {
+			if match != nil {
+				t.Errorf("%s: expected no match, got %+v", tc.name, match)
+			}
+			continue
+		}
+		if match == nil || match.instance != tc.instance || match.rule != tc.rule {
+			t.Errorf("%s: expected %s by %q, got %+v", tc.name, tc.instance, tc.rule, match)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// terminatedRetention is how long a terminated instance stays listed, so
+// reports can still show what happened to it.
+const terminatedRetention = time.Hour
+
+// RecordCleanup notes that the agent uninstalled an instance. The
+// instance's termination, once its pods are gone, links to the cleanup.
+func (d *Discovery) RecordCleanup(namespace, name, reason string) {
+	if d == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	cleanup := Cleanup{Reason: reason, At: metav1.Now()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if instance, exists := d.instances[key]; exists && instance.Status == InstanceStatusTerminated {
+		// The pods went before the cleanup finished.
+		terminated := *instance
+		terminated.Cleanup = &cleanup
+		d.instances[key] = &terminated
+		return
+	}
+	d.cleanups[key] = cleanup
+}
+
+// handlePodDeleted updates the instance of a deleted pod from the pods
+// still in the informer's store, and marks it terminated when none are left.
+// Checking the store rather than the last scan keeps a pod replaced during
+// a rolling update from terminating its instance.
+func (d *Discovery) handlePodDeleted(pod *corev1.Pod, remaining []interface{}, now time.Time) {
+	instance := d.identifyMilvusInstance(pod)
+	if instance == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", instance.Namespace, instance.Name)
+
+	var pods []PodInfo
+	for _, obj := range remaining {
+		other, ok := obj.(*corev1.Pod)
+		if !ok || other.Namespace != pod.Namespace || other.Name == pod.Name || !d.isPodOfInstance(other, instance) {
+			continue
+		}
+		pods = append(pods, d.createPodInfo(other))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	existing, exists := d.instances[key]
+	if !exists || existing.Status == InstanceStatusTerminated {
+		return
+	}
+	if len(pods) > 0 {
+		updated := *existing
+		updated.Pods = pods
+		d.instances[key] = &updated
+		return
+	}
+	d.terminate(key, existing, now)
+}
+
+func (d *Discovery) isPodOfInstance(pod *corev1.Pod, instance *MilvusInstance) bool {
+	match := d.matchPod(pod)
+	return match != nil && match.instance == instance.Name
+}
+
+// terminateMissing marks the instances of namespace that a scan no longer
+// found as terminated, in case the watch missed their deletion, and drops
+// those terminated for longer than terminatedRetention. d.mu must be held.
+func (d *Discovery) terminateMissing(namespace string, found map[string]*MilvusInstance, now time.Time) {
+	for key, instance := range d.instances {
+		if instance.Namespace != namespace {
+			continue
+		}
+		if instance.Status == InstanceStatusTerminated {
+			if now.Sub(instance.TerminatedAt.Time) > terminatedRetention {
+				delete(d.instances, key)
+			}
+			continue
+		}
+		if _, exists := found[key]; !exists {
+			d.terminate(key, instance, now)
+		}
+	}
+	for key, cleanup := range d.cleanups {
+		if now.Sub(cleanup.At.Time) > terminatedRetention {
+			delete(d.cleanups, key)
+		}
+	}
+}
+
+// terminate replaces the instance with a terminated copy. d.mu must be held.
+func (d *Discovery) terminate(key string, instance *MilvusInstance, now time.Time) {
+	terminated := *instance
+	terminated.Status = InstanceStatusTerminated
+	terminated.Pods = []PodInfo{}
+	terminatedAt := metav1.NewTime(now)
+	terminated.TerminatedAt = &terminatedAt
+	if cleanup, exists := d.cleanups[key]; exists {
+		terminated.Cleanup = &cleanup
+		delete(d.cleanups, key)
+		klog.Infof("Milvus instance %s terminated after cleanup: %s", key, cleanup.Reason)
+	} else {
+		klog.Infof("Milvus instance %s terminated", key)
+	}
+	d.instances[key] = &terminated
+}
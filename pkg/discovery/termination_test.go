@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func milvusPod(name, release string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "milvus",
+			Labels: map[string]string{
+				"app.kubernetes.io/name":     "milvus",
+				"app.kubernetes.io/instance": release,
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func newTestDiscovery(pods ...*corev1.Pod) *Discovery {
+	d := &Discovery{
+		config:    &config.DiscoveryConfig{HelmReleaseLabels: []string{"app.kubernetes.io/name=milvus"}},
+		instances: make(map[string]*MilvusInstance),
+		cleanups:  make(map[string]Cleanup),
+	}
+	for _, pod := range pods {
+		instance := d.identifyMilvusInstance(pod)
+		key := instance.Namespace + "/" + instance.Name
+		if existing, exists := d.instances[key]; exists {
+			instance = existing
+		}
+		instance.Pods = append(instance.Pods, d.createPodInfo(pod))
+		d.instances[key] = instance
+	}
+	return d
+}
+
+func TestPodDeletionTerminatesInstance(t *testing.T) {
+	proxy, querynode := milvusPod("prod-proxy-0", "prod"), milvusPod("prod-querynode-0", "prod")
+	d := newTestDiscovery(proxy, querynode, milvusPod("dev-standalone-0", "dev"))
+	before := d.GetInstances()["milvus/prod"]
+	now := time.Now()
+
+	// Another pod of the instance is still in the store.
+	d.handlePodDeleted(proxy, []interface{}{querynode, milvusPod("dev-standalone-0", "dev")}, now)
+	instance := d.GetInstances()["milvus/prod"]
+	if instance.Status != InstanceStatusRunning || len(instance.Pods) != 1 || instance.Pods[0].Name != "prod-querynode-0" {
+		t.Fatalf("expected the instance to keep its remaining pod, got %+v", instance)
+	}
+	if len(before.Pods) != 2 {
+		t.Errorf("expected the earlier snapshot to stay unchanged, got %d pods", len(before.Pods))
+	}
+
+	d.RecordCleanup("milvus", "prod", "crash loop")
+	d.handlePodDeleted(querynode, []interface{}{milvusPod("dev-standalone-0", "dev")}, now)
+	instance = d.GetInstances()["milvus/prod"]
+	if instance.Status != InstanceStatusTerminated || instance.TerminatedAt == nil || len(instance.Pods) != 0 {
+		t.Fatalf("expected the instance to be terminated, got %+v", instance)
+	}
+	if instance.Cleanup == nil || instance.Cleanup.Reason != "crash loop" {
+		t.Errorf("expected the termination linked to the cleanup, got %+v", instance.Cleanup)
+	}
+	if len(d.cleanups) != 0 {
+		t.Errorf("expected the linked cleanup to be consumed, got %v", d.cleanups)
+	}
+	if dev := d.GetInstances()["milvus/dev"]; dev.Status != InstanceStatusRunning {
+		t.Errorf("expected other instances to be unaffected, got %s", dev.Status)
+	}
+}
+
+func TestCleanupRecordedAfterTermination(t *testing.T) {
+	pod := milvusPod("prod-standalone-0", "prod")
+	d := newTestDiscovery(pod)
+	d.handlePodDeleted(pod, nil, time.Now())
+	d.RecordCleanup("milvus", "prod", "crash loop")
+
+	if instance := d.GetInstances()["milvus/prod"]; instance.Cleanup == nil {
+		t.Errorf("expected a cleanup finishing after the pods went to be linked, got %+v", instance)
+	}
+
+	var nilDiscovery *Discovery
+	nilDiscovery.RecordCleanup("milvus", "prod", "crash loop")
+}
+
+func TestScanTerminatesMissingInstances(t *testing.T) {
+	d := newTestDiscovery(milvusPod("prod-standalone-0", "prod"), milvusPod("dev-standalone-0", "dev"))
+	now := time.Now()
+
+	d.terminateMissing("milvus", map[string]*MilvusInstance{"milvus/dev": d.instances["milvus/dev"]}, now)
+	if status := d.instances["milvus/prod"].Status; status != InstanceStatusTerminated {
+		t.Fatalf("expected the instance missing from the scan to be terminated, got %s", status)
+	}
+	if status := d.instances["milvus/dev"].Status; status != InstanceStatusRunning {
+		t.Errorf("expected the found instance to keep running, got %s", status)
+	}
+
+	d.terminateMissing("other", nil, now.Add(2*terminatedRetention))
+	if _, exists := d.instances["milvus/prod"]; !exists {
+		t.Error("expected a scan of another namespace to leave the instance alone")
+	}
+	d.terminateMissing("milvus", map[string]*MilvusInstance{"milvus/dev": d.instances["milvus/dev"]}, now.Add(2*terminatedRetention))
+	if _, exists := d.instances["milvus/prod"]; exists {
+		t.Error("expected the terminated instance to be dropped after the retention")
+	}
+}
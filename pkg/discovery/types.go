@@ -8,11 +8,25 @@ type MilvusInstance struct {
 	Name        string            `json:"name"`
 	Namespace   string            `json:"namespace"`
 	Type        DeploymentType    `json:"type"`
+	// The discovery config entry that identified the instance, such as
+	// "helmReleaseLabels: app.kubernetes.io/name=milvus" or "rules: kustomize"
+	MatchedRule string            `json:"matchedRule,omitempty"`
 	Labels      map[string]string `json:"labels"`
 	Annotations map[string]string `json:"annotations"`
 	Status      InstanceStatus    `json:"status"`
 	CreatedAt   metav1.Time       `json:"createdAt"`
 	Pods        []PodInfo         `json:"pods"`
+	// When the last pod of the instance was deleted
+	TerminatedAt *metav1.Time `json:"terminatedAt,omitempty"`
+	// The agent's cleanup that uninstalled the instance, if it did
+	Cleanup *Cleanup `json:"cleanup,omitempty"`
+}
+
+// Cleanup records that the agent uninstalled an instance, so its
+// termination can be told apart from a manual uninstall.
+type Cleanup struct {
+	Reason string      `json:"reason"`
+	At     metav1.Time `json:"at"`
 }
 
 type DeploymentType string
@@ -20,6 +34,10 @@ type DeploymentType string
 const (
 	DeploymentTypeHelm     DeploymentType = "helm"
 	DeploymentTypeOperator DeploymentType = "operator"
+	// Registered by hand in the discovery config
+	DeploymentTypeRegistered DeploymentType = "registered"
+	// Matched by a custom rule of the discovery config
+	DeploymentTypeCustom DeploymentType = "custom"
 )
 
 type InstanceStatus string
@@ -29,6 +47,8 @@ const (
 	InstanceStatusFailed     InstanceStatus = "failed"
 	InstanceStatusPending    InstanceStatus = "pending"
 	InstanceStatusTerminating InstanceStatus = "terminating"
+	// All pods of the instance are gone
+	InstanceStatusTerminated InstanceStatus = "terminated"
 )
 
 type PodInfo struct {
@@ -37,15 +57,32 @@ type PodInfo struct {
 	Status          string    `json:"status"`
 	RestartCount    int32     `json:"restartCount"`
 	LastRestart     metav1.Time `json:"lastRestart"`
+	Component       string    `json:"component,omitempty"`
+	MilvusVersion   string    `json:"milvusVersion,omitempty"`
 	ContainerStatuses []ContainerStatusInfo `json:"containerStatuses"`
 }
 
+// Container types, as in the pod spec's containers, initContainers and
+// ephemeralContainers. Sidecars are init containers that keep running.
+const (
+	ContainerTypeMain      = "main"
+	ContainerTypeInit      = "init"
+	ContainerTypeEphemeral = "ephemeral"
+)
+
 type ContainerStatusInfo struct {
 	Name         string `json:"name"`
+	Type         string `json:"type"`
 	RestartCount int32  `json:"restartCount"`
 	Ready        bool   `json:"ready"`
+	// Runtime ID of the current container, without the runtime prefix
+	ContainerID  string `json:"containerId,omitempty"`
+	Image        string `json:"image,omitempty"`
+	ImageID      string `json:"imageId,omitempty"`
 	LastTerminationReason string `json:"lastTerminationReason,omitempty"`
 	LastTerminationMessage string `json:"lastTerminationMessage,omitempty"`
+	// When the container last terminated, whether or not it was restarted.
+	LastTerminatedAt metav1.Time `json:"lastTerminatedAt,omitempty"`
 }
 
 type RestartEvent struct {
@@ -0,0 +1,48 @@
+// Package fanout copies events from one channel to several consumers.
+//
+// Components expose a single event channel, and a value received from a Go
+// channel is delivered to exactly one reader. Wiring the same channel into
+// more than one consumer therefore splits the events between them instead of
+// giving each consumer the full stream.
+package fanout
+
+import "context"
+
+// Split starts a goroutine that forwards every value received from in to n
+// new channels. Sends block, so a slow consumer applies backpressure to the
+// others rather than silently losing events. The outputs are closed when in
+// is closed; when ctx is done forwarding stops and consumers are expected to
+// notice the cancellation themselves.
+func Split[T any](ctx context.Context, in <-chan T, n, buffer int) []<-chan T {
+	outs := make([]chan T, n)
+	result := make([]<-chan T, n)
+	for i := range outs {
+		outs[i] = make(chan T, buffer)
+		result[i] = outs[i]
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case value, ok := <-in:
+				if !ok {
+					for _, out := range outs {
+						close(out)
+					}
+					return
+				}
+				for _, out := range outs {
+					select {
+					case out <- value:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return result
+}
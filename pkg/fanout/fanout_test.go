@@ -0,0 +1,36 @@
+package fanout
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSplitDeliversToEveryConsumer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan int)
+	outs := Split(ctx, in, 3, 10)
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	for n, out := range outs {
+		var received []int
+		for value := range out {
+			received = append(received, value)
+		}
+		if len(received) != 5 {
+			t.Fatalf("consumer %d: expected 5 values, got %v", n, received)
+		}
+		for i, value := range received {
+			if value != i {
+				t.Errorf("consumer %d: expected %d at position %d, got %d", n, i, i, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package ghissues finds the GitHub issues that mention the crash site of a
+// core, so its AI analysis links real reports of the crash rather than
+// issues the model made up. The distinctive top stack frames are searched
+// for in the configured repositories, milvus-io/milvus by default.
+package ghissues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultAPIURL     = "https://api.github.com"
+	defaultFrames     = 3
+	defaultMaxResults = 5
+	defaultCacheTTL   = time.Hour
+	defaultTimeout    = 10 * time.Second
+
+	// Frames looked at for distinctive ones.
+	scannedFrames = 20
+	maxCacheSize  = 1000
+	// GitHub rejects queries longer than 256 characters.
+	maxQueryLength  = 256
+	maxResponseSize = 8 << 20
+)
+
+// Frames of these are on the stacks of unrelated crashes.
+var genericPrefixes = []string{
+	"__", "_dl_", "_Unwind", "std::", "__gnu_cxx::", "runtime.", "raise", "abort", "gsignal",
+	"pthread_", "start_thread", "clone", "folly::symbolizer", "google::", "??",
+}
+
+type cached struct {
+	issues []string
+	at     time.Time
+}
+
+// Matcher searches the issues of the configured repositories. A nil
+// Matcher finds nothing.
+type Matcher struct {
+	config *config.GitHubIssuesConfig
+	client *http.Client
+	apiURL string
+	token  string
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// New creates the matcher, or returns nil when it is disabled.
+func New(cfg *config.GitHubIssuesConfig) (*Matcher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client, err := httpclient.New("GitHub", cfg.Proxy, cfg.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	return &Matcher{
+		config: cfg,
+		client: client,
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		cache:  make(map[string]cached),
+	}, nil
+}
+
+// Match returns the URLs of the issues mentioning the core's distinctive
+// top frames, best match first. Cores of a crash group share their issues
+// for the cache TTL, so a crash loop costs one search.
+func (m *Matcher) Match(ctx context.Context, coredump *collector.CoredumpFile, results *collector.AnalysisResults) ([]string, error) {
+	if m == nil || results == nil {
+		return nil, nil
+	}
+	_, frames := crashgroup.Fingerprint(coredump.Executable, coredump.Signal, results.StackTrace, scannedFrames)
+	frames = m.distinctive(frames)
+	if len(frames) == 0 {
+		return nil, nil
+	}
+
+	key := coredump.Fingerprint
+	if key == "" {
+		key = strings.Join(frames, "|")
+	}
+	if issues, found := m.cached(key, time.Now()); found {
+		return issues, nil
+	}
+	issues, err := m.search(ctx, m.query(frames))
+	if err != nil {
+		return nil, err
+	}
+	m.store(key, issues, time.Now())
+	return issues, nil
+}
+
+func (m *Matcher) distinctive(frames []string) []string {
+	limit := m.config.Frames
+	if limit <= 0 {
+		limit = defaultFrames
+	}
+	var picked []string
+	for _, frame := range frames {
+		if generic(frame) || contains(picked, frame) {
+			continue
+		}
+		picked = append(picked, frame)
+		if len(picked) == limit {
+			break
+		}
+	}
+	return picked
+}
+
+func generic(frame string) bool {
+	for _, prefix := range genericPrefixes {
+		if strings.HasPrefix(frame, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// query searches the issues of the repositories for any of the frames,
+// leaving out the last frames when the query would be too long.
+func (m *Matcher) query(frames []string) string {
+	qualifiers := " is:issue"
+	for _, repo := range m.config.Repos {
+		qualifiers += " repo:" + repo
+	}
+	terms := strconv.Quote(frames[0])
+	for _, frame := range frames[1:] {
+		term := " OR " + strconv.Quote(frame)
+		if len(terms)+len(term)+len(qualifiers) > maxQueryLength {
+			break
+		}
+		terms += term
+	}
+	return terms + qualifiers
+}
+
+type searchResponse struct {
+	Items []struct {
+		HTMLURL     string    `json:"html_url"`
+		PullRequest *struct{} `json:"pull_request"`
+	} `json:"items"`
+}
+
+func (m *Matcher) search(ctx context.Context, query string) ([]string, error) {
+	maxResults := m.config.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultMaxResults
+	}
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("per_page", strconv.Itoa(maxResults))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.apiURL+"/search/issues?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if m.token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.token)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search GitHub issues: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return nil, fmt.Errorf("GitHub search rate limit exceeded until %s", resetTime(resp.Header.Get("X-RateLimit-Reset")))
+		}
+		return nil, fmt.Errorf("GitHub returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var response searchResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	issues := []string{}
+	for _, item := range response.Items {
+		// is:issue already leaves out pull requests on github.com.
+		if item.PullRequest == nil && len(issues) < maxResults {
+			issues = append(issues, item.HTMLURL)
+		}
+	}
+	return issues, nil
+}
+
+func resetTime(value string) string {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "unknown"
+	}
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+}
+
+func (m *Matcher) cached(key string, now time.Time) ([]string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, found := m.cache[key]
+	if !found || now.Sub(entry.at) > m.cacheTTL() {
+		return nil, false
+	}
+	return append([]string(nil), entry.issues...), true
+}
+
+func (m *Matcher) store(key string, issues []string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.cache) >= maxCacheSize {
+		oldest := ""
+		for k, entry := range m.cache {
+			if oldest == "" || entry.at.Before(m.cache[oldest].at) {
+				oldest = k
+			}
+		}
+		delete(m.cache, oldest)
+	}
+	m.cache[key] = cached{issues: issues, at: now}
+}
+
+func (m *Matcher) cacheTTL() time.Duration {
+	if m.config.CacheTTL > 0 {
+		return m.config.CacheTTL
+	}
+	return defaultCacheTTL
+}
@@ -0,0 +1,94 @@
+package ghissues
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+const stack = `#0  0x00007f in __pthread_kill_implementation (threadid=1) at pthread_kill.c:44
+#1  0x00007f in raise (sig=6) at raise.c:26
+#2  0x00007f in abort () at abort.c:79
+#3  0x00007f in milvus::segcore::SegmentSealedImpl::Search (this=0x0) at SegmentSealedImpl.cpp:512
+#4  0x00007f in milvus::query::ExecPlanNodeVisitor::visit (node=...) at ExecPlanNodeVisitor.cpp:88
+#5  0x00007f in std::function<void ()>::operator() (this=0x1) at std_function.h:591
+#6  0x00007f in milvus::query::ExecPlanNodeVisitor::visit (node=...) at ExecPlanNodeVisitor.cpp:88
+#7  0x00007f in AsyncSearch (c_plan=0x2) at segment_c.cpp:102
+#8  0x00007f in milvus::ThreadPool::Worker (this=0x3) at ThreadPool.cpp:40
+`
+
+func TestMatchSearchesDistinctiveFrames(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("q"))
+		if r.URL.Path != "/search/issues" || r.Header.Get("Authorization") != "Bearer ghp_test" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"items": [
+			{"html_url": "https://github.com/milvus-io/milvus/issues/31337"},
+			{"html_url": "https://github.com/milvus-io/milvus/pull/31400", "pull_request": {}},
+			{"html_url": "https://github.com/milvus-io/milvus/issues/29001"}]}`))
+	}))
+	defer server.Close()
+
+	matcher, err := New(&config.GitHubIssuesConfig{
+		Enabled: true,
+		Repos:   []string{"milvus-io/milvus", "milvus-io/knowhere"},
+		Token:   "ghp_test",
+		APIURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coredump := &collector.CoredumpFile{Executable: "milvus", Signal: 6, Fingerprint: "f00d"}
+	results := &collector.AnalysisResults{StackTrace: stack}
+	issues, err := matcher.Match(context.Background(), coredump, results)
+	if err != nil {
+		t.Fatalf("Match failed: %v", err)
+	}
+	if len(issues) != 2 || issues[0] != "https://github.com/milvus-io/milvus/issues/31337" {
+		t.Errorf("expected the two issues without the pull request, got %v", issues)
+	}
+
+	expected := `"milvus::segcore::SegmentSealedImpl::Search" OR "milvus::query::ExecPlanNodeVisitor::visit" OR "AsyncSearch"` +
+		` is:issue repo:milvus-io/milvus repo:milvus-io/knowhere`
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("expected the distinctive frames to be searched, got %q", queries)
+	}
+
+	// Later cores of the crash group reuse the search.
+	if issues, err := matcher.Match(context.Background(), coredump, results); err != nil || len(issues) != 2 || len(queries) != 1 {
+		t.Errorf("expected the cached issues, got %v, %v after %d searches", issues, err, len(queries))
+	}
+}
+
+func TestMatchReportsRateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1714521600")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	matcher, err := New(&config.GitHubIssuesConfig{Enabled: true, Repos: []string{"milvus-io/milvus"}, APIURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = matcher.Match(context.Background(), &collector.CoredumpFile{}, &collector.AnalysisResults{StackTrace: stack})
+	if err == nil || !strings.Contains(err.Error(), "rate limit exceeded until 2024-05-01T00:00:00Z") {
+		t.Errorf("expected the rate limit to be reported, got %v", err)
+	}
+
+	// Stacks of nothing but libc frames have nothing to search for.
+	issues, err := matcher.Match(context.Background(), &collector.CoredumpFile{},
+		&collector.AnalysisResults{StackTrace: "#0  0x00007f in raise (sig=6) at raise.c:26\n"})
+	if err != nil || issues != nil {
+		t.Errorf("expected no search for generic frames, got %v, %v", issues, err)
+	}
+}
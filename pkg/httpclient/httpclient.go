@@ -0,0 +1,139 @@
+// Package httpclient builds the http.Clients the agent uses to talk to
+// external services, so proxy and TLS settings are applied the same way
+// everywhere.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// New returns a client for the named integration that honours the given
+// proxy and TLS settings. A zero ProxyConfig yields a direct client that
+// ignores proxy environment variables unless FromEnvironment is set; a zero
+// TLSConfig trusts the system roots only.
+func New(name string, proxy config.ProxyConfig, tlsConfig config.TLSConfig, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxyFunc, err := proxyFunc(proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxyFunc
+
+	clientTLS, err := buildTLSConfig(name, proxy, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = clientTLS
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+func proxyFunc(proxy config.ProxyConfig) (func(*http.Request) (*url.URL, error), error) {
+	if proxy.URL == "" {
+		if proxy.FromEnvironment {
+			return http.ProxyFromEnvironment, nil
+		}
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy.URL)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http or https", proxyURL.Scheme)
+	}
+
+	// Credentials may be given separately so the URL can live in a
+	// ConfigMap and the password in a Secret.
+	if proxy.Username != "" {
+		proxyURL.User = url.UserPassword(proxy.Username, proxy.Password)
+	}
+
+	cfg := &httpproxy.Config{
+		HTTPProxy:  proxyURL.String(),
+		HTTPSProxy: proxyURL.String(),
+		NoProxy:    strings.Join(proxy.NoProxy, ","),
+	}
+	resolve := cfg.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return resolve(req.URL)
+	}, nil
+}
+
+func buildTLSConfig(name string, proxy config.ProxyConfig, tlsConfig config.TLSConfig) (*tls.Config, error) {
+	result := &tls.Config{}
+
+	// The proxy bundle covers DLP proxies that re-sign traffic with an
+	// internal CA, the integration bundle covers on-prem endpoints with a
+	// private CA. Both extend the system roots.
+	var bundles []string
+	for _, bundle := range []string{proxy.CABundle, tlsConfig.CAFile} {
+		if bundle != "" {
+			bundles = append(bundles, bundle)
+		}
+	}
+	if len(bundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, bundle := range bundles {
+			if err := appendCABundle(pool, bundle); err != nil {
+				return nil, err
+			}
+		}
+		result.RootCAs = pool
+	}
+
+	if tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" {
+		if tlsConfig.CertFile == "" || tlsConfig.KeyFile == "" {
+			return nil, fmt.Errorf("both certFile and keyFile are required for a %s client certificate", name)
+		}
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s client certificate: %w", name, err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+
+	if tlsConfig.ServerName != "" {
+		result.ServerName = tlsConfig.ServerName
+	}
+
+	if tlsConfig.InsecureSkipVerify {
+		klog.Warningf("TLS CERTIFICATE VERIFICATION IS DISABLED for %s: connections can be intercepted "+
+			"and credentials leaked. Configure caFile instead of insecureSkipVerify.", name)
+		result.InsecureSkipVerify = true
+	}
+
+	return result, nil
+}
+
+func appendCABundle(pool *x509.CertPool, path string) error {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return nil
+}
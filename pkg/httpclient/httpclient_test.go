@@ -0,0 +1,195 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestProxyWithCredentials(t *testing.T) {
+	var gotURL, gotAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Proxy-Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	client, err := New("test", config.ProxyConfig{
+		URL:      proxy.URL,
+		Username: "agent",
+		Password: "s3cret",
+	}, config.TLSConfig{}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	resp, err := client.Get("http://upstream.test/api")
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotURL != "http://upstream.test/api" {
+		t.Errorf("expected proxy to receive absolute URL, got %q", gotURL)
+	}
+	expectedAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("agent:s3cret"))
+	if gotAuth != expectedAuth {
+		t.Errorf("expected Proxy-Authorization %q, got %q", expectedAuth, gotAuth)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     config.ProxyConfig
+		target    string
+		wantProxy bool
+		wantErr   bool
+	}{
+		{name: "no proxy", proxy: config.ProxyConfig{}, target: "https://api.test", wantProxy: false},
+		{name: "proxied", proxy: config.ProxyConfig{URL: "http://proxy.internal:3128"}, target: "https://api.test", wantProxy: true},
+		{name: "no_proxy match", proxy: config.ProxyConfig{URL: "http://proxy.internal:3128", NoProxy: []string{".svc.cluster.local"}},
+			target: "http://loki.monitoring.svc.cluster.local", wantProxy: false},
+		{name: "bad scheme", proxy: config.ProxyConfig{URL: "socks5://proxy.internal:1080"}, wantErr: true},
+		{name: "missing host", proxy: config.ProxyConfig{URL: "proxy.internal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := proxyFunc(tt.proxy)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fn == nil {
+				if tt.wantProxy {
+					t.Fatal("expected a proxy function")
+				}
+				return
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			proxyURL, err := fn(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (proxyURL != nil) != tt.wantProxy {
+				t.Errorf("expected proxied=%v, got %v", tt.wantProxy, proxyURL)
+			}
+		})
+	}
+}
+
+func TestCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, []byte(certPEM(server)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Untrusted by default
+	client, err := New("test", config.ProxyConfig{}, config.TLSConfig{}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected an untrusted certificate error without a CA bundle")
+	}
+
+	for name, tc := range map[string]struct {
+		proxy config.ProxyConfig
+		tls   config.TLSConfig
+	}{
+		"integration caFile": {tls: config.TLSConfig{CAFile: bundle}},
+		"proxy caBundle":     {proxy: config.ProxyConfig{CABundle: bundle}},
+		"insecure":           {tls: config.TLSConfig{InsecureSkipVerify: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, err := New("test", tc.proxy, tc.tls, 5*time.Second)
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("expected the server to be trusted: %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+
+	if _, err := New("test", config.ProxyConfig{}, config.TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, time.Second); err == nil {
+		t.Error("expected an error for a missing CA bundle")
+	}
+	if _, err := New("test", config.ProxyConfig{}, config.TLSConfig{CertFile: bundle}, time.Second); err == nil {
+		t.Error("expected an error for a client certificate without a key")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(caFile, []byte(certPEM(server)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The httptest certificate doubles as a client certificate.
+	cert := server.TLS.Certificates[0]
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := New("test", config.ProxyConfig{}, config.TLSConfig{
+		CAFile:   caFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("mTLS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the client certificate to be presented, got status %d", resp.StatusCode)
+	}
+}
+
+func certPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
@@ -0,0 +1,209 @@
+// Package httputil runs the agent's HTTP listeners. Components are mounted
+// by address, and components given the same address share one server, so
+// a deployment can expose health, metrics and the query API on a single
+// port. Every listener gets the same TLS settings and the same bearer token
+// check for the endpoints that aren't public; without a token those only
+// accept reads.
+package httputil
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+type listener struct {
+	names []string
+	mux   *http.ServeMux
+}
+
+// Servers collects the components to serve and runs a server per address.
+type Servers struct {
+	tls   *tls.Config
+	token []byte
+
+	mu        sync.Mutex
+	listeners map[string]*listener
+	order     []string
+}
+
+// New loads the certificate and token the servers share.
+func New(config *config.ServerConfig) (*Servers, error) {
+	s := &Servers{listeners: make(map[string]*listener)}
+
+	if config.TLS.CertFile != "" || config.TLS.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server certificate: %w", err)
+		}
+		s.tls = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	if config.TokenFile != "" {
+		data, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server token: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return nil, fmt.Errorf("server token file %s is empty", config.TokenFile)
+		}
+		s.token = []byte(token)
+	}
+	return s, nil
+}
+
+// Handle mounts handler on pattern of the server at addr, on behalf of the
+// named component. Unless public, requests need the bearer token when one
+// is configured.
+func (s *Servers) Handle(addr, name, pattern string, handler http.Handler, public bool) {
+	if !public {
+		handler = s.requireToken(handler)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, exists := s.listeners[addr]
+	if !exists {
+		l = &listener{mux: http.NewServeMux()}
+		s.listeners[addr] = l
+		s.order = append(s.order, addr)
+	}
+	if !containsName(l.names, name) {
+		l.names = append(l.names, name)
+	}
+	l.mux.Handle(pattern, handler)
+}
+
+// Run serves every address until ctx is done, then shuts the servers down.
+// It returns the first listener that fails to start.
+func (s *Servers) Run(ctx context.Context) error {
+	s.mu.Lock()
+	servers := make([]*http.Server, 0, len(s.order))
+	listeners := make([]net.Listener, 0, len(s.order))
+	for _, addr := range s.order {
+		l := s.listeners[addr]
+		ln, err := net.Listen("tcp", addr)
+		if err == nil && s.tls != nil {
+			ln = tls.NewListener(ln, s.tls)
+		}
+		if err != nil {
+			s.mu.Unlock()
+			for _, ln := range listeners {
+				ln.Close()
+			}
+			return fmt.Errorf("failed to listen on %s for %s: %w", addr, strings.Join(l.names, ", "), err)
+		}
+		listeners = append(listeners, ln)
+		servers = append(servers, &http.Server{Addr: addr, Handler: l.mux})
+
+		scheme := "http"
+		if s.tls != nil {
+			scheme = "https"
+		}
+		klog.Infof("Serving %s on %s (%s)", strings.Join(l.names, ", "), ln.Addr(), scheme)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server, ln net.Listener) {
+			defer wg.Done()
+			if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+				klog.Errorf("Server on %s failed: %v", server.Addr, err)
+			}
+		}(server, listeners[i])
+	}
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(shutdownCtx)
+	}
+	wg.Wait()
+	return nil
+}
+
+// requireToken checks the bearer token. Without a configured token reads
+// stay open, but requests that change state are refused: the API listens
+// on the host network, and anything reaching the node could otherwise
+// silence alerts or write into the coredump directory.
+func (s *Servers) requireToken(next http.Handler) http.Handler {
+	if s.token == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !safeMethod(r.Method) {
+				writeProblem(w, r, http.StatusForbidden, "forbidden", "Token not allowed for this resource",
+					r.Method+" requests need server.tokenFile to be configured")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), s.token) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="milvus-coredump-agent"`)
+			writeProblem(w, r, http.StatusUnauthorized, "unauthorized", "Missing or invalid token", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// writeProblem answers with the same RFC 7807 document the API uses for its
+// errors (pkg/api/errors.go), so clients handle one error format.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, title, detail string) {
+	problem := struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+		Code     string `json:"code"`
+	}{
+		Type:     "urn:milvus-coredump-agent:problem:" + code,
+		Title:    title,
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		klog.Errorf("Failed to encode HTTP error: %v", err)
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, existing := range names {
+		if existing == name {
+			return true
+		}
+	}
+	return false
+}
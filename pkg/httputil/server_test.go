@@ -0,0 +1,132 @@
+package httputil
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func respond(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	})
+}
+
+func TestServersShareAddress(t *testing.T) {
+	servers, err := New(&config.ServerConfig{TokenFile: writeFile(t, "token", "secret\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers.Handle(":8080", "health", "/healthz", respond("ok"), true)
+	servers.Handle(":8080", "metrics", "/metrics", respond("metrics"), false)
+	servers.Handle(":8082", "api", "/", respond("api"), false)
+
+	if len(servers.listeners) != 2 {
+		t.Fatalf("expected health and metrics to share a listener, got %d", len(servers.listeners))
+	}
+	if names := servers.listeners[":8080"].names; len(names) != 2 {
+		t.Errorf("expected both components named, got %v", names)
+	}
+
+	server := httptest.NewServer(servers.listeners[":8080"].mux)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{"/healthz", "", http.StatusOK},
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/metrics", "wrong", http.StatusUnauthorized},
+		{"/metrics", "secret", http.StatusOK},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var problem struct {
+			Type   string `json:"type"`
+			Status int    `json:"status"`
+			Code   string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&problem)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s with token %q: expected %d, got %d", tc.path, tc.token, tc.status, resp.StatusCode)
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			continue
+		}
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("expected a WWW-Authenticate challenge for %s", tc.path)
+		}
+		if resp.Header.Get("Content-Type") != "application/problem+json" || problem.Code != "unauthorized" ||
+			problem.Status != http.StatusUnauthorized || problem.Type != "urn:milvus-coredump-agent:problem:unauthorized" {
+			t.Errorf("expected an unauthorized problem for %s, got %+v", tc.path, problem)
+		}
+	}
+}
+
+func TestServersWithoutTokenOnlyAcceptReads(t *testing.T) {
+	servers, err := New(&config.ServerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers.Handle(":9090", "metrics", "/metrics", respond("metrics"), false)
+
+	servers.Handle(":9090", "health", "/healthz", respond("ok"), true)
+
+	rec := httptest.NewRecorder()
+	servers.listeners[":9090"].mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected metrics to be open without a token, got %d", rec.Code)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		servers.listeners[":9090"].mux.ServeHTTP(rec, httptest.NewRequest(method, "/metrics", nil))
+		if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("expected %s to be refused without a token, got %d", method, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	servers.listeners[":9090"].mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected public endpoints to accept any method, got %d", rec.Code)
+	}
+}
+
+func TestNewRejectsBadCredentials(t *testing.T) {
+	for name, cfg := range map[string]*config.ServerConfig{
+		"empty token":   {TokenFile: writeFile(t, "token", " \n")},
+		"missing token": {TokenFile: filepath.Join(t.TempDir(), "token")},
+		"bad cert": {TLS: config.ServerTLSConfig{
+			CertFile: writeFile(t, "tls.crt", "not a cert"),
+			KeyFile:  writeFile(t, "tls.key", "not a key"),
+		}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
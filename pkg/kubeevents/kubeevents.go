@@ -0,0 +1,161 @@
+// Package kubeevents posts Kubernetes Events for what the agent did, so
+// that `kubectl describe pod` on a crashed Milvus pod shows its coredump
+// was captured and analyzed, and the events of a namespace show the
+// instances the cleaner uninstalled.
+package kubeevents
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+const (
+	ReasonCoredumpCaptured       = "CoredumpCaptured"
+	ReasonAIAnalysisComplete     = "AIAnalysisComplete"
+	ReasonCrashLoopCleanup       = "CrashLoopCleanup"
+	ReasonCrashLoopCleanupFailed = "CrashLoopCleanupFailed"
+
+	component = "milvus-coredump-agent"
+	// The API server rejects longer event messages.
+	maxMessageLength = 1024
+)
+
+// Channels are the pipeline event streams events are posted for.
+type Channels struct {
+	CollectorEvents <-chan collector.CollectionEvent
+	AnalyzerEvents  <-chan analyzer.AnalysisEvent
+	// CleanerEvents are nil when not followed.
+	CleanerEvents <-chan cleaner.CleanupEvent
+}
+
+// Recorder posts the events through client-go's event broadcaster, which
+// aggregates similar events and rate limits them per object.
+type Recorder struct {
+	kubeClient  kubernetes.Interface
+	discovery   *discovery.Discovery
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+}
+
+// New creates the recorder. Events name nodeName as their source host;
+// pods are looked up in the discovery's cache so events carry their UID,
+// which kubectl describe matches them by.
+func New(config *config.KubeEventsConfig, kubeClient kubernetes.Interface, discovery *discovery.Discovery, nodeName string) *Recorder {
+	// Zero keeps client-go's defaults: a burst of 25, then an event every
+	// five minutes.
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		QPS:       config.QPS,
+		BurstSize: config.Burst,
+	})
+	return &Recorder{
+		kubeClient:  kubeClient,
+		discovery:   discovery,
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component, Host: nodeName}),
+	}
+}
+
+func (r *Recorder) Start(ctx context.Context, channels *Channels) error {
+	klog.Info("Starting Kubernetes event recorder")
+	r.broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: r.kubeClient.CoreV1().Events("")})
+	defer r.broadcaster.Shutdown()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-channels.CollectorEvents:
+			if event.Type == collector.EventTypeFileDiscovered {
+				r.coredumpCaptured(event.CoredumpFile)
+			}
+		case event := <-channels.AnalyzerEvents:
+			if event.Type == analyzer.EventTypeAnalysisComplete {
+				r.analysisComplete(event.CoredumpFile)
+			}
+		case event := <-channels.CleanerEvents:
+			r.cleanup(event)
+		}
+	}
+}
+
+func (r *Recorder) coredumpCaptured(file *collector.CoredumpFile) {
+	if file == nil || file.SelfTest || file.PodName == "" {
+		return
+	}
+
+	message := fmt.Sprintf("Captured coredump %s (signal %d, %.1f MiB)", file.FileName, file.Signal, float64(file.Size)/(1<<20))
+	if file.ContainerName != "" {
+		message += " of container " + file.ContainerName
+	}
+	if file.NodeName != "" {
+		message += " on node " + file.NodeName
+	}
+	r.recorder.Event(r.pod(file.PodNamespace, file.PodName), corev1.EventTypeWarning, ReasonCoredumpCaptured, message)
+}
+
+func (r *Recorder) analysisComplete(file *collector.CoredumpFile) {
+	if file == nil || file.PodName == "" || file.AnalysisResults == nil {
+		return
+	}
+	ai := file.AnalysisResults.AIAnalysis
+	if ai == nil || !ai.Enabled || ai.ErrorMessage != "" {
+		return
+	}
+
+	message := fmt.Sprintf("Coredump %s scored %.1f, AI analysis (confidence %.0f%%): %s",
+		file.FileName, file.ValueScore, ai.Confidence*100, ai.Summary)
+	r.recorder.Event(r.pod(file.PodNamespace, file.PodName), corev1.EventTypeNormal, ReasonAIAnalysisComplete, truncate(message))
+}
+
+func (r *Recorder) cleanup(event cleaner.CleanupEvent) {
+	var eventType, reason, message string
+	switch event.Type {
+	case cleaner.EventTypeInstanceUninstalled:
+		eventType, reason = corev1.EventTypeWarning, ReasonCrashLoopCleanup
+		message = fmt.Sprintf("Uninstalled Milvus instance %s: %s", event.InstanceName, event.Reason)
+	case cleaner.EventTypeCleanupError:
+		eventType, reason = corev1.EventTypeWarning, ReasonCrashLoopCleanupFailed
+		message = fmt.Sprintf("Failed to uninstall Milvus instance %s: %s", event.InstanceName, event.Error)
+	default:
+		return
+	}
+
+	// Posted in the namespace itself, where kubectl get events lists it.
+	namespace := &corev1.ObjectReference{Kind: "Namespace", APIVersion: "v1", Name: event.Namespace, Namespace: event.Namespace}
+	r.recorder.Event(namespace, eventType, reason, truncate(message))
+}
+
+// pod returns the cached pod, or a reference without UID to a pod that is
+// gone or not cached.
+func (r *Recorder) pod(namespace, name string) runtime.Object {
+	if pod := r.discovery.Pod(namespace, name); pod != nil {
+		return pod
+	}
+	return &corev1.ObjectReference{Kind: "Pod", APIVersion: "v1", Namespace: namespace, Name: name}
+}
+
+func truncate(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+	cut := maxMessageLength - len("...")
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + "..."
+}
@@ -0,0 +1,109 @@
+package kubeevents
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// waitForEvents returns the events in namespace once there are want of
+// them, then checks no more arrive.
+func waitForEvents(t *testing.T, client *fake.Clientset, namespace string, want int) []corev1.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, err := client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Items) >= want {
+			time.Sleep(100 * time.Millisecond)
+			events, _ = client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
+			if len(events.Items) != want {
+				t.Fatalf("expected %d events, got %d", want, len(events.Items))
+			}
+			return events.Items
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events, got %d", want, len(events.Items))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecorderPostsEvents(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	collectorEvents := make(chan collector.CollectionEvent)
+	analyzerEvents := make(chan analyzer.AnalysisEvent)
+	cleanerEvents := make(chan cleaner.CleanupEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// One event per object, so the second core of the pod is dropped.
+	r := New(&config.KubeEventsConfig{QPS: 0.001, Burst: 2}, client, nil, "node-1")
+	go r.Start(ctx, &Channels{CollectorEvents: collectorEvents, AnalyzerEvents: analyzerEvents, CleanerEvents: cleanerEvents})
+
+	file := &collector.CoredumpFile{
+		FileName:      "core.milvus.1234.0.11",
+		Signal:        11,
+		Size:          64 << 20,
+		NodeName:      "node-1",
+		PodName:       "prod-querynode-0",
+		PodNamespace:  "milvus",
+		ContainerName: "querynode",
+		ValueScore:    8,
+		AnalysisResults: &collector.AnalysisResults{AIAnalysis: &collector.AIAnalysisResult{
+			Enabled:    true,
+			Summary:    strings.Repeat("x", 2000),
+			Confidence: 0.8,
+		}},
+	}
+	collectorEvents <- collector.CollectionEvent{Type: collector.EventTypeFileDiscovered, CoredumpFile: file}
+	analyzerEvents <- analyzer.AnalysisEvent{Type: analyzer.EventTypeAnalysisComplete, CoredumpFile: file}
+	second := *file
+	second.FileName = "core.milvus.1235.0.11"
+	collectorEvents <- collector.CollectionEvent{Type: collector.EventTypeFileDiscovered, CoredumpFile: &second}
+	// Self-test cores and cores of no pod get no event.
+	collectorEvents <- collector.CollectionEvent{Type: collector.EventTypeFileDiscovered, CoredumpFile: &collector.CoredumpFile{SelfTest: true, PodName: "a", PodNamespace: "milvus"}}
+	cleanerEvents <- cleaner.CleanupEvent{Type: cleaner.EventTypeInstanceUninstalled, Namespace: "milvus", InstanceName: "prod", Reason: "crash loop"}
+
+	reasons := make(map[string]corev1.Event)
+	for _, event := range waitForEvents(t, client, "milvus", 3) {
+		reasons[event.Reason] = event
+	}
+
+	captured := reasons[ReasonCoredumpCaptured]
+	if captured.InvolvedObject.Kind != "Pod" || captured.InvolvedObject.Name != "prod-querynode-0" || captured.Type != corev1.EventTypeWarning ||
+		captured.Message != "Captured coredump core.milvus.1234.0.11 (signal 11, 64.0 MiB) of container querynode on node node-1" {
+		t.Errorf("unexpected capture event %+v", captured)
+	}
+	if analysis := reasons[ReasonAIAnalysisComplete]; analysis.Type != corev1.EventTypeNormal || len(analysis.Message) != maxMessageLength {
+		t.Errorf("expected a truncated analysis event, got %q", analysis.Message)
+	}
+	cleanup := reasons[ReasonCrashLoopCleanup]
+	if cleanup.InvolvedObject.Kind != "Namespace" || cleanup.Message != "Uninstalled Milvus instance prod: crash loop" || cleanup.Source.Host != "node-1" {
+		t.Errorf("unexpected cleanup event %+v", cleanup)
+	}
+}
+
+func TestTruncateKeepsRunes(t *testing.T) {
+	message := strings.Repeat("崩", 400)
+	truncated := truncate(message)
+	if len(truncated) > maxMessageLength || !strings.HasSuffix(truncated, "...") || !utf8.ValidString(truncated) {
+		t.Errorf("unexpected truncation to %d bytes: %q", len(truncated), truncated)
+	}
+	if truncate("short") != "short" {
+		t.Error("expected short messages to be kept")
+	}
+}
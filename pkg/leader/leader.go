@@ -0,0 +1,119 @@
+// Package leader elects one agent of the DaemonSet, through a
+// coordination.k8s.io Lease, to take the actions that concern the whole
+// cluster, such as uninstalling crashing instances. Every agent sees the
+// same pods and would otherwise act on them at once; collecting and
+// analyzing the coredumps of each node is left to that node's agent.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	defaultLeaseName     = "milvus-coredump-agent"
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// Elector takes part in the election under identity. A nil *Elector is
+// always the leader, as a single agent without election is.
+type Elector struct {
+	config    *config.LeaderElectionConfig
+	client    kubernetes.Interface
+	identity  string
+	namespace string
+	leading   atomic.Bool
+}
+
+// New creates an elector for the Lease in namespace, unless the config
+// names another namespace.
+func New(config *config.LeaderElectionConfig, client kubernetes.Interface, identity, namespace string) *Elector {
+	if config.Namespace != "" {
+		namespace = config.Namespace
+	}
+	return &Elector{
+		config:    config,
+		client:    client,
+		identity:  identity,
+		namespace: namespace,
+	}
+}
+
+// IsLeader reports whether this agent currently holds the Lease.
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.leading.Load()
+}
+
+// Start takes part in the election until ctx is done, standing again
+// whenever leadership is lost. The Lease is released on the way out so
+// another agent takes over without waiting for it to expire.
+func (e *Elector) Start(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+
+	name := e.config.LeaseName
+	if name == "" {
+		name = defaultLeaseName
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: name, Namespace: e.namespace},
+			Client:     e.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: e.identity},
+		},
+		LeaseDuration:   orDefault(e.config.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline:   orDefault(e.config.RenewDeadline, defaultRenewDeadline),
+		RetryPeriod:     orDefault(e.config.RetryPeriod, defaultRetryPeriod),
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				e.leading.Store(true)
+				klog.Infof("Became the leader of %s/%s as %s", e.namespace, name, e.identity)
+			},
+			// Also called when the election ends without having led.
+			OnStoppedLeading: func() {
+				if e.leading.Swap(false) {
+					klog.Infof("No longer the leader of %s/%s", e.namespace, name)
+				}
+			},
+			OnNewLeader: func(identity string) {
+				if identity != e.identity {
+					klog.Infof("Agent %s is the leader of %s/%s", identity, e.namespace, name)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid leader election config: %w", err)
+	}
+
+	klog.Infof("Standing for leader of %s/%s as %s", e.namespace, name, e.identity)
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
+
+func orDefault(value, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
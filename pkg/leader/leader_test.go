@@ -0,0 +1,73 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestOneAgentLeads(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	cfg := &config.LeaderElectionConfig{
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   50 * time.Millisecond,
+	}
+	first := New(cfg, client, "node-a", "milvus-system")
+	second := New(cfg, client, "node-b", "milvus-system")
+	if first.IsLeader() || second.IsLeader() {
+		t.Fatal("expected no leader before the election")
+	}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error)
+	go func() { firstDone <- first.Start(firstCtx) }()
+	waitFor(t, first.IsLeader, "expected the first agent to lead")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go second.Start(ctx)
+	time.Sleep(200 * time.Millisecond)
+	if second.IsLeader() {
+		t.Fatal("expected a single leader")
+	}
+
+	// The first agent releases the Lease on shutdown.
+	stopFirst()
+	if err := <-firstDone; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.IsLeader() {
+		t.Error("expected the stopped agent to no longer lead")
+	}
+	waitFor(t, second.IsLeader, "expected the second agent to take over")
+
+	var nilElector *Elector
+	if !nilElector.IsLeader() || nilElector.Start(context.Background()) != nil {
+		t.Error("expected a nil elector to always lead")
+	}
+}
+
+func TestStartRejectsInvalidTimings(t *testing.T) {
+	elector := New(&config.LeaderElectionConfig{LeaseDuration: time.Second, RenewDeadline: 2 * time.Second},
+		fake.NewSimpleClientset(), "node-a", "milvus-system")
+	if err := elector.Start(context.Background()); err == nil {
+		t.Error("expected a renew deadline beyond the lease duration to be rejected")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for !condition() {
+		select {
+		case <-deadline:
+			t.Fatal(message)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
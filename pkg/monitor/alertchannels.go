@@ -0,0 +1,279 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// pagerDutyEventsURL is the Events API v2 endpoint, used unless the channel
+// sets its own URL.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// maxListedCoredumps bounds the coredump IDs listed in chat messages.
+const maxListedCoredumps = 5
+
+// alertChannel formats notifications for one destination.
+type alertChannel struct {
+	config *config.AlertChannelConfig
+	url    string
+	// payload returns the request body for a notification.
+	payload func(notification *AlertNotification) ([]byte, error)
+	// check inspects a successful response, for services that report
+	// errors in the body.
+	check func(body []byte) error
+}
+
+func newAlertChannel(cfg *config.AlertChannelConfig) *alertChannel {
+	channel := &alertChannel{config: cfg, url: cfg.URL}
+	switch cfg.Type {
+	case "slack":
+		channel.payload = slackPayload
+	case "feishu":
+		channel.payload = func(notification *AlertNotification) ([]byte, error) {
+			return feishuPayload(notification, cfg.Secret, time.Now())
+		}
+		channel.check = checkFeishuResponse
+	case "pagerduty":
+		if channel.url == "" {
+			channel.url = pagerDutyEventsURL
+		}
+		channel.payload = func(notification *AlertNotification) ([]byte, error) {
+			return pagerDutyPayload(notification, cfg.RoutingKey)
+		}
+	default:
+		channel.payload = func(notification *AlertNotification) ([]byte, error) {
+			return json.Marshal(notification)
+		}
+	}
+	return channel
+}
+
+// newWebhookChannel is the channel of the alerting webhookUrl, which
+// receives every alert.
+func newWebhookChannel(url string) *alertChannel {
+	return newAlertChannel(&config.AlertChannelConfig{Name: "webhook", Type: "webhook", URL: url})
+}
+
+// route sends the notification to every channel whose route matches it.
+func (a *Alerter) route(ctx context.Context, notification *AlertNotification) error {
+	var errs []error
+	for _, channel := range a.channels {
+		if !routeMatches(&channel.config.Route, notification) {
+			continue
+		}
+		if err := a.post(ctx, channel, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Alerter) post(ctx context.Context, channel *alertChannel, notification *AlertNotification) error {
+	body, err := channel.payload(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", channel.config.Type, resp.StatusCode)
+	}
+	if channel.check != nil {
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return channel.check(respBody)
+	}
+	return nil
+}
+
+func routeMatches(route *config.AlertRouteConfig, notification *AlertNotification) bool {
+	if !matchesAny(route.Kinds, notification.Kind) ||
+		!matchesAny(route.Severities, notification.Severity) {
+		return false
+	}
+	// Alerts about the AI budget belong to no namespace.
+	if notification.Kind != AlertKindAIBudget && !matchesAny(route.Namespaces, notification.Namespace) {
+		return false
+	}
+	return notification.Kind != AlertKindCrash || notification.ValueScore >= route.MinScore
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// alertTitle is the one-line summary of a notification.
+func alertTitle(notification *AlertNotification) string {
+	switch notification.Kind {
+	case AlertKindCrash:
+		instance := notification.Instance
+		if instance == "" {
+			instance = "unknown instance"
+		}
+		crashed := "crashed"
+		if notification.Count > 1 {
+			crashed = fmt.Sprintf("crashed %d times", notification.Count)
+		}
+		return fmt.Sprintf("Milvus %s in %s %s (%s, signal %d)",
+			instance, notification.Namespace, crashed, notification.Executable, notification.Signal)
+	case AlertKindCleanup:
+		return fmt.Sprintf("Crash loop cleanup of Milvus %s in %s", notification.Instance, notification.Namespace)
+	default:
+		return "AI analysis budget exhausted"
+	}
+}
+
+// alertDetails are the lines of a notification's body in chat messages.
+func alertDetails(notification *AlertNotification) []string {
+	lines := crashDetails(notification)
+	if notification.Kind != AlertKindCrash {
+		lines = []string{notification.Message}
+	}
+	if notification.Rule != "" {
+		rule := "Rule: " + notification.Rule
+		if notification.EscalationLevel > 0 {
+			rule += fmt.Sprintf(" (escalation level %d)", notification.EscalationLevel)
+		}
+		lines = append(lines, rule)
+	}
+	return lines
+}
+
+func crashDetails(notification *AlertNotification) []string {
+
+	lines := []string{fmt.Sprintf("Value score: %.1f", notification.ValueScore)}
+	if len(notification.Pods) > 0 {
+		lines = append(lines, "Pods: "+strings.Join(notification.Pods, ", "))
+	}
+	if notification.Fingerprint != "" {
+		lines = append(lines, "Fingerprint: "+notification.Fingerprint)
+	}
+	if len(notification.Watchlist) > 0 {
+		lines = append(lines, "Watchlist: "+strings.Join(notification.Watchlist, ", "))
+	}
+	if notification.UnderChaos {
+		lines = append(lines, "Under chaos: "+strings.Join(notification.ChaosExperiments, ", "))
+	}
+	if ids := notification.CoredumpIDs; len(ids) > 0 {
+		listed := strings.Join(ids[:min(len(ids), maxListedCoredumps)], ", ")
+		if len(ids) > maxListedCoredumps {
+			listed += fmt.Sprintf(" and %d more", len(ids)-maxListedCoredumps)
+		}
+		lines = append(lines, "Coredumps: "+listed)
+	}
+	return lines
+}
+
+func slackPayload(notification *AlertNotification) ([]byte, error) {
+	text := fmt.Sprintf("*[%s] %s*\n%s", strings.ToUpper(notification.Severity), alertTitle(notification),
+		strings.Join(alertDetails(notification), "\n"))
+	return json.Marshal(map[string]string{"text": text})
+}
+
+// feishuPayload builds a custom bot text message. With a secret, the bot
+// expects the timestamp signed as its documentation describes: the HMAC
+// key is the timestamp and the secret, the message is empty.
+func feishuPayload(notification *AlertNotification, secret string, now time.Time) ([]byte, error) {
+	text := fmt.Sprintf("[%s] %s\n%s", strings.ToUpper(notification.Severity), alertTitle(notification),
+		strings.Join(alertDetails(notification), "\n"))
+	message := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": text},
+	}
+	if secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+		message["timestamp"] = timestamp
+		message["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return json.Marshal(message)
+}
+
+// checkFeishuResponse reports the errors Feishu returns with status 200.
+func checkFeishuResponse(body []byte) error {
+	var response struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("failed to parse feishu response: %w", err)
+	}
+	if response.Code != 0 {
+		return fmt.Errorf("feishu returned code %d: %s", response.Code, response.Msg)
+	}
+	return nil
+}
+
+// pagerDutyPayload triggers an Events API v2 event. Repeated alerts of the
+// same crash site share a dedup key, so they update one incident.
+func pagerDutyPayload(notification *AlertNotification, routingKey string) ([]byte, error) {
+	source := "milvus-coredump-agent"
+	if notification.Instance != "" {
+		source = notification.Namespace + "/" + notification.Instance
+	}
+	dedupKey := notification.Kind + "/" + source
+	if notification.Kind == AlertKindCrash {
+		site := notification.Fingerprint
+		if site == "" {
+			site = fmt.Sprintf("%s:%d", notification.Executable, notification.Signal)
+		}
+		dedupKey += "/" + site
+	}
+
+	summary := alertTitle(notification)
+	if notification.Kind != AlertKindCrash {
+		summary = notification.Message
+	}
+	if len(summary) > 1024 {
+		summary = summary[:1024]
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    dedupKey,
+		"payload": map[string]interface{}{
+			"summary":        summary,
+			"source":         source,
+			"severity":       notification.Severity,
+			"component":      "milvus",
+			"group":          notification.Namespace,
+			"class":          notification.Kind,
+			"custom_details": notification,
+		},
+	})
+}
@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// channelServer records the bodies posted to it, by path.
+type channelServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies map[string][]map[string]interface{}
+}
+
+func newChannelServer(t *testing.T) *channelServer {
+	s := &channelServer{bodies: make(map[string][]map[string]interface{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("%s received invalid JSON: %v", r.URL.Path, err)
+		}
+		s.mu.Lock()
+		s.bodies[r.URL.Path] = append(s.bodies[r.URL.Path], body)
+		s.mu.Unlock()
+		switch r.URL.Path {
+		case "/feishu":
+			w.Write([]byte(`{"code":0,"msg":"success"}`))
+		case "/feishu-bad-sign":
+			w.Write([]byte(`{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`))
+		case "/pagerduty":
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *channelServer) received(path string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[path]
+}
+
+func TestAlerterRoutesToChannels(t *testing.T) {
+	server := newChannelServer(t)
+	alerter, err := NewAlerter(&config.AlertingConfig{
+		Channels: []config.AlertChannelConfig{
+			{Name: "slack", Type: "slack", URL: server.URL + "/slack"},
+			{Name: "feishu", Type: "feishu", URL: server.URL + "/feishu", Secret: "s3cret",
+				Route: config.AlertRouteConfig{Namespaces: []string{"milvus"}}},
+			{Name: "oncall", Type: "pagerduty", URL: server.URL + "/pagerduty", RoutingKey: "key",
+				Route: config.AlertRouteConfig{Kinds: []string{"crash"}, Severities: []string{"critical"}, MinScore: 9}},
+		},
+	}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+
+	crash := &AlertNotification{
+		Kind: AlertKindCrash, Severity: SeverityCritical, Instance: "prod", Namespace: "milvus",
+		Executable: "milvus", Signal: 11, Count: 3, ValueScore: 9.5,
+		Pods: []string{"prod-querynode-0", "prod-querynode-1"}, CoredumpIDs: []string{"a", "b", "c"},
+	}
+	if err := alerter.route(context.Background(), crash); err != nil {
+		t.Fatalf("failed to route crash alert: %v", err)
+	}
+	// Below the PagerDuty route's score and in another namespace than
+	// Feishu's: only Slack gets it.
+	other := *crash
+	other.Namespace, other.ValueScore = "staging", 8.5
+	if err := alerter.route(context.Background(), &other); err != nil {
+		t.Fatalf("failed to route crash alert: %v", err)
+	}
+	// Alerts about the AI budget have no namespace but match Feishu's route.
+	budget := &AlertNotification{Kind: AlertKindAIBudget, Severity: SeverityWarning, Message: "AI analysis budget exhausted"}
+	if err := alerter.route(context.Background(), budget); err != nil {
+		t.Fatalf("failed to route budget alert: %v", err)
+	}
+
+	if slack := server.received("/slack"); len(slack) != 3 ||
+		slack[0]["text"] != "*[CRITICAL] Milvus prod in milvus crashed 3 times (milvus, signal 11)*\nValue score: 9.5\nPods: prod-querynode-0, prod-querynode-1\nCoredumps: a, b, c" {
+		t.Errorf("unexpected Slack messages %v", slack)
+	}
+
+	feishu := server.received("/feishu")
+	if len(feishu) != 2 || feishu[0]["msg_type"] != "text" {
+		t.Fatalf("expected the milvus crash and the budget alert on Feishu, got %v", feishu)
+	}
+	timestamp, _ := feishu[0]["timestamp"].(string)
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+"s3cret"))
+	if feishu[0]["sign"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected Feishu signature %v", feishu[0]["sign"])
+	}
+	if text := feishu[1]["content"].(map[string]interface{})["text"]; text != "[WARNING] AI analysis budget exhausted\nAI analysis budget exhausted" {
+		t.Errorf("unexpected Feishu text %q", text)
+	}
+
+	pagerDuty := server.received("/pagerduty")
+	if len(pagerDuty) != 1 {
+		t.Fatalf("expected only the critical crash scoring 9 on PagerDuty, got %v", pagerDuty)
+	}
+	payload := pagerDuty[0]["payload"].(map[string]interface{})
+	if pagerDuty[0]["routing_key"] != "key" || pagerDuty[0]["dedup_key"] != "crash/milvus/prod/milvus:11" ||
+		payload["severity"] != "critical" || payload["source"] != "milvus/prod" {
+		t.Errorf("unexpected PagerDuty event %v", pagerDuty[0])
+	}
+}
+
+func TestAlerterReportsChannelErrors(t *testing.T) {
+	server := newChannelServer(t)
+	alerter, err := NewAlerter(&config.AlertingConfig{
+		Channels: []config.AlertChannelConfig{
+			{Name: "slack", Type: "slack", URL: server.URL + "/slack"},
+			{Name: "team-feishu", Type: "feishu", URL: server.URL + "/feishu-bad-sign"},
+		},
+	}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+
+	err = alerter.route(context.Background(), &AlertNotification{Kind: AlertKindCleanup, Severity: SeverityCritical, Namespace: "milvus"})
+	if err == nil || !strings.Contains(err.Error(), "team-feishu: feishu returned code 19021") {
+		t.Errorf("expected the Feishu error, got %v", err)
+	}
+	if len(server.received("/slack")) != 1 {
+		t.Error("expected Slack to get the alert despite Feishu failing")
+	}
+}
+
+func TestFeishuPayloadWithoutSecret(t *testing.T) {
+	body, err := feishuPayload(&AlertNotification{Kind: AlertKindAIBudget, Severity: SeverityWarning}, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "sign") {
+		t.Errorf("expected no signature without a secret, got %s", body)
+	}
+}
@@ -0,0 +1,340 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/alertrules"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+
+	AlertKindCrash    = "crash"
+	AlertKindCleanup  = "cleanup"
+	AlertKindAIBudget = "ai_budget"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// AlertNotification is the webhook payload for a group of crashes of the same
+// instance and crash site, an instance the cleaner acted on, or the AI
+// analysis budget running out. Message describes the latter two.
+type AlertNotification struct {
+	Kind        string `json:"kind"`
+	Severity    string `json:"severity"`
+	Instance    string `json:"instance"`
+	Namespace   string `json:"namespace"`
+	Executable  string `json:"executable"`
+	Signal      int    `json:"signal"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Count       int    `json:"count"`
+	// ValueScore is the highest score of the crashes.
+	ValueScore float64 `json:"valueScore,omitempty"`
+	Message    string  `json:"message,omitempty"`
+	// RestartCount is the number of restarts that led to a cleanup.
+	RestartCount int `json:"restartCount,omitempty"`
+	// Rule names the alert rule that matched, EscalationLevel the level
+	// of its escalation reached.
+	Rule            string    `json:"rule,omitempty"`
+	EscalationLevel int       `json:"escalationLevel,omitempty"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+	Pods            []string  `json:"pods"`
+	CoredumpIDs     []string  `json:"coredumpIds"`
+	// UnderChaos marks crashes that overlapped a chaos experiment;
+	// ChaosExperiments names them as namespace/name.
+	UnderChaos       bool     `json:"underChaos"`
+	ChaosExperiments []string `json:"chaosExperiments,omitempty"`
+	// Watchlist names the watchlist entries the crashes matched.
+	Watchlist []string `json:"watchlist,omitempty"`
+}
+
+// Alerter collapses crashes of the same instance and fingerprint that arrive
+// within a per-severity window into a single notification, so a dependency
+// outage that crashes every replica at once sends one alert with a count
+// instead of one alert per core. Notifications go to every channel whose
+// route matches them.
+type Alerter struct {
+	config *config.AlertingConfig
+	// critical is the value score from which a crash alerts as critical.
+	critical float64
+	client   *http.Client
+	channels []*alertChannel
+	send     func(ctx context.Context, notification *AlertNotification) error
+	// rules decide whether a notification is sent and its severity.
+	rules *alertrules.Engine
+
+	// onSent is called after each delivery attempt.
+	onSent func(notification *AlertNotification, err error)
+	// onSuppressed is called for notifications the rules drop.
+	onSuppressed func(notification *AlertNotification, decision alertrules.Decision)
+
+	mu     sync.Mutex
+	groups map[string]*alertGroup
+	// budgetExhausted is set once the AI budget alert is sent, until an
+	// analysis runs again.
+	budgetExhausted bool
+}
+
+type alertGroup struct {
+	notification *AlertNotification
+	pods         map[string]bool
+	experiments  map[string]bool
+	timer        *time.Timer
+}
+
+func NewAlerter(config *config.AlertingConfig, thresholds config.ScoreThresholds) (*Alerter, error) {
+	client, err := httpclient.New("alert webhook", config.Proxy, config.TLS, webhookTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook HTTP client: %w", err)
+	}
+
+	a := &Alerter{
+		config:   config,
+		critical: thresholds.Critical,
+		client:   client,
+		groups:   make(map[string]*alertGroup),
+	}
+	if config.WebhookURL != "" {
+		a.channels = append(a.channels, newWebhookChannel(config.WebhookURL))
+	}
+	for i := range config.Channels {
+		a.channels = append(a.channels, newAlertChannel(&config.Channels[i]))
+	}
+	a.send = a.route
+	return a, nil
+}
+
+// Observe records a crash worth alerting on. The first crash of a group
+// starts its window; the notification is sent when the window closes.
+// Crashes on the watchlist are sent right away.
+func (a *Alerter) Observe(coredump *collector.CoredumpFile) {
+	severity := alertSeverity(coredump, a.critical)
+	key := severity + "/" + alertGroupKey(coredump)
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if group, exists := a.groups[key]; exists {
+		group.add(coredump, now)
+		return
+	}
+
+	group := &alertGroup{
+		notification: &AlertNotification{
+			Kind:        AlertKindCrash,
+			Severity:    severity,
+			Instance:    coredump.InstanceName,
+			Namespace:   coredump.PodNamespace,
+			Executable:  coredump.Executable,
+			Signal:      coredump.Signal,
+			Fingerprint: coredump.Fingerprint,
+			FirstSeen:   now,
+			UnderChaos:  coredump.UnderChaos,
+			Watchlist:   coredump.Watchlist,
+		},
+		pods:        make(map[string]bool),
+		experiments: make(map[string]bool),
+	}
+	group.add(coredump, now)
+
+	window := a.groupWindow(severity)
+	if window <= 0 || len(coredump.Watchlist) > 0 {
+		go a.deliver(group.notification)
+		return
+	}
+
+	a.groups[key] = group
+	group.timer = time.AfterFunc(window, func() { a.flush(key) })
+	klog.V(2).Infof("Opened %s alert group %s for %v", severity, key, window)
+}
+
+// ObserveCleanup alerts right away on an instance the cleaner uninstalled
+// or failed to.
+func (a *Alerter) ObserveCleanup(event cleaner.CleanupEvent) {
+	notification := &AlertNotification{
+		Kind:         AlertKindCleanup,
+		Instance:     event.InstanceName,
+		Namespace:    event.Namespace,
+		RestartCount: event.RestartCount,
+		FirstSeen:    event.Timestamp,
+		LastSeen:     event.Timestamp,
+	}
+	switch event.Type {
+	case cleaner.EventTypeInstanceUninstalled:
+		notification.Severity = SeverityCritical
+		notification.Message = fmt.Sprintf("Uninstalled Milvus instance %s: %s", event.InstanceName, event.Reason)
+	case cleaner.EventTypeCleanupError:
+		notification.Severity = SeverityWarning
+		notification.Message = fmt.Sprintf("Failed to uninstall Milvus instance %s: %s", event.InstanceName, event.Error)
+	default:
+		return
+	}
+	go a.deliver(notification)
+}
+
+// ObserveAIAnalysis alerts once when AI analyses start being skipped for
+// the cost control limits, and again only after an analysis has run since.
+func (a *Alerter) ObserveAIAnalysis(result *collector.AIAnalysisResult) {
+	if result == nil || !result.Enabled {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if result.CostLimitReached == "" {
+		if result.ErrorMessage == "" {
+			a.budgetExhausted = false
+		}
+		return
+	}
+	if a.budgetExhausted {
+		return
+	}
+	a.budgetExhausted = true
+
+	now := time.Now()
+	go a.deliver(&AlertNotification{
+		Kind:      AlertKindAIBudget,
+		Severity:  SeverityWarning,
+		FirstSeen: now,
+		LastSeen:  now,
+		Message:   fmt.Sprintf("AI analysis budget exhausted: the %s was reached, coredumps are analyzed without AI until it resets", result.CostLimitReached),
+	})
+}
+
+// FlushAll sends every pending group immediately, used on shutdown.
+func (a *Alerter) FlushAll() {
+	a.mu.Lock()
+	keys := make([]string, 0, len(a.groups))
+	for key := range a.groups {
+		keys = append(keys, key)
+	}
+	a.mu.Unlock()
+
+	for _, key := range keys {
+		a.flush(key)
+	}
+}
+
+func (a *Alerter) flush(key string) {
+	a.mu.Lock()
+	group, exists := a.groups[key]
+	if exists {
+		delete(a.groups, key)
+		group.timer.Stop()
+	}
+	a.mu.Unlock()
+
+	if exists {
+		a.deliver(group.notification)
+	}
+}
+
+func (a *Alerter) deliver(notification *AlertNotification) {
+	decision := a.rules.Evaluate(alertrules.Alert{
+		Kind:         notification.Kind,
+		Severity:     notification.Severity,
+		Namespace:    notification.Namespace,
+		Instance:     notification.Instance,
+		Score:        notification.ValueScore,
+		RestartCount: notification.RestartCount,
+		Count:        notification.Count,
+	}, time.Now())
+	notification.Severity = decision.Severity
+	notification.Rule = decision.Rule
+	notification.EscalationLevel = decision.Level
+	if !decision.Deliver {
+		klog.V(2).Infof("Alert %s: %s", decision.Reason, alertTitle(notification))
+		if a.onSuppressed != nil {
+			a.onSuppressed(notification, decision)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	err := a.send(ctx, notification)
+	if err != nil {
+		klog.Errorf("Failed to send %s alert: %s: %v", notification.Severity, alertTitle(notification), err)
+	} else {
+		klog.Infof("Sent %s alert: %s", notification.Severity, alertTitle(notification))
+	}
+
+	if a.onSent != nil {
+		a.onSent(notification, err)
+	}
+}
+
+func (a *Alerter) groupWindow(severity string) time.Duration {
+	if window, exists := a.config.GroupWindows[severity]; exists {
+		return window
+	}
+	return a.config.GroupWindow
+}
+
+func (g *alertGroup) add(coredump *collector.CoredumpFile, now time.Time) {
+	g.notification.Count++
+	g.notification.LastSeen = now
+	if coredump.ValueScore > g.notification.ValueScore {
+		g.notification.ValueScore = coredump.ValueScore
+	}
+	if coredump.ID != "" {
+		g.notification.CoredumpIDs = append(g.notification.CoredumpIDs, coredump.ID)
+	}
+	for _, experiment := range coredump.ChaosExperiments {
+		name := experiment.Namespace + "/" + experiment.Name
+		if !g.experiments[name] {
+			g.experiments[name] = true
+			g.notification.ChaosExperiments = append(g.notification.ChaosExperiments, name)
+		}
+	}
+	if coredump.PodName != "" && !g.pods[coredump.PodName] {
+		g.pods[coredump.PodName] = true
+		g.notification.Pods = append(g.notification.Pods, coredump.PodName)
+		sort.Strings(g.notification.Pods)
+	}
+}
+
+// alertSeverity never reports crashes under chaos as critical: they are
+// expected while faults are being injected. Crashes on the watchlist are
+// critical regardless, being the ones hunted for.
+func alertSeverity(coredump *collector.CoredumpFile, critical float64) string {
+	if len(coredump.Watchlist) > 0 {
+		return SeverityCritical
+	}
+	if coredump.ValueScore >= critical && !coredump.UnderChaos {
+		return SeverityCritical
+	}
+	return SeverityWarning
+}
+
+// alertGroupKey identifies "the same crash of the same instance". The crash
+// fingerprint is only computed when storage dedup is enabled; without it the
+// executable and signal are the best available approximation.
+func alertGroupKey(coredump *collector.CoredumpFile) string {
+	site := coredump.Fingerprint
+	if site == "" {
+		site = fmt.Sprintf("%s:%d", coredump.Executable, coredump.Signal)
+	}
+	key := fmt.Sprintf("%s/%s/%s", coredump.PodNamespace, coredump.InstanceName, site)
+	if coredump.UnderChaos {
+		key += "/chaos"
+	}
+	return key
+}
@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/alertrules"
+	"milvus-coredump-agent/pkg/chaos"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []*AlertNotification
+}
+
+func (r *recordingSender) send(ctx context.Context, notification *AlertNotification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, notification)
+	return nil
+}
+
+func (r *recordingSender) notifications() []*AlertNotification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*AlertNotification(nil), r.sent...)
+}
+
+func TestAlerterGroupsCrashesOfSameInstance(t *testing.T) {
+	recorder := &recordingSender{}
+	alerter, err := NewAlerter(&config.AlertingConfig{
+		GroupWindow:  time.Hour,
+		GroupWindows: map[string]time.Duration{SeverityCritical: 50 * time.Millisecond},
+	}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.send = recorder.send
+
+	// 20 replicas of one instance crash at the same site.
+	for i := 0; i < 20; i++ {
+		alerter.Observe(&collector.CoredumpFile{
+			ID:           string(rune('a' + i)),
+			PodName:      "milvus-querynode-" + string(rune('a'+i%4)),
+			PodNamespace: "default",
+			InstanceName: "milvus-prod",
+			Executable:   "milvus",
+			Signal:       11,
+			ValueScore:   9,
+		})
+	}
+	// A different instance alerts separately.
+	alerter.Observe(&collector.CoredumpFile{
+		ID: "z", PodNamespace: "default", InstanceName: "milvus-dev",
+		Executable: "milvus", Signal: 11, ValueScore: 9,
+	})
+	// Warning severity uses the default one hour window and stays pending.
+	alerter.Observe(&collector.CoredumpFile{
+		ID: "w", PodNamespace: "default", InstanceName: "milvus-prod",
+		Executable: "milvus", Signal: 11, ValueScore: 5,
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.notifications()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sent := recorder.notifications()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(sent))
+	}
+
+	counts := map[string]int{}
+	for _, notification := range sent {
+		counts[notification.Instance] = notification.Count
+		if notification.Severity != SeverityCritical {
+			t.Errorf("expected critical severity, got %s", notification.Severity)
+		}
+	}
+	if counts["milvus-prod"] != 20 || counts["milvus-dev"] != 1 {
+		t.Errorf("unexpected grouped counts: %v", counts)
+	}
+	for _, notification := range sent {
+		if notification.Instance == "milvus-prod" && len(notification.Pods) != 4 {
+			t.Errorf("expected 4 distinct pods, got %v", notification.Pods)
+		}
+	}
+
+	alerter.FlushAll()
+	sent = recorder.notifications()
+	if len(sent) != 3 || sent[2].Severity != SeverityWarning {
+		t.Errorf("expected pending warning group to be flushed, got %d notifications", len(sent))
+	}
+}
+
+func TestAlerterDowngradesCrashesUnderChaos(t *testing.T) {
+	recorder := &recordingSender{}
+	alerter, err := NewAlerter(&config.AlertingConfig{GroupWindow: time.Hour}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.send = recorder.send
+
+	experiment := chaos.Experiment{Source: chaos.SourceChaosMesh, Namespace: "chaos-testing", Name: "kill-querynode"}
+	for i := 0; i < 2; i++ {
+		alerter.Observe(&collector.CoredumpFile{
+			ID: string(rune('a' + i)), PodNamespace: "default", InstanceName: "milvus-prod",
+			Executable: "milvus", Signal: 11, ValueScore: 9,
+			UnderChaos: true, ChaosExperiments: []chaos.Experiment{experiment},
+		})
+	}
+	// The same crash without chaos is grouped separately.
+	alerter.Observe(&collector.CoredumpFile{
+		ID: "c", PodNamespace: "default", InstanceName: "milvus-prod",
+		Executable: "milvus", Signal: 11, ValueScore: 9,
+	})
+	alerter.FlushAll()
+
+	sent := recorder.notifications()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(sent))
+	}
+	for _, notification := range sent {
+		if !notification.UnderChaos {
+			if notification.Severity != SeverityCritical {
+				t.Errorf("expected critical severity without chaos, got %s", notification.Severity)
+			}
+			continue
+		}
+		if notification.Severity != SeverityWarning || notification.Count != 2 {
+			t.Errorf("expected 2 crashes as warning under chaos, got %d as %s", notification.Count, notification.Severity)
+		}
+		if len(notification.ChaosExperiments) != 1 || notification.ChaosExperiments[0] != "chaos-testing/kill-querynode" {
+			t.Errorf("unexpected chaos experiments: %v", notification.ChaosExperiments)
+		}
+	}
+}
+
+func TestAlerterSendsWatchlistCrashesRightAway(t *testing.T) {
+	recorder := &recordingSender{}
+	alerter, err := NewAlerter(&config.AlertingConfig{GroupWindow: time.Hour}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.send = recorder.send
+
+	alerter.Observe(&collector.CoredumpFile{
+		ID: "a", PodNamespace: "default", InstanceName: "milvus-prod",
+		Executable: "milvus", Signal: 11, ValueScore: 3, UnderChaos: true,
+		Watchlist: []string{"hnsw-null-segment"},
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.notifications()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := recorder.notifications()
+	if len(sent) != 1 {
+		t.Fatalf("expected the watched crash to be sent without waiting for the window, got %d", len(sent))
+	}
+	if sent[0].Severity != SeverityCritical || len(sent[0].Watchlist) != 1 {
+		t.Errorf("expected a critical alert naming the watchlist entry, got %+v", sent[0])
+	}
+}
+
+func TestAlerterPostsWebhook(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	alerter, err := NewAlerter(&config.AlertingConfig{WebhookURL: server.URL}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.Observe(&collector.CoredumpFile{ID: "a", InstanceName: "milvus-dev", ValueScore: 9})
+
+	select {
+	case contentType := <-received:
+		if contentType != "application/json" {
+			t.Errorf("expected JSON payload, got %s", contentType)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestAlerterAlertsOnCleanupAndAIBudget(t *testing.T) {
+	recorder := &recordingSender{}
+	alerter, err := NewAlerter(&config.AlertingConfig{GroupWindow: time.Hour}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.send = recorder.send
+
+	alerter.ObserveCleanup(cleaner.CleanupEvent{Type: cleaner.EventTypeInstanceUninstalled, InstanceName: "milvus-prod", Namespace: "default", Reason: "crash loop"})
+	alerter.ObserveCleanup(cleaner.CleanupEvent{Type: cleaner.EventTypeRestartThreshold, InstanceName: "milvus-prod", Namespace: "default"})
+
+	exhausted := &collector.AIAnalysisResult{Enabled: true, ErrorMessage: "Analysis skipped due to cost control limits", CostLimitReached: "hourly limit of 10 analyses"}
+	// Only the first skipped analysis alerts, until one runs again.
+	alerter.ObserveAIAnalysis(exhausted)
+	alerter.ObserveAIAnalysis(exhausted)
+	alerter.ObserveAIAnalysis(&collector.AIAnalysisResult{Enabled: true, ErrorMessage: "API error"})
+	alerter.ObserveAIAnalysis(exhausted)
+	alerter.ObserveAIAnalysis(&collector.AIAnalysisResult{Enabled: true, Summary: "nil pointer"})
+	alerter.ObserveAIAnalysis(exhausted)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.notifications()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	kinds := map[string]int{}
+	for _, notification := range recorder.notifications() {
+		kinds[notification.Kind]++
+		if notification.Kind == AlertKindCleanup && (notification.Severity != SeverityCritical || notification.Message != "Uninstalled Milvus instance milvus-prod: crash loop") {
+			t.Errorf("unexpected cleanup alert %+v", notification)
+		}
+	}
+	if kinds[AlertKindCleanup] != 1 || kinds[AlertKindAIBudget] != 2 {
+		t.Errorf("expected one cleanup and two budget alerts, got %v", kinds)
+	}
+}
+
+func TestAlerterAppliesAlertRules(t *testing.T) {
+	recorder := &recordingSender{}
+	alerter, err := NewAlerter(&config.AlertingConfig{}, config.ScoreThresholds{Critical: 8})
+	if err != nil {
+		t.Fatalf("failed to create alerter: %v", err)
+	}
+	alerter.send = recorder.send
+	alerter.rules, err = alertrules.New([]config.AlertRuleConfig{{Name: "low", Kinds: []string{"crash"}, Severity: "info", DedupWindow: time.Hour}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suppressed := make(chan string, 3)
+	alerter.onSuppressed = func(notification *AlertNotification, decision alertrules.Decision) {
+		suppressed <- decision.Reason
+	}
+
+	// Without a group window every crash alerts on its own.
+	crash := &collector.CoredumpFile{ID: "a", PodNamespace: "default", InstanceName: "milvus-prod", Executable: "milvus", Signal: 11, ValueScore: 9}
+	for i := 0; i < 3; i++ {
+		alerter.Observe(crash)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case reason := <-suppressed:
+			if reason != alertrules.ReasonDeduplicated {
+				t.Errorf("expected a deduplicated alert, got %s", reason)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected two alerts to be deduplicated")
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.notifications()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sent := recorder.notifications()
+	if len(sent) != 1 || sent[0].Severity != "info" || sent[0].Rule != "low" {
+		t.Fatalf("expected one info alert of the rule, got %+v", sent)
+	}
+}
@@ -0,0 +1,49 @@
+package monitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"milvus-coredump-agent/pkg/chanstats"
+)
+
+// channelCollector exports the drop counts and fill level of the agent's
+// internal event channels, read from chanstats at scrape time.
+type channelCollector struct {
+	dropped     *prometheus.Desc
+	length      *prometheus.Desc
+	utilization *prometheus.Desc
+}
+
+func newChannelCollector() *channelCollector {
+	return &channelCollector{
+		dropped: prometheus.NewDesc(
+			"milvus_coredump_agent_events_dropped_total",
+			"Total number of events dropped because an internal channel was full",
+			[]string{"channel"}, nil,
+		),
+		length: prometheus.NewDesc(
+			"milvus_coredump_agent_channel_length",
+			"Number of events waiting in an internal channel",
+			[]string{"channel"}, nil,
+		),
+		utilization: prometheus.NewDesc(
+			"milvus_coredump_agent_channel_utilization_ratio",
+			"Fraction of an internal channel's buffer in use",
+			[]string{"channel"}, nil,
+		),
+	}
+}
+
+func (c *channelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.dropped
+	ch <- c.length
+	ch <- c.utilization
+}
+
+func (c *channelCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range chanstats.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(stats.Dropped), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.length, prometheus.GaugeValue, float64(stats.Length), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.utilization, prometheus.GaugeValue, stats.Utilization(), stats.Name)
+	}
+}
@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"milvus-coredump-agent/pkg/discovery"
+)
+
+// informerCollector exports the state of discovery's pod caches, read at
+// scrape time.
+type informerCollector struct {
+	discovery *discovery.Discovery
+	pods      *prometheus.Desc
+	synced    *prometheus.Desc
+	events    *prometheus.Desc
+}
+
+func newInformerCollector(discoveryManager *discovery.Discovery) *informerCollector {
+	return &informerCollector{
+		discovery: discoveryManager,
+		pods: prometheus.NewDesc(
+			"milvus_coredump_agent_informer_cached_pods",
+			"Number of pods in discovery's cache of a namespace",
+			[]string{"namespace"}, nil,
+		),
+		synced: prometheus.NewDesc(
+			"milvus_coredump_agent_informer_synced",
+			"Whether discovery's pod cache of a namespace has synced (1) or not (0)",
+			[]string{"namespace"}, nil,
+		),
+		events: prometheus.NewDesc(
+			"milvus_coredump_agent_informer_events_total",
+			"Total number of pod events discovery's cache of a namespace received, updates counting resyncs",
+			[]string{"namespace", "type"}, nil,
+		),
+	}
+}
+
+func (c *informerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pods
+	ch <- c.synced
+	ch <- c.events
+}
+
+func (c *informerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.discovery.InformerStats() {
+		// The cache of all namespaces has no namespace.
+		namespace := stats.Namespace
+		if namespace == "" {
+			namespace = "*"
+		}
+		synced := 0.0
+		if stats.Synced {
+			synced = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.pods, prometheus.GaugeValue, float64(stats.Pods), namespace)
+		ch <- prometheus.MustNewConstMetric(c.synced, prometheus.GaugeValue, synced, namespace)
+		ch <- prometheus.MustNewConstMetric(c.events, prometheus.CounterValue, float64(stats.Adds), namespace, "add")
+		ch <- prometheus.MustNewConstMetric(c.events, prometheus.CounterValue, float64(stats.Updates), namespace, "update")
+		ch <- prometheus.MustNewConstMetric(c.events, prometheus.CounterValue, float64(stats.Deletes), namespace, "delete")
+	}
+}
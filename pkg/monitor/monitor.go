@@ -3,15 +3,20 @@ package monitor
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"k8s.io/klog/v2"
 
+	"milvus-coredump-agent/pkg/alertrules"
 	"milvus-coredump-agent/pkg/analyzer"
 	"milvus-coredump-agent/pkg/cleaner"
 	"milvus-coredump-agent/pkg/collector"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/discovery"
+	"milvus-coredump-agent/pkg/pressure"
+	"milvus-coredump-agent/pkg/procwatch"
 	"milvus-coredump-agent/pkg/storage"
 )
 
@@ -19,6 +24,7 @@ type Monitor struct {
 	config   *config.MonitorConfig
 	registry *prometheus.Registry
 	metrics  *Metrics
+	alerter  *Alerter
 }
 
 type Channels struct {
@@ -26,6 +32,7 @@ type Channels struct {
 	AnalyzerEvents  <-chan analyzer.AnalysisEvent
 	StorageEvents   <-chan storage.StorageEvent
 	CleanerEvents   <-chan cleaner.CleanupEvent
+	StateTransitions <-chan collector.StateTransition
 }
 
 type Metrics struct {
@@ -41,12 +48,23 @@ type Metrics struct {
 	AnalysisFailed       prometheus.Counter
 	AnalysisDuration     prometheus.Histogram
 	ValueScoreDistribution prometheus.Histogram
+	AnalysisQueueDepth   prometheus.GaugeFunc
+	AnalysisWorkersBusy  prometheus.GaugeFunc
+	AnalysisWorkers      prometheus.GaugeFunc
 	
 	// Storage metrics
 	FilesStored          prometheus.Counter
-	StorageSize          prometheus.Gauge
+	FilesStoredByBackend *prometheus.CounterVec
+	StorageSize          prometheus.GaugeFunc
 	StorageErrors        prometheus.Counter
 	FilesDeleted         prometheus.Counter
+	FilesDeduplicated    prometheus.Counter
+	
+	// Alerting metrics
+	AlertsSent           *prometheus.CounterVec
+	AlertsGrouped        prometheus.Counter
+	AlertErrors          prometheus.Counter
+	AlertsSuppressed     *prometheus.CounterVec
 	
 	// Cleanup metrics
 	InstancesUninstalled prometheus.Counter
@@ -57,9 +75,41 @@ type Metrics struct {
 	AgentUp              prometheus.Gauge
 	MilvusInstancesTotal *prometheus.GaugeVec
 	LastProcessedFile    prometheus.Gauge
+	
+	// Resource pressure metrics
+	DegradedMode         prometheus.GaugeFunc
+	MemoryUsageBytes     prometheus.GaugeFunc
+	MemoryUsageRatio     prometheus.GaugeFunc
+	CPUUsageRatio        prometheus.GaugeFunc
+	
+	// Subprocess watchdog metrics
+	SubprocessesRunning  prometheus.GaugeFunc
+	SubprocessTimeouts   prometheus.CounterFunc
+	OrphansKilled        prometheus.CounterFunc
+	ZombiesReaped        prometheus.CounterFunc
+	SubprocessesStuck    prometheus.CounterFunc
+	
+	// Coredump lifecycle metrics
+	StateTransitions     *prometheus.CounterVec
+	RejectedTransitions  prometheus.CounterFunc
+	LifecycleDuration    *prometheus.HistogramVec
+	LifecycleSLATarget   *prometheus.GaugeVec
+	LifecycleSLABreaches *prometheus.CounterVec
+	
+	// Internal event channel metrics
+	EventChannels        prometheus.Collector
+
+	// Storage efficiency metrics
+	StorageEfficiency    prometheus.Collector
+
+	// API stats cache metrics
+	StatsCaches          prometheus.Collector
+
+	// Discovery pod cache metrics
+	Informers            prometheus.Collector
 }
 
-func New(config *config.MonitorConfig) *Monitor {
+func New(config *config.MonitorConfig, thresholds config.ScoreThresholds, tracker *pressure.Tracker, watchdog *procwatch.Watchdog, states *collector.StateMachine, discoveryManager *discovery.Discovery, analyzerManager *analyzer.Analyzer, storageManager *storage.Storage) *Monitor {
 	registry := prometheus.NewRegistry()
 	
 	metrics := &Metrics{
@@ -101,13 +151,37 @@ func New(config *config.MonitorConfig) *Monitor {
 			Help:    "Distribution of coredump value scores",
 			Buckets: prometheus.LinearBuckets(0, 1, 11),
 		}),
+		AnalysisQueueDepth: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_analysis_queue_depth",
+			Help: "Number of coredumps waiting for an analysis worker",
+		}, func() float64 {
+			return float64(analyzerManager.PoolStats().Queued)
+		}),
+		AnalysisWorkersBusy: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_analysis_workers_busy",
+			Help: "Number of analysis workers currently analyzing a coredump",
+		}, func() float64 {
+			return float64(analyzerManager.PoolStats().Busy)
+		}),
+		AnalysisWorkers: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_analysis_workers",
+			Help: "Maximum number of coredumps analyzed concurrently",
+		}, func() float64 {
+			return float64(analyzerManager.PoolStats().Workers)
+		}),
 		FilesStored: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "milvus_coredump_agent_files_stored_total",
 			Help: "Total number of coredump files stored",
 		}),
-		StorageSize: prometheus.NewGauge(prometheus.GaugeOpts{
+		FilesStoredByBackend: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_files_stored_by_backend_total",
+			Help: "Total number of coredump files written to each storage backend, by role (primary, secondary)",
+		}, []string{"backend"}),
+		StorageSize: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
 			Name: "milvus_coredump_agent_storage_size_bytes",
 			Help: "Current storage size in bytes",
+		}, func() float64 {
+			return float64(storageManager.Efficiency().UsedBytes)
 		}),
 		StorageErrors: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "milvus_coredump_agent_storage_errors_total",
@@ -117,6 +191,26 @@ func New(config *config.MonitorConfig) *Monitor {
 			Name: "milvus_coredump_agent_files_deleted_total",
 			Help: "Total number of files deleted during cleanup",
 		}),
+		FilesDeduplicated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_files_deduplicated_total",
+			Help: "Total number of coredump files recorded as metadata only because of a matching crash fingerprint",
+		}),
+		AlertsSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_alerts_sent_total",
+			Help: "Total number of crash alert notifications sent",
+		}, []string{"severity"}),
+		AlertsGrouped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_alerts_grouped_total",
+			Help: "Total number of crashes folded into an existing alert instead of alerting separately",
+		}),
+		AlertsSuppressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_alerts_suppressed_total",
+			Help: "Total number of alerts not sent, by reason (silenced, deduplicated)",
+		}, []string{"reason"}),
+		AlertErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_alert_errors_total",
+			Help: "Total number of failed alert deliveries",
+		}),
 		InstancesUninstalled: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "milvus_coredump_agent_instances_uninstalled_total",
 			Help: "Total number of Milvus instances uninstalled",
@@ -141,6 +235,90 @@ func New(config *config.MonitorConfig) *Monitor {
 			Name: "milvus_coredump_agent_last_processed_file_timestamp",
 			Help: "Timestamp of the last processed coredump file",
 		}),
+		DegradedMode: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_degraded_mode",
+			Help: "Whether the agent is throttling itself because of resource pressure",
+		}, func() float64 {
+			if tracker.Degraded() {
+				return 1
+			}
+			return 0
+		}),
+		MemoryUsageBytes: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_memory_usage_bytes",
+			Help: "Memory used by the agent's cgroup",
+		}, func() float64 {
+			return float64(tracker.Status().MemoryBytes)
+		}),
+		MemoryUsageRatio: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_memory_usage_ratio",
+			Help: "Memory used by the agent as a fraction of its cgroup limit",
+		}, func() float64 {
+			return tracker.Status().MemoryRatio
+		}),
+		CPUUsageRatio: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_cpu_usage_ratio",
+			Help: "CPU used by the agent as a fraction of its cgroup quota",
+		}, func() float64 {
+			return tracker.Status().CPURatio
+		}),
+		SubprocessesRunning: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_subprocesses_running",
+			Help: "Number of analysis subprocesses currently running",
+		}, func() float64 {
+			return float64(watchdog.Stats().Running)
+		}),
+		SubprocessTimeouts: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_subprocess_timeouts_total",
+			Help: "Total number of analysis subprocesses killed for exceeding their timeout",
+		}, func() float64 {
+			return float64(watchdog.Stats().TimedOut)
+		}),
+		OrphansKilled: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_orphan_processes_killed_total",
+			Help: "Total number of processes left running by an analysis subprocess and killed",
+		}, func() float64 {
+			return float64(watchdog.Stats().OrphansKilled)
+		}),
+		ZombiesReaped: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_zombie_processes_reaped_total",
+			Help: "Total number of zombie processes reaped by the agent",
+		}, func() float64 {
+			return float64(watchdog.Stats().ZombiesReaped)
+		}),
+		SubprocessesStuck: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_subprocesses_stuck_total",
+			Help: "Total number of analysis subprocesses that did not exit after SIGKILL",
+		}, func() float64 {
+			return float64(watchdog.Stats().Stuck)
+		}),
+		StateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_state_transitions_total",
+			Help: "Total number of coredump status transitions",
+		}, []string{"from", "to"}),
+		RejectedTransitions: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_rejected_state_transitions_total",
+			Help: "Total number of coredump status changes rejected as invalid or conflicting",
+		}, func() float64 {
+			return float64(states.Rejected())
+		}),
+		LifecycleDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "milvus_coredump_agent_lifecycle_stage_duration_seconds",
+			Help:    "Time coredumps took through each stage of the pipeline, from crash to discovered, analyzed and stored",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		}, []string{"stage"}),
+		LifecycleSLATarget: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "milvus_coredump_agent_lifecycle_sla_target_seconds",
+			Help: "Configured SLA target of each pipeline stage",
+		}, []string{"stage"}),
+		LifecycleSLABreaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "milvus_coredump_agent_lifecycle_sla_breaches_total",
+			Help: "Total number of coredumps that took longer than the SLA target through a pipeline stage",
+		}, []string{"stage"}),
+		EventChannels: newChannelCollector(),
+		StorageEfficiency: newStorageCollector(storageManager),
+		StatsCaches: newStatsCacheCollector(),
+		Informers: newInformerCollector(discoveryManager),
 	}
 
 	registry.MustRegister(
@@ -153,23 +331,88 @@ func New(config *config.MonitorConfig) *Monitor {
 		metrics.AnalysisFailed,
 		metrics.AnalysisDuration,
 		metrics.ValueScoreDistribution,
+		metrics.AnalysisQueueDepth,
+		metrics.AnalysisWorkersBusy,
+		metrics.AnalysisWorkers,
 		metrics.FilesStored,
+		metrics.FilesStoredByBackend,
 		metrics.StorageSize,
 		metrics.StorageErrors,
 		metrics.FilesDeleted,
+		metrics.FilesDeduplicated,
+		metrics.AlertsSent,
+		metrics.AlertsGrouped,
+		metrics.AlertErrors,
+		metrics.AlertsSuppressed,
 		metrics.InstancesUninstalled,
 		metrics.CleanupErrors,
 		metrics.RestartCounts,
 		metrics.AgentUp,
 		metrics.MilvusInstancesTotal,
 		metrics.LastProcessedFile,
+		metrics.DegradedMode,
+		metrics.MemoryUsageBytes,
+		metrics.MemoryUsageRatio,
+		metrics.CPUUsageRatio,
+		metrics.SubprocessesRunning,
+		metrics.SubprocessTimeouts,
+		metrics.OrphansKilled,
+		metrics.ZombiesReaped,
+		metrics.SubprocessesStuck,
+		metrics.StateTransitions,
+		metrics.RejectedTransitions,
+		metrics.LifecycleDuration,
+		metrics.LifecycleSLATarget,
+		metrics.LifecycleSLABreaches,
+		metrics.EventChannels,
+		metrics.StorageEfficiency,
+		metrics.StatsCaches,
+		metrics.Informers,
 	)
 
-	return &Monitor{
+	for _, stage := range collector.LifecycleStages {
+		if target := collector.SLATarget(&config.LifecycleSLA, stage); target > 0 {
+			metrics.LifecycleSLATarget.WithLabelValues(string(stage)).Set(target.Seconds())
+		}
+	}
+
+	monitor := &Monitor{
 		config:   config,
 		registry: registry,
 		metrics:  metrics,
 	}
+
+	if config.Alerting.Enabled && (config.Alerting.WebhookURL != "" || len(config.Alerting.Channels) > 0) {
+		alerter, err := NewAlerter(&config.Alerting, thresholds)
+		if err != nil {
+			klog.Errorf("Failed to initialize alerting, alerts are disabled: %v", err)
+		} else {
+			alerter.onSent = func(notification *AlertNotification, err error) {
+				if err != nil {
+					metrics.AlertErrors.Inc()
+					return
+				}
+				metrics.AlertsSent.WithLabelValues(notification.Severity).Inc()
+				if notification.Kind == AlertKindCrash {
+					metrics.AlertsGrouped.Add(float64(notification.Count - 1))
+				}
+			}
+			alerter.onSuppressed = func(notification *AlertNotification, decision alertrules.Decision) {
+				metrics.AlertsSuppressed.WithLabelValues(decision.Reason).Inc()
+			}
+			monitor.alerter = alerter
+		}
+	}
+
+	return monitor
+}
+
+// UseAlertRules has the alert rules and silences of engine decide which
+// alerts are sent. Call it before Start.
+func (m *Monitor) UseAlertRules(engine *alertrules.Engine) {
+	if m.alerter != nil {
+		m.alerter.rules = engine
+	}
 }
 
 func (m *Monitor) Start(ctx context.Context, channels *Channels) error {
@@ -181,14 +424,49 @@ func (m *Monitor) Start(ctx context.Context, channels *Channels) error {
 	go m.processAnalyzerEvents(ctx, channels.AnalyzerEvents)
 	go m.processStorageEvents(ctx, channels.StorageEvents)
 	go m.processCleanerEvents(ctx, channels.CleanerEvents)
+	go m.processStateTransitions(ctx, channels.StateTransitions)
 
 	<-ctx.Done()
+	if m.alerter != nil {
+		m.alerter.FlushAll()
+	}
 	m.metrics.AgentUp.Set(0)
 	return nil
 }
 
 func (m *Monitor) GetHandler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	// Exemplars are only exposed in the OpenMetrics format, which Prometheus
+	// negotiates when exemplar storage is enabled.
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
+
+// coredumpExemplar labels a sample with the coredump's correlation ID so a
+// spike in Grafana links straight to /api/v1/coredumps/<id>.
+func coredumpExemplar(coredump *collector.CoredumpFile) prometheus.Labels {
+	if coredump == nil || coredump.ID == "" {
+		return nil
+	}
+	return prometheus.Labels{"coredump_id": coredump.ID}
+}
+
+func incWithExemplar(counter prometheus.Counter, coredump *collector.CoredumpFile) {
+	exemplar := coredumpExemplar(coredump)
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		adder.AddWithExemplar(1, exemplar)
+		return
+	}
+	counter.Inc()
+}
+
+func observeWithExemplar(histogram prometheus.Observer, value float64, coredump *collector.CoredumpFile) {
+	exemplar := coredumpExemplar(coredump)
+	if observer, ok := histogram.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		observer.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	histogram.Observe(value)
 }
 
 func (m *Monitor) processCollectorEvents(ctx context.Context, events <-chan collector.CollectionEvent) {
@@ -199,9 +477,10 @@ func (m *Monitor) processCollectorEvents(ctx context.Context, events <-chan coll
 		case event := <-events:
 			switch event.Type {
 			case collector.EventTypeFileDiscovered:
-				m.metrics.CoredumpsDiscovered.Inc()
+				incWithExemplar(m.metrics.CoredumpsDiscovered, event.CoredumpFile)
 				if event.CoredumpFile != nil {
 					m.metrics.LastProcessedFile.SetToCurrentTime()
+					m.observeStage(event.CoredumpFile, collector.StageCrashToDiscovered)
 				}
 			case collector.EventTypeFileProcessed:
 				m.metrics.CoredumpsProcessed.Inc()
@@ -214,6 +493,34 @@ func (m *Monitor) processCollectorEvents(ctx context.Context, events <-chan coll
 	}
 }
 
+// observeStage records how long the coredump took through stage and counts
+// it against the stage's SLA target.
+func (m *Monitor) observeStage(coredump *collector.CoredumpFile, stage collector.LifecycleStage) {
+	duration, ok := coredump.StageDuration(stage)
+	if !ok {
+		return
+	}
+	observeWithExemplar(m.metrics.LifecycleDuration.WithLabelValues(string(stage)), duration.Seconds(), coredump)
+
+	target := collector.SLATarget(&m.config.LifecycleSLA, stage)
+	if target > 0 && duration > target {
+		incWithExemplar(m.metrics.LifecycleSLABreaches.WithLabelValues(string(stage)), coredump)
+		klog.Warningf("Coredump %s took %s through %s, over the SLA target of %s",
+			coredump.ID, duration.Round(time.Second), stage, target)
+	}
+}
+
+func (m *Monitor) processStateTransitions(ctx context.Context, transitions <-chan collector.StateTransition) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case transition := <-transitions:
+			m.metrics.StateTransitions.WithLabelValues(string(transition.From), string(transition.To)).Inc()
+		}
+	}
+}
+
 func (m *Monitor) processAnalyzerEvents(ctx context.Context, events <-chan analyzer.AnalysisEvent) {
 	for {
 		select {
@@ -224,17 +531,21 @@ func (m *Monitor) processAnalyzerEvents(ctx context.Context, events <-chan analy
 			
 			switch event.Type {
 			case analyzer.EventTypeAnalysisComplete:
-				m.metrics.AnalysisSuccessful.Inc()
+				incWithExemplar(m.metrics.AnalysisSuccessful, event.CoredumpFile)
 				if event.CoredumpFile != nil && event.CoredumpFile.IsAnalyzed {
-					m.metrics.ValueScoreDistribution.Observe(event.CoredumpFile.ValueScore)
+					observeWithExemplar(m.metrics.ValueScoreDistribution, event.CoredumpFile.ValueScore, event.CoredumpFile)
 					
 					if !event.CoredumpFile.AnalysisTime.IsZero() {
 						duration := event.CoredumpFile.AnalysisTime.Sub(event.CoredumpFile.CreatedAt.Time)
-						m.metrics.AnalysisDuration.Observe(duration.Seconds())
+						observeWithExemplar(m.metrics.AnalysisDuration, duration.Seconds(), event.CoredumpFile)
 					}
+					m.observeStage(event.CoredumpFile, collector.StageDiscoveredToAnalyzed)
+				}
+				if m.alerter != nil && event.CoredumpFile != nil && event.CoredumpFile.AnalysisResults != nil {
+					m.alerter.ObserveAIAnalysis(event.CoredumpFile.AnalysisResults.AIAnalysis)
 				}
 			case analyzer.EventTypeAnalysisError:
-				m.metrics.AnalysisFailed.Inc()
+				incWithExemplar(m.metrics.AnalysisFailed, event.CoredumpFile)
 			}
 		}
 	}
@@ -248,22 +559,45 @@ func (m *Monitor) processStorageEvents(ctx context.Context, events <-chan storag
 		case event := <-events:
 			switch event.Type {
 			case storage.EventTypeFileStored:
-				m.metrics.FilesStored.Inc()
+				incWithExemplar(m.metrics.FilesStored, event.CoredumpFile)
+				if event.CoredumpFile != nil {
+					for _, backend := range event.CoredumpFile.StorageBackends {
+						m.metrics.FilesStoredByBackend.WithLabelValues(backend).Inc()
+					}
+					m.observeStage(event.CoredumpFile, collector.StageAnalyzedToStored)
+				}
+				m.alert(event.CoredumpFile)
 			case storage.EventTypeFileDeleted:
 				m.metrics.FilesDeleted.Inc()
+			case storage.EventTypeFileDeduplicated:
+				incWithExemplar(m.metrics.FilesDeduplicated, event.CoredumpFile)
+				if event.CoredumpFile != nil {
+					m.observeStage(event.CoredumpFile, collector.StageAnalyzedToStored)
+				}
+				m.alert(event.CoredumpFile)
 			case storage.EventTypeStorageError:
-				m.metrics.StorageErrors.Inc()
+				incWithExemplar(m.metrics.StorageErrors, event.CoredumpFile)
 			}
 		}
 	}
 }
 
+// alert notifies about crashes that were valuable enough to keep.
+func (m *Monitor) alert(coredump *collector.CoredumpFile) {
+	if m.alerter != nil && coredump != nil && !coredump.SelfTest {
+		m.alerter.Observe(coredump)
+	}
+}
+
 func (m *Monitor) processCleanerEvents(ctx context.Context, events <-chan cleaner.CleanupEvent) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case event := <-events:
+			if m.alerter != nil {
+				m.alerter.ObserveCleanup(event)
+			}
 			switch event.Type {
 			case cleaner.EventTypeInstanceUninstalled:
 				m.metrics.InstancesUninstalled.Inc()
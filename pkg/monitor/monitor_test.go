@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/cleaner"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/storage"
+)
+
+func TestCrashCountersCarryCoredumpExemplars(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	collectorEvents := make(chan collector.CollectionEvent, 1)
+	m := New(&config.MonitorConfig{PrometheusEnabled: true}, config.ScoreThresholds{}, nil, nil, nil, nil, nil, nil)
+	go m.Start(ctx, &Channels{
+		CollectorEvents: collectorEvents,
+		AnalyzerEvents:  make(chan analyzer.AnalysisEvent),
+		StorageEvents:   make(chan storage.StorageEvent),
+		CleanerEvents:   make(chan cleaner.CleanupEvent),
+	})
+
+	collectorEvents <- collector.CollectionEvent{
+		Type:         collector.EventTypeFileDiscovered,
+		CoredumpFile: &collector.CoredumpFile{ID: "abc123"},
+	}
+
+	server := httptest.NewServer(m.GetHandler())
+	defer server.Close()
+
+	expected := `milvus_coredump_agent_coredumps_discovered_total 1.0 # {coredump_id="abc123"}`
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to scrape metrics: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if strings.Contains(string(body), expected) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected exemplar line %q in:\n%s", expected, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLifecycleSLABreaches(t *testing.T) {
+	m := New(&config.MonitorConfig{
+		LifecycleSLA: config.LifecycleSLAConfig{DiscoveredToAnalyzed: time.Minute},
+	}, config.ScoreThresholds{}, nil, nil, nil, nil, nil, nil)
+
+	discovered := time.Now()
+	for _, took := range []time.Duration{30 * time.Second, 2 * time.Minute} {
+		m.observeStage(&collector.CoredumpFile{
+			ID:           "abc123",
+			CreatedAt:    metav1.NewTime(discovered),
+			AnalysisTime: discovered.Add(took),
+		}, collector.StageDiscoveredToAnalyzed)
+	}
+	// Not stored yet, so nothing to observe.
+	m.observeStage(&collector.CoredumpFile{AnalysisTime: discovered}, collector.StageAnalyzedToStored)
+
+	rec := httptest.NewRecorder()
+	m.GetHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`milvus_coredump_agent_lifecycle_stage_duration_seconds_count{stage="discovered_to_analyzed"} 2`,
+		`milvus_coredump_agent_lifecycle_sla_breaches_total{stage="discovered_to_analyzed"} 1`,
+		`milvus_coredump_agent_lifecycle_sla_target_seconds{stage="discovered_to_analyzed"} 60`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, `stage="analyzed_to_stored"`) {
+		t.Errorf("expected no samples for stages without target or observations:\n%s", body)
+	}
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"milvus-coredump-agent/pkg/statscache"
+)
+
+// statsCacheCollector exports the hit rates of the stats caches, read from
+// statscache at scrape time.
+type statsCacheCollector struct {
+	hits          *prometheus.Desc
+	misses        *prometheus.Desc
+	invalidations *prometheus.Desc
+	entries       *prometheus.Desc
+}
+
+func newStatsCacheCollector() *statsCacheCollector {
+	return &statsCacheCollector{
+		hits: prometheus.NewDesc(
+			"milvus_coredump_agent_stats_cache_hits_total",
+			"Total number of stats served from cache",
+			[]string{"cache"}, nil,
+		),
+		misses: prometheus.NewDesc(
+			"milvus_coredump_agent_stats_cache_misses_total",
+			"Total number of stats computed because the cache had no fresh entry",
+			[]string{"cache"}, nil,
+		),
+		invalidations: prometheus.NewDesc(
+			"milvus_coredump_agent_stats_cache_invalidations_total",
+			"Total number of times cached stats were dropped because the records changed",
+			[]string{"cache"}, nil,
+		),
+		entries: prometheus.NewDesc(
+			"milvus_coredump_agent_stats_cache_entries",
+			"Number of entries in a stats cache",
+			[]string{"cache"}, nil,
+		),
+	}
+}
+
+func (c *statsCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.invalidations
+	ch <- c.entries
+}
+
+func (c *statsCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range statscache.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.invalidations, prometheus.CounterValue, float64(stats.Invalidations), stats.Name)
+		ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(stats.Entries), stats.Name)
+	}
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"milvus-coredump-agent/pkg/storage"
+)
+
+// storageCollector exports the storage efficiency stats, read from the
+// storage manager at scrape time.
+type storageCollector struct {
+	storage *storage.Storage
+
+	compressionRatio *prometheus.Desc
+	originalBytes    *prometheus.Desc
+	compressedBytes  *prometheus.Desc
+	usage            *prometheus.Desc
+	capacity         *prometheus.Desc
+	ingestRate       *prometheus.Desc
+	daysUntilFull    *prometheus.Desc
+}
+
+func newStorageCollector(storage *storage.Storage) *storageCollector {
+	return &storageCollector{
+		storage: storage,
+		compressionRatio: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_compression_ratio",
+			"Stored size divided by original size of the coredumps stored since startup",
+			nil, nil,
+		),
+		originalBytes: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_original_bytes",
+			"Original size of the coredumps stored since startup",
+			nil, nil,
+		),
+		compressedBytes: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_compressed_bytes",
+			"Stored size of the coredumps stored since startup",
+			nil, nil,
+		),
+		usage: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_usage_bytes",
+			"Bytes used on the primary storage backend by each Milvus instance",
+			[]string{"namespace", "instance"}, nil,
+		),
+		capacity: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_capacity_bytes",
+			"Configured maximum storage size",
+			nil, nil,
+		),
+		ingestRate: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_ingest_bytes_per_day",
+			"Bytes stored per day over the last week",
+			nil, nil,
+		),
+		daysUntilFull: prometheus.NewDesc(
+			"milvus_coredump_agent_storage_days_until_full",
+			"Projected days until the storage reaches its maximum size at the current ingestion rate",
+			nil, nil,
+		),
+	}
+}
+
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.compressionRatio
+	ch <- c.originalBytes
+	ch <- c.compressedBytes
+	ch <- c.usage
+	ch <- c.capacity
+	ch <- c.ingestRate
+	ch <- c.daysUntilFull
+}
+
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.storage == nil {
+		return
+	}
+	stats := c.storage.Efficiency()
+	ch <- prometheus.MustNewConstMetric(c.compressionRatio, prometheus.GaugeValue, stats.CompressionRatio)
+	ch <- prometheus.MustNewConstMetric(c.originalBytes, prometheus.GaugeValue, float64(stats.OriginalBytes))
+	ch <- prometheus.MustNewConstMetric(c.compressedBytes, prometheus.GaugeValue, float64(stats.CompressedBytes))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(stats.CapacityBytes))
+	ch <- prometheus.MustNewConstMetric(c.ingestRate, prometheus.GaugeValue, stats.IngestBytesPerDay)
+	for _, bucket := range stats.ByInstance {
+		ch <- prometheus.MustNewConstMetric(c.usage, prometheus.GaugeValue, float64(bucket.StoredBytes), bucket.Namespace, bucket.Instance)
+	}
+	// Left out rather than reported as infinite while the rate is unknown.
+	if stats.DaysUntilFull != nil {
+		ch <- prometheus.MustNewConstMetric(c.daysUntilFull, prometheus.GaugeValue, *stats.DaysUntilFull)
+	}
+}
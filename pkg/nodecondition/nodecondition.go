@@ -0,0 +1,243 @@
+// Package nodecondition reports frequent Milvus crashes on a node as a node
+// condition, the way node-problem-detector does, so autoscalers and
+// remediation controllers that already act on node conditions can react to
+// them.
+package nodecondition
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	defaultConditionType = "MilvusFrequentCrashes"
+	defaultWindow        = time.Hour
+	defaultThreshold     = 5
+	defaultResyncPeriod  = time.Minute
+
+	reasonCrashing = "MilvusFrequentCrashes"
+	reasonHealthy  = "NoFrequentMilvusCrashes"
+	source         = "milvus-coredump-agent"
+)
+
+// Reporter counts coredumps found on this node over a sliding window and
+// keeps the node condition in sync with the threshold. The condition is
+// rewritten every resync period so its heartbeat shows the reporter is alive.
+type Reporter struct {
+	config     *config.NodeConditionConfig
+	kubeClient kubernetes.Interface
+	nodeName   string
+
+	mu         sync.Mutex
+	crashes    []time.Time
+	active     bool
+	reported   bool
+	transition time.Time
+}
+
+func New(config *config.NodeConditionConfig, kubeClient kubernetes.Interface, nodeName string) *Reporter {
+	return &Reporter{
+		config:     config,
+		kubeClient: kubeClient,
+		nodeName:   nodeName,
+	}
+}
+
+func (r *Reporter) Start(ctx context.Context, events <-chan collector.CollectionEvent) error {
+	if r.nodeName == "" {
+		return fmt.Errorf("node name is not set")
+	}
+	klog.Infof("Starting node condition reporter for %s (%s)", r.nodeName, r.conditionType())
+
+	r.sync(ctx, time.Now())
+
+	ticker := time.NewTicker(r.resyncPeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Type != collector.EventTypeFileDiscovered || event.CoredumpFile == nil || event.CoredumpFile.SelfTest {
+				continue
+			}
+			now := time.Now()
+			r.record(now)
+			r.sync(ctx, now)
+		case <-ticker.C:
+			r.sync(ctx, time.Now())
+		}
+	}
+}
+
+func (r *Reporter) record(at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crashes = append(r.crashes, at)
+}
+
+// evaluate drops crashes that left the window and returns the count inside
+// it and the condition's state. changed is set when the condition needs a new
+// transition time; notify when that change is worth an event, which the
+// initial False report is not.
+func (r *Reporter) evaluate(now time.Time) (count int, active, changed, notify bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := now.Add(-r.window())
+	kept := r.crashes[:0]
+	for _, at := range r.crashes {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	r.crashes = kept
+
+	active = len(r.crashes) >= r.threshold()
+	changed = !r.reported || active != r.active
+	notify = changed && (active || r.active)
+	return len(r.crashes), active, changed, notify
+}
+
+// commit records that the condition was written, so a failed update is
+// retried as a transition on the next sync.
+func (r *Reporter) commit(active bool, transition time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = active
+	r.reported = true
+	r.transition = transition
+}
+
+func (r *Reporter) sync(ctx context.Context, now time.Time) {
+	count, active, changed, notify := r.evaluate(now)
+
+	r.mu.Lock()
+	transition := r.transition
+	r.mu.Unlock()
+	if changed {
+		transition = now
+	}
+
+	condition := r.condition(count, active, now, transition)
+	if err := r.patchCondition(ctx, condition); err != nil {
+		klog.Errorf("Failed to update node condition %s on %s: %v", condition.Type, r.nodeName, err)
+		return
+	}
+	r.commit(active, transition)
+
+	if notify {
+		r.emitEvent(ctx, condition, active)
+	}
+}
+
+func (r *Reporter) condition(count int, active bool, now, transition time.Time) corev1.NodeCondition {
+	condition := corev1.NodeCondition{
+		Type:               corev1.NodeConditionType(r.conditionType()),
+		Status:             corev1.ConditionFalse,
+		Reason:             reasonHealthy,
+		Message:            fmt.Sprintf("%d Milvus coredumps in the last %v", count, r.window()),
+		LastHeartbeatTime:  metav1.NewTime(now),
+		LastTransitionTime: metav1.NewTime(transition),
+	}
+	if active {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonCrashing
+		condition.Message = fmt.Sprintf("%d Milvus coredumps in the last %v, threshold is %d", count, r.window(), r.threshold())
+	}
+	return condition
+}
+
+// patchCondition sets the condition with a strategic merge patch, which
+// merges node conditions by type and leaves the kubelet's alone.
+func (r *Reporter) patchCondition(ctx context.Context, condition corev1.NodeCondition) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.kubeClient.CoreV1().Nodes().PatchStatus(ctx, r.nodeName, patch)
+	return err
+}
+
+// emitEvent records the transition as an event on the node, in the shape
+// node-problem-detector uses for its own problems.
+func (r *Reporter) emitEvent(ctx context.Context, condition corev1.NodeCondition, active bool) {
+	eventType := corev1.EventTypeNormal
+	if active {
+		eventType = corev1.EventTypeWarning
+	}
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: r.nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: r.nodeName,
+			// The kubelet and node-problem-detector use the node name as
+			// its UID in events.
+			UID: types.UID(r.nodeName),
+		},
+		Reason:         condition.Reason,
+		Message:        condition.Message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: source, Host: r.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := r.kubeClient.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		klog.Errorf("Failed to record %s event on node %s: %v", condition.Reason, r.nodeName, err)
+		return
+	}
+	klog.Infof("Node %s: %s=%s (%s)", r.nodeName, condition.Type, condition.Status, condition.Message)
+}
+
+func (r *Reporter) conditionType() string {
+	if r.config.ConditionType == "" {
+		return defaultConditionType
+	}
+	return r.config.ConditionType
+}
+
+func (r *Reporter) window() time.Duration {
+	if r.config.Window <= 0 {
+		return defaultWindow
+	}
+	return r.config.Window
+}
+
+func (r *Reporter) threshold() int {
+	if r.config.Threshold <= 0 {
+		return defaultThreshold
+	}
+	return r.config.Threshold
+}
+
+func (r *Reporter) resyncPeriod() time.Duration {
+	if r.config.ResyncPeriod <= 0 {
+		return defaultResyncPeriod
+	}
+	return r.config.ResyncPeriod
+}
@@ -0,0 +1,109 @@
+package nodecondition
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func newTestReporter(t *testing.T) (*Reporter, *fake.Clientset) {
+	t.Helper()
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	client := fake.NewSimpleClientset(node)
+	reporter := New(&config.NodeConditionConfig{Window: time.Hour, Threshold: 2}, client, "node-1")
+	return reporter, client
+}
+
+func nodeCondition(t *testing.T, client *fake.Clientset, conditionType corev1.NodeConditionType) *corev1.NodeCondition {
+	t.Helper()
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == conditionType {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func eventReasons(t *testing.T, client *fake.Clientset) []string {
+	t.Helper()
+	var reasons []string
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" && action.GetResource().Resource == "events" {
+			reasons = append(reasons, action.(k8stesting.CreateAction).GetObject().(*corev1.Event).Reason)
+		}
+	}
+	return reasons
+}
+
+func TestReporterSetsConditionAboveThreshold(t *testing.T) {
+	reporter, client := newTestReporter(t)
+	ctx := context.Background()
+	start := time.Now()
+
+	reporter.sync(ctx, start)
+	condition := nodeCondition(t, client, "MilvusFrequentCrashes")
+	if condition == nil || condition.Status != corev1.ConditionFalse {
+		t.Fatalf("expected initial False condition, got %+v", condition)
+	}
+	if nodeCondition(t, client, corev1.NodeReady) == nil {
+		t.Fatal("patch must keep the kubelet's conditions")
+	}
+
+	reporter.record(start.Add(time.Minute))
+	reporter.sync(ctx, start.Add(time.Minute))
+	reporter.record(start.Add(2 * time.Minute))
+	reporter.sync(ctx, start.Add(2*time.Minute))
+
+	condition = nodeCondition(t, client, "MilvusFrequentCrashes")
+	if condition.Status != corev1.ConditionTrue || condition.Reason != reasonCrashing {
+		t.Fatalf("expected True condition, got %+v", condition)
+	}
+
+	// Both crashes leave the window.
+	reporter.sync(ctx, start.Add(2*time.Hour))
+	condition = nodeCondition(t, client, "MilvusFrequentCrashes")
+	if condition.Status != corev1.ConditionFalse || condition.Reason != reasonHealthy {
+		t.Fatalf("expected recovery to False, got %+v", condition)
+	}
+
+	reasons := eventReasons(t, client)
+	if len(reasons) != 2 || reasons[0] != reasonCrashing || reasons[1] != reasonHealthy {
+		t.Errorf("expected events for the two transitions, got %v", reasons)
+	}
+}
+
+func TestReporterKeepsTransitionTimeOnResync(t *testing.T) {
+	reporter, client := newTestReporter(t)
+	ctx := context.Background()
+	start := time.Now().Truncate(time.Second)
+
+	reporter.sync(ctx, start)
+	reporter.sync(ctx, start.Add(time.Minute))
+
+	condition := nodeCondition(t, client, "MilvusFrequentCrashes")
+	if !condition.LastTransitionTime.Time.Equal(start) {
+		t.Errorf("expected transition time %v, got %v", start, condition.LastTransitionTime)
+	}
+	if !condition.LastHeartbeatTime.Time.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected heartbeat at %v, got %v", start.Add(time.Minute), condition.LastHeartbeatTime)
+	}
+	if reasons := eventReasons(t, client); len(reasons) != 0 {
+		t.Errorf("expected no events without crashes, got %v", reasons)
+	}
+}
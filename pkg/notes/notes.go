@@ -0,0 +1,224 @@
+// Package notes keeps the notes engineers attach to coredumps and crash
+// groups while triaging them, such as how a crash was reproduced or which
+// change fixed it, so the findings stay next to the crash data.
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+const (
+	MaxBodySize   = 16 << 10
+	MaxAuthorSize = 100
+	// MaxPerSubject bounds the notes on one coredump or crash group.
+	MaxPerSubject = 100
+)
+
+var (
+	ErrNotFound = errors.New("note not found")
+	ErrInvalid  = errors.New("invalid note")
+	// ErrVersionConflict is returned when a note was edited since the
+	// version the editor started from.
+	ErrVersionConflict = errors.New("note was edited concurrently")
+	ErrTooMany         = errors.New("too many notes")
+)
+
+// Kind is what a note is attached to.
+type Kind string
+
+const (
+	KindCoredump   Kind = "coredump"
+	KindCrashGroup Kind = "crash-group"
+)
+
+// Subject identifies the coredump or crash group a note is attached to.
+type Subject struct {
+	Kind Kind
+	ID   string
+}
+
+func (s Subject) key() string {
+	return string(s.Kind) + "/" + s.ID
+}
+
+// Revision is one version of a note's text.
+type Revision struct {
+	Version   int       `json:"version"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Note is a markdown note and the earlier versions of its text, oldest
+// first. Author, Body and UpdatedAt are those of the latest version.
+type Note struct {
+	ID        int        `json:"id"`
+	Version   int        `json:"version"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	History   []Revision `json:"history,omitempty"`
+}
+
+func (n *Note) copy() Note {
+	c := *n
+	c.History = append([]Revision(nil), n.History...)
+	return c
+}
+
+// Book holds the notes, kept in statePath when set.
+type Book struct {
+	statePath string
+
+	mu       sync.Mutex
+	nextID   int
+	subjects map[string][]*Note
+}
+
+type state struct {
+	NextID   int                `json:"nextId"`
+	Subjects map[string][]*Note `json:"subjects"`
+}
+
+// New creates the book, restoring the notes saved in statePath.
+func New(statePath string) *Book {
+	b := &Book{statePath: statePath, nextID: 1, subjects: make(map[string][]*Note)}
+	b.load()
+	return b
+}
+
+// List returns copies of the subject's notes, oldest first.
+func (b *Book) List(subject Subject) []Note {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	notes := make([]Note, 0, len(b.subjects[subject.key()]))
+	for _, note := range b.subjects[subject.key()] {
+		notes = append(notes, note.copy())
+	}
+	return notes
+}
+
+// Add attaches a new note to the subject.
+func (b *Book) Add(subject Subject, author, body string, now time.Time) (Note, error) {
+	author, body, err := validate(author, body)
+	if err != nil {
+		return Note{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := subject.key()
+	if len(b.subjects[key]) >= MaxPerSubject {
+		return Note{}, fmt.Errorf("%w: a %s has at most %d notes", ErrTooMany, subject.Kind, MaxPerSubject)
+	}
+	note := &Note{ID: b.nextID, Version: 1, Author: author, Body: body, CreatedAt: now, UpdatedAt: now}
+	b.nextID++
+	b.subjects[key] = append(b.subjects[key], note)
+	b.save()
+	return note.copy(), nil
+}
+
+// Edit replaces the text of a note, keeping the previous one in its
+// history. version is the version the edit was made from; it fails with
+// ErrVersionConflict when the note was edited since.
+func (b *Book) Edit(subject Subject, id, version int, author, body string, now time.Time) (Note, error) {
+	author, body, err := validate(author, body)
+	if err != nil {
+		return Note{}, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, note := range b.subjects[subject.key()] {
+		if note.ID != id {
+			continue
+		}
+		if note.Version != version {
+			return Note{}, fmt.Errorf("%w: note %d is at version %d", ErrVersionConflict, id, note.Version)
+		}
+		note.History = append(note.History, Revision{
+			Version:   note.Version,
+			Author:    note.Author,
+			Body:      note.Body,
+			CreatedAt: note.UpdatedAt,
+		})
+		note.Version++
+		note.Author = author
+		note.Body = body
+		note.UpdatedAt = now
+		b.save()
+		return note.copy(), nil
+	}
+	return Note{}, ErrNotFound
+}
+
+func validate(author, body string) (string, string, error) {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return "", "", fmt.Errorf("%w: author is required", ErrInvalid)
+	}
+	if len(author) > MaxAuthorSize {
+		return "", "", fmt.Errorf("%w: author must not exceed %d bytes", ErrInvalid, MaxAuthorSize)
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", "", fmt.Errorf("%w: body is required", ErrInvalid)
+	}
+	if len(body) > MaxBodySize {
+		return "", "", fmt.Errorf("%w: body must not exceed %d bytes", ErrInvalid, MaxBodySize)
+	}
+	return author, body, nil
+}
+
+func (b *Book) load() {
+	if b.statePath == "" {
+		return
+	}
+	var saved state
+	if err := statefile.Load(b.statePath, &saved); err != nil {
+		klog.Warningf("Starting without notes: %v", err)
+		return
+	}
+	count := 0
+	for key, notes := range saved.Subjects {
+		for _, note := range notes {
+			if note == nil {
+				continue
+			}
+			b.subjects[key] = append(b.subjects[key], note)
+			if note.ID >= b.nextID {
+				b.nextID = note.ID + 1
+			}
+			count++
+		}
+		sort.Slice(b.subjects[key], func(i, j int) bool {
+			return b.subjects[key][i].ID < b.subjects[key][j].ID
+		})
+	}
+	if saved.NextID > b.nextID {
+		b.nextID = saved.NextID
+	}
+	if count > 0 {
+		klog.Infof("Restored %d notes from %s", count, b.statePath)
+	}
+}
+
+// save must be called with b.mu held.
+func (b *Book) save() {
+	if b.statePath == "" {
+		return
+	}
+	if err := statefile.Save(b.statePath, state{NextID: b.nextID, Subjects: b.subjects}); err != nil {
+		klog.Warningf("Failed to save notes: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package notes
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEditKeepsHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.json")
+	book := New(path)
+	core := Subject{Kind: KindCoredump, ID: "abc123"}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	note, err := book.Add(core, " alice ", "Reproduced with `bulk_insert.py`", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.ID != 1 || note.Version != 1 || note.Author != "alice" {
+		t.Errorf("unexpected note %+v", note)
+	}
+
+	edited, err := book.Edit(core, note.ID, 1, "bob", "Fixed in PR 1234", created.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Version != 2 || edited.Author != "bob" || !edited.CreatedAt.Equal(created) || len(edited.History) != 1 {
+		t.Fatalf("unexpected edited note %+v", edited)
+	}
+	if previous := edited.History[0]; previous.Version != 1 || previous.Author != "alice" || !strings.Contains(previous.Body, "bulk_insert") {
+		t.Errorf("expected the first version in the history, got %+v", previous)
+	}
+
+	// An edit made from the first version would overwrite bob's.
+	if _, err := book.Edit(core, note.ID, 1, "carol", "Not fixed", created.Add(2*time.Hour)); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("expected a version conflict, got %v", err)
+	}
+	if _, err := book.Edit(core, 99, 1, "carol", "Not fixed", created); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unknown note not to be found, got %v", err)
+	}
+	if _, err := book.Edit(Subject{Kind: KindCrashGroup, ID: "abc123"}, note.ID, 2, "carol", "Not fixed", created); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected notes to be kept per subject, got %v", err)
+	}
+
+	restored := New(path)
+	if notes := restored.List(core); len(notes) != 1 || notes[0].Version != 2 || len(notes[0].History) != 1 {
+		t.Fatalf("expected the note to survive a restart, got %+v", notes)
+	}
+	if next, err := restored.Add(core, "alice", "Another", created); err != nil || next.ID != 2 {
+		t.Errorf("expected note ids not to be reused, got %+v (%v)", next, err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	book := New("")
+	group := Subject{Kind: KindCrashGroup, ID: "fp"}
+
+	for _, c := range []struct{ author, body string }{
+		{"", "body"},
+		{"alice", "  \n"},
+		{strings.Repeat("a", MaxAuthorSize+1), "body"},
+		{"alice", strings.Repeat("b", MaxBodySize+1)},
+	} {
+		if _, err := book.Add(group, c.author, c.body, time.Now()); !errors.Is(err, ErrInvalid) {
+			t.Errorf("expected author %.10q with a %d byte body to be invalid, got %v", c.author, len(c.body), err)
+		}
+	}
+
+	for i := 0; i < MaxPerSubject; i++ {
+		if _, err := book.Add(group, "alice", "note", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := book.Add(group, "alice", "one too many", time.Now()); !errors.Is(err, ErrTooMany) {
+		t.Errorf("expected the number of notes to be bounded, got %v", err)
+	}
+}
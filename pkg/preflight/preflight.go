@@ -0,0 +1,187 @@
+// Package preflight checks the agent's runtime dependencies before the
+// pipeline starts, so a missing mount or binary is reported once and clearly
+// instead of as a stream of errors from every component.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	FailurePolicyDegrade  = "degrade"
+	FailurePolicyFailFast = "failFast"
+
+	StatusPassed   = "passed"
+	StatusFailed   = "failed"
+	StatusDegraded = "degraded"
+	StatusSkipped  = "skipped"
+)
+
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Action   string        `json:"action,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type Report struct {
+	Ready     bool      `json:"ready"`
+	Policy    string    `json:"policy"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Failed returns the checks that failed without a fallback.
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, check := range r.Checks {
+		if check.Status == StatusFailed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+type check struct {
+	name    string
+	enabled func(cfg *config.Config) bool
+	run     func(ctx context.Context, cfg *config.Config) error
+	// degrade turns off the feature that depends on the check and describes
+	// what was turned off. Checks without it make the agent unready.
+	degrade func(cfg *config.Config) string
+}
+
+var checks = []check{
+	{
+		name:    "coredump_path_readable",
+		enabled: func(cfg *config.Config) bool { return true },
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return checkReadableDir(cfg.Collector.CoredumpPath)
+		},
+	},
+	{
+		name:    "storage_path_writable",
+		enabled: func(cfg *config.Config) bool { return cfg.Storage.Backend == "local" },
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return checkWritableDir(cfg.Storage.LocalPath)
+		},
+	},
+	{
+		name:    "gdb_present",
+		enabled: func(cfg *config.Config) bool { return cfg.Analyzer.EnableGdbAnalysis },
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return checkBinary("gdb")
+		},
+		degrade: func(cfg *config.Config) string {
+			cfg.Analyzer.EnableGdbAnalysis = false
+			return "gdb analysis disabled, using basic analysis"
+		},
+	},
+	{
+		name:    "helm_present",
+		enabled: func(cfg *config.Config) bool { return cfg.Cleaner.Enabled },
+		run: func(ctx context.Context, cfg *config.Config) error {
+			return checkBinary("helm")
+		},
+		degrade: func(cfg *config.Config) string {
+			cfg.Cleaner.Enabled = false
+			return "automatic cleanup disabled"
+		},
+	},
+}
+
+// Run executes all checks that apply to cfg. Under the degrade policy a
+// failed check with a fallback switches the dependent feature off in cfg;
+// under failFast every failure makes the report unready.
+func Run(ctx context.Context, cfg *config.Config) *Report {
+	policy := cfg.Agent.Preflight.FailurePolicy
+	if policy == "" {
+		policy = FailurePolicyDegrade
+	}
+
+	report := &Report{
+		Ready:     true,
+		Policy:    policy,
+		CheckedAt: time.Now(),
+	}
+
+	for _, c := range checks {
+		result := Result{Name: c.name}
+
+		if !c.enabled(cfg) {
+			result.Status = StatusSkipped
+			report.Checks = append(report.Checks, result)
+			continue
+		}
+
+		start := time.Now()
+		err := c.run(ctx, cfg)
+		result.Duration = time.Since(start)
+
+		switch {
+		case err == nil:
+			result.Status = StatusPassed
+		case c.degrade != nil && policy == FailurePolicyDegrade:
+			result.Status = StatusDegraded
+			result.Error = err.Error()
+			result.Action = c.degrade(cfg)
+			klog.Warningf("Preflight check %s failed: %v (%s)", c.name, err, result.Action)
+		default:
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			report.Ready = false
+			klog.Errorf("Preflight check %s failed: %v", c.name, err)
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
+
+func checkReadableDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot access %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if _, err := os.ReadDir(path); err != nil {
+		return fmt.Errorf("cannot list %s: %w", path, err)
+	}
+	return nil
+}
+
+func checkWritableDir(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, err)
+	}
+	probe, err := os.CreateTemp(path, ".preflight-")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", path, err)
+	}
+	name := probe.Name()
+	probe.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove probe file %s: %w", filepath.Base(name), err)
+	}
+	return nil
+}
+
+func checkBinary(name string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s not found in PATH", name)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package preflight
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	cfg := &config.Config{}
+	cfg.Collector.CoredumpPath = t.TempDir()
+	cfg.Storage.Backend = "local"
+	cfg.Storage.LocalPath = filepath.Join(t.TempDir(), "storage")
+	return cfg
+}
+
+func findCheck(t *testing.T, report *Report, name string) Result {
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("check %s not in report", name)
+	return Result{}
+}
+
+func TestRunPasses(t *testing.T) {
+	report := Run(context.Background(), testConfig(t))
+
+	if !report.Ready {
+		t.Fatalf("expected ready report, got failures: %v", report.Failed())
+	}
+	if report.Policy != FailurePolicyDegrade {
+		t.Errorf("expected default policy %s, got %s", FailurePolicyDegrade, report.Policy)
+	}
+	if got := findCheck(t, report, "storage_path_writable").Status; got != StatusPassed {
+		t.Errorf("expected storage check to pass, got %s", got)
+	}
+	if got := findCheck(t, report, "gdb_present").Status; got != StatusSkipped {
+		t.Errorf("expected gdb check to be skipped, got %s", got)
+	}
+}
+
+func TestRunMissingCoredumpPath(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Collector.CoredumpPath = filepath.Join(t.TempDir(), "missing")
+
+	report := Run(context.Background(), cfg)
+
+	if report.Ready {
+		t.Fatal("expected report to be unready")
+	}
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Name != "coredump_path_readable" {
+		t.Errorf("expected only coredump_path_readable to fail, got %v", failed)
+	}
+}
+
+func TestRunMissingBinary(t *testing.T) {
+	t.Setenv("PATH", "")
+
+	t.Run("degrade", func(t *testing.T) {
+		cfg := testConfig(t)
+		cfg.Analyzer.EnableGdbAnalysis = true
+		cfg.Cleaner.Enabled = true
+
+		report := Run(context.Background(), cfg)
+
+		if !report.Ready {
+			t.Fatalf("expected degraded agent to be ready, got failures: %v", report.Failed())
+		}
+		if got := findCheck(t, report, "gdb_present").Status; got != StatusDegraded {
+			t.Errorf("expected gdb check to degrade, got %s", got)
+		}
+		if cfg.Analyzer.EnableGdbAnalysis || cfg.Cleaner.Enabled {
+			t.Error("expected gdb analysis and cleaner to be disabled")
+		}
+	})
+
+	t.Run("failFast", func(t *testing.T) {
+		cfg := testConfig(t)
+		cfg.Agent.Preflight.FailurePolicy = FailurePolicyFailFast
+		cfg.Analyzer.EnableGdbAnalysis = true
+
+		report := Run(context.Background(), cfg)
+
+		if report.Ready {
+			t.Fatal("expected report to be unready")
+		}
+		if !cfg.Analyzer.EnableGdbAnalysis {
+			t.Error("failFast must not change the configuration")
+		}
+	})
+}
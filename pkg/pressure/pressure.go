@@ -0,0 +1,341 @@
+// Package pressure watches the agent's own memory and CPU usage against its
+// cgroup limits. When usage crosses the configured thresholds the agent
+// enters degraded mode: other components defer gdb analysis and scan less
+// often instead of getting OOM-killed halfway through an analysis.
+package pressure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// Status is a snapshot of the agent's resource usage.
+type Status struct {
+	Degraded         bool      `json:"degraded"`
+	Reason           string    `json:"reason,omitempty"`
+	DegradedSince    time.Time `json:"degradedSince,omitempty"`
+	MemoryBytes      int64     `json:"memoryBytes"`
+	MemoryLimitBytes int64     `json:"memoryLimitBytes,omitempty"`
+	MemoryRatio      float64   `json:"memoryRatio"`
+	CPURatio         float64   `json:"cpuRatio"`
+	CheckedAt        time.Time `json:"checkedAt"`
+}
+
+// Tracker samples resource usage periodically. A nil *Tracker is valid and
+// never reports pressure, so components can be built without one.
+type Tracker struct {
+	config *config.PressureConfig
+	cgroup cgroupReader
+
+	mu     sync.RWMutex
+	status Status
+
+	lastCPUUsage time.Duration
+	lastSample   time.Time
+}
+
+func New(config *config.PressureConfig) *Tracker {
+	return &Tracker{
+		config: config,
+		cgroup: detectCgroup(),
+	}
+}
+
+func (t *Tracker) Start(ctx context.Context) error {
+	if t == nil || !t.config.Enabled {
+		return nil
+	}
+
+	interval := t.config.CheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	klog.Infof("Starting resource pressure tracker (memory threshold %.0f%%, CPU threshold %.0f%%)",
+		t.config.MemoryThreshold*100, t.config.CPUThreshold*100)
+
+	if limit := t.cgroup.memoryLimit(); limit > 0 && t.config.MemoryThreshold > 0 {
+		// Let the Go runtime collect more aggressively before the kernel
+		// has to step in.
+		debug.SetMemoryLimit(int64(float64(limit) * t.config.MemoryThreshold))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.sample()
+		}
+	}
+}
+
+// Degraded reports whether the agent is currently under resource pressure.
+func (t *Tracker) Degraded() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status.Degraded
+}
+
+func (t *Tracker) Status() Status {
+	if t == nil {
+		return Status{}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
+
+// WaitForCapacity blocks while the agent is degraded, up to the configured
+// maximum analysis deferral. It reports whether pressure cleared in time.
+func (t *Tracker) WaitForCapacity(ctx context.Context) bool {
+	if !t.Degraded() {
+		return true
+	}
+
+	maxWait := t.config.MaxAnalysisDeferral
+	if maxWait <= 0 {
+		maxWait = 5 * time.Minute
+	}
+	klog.V(2).Infof("Deferring work for up to %v while under resource pressure", maxWait)
+
+	deadline := time.After(maxWait)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline:
+			return false
+		case <-ticker.C:
+			if !t.Degraded() {
+				return true
+			}
+		}
+	}
+}
+
+// DegradedScanFactor is how many scan ticks are merged into one while
+// degraded.
+func (t *Tracker) DegradedScanFactor() int {
+	if t == nil {
+		return 1
+	}
+	if t.config.DegradedScanFactor <= 0 {
+		return 4
+	}
+	return t.config.DegradedScanFactor
+}
+
+func (t *Tracker) sample() {
+	now := time.Now()
+
+	memory := t.cgroup.memoryUsage()
+	if memory <= 0 {
+		memory = processRSS()
+	}
+	limit := t.cgroup.memoryLimit()
+
+	var memoryRatio float64
+	if limit > 0 {
+		memoryRatio = float64(memory) / float64(limit)
+	}
+
+	var cpuRatio float64
+	cpuUsage := t.cgroup.cpuUsage()
+	if !t.lastSample.IsZero() && cpuUsage > 0 {
+		elapsed := now.Sub(t.lastSample)
+		cpus := t.cgroup.cpuQuota()
+		if cpus <= 0 {
+			cpus = float64(runtime.NumCPU())
+		}
+		cpuRatio = float64(cpuUsage-t.lastCPUUsage) / (float64(elapsed) * cpus)
+	}
+	t.lastCPUUsage = cpuUsage
+	t.lastSample = now
+
+	var reasons []string
+	if t.config.MemoryThreshold > 0 && memoryRatio >= t.config.MemoryThreshold {
+		reasons = append(reasons, fmt.Sprintf("memory at %.0f%% of limit", memoryRatio*100))
+	}
+	if t.config.CPUThreshold > 0 && cpuRatio >= t.config.CPUThreshold {
+		reasons = append(reasons, fmt.Sprintf("CPU at %.0f%% of quota", cpuRatio*100))
+	}
+	degraded := len(reasons) > 0
+
+	t.mu.Lock()
+	wasDegraded := t.status.Degraded
+	since := t.status.DegradedSince
+	if degraded && !wasDegraded {
+		since = now
+	} else if !degraded {
+		since = time.Time{}
+	}
+	t.status = Status{
+		Degraded:         degraded,
+		Reason:           strings.Join(reasons, ", "),
+		DegradedSince:    since,
+		MemoryBytes:      memory,
+		MemoryLimitBytes: limit,
+		MemoryRatio:      memoryRatio,
+		CPURatio:         cpuRatio,
+		CheckedAt:        now,
+	}
+	t.mu.Unlock()
+
+	switch {
+	case degraded && !wasDegraded:
+		klog.Warningf("Entering degraded mode: %s", strings.Join(reasons, ", "))
+		// Hand freed heap back to the OS right away rather than waiting
+		// for the scavenger.
+		debug.FreeOSMemory()
+	case !degraded && wasDegraded:
+		klog.Infof("Leaving degraded mode after %v", now.Sub(since))
+	}
+}
+
+// cgroupReader reads usage and limits for the agent's own cgroup.
+type cgroupReader interface {
+	memoryUsage() int64
+	memoryLimit() int64
+	// cpuUsage is the cumulative CPU time consumed.
+	cpuUsage() time.Duration
+	// cpuQuota is the number of CPUs the cgroup may use, 0 if unlimited.
+	cpuQuota() float64
+}
+
+func detectCgroup() cgroupReader {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return cgroupV2{root: "/sys/fs/cgroup"}
+	}
+	return cgroupV1{root: "/sys/fs/cgroup"}
+}
+
+type cgroupV2 struct {
+	root string
+}
+
+func (c cgroupV2) memoryUsage() int64 {
+	return readInt(c.root + "/memory.current")
+}
+
+func (c cgroupV2) memoryLimit() int64 {
+	return readInt(c.root + "/memory.max")
+}
+
+func (c cgroupV2) cpuUsage() time.Duration {
+	data, err := os.ReadFile(c.root + "/cpu.stat")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(line, "usage_usec "); found {
+			usec, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return time.Duration(usec) * time.Microsecond
+		}
+	}
+	return 0
+}
+
+func (c cgroupV2) cpuQuota() float64 {
+	data, err := os.ReadFile(c.root + "/cpu.max")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+type cgroupV1 struct {
+	root string
+}
+
+// v1 reports "no limit" as a page-aligned value close to MaxInt64.
+const cgroupV1Unlimited = 1 << 62
+
+func (c cgroupV1) memoryUsage() int64 {
+	return readInt(c.root + "/memory/memory.usage_in_bytes")
+}
+
+func (c cgroupV1) memoryLimit() int64 {
+	limit := readInt(c.root + "/memory/memory.limit_in_bytes")
+	if limit >= cgroupV1Unlimited {
+		return 0
+	}
+	return limit
+}
+
+func (c cgroupV1) cpuUsage() time.Duration {
+	return time.Duration(readInt(c.root + "/cpuacct/cpuacct.usage"))
+}
+
+func (c cgroupV1) cpuQuota() float64 {
+	quota := readInt(c.root + "/cpu/cpu.cfs_quota_us")
+	period := readInt(c.root + "/cpu/cpu.cfs_period_us")
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+	return float64(quota) / float64(period)
+}
+
+// readInt returns the integer in a cgroup file, or 0 if it is missing or
+// holds "max".
+func readInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// processRSS is the fallback when no cgroup memory accounting is available.
+func processRSS() int64 {
+	data, err := os.ReadFile("/proc/self/status")
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, found := strings.CutPrefix(line, "VmRSS:"); found {
+				fields := strings.Fields(value)
+				if len(fields) > 0 {
+					kb, _ := strconv.ParseInt(fields[0], 10, 64)
+					return kb * 1024
+				}
+			}
+		}
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.Sys)
+}
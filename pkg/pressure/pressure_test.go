@@ -0,0 +1,109 @@
+package pressure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+type fakeCgroup struct {
+	memory int64
+	limit  int64
+	cpu    time.Duration
+	quota  float64
+}
+
+func (f *fakeCgroup) memoryUsage() int64      { return f.memory }
+func (f *fakeCgroup) memoryLimit() int64      { return f.limit }
+func (f *fakeCgroup) cpuUsage() time.Duration { return f.cpu }
+func (f *fakeCgroup) cpuQuota() float64       { return f.quota }
+
+func TestTrackerEntersAndLeavesDegradedMode(t *testing.T) {
+	cgroup := &fakeCgroup{memory: 100, limit: 1000, quota: 1}
+	tracker := &Tracker{
+		config: &config.PressureConfig{MemoryThreshold: 0.8, CPUThreshold: 0.9},
+		cgroup: cgroup,
+	}
+
+	tracker.sample()
+	if tracker.Degraded() {
+		t.Fatal("expected no pressure at 10% memory")
+	}
+
+	cgroup.memory = 850
+	tracker.sample()
+	status := tracker.Status()
+	if !status.Degraded || status.DegradedSince.IsZero() {
+		t.Fatalf("expected degraded mode at 85%% memory, got %+v", status)
+	}
+
+	cgroup.memory = 100
+	tracker.sample()
+	if status := tracker.Status(); status.Degraded || !status.DegradedSince.IsZero() {
+		t.Fatalf("expected degraded mode to clear, got %+v", status)
+	}
+}
+
+func TestTrackerCPUPressure(t *testing.T) {
+	cgroup := &fakeCgroup{quota: 0.5}
+	tracker := &Tracker{
+		config: &config.PressureConfig{CPUThreshold: 0.9},
+		cgroup: cgroup,
+	}
+
+	tracker.sample()
+	// Pretend the agent burned a full half-CPU quota since the last sample.
+	tracker.lastSample = time.Now().Add(-time.Second)
+	cgroup.cpu = 500 * time.Millisecond
+	tracker.sample()
+
+	if status := tracker.Status(); !status.Degraded {
+		t.Fatalf("expected CPU pressure, got %+v", status)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	if tracker.Degraded() {
+		t.Error("expected nil tracker to never be degraded")
+	}
+	if !tracker.WaitForCapacity(context.Background()) {
+		t.Error("expected nil tracker to always have capacity")
+	}
+	if tracker.DegradedScanFactor() != 1 {
+		t.Error("expected nil tracker to scan on every tick")
+	}
+}
+
+func TestCgroupV2Reader(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"memory.current": "104857600\n",
+		"memory.max":     "max\n",
+		"cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\n",
+		"cpu.max":        "50000 100000\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cgroup := cgroupV2{root: root}
+	if cgroup.memoryUsage() != 104857600 {
+		t.Errorf("unexpected memory usage %d", cgroup.memoryUsage())
+	}
+	if cgroup.memoryLimit() != 0 {
+		t.Errorf("expected unlimited memory, got %d", cgroup.memoryLimit())
+	}
+	if cgroup.cpuUsage() != 2500*time.Millisecond {
+		t.Errorf("unexpected CPU usage %v", cgroup.cpuUsage())
+	}
+	if cgroup.cpuQuota() != 0.5 {
+		t.Errorf("unexpected CPU quota %v", cgroup.cpuQuota())
+	}
+}
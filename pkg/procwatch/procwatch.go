@@ -0,0 +1,398 @@
+// Package procwatch runs analysis subprocesses such as gdb in their own
+// process group and makes sure they, and everything they spawned, are gone
+// when the analysis ends. gdb has been seen to hang past its context timeout
+// and to leave children holding its output pipe open; since the agent is
+// PID 1 in its container, those children also end up as zombies under it.
+package procwatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	defaultKillGracePeriod = 10 * time.Second
+	defaultCheckInterval   = 30 * time.Second
+
+	// reapTimeout bounds how long Output waits for a killed group to exit.
+	reapTimeout = 2 * time.Second
+)
+
+// Stats counts what the watchdog had to clean up.
+type Stats struct {
+	Running         int
+	TimedOut        int64
+	OrphansKilled   int64
+	ZombiesReaped   int64
+	Stuck           int64
+	WorkDirsRemoved int64
+}
+
+// Watchdog tracks running subprocesses. A nil *Watchdog still runs commands
+// with process-group cleanup but keeps no statistics.
+type Watchdog struct {
+	config  *config.WatchdogConfig
+	created time.Time
+
+	mu    sync.Mutex
+	procs map[int]*process
+
+	timedOut        atomic.Int64
+	orphansKilled   atomic.Int64
+	zombiesReaped   atomic.Int64
+	stuck           atomic.Int64
+	workDirsRemoved atomic.Int64
+}
+
+type process struct {
+	name     string
+	pgid     int
+	deadline time.Time
+	stuck    bool
+}
+
+func New(config *config.WatchdogConfig) *Watchdog {
+	return &Watchdog{
+		config:  config,
+		created: time.Now(),
+		procs:   make(map[int]*process),
+	}
+}
+
+// Start removes work directories left by a previous agent run and then
+// periodically looks for subprocesses that outlived their hard deadline.
+// On shutdown every tracked process group is killed.
+func (w *Watchdog) Start(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+
+	klog.Info("Starting subprocess watchdog")
+	w.removeStaleWorkDirs()
+
+	interval := w.config.CheckInterval
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.killAll()
+			return nil
+		case <-ticker.C:
+			w.check(time.Now())
+		}
+	}
+}
+
+func (w *Watchdog) Stats() Stats {
+	if w == nil {
+		return Stats{}
+	}
+	w.mu.Lock()
+	running := len(w.procs)
+	w.mu.Unlock()
+
+	return Stats{
+		Running:         running,
+		TimedOut:        w.timedOut.Load(),
+		OrphansKilled:   w.orphansKilled.Load(),
+		ZombiesReaped:   w.zombiesReaped.Load(),
+		Stuck:           w.stuck.Load(),
+		WorkDirsRemoved: w.workDirsRemoved.Load(),
+	}
+}
+
+// Output runs the named program in a fresh work directory and process group
+// and returns its stdout. When timeout expires the whole group gets SIGTERM,
+// then SIGKILL after the grace period. Whatever is left in the group once
+// the program exits is killed and reaped, and the work directory is removed
+// along with any files the program wrote there.
+func (w *Watchdog) Output(ctx context.Context, name string, timeout time.Duration, stdin io.Reader, path string, args ...string) ([]byte, error) {
+	return w.OutputEnv(ctx, name, timeout, nil, stdin, path, args...)
+}
+
+// OutputEnv is Output with env added to the agent's environment.
+func (w *Watchdog) OutputEnv(ctx context.Context, name string, timeout time.Duration, env []string, stdin io.Reader, path string, args ...string) ([]byte, error) {
+	workDir, err := w.newWorkDir(name)
+	if err != nil {
+		return nil, err
+	}
+	defer w.removeWorkDir(workDir)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = workDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	// After the grace period Wait kills the leader and stops waiting for
+	// children that still hold stdout open.
+	cmd.WaitDelay = w.killGracePeriod()
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	pgid := cmd.Process.Pid
+	w.track(pgid, name, timeout)
+
+	waitErr := cmd.Wait()
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if errors.Is(waitErr, exec.ErrWaitDelay) {
+		// The program exited but a child kept stdout open; the child is
+		// killed below and the output read so far is complete.
+		waitErr = nil
+	}
+
+	w.cleanupGroup(name, pgid)
+	w.untrack(pgid)
+
+	if timedOut {
+		if w != nil {
+			w.timedOut.Add(1)
+		}
+		klog.Warningf("%s exceeded its %v timeout and was killed", name, timeout)
+		return nil, fmt.Errorf("%s timed out after %v", name, timeout)
+	}
+	if waitErr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", name, waitErr, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, waitErr)
+	}
+	return stdout.Bytes(), nil
+}
+
+// cleanupGroup kills whatever is left of the process group and reaps the
+// members that were reparented to the agent.
+func (w *Watchdog) cleanupGroup(name string, pgid int) {
+	if alive := groupMembers(pgid, false); len(alive) > 0 {
+		klog.Warningf("Killing %d orphaned processes left by %s (pgid %d)", len(alive), name, pgid)
+		syscall.Kill(-pgid, syscall.SIGKILL)
+		if w != nil {
+			w.orphansKilled.Add(int64(len(alive)))
+		}
+	}
+
+	deadline := time.Now().Add(reapTimeout)
+	for {
+		// Zombies parented elsewhere are their reaper's business; only wait
+		// for live members and zombies the agent has to collect itself.
+		var pending []member
+		for _, member := range groupMembers(pgid, true) {
+			if !member.zombie {
+				pending = append(pending, member)
+				continue
+			}
+			if member.ppid != os.Getpid() {
+				continue
+			}
+			var status syscall.WaitStatus
+			if pid, _ := syscall.Wait4(member.pid, &status, syscall.WNOHANG, nil); pid == member.pid {
+				if w != nil {
+					w.zombiesReaped.Add(1)
+				}
+				continue
+			}
+			pending = append(pending, member)
+		}
+		if len(pending) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			klog.Warningf("%d processes of %s (pgid %d) did not exit after SIGKILL", len(pending), name, pgid)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// check flags process groups still running well past their deadline. Wait
+// only returns once the kernel lets the leader die, which a process blocked
+// in uninterruptible I/O on a core file may never do.
+func (w *Watchdog) check(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, proc := range w.procs {
+		if now.Before(proc.deadline) {
+			continue
+		}
+		syscall.Kill(-proc.pgid, syscall.SIGKILL)
+		if !proc.stuck {
+			proc.stuck = true
+			w.stuck.Add(1)
+			klog.Errorf("%s (pgid %d) is stuck %v past its deadline and ignores SIGKILL",
+				proc.name, proc.pgid, now.Sub(proc.deadline).Round(time.Second))
+		}
+	}
+}
+
+func (w *Watchdog) killAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, proc := range w.procs {
+		klog.Infof("Killing %s (pgid %d) on shutdown", proc.name, proc.pgid)
+		syscall.Kill(-proc.pgid, syscall.SIGKILL)
+	}
+}
+
+func (w *Watchdog) track(pgid int, name string, timeout time.Duration) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Leave room for the SIGTERM grace period and the final reap before
+	// calling the process stuck.
+	w.procs[pgid] = &process{
+		name:     name,
+		pgid:     pgid,
+		deadline: time.Now().Add(timeout + 2*w.killGracePeriod() + reapTimeout),
+	}
+}
+
+func (w *Watchdog) untrack(pgid int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.procs, pgid)
+}
+
+func (w *Watchdog) killGracePeriod() time.Duration {
+	if w == nil || w.config.KillGracePeriod <= 0 {
+		return defaultKillGracePeriod
+	}
+	return w.config.KillGracePeriod
+}
+
+func (w *Watchdog) workRoot() string {
+	if w == nil || w.config.WorkDir == "" {
+		return filepath.Join(os.TempDir(), "milvus-coredump-agent")
+	}
+	return w.config.WorkDir
+}
+
+func (w *Watchdog) newWorkDir(name string) (string, error) {
+	root := w.workRoot()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create work directory root: %w", err)
+	}
+	dir, err := os.MkdirTemp(root, strings.ReplaceAll(name, " ", "-")+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create work directory: %w", err)
+	}
+	return dir, nil
+}
+
+func (w *Watchdog) removeWorkDir(dir string) {
+	entries, _ := os.ReadDir(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		klog.Warningf("Failed to remove work directory %s: %v", dir, err)
+		return
+	}
+	if len(entries) > 0 {
+		klog.V(2).Infof("Removed %d temporary files from %s", len(entries), dir)
+	}
+}
+
+// removeStaleWorkDirs deletes work directories from before this agent
+// started, left behind when a previous agent was killed mid-analysis.
+func (w *Watchdog) removeStaleWorkDirs() {
+	root := w.workRoot()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() || !info.ModTime().Before(w.created) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			klog.Warningf("Failed to remove stale work directory %s: %v", entry.Name(), err)
+			continue
+		}
+		w.workDirsRemoved.Add(1)
+	}
+	if removed := w.workDirsRemoved.Load(); removed > 0 {
+		klog.Infof("Removed %d stale work directories from %s", removed, root)
+	}
+}
+
+type member struct {
+	pid    int
+	ppid   int
+	zombie bool
+}
+
+// groupMembers lists the processes in a process group from /proc, including
+// zombies only when asked to.
+func groupMembers(pgid int, includeZombies bool) []member {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var members []member
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name is in parentheses and may contain spaces, so
+		// parse from the last closing parenthesis.
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 3 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		group, _ := strconv.Atoi(fields[2])
+		if group != pgid {
+			continue
+		}
+		zombie := fields[0] == "Z"
+		if zombie && !includeZombies {
+			continue
+		}
+		members = append(members, member{pid: pid, ppid: ppid, zombie: zombie})
+	}
+	return members
+}
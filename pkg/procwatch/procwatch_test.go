@@ -0,0 +1,121 @@
+package procwatch
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func newTestWatchdog(t *testing.T) *Watchdog {
+	return New(&config.WatchdogConfig{
+		KillGracePeriod: 100 * time.Millisecond,
+		WorkDir:         t.TempDir(),
+	})
+}
+
+func TestOutput(t *testing.T) {
+	w := newTestWatchdog(t)
+
+	output, err := w.Output(context.Background(), "echo", time.Second, strings.NewReader("from stdin"), "sh", "-c", "echo scratch > scratch.txt; cat")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(output) != "from stdin" {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	entries, err := os.ReadDir(w.config.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected work directory to be removed, found %d entries", len(entries))
+	}
+	if stats := w.Stats(); stats.Running != 0 || stats.TimedOut != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestOutputEnv(t *testing.T) {
+	w := newTestWatchdog(t)
+
+	output, err := w.OutputEnv(context.Background(), "env", time.Second, []string{"PROCWATCH_TEST=set"}, nil, "sh", "-c", "echo -n $PROCWATCH_TEST")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(output) != "set" {
+		t.Errorf("expected the variable to be passed, got %q", output)
+	}
+}
+
+func TestOutputKillsOrphans(t *testing.T) {
+	w := newTestWatchdog(t)
+
+	output, err := w.Output(context.Background(), "orphan", time.Second, nil, "sh", "-c", "sleep 30 >/dev/null 2>&1 & echo started")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "started" {
+		t.Errorf("unexpected output %q", output)
+	}
+	if got := w.Stats().OrphansKilled; got != 1 {
+		t.Errorf("expected 1 orphan killed, got %d", got)
+	}
+}
+
+func TestOutputTimeout(t *testing.T) {
+	w := newTestWatchdog(t)
+
+	start := time.Now()
+	// The shell ignores SIGTERM, so only the SIGKILL after the grace period
+	// ends it; its child keeps stdout open.
+	_, err := w.Output(context.Background(), "hang", 200*time.Millisecond, nil, "sh", "-c", "trap '' TERM; sleep 30 & wait")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Output took %v, expected the group to be killed promptly", elapsed)
+	}
+
+	stats := w.Stats()
+	if stats.TimedOut != 1 || stats.Running != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestRemoveStaleWorkDirs(t *testing.T) {
+	w := newTestWatchdog(t)
+	stale, err := os.MkdirTemp(w.config.WorkDir, "gdb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	w.removeStaleWorkDirs()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected stale work directory to be removed, got %v", err)
+	}
+	if got := w.Stats().WorkDirsRemoved; got != 1 {
+		t.Errorf("expected 1 work directory removed, got %d", got)
+	}
+}
+
+func TestNilWatchdog(t *testing.T) {
+	var w *Watchdog
+
+	output, err := w.Output(context.Background(), "echo", time.Second, nil, "echo", "ok")
+	if err != nil || strings.TrimSpace(string(output)) != "ok" {
+		t.Fatalf("unexpected result %q, %v", output, err)
+	}
+	if stats := w.Stats(); stats != (Stats{}) {
+		t.Errorf("expected zero stats, got %+v", stats)
+	}
+}
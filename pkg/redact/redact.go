@@ -0,0 +1,133 @@
+// Package redact removes secrets and identifying details, such as IP
+// addresses, tokens and S3 keys, from text before it leaves the cluster.
+// The AI analysis runs its prompt through it: stack traces, registers and
+// library lists may hold file paths, hostnames and strings from the
+// crashed process' memory.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// Built-in detectors. A pattern with a group redacts only what the first
+// group matched, keeping the key a secret is assigned to readable.
+var detectors = map[string][]string{
+	"ip": {
+		`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`,
+		// Only the full form: the compressed one can't be told apart from
+		// C++ scopes such as std::vector.
+		`\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b`,
+	},
+	"token": {
+		`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`,
+		`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`,
+		`\b(?:sk|sk-ant|sk-proj)-[A-Za-z0-9_-]{20,}`,
+		`\bgh[pousr]_[A-Za-z0-9]{36}\b`,
+		`(?i)\b(?:token|password|passwd|secret|api[_-]?key)\s*[=:]\s*["']?([^\s"',;]{8,})`,
+	},
+	"s3-key": {
+		`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+		`(?i)\b(?:aws_secret_access_key|secret_?access_?key|secretKey)\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`,
+	},
+}
+
+// DefaultDetectors are run when the configuration names none.
+var DefaultDetectors = []string{"token", "s3-key", "ip"}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Redactor replaces what its rules match with [REDACTED:<rule>]. A nil
+// Redactor leaves text as it is.
+type Redactor struct {
+	rules []rule
+}
+
+// New compiles the configured patterns and detectors; it returns nil when
+// redaction is off.
+func New(config *config.RedactionConfig) (*Redactor, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	redactor := &Redactor{}
+	// Patterns go first, so what they match is reported under their name.
+	for i, pattern := range config.Patterns {
+		name := pattern.Name
+		if name == "" {
+			name = fmt.Sprintf("pattern-%d", i)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", name, err)
+		}
+		redactor.rules = append(redactor.rules, rule{name: name, re: re})
+	}
+
+	names := config.Detectors
+	if len(names) == 0 {
+		names = DefaultDetectors
+	}
+	for _, name := range names {
+		patterns, found := detectors[name]
+		if !found {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+		for _, pattern := range patterns {
+			redactor.rules = append(redactor.rules, rule{name: name, re: regexp.MustCompile(pattern)})
+		}
+	}
+	return redactor, nil
+}
+
+// Redact returns text with the matches of the rules replaced, and what was
+// replaced, which is nil when nothing was.
+func (r *Redactor) Redact(text string) (string, *collector.RedactionReport) {
+	if r == nil {
+		return text, nil
+	}
+
+	var report *collector.RedactionReport
+	for _, rule := range r.rules {
+		var count int
+		text, count = replace(rule, text)
+		if count == 0 {
+			continue
+		}
+		if report == nil {
+			report = &collector.RedactionReport{Counts: map[string]int{}}
+		}
+		report.Counts[rule.name] += count
+		report.Total += count
+	}
+	return text, report
+}
+
+func replace(rule rule, text string) (string, int) {
+	matches := rule.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	replacement := "[REDACTED:" + rule.name + "]"
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) >= 4 && match[2] >= 0 {
+			start, end = match[2], match[3]
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(replacement)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String(), len(matches)
+}
@@ -0,0 +1,220 @@
+// Package reload applies changes to the agent's configuration file without
+// restarting it. The file's directory is watched, which also catches the
+// symlink swap a ConfigMap volume is updated with; a changed configuration
+// is validated and handed to the components that can apply it.
+//
+// Only the settings the components read while running are reloaded, such
+// as the ignore patterns, the panic keywords and the score thresholds.
+// Settings the agent is built from, such as the coredump path, the storage
+// backend or the AI provider, keep their old value until the agent is
+// restarted.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/chanstats"
+	"milvus-coredump-agent/pkg/config"
+)
+
+const defaultDebounce = 2 * time.Second
+
+type EventType string
+
+const (
+	EventTypeConfigReloaded     EventType = "config_reloaded"
+	EventTypeConfigReloadFailed EventType = "config_reload_failed"
+)
+
+// Event reports a reload. Changed names the sections whose settings were
+// applied, RestartRequired those whose changes wait for a restart.
+type Event struct {
+	Type            EventType `json:"type"`
+	Changed         []string  `json:"changed,omitempty"`
+	RestartRequired []string  `json:"restartRequired,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// Watcher reloads the configuration file at path. prepare, when set, is
+// called on every configuration read before it is validated, as the agent
+// does with the one it started with; an error rejects it. apply receives
+// the configuration in effect after a reload.
+type Watcher struct {
+	config    *config.ConfigReloadConfig
+	path      string
+	prepare   func(*config.Config) error
+	apply     func(*config.Config)
+	eventChan chan Event
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+// New creates a watcher for the configuration current was loaded from.
+func New(cfg *config.ConfigReloadConfig, path string, current *config.Config, prepare func(*config.Config) error, apply func(*config.Config)) *Watcher {
+	w := &Watcher{
+		config:    cfg,
+		path:      path,
+		prepare:   prepare,
+		apply:     apply,
+		eventChan: make(chan Event, 10),
+		current:   current,
+	}
+	chanstats.Register("reload_events", w.eventChan)
+	return w
+}
+
+// GetEventChannel returns the reload events; a nil *Watcher has none.
+func (w *Watcher) GetEventChannel() <-chan Event {
+	if w == nil {
+		return nil
+	}
+	return w.eventChan
+}
+
+// Start watches the configuration file until ctx is done. Changes are
+// reloaded once the file has been quiet for the debounce interval, as
+// editors and ConfigMap updates touch it several times. A nil *Watcher
+// returns at once.
+func (w *Watcher) Start(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	dir := filepath.Dir(w.path)
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	klog.Infof("Watching %s for configuration changes", w.path)
+
+	debounce := w.config.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-watcher.Events:
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			klog.Warningf("Config watcher error: %v", err)
+		case <-timer.C:
+			w.Reload()
+		}
+	}
+}
+
+// Reload reads the configuration file and applies what changed. It returns
+// the sections whose settings were applied.
+func (w *Watcher) Reload() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := config.Load(w.path)
+	if err == nil && w.prepare != nil {
+		err = w.prepare(next)
+	}
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		klog.Errorf("Keeping the current configuration, reload failed: %v", err)
+		w.sendEvent(Event{Type: EventTypeConfigReloadFailed, Error: err.Error(), Timestamp: time.Now()})
+		return nil, err
+	}
+
+	applied := *w.current
+	copyReloadable(&applied, next)
+	changed := changedSections(w.current, &applied)
+
+	// What is left of the new configuration once its reloadable settings
+	// are the current ones must be the current configuration.
+	rest := *next
+	copyReloadable(&rest, w.current)
+	restartRequired := changedSections(w.current, &rest)
+
+	if len(restartRequired) > 0 {
+		klog.Warningf("Configuration changes to %v take effect after the agent restarts", restartRequired)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	w.current = &applied
+	w.apply(&applied)
+	klog.Infof("Configuration reloaded, changed: %v", changed)
+	w.sendEvent(Event{
+		Type:            EventTypeConfigReloaded,
+		Changed:         changed,
+		RestartRequired: restartRequired,
+		Timestamp:       time.Now(),
+	})
+	return changed, nil
+}
+
+// copyReloadable copies the settings the components read while running
+// from src to dst.
+func copyReloadable(dst, src *config.Config) {
+	dst.Collector.MaxFileAge = src.Collector.MaxFileAge
+	dst.Collector.StableFor = src.Collector.StableFor
+
+	dst.Analyzer.EnableGdbAnalysis = src.Analyzer.EnableGdbAnalysis
+	dst.Analyzer.GdbTimeout = src.Analyzer.GdbTimeout
+	dst.Analyzer.ValueThreshold = src.Analyzer.ValueThreshold
+	dst.Analyzer.Thresholds = src.Analyzer.Thresholds
+	dst.Analyzer.IgnorePatterns = src.Analyzer.IgnorePatterns
+	dst.Analyzer.PanicKeywords = src.Analyzer.PanicKeywords
+	dst.Analyzer.Delve = src.Analyzer.Delve
+	dst.Analyzer.Environment = src.Analyzer.Environment
+	dst.Analyzer.Jemalloc = src.Analyzer.Jemalloc
+
+	dst.Storage.RetentionDays = src.Storage.RetentionDays
+	dst.Storage.MaxStorageSize = src.Storage.MaxStorageSize
+
+	dst.Cleaner.Enabled = src.Cleaner.Enabled
+	dst.Cleaner.MaxRestartCount = src.Cleaner.MaxRestartCount
+	dst.Cleaner.RestartTimeWindow = src.Cleaner.RestartTimeWindow
+	dst.Cleaner.CleanupDelay = src.Cleaner.CleanupDelay
+}
+
+// changedSections names the top-level sections that differ between a and
+// b, by their key in the configuration file.
+func changedSections(a, b *config.Config) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(*a), reflect.ValueOf(*b)
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return changed
+}
+
+func (w *Watcher) sendEvent(event Event) {
+	if !chanstats.TrySend("reload_events", w.eventChan, event) {
+		klog.Warning("Reload event channel is full, dropping event")
+	}
+}
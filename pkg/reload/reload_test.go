@@ -0,0 +1,124 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const baseConfig = `
+agent:
+  name: agent
+  metricsPort: 8080
+collector:
+  coredumpPath: /var/lib/coredumps
+analyzer:
+  ignorePatterns: ["test"]
+storage:
+  backend: memory
+`
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadAppliesRuntimeSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseConfig)
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied *config.Config
+	w := New(&config.ConfigReloadConfig{Debounce: 10 * time.Millisecond}, path, current, nil, func(cfg *config.Config) {
+		applied = cfg
+	})
+
+	// Unchanged files are not applied.
+	if changed, err := w.Reload(); err != nil || changed != nil || applied != nil {
+		t.Fatalf("expected nothing to apply, got %v (%v)", changed, err)
+	}
+
+	// The ignore patterns are applied, the coredump path waits for a restart.
+	writeConfig(t, path, `
+agent:
+  name: agent
+  metricsPort: 8080
+collector:
+  coredumpPath: /data/coredumps
+analyzer:
+  ignorePatterns: ["test", "debug"]
+storage:
+  backend: memory
+`)
+	changed, err := w.Reload()
+	if err != nil || !reflect.DeepEqual(changed, []string{"analyzer"}) {
+		t.Fatalf("expected the analyzer to change, got %v (%v)", changed, err)
+	}
+	if applied == nil || len(applied.Analyzer.IgnorePatterns) != 2 || applied.Collector.CoredumpPath != "/var/lib/coredumps" {
+		t.Fatalf("expected new ignore patterns and the old coredump path, got %+v", applied)
+	}
+	event := <-w.GetEventChannel()
+	if event.Type != EventTypeConfigReloaded || !reflect.DeepEqual(event.RestartRequired, []string{"collector"}) {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	// Invalid configurations are rejected.
+	writeConfig(t, path, "agent:\n  name: \"\"\n")
+	if _, err := w.Reload(); err == nil {
+		t.Fatal("expected an invalid configuration to be rejected")
+	}
+	if event := <-w.GetEventChannel(); event.Type != EventTypeConfigReloadFailed || event.Error == "" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if len(applied.Analyzer.IgnorePatterns) != 2 {
+		t.Errorf("expected the last good configuration to stay, got %+v", applied.Analyzer)
+	}
+}
+
+func TestWatcherReloadsOnWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseConfig)
+	current, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := make(chan *config.Config, 1)
+	w := New(&config.ConfigReloadConfig{Debounce: 10 * time.Millisecond}, path, current, nil, func(cfg *config.Config) {
+		applied <- cfg
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	var nilWatcher *Watcher
+	if nilWatcher.Start(ctx) != nil || nilWatcher.GetEventChannel() != nil {
+		t.Error("expected a nil watcher to do nothing")
+	}
+
+	// The directory may not be watched yet; write until the change is seen.
+	deadline := time.After(5 * time.Second)
+	for {
+		writeConfig(t, path, baseConfig+"cleaner:\n  maxRestartCount: 7\n")
+		select {
+		case cfg := <-applied:
+			if cfg.Cleaner.MaxRestartCount != 7 {
+				t.Errorf("expected the new restart count, got %+v", cfg.Cleaner)
+			}
+			return
+		case <-deadline:
+			t.Fatal("expected the write to be reloaded")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
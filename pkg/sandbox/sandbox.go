@@ -0,0 +1,346 @@
+// Package sandbox runs gdb in a short-lived pod on the agent's node instead
+// of the agent's container. The pod has no service account token, no
+// privileges beyond reading the cores, a read-only root filesystem and
+// resource limits, so a core crafted to exploit gdb gets no further than
+// the pod, and the agent image needs no gdb.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+const (
+	defaultStartTimeout = 2 * time.Minute
+	defaultPollInterval = time.Second
+	// Output past this is cut off; gdb's output of even a large Milvus
+	// core is a few megabytes.
+	maxOutput = 64 << 20
+
+	containerName = "gdb"
+	scratchDir    = "/tmp"
+	// Label of the sandbox pods, for finding ones a crashed agent left.
+	componentLabel = "milvus-coredump-agent/component"
+	componentValue = "gdb-sandbox"
+)
+
+// Runner starts the sandbox pods.
+type Runner struct {
+	config     *config.GdbSandboxConfig
+	kubeClient kubernetes.Interface
+	nodeName   string
+	namespace  string
+	resources  corev1.ResourceList
+
+	pollInterval time.Duration
+}
+
+// New creates a runner starting pods on nodeName in the configured
+// namespace, or namespace when none is configured.
+func New(config *config.GdbSandboxConfig, kubeClient kubernetes.Interface, nodeName, namespace string) (*Runner, error) {
+	if nodeName == "" {
+		return nil, fmt.Errorf("node name is not set")
+	}
+	if config.Namespace != "" {
+		namespace = config.Namespace
+	}
+
+	resources := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    config.CPU,
+		corev1.ResourceMemory: config.Memory,
+	} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gdb sandbox %s limit %q: %w", name, value, err)
+		}
+		resources[name] = quantity
+	}
+
+	return &Runner{
+		config:       config,
+		kubeClient:   kubeClient,
+		nodeName:     nodeName,
+		namespace:    namespace,
+		resources:    resources,
+		pollInterval: defaultPollInterval,
+	}, nil
+}
+
+// Run runs gdb with args and env in a sandbox pod and returns its output,
+// stdout and stderr interleaved. paths are the files gdb reads, such as
+// the core; the pod gets the mounts holding them, read-only, so args name
+// them as the agent sees them. gdb is killed after timeout, and the pod is
+// deleted however the run ends.
+func (r *Runner) Run(ctx context.Context, timeout time.Duration, env, args []string, paths ...string) ([]byte, error) {
+	pod, err := r.newPod(timeout, env, args, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := r.kubeClient.CoreV1().Pods(r.namespace)
+	pod, err = pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gdb sandbox pod: %w", err)
+	}
+	defer r.delete(pod.Name)
+	klog.V(2).Infof("Running gdb in sandbox pod %s/%s", r.namespace, pod.Name)
+
+	ctx, cancel := context.WithTimeout(ctx, r.startTimeout()+timeout)
+	defer cancel()
+
+	if err := r.waitFor(ctx, pod.Name, started); err != nil {
+		return nil, fmt.Errorf("gdb sandbox pod %s did not start: %w", pod.Name, err)
+	}
+
+	output, err := r.logs(ctx, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the output of gdb sandbox pod %s: %w", pod.Name, err)
+	}
+
+	var final *corev1.Pod
+	err = r.waitFor(ctx, pod.Name, func(pod *corev1.Pod) (bool, error) {
+		final = pod
+		return finished(pod), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gdb sandbox pod %s did not finish: %w", pod.Name, err)
+	}
+	if err := failure(final, timeout); err != nil {
+		if msg := lastLine(output); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+func (r *Runner) newPod(timeout time.Duration, env, args []string, paths []string) (*corev1.Pod, error) {
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	for _, path := range paths {
+		mount, found := r.mountFor(path)
+		if !found {
+			return nil, fmt.Errorf("%s is not under a gdb sandbox mount", path)
+		}
+		name := fmt.Sprintf("mount-%d", mount)
+		if hasVolume(volumes, name) {
+			continue
+		}
+		hostPathType := corev1.HostPathDirectory
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+				Path: r.config.Mounts[mount].HostPath,
+				Type: &hostPathType,
+			}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: name, MountPath: r.config.Mounts[mount].Path, ReadOnly: true})
+	}
+	volumes = append(volumes, corev1.Volume{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	mounts = append(mounts, corev1.VolumeMount{Name: "scratch", MountPath: scratchDir})
+
+	// The agent's debuginfod cache is not in the pod; downloads go to the
+	// scratch directory and are gone with the pod.
+	vars := []corev1.EnvVar{{Name: "HOME", Value: scratchDir}}
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		if name == "DEBUGINFOD_CACHE_PATH" {
+			value = filepath.Join(scratchDir, "debuginfod")
+		}
+		vars = append(vars, corev1.EnvVar{Name: name, Value: value})
+	}
+
+	deadline := int64((timeout + time.Second - 1) / time.Second)
+	disabled, enabled := false, true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "coredump-gdb-",
+			Labels: map[string]string{
+				"app":          "milvus-coredump-agent",
+				componentLabel: componentValue,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:                     r.nodeName,
+			RestartPolicy:                corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:        &deadline,
+			AutomountServiceAccountToken: &disabled,
+			EnableServiceLinks:           &disabled,
+			// Scheduled like the agent, wherever it runs.
+			Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Volumes:     volumes,
+			Containers: []corev1.Container{{
+				Name:         containerName,
+				Image:        r.config.Image,
+				Command:      []string{"gdb"},
+				Args:         args,
+				Env:          vars,
+				WorkingDir:   scratchDir,
+				VolumeMounts: mounts,
+				Resources:    corev1.ResourceRequirements{Limits: r.resources, Requests: r.resources},
+				SecurityContext: &corev1.SecurityContext{
+					// Cores are owned by root and not world-readable;
+					// DAC_READ_SEARCH is the one capability gdb keeps to
+					// read them.
+					AllowPrivilegeEscalation: &disabled,
+					ReadOnlyRootFilesystem:   &enabled,
+					Capabilities: &corev1.Capabilities{
+						Drop: []corev1.Capability{"ALL"},
+						Add:  []corev1.Capability{"DAC_READ_SEARCH"},
+					},
+					SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+				},
+			}},
+		},
+	}, nil
+}
+
+// mountFor returns the index of the mount with the longest path holding
+// path.
+func (r *Runner) mountFor(path string) (int, bool) {
+	best := -1
+	for i, mount := range r.config.Mounts {
+		rel, err := filepath.Rel(mount.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if best < 0 || len(mount.Path) > len(r.config.Mounts[best].Path) {
+			best = i
+		}
+	}
+	return best, best >= 0
+}
+
+func hasVolume(volumes []corev1.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// waitFor polls the pod until done reports true.
+func (r *Runner) waitFor(ctx context.Context, name string, done func(*corev1.Pod) (bool, error)) error {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		pod, err := r.kubeClient.CoreV1().Pods(r.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if ok, err := done(pod); err != nil || ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			if reason := waitingReason(pod); reason != "" {
+				return fmt.Errorf("%w (%s)", ctx.Err(), reason)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// started reports whether the pod is past Pending, failing early when its
+// image cannot be pulled.
+func started(pod *corev1.Pod) (bool, error) {
+	switch reason := waitingReason(pod); reason {
+	case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError", "CreateContainerError":
+		return false, fmt.Errorf("%s", reason)
+	}
+	return pod.Status.Phase != corev1.PodPending, nil
+}
+
+func finished(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+func waitingReason(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil {
+			return status.State.Waiting.Reason
+		}
+	}
+	return ""
+}
+
+// failure explains why a finished pod failed, such as gdb running out of
+// memory or time, or returns nil when gdb exited successfully.
+func failure(pod *corev1.Pod, timeout time.Duration) error {
+	if pod.Status.Reason == "DeadlineExceeded" {
+		return fmt.Errorf("gdb timed out after %v", timeout)
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Errorf("gdb failed: exit code %d (%s)", terminated.ExitCode, terminated.Reason)
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return fmt.Errorf("gdb sandbox pod %s failed: %s", pod.Name, pod.Status.Reason)
+	}
+	return nil
+}
+
+func (r *Runner) logs(ctx context.Context, name string) ([]byte, error) {
+	stream, err := r.kubeClient.CoreV1().Pods(r.namespace).GetLogs(name, &corev1.PodLogOptions{
+		Container: containerName,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	output, err := io.ReadAll(io.LimitReader(stream, maxOutput))
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == maxOutput {
+		klog.Warningf("Output of gdb sandbox pod %s cut off at %d bytes", name, maxOutput)
+	}
+	return output, nil
+}
+
+// delete removes the pod, also when the analysis' context is done.
+func (r *Runner) delete(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	grace := int64(0)
+	err := r.kubeClient.CoreV1().Pods(r.namespace).Delete(ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if err != nil {
+		klog.Warningf("Failed to delete gdb sandbox pod %s/%s: %v", r.namespace, name, err)
+	}
+}
+
+func (r *Runner) startTimeout() time.Duration {
+	if r.config.StartTimeout > 0 {
+		return r.config.StartTimeout
+	}
+	return defaultStartTimeout
+}
+
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,126 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+func newTestRunner(t *testing.T, terminated corev1.ContainerStateTerminated) (*Runner, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	var created *corev1.Pod
+	// The fake clientset neither generates names nor runs pods.
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = pod.GenerateName + "test"
+		created = pod.DeepCopy()
+		return false, nil, nil
+	})
+	client.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := created.DeepCopy()
+		pod.Status.Phase = corev1.PodSucceeded
+		if terminated.ExitCode != 0 {
+			pod.Status.Phase = corev1.PodFailed
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  containerName,
+			State: corev1.ContainerState{Terminated: &terminated},
+		}}
+		return true, pod, nil
+	})
+
+	runner, err := New(&config.GdbSandboxConfig{
+		Image:  "gdb:latest",
+		Memory: "2Gi",
+		Mounts: []config.SandboxMount{
+			{Path: "/host/var/lib/systemd/coredump", HostPath: "/var/lib/systemd/coredump"},
+			{Path: "/data/coredumps", HostPath: "/opt/milvus-coredumps"},
+		},
+	}, client, "node-1", "milvus-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.pollInterval = time.Millisecond
+	return runner, client
+}
+
+func TestRunAnalyzesTheCoreInAPodOnTheNode(t *testing.T) {
+	runner, client := newTestRunner(t, corev1.ContainerStateTerminated{})
+	core := "/host/var/lib/systemd/coredump/core.milvus.1000.zst"
+
+	output, err := runner.Run(context.Background(), time.Minute,
+		[]string{"DEBUGINFOD_CACHE_PATH=/var/cache/debuginfod"}, []string{"-batch", "-ex", "bt full", core}, core)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if string(output) != "fake logs" {
+		t.Errorf("expected the pod's logs, got %q", output)
+	}
+
+	var pod *corev1.Pod
+	var namespace string
+	deleted := false
+	for _, action := range client.Actions() {
+		switch action := action.(type) {
+		case k8stesting.CreateAction:
+			pod, namespace = action.GetObject().(*corev1.Pod), action.GetNamespace()
+		case k8stesting.DeleteAction:
+			deleted = action.GetName() == "coredump-gdb-test"
+		}
+	}
+	if !deleted {
+		t.Error("expected the pod to be deleted")
+	}
+	if pod == nil {
+		t.Fatal("expected a pod to be created")
+	}
+
+	spec := pod.Spec
+	if namespace != "milvus-system" || spec.NodeName != "node-1" || *spec.AutomountServiceAccountToken || *spec.ActiveDeadlineSeconds != 60 {
+		t.Errorf("expected a pod on node-1 without a token and a 60s deadline, got %+v", spec)
+	}
+	container := spec.Containers[0]
+	if strings.Join(container.Args, " ") != "-batch -ex bt full "+core || container.Resources.Limits.Memory().String() != "2Gi" {
+		t.Errorf("unexpected container %+v", container)
+	}
+	if len(spec.Volumes) != 2 || spec.Volumes[0].HostPath == nil || spec.Volumes[0].HostPath.Path != "/var/lib/systemd/coredump" {
+		t.Errorf("expected only the core's mount and scratch space, got %+v", spec.Volumes)
+	}
+	if mount := container.VolumeMounts[0]; mount.MountPath != "/host/var/lib/systemd/coredump" || !mount.ReadOnly {
+		t.Errorf("expected the core read-only at the agent's path, got %+v", mount)
+	}
+	for _, env := range container.Env {
+		if env.Name == "DEBUGINFOD_CACHE_PATH" && env.Value != "/tmp/debuginfod" {
+			t.Errorf("expected the debuginfod cache in scratch space, got %s", env.Value)
+		}
+	}
+}
+
+func TestRunReportsWhyGdbFailed(t *testing.T) {
+	runner, client := newTestRunner(t, corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"})
+
+	_, err := runner.Run(context.Background(), time.Minute, nil, []string{"/data/coredumps/core"}, "/data/coredumps/core")
+	if err == nil || !strings.Contains(err.Error(), "OOMKilled") {
+		t.Errorf("expected the OOM kill to be reported, got %v", err)
+	}
+	actions := client.Actions()
+	if !actions[len(actions)-1].Matches("delete", "pods") {
+		t.Error("expected the failed pod to be deleted")
+	}
+
+	client.ClearActions()
+	if _, err := runner.Run(context.Background(), time.Minute, nil, nil, "/var/crash/core"); err == nil {
+		t.Error("expected a core outside the mounts to be refused")
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("expected no pod for a core outside the mounts, got %v", client.Actions())
+	}
+}
@@ -0,0 +1,88 @@
+package similarity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultEmbeddingTimeout = 30 * time.Second
+	maxResponseSize         = 8 << 20
+)
+
+// embedder calls an OpenAI-compatible embeddings endpoint, as served by
+// OpenAI, vLLM, Ollama and most others.
+type embedder struct {
+	config *config.EmbeddingConfig
+	client *http.Client
+}
+
+func newEmbedder(cfg *config.EmbeddingConfig) (*embedder, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultEmbeddingTimeout
+	}
+	client, err := httpclient.New("embedding", cfg.Proxy, cfg.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &embedder{config: cfg, client: client}, nil
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (e *embedder) embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: e.config.Model, Input: []string{text}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.config.APIKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request embedding: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var response embeddingResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(response.Data) == 0 || len(response.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embedding endpoint returned no embedding")
+	}
+	return response.Data[0].Embedding, nil
+}
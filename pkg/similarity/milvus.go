@@ -0,0 +1,222 @@
+package similarity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultMilvusTimeout = 10 * time.Second
+	defaultCollection    = "milvus_crashes"
+	// Longest coredump ID the collection's primary key holds.
+	maxIDLength = 256
+)
+
+// Fields kept next to each vector, in the collection's dynamic field.
+var outputFields = []string{
+	"node", "namespace", "instance", "executable", "timestamp", "fingerprint",
+	"crashReason", "summary", "rootCause", "recommendations",
+}
+
+// collection talks to a Milvus collection through the RESTful API (v2).
+type collection struct {
+	config   *config.MilvusCollectionConfig
+	client   *http.Client
+	baseURL  string
+	database string
+	name     string
+
+	mu    sync.Mutex
+	ready bool
+}
+
+func newCollection(cfg *config.MilvusCollectionConfig) (*collection, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultMilvusTimeout
+	}
+	client, err := httpclient.New("Milvus", cfg.Proxy, cfg.TLS, timeout)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.Collection
+	if name == "" {
+		name = defaultCollection
+	}
+	return &collection{
+		config:   cfg,
+		client:   client,
+		baseURL:  strings.TrimSuffix(cfg.Address, "/"),
+		database: cfg.Database,
+		name:     name,
+	}, nil
+}
+
+// ensure creates the collection for vectors of dimension when it doesn't
+// exist yet. Quick setup indexes the vectors for cosine similarity, keeps
+// the other fields in a dynamic field and loads the collection.
+func (c *collection) ensure(ctx context.Context, dimension int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready {
+		return nil
+	}
+
+	var has struct {
+		Has bool `json:"has"`
+	}
+	if err := c.call(ctx, "/v2/vectordb/collections/has", map[string]interface{}{}, &has); err != nil {
+		return err
+	}
+	if !has.Has {
+		request := map[string]interface{}{
+			"dimension":        dimension,
+			"metricType":       "COSINE",
+			"idType":           "VarChar",
+			"primaryFieldName": "id",
+			"vectorFieldName":  "vector",
+			"params":           map[string]interface{}{"max_length": maxIDLength},
+		}
+		if err := c.call(ctx, "/v2/vectordb/collections/create", request, nil); err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", c.name, err)
+		}
+		klog.Infof("Created Milvus collection %s for crash similarity search (dimension %d)", c.name, dimension)
+	}
+	c.ready = true
+	return nil
+}
+
+func (c *collection) upsert(ctx context.Context, entry map[string]interface{}, dimension int) error {
+	if err := c.ensure(ctx, dimension); err != nil {
+		return err
+	}
+	request := map[string]interface{}{"data": []interface{}{entry}}
+	if err := c.call(ctx, "/v2/vectordb/entities/upsert", request, nil); err != nil {
+		return fmt.Errorf("failed to add crash to collection %s: %w", c.name, err)
+	}
+	return nil
+}
+
+type searchHit struct {
+	ID              string   `json:"id"`
+	Distance        float64  `json:"distance"`
+	Node            string   `json:"node"`
+	Namespace       string   `json:"namespace"`
+	Instance        string   `json:"instance"`
+	Executable      string   `json:"executable"`
+	Timestamp       int64    `json:"timestamp"`
+	Fingerprint     string   `json:"fingerprint"`
+	CrashReason     string   `json:"crashReason"`
+	Summary         string   `json:"summary"`
+	RootCause       string   `json:"rootCause"`
+	Recommendations []string `json:"recommendations"`
+}
+
+func (c *collection) search(ctx context.Context, vector []float32, excludeID string, limit int) ([]collector.SimilarCrash, error) {
+	if err := c.ensure(ctx, len(vector)); err != nil {
+		return nil, err
+	}
+	request := map[string]interface{}{
+		"data":         [][]float32{vector},
+		"annsField":    "vector",
+		"limit":        limit,
+		"outputFields": outputFields,
+	}
+	if excludeID != "" {
+		request["filter"] = "id != " + strconv.Quote(excludeID)
+	}
+	var hits []searchHit
+	if err := c.call(ctx, "/v2/vectordb/entities/search", request, &hits); err != nil {
+		return nil, fmt.Errorf("failed to search collection %s: %w", c.name, err)
+	}
+
+	crashes := make([]collector.SimilarCrash, 0, len(hits))
+	for _, hit := range hits {
+		crashes = append(crashes, collector.SimilarCrash{
+			CoredumpID:      hit.ID,
+			Score:           hit.Distance,
+			Node:            hit.Node,
+			Namespace:       hit.Namespace,
+			Instance:        hit.Instance,
+			Executable:      hit.Executable,
+			Timestamp:       unixTime(hit.Timestamp),
+			CrashReason:     hit.CrashReason,
+			Fingerprint:     hit.Fingerprint,
+			Summary:         hit.Summary,
+			RootCause:       hit.RootCause,
+			Recommendations: hit.Recommendations,
+		})
+	}
+	return crashes, nil
+}
+
+type milvusResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// call posts request, with the database and collection added, and decodes
+// the data of the response into data when set. Milvus answers 200 to most
+// failed requests and reports them in the code.
+func (c *collection) call(ctx context.Context, path string, request map[string]interface{}, data interface{}) error {
+	request["collectionName"] = c.name
+	if c.database != "" {
+		request["dbName"] = c.database
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Milvus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Milvus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read Milvus response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Milvus returned %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+	}
+
+	var response milvusResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return fmt.Errorf("failed to decode Milvus response: %w", err)
+	}
+	if response.Code != 0 {
+		return fmt.Errorf("Milvus error %d: %s", response.Code, response.Message)
+	}
+	if data == nil || len(response.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, data); err != nil {
+		return fmt.Errorf("failed to decode Milvus response: %w", err)
+	}
+	return nil
+}
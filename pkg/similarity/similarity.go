@@ -0,0 +1,169 @@
+// Package similarity finds the past crashes whose stacks are most like a
+// core's, dogfooding Milvus as the vector store. The top frames of each
+// analyzed core are embedded and kept in a Milvus collection along with
+// what the AI analysis found, so the analysis of a new core can show that
+// the crash was seen before and how it was explained then.
+package similarity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/crashgroup"
+)
+
+const (
+	defaultFrames = 20
+	defaultTopK   = 5
+)
+
+// Index embeds stacks and searches the collection. A nil Index finds
+// nothing.
+type Index struct {
+	config     *config.SimilarityConfig
+	embedder   *embedder
+	collection *collection
+	nodeName   string
+}
+
+// New creates the index, or returns nil when similarity search is disabled.
+// Crashes added are recorded as seen on nodeName.
+func New(cfg *config.SimilarityConfig, nodeName string) (*Index, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	embedder, err := newEmbedder(&cfg.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := newCollection(&cfg.Milvus)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{
+		config:     cfg,
+		embedder:   embedder,
+		collection: collection,
+		nodeName:   nodeName,
+	}, nil
+}
+
+// Embed returns the embedding of the core's stack, or nil when the core has
+// no stack frames to compare.
+func (i *Index) Embed(ctx context.Context, coredump *collector.CoredumpFile) ([]float32, error) {
+	if i == nil {
+		return nil, nil
+	}
+	text := i.text(coredump)
+	if text == "" {
+		return nil, nil
+	}
+	return i.embedder.embed(ctx, text)
+}
+
+// Search returns up to limit crashes most similar to vector, leaving out
+// the core excludeID. limit 0 is the configured top k.
+func (i *Index) Search(ctx context.Context, vector []float32, excludeID string, limit int) ([]collector.SimilarCrash, error) {
+	if i == nil || len(vector) == 0 {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = i.topK()
+	}
+	crashes, err := i.collection.search(ctx, vector, excludeID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	similar := crashes[:0]
+	for _, crash := range crashes {
+		if crash.Score >= i.config.MinScore {
+			similar = append(similar, crash)
+		}
+	}
+	return similar, nil
+}
+
+// Similar embeds the core's stack and returns up to limit crashes most
+// similar to it.
+func (i *Index) Similar(ctx context.Context, coredump *collector.CoredumpFile, limit int) ([]collector.SimilarCrash, error) {
+	vector, err := i.Embed(ctx, coredump)
+	if err != nil {
+		return nil, err
+	}
+	return i.Search(ctx, vector, coredump.ID, limit)
+}
+
+// Add stores the analyzed core with its embedding, so later crashes find
+// it. A core added again replaces its earlier entry.
+func (i *Index) Add(ctx context.Context, coredump *collector.CoredumpFile, vector []float32) error {
+	if i == nil || len(vector) == 0 {
+		return nil
+	}
+	entry := map[string]interface{}{
+		"id":          coredump.ID,
+		"vector":      vector,
+		"node":        i.nodeName,
+		"namespace":   coredump.PodNamespace,
+		"instance":    coredump.InstanceName,
+		"executable":  coredump.Executable,
+		"timestamp":   coredump.Timestamp.Unix(),
+		"fingerprint": coredump.Fingerprint,
+	}
+	if results := coredump.AnalysisResults; results != nil {
+		entry["crashReason"] = results.CrashReason
+		if ai := results.AIAnalysis; ai != nil && ai.ErrorMessage == "" {
+			entry["summary"] = ai.Summary
+			entry["rootCause"] = ai.RootCause
+			entry["recommendations"] = ai.Recommendations
+		}
+	}
+	return i.collection.upsert(ctx, entry, len(vector))
+}
+
+// text is what is embedded of a core: its executable, signal, crash reason
+// and top frames, without the addresses and arguments that differ between
+// cores of the same crash.
+func (i *Index) text(coredump *collector.CoredumpFile) string {
+	results := coredump.AnalysisResults
+	if results == nil {
+		return ""
+	}
+	frames := i.config.Frames
+	if frames <= 0 {
+		frames = defaultFrames
+	}
+	_, top := crashgroup.Fingerprint(coredump.Executable, coredump.Signal, results.StackTrace, frames)
+	if len(top) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "executable: %s\nsignal: %d\n", coredump.Executable, coredump.Signal)
+	if results.CrashReason != "" {
+		fmt.Fprintf(&b, "crash reason: %s\n", results.CrashReason)
+	}
+	b.WriteString("frames:\n")
+	for _, frame := range top {
+		b.WriteString(frame + "\n")
+	}
+	return b.String()
+}
+
+func (i *Index) topK() int {
+	if i.config.TopK > 0 {
+		return i.config.TopK
+	}
+	return defaultTopK
+}
+
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
@@ -0,0 +1,159 @@
+package similarity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// fakeMilvus serves the RESTful API calls the index makes.
+type fakeMilvus struct {
+	mu       sync.Mutex
+	created  map[string]interface{}
+	entries  []map[string]interface{}
+	requests []map[string]interface{}
+	paths    []string
+}
+
+func (f *fakeMilvus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var request map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&request)
+	f.requests = append(f.requests, request)
+	f.paths = append(f.paths, r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer root:Milvus" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 1800, "message": "user hasn't authenticated"})
+		return
+	}
+
+	var data interface{} = map[string]interface{}{}
+	switch r.URL.Path {
+	case "/v2/vectordb/collections/has":
+		data = map[string]bool{"has": f.created != nil}
+	case "/v2/vectordb/collections/create":
+		f.created = request
+	case "/v2/vectordb/entities/upsert":
+		f.entries = append(f.entries, request["data"].([]interface{})[0].(map[string]interface{}))
+	case "/v2/vectordb/entities/search":
+		data = []map[string]interface{}{
+			{"id": "core-1", "distance": 0.97, "instance": "prod", "timestamp": 1714521600,
+				"crashReason": "SIGSEGV", "rootCause": "null segment", "recommendations": []string{"upgrade to 2.4.5"}},
+			{"id": "core-2", "distance": 0.41, "instance": "dev"},
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+}
+
+func TestIndexAddsAndSearchesCrashes(t *testing.T) {
+	embeddings := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request embeddingRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Model != "embed-small" || !strings.Contains(request.Input[0], "frames:\nsegcore::Search\n") ||
+			strings.Contains(request.Input[0], "0x7f") {
+			t.Errorf("expected the top frames without addresses to be embedded, got %+v", request)
+		}
+		w.Write([]byte(`{"data": [{"embedding": [0.1, 0.2, 0.3]}]}`))
+	}))
+	defer embeddings.Close()
+	milvus := &fakeMilvus{}
+	milvusServer := httptest.NewServer(milvus)
+	defer milvusServer.Close()
+
+	index, err := New(&config.SimilarityConfig{
+		Enabled:   true,
+		Embedding: config.EmbeddingConfig{URL: embeddings.URL, Model: "embed-small"},
+		Milvus:    config.MilvusCollectionConfig{Address: milvusServer.URL, Token: "root:Milvus", Collection: "crashes"},
+		MinScore:  0.8,
+	}, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coredump := &collector.CoredumpFile{
+		ID:           "core-3",
+		Executable:   "milvus",
+		Signal:       11,
+		InstanceName: "prod",
+		Timestamp:    time.Unix(1714608000, 0),
+		AnalysisResults: &collector.AnalysisResults{
+			CrashReason: "SIGSEGV",
+			StackTrace:  "#0  0x7f3a in segcore::Search (seg=0x0) at search.cpp:42\n#1  0x7f3b in QueryNode::Search (req=...) at node.cpp:7\n",
+			AIAnalysis:  &collector.AIAnalysisResult{RootCause: "null segment"},
+		},
+	}
+
+	similar, err := index.Similar(context.Background(), coredump, 0)
+	if err != nil {
+		t.Fatalf("Similar failed: %v", err)
+	}
+	if len(similar) != 1 || similar[0].CoredumpID != "core-1" || similar[0].Recommendations[0] != "upgrade to 2.4.5" ||
+		!similar[0].Timestamp.Equal(time.Unix(1714521600, 0)) {
+		t.Errorf("expected only the crash above minScore, got %+v", similar)
+	}
+	if milvus.created["dimension"] != 3.0 || milvus.created["metricType"] != "COSINE" || milvus.created["collectionName"] != "crashes" {
+		t.Errorf("expected the collection to be created for the embedding's dimension, got %v", milvus.created)
+	}
+	search := milvus.requests[len(milvus.requests)-1]
+	if search["filter"] != `id != "core-3"` || search["limit"] != 5.0 {
+		t.Errorf("expected the core itself left out of the top 5, got %v", search)
+	}
+
+	vector, err := index.Embed(context.Background(), coredump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Add(context.Background(), coredump, vector); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if len(milvus.entries) != 1 || milvus.entries[0]["id"] != "core-3" || milvus.entries[0]["node"] != "node-1" ||
+		milvus.entries[0]["rootCause"] != "null segment" {
+		t.Errorf("expected the core and its root cause to be stored, got %v", milvus.entries)
+	}
+	hasCalls := 0
+	for _, path := range milvus.paths {
+		if path == "/v2/vectordb/collections/has" {
+			hasCalls++
+		}
+	}
+	if hasCalls != 1 {
+		t.Errorf("expected the collection to be checked once, got %d", hasCalls)
+	}
+
+	// Cores without stack frames have nothing to compare.
+	if vector, err := index.Embed(context.Background(), &collector.CoredumpFile{AnalysisResults: &collector.AnalysisResults{}}); err != nil || vector != nil {
+		t.Errorf("expected no embedding without frames, got %v, %v", vector, err)
+	}
+}
+
+func TestIndexReportsMilvusErrors(t *testing.T) {
+	milvus := httptest.NewServer(&fakeMilvus{})
+	defer milvus.Close()
+
+	index, err := New(&config.SimilarityConfig{
+		Enabled:   true,
+		Embedding: config.EmbeddingConfig{URL: "http://127.0.0.1:1"},
+		Milvus:    config.MilvusCollectionConfig{Address: milvus.URL},
+	}, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = index.Search(context.Background(), []float32{1}, "", 0)
+	if err == nil || !strings.Contains(err.Error(), "1800") {
+		t.Errorf("expected Milvus' error code, got %v", err)
+	}
+
+	var nilIndex *Index
+	if similar, err := nilIndex.Similar(context.Background(), &collector.CoredumpFile{}, 0); err != nil || similar != nil {
+		t.Errorf("expected a nil index to find nothing, got %v, %v", similar, err)
+	}
+}
@@ -0,0 +1,38 @@
+package statefile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "processed-files.json")
+
+	loaded := []string{"unchanged"}
+	if err := Load(path, &loaded); err != nil || len(loaded) != 1 {
+		t.Fatalf("expected a missing file to leave the state alone, got %v (%v)", loaded, err)
+	}
+
+	saved := []string{"/var/lib/systemd/coredump/core.milvus.1000.1.2"}
+	if err := Save(path, saved); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	loaded = nil
+	if err := Load(path, &loaded); err != nil || !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("expected %v, got %v (%v)", saved, loaded, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected no temporary files left behind, got %v (%v)", entries, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path, &loaded); err == nil {
+		t.Error("expected an error for a corrupt state file")
+	}
+}
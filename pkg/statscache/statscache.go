@@ -0,0 +1,137 @@
+// Package statscache caches aggregate statistics computed over the agent's
+// records. Entries expire after a TTL and are all dropped when the records
+// change, so readers never see stats older than the last write. Caches
+// register by name like chanstats channels, and their hit rates are exported
+// as metrics.
+package statscache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultTTL = 10 * time.Second
+
+type Stats struct {
+	Name          string `json:"name"`
+	Entries       int    `json:"entries"`
+	Hits          int64  `json:"hits"`
+	Misses        int64  `json:"misses"`
+	Invalidations int64  `json:"invalidations"`
+}
+
+type entry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// Cache is a keyed TTL cache. A nil *Cache computes every value.
+type Cache struct {
+	name string
+	ttl  time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]entry
+	generation uint64
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+var (
+	mu     sync.RWMutex
+	caches = make(map[string]*Cache)
+)
+
+// New creates a cache and registers it under name, replacing any cache
+// registered before. A ttl of zero or less uses DefaultTTL.
+func New(name string, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	c := &Cache{
+		name:    name,
+		ttl:     ttl,
+		entries: make(map[string]entry),
+	}
+	mu.Lock()
+	caches[name] = c
+	mu.Unlock()
+	return c
+}
+
+// Get returns the cached value for key, calling compute when there is none
+// or it expired. compute runs without the lock held; a value computed while
+// the cache was invalidated is returned but not stored. Callers must not
+// modify the returned value, it is shared with every other reader.
+func Get[T any](c *Cache, key string, compute func() T) T {
+	if c == nil {
+		return compute()
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	cached, exists := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if exists && now.Before(cached.expiresAt) {
+		c.hits.Add(1)
+		return cached.value.(T)
+	}
+	c.misses.Add(1)
+
+	value := compute()
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[key] = entry{value: value, expiresAt: now.Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return value
+}
+
+// Invalidate drops every entry. Writers call it after changing the records
+// the cached stats are computed from.
+func (c *Cache) Invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.generation++
+	dropped := len(c.entries) > 0
+	c.entries = make(map[string]entry)
+	c.mu.Unlock()
+	if dropped {
+		c.invalidations.Add(1)
+	}
+}
+
+func (c *Cache) stats() Stats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return Stats{
+		Name:          c.name,
+		Entries:       entries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// Snapshot returns the state of every registered cache, sorted by name.
+func Snapshot() []Stats {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	stats := make([]Stats, 0, len(caches))
+	for _, c := range caches {
+		stats = append(stats, c.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
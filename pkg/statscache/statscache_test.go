@@ -0,0 +1,73 @@
+package statscache
+
+import (
+	"testing"
+	"time"
+)
+
+func find(t *testing.T, name string) Stats {
+	t.Helper()
+	for _, stats := range Snapshot() {
+		if stats.Name == name {
+			return stats
+		}
+	}
+	t.Fatalf("cache %s not in snapshot", name)
+	return Stats{}
+}
+
+func TestGetCachesUntilInvalidated(t *testing.T) {
+	cache := New("test_invalidate", time.Hour)
+	computed := 0
+	compute := func() int {
+		computed++
+		return computed
+	}
+
+	if Get(cache, "count", compute) != 1 || Get(cache, "count", compute) != 1 {
+		t.Fatal("expected the second read to hit the cache")
+	}
+	cache.Invalidate()
+	if value := Get(cache, "count", compute); value != 2 {
+		t.Errorf("expected a recompute after invalidation, got %d", value)
+	}
+
+	stats := find(t, "test_invalidate")
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Invalidations != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestGetExpiresEntries(t *testing.T) {
+	cache := New("test_expiry", time.Millisecond)
+	computed := 0
+	compute := func() int {
+		computed++
+		return computed
+	}
+
+	Get(cache, "count", compute)
+	time.Sleep(5 * time.Millisecond)
+	if value := Get(cache, "count", compute); value != 2 {
+		t.Errorf("expected a recompute after the TTL, got %d", value)
+	}
+}
+
+func TestValueComputedDuringInvalidationIsNotStored(t *testing.T) {
+	cache := New("test_race", time.Hour)
+	Get(cache, "count", func() int {
+		cache.Invalidate()
+		return 1
+	})
+	if value := Get(cache, "count", func() int { return 2 }); value != 2 {
+		t.Errorf("expected the stale value to be discarded, got %d", value)
+	}
+}
+
+func TestNilCacheComputes(t *testing.T) {
+	var cache *Cache
+	cache.Invalidate()
+	if Get(cache, "count", func() int { return 7 }) != 7 {
+		t.Error("nil cache must return the computed value")
+	}
+}
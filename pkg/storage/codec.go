@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	CompressionZstd = "zstd"
+	CompressionLZ4  = "lz4"
+)
+
+// codec compresses stored cores. level 0 selects the codec's default.
+type codec struct {
+	extension string
+	writer    func(w io.Writer, level int) (io.WriteCloser, error)
+	reader    func(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = map[string]codec{
+	CompressionGzip: {
+		extension: ".gz",
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	// zstd and lz4 compress multi-GB cores several times faster than gzip,
+	// lz4 at a lower ratio.
+	CompressionZstd: {
+		extension: ".zst",
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				return zstd.NewWriter(w)
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	CompressionLZ4: {
+		extension: ".lz4",
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			writer := lz4.NewWriter(w)
+			if level > 0 {
+				if err := writer.Apply(lz4.CompressionLevelOption(lz4.CompressionLevel(1 << (8 + level - 1)))); err != nil {
+					return nil, err
+				}
+			}
+			return writer, nil
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(lz4.NewReader(r)), nil
+		},
+	},
+	CompressionNone: {
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	},
+}
+
+// storageExtension is the file extension of cores stored with compression.
+// Cores stored before the codec was recorded were gzipped.
+func storageExtension(compression string) string {
+	if compression == "" {
+		compression = CompressionGzip
+	}
+	return ".core" + codecs[compression].extension
+}
+
+// decompressReader returns the content of a core stored with compression.
+func decompressReader(reader io.ReadCloser, compression string) (io.ReadCloser, error) {
+	if compression == "" {
+		compression = CompressionGzip
+	}
+	codec, exists := codecs[compression]
+	if !exists {
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+	decompressed, err := codec.reader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", compression, err)
+	}
+	return &stackedReadCloser{Reader: decompressed, closers: []io.Closer{decompressed, reader}}, nil
+}
+
+// stackedReadCloser closes the decompressor and the stream under it.
+type stackedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *stackedReadCloser) Close() error {
+	var first error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestCompressionCodecs(t *testing.T) {
+	content := bytes.Repeat([]byte("synthetic core with repeated pages "), 4096)
+	corePath := filepath.Join(t.TempDir(), "core.milvus.1000.1234567890.11")
+	if err := os.WriteFile(corePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		compression string
+		level       int
+		extension   string
+	}{
+		{CompressionGzip, 0, ".core.gz"},
+		{CompressionGzip, 1, ".core.gz"},
+		{CompressionZstd, 0, ".core.zst"},
+		{CompressionZstd, 19, ".core.zst"},
+		{CompressionLZ4, 0, ".core.lz4"},
+		{CompressionLZ4, 9, ".core.lz4"},
+		{CompressionNone, 0, ".core"},
+	} {
+		storage, err := New(&config.StorageConfig{
+			Backend:          "memory",
+			Compression:      tc.compression,
+			CompressionLevel: tc.level,
+		}, &config.AnalyzerConfig{}, nil, "", "")
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+
+		core := &collector.CoredumpFile{ID: "abc123", Path: corePath, FileName: filepath.Base(corePath), Timestamp: time.Now()}
+		path, err := storage.storeFile(context.Background(), storage.backend, core)
+		if err != nil {
+			t.Fatalf("%s: store failed: %v", tc.compression, err)
+		}
+		core.StoragePath = path
+		if !strings.HasSuffix(path, tc.extension) || core.Compression != tc.compression {
+			t.Errorf("%s: expected a %s file recorded as %s, got %s as %s", tc.compression, tc.extension, tc.compression, path, core.Compression)
+		}
+		if tc.compression != CompressionNone && core.StoredSize >= int64(len(content)) {
+			t.Errorf("%s: expected the core to shrink, stored %d of %d bytes", tc.compression, core.StoredSize, len(content))
+		}
+
+		reader, err := storage.Open(context.Background(), core)
+		if err != nil {
+			t.Fatalf("%s: open failed: %v", tc.compression, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s: expected the original content back, got %d bytes (%v)", tc.compression, len(data), err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/statefile"
+)
+
+// DedupModeContent stores every core but keeps identical files once.
+const DedupModeContent = "content"
+
+const blobPrefix = "blobs/"
+
+// mover is implemented by backends that can rename a stored file.
+type mover interface {
+	Move(ctx context.Context, from, to string) error
+}
+
+// contentBackend deduplicates the files written to a backend by content.
+// Each file is hashed while it streams to the backend and then moved to a
+// path named after its SHA-256; a later file with the same hash is dropped
+// and shares the stored blob. Callers keep using one path per core: the
+// reference table maps those paths to blobs, and a blob is only deleted
+// with its last reference.
+//
+// The table is local to the agent, so blobs live under a per-node prefix
+// where no other agent references them.
+type contentBackend struct {
+	backend   Backend
+	prefix    string
+	statePath string
+
+	// mu is held while a new file is matched against the stored blobs, so
+	// two copies of a file can't both become the blob.
+	mu    sync.Mutex
+	refs  map[string]*contentRef
+	blobs map[string]*contentBlob
+}
+
+type contentRef struct {
+	Hash     string    `json:"hash"`
+	StoredAt time.Time `json:"storedAt"`
+}
+
+type contentBlob struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Refs int    `json:"refs"`
+}
+
+type contentState struct {
+	Refs  map[string]*contentRef  `json:"refs"`
+	Blobs map[string]*contentBlob `json:"blobs"`
+}
+
+func newContentBackend(backend Backend, nodeName, statePath string) *contentBackend {
+	prefix := blobPrefix
+	if nodeName != "" {
+		prefix += nodeName + "/"
+	}
+	b := &contentBackend{
+		backend:   backend,
+		prefix:    prefix,
+		statePath: statePath,
+		refs:      make(map[string]*contentRef),
+		blobs:     make(map[string]*contentBlob),
+	}
+	b.load()
+	return b
+}
+
+// Store uploads the file, then either moves it to its blob path or, when
+// the blob already exists, deletes it again. The returned path is the one
+// the backend would have used without deduplication.
+func (b *contentBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(reader, hash)}
+	uploaded, err := b.backend.Store(ctx, file, counter)
+	if err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blob, exists := b.blobs[sum]
+	if exists && blob.Path != uploaded {
+		if err := b.backend.Delete(ctx, uploaded); err != nil {
+			klog.Warningf("Failed to delete duplicate upload %s of blob %s: %v", uploaded, blob.Path, err)
+		}
+		klog.Infof("Core %s has the same content as blob %s, sharing it", uploaded, blob.Path)
+	} else if !exists {
+		blob = &contentBlob{Path: uploaded, Size: counter.n}
+		if mover, ok := b.backend.(mover); ok {
+			blobPath := b.prefix + sum + storageExtension(file.Compression)
+			// A file that can't be moved stays a blob under its upload path.
+			if err := mover.Move(ctx, uploaded, blobPath); err != nil {
+				klog.Warningf("Failed to move %s to %s, keeping it in place: %v", uploaded, blobPath, err)
+			} else {
+				blob.Path = blobPath
+			}
+		}
+		b.blobs[sum] = blob
+	}
+
+	previous := b.refs[uploaded]
+	b.refs[uploaded] = &contentRef{Hash: sum, StoredAt: time.Now()}
+	blob.Refs++
+	if previous != nil {
+		b.release(ctx, previous.Hash)
+	}
+	b.save()
+	return uploaded, nil
+}
+
+func (b *contentBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	return b.backend.Retrieve(ctx, b.resolve(path))
+}
+
+// resolve returns the blob a path refers to, or the path itself for files
+// stored without deduplication.
+func (b *contentBackend) resolve(path string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ref, exists := b.refs[path]; exists {
+		if blob, exists := b.blobs[ref.Hash]; exists {
+			return blob.Path
+		}
+	}
+	return path
+}
+
+// Delete drops a reference and deletes the blob once nothing refers to it.
+func (b *contentBackend) Delete(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ref, exists := b.refs[path]
+	if !exists {
+		return b.backend.Delete(ctx, path)
+	}
+	if err := b.release(ctx, ref.Hash); err != nil {
+		return err
+	}
+	delete(b.refs, path)
+	b.save()
+	return nil
+}
+
+// release drops a reference to a blob, deleting the blob with the last
+// one. It must be called with b.mu held.
+func (b *contentBackend) release(ctx context.Context, hash string) error {
+	blob, exists := b.blobs[hash]
+	if !exists {
+		return nil
+	}
+	if blob.Refs > 1 {
+		blob.Refs--
+		return nil
+	}
+	if err := b.backend.Delete(ctx, blob.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(b.blobs, hash)
+	return nil
+}
+
+// List returns a file per reference in place of each blob. The blob's size
+// is split between its references, so the total matches the space used.
+func (b *contentBackend) List(ctx context.Context) ([]*StoredFile, error) {
+	stored, err := b.backend.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	byPath := make(map[string]*contentBlob, len(b.blobs))
+	for _, blob := range b.blobs {
+		byPath[blob.Path] = blob
+	}
+	refsByBlob := make(map[*contentBlob][]string)
+	for path, ref := range b.refs {
+		if blob, exists := b.blobs[ref.Hash]; exists {
+			refsByBlob[blob] = append(refsByBlob[blob], path)
+		}
+	}
+
+	var files []*StoredFile
+	for _, file := range stored {
+		blob, exists := byPath[file.Path]
+		if !exists {
+			files = append(files, file)
+			continue
+		}
+		paths := refsByBlob[blob]
+		sort.Strings(paths)
+		for i, path := range paths {
+			size := file.Size / int64(len(paths))
+			if i == 0 {
+				size += file.Size % int64(len(paths))
+			}
+			files = append(files, &StoredFile{
+				Path:         path,
+				Size:         size,
+				StoredAt:     b.refs[path].StoredAt,
+				ValueScore:   file.ValueScore,
+				InstanceName: file.InstanceName,
+			})
+		}
+	}
+	return files, nil
+}
+
+func (b *contentBackend) GetStorageSize(ctx context.Context) (int64, error) {
+	return b.backend.GetStorageSize(ctx)
+}
+
+func (b *contentBackend) load() {
+	if b.statePath == "" {
+		return
+	}
+	var saved contentState
+	if err := statefile.Load(b.statePath, &saved); err != nil {
+		klog.Warningf("Starting without content dedup references: %v", err)
+		return
+	}
+	for hash, blob := range saved.Blobs {
+		if blob != nil {
+			blob.Refs = 0
+			b.blobs[hash] = blob
+		}
+	}
+	// Reference counts are rebuilt from the references themselves.
+	for path, ref := range saved.Refs {
+		if ref == nil {
+			continue
+		}
+		if blob, exists := b.blobs[ref.Hash]; exists {
+			b.refs[path] = ref
+			blob.Refs++
+		}
+	}
+	for hash, blob := range b.blobs {
+		if blob.Refs == 0 {
+			delete(b.blobs, hash)
+		}
+	}
+	if len(b.refs) > 0 {
+		klog.Infof("Restored %d content dedup references to %d blobs from %s", len(b.refs), len(b.blobs), b.statePath)
+	}
+}
+
+// save must be called with b.mu held.
+func (b *contentBackend) save() {
+	if b.statePath == "" {
+		return
+	}
+	if err := statefile.Save(b.statePath, contentState{Refs: b.refs, Blobs: b.blobs}); err != nil {
+		klog.Warningf("Failed to save content dedup references: %v", err)
+	}
+}
+
+// secondaryStatePath is the reference table of the secondary backend,
+// kept next to the primary's.
+func secondaryStatePath(statePath string) string {
+	if statePath == "" {
+		return ""
+	}
+	ext := filepath.Ext(statePath)
+	return strings.TrimSuffix(statePath, ext) + "-secondary" + ext
+}
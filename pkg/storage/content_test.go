@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func storeCore(t *testing.T, backend Backend, pod, content string) string {
+	t.Helper()
+	core := &collector.CoredumpFile{
+		InstanceName:  "milvus",
+		PodName:       pod,
+		ContainerName: "querynode",
+		Timestamp:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	path, err := backend.Store(context.Background(), core, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	return path
+}
+
+func TestContentBackendSharesIdenticalFiles(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := NewMemoryBackend(&config.StorageConfig{})
+	statePath := filepath.Join(t.TempDir(), "content-refs.json")
+	backend := newContentBackend(memory, "node-1", statePath)
+
+	first := storeCore(t, backend, "pod-a", "crash loop core")
+	second := storeCore(t, backend, "pod-b", "crash loop core")
+	other := storeCore(t, backend, "pod-c", "another core")
+	if first == second {
+		t.Fatalf("expected a path per core, got %s twice", first)
+	}
+
+	stored, _ := memory.List(ctx)
+	if len(stored) != 2 {
+		t.Fatalf("expected two blobs, got %+v", stored)
+	}
+	for _, file := range stored {
+		if !strings.HasPrefix(file.Path, "blobs/node-1/") || !strings.HasSuffix(file.Path, ".core.gz") {
+			t.Errorf("expected blobs under the node's prefix, got %s", file.Path)
+		}
+	}
+
+	files, err := backend.List(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	var total int64
+	paths := map[string]bool{}
+	for _, file := range files {
+		total += file.Size
+		paths[file.Path] = true
+	}
+	if len(files) != 3 || !paths[first] || !paths[second] || !paths[other] {
+		t.Errorf("expected a file per core, got %+v", files)
+	}
+	if size, _ := memory.GetStorageSize(ctx); total != size {
+		t.Errorf("expected listed sizes to add up to the %d bytes stored, got %d", size, total)
+	}
+
+	for _, path := range []string{first, second} {
+		reader, err := backend.Retrieve(ctx, path)
+		if err != nil {
+			t.Fatalf("retrieve %s failed: %v", path, err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != "crash loop core" {
+			t.Errorf("unexpected content %q for %s", data, path)
+		}
+	}
+
+	if err := backend.Delete(ctx, first); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if reader, err := backend.Retrieve(ctx, second); err != nil {
+		t.Errorf("expected the shared blob to outlive the first reference: %v", err)
+	} else {
+		reader.Close()
+	}
+
+	// A restarted agent still knows the blob is shared.
+	restored := newContentBackend(memory, "node-1", statePath)
+	if err := restored.Delete(ctx, second); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if stored, _ := memory.List(ctx); len(stored) != 1 {
+		t.Errorf("expected the blob to go with its last reference, got %+v", stored)
+	}
+	if _, err := restored.Retrieve(ctx, second); err == nil {
+		t.Error("expected the deleted core to be gone")
+	}
+}
+
+func TestContentBackendMovesS3Objects(t *testing.T) {
+	fake, s3Config := newFakeS3(t, false)
+	s3Config.Prefix = "agent"
+	s3, err := NewS3Backend(&config.StorageConfig{S3: s3Config})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	backend := newContentBackend(s3, "", "")
+
+	path := storeCore(t, backend, "pod-a", "synthetic core")
+	storeCore(t, backend, "pod-b", "synthetic core")
+
+	fake.mu.Lock()
+	var keys []string
+	for key := range fake.objects {
+		keys = append(keys, key)
+	}
+	fake.mu.Unlock()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "agent/blobs/") || !strings.HasSuffix(keys[0], ".core.gz") {
+		t.Fatalf("expected a single blob in the bucket, got %v", keys)
+	}
+
+	reader, err := backend.Retrieve(context.Background(), path)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); string(data) != "synthetic core" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
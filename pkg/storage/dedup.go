@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/crashgroup"
+)
+
+const (
+	DedupModeOff      = "off"
+	DedupModeMetadata = "metadata"
+)
+
+// dedupIndex remembers the first stored core for each crash fingerprint so
+// later cores from the same crash loop can be recorded as metadata only.
+// Repeats are not stored as binary diffs against the original, so their
+// content can't be reconstructed; download the original via DuplicateOf.
+type dedupIndex struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	StoredPath string
+	StoredAt   time.Time
+	Duplicates int
+}
+
+func newDedupIndex(window time.Duration) *dedupIndex {
+	return &dedupIndex{
+		window:  window,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+// lookup returns the stored path of the original core if the fingerprint
+// was stored within the dedup window, and counts the duplicate.
+func (d *dedupIndex) lookup(fingerprint string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, exists := d.entries[fingerprint]
+	if !exists {
+		return "", false
+	}
+	if d.window > 0 && time.Since(entry.StoredAt) > d.window {
+		delete(d.entries, fingerprint)
+		return "", false
+	}
+
+	entry.Duplicates++
+	return entry.StoredPath, true
+}
+
+func (d *dedupIndex) record(fingerprint, storedPath string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entries[fingerprint] = &dedupEntry{
+		StoredPath: storedPath,
+		StoredAt:   time.Now(),
+	}
+}
+
+// forget drops index entries that point at a deleted file so that the next
+// core with that fingerprint is stored in full again.
+func (d *dedupIndex) forget(storedPath string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for fingerprint, entry := range d.entries {
+		if entry.StoredPath == storedPath {
+			delete(d.entries, fingerprint)
+		}
+	}
+}
+
+// crashFingerprint returns the fingerprint the analyzer gave the core, or
+// computes it the same way when crash grouping is off.
+func crashFingerprint(coredump *collector.CoredumpFile, frames int) string {
+	if coredump.Fingerprint != "" || coredump.AnalysisResults == nil {
+		return coredump.Fingerprint
+	}
+	fingerprint, _ := crashgroup.Fingerprint(coredump.Executable, coredump.Signal, coredump.AnalysisResults.StackTrace, frames)
+	return fingerprint
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/testutil"
+)
+
+func TestCrashFingerprintPrefersAnalyzer(t *testing.T) {
+	core := &collector.CoredumpFile{
+		Executable:      "milvus",
+		Signal:          11,
+		AnalysisResults: &collector.AnalysisResults{StackTrace: testutil.LoadTestGDBOutput(t, "milvus_segcore_sigsegv.txt")},
+	}
+
+	computed := crashFingerprint(core, 0)
+	if computed == "" {
+		t.Fatal("expected a fingerprint for a parsable stack trace")
+	}
+
+	core.Fingerprint = "from-analyzer"
+	if fp := crashFingerprint(core, 0); fp != "from-analyzer" {
+		t.Errorf("expected the analyzer's fingerprint, got %s", fp)
+	}
+	if fp := crashFingerprint(&collector.CoredumpFile{Executable: "milvus"}, 0); fp != "" {
+		t.Errorf("expected empty fingerprint without analysis results, got %s", fp)
+	}
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+)
+
+const (
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+
+	// ingestWindow is how far back the ingestion rate looks.
+	ingestWindow = 7 * 24 * time.Hour
+	// minIngestHistory is the history needed before projecting when the
+	// storage fills up; a single core right after startup says nothing
+	// about the rate.
+	minIngestHistory = time.Hour
+)
+
+// UsageBucket is the storage used by one instance's cores.
+type UsageBucket struct {
+	Namespace     string `json:"namespace"`
+	Instance      string `json:"instance"`
+	Files         int    `json:"files"`
+	OriginalBytes int64  `json:"originalBytes"`
+	StoredBytes   int64  `json:"storedBytes"`
+}
+
+// EfficiencyStats describes how well the primary backend's space is used.
+// Original sizes and compression are only known for cores stored since the
+// agent started; files found in the backend at startup count with their
+// stored size and, when the path allows, their instance.
+type EfficiencyStats struct {
+	Backend           string  `json:"backend"`
+	Compression       string  `json:"compression"`
+	Files             int     `json:"files"`
+	UsedBytes         int64   `json:"usedBytes"`
+	CapacityBytes     int64   `json:"capacityBytes"`
+	OriginalBytes     int64   `json:"originalBytes"`
+	CompressedBytes   int64   `json:"compressedBytes"`
+	CompressionRatio  float64 `json:"compressionRatio"`
+	IngestBytesPerDay float64 `json:"ingestBytesPerDay"`
+	// DaysUntilFull is nil while the ingestion rate is unknown or zero.
+	DaysUntilFull *float64      `json:"daysUntilFull,omitempty"`
+	ByInstance    []UsageBucket `json:"byInstance"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+}
+
+type usageEntry struct {
+	namespace     string
+	instance      string
+	originalBytes int64
+	storedBytes   int64
+}
+
+type ingest struct {
+	at    time.Time
+	bytes int64
+}
+
+// usageTracker follows the files on the primary backend as they are stored
+// and cleaned up, so the stats cost no backend calls.
+type usageTracker struct {
+	mu      sync.Mutex
+	started time.Time
+	files   map[string]*usageEntry
+	ingests []ingest
+
+	// Totals over the cores stored since startup, for the compression ratio.
+	originalBytes   int64
+	compressedBytes int64
+}
+
+func newUsageTracker(now time.Time) *usageTracker {
+	return &usageTracker{
+		started: now,
+		files:   make(map[string]*usageEntry),
+	}
+}
+
+// seed records files already in the backend. Their instance is the first
+// path element, see generateStorageFilename.
+func (u *usageTracker) seed(files []*StoredFile) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, file := range files {
+		if _, exists := u.files[file.Path]; exists {
+			continue
+		}
+		instance := filepath.Dir(file.Path)
+		if instance == "." {
+			instance = ""
+		}
+		u.files[file.Path] = &usageEntry{instance: instance, storedBytes: file.Size}
+	}
+}
+
+func (u *usageTracker) record(path string, coredump *collector.CoredumpFile, now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.files[path] = &usageEntry{
+		namespace:     coredump.PodNamespace,
+		instance:      coredump.InstanceName,
+		originalBytes: coredump.Size,
+		storedBytes:   coredump.StoredSize,
+	}
+	u.originalBytes += coredump.Size
+	u.compressedBytes += coredump.StoredSize
+	u.ingests = append(u.ingests, ingest{at: now, bytes: coredump.StoredSize})
+}
+
+func (u *usageTracker) forget(path string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.files, path)
+}
+
+func (u *usageTracker) stats(capacity int64, now time.Time) EfficiencyStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	stats := EfficiencyStats{
+		CapacityBytes:   capacity,
+		OriginalBytes:   u.originalBytes,
+		CompressedBytes: u.compressedBytes,
+		UpdatedAt:       now,
+	}
+	if u.originalBytes > 0 {
+		stats.CompressionRatio = float64(u.compressedBytes) / float64(u.originalBytes)
+	}
+
+	buckets := make(map[string]*UsageBucket)
+	for _, entry := range u.files {
+		stats.Files++
+		stats.UsedBytes += entry.storedBytes
+		key := entry.namespace + "/" + entry.instance
+		bucket, exists := buckets[key]
+		if !exists {
+			bucket = &UsageBucket{Namespace: entry.namespace, Instance: entry.instance}
+			buckets[key] = bucket
+		}
+		bucket.Files++
+		bucket.OriginalBytes += entry.originalBytes
+		bucket.StoredBytes += entry.storedBytes
+	}
+	stats.ByInstance = make([]UsageBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		stats.ByInstance = append(stats.ByInstance, *bucket)
+	}
+	sort.Slice(stats.ByInstance, func(i, j int) bool {
+		return stats.ByInstance[i].StoredBytes > stats.ByInstance[j].StoredBytes
+	})
+
+	cutoff := now.Add(-ingestWindow)
+	kept := u.ingests[:0]
+	var ingested int64
+	for _, entry := range u.ingests {
+		if entry.at.After(cutoff) {
+			kept = append(kept, entry)
+			ingested += entry.bytes
+		}
+	}
+	u.ingests = kept
+
+	history := now.Sub(u.started)
+	if history > ingestWindow {
+		history = ingestWindow
+	}
+	if history >= minIngestHistory {
+		stats.IngestBytesPerDay = float64(ingested) / history.Hours() * 24
+		if stats.IngestBytesPerDay > 0 && capacity > 0 {
+			days := float64(capacity-stats.UsedBytes) / stats.IngestBytesPerDay
+			if days < 0 {
+				days = 0
+			}
+			stats.DaysUntilFull = &days
+		}
+	}
+	return stats
+}
+
+// Efficiency reports compression savings and space usage of the primary
+// backend. A nil *Storage reports nothing.
+func (s *Storage) Efficiency() EfficiencyStats {
+	if s == nil {
+		return EfficiencyStats{}
+	}
+	stats := s.usage.stats(s.parseSize(s.config().MaxStorageSize), time.Now())
+	stats.Backend = s.config().Backend
+	stats.Compression = s.compression()
+	return stats
+}
+
+func (s *Storage) compression() string {
+	return s.config().EffectiveCompression()
+}
+
+// seedUsage lists the primary backend once at startup.
+func (s *Storage) seedUsage(ctx context.Context) {
+	files, err := s.backend.List(ctx)
+	if err != nil {
+		klog.Warningf("Failed to list %s backend for usage stats: %v", s.config().Backend, err)
+		return
+	}
+	s.usage.seed(files)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestStoreFileRecordsCompression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "core.milvus.1000.1234567890.11")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("milvus"), 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	coredump := &collector.CoredumpFile{Path: path, FileName: filepath.Base(path), Size: 60000, Timestamp: time.Now()}
+
+	storage, err := New(&config.StorageConfig{
+		Backend:            "local",
+		LocalPath:          t.TempDir(),
+		CompressionEnabled: true,
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if _, err := storage.storeFile(context.Background(), storage.backend, coredump); err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if coredump.Compression != CompressionGzip {
+		t.Errorf("expected gzip compression, got %q", coredump.Compression)
+	}
+	if coredump.StoredSize <= 0 || coredump.StoredSize >= coredump.Size {
+		t.Errorf("expected a compressed size below %d, got %d", coredump.Size, coredump.StoredSize)
+	}
+}
+
+func TestUsageTrackerProjectsDaysUntilFull(t *testing.T) {
+	start := time.Now()
+	usage := newUsageTracker(start)
+	usage.seed([]*StoredFile{{Path: "milvus-a/old.core.gz", Size: 1000}})
+
+	usage.record("milvus-a/new.core.gz", &collector.CoredumpFile{
+		PodNamespace: "ns", InstanceName: "milvus-a", Size: 4000, StoredSize: 1000,
+	}, start.Add(time.Minute))
+	usage.record("milvus-b/new.core.gz", &collector.CoredumpFile{
+		PodNamespace: "ns", InstanceName: "milvus-b", Size: 4000, StoredSize: 1000,
+	}, start.Add(2*time.Minute))
+
+	stats := usage.stats(10000, start.Add(30*time.Minute))
+	if stats.UsedBytes != 3000 || stats.Files != 3 {
+		t.Errorf("expected 3 files using 3000 bytes, got %d using %d", stats.Files, stats.UsedBytes)
+	}
+	if stats.CompressionRatio != 0.25 {
+		t.Errorf("expected compression ratio 0.25, got %v", stats.CompressionRatio)
+	}
+	if stats.DaysUntilFull != nil {
+		t.Errorf("expected no projection within the first hour, got %v", *stats.DaysUntilFull)
+	}
+
+	// 2000 bytes in a day leave 3.5 days for the remaining 7000.
+	stats = usage.stats(10000, start.Add(24*time.Hour))
+	if stats.DaysUntilFull == nil || *stats.DaysUntilFull != 3.5 {
+		t.Errorf("expected 3.5 days until full, got %v", stats.DaysUntilFull)
+	}
+	if len(stats.ByInstance) != 3 {
+		t.Errorf("expected seeded and recorded files in separate buckets, got %+v", stats.ByInstance)
+	}
+
+	usage.forget("milvus-a/old.core.gz")
+	if stats := usage.stats(10000, start.Add(24*time.Hour)); stats.UsedBytes != 2000 {
+		t.Errorf("expected 2000 bytes after cleanup, got %d", stats.UsedBytes)
+	}
+}
+
+func TestNilStorageEfficiency(t *testing.T) {
+	var storage *Storage
+	if stats := storage.Efficiency(); stats.Files != 0 {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+}
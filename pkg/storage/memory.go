@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// MemoryBackend keeps stored coredumps in process memory. It is selected
+// with backend "memory" for hermetic tests and local development and does
+// not survive restarts.
+type MemoryBackend struct {
+	mu    sync.RWMutex
+	files map[string]*memoryFile
+}
+
+type memoryFile struct {
+	data     []byte
+	storedAt time.Time
+}
+
+func NewMemoryBackend(config *config.StorageConfig) (*MemoryBackend, error) {
+	return &MemoryBackend{
+		files: make(map[string]*memoryFile),
+	}, nil
+}
+
+func (b *MemoryBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	path := generateStorageFilename(file)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.files[path] = &memoryFile{
+		data:     data,
+		storedAt: time.Now(),
+	}
+
+	return path, nil
+}
+
+func (b *MemoryBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	file, exists := b.files[path]
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(file.data)), nil
+}
+
+func (b *MemoryBackend) Delete(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.files[path]; !exists {
+		return fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	delete(b.files, path)
+	return nil
+}
+
+func (b *MemoryBackend) Move(ctx context.Context, from, to string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, exists := b.files[from]
+	if !exists {
+		return fmt.Errorf("%s: %w", from, os.ErrNotExist)
+	}
+	b.files[to] = file
+	delete(b.files, from)
+	return nil
+}
+
+func (b *MemoryBackend) List(ctx context.Context) ([]*StoredFile, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	files := make([]*StoredFile, 0, len(b.files))
+	for path, file := range b.files {
+		files = append(files, &StoredFile{
+			Path:     path,
+			Size:     int64(len(file.data)),
+			StoredAt: file.storedAt,
+		})
+	}
+	return files, nil
+}
+
+func (b *MemoryBackend) GetStorageSize(ctx context.Context) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var totalSize int64
+	for _, file := range b.files {
+		totalSize += int64(len(file.data))
+	}
+	return totalSize, nil
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"milvus-coredump-agent/pkg/analyzer"
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/testutil"
+	"milvus-coredump-agent/pkg/watchlist"
+)
+
+// TestPipelineWithFakes runs analyzer and storage end to end with the fake
+// AI provider and the memory backend, without gdb, S3 or API keys.
+func TestPipelineWithFakes(t *testing.T) {
+	tmpDir, cleanup := testutil.SetupTempDir(t, "pipeline_test")
+	defer cleanup()
+
+	corePath := filepath.Join(tmpDir, "core.milvus.1000.1234567890.11")
+	if err := os.WriteFile(corePath, []byte("synthetic core"), 0644); err != nil {
+		t.Fatalf("Failed to write core file: %v", err)
+	}
+
+	analyzerConfig := &config.AnalyzerConfig{
+		EnableGdbAnalysis: false,
+		ValueThreshold:    4.0,
+		PanicKeywords:     []string{"sigsegv"},
+		AIAnalysis: config.AIAnalysisConfig{
+			Enabled:  true,
+			Provider: "fake",
+			Model:    "fake",
+		},
+	}
+	storageConfig := &config.StorageConfig{
+		Backend:           "memory",
+		MaxStorageSize:    "1GB",
+		RetentionDays:     1,
+		SelfTestOnStartup: true,
+	}
+
+	analyzerManager := analyzer.New(analyzerConfig, nil, nil, nil, nil, nil, nil, nil, "")
+	storageManager, err := New(storageConfig, analyzerConfig, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	collectorEvents := make(chan collector.CollectionEvent, 1)
+	go analyzerManager.Start(ctx, collectorEvents)
+	go storageManager.Start(ctx, analyzerManager.GetEventChannel())
+
+	collectorEvents <- collector.CollectionEvent{
+		Type: collector.EventTypeFileDiscovered,
+		CoredumpFile: &collector.CoredumpFile{
+			Path:       corePath,
+			FileName:   filepath.Base(corePath),
+			Executable: "milvus",
+			Signal:     11,
+			Size:       14,
+			ModTime:    time.Now(),
+			Timestamp:  time.Now(),
+			CreatedAt:  metav1.Now(),
+		},
+		Timestamp: time.Now(),
+	}
+
+	event := testutil.AssertEventReceived(t, storageManager.GetEventChannel(), 5*time.Second, "file stored")
+	if event.Type != EventTypeFileStored {
+		t.Fatalf("expected %s event, got %s (%s)", EventTypeFileStored, event.Type, event.Error)
+	}
+
+	coredump := event.CoredumpFile
+	if coredump.AnalysisResults == nil || coredump.AnalysisResults.AIAnalysis == nil {
+		t.Fatal("expected fake AI analysis to be attached")
+	}
+	if coredump.AnalysisResults.AIAnalysis.ErrorMessage != "" {
+		t.Errorf("unexpected AI error: %s", coredump.AnalysisResults.AIAnalysis.ErrorMessage)
+	}
+
+	reader, err := storageManager.backend.Retrieve(ctx, coredump.StoragePath)
+	if err != nil {
+		t.Fatalf("Failed to retrieve stored core: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); len(data) == 0 {
+		t.Error("expected stored content")
+	}
+
+	if result := storageManager.GetSelfTestResult(); result == nil || !result.Passed {
+		t.Errorf("expected memory backend self-test to pass, got %+v", result)
+	}
+}
+
+// TestPipelineKeepsWatchedCores checks that a core on the watchlist is
+// stored even though its value score is below the store threshold.
+func TestPipelineKeepsWatchedCores(t *testing.T) {
+	tmpDir, cleanup := testutil.SetupTempDir(t, "pipeline_watchlist_test")
+	defer cleanup()
+
+	corePath := filepath.Join(tmpDir, "core.milvus.1000.1234567890.6")
+	if err := os.WriteFile(corePath, []byte("synthetic core"), 0644); err != nil {
+		t.Fatalf("Failed to write core file: %v", err)
+	}
+
+	analyzerConfig := &config.AnalyzerConfig{
+		Thresholds: config.ScoreThresholds{Store: 10},
+		Watchlist:  []config.WatchlistEntry{{Name: "prod", Instance: "^milvus-prod$"}},
+	}
+	watched, err := watchlist.New(analyzerConfig.Watchlist)
+	if err != nil {
+		t.Fatalf("Failed to create watchlist: %v", err)
+	}
+	analyzerManager := analyzer.New(analyzerConfig, nil, nil, nil, nil, watched, nil, nil, "")
+	storageManager, err := New(&config.StorageConfig{Backend: "memory", MaxStorageSize: "1GB", RetentionDays: 1}, analyzerConfig, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	collectorEvents := make(chan collector.CollectionEvent, 1)
+	go analyzerManager.Start(ctx, collectorEvents)
+	go storageManager.Start(ctx, analyzerManager.GetEventChannel())
+
+	collectorEvents <- collector.CollectionEvent{
+		Type: collector.EventTypeFileDiscovered,
+		CoredumpFile: &collector.CoredumpFile{
+			Path:         corePath,
+			FileName:     filepath.Base(corePath),
+			Executable:   "milvus",
+			InstanceName: "milvus-prod",
+			Signal:       6,
+			Size:         14,
+			ModTime:      time.Now(),
+			Timestamp:    time.Now(),
+			CreatedAt:    metav1.Now(),
+		},
+		Timestamp: time.Now(),
+	}
+
+	event := testutil.AssertEventReceived(t, storageManager.GetEventChannel(), 5*time.Second, "file stored")
+	if event.Type != EventTypeFileStored {
+		t.Fatalf("expected %s event, got %s (%s)", EventTypeFileStored, event.Type, event.Error)
+	}
+	if watchlist := event.CoredumpFile.Watchlist; len(watchlist) != 1 || watchlist[0] != "prod" {
+		t.Errorf("expected the core to be tagged with the watchlist entry, got %v", watchlist)
+	}
+}
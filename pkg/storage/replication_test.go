@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func writeTestCore(t *testing.T) *collector.CoredumpFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "core.milvus.1000.1234567890.11")
+	if err := os.WriteFile(path, []byte("synthetic core"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &collector.CoredumpFile{
+		Path:      path,
+		FileName:  filepath.Base(path),
+		Timestamp: time.Now(),
+	}
+}
+
+func TestReplicateWritesToBothBackends(t *testing.T) {
+	storageConfig := &config.StorageConfig{
+		Backend:   "local",
+		LocalPath: t.TempDir(),
+		Secondary: config.SecondaryStorageConfig{Backend: "memory", Mode: "replicate"},
+	}
+	storage, err := New(storageConfig, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	path, backends, err := storage.storeReplicated(context.Background(), writeTestCore(t))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if !reflect.DeepEqual(backends, []string{BackendPrimary, BackendSecondary}) {
+		t.Errorf("expected core on both backends, got %v", backends)
+	}
+	if _, err := os.Stat(filepath.Join(storageConfig.LocalPath, path)); err != nil {
+		t.Errorf("expected core on the primary: %v", err)
+	}
+	reader, err := storage.secondary.Retrieve(context.Background(), path)
+	if err != nil {
+		t.Fatalf("expected core on the secondary: %v", err)
+	}
+	reader.Close()
+}
+
+func TestFailoverUsesSecondaryOnlyOnError(t *testing.T) {
+	_, unavailable := newFakeS3(t, true)
+	storage, err := New(&config.StorageConfig{
+		Backend:   "s3",
+		S3:        unavailable,
+		Secondary: config.SecondaryStorageConfig{Backend: "memory", Mode: "failover"},
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	_, backends, err := storage.storeReplicated(context.Background(), writeTestCore(t))
+	if err != nil {
+		t.Fatalf("expected failover to succeed: %v", err)
+	}
+	if !reflect.DeepEqual(backends, []string{BackendSecondary}) {
+		t.Errorf("expected core only on the secondary, got %v", backends)
+	}
+
+	healthy, err := New(&config.StorageConfig{
+		Backend:   "local",
+		LocalPath: t.TempDir(),
+		Secondary: config.SecondaryStorageConfig{Backend: "memory", Mode: "failover"},
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	_, backends, err = healthy.storeReplicated(context.Background(), writeTestCore(t))
+	if err != nil || !reflect.DeepEqual(backends, []string{BackendPrimary}) {
+		t.Errorf("expected core only on a healthy primary, got %v (%v)", backends, err)
+	}
+}
+
+func TestStoreFailsWhenAllBackendsFail(t *testing.T) {
+	_, unavailable := newFakeS3(t, true)
+	storage, err := New(&config.StorageConfig{
+		Backend:   "s3",
+		S3:        unavailable,
+		Secondary: config.SecondaryStorageConfig{Backend: "nfs", Mode: "replicate"},
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if _, backends, err := storage.storeReplicated(context.Background(), writeTestCore(t)); err == nil {
+		t.Errorf("expected an error, core reported on %v", backends)
+	}
+}
+
+func TestFailoverBetweenBackendsOfOneType(t *testing.T) {
+	_, unavailable := newFakeS3(t, true)
+	_, other := newFakeS3(t, false)
+	storage, err := New(&config.StorageConfig{
+		Backend:   "s3",
+		S3:        unavailable,
+		Secondary: config.SecondaryStorageConfig{Backend: "s3", Mode: "failover", S3: other},
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	coredump := writeTestCore(t)
+	path, backends, err := storage.storeReplicated(context.Background(), coredump)
+	if err != nil || !reflect.DeepEqual(backends, []string{BackendSecondary}) {
+		t.Fatalf("expected the core on the secondary bucket, got %v (%v)", backends, err)
+	}
+	coredump.StoragePath = path
+	coredump.StorageBackends = backends
+
+	reader, err := storage.Open(context.Background(), coredump)
+	if err != nil {
+		t.Fatalf("expected the core to be read from the secondary: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); string(data) != "synthetic core" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestSecondaryCleanupKeepsDedupEntry(t *testing.T) {
+	storage, err := New(&config.StorageConfig{
+		Backend:   "local",
+		LocalPath: t.TempDir(),
+		DedupMode: DedupModeMetadata,
+		Secondary: config.SecondaryStorageConfig{Backend: "memory", Mode: "replicate"},
+	}, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	path, _, err := storage.storeReplicated(context.Background(), writeTestCore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.dedup.record("fingerprint", path)
+
+	// Without retention days every file is past retention.
+	if err := storage.cleanupBackend(context.Background(), BackendSecondary, storage.secondary); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := storage.dedup.lookup("fingerprint"); !found {
+		t.Error("expected the primary's copy to stay the original for duplicates")
+	}
+	if err := storage.cleanupBackend(context.Background(), BackendPrimary, storage.backend); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := storage.dedup.lookup("fingerprint"); found {
+		t.Error("expected the deleted original to be forgotten")
+	}
+}
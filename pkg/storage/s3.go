@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/httpclient"
+)
+
+const (
+	defaultS3Region      = "us-east-1"
+	defaultS3PartSizeMB  = 64
+	defaultS3Concurrency = 4
+)
+
+// S3Backend stores coredumps in an S3 bucket or an S3-compatible store such
+// as MinIO. Cores are streamed in multipart uploads, so multi-GB files are
+// never held in memory whole; at most Concurrency parts are buffered.
+type S3Backend struct {
+	config   *config.S3Config
+	prefix   string
+	client   *s3.Client
+	uploader *manager.Uploader
+}
+
+func NewS3Backend(config *config.StorageConfig) (*S3Backend, error) {
+	s3Config := &config.S3
+	if s3Config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket not configured")
+	}
+
+	// No client timeout, a multi-GB upload takes as long as it takes.
+	httpClient, err := httpclient.New("S3", s3Config.Proxy, s3Config.TLS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 HTTP client: %w", err)
+	}
+
+	var options []func(*awsconfig.LoadOptions) error
+	if s3Config.Region != "" {
+		options = append(options, awsconfig.WithRegion(s3Config.Region))
+	}
+	// Without static keys the SDK's default chain applies: environment,
+	// shared config, IRSA web identity, then the instance role.
+	if s3Config.AccessKey != "" {
+		options = append(options, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(s3Config.AccessKey, s3Config.SecretKey, "")))
+	}
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if awsConfig.Region == "" {
+		awsConfig.Region = defaultS3Region
+	}
+
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		// Set here rather than in LoadDefaultConfig, which rejects a plain
+		// http.Client when AWS_CA_BUNDLE is set; storage.s3.tls covers it.
+		o.HTTPClient = httpClient
+		if s3Config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s3Config.Endpoint)
+		}
+		o.UsePathStyle = s3Config.ForcePathStyle
+	})
+
+	partSizeMB := s3Config.PartSizeMB
+	if partSizeMB <= 0 {
+		partSizeMB = defaultS3PartSizeMB
+	}
+	concurrency := s3Config.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultS3Concurrency
+	}
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = int64(partSizeMB) * 1024 * 1024
+		u.Concurrency = concurrency
+	})
+
+	prefix := strings.Trim(s3Config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	klog.Infof("Using S3 bucket %s (endpoint %q, prefix %q)", s3Config.Bucket, s3Config.Endpoint, prefix)
+	return &S3Backend{
+		config:   s3Config,
+		prefix:   prefix,
+		client:   client,
+		uploader: uploader,
+	}, nil
+}
+
+// Store uploads the core under the same relative path the other backends
+// use; the configured prefix is not part of the returned path.
+func (b *S3Backend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	filename := generateStorageFilename(file)
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(b.config.Bucket),
+		Key:         aws.String(b.prefix + filename),
+		Body:        reader,
+		ContentType: aws.String("application/octet-stream"),
+		Metadata: map[string]string{
+			"instance":    file.InstanceName,
+			"pod":         file.PodNamespace + "/" + file.PodName,
+			"signal":      strconv.Itoa(file.Signal),
+			"value-score": strconv.FormatFloat(file.ValueScore, 'f', 2, 64),
+			"compression": file.Compression,
+		},
+	}
+	if b.config.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(b.config.ServerSideEncryption)
+		if b.config.KMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(b.config.KMSKeyID)
+		}
+	}
+
+	if _, err := b.uploader.Upload(ctx, input); err != nil {
+		return "", fmt.Errorf("failed to upload to s3://%s/%s: %w", b.config.Bucket, b.prefix+filename, err)
+	}
+	return filename, nil
+}
+
+func (b *S3Backend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	output, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.config.Bucket),
+		Key:    aws.String(b.prefix + path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get s3://%s/%s: %w", b.config.Bucket, b.prefix+path, err)
+	}
+	return output.Body, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, path string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.config.Bucket),
+		Key:    aws.String(b.prefix + path),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete s3://%s/%s: %w", b.config.Bucket, b.prefix+path, err)
+	}
+	return nil
+}
+
+// Move copies the object and deletes the original. CopyObject is limited to
+// 5GB; larger objects fail to move and stay where they are.
+func (b *S3Backend) Move(ctx context.Context, from, to string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(b.config.Bucket),
+		Key:        aws.String(b.prefix + to),
+		CopySource: aws.String(url.PathEscape(b.config.Bucket + "/" + b.prefix + from)),
+	}
+	if b.config.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(b.config.ServerSideEncryption)
+		if b.config.KMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(b.config.KMSKeyID)
+		}
+	}
+	if _, err := b.client.CopyObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to copy s3://%s/%s to %s: %w", b.config.Bucket, b.prefix+from, b.prefix+to, err)
+	}
+	return b.Delete(ctx, from)
+}
+
+func (b *S3Backend) List(ctx context.Context) ([]*StoredFile, error) {
+	var files []*StoredFile
+
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.config.Bucket),
+		Prefix: aws.String(b.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", b.config.Bucket, b.prefix, err)
+		}
+		for _, object := range page.Contents {
+			file := &StoredFile{
+				Path: strings.TrimPrefix(aws.ToString(object.Key), b.prefix),
+				Size: aws.ToInt64(object.Size),
+			}
+			if object.LastModified != nil {
+				file.StoredAt = *object.LastModified
+			}
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func (b *S3Backend) GetStorageSize(ctx context.Context) (int64, error) {
+	files, err := b.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	return totalSize, nil
+}
+
+// lifecycleRuleID names the rule ApplyLifecycle owns; other rules on the
+// bucket are left alone.
+const lifecycleRuleID = "milvus-coredump-agent-retention"
+
+// CheckBucket verifies the bucket exists. With provision set a missing
+// bucket is created.
+func (b *S3Backend) CheckBucket(ctx context.Context) error {
+	_, err := b.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(b.config.Bucket)})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to check bucket %s: %w", b.config.Bucket, err)
+	}
+	if !b.config.Provision {
+		return fmt.Errorf("bucket %s does not exist", b.config.Bucket)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(b.config.Bucket)}
+	// us-east-1 rejects an explicit location constraint.
+	if region := b.config.Region; region != "" && region != defaultS3Region {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if _, err := b.client.CreateBucket(ctx, input); err != nil {
+		return fmt.Errorf("failed to create bucket %s: %w", b.config.Bucket, err)
+	}
+	klog.Infof("Created S3 bucket %s", b.config.Bucket)
+	return nil
+}
+
+// ApplyLifecycle expires objects under the prefix after retentionDays, so
+// the bucket is cleaned even while no agent runs. It does nothing unless
+// provision is set, and the bucket's other rules are kept.
+func (b *S3Backend) ApplyLifecycle(ctx context.Context, retentionDays int) error {
+	if !b.config.Provision || retentionDays <= 0 {
+		return nil
+	}
+
+	var rules []types.LifecycleRule
+	output, err := b.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(b.config.Bucket),
+	})
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		rules = output.Rules
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration":
+	default:
+		return fmt.Errorf("failed to get lifecycle of bucket %s: %w", b.config.Bucket, err)
+	}
+
+	rule := types.LifecycleRule{
+		ID:         aws.String(lifecycleRuleID),
+		Status:     types.ExpirationStatusEnabled,
+		Filter:     &types.LifecycleRuleFilterMemberPrefix{Value: b.prefix},
+		Expiration: &types.LifecycleExpiration{Days: aws.Int32(int32(retentionDays))},
+		AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(1),
+		},
+	}
+	replaced := false
+	for i, existing := range rules {
+		if aws.ToString(existing.ID) != lifecycleRuleID {
+			continue
+		}
+		if sameLifecycleRule(existing, b.prefix, retentionDays) {
+			return nil
+		}
+		rules[i] = rule
+		replaced = true
+	}
+	if !replaced {
+		rules = append(rules, rule)
+	}
+
+	_, err = b.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(b.config.Bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set lifecycle of bucket %s: %w", b.config.Bucket, err)
+	}
+	klog.Infof("Set S3 bucket %s to expire %q after %d days", b.config.Bucket, b.prefix, retentionDays)
+	return nil
+}
+
+func sameLifecycleRule(rule types.LifecycleRule, prefix string, days int) bool {
+	if rule.Status != types.ExpirationStatusEnabled || rule.Expiration == nil ||
+		aws.ToInt32(rule.Expiration.Days) != int32(days) {
+		return false
+	}
+	filter, ok := rule.Filter.(*types.LifecycleRuleFilterMemberPrefix)
+	return ok && filter.Value == prefix
+}
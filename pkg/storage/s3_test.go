@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// fakeS3 serves the path-style object API for a single bucket. With refuse
+// set it answers every request with 403, standing in for an outage. With
+// missing set the bucket does not exist until it is created.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	headers  map[string]http.Header
+	refuse   bool
+	requests int
+
+	missing         bool
+	lifecycle       []byte
+	lifecyclePuts   int
+	refuseLifecycle bool
+}
+
+func newFakeS3(t *testing.T, refuse bool) (*fakeS3, config.S3Config) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, headers: map[string]http.Header{}, refuse: refuse}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, config.S3Config{
+		Bucket:         "cores",
+		Region:         "us-east-1",
+		Endpoint:       server.URL,
+		AccessKey:      "test",
+		SecretKey:      "test",
+		ForcePathStyle: true,
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if f.refuse {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>refused</Message></Error>`))
+		return
+	}
+
+	if strings.TrimSuffix(r.URL.Path, "/") == "/cores" && r.URL.Query().Get("list-type") == "" {
+		f.serveBucket(w, r)
+		return
+	}
+	if f.missing {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchBucket</Code></Error>`))
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/cores/")
+	switch {
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		data, exists := f.objects[strings.TrimPrefix(source, "cores/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		f.objects[key] = data
+		f.headers[key] = r.Header.Clone()
+		w.Write([]byte(`<CopyObjectResult><ETag>"copy"</ETag></CopyObjectResult>`))
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+		f.headers[key] = r.Header.Clone()
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodGet:
+		data, exists := f.objects[key]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveBucket handles the bucket itself: HEAD, create and its lifecycle.
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request) {
+	_, lifecycle := r.URL.Query()["lifecycle"]
+	switch {
+	case r.Method == http.MethodHead && f.missing:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodHead:
+	case r.Method == http.MethodPut && !lifecycle:
+		f.missing = false
+	case r.Method == http.MethodGet && lifecycle && f.lifecycle == nil:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchLifecycleConfiguration</Code></Error>`))
+	case r.Method == http.MethodGet && lifecycle:
+		w.Write(f.lifecycle)
+	case r.Method == http.MethodPut && lifecycle && f.refuseLifecycle:
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>refused</Message></Error>`))
+	case r.Method == http.MethodPut && lifecycle:
+		f.lifecycle, _ = io.ReadAll(r.Body)
+		f.lifecyclePuts++
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		LastModified string
+		Size         int
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []content
+	}{Name: "cores", Prefix: prefix}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+			Size:         len(f.objects[key]),
+		})
+	}
+	result.KeyCount = len(keys)
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3BackendRoundTrip(t *testing.T) {
+	fake, s3Config := newFakeS3(t, false)
+	s3Config.Prefix = "/agent-1/"
+	s3Config.ServerSideEncryption = "aws:kms"
+	s3Config.KMSKeyID = "alias/coredumps"
+
+	backend, err := NewS3Backend(&config.StorageConfig{S3: s3Config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	coredump := &collector.CoredumpFile{
+		FileName: "core.milvus.1", InstanceName: "milvus-a", PodName: "querynode-0",
+		PodNamespace: "milvus", Timestamp: time.Now(),
+	}
+	path, err := backend.Store(ctx, coredump, strings.NewReader("synthetic core"))
+	if err != nil {
+		t.Fatalf("store failed: %v", err)
+	}
+	if !strings.HasPrefix(path, "milvus-a/") {
+		t.Errorf("expected the instance directory without the key prefix, got %s", path)
+	}
+	header := fake.headers["agent-1/"+path]
+	if header == nil {
+		t.Fatalf("expected object under the prefix, have %v", fake.objects)
+	}
+	if header.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "alias/coredumps" {
+		t.Errorf("expected SSE-KMS headers, got %v", header)
+	}
+	if header.Get("X-Amz-Meta-Instance") != "milvus-a" {
+		t.Errorf("expected instance metadata, got %v", header)
+	}
+
+	files, err := backend.List(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != path || files[0].Size != int64(len("synthetic core")) {
+		t.Fatalf("unexpected listing: %+v", files)
+	}
+
+	reader, err := backend.Retrieve(ctx, path)
+	if err != nil {
+		t.Fatalf("retrieve failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "synthetic core" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	if err := backend.Delete(ctx, path); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if size, err := backend.GetStorageSize(ctx); err != nil || size != 0 {
+		t.Errorf("expected an empty bucket, got %d (%v)", size, err)
+	}
+}
+
+func TestS3BackendRequiresBucket(t *testing.T) {
+	if _, err := NewS3Backend(&config.StorageConfig{}); err == nil {
+		t.Error("expected an error without a bucket")
+	}
+}
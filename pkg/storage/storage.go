@@ -1,33 +1,45 @@
 package storage
 
 import (
-	"compress/gzip"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/klog/v2"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"milvus-coredump-agent/pkg/chanstats"
 	"milvus-coredump-agent/pkg/analyzer"
 	"milvus-coredump-agent/pkg/collector"
 	"milvus-coredump-agent/pkg/config"
+	"milvus-coredump-agent/pkg/tracing"
 )
 
 type Storage struct {
-	config         *config.StorageConfig
-	analyzerConfig *config.AnalyzerConfig
+	// Swapped by UpdateConfig; see config
+	settings         atomic.Pointer[config.StorageConfig]
+	analyzerSettings atomic.Pointer[config.AnalyzerConfig]
 	backend        Backend
+	secondary      Backend
 	eventChan      chan StorageEvent
+	dedup          *dedupIndex
+	states         *collector.StateMachine
+	usage          *usageTracker
+
+	mu             sync.RWMutex
+	selfTest       *SelfTestResult
 }
 
 type Backend interface {
-	Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) error
+	Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error)
+	Retrieve(ctx context.Context, path string) (io.ReadCloser, error)
 	Delete(ctx context.Context, path string) error
 	List(ctx context.Context) ([]*StoredFile, error)
 	GetStorageSize(ctx context.Context) (int64, error)
@@ -47,6 +59,7 @@ const (
 	EventTypeFileDeleted  EventType = "file_deleted"
 	EventTypeStorageError EventType = "storage_error"
 	EventTypeCleanupDone  EventType = "cleanup_done"
+	EventTypeFileDeduplicated EventType = "file_deduplicated"
 )
 
 type StoredFile struct {
@@ -57,36 +70,129 @@ type StoredFile struct {
 	InstanceName string    `json:"instanceName"`
 }
 
-func New(config *config.StorageConfig, analyzerConfig *config.AnalyzerConfig) (*Storage, error) {
-	var backend Backend
-	var err error
+// SelfTestResult records the outcome of the bucket checks and the
+// write/read/delete probe run against the storage backend at startup.
+type SelfTestResult struct {
+	Backend   string        `json:"backend"`
+	Passed    bool          `json:"passed"`
+	Stage     string        `json:"stage,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checkedAt"`
+	Duration  time.Duration `json:"duration"`
+}
+
+const selfTestProbeName = ".selftest-probe"
+
+// Roles of the backends holding a core, as listed in its StorageBackends.
+// Both backends may be of the same type, e.g. S3 buckets in two regions.
+const (
+	BackendPrimary   = "primary"
+	BackendSecondary = "secondary"
+)
+
+// New creates the storage manager. nodeName and statePath are used by the
+// content dedup mode, which keeps its reference table in statePath.
+func New(config *config.StorageConfig, analyzerConfig *config.AnalyzerConfig, states *collector.StateMachine, nodeName, statePath string) (*Storage, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage backend: %w", err)
+	}
 
+	var secondary Backend
+	if config.Secondary.Backend != "" {
+		secondary, err = newBackend(secondaryConfig(config))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create secondary storage backend: %w", err)
+		}
+		klog.Infof("Storage uses %s as primary and %s as secondary backend (%s)",
+			config.Backend, config.Secondary.Backend, config.Secondary.Mode)
+	}
+
+	if config.DedupMode == DedupModeContent {
+		backend = newContentBackend(backend, nodeName, statePath)
+		if secondary != nil {
+			secondary = newContentBackend(secondary, nodeName, secondaryStatePath(statePath))
+		}
+	}
+
+	storage := &Storage{
+		backend:   backend,
+		secondary: secondary,
+		eventChan: make(chan StorageEvent, 100),
+		states:    states,
+		usage:     newUsageTracker(time.Now()),
+	}
+
+	storage.settings.Store(config)
+	storage.analyzerSettings.Store(analyzerConfig)
+
+	if config.DedupMode == DedupModeMetadata {
+		storage.dedup = newDedupIndex(config.DedupWindow)
+	}
+	chanstats.Register("storage_events", storage.eventChan)
+
+	return storage, nil
+}
+
+// config returns the storage's current configuration, which UpdateConfig
+// may swap at any time.
+func (s *Storage) config() *config.StorageConfig {
+	return s.settings.Load()
+}
+
+// analyzerConfig returns the analyzer configuration the storage reads the
+// store threshold and crash group settings from.
+func (s *Storage) analyzerConfig() *config.AnalyzerConfig {
+	return s.analyzerSettings.Load()
+}
+
+// UpdateConfig swaps in a reloaded configuration. The backends and the
+// deduplication mode are those the storage was created with.
+func (s *Storage) UpdateConfig(config *config.StorageConfig, analyzerConfig *config.AnalyzerConfig) {
+	s.settings.Store(config)
+	s.analyzerSettings.Store(analyzerConfig)
+}
+
+func newBackend(config *config.StorageConfig) (Backend, error) {
 	switch config.Backend {
 	case "local":
-		backend, err = NewLocalBackend(config)
+		return NewLocalBackend(config)
 	case "s3":
-		backend, err = NewS3Backend(config)
+		return NewS3Backend(config)
 	case "nfs":
-		backend, err = NewNFSBackend(config)
+		return NewNFSBackend(config)
+	case "memory":
+		return NewMemoryBackend(config)
 	default:
 		return nil, fmt.Errorf("unsupported storage backend: %s", config.Backend)
 	}
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create storage backend: %w", err)
-	}
-
-	return &Storage{
-		config:         config,
-		analyzerConfig: analyzerConfig,
-		backend:   backend,
-		eventChan: make(chan StorageEvent, 100),
-	}, nil
+// secondaryConfig is the storage config with the secondary backend's
+// settings in place of the primary's.
+func secondaryConfig(primary *config.StorageConfig) *config.StorageConfig {
+	secondary := *primary
+	secondary.Backend = primary.Secondary.Backend
+	secondary.LocalPath = primary.Secondary.LocalPath
+	secondary.S3 = primary.Secondary.S3
+	return &secondary
 }
 
 func (s *Storage) Start(ctx context.Context, analyzerChan <-chan analyzer.AnalysisEvent) error {
 	klog.Info("Starting storage manager")
 
+	if s.config().SelfTestOnStartup {
+		result := s.SelfTest(ctx)
+		if result.Passed {
+			klog.Infof("Storage self-test passed for %s backend in %v", result.Backend, result.Duration)
+		} else {
+			klog.Errorf("Storage self-test failed for %s backend at %s stage: %s",
+				result.Backend, result.Stage, result.Error)
+		}
+	}
+
+	s.seedUsage(ctx)
+
 	go s.processAnalysisEvents(ctx, analyzerChan)
 	go s.periodicCleanup(ctx)
 
@@ -119,16 +225,59 @@ func (s *Storage) processAnalysisEvents(ctx context.Context, analyzerChan <-chan
 }
 
 func (s *Storage) handleAnalyzedFile(ctx context.Context, coredump *collector.CoredumpFile) {
-	if coredump.ValueScore < s.analyzerConfig.ValueThreshold {
+	ctx, span := tracing.Start(ctx, coredump.TraceParent, tracing.SpanStorage)
+	var spanErr error
+	defer func() { tracing.End(span, spanErr) }()
+
+	// Self-test cores only check that the pipeline gets this far; the
+	// backend is checked by SelfTest.
+	if coredump.SelfTest {
+		span.SetAttributes(attribute.String("coredump.storage.outcome", "skipped"))
+		s.states.Transition(coredump, collector.StatusAnalyzed, collector.StatusSkipped, "self-test core")
+		return
+	}
+
+	// Cores on the watchlist are kept whatever their score.
+	if coredump.ValueScore < s.analyzerConfig().EffectiveThresholds().Store && len(coredump.Watchlist) == 0 {
 		klog.Infof("Skipping storage for low-value coredump: %s (score: %.2f)", 
 			coredump.Path, coredump.ValueScore)
+		span.SetAttributes(attribute.String("coredump.storage.outcome", "skipped"))
+		s.states.Transition(coredump, collector.StatusAnalyzed, collector.StatusSkipped, "value score below threshold")
 		return
 	}
 
+	if s.dedup != nil {
+		coredump.Fingerprint = crashFingerprint(coredump, s.analyzerConfig().CrashGroups.Frames)
+		if coredump.Fingerprint != "" {
+			if original, duplicate := s.dedup.lookup(coredump.Fingerprint); duplicate {
+				klog.Infof("Coredump %s duplicates stored file %s (fingerprint %s), keeping metadata only",
+					coredump.Path, original, coredump.Fingerprint[:12])
+
+				coredump.DuplicateOf = original
+				span.SetAttributes(attribute.String("coredump.storage.outcome", "duplicate"))
+				if err := s.states.Transition(coredump, collector.StatusAnalyzed, collector.StatusStored, "duplicate of "+original); err != nil {
+					return
+				}
+
+				s.sendEvent(StorageEvent{
+					Type:         EventTypeFileDeduplicated,
+					CoredumpFile: coredump,
+					Timestamp:    time.Now(),
+				})
+				return
+			}
+		}
+	}
+
 	klog.Infof("Storing coredump file: %s (score: %.2f)", coredump.Path, coredump.ValueScore)
 
-	if err := s.storeFile(ctx, coredump); err != nil {
+	storedPath, backends, err := s.storeReplicated(ctx, coredump)
+	if err != nil {
 		klog.Errorf("Failed to store coredump %s: %v", coredump.Path, err)
+		spanErr = err
+		if err := s.states.Transition(coredump, collector.StatusAnalyzed, collector.StatusError, err.Error()); err != nil {
+			return
+		}
 		
 		event := StorageEvent{
 			Type:         EventTypeStorageError,
@@ -140,8 +289,23 @@ func (s *Storage) handleAnalyzedFile(ctx context.Context, coredump *collector.Co
 		return
 	}
 
-	coredump.Status = collector.StatusStored
-	coredump.UpdatedAt = metav1.Now()
+	if s.dedup != nil && coredump.Fingerprint != "" {
+		s.dedup.record(coredump.Fingerprint, storedPath)
+	}
+
+	coredump.StoragePath = storedPath
+	coredump.StorageBackends = backends
+	span.SetAttributes(
+		attribute.String("coredump.storage.outcome", "stored"),
+		attribute.StringSlice("coredump.storage.backends", backends),
+		attribute.Int64("coredump.storage.size", coredump.StoredSize),
+		attribute.String("coredump.storage.compression", coredump.Compression))
+	if len(backends) > 0 && backends[0] == BackendPrimary {
+		s.usage.record(storedPath, coredump, time.Now())
+	}
+	if err := s.states.Transition(coredump, collector.StatusAnalyzed, collector.StatusStored, ""); err != nil {
+		return
+	}
 
 	event := StorageEvent{
 		Type:         EventTypeFileStored,
@@ -151,42 +315,215 @@ func (s *Storage) handleAnalyzedFile(ctx context.Context, coredump *collector.Co
 	s.sendEvent(event)
 }
 
-func (s *Storage) storeFile(ctx context.Context, coredump *collector.CoredumpFile) error {
+// storeReplicated writes the core to the primary backend and, depending on
+// the secondary mode, to the secondary as well. It returns the roles of the
+// backends that hold the core and fails only when none does. Both backends
+// name files the same way, so one storage path is valid for either.
+func (s *Storage) storeReplicated(ctx context.Context, coredump *collector.CoredumpFile) (string, []string, error) {
+	path, err := s.storeFile(ctx, s.backend, coredump)
+	var backends []string
+	if err == nil {
+		backends = append(backends, BackendPrimary)
+	}
+	if s.secondary == nil {
+		return path, backends, err
+	}
+
+	secondaryName := s.config().Secondary.Backend
+	if s.config().Secondary.Mode == "failover" && err == nil {
+		return path, backends, nil
+	}
+	if err != nil {
+		klog.Warningf("Primary %s backend failed to store %s: %v", s.config().Backend, coredump.Path, err)
+	}
+
+	secondaryPath, secondaryErr := s.storeFile(ctx, s.secondary, coredump)
+	if secondaryErr != nil {
+		klog.Errorf("Secondary %s backend failed to store %s: %v", secondaryName, coredump.Path, secondaryErr)
+		if err != nil {
+			return "", nil, fmt.Errorf("all storage backends failed: %s: %v; %s: %w", s.config().Backend, err, secondaryName, secondaryErr)
+		}
+		return path, backends, nil
+	}
+
+	backends = append(backends, BackendSecondary)
+	if err != nil {
+		klog.Infof("Stored %s on secondary %s backend after primary failure", coredump.Path, secondaryName)
+		return secondaryPath, backends, nil
+	}
+	return path, backends, nil
+}
+
+func (s *Storage) storeFile(ctx context.Context, backend Backend, coredump *collector.CoredumpFile) (string, error) {
 	file, err := os.Open(coredump.Path)
 	if err != nil {
-		return fmt.Errorf("failed to open coredump file: %w", err)
+		return "", fmt.Errorf("failed to open coredump file: %w", err)
 	}
 	defer file.Close()
 
 	var reader io.Reader = file
 
-	if s.config.CompressionEnabled {
-		reader, err = s.compressReader(file)
+	compression := s.compression()
+	if compression != CompressionNone {
+		compressed, err := s.compressReader(file, compression)
 		if err != nil {
-			return fmt.Errorf("failed to compress file: %w", err)
+			return "", fmt.Errorf("failed to compress file: %w", err)
 		}
+		// Unblocks the compressing goroutine when the backend gives up
+		// before reading everything, as a failed upload does.
+		defer compressed.Close()
+		reader = compressed
+	}
+
+	// Set before storing, backends name and tag files after the codec.
+	coredump.Compression = compression
+	counter := &countingReader{reader: reader}
+	path, err := backend.Store(ctx, coredump, counter)
+	if err != nil {
+		return "", err
 	}
+	coredump.StoredSize = counter.n
+	return path, nil
+}
 
-	return s.backend.Store(ctx, coredump, reader)
+// bucketBackend is implemented by backends that store into a bucket, which
+// the self-test checks before probing it.
+type bucketBackend interface {
+	CheckBucket(ctx context.Context) error
+	ApplyLifecycle(ctx context.Context, retentionDays int) error
 }
 
-func (s *Storage) compressReader(reader io.Reader) (io.Reader, error) {
+// SelfTest checks the bucket of bucket backends and applies the retention
+// lifecycle, then writes a small probe object to the backend, reads it back
+// and deletes it again. The result is kept for the health endpoint.
+func (s *Storage) SelfTest(ctx context.Context) *SelfTestResult {
+	start := time.Now()
+	result := &SelfTestResult{
+		Backend:   s.config().Backend,
+		CheckedAt: start,
+	}
+
+	fail := func(stage string, err error) *SelfTestResult {
+		result.Stage = stage
+		result.Error = err.Error()
+		result.Duration = time.Since(start)
+		s.setSelfTestResult(result)
+		return result
+	}
+
+	backend := s.backend
+	if content, ok := backend.(*contentBackend); ok {
+		backend = content.backend
+	}
+	if bucket, ok := backend.(bucketBackend); ok {
+		if err := bucket.CheckBucket(ctx); err != nil {
+			return fail("bucket", err)
+		}
+		// Content blobs are shared with cores stored later, an age based
+		// rule would expire them under the newer cores.
+		if s.config().DedupMode != DedupModeContent {
+			if err := bucket.ApplyLifecycle(ctx, s.config().RetentionDays); err != nil {
+				return fail("lifecycle", err)
+			}
+		}
+	}
+
+	payload := []byte(fmt.Sprintf("milvus-coredump-agent storage probe %d", start.UnixNano()))
+	probe := &collector.CoredumpFile{
+		FileName:  selfTestProbeName,
+		Timestamp: start,
+	}
+
+	path, err := s.backend.Store(ctx, probe, bytes.NewReader(payload))
+	if err != nil {
+		return fail("write", err)
+	}
+
+	reader, err := s.backend.Retrieve(ctx, path)
+	if err != nil {
+		s.backend.Delete(ctx, path)
+		return fail("read", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		s.backend.Delete(ctx, path)
+		return fail("read", err)
+	}
+	if !bytes.Equal(data, payload) {
+		s.backend.Delete(ctx, path)
+		return fail("read", fmt.Errorf("probe content mismatch: wrote %d bytes, read %d bytes", len(payload), len(data)))
+	}
+
+	if err := s.backend.Delete(ctx, path); err != nil {
+		return fail("delete", err)
+	}
+
+	result.Passed = true
+	result.Duration = time.Since(start)
+	s.setSelfTestResult(result)
+	return result
+}
+
+// GetSelfTestResult returns the latest self-test result, or nil if the
+// self-test has not run.
+func (s *Storage) GetSelfTestResult() *SelfTestResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.selfTest
+}
+
+func (s *Storage) setSelfTestResult(result *SelfTestResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selfTest = result
+}
+
+func (s *Storage) compressReader(reader io.Reader, compression string) (io.ReadCloser, error) {
+	codec, exists := codecs[compression]
+	if !exists || codec.writer == nil {
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
 	pr, pw := io.Pipe()
+	writer, err := codec.writer(pw, s.config().CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
 	
 	go func() {
-		defer pw.Close()
-		
-		gzWriter := gzip.NewWriter(pw)
-		defer gzWriter.Close()
-		
-		if _, err := io.Copy(gzWriter, reader); err != nil {
+		if _, err := io.Copy(writer, reader); err != nil {
+			writer.Close()
 			pw.CloseWithError(err)
+			return
 		}
+		pw.CloseWithError(writer.Close())
 	}()
 	
 	return pr, nil
 }
 
+// Open returns the content of a stored core, decompressed, from the first
+// backend that holds it.
+func (s *Storage) Open(ctx context.Context, coredump *collector.CoredumpFile) (io.ReadCloser, error) {
+	if coredump.StoragePath == "" {
+		return nil, fmt.Errorf("coredump %s is not stored", coredump.ID)
+	}
+	backend := s.backend
+	if len(coredump.StorageBackends) > 0 && coredump.StorageBackends[0] == BackendSecondary && s.secondary != nil {
+		backend = s.secondary
+	}
+	reader, err := backend.Retrieve(ctx, coredump.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	decompressed, err := decompressReader(reader, coredump.Compression)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return decompressed, nil
+}
+
 func (s *Storage) periodicCleanup(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
@@ -206,13 +543,33 @@ func (s *Storage) periodicCleanup(ctx context.Context) {
 func (s *Storage) performCleanup(ctx context.Context) error {
 	klog.Info("Starting storage cleanup")
 
-	files, err := s.backend.List(ctx)
+	if err := s.cleanupBackend(ctx, s.config().Backend, s.backend); err != nil {
+		return err
+	}
+	// Retention and the size limit apply to each backend on its own.
+	if s.secondary != nil {
+		if err := s.cleanupBackend(ctx, s.config().Secondary.Backend, s.secondary); err != nil {
+			return err
+		}
+	}
+
+	event := StorageEvent{
+		Type:      EventTypeCleanupDone,
+		Timestamp: time.Now(),
+	}
+	s.sendEvent(event)
+
+	return nil
+}
+
+func (s *Storage) cleanupBackend(ctx context.Context, name string, backend Backend) error {
+	files, err := backend.List(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list stored files: %w", err)
+		return fmt.Errorf("failed to list files stored on %s backend: %w", name, err)
 	}
 
 	now := time.Now()
-	retentionTime := time.Duration(s.config.RetentionDays) * 24 * time.Hour
+	retentionTime := time.Duration(s.config().RetentionDays) * 24 * time.Hour
 
 	var filesToDelete []*StoredFile
 	var totalSize int64
@@ -225,7 +582,7 @@ func (s *Storage) performCleanup(ctx context.Context) error {
 		}
 	}
 
-	maxSize := s.parseSize(s.config.MaxStorageSize)
+	maxSize := s.parseSize(s.config().MaxStorageSize)
 	if totalSize > maxSize {
 		klog.Infof("Storage size (%d) exceeds limit (%d), cleaning up low-value files", 
 			totalSize, maxSize)
@@ -245,52 +602,32 @@ func (s *Storage) performCleanup(ctx context.Context) error {
 
 	deletedCount := 0
 	for _, file := range filesToDelete {
-		if err := s.backend.Delete(ctx, file.Path); err != nil {
+		if err := backend.Delete(ctx, file.Path); err != nil {
 			klog.Errorf("Failed to delete file %s: %v", file.Path, err)
 			continue
 		}
 		deletedCount++
+		// A replica deleted from the secondary leaves the primary's copy,
+		// which duplicates still point to.
+		if backend == s.backend {
+			if s.dedup != nil {
+				s.dedup.forget(file.Path)
+			}
+			s.usage.forget(file.Path)
+		}
 		klog.V(2).Infof("Deleted old coredump file: %s", file.Path)
 	}
 
-	klog.Infof("Storage cleanup completed, deleted %d files", deletedCount)
-
-	event := StorageEvent{
-		Type:      EventTypeCleanupDone,
-		Timestamp: time.Now(),
-	}
-	s.sendEvent(event)
-
+	klog.Infof("Storage cleanup of %s backend completed, deleted %d files", name, deletedCount)
 	return nil
 }
 
 func (s *Storage) parseSize(sizeStr string) int64 {
-	sizeStr = strings.ToUpper(strings.TrimSpace(sizeStr))
-	
-	var multiplier int64 = 1
-	if strings.HasSuffix(sizeStr, "GB") {
-		multiplier = 1024 * 1024 * 1024
-		sizeStr = strings.TrimSuffix(sizeStr, "GB")
-	} else if strings.HasSuffix(sizeStr, "MB") {
-		multiplier = 1024 * 1024
-		sizeStr = strings.TrimSuffix(sizeStr, "MB")
-	} else if strings.HasSuffix(sizeStr, "KB") {
-		multiplier = 1024
-		sizeStr = strings.TrimSuffix(sizeStr, "KB")
-	}
-
-	var size int64 = 50 * 1024 * 1024 * 1024 // default 50GB
-	if _, err := fmt.Sscanf(sizeStr, "%d", &size); err == nil {
-		size *= multiplier
-	}
-
-	return size
+	return config.ParseSize(sizeStr, 50*1024*1024*1024) // default 50GB
 }
 
 func (s *Storage) sendEvent(event StorageEvent) {
-	select {
-	case s.eventChan <- event:
-	default:
+	if !chanstats.TrySend("storage_events", s.eventChan, event) {
 		klog.Warning("Storage event channel is full, dropping event")
 	}
 }
@@ -309,25 +646,29 @@ func NewLocalBackend(config *config.StorageConfig) (*LocalBackend, error) {
 	}, nil
 }
 
-func (b *LocalBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) error {
-	filename := b.generateStorageFilename(file)
+func (b *LocalBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	filename := generateStorageFilename(file)
 	fullPath := filepath.Join(b.basePath, filename)
 
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	outFile, err := os.Create(fullPath)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return "", fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
 	if _, err := io.Copy(outFile, reader); err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+		return "", fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	return nil
+	return filename, nil
+}
+
+func (b *LocalBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(b.basePath, path))
 }
 
 func (b *LocalBackend) Delete(ctx context.Context, path string) error {
@@ -335,6 +676,14 @@ func (b *LocalBackend) Delete(ctx context.Context, path string) error {
 	return os.Remove(fullPath)
 }
 
+func (b *LocalBackend) Move(ctx context.Context, from, to string) error {
+	fullPath := filepath.Join(b.basePath, to)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.Rename(filepath.Join(b.basePath, from), fullPath)
+}
+
 func (b *LocalBackend) List(ctx context.Context) ([]*StoredFile, error) {
 	var files []*StoredFile
 
@@ -378,43 +727,17 @@ func (b *LocalBackend) GetStorageSize(ctx context.Context) (int64, error) {
 	return totalSize, err
 }
 
-func (b *LocalBackend) generateStorageFilename(file *collector.CoredumpFile) string {
+func generateStorageFilename(file *collector.CoredumpFile) string {
 	timestamp := file.Timestamp.Format("2006-01-02_15-04-05")
 	
 	if file.InstanceName != "" && file.PodName != "" {
 		return filepath.Join(
 			file.InstanceName,
-			fmt.Sprintf("%s_%s_%s.core.gz", timestamp, file.PodName, file.ContainerName),
+			fmt.Sprintf("%s_%s_%s%s", timestamp, file.PodName, file.ContainerName, storageExtension(file.Compression)),
 		)
 	}
 	
-	return fmt.Sprintf("%s_%s.core.gz", timestamp, file.FileName)
-}
-
-type S3Backend struct {
-	config *config.S3Config
-}
-
-func NewS3Backend(config *config.StorageConfig) (*S3Backend, error) {
-	return &S3Backend{
-		config: &config.S3,
-	}, nil
-}
-
-func (b *S3Backend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) error {
-	return fmt.Errorf("S3 backend not implemented yet")
-}
-
-func (b *S3Backend) Delete(ctx context.Context, path string) error {
-	return fmt.Errorf("S3 backend not implemented yet")
-}
-
-func (b *S3Backend) List(ctx context.Context) ([]*StoredFile, error) {
-	return nil, fmt.Errorf("S3 backend not implemented yet")
-}
-
-func (b *S3Backend) GetStorageSize(ctx context.Context) (int64, error) {
-	return 0, fmt.Errorf("S3 backend not implemented yet")
+	return fmt.Sprintf("%s_%s%s", timestamp, file.FileName, storageExtension(file.Compression))
 }
 
 type NFSBackend struct {
@@ -427,8 +750,12 @@ func NewNFSBackend(config *config.StorageConfig) (*NFSBackend, error) {
 	}, nil
 }
 
-func (b *NFSBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) error {
-	return fmt.Errorf("NFS backend not implemented yet")
+func (b *NFSBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	return "", fmt.Errorf("NFS backend not implemented yet")
+}
+
+func (b *NFSBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("NFS backend not implemented yet")
 }
 
 func (b *NFSBackend) Delete(ctx context.Context, path string) error {
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+// failingBackend fails the operation named by stage and passes the rest
+// through to the wrapped backend.
+type failingBackend struct {
+	Backend
+	stage string
+}
+
+func (b *failingBackend) Store(ctx context.Context, file *collector.CoredumpFile, reader io.Reader) (string, error) {
+	if b.stage == "write" {
+		return "", errors.New("disk full")
+	}
+	return b.Backend.Store(ctx, file, reader)
+}
+
+func (b *failingBackend) Retrieve(ctx context.Context, path string) (io.ReadCloser, error) {
+	if b.stage == "read" {
+		return nil, errors.New("i/o error")
+	}
+	return b.Backend.Retrieve(ctx, path)
+}
+
+func (b *failingBackend) Delete(ctx context.Context, path string) error {
+	if b.stage == "delete" {
+		return errors.New("permission denied")
+	}
+	return b.Backend.Delete(ctx, path)
+}
+
+func TestSelfTestProvisionsBucket(t *testing.T) {
+	fake, s3Config := newFakeS3(t, false)
+	fake.missing = true
+	s3Config.Prefix = "agent"
+	s3Config.Provision = true
+	storage, err := New(&config.StorageConfig{Backend: "s3", S3: s3Config, RetentionDays: 7},
+		&config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if result := storage.SelfTest(context.Background()); !result.Passed {
+		t.Fatalf("expected the self-test to pass, failed at %s: %s", result.Stage, result.Error)
+	}
+	fake.mu.Lock()
+	lifecycle := string(fake.lifecycle)
+	missing := fake.missing
+	fake.mu.Unlock()
+	if missing {
+		t.Error("expected the missing bucket to be created")
+	}
+	for _, want := range []string{lifecycleRuleID, "<Days>7</Days>", "<Prefix>agent/</Prefix>"} {
+		if !strings.Contains(lifecycle, want) {
+			t.Errorf("expected %s in the lifecycle, got %s", want, lifecycle)
+		}
+	}
+
+	storage.SelfTest(context.Background())
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.lifecyclePuts != 1 {
+		t.Errorf("expected an unchanged lifecycle to be left alone, put %d times", fake.lifecyclePuts)
+	}
+}
+
+func TestSelfTestSkipsLifecycleForContentDedup(t *testing.T) {
+	fake, s3Config := newFakeS3(t, false)
+	fake.refuseLifecycle = true
+	s3Config.Provision = true
+	storage, err := New(&config.StorageConfig{Backend: "s3", S3: s3Config, RetentionDays: 7, DedupMode: DedupModeContent},
+		&config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if result := storage.SelfTest(context.Background()); !result.Passed {
+		t.Errorf("expected the self-test to pass, failed at %s: %s", result.Stage, result.Error)
+	}
+}
+
+func TestSelfTestReportsFailingStage(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage string
+		setup func(t *testing.T) *Storage
+	}{
+		{
+			name:  "missing bucket",
+			stage: "bucket",
+			setup: func(t *testing.T) *Storage {
+				fake, s3Config := newFakeS3(t, false)
+				fake.missing = true
+				return newSelfTestStorage(t, &config.StorageConfig{Backend: "s3", S3: s3Config})
+			},
+		},
+		{
+			name:  "lifecycle refused",
+			stage: "lifecycle",
+			setup: func(t *testing.T) *Storage {
+				fake, s3Config := newFakeS3(t, false)
+				fake.refuseLifecycle = true
+				s3Config.Provision = true
+				return newSelfTestStorage(t, &config.StorageConfig{Backend: "s3", S3: s3Config, RetentionDays: 7})
+			},
+		},
+		{name: "write fails", stage: "write", setup: failingStorage("write")},
+		{name: "read fails", stage: "read", setup: failingStorage("read")},
+		{name: "delete fails", stage: "delete", setup: failingStorage("delete")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := tt.setup(t)
+			result := storage.SelfTest(context.Background())
+			if result.Passed || result.Stage != tt.stage || result.Error == "" {
+				t.Errorf("expected a failure at the %s stage, got %+v", tt.stage, result)
+			}
+			if storage.GetSelfTestResult() != result {
+				t.Error("expected the result to be kept for the health endpoint")
+			}
+		})
+	}
+}
+
+func newSelfTestStorage(t *testing.T, storageConfig *config.StorageConfig) *Storage {
+	t.Helper()
+	storage, err := New(storageConfig, &config.AnalyzerConfig{}, nil, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return storage
+}
+
+func failingStorage(stage string) func(t *testing.T) *Storage {
+	return func(t *testing.T) *Storage {
+		storage := newSelfTestStorage(t, &config.StorageConfig{Backend: "memory"})
+		storage.backend = &failingBackend{Backend: storage.backend, stage: stage}
+		return storage
+	}
+}
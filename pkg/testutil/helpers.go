@@ -63,7 +63,7 @@ func CreateTestFiles(t *testing.T, dir string, files []TestFileInfo) {
 
 
 // LoadTestGDBOutput loads test GDB output from testdata
-func LoadTestGDBOutput(t *testing.T, filename string) string {
+func LoadTestGDBOutput(t testing.TB, filename string) string {
 	data, err := ioutil.ReadFile(filepath.Join("../../testdata/gdb_outputs", filename))
 	if err != nil {
 		t.Fatalf("Failed to load test GDB output %s: %v", filename, err)
@@ -71,6 +71,15 @@ func LoadTestGDBOutput(t *testing.T, filename string) string {
 	return string(data)
 }
 
+// LoadTestDelveOutput loads test delve output from testdata
+func LoadTestDelveOutput(t testing.TB, filename string) string {
+	data, err := ioutil.ReadFile(filepath.Join("../../testdata/dlv_outputs", filename))
+	if err != nil {
+		t.Fatalf("Failed to load test delve output %s: %v", filename, err)
+	}
+	return string(data)
+}
+
 // AssertEventReceived asserts that an event is received on a channel within timeout
 func AssertEventReceived[T any](t *testing.T, ch <-chan T, timeout time.Duration, msgAndArgs ...interface{}) T {
 	select {
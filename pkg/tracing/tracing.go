@@ -0,0 +1,169 @@
+// Package tracing exports OpenTelemetry spans that follow a coredump
+// through the pipeline: discovery, collection, analysis with gdb and the AI
+// provider, storage and cleanup. A coredump carries its trace as a W3C
+// traceparent, so every stage adds its spans to the same trace, however
+// long the core waited in between.
+//
+// Stages start their spans through the global tracer provider, which New
+// installs; without it spans are not recorded and cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+
+	"milvus-coredump-agent/pkg/config"
+)
+
+// Span names of the pipeline stages.
+const (
+	SpanDiscovery     = "coredump.discovery"
+	SpanCollection    = "coredump.collection"
+	SpanAnalysisQueue = "coredump.analysis.queue"
+	SpanAnalysis      = "coredump.analysis"
+	SpanGdb           = "coredump.analysis.gdb"
+	SpanAI            = "coredump.analysis.ai"
+	SpanStorage       = "coredump.storage"
+	SpanCleanup       = "coredump.cleanup"
+)
+
+const (
+	serviceName = "milvus-coredump-agent"
+	// How long Shutdown waits for the last spans to be exported.
+	shutdownTimeout = 5 * time.Second
+)
+
+var propagator = propagation.TraceContext{}
+
+// Provider exports the spans of the agent.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// New installs a tracer provider exporting over OTLP as the global one.
+// Spans name nodeName and the agent's version.
+func New(ctx context.Context, config *config.TracingConfig, nodeName, version string) (*Provider, error) {
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	attributes := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	}
+	if nodeName != "" {
+		attributes = append(attributes, semconv.K8SNodeName(nodeName))
+	}
+
+	// Stages follow the decision made for the coredump's first span, so a
+	// trace is exported whole or not at all.
+	sampler := sdktrace.ParentBased(sdktrace.AlwaysSample())
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attributes...)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		klog.V(2).Infof("Tracing error: %v", err)
+	}))
+
+	protocol := config.Protocol
+	if protocol == "" {
+		protocol = "grpc"
+	}
+	klog.Infof("Exporting traces over OTLP/%s to %s", protocol, endpointName(config.Endpoint))
+	return &Provider{provider: provider}, nil
+}
+
+func newExporter(ctx context.Context, config *config.TracingConfig) (*otlptrace.Exporter, error) {
+	if config.Protocol == "http" {
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, options...)
+	}
+
+	var options []otlptracegrpc.Option
+	if config.Endpoint != "" {
+		options = append(options, otlptracegrpc.WithEndpoint(config.Endpoint))
+	}
+	if config.Insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, options...)
+}
+
+func endpointName(endpoint string) string {
+	if endpoint == "" {
+		return "the endpoint of the OTEL_EXPORTER_OTLP_* environment"
+	}
+	return endpoint
+}
+
+// Shutdown exports the spans still buffered and stops the provider.
+func (p *Provider) Shutdown() {
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := p.provider.Shutdown(ctx); err != nil {
+		klog.Warningf("Failed to export the last spans: %v", err)
+	}
+}
+
+// Start starts the span of a pipeline stage as a child of the span in ctx,
+// else of the coredump's trace as recorded in traceParent. With neither,
+// the span starts a new trace.
+func Start(ctx context.Context, traceParent, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if traceParent != "" && !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+	}
+	return otel.Tracer(serviceName).Start(ctx, name, options...)
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, which may
+// have ended, and is empty when tracing is off. It carries the sampling
+// decision, so the later stages of an unsampled core are not recorded
+// either.
+func TraceParent(ctx context.Context) string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ""
+	}
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
+
+// End ends span, marking it failed with err when set.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStagesJoinTheCoredumpTrace(t *testing.T) {
+	// Without a provider, spans are not recorded and cores carry no trace.
+	ctx, span := Start(context.Background(), "", SpanDiscovery)
+	span.End()
+	if traceParent := TraceParent(ctx); traceParent != "" {
+		t.Fatalf("expected no trace parent without a provider, got %q", traceParent)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	ctx, discovered := Start(context.Background(), "", SpanDiscovery)
+	discovered.End()
+	traceParent := TraceParent(ctx)
+	if traceParent == "" {
+		t.Fatal("expected the discovery span to record a trace parent")
+	}
+
+	// A later stage only has the trace parent the core carries.
+	ctx, analysis := Start(context.Background(), traceParent, SpanAnalysis)
+	_, ai := Start(ctx, "unused", SpanAI)
+	End(ai, errors.New("provider timed out"))
+	End(analysis, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected three spans, got %d", len(spans))
+	}
+	root, stage, call := spans[0], spans[2], spans[1]
+	if stage.Name() != SpanAnalysis || stage.SpanContext().TraceID() != root.SpanContext().TraceID() ||
+		stage.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("expected the analysis to continue the discovery's trace, got %s under %s", stage.Name(), stage.Parent().SpanID())
+	}
+	if call.Name() != SpanAI || call.Parent().SpanID() != stage.SpanContext().SpanID() {
+		t.Errorf("expected the AI call under the analysis, got %s under %s", call.Name(), call.Parent().SpanID())
+	}
+	if call.Status().Code != codes.Error || len(call.Events()) != 1 || stage.Status().Code == codes.Error {
+		t.Errorf("expected only the AI call to fail, got %v and %v", call.Status(), stage.Status())
+	}
+}
@@ -0,0 +1,98 @@
+// Package watchlist singles out the cores of a bug being hunted in
+// production. Watched cores are analyzed without waiting for resource
+// pressure to ease, always get a fresh AI analysis, are stored whatever
+// their value score and alert as critical right away.
+package watchlist
+
+import (
+	"fmt"
+	"regexp"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+type entry struct {
+	name       string
+	executable *regexp.Regexp
+	instance   *regexp.Regexp
+	stack      *regexp.Regexp
+}
+
+// List matches cores against the watchlist entries. A nil List matches
+// nothing.
+type List struct {
+	entries []entry
+}
+
+// New compiles the entries' patterns; it returns nil for an empty watchlist.
+func New(entries []config.WatchlistEntry) (*List, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	list := &List{}
+	for i, e := range entries {
+		name := e.Name
+		if name == "" {
+			name = fmt.Sprintf("watchlist-%d", i)
+		}
+		compiled := entry{name: name}
+		for _, pattern := range []struct {
+			field  string
+			source string
+			target **regexp.Regexp
+		}{
+			{"executable", e.Executable, &compiled.executable},
+			{"instance", e.Instance, &compiled.instance},
+			{"stack", e.Stack, &compiled.stack},
+		} {
+			if pattern.source == "" {
+				continue
+			}
+			re, err := regexp.Compile(pattern.source)
+			if err != nil {
+				return nil, fmt.Errorf("watchlist entry %s: invalid %s pattern: %w", name, pattern.field, err)
+			}
+			*pattern.target = re
+		}
+		if compiled.executable == nil && compiled.instance == nil && compiled.stack == nil {
+			return nil, fmt.Errorf("watchlist entry %s has no patterns", name)
+		}
+		list.entries = append(list.entries, compiled)
+	}
+	return list, nil
+}
+
+// Candidate reports whether the core may match an entry once its stack
+// trace is known, judging by its executable and instance alone.
+func (l *List) Candidate(coredump *collector.CoredumpFile) bool {
+	if l == nil {
+		return false
+	}
+	for _, e := range l.entries {
+		if e.matchesFile(coredump) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the names of the entries the analyzed core matches.
+func (l *List) Match(coredump *collector.CoredumpFile, stack string) []string {
+	if l == nil {
+		return nil
+	}
+	var names []string
+	for _, e := range l.entries {
+		if e.matchesFile(coredump) && (e.stack == nil || e.stack.MatchString(stack)) {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+func (e *entry) matchesFile(coredump *collector.CoredumpFile) bool {
+	return (e.executable == nil || e.executable.MatchString(coredump.Executable)) &&
+		(e.instance == nil || e.instance.MatchString(coredump.InstanceName))
+}
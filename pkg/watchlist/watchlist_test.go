@@ -0,0 +1,54 @@
+package watchlist
+
+import (
+	"reflect"
+	"testing"
+
+	"milvus-coredump-agent/pkg/collector"
+	"milvus-coredump-agent/pkg/config"
+)
+
+func TestMatch(t *testing.T) {
+	list, err := New([]config.WatchlistEntry{
+		{Name: "hnsw-null-segment", Executable: "^milvus$", Stack: `knowhere::IndexHNSW`},
+		{Name: "prod", Instance: "^prod-"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	prod := &collector.CoredumpFile{Executable: "milvus", InstanceName: "prod-east"}
+	if !list.Candidate(prod) {
+		t.Error("expected a candidate by executable alone")
+	}
+	if names := list.Match(prod, "#0 knowhere::IndexHNSW::Search"); !reflect.DeepEqual(names, []string{"hnsw-null-segment", "prod"}) {
+		t.Errorf("expected both entries to match, got %v", names)
+	}
+	if names := list.Match(prod, "#0 raise"); !reflect.DeepEqual(names, []string{"prod"}) {
+		t.Errorf("expected only the instance entry without the stack, got %v", names)
+	}
+
+	other := &collector.CoredumpFile{Executable: "minio", InstanceName: "staging"}
+	if list.Candidate(other) || list.Match(other, "knowhere::IndexHNSW") != nil {
+		t.Error("expected no match for another executable and instance")
+	}
+
+	var empty *List
+	if empty.Candidate(prod) || empty.Match(prod, "") != nil {
+		t.Error("expected a nil list to match nothing")
+	}
+}
+
+func TestNewRejectsInvalidEntries(t *testing.T) {
+	for _, entries := range [][]config.WatchlistEntry{
+		{{Name: "broken", Stack: "("}},
+		{{Name: "empty"}},
+	} {
+		if _, err := New(entries); err == nil {
+			t.Errorf("expected an error for %+v", entries)
+		}
+	}
+	if list, err := New(nil); list != nil || err != nil {
+		t.Errorf("expected no list for an empty watchlist, got %v (%v)", list, err)
+	}
+}